@@ -0,0 +1,70 @@
+package batch
+
+import (
+	"context"
+	"time"
+)
+
+// Chunks 分块执行：按提交顺序每 Size 个任务为一块，逐块执行，相邻两块之间暂停 Pause
+// 块内仍按 Mode、MaxConcurrency 并发执行（Ramp 在每块开始时重新预热），用于向脆弱的下游逐步施压，
+// 避免整个批次一开始就以满并发压上去
+type Chunks struct {
+	Size  int           // 每块的任务数，小于1时不分块
+	Pause time.Duration // 上一块全部完成后等待多久开始下一块
+	// OnPause 块间暂停开始（paused 为 true）和结束时调用，为nil时不通知
+	OnPause func(paused bool)
+}
+
+// enabled 是否需要分块
+func (c *Chunks) enabled(tasks int) bool {
+	return c != nil && c.Size > 0 && tasks > c.Size
+}
+
+func (c *Chunks) notifyPause(paused bool) {
+	if c.OnPause != nil {
+		c.OnPause(paused)
+	}
+}
+
+// eachChunk 逐块执行任务，Timeout 为整个批次（含块间暂停）的时间，到期或 ctx 取消后不再开始剩余的块
+// 运行中调整的并发数在之后的块中继续生效，块间暂停期间不能调整
+func (p *Processor[T, R]) eachChunk(ctx context.Context, tasks []T, fn func(R)) int {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	deadline, hasDeadline := ctx.Deadline()
+
+	chunk := *p
+	chunk.Chunks = nil
+	collected := 0
+	for start := 0; start < len(tasks); start += p.Chunks.Size {
+		if start > 0 && p.Chunks.Pause > 0 {
+			p.Chunks.notifyPause(true)
+			timer := time.NewTimer(p.Chunks.Pause)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+			p.Chunks.notifyPause(false)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if hasDeadline {
+			chunk.Timeout = time.Until(deadline)
+		}
+		offset := start
+		chunk.Run = func(ctx context.Context, index, slot int, task T) R {
+			return p.Run(ctx, offset+index, slot, task)
+		}
+		end := min(start+p.Chunks.Size, len(tasks))
+		collected += chunk.Each(ctx, tasks[start:end], fn)
+		if n := p.Concurrency.lastLimit(); n > 0 {
+			chunk.MaxConcurrency = n
+		}
+	}
+	return collected
+}
@@ -0,0 +1,84 @@
+package batch
+
+import (
+	"context"
+	"time"
+)
+
+// StopReason 收集结束的原因
+type StopReason int
+
+const (
+	// StopCompleted 结果通道已关闭或已收到 Expected 个结果
+	StopCompleted StopReason = iota
+	// StopTimeout 超过 Collector.Timeout
+	StopTimeout
+	// StopCancelled ctx 被取消或到期
+	StopCancelled
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopTimeout:
+		return "timeout"
+	case StopCancelled:
+		return "cancelled"
+	}
+	return "completed"
+}
+
+// Collector 从结果通道收集结果，直到通道关闭、收齐、超时或 ctx 结束
+// 超时或取消时，已经写入通道但尚未读取的结果仍会被收集，不会因为与计时器同时就绪而被丢弃；
+// 之后才写入通道的结果不再收集，由调用方按缺失处理
+type Collector[R any] struct {
+	Timeout  time.Duration // 收集的总时间，0表示不限制
+	Expected int           // 收到该数量的结果后立即结束，用于不会关闭的通道；0表示等待通道关闭
+	// OnResult 每收集到一个结果时调用，在 Collect 的调用方协程中串行执行，阻塞会延后后续结果的收集
+	OnResult func(result R)
+}
+
+// Collect 收集 results 中的结果，返回收集到的结果数和结束原因
+func (c *Collector[R]) Collect(ctx context.Context, results <-chan R) (int, StopReason) {
+	var timeout <-chan time.Time
+	if c.Timeout > 0 {
+		timer := time.NewTimer(c.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	collected := 0
+	// accept 记录一个结果，收齐时返回 true
+	accept := func(result R) bool {
+		collected++
+		if c.OnResult != nil {
+			c.OnResult(result)
+		}
+		return c.Expected > 0 && collected >= c.Expected
+	}
+	// drain 收集通道中已就绪的结果后以 reason 结束；期间收齐或通道关闭时视为正常完成
+	drain := func(reason StopReason) (int, StopReason) {
+		for {
+			select {
+			case result, ok := <-results:
+				if !ok || accept(result) {
+					return collected, StopCompleted
+				}
+			default:
+				return collected, reason
+			}
+		}
+	}
+
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok || accept(result) {
+				return collected, StopCompleted
+			}
+		case <-timeout:
+			return drain(StopTimeout)
+		case <-ctx.Done():
+			return drain(StopCancelled)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package batch
+
+import "sync"
+
+// MaxConcurrencyLimit 运行中调整并发数的上限
+const MaxConcurrencyLimit = 1000
+
+// Concurrency 运行中可调整的并发数，零值可用
+// 设置到 Processor.Concurrency 后，Process/Each 开始执行时以 MaxConcurrency 初始化，返回时失效；
+// 期间调用 Set 即时生效：调高时立即开放新的槽位（或启动新的工作协程），调低时正在执行的任务不受影响，
+// 超出新并发数的槽位在其任务完成后收回。同一时刻只能被一个执行中的批次使用
+type Concurrency struct {
+	mu      sync.Mutex
+	limit   int // 0表示未在执行
+	last    int // 上一次执行结束时的并发数
+	changed chan struct{}
+}
+
+// Set 将并发数调整为 n（限制在 [1, MaxConcurrencyLimit]），返回调整后的并发数；批次未在执行时返回 false
+func (c *Concurrency) Set(n int) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.limit == 0 {
+		return 0, false
+	}
+	c.limit = min(max(n, 1), MaxConcurrencyLimit)
+	select {
+	case c.changed <- struct{}{}:
+	default:
+		// 已有未处理的通知，处理时读取最新的并发数
+	}
+	return c.limit, true
+}
+
+// Limit 返回当前的并发数，批次未在执行时返回0
+func (c *Concurrency) Limit() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
+
+// begin 批次开始执行，返回并发数变化的通知通道
+func (c *Concurrency) begin(size int) <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.limit = size
+	c.changed = make(chan struct{}, 1)
+	return c.changed
+}
+
+// end 批次返回，此后的 Set 不再生效
+func (c *Concurrency) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.last, c.limit = c.limit, 0
+}
+
+// lastLimit 返回上一次执行结束时的并发数，c 为nil或未执行过时返回0
+func (c *Concurrency) lastLimit() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// watch 批次执行期间每次并发数变化时以新的并发数调用 fn，stop 关闭时返回
+func (c *Concurrency) watch(changed <-chan struct{}, stop <-chan struct{}, fn func(limit int)) {
+	for {
+		select {
+		case <-changed:
+			if limit := c.Limit(); limit > 0 {
+				fn(limit)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// slotPool 可调整大小的工作槽位池，用作带编号的信号量：从 c 取得槽位，用完后 release
+// 流通中（空闲或被占用）的槽位编号始终小于容量，调低后超出 limit 的槽位在归还时收回
+type slotPool struct {
+	c     chan int
+	mu    sync.Mutex
+	limit int
+	open  []bool // 槽位是否在流通中
+}
+
+// newResizableSlotPool 创建容量为 capacity、并发数为 limit 的槽位池，初始放入槽位 [0, available)
+func newResizableSlotPool(capacity, limit, available int) *slotPool {
+	capacity = max(capacity, limit, 1)
+	p := &slotPool{c: make(chan int, capacity), limit: limit, open: make([]bool, capacity)}
+	for slot := 0; slot < min(max(available, 1), limit); slot++ {
+		p.add(slot)
+	}
+	return p
+}
+
+// add 放入一个槽位，已在流通中或超出当前并发数时忽略
+func (p *slotPool) add(slot int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if slot >= p.limit || p.open[slot] {
+		return
+	}
+	p.open[slot] = true
+	p.c <- slot
+}
+
+// release 归还槽位，超出当前并发数时收回
+func (p *slotPool) release(slot int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if slot >= p.limit {
+		p.open[slot] = false
+		return
+	}
+	p.c <- slot
+}
+
+// resize 调整并发数，调高时放入 [0, limit) 中未流通的槽位
+func (p *slotPool) resize(limit int) {
+	p.mu.Lock()
+	p.limit = min(limit, len(p.open))
+	p.mu.Unlock()
+	for slot := 0; slot < limit && slot < len(p.open); slot++ {
+		p.add(slot)
+	}
+}
@@ -0,0 +1,231 @@
+package batch
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrDependencyCycle 任务之间的依赖关系存在环
+var ErrDependencyCycle = errors.New("任务依赖关系存在环")
+
+// DependencyError 任务因依赖的任务未成功而未执行，传给 Processor.Skip
+// 依赖的任务本身被跳过时 Err 为其 *DependencyError，可逐层追溯到最初失败的任务
+type DependencyError struct {
+	Dep int // 未成功的依赖任务序号
+	Err error
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("依赖的任务 %d 未成功: %v", e.Dep, e.Err)
+}
+
+func (e *DependencyError) Unwrap() error { return e.Err }
+
+// depResultsKey 依赖任务结果在任务 ctx 中的键
+type depResultsKey struct{}
+
+// DependencyResults 返回当前任务依赖的各任务的结果（按任务序号），只在设置了 Processor.Deps 的任务函数中可用
+// R 须与 Processor 的结果类型一致，否则返回nil
+func DependencyResults[R any](ctx context.Context) map[int]R {
+	results, _ := ctx.Value(depResultsKey{}).(map[int]R)
+	return results
+}
+
+// depGraph 任务的依赖关系
+type depGraph struct {
+	deps     [][]int // 每个任务依赖的任务，已去重
+	children [][]int // 依赖每个任务的任务
+}
+
+// CheckDeps 检查任务的依赖关系：依赖的序号须在 [0, len(tasks)) 内、不能依赖自身，且不能有环
+func CheckDeps[T any](tasks []T, deps func(task T) []int) error {
+	_, err := newDepGraph(tasks, deps)
+	return err
+}
+
+// newDepGraph 建立依赖关系并以拓扑排序检查是否有环
+func newDepGraph[T any](tasks []T, deps func(task T) []int) (*depGraph, error) {
+	g := &depGraph{deps: make([][]int, len(tasks)), children: make([][]int, len(tasks))}
+	pending := make([]int, len(tasks))
+	for i, task := range tasks {
+		seen := make(map[int]bool)
+		for _, d := range deps(task) {
+			switch {
+			case d < 0 || d >= len(tasks):
+				return nil, fmt.Errorf("任务 %d 依赖的任务 %d 不存在", i, d)
+			case d == i:
+				return nil, fmt.Errorf("任务 %d 不能依赖自身", i)
+			case seen[d]:
+				continue
+			}
+			seen[d] = true
+			g.deps[i] = append(g.deps[i], d)
+			g.children[d] = append(g.children[d], i)
+			pending[i]++
+		}
+	}
+
+	var ready []int
+	for i, n := range pending {
+		if n == 0 {
+			ready = append(ready, i)
+		}
+	}
+	sorted := 0
+	for len(ready) > 0 {
+		i := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		sorted++
+		for _, c := range g.children[i] {
+			if pending[c]--; pending[c] == 0 {
+				ready = append(ready, c)
+			}
+		}
+	}
+	if sorted < len(tasks) {
+		var cyclic []int
+		for i, n := range pending {
+			if n > 0 {
+				cyclic = append(cyclic, i)
+			}
+		}
+		return nil, fmt.Errorf("%w，涉及任务 %v", ErrDependencyCycle, cyclic)
+	}
+	return g, nil
+}
+
+// readyQueue 依赖已满足、等待槽位的任务，优先级高的先出队，相同优先级按任务序号
+type readyQueue struct {
+	index    []int
+	priority []int // 按任务序号
+}
+
+func (q *readyQueue) Len() int { return len(q.index) }
+func (q *readyQueue) Less(a, b int) bool {
+	pa, pb := q.priority[q.index[a]], q.priority[q.index[b]]
+	return pa > pb || pa == pb && q.index[a] < q.index[b]
+}
+func (q *readyQueue) Swap(a, b int) { q.index[a], q.index[b] = q.index[b], q.index[a] }
+func (q *readyQueue) Push(x any)    { q.index = append(q.index, x.(int)) }
+func (q *readyQueue) Pop() any {
+	n := len(q.index) - 1
+	i := q.index[n]
+	q.index = q.index[:n]
+	return i
+}
+
+// runDAG 按依赖关系调度任务：依赖全部成功的任务进入就绪队列，由调度协程按优先级分配槽位后执行，
+// 互不依赖的任务并发执行；任务的 Err 不为nil时其全部后继任务以 Skip 生成结果，不再执行。
+// 依赖关系不合法时全部任务以该错误跳过，调用方应先用 CheckDeps 校验
+func (p *Processor[T, R]) runDAG(ctx context.Context, tasks []T, done <-chan struct{}) <-chan R {
+	resultCh := make(chan R, len(tasks))
+	graph, err := newDepGraph(tasks, p.Deps)
+	if err != nil {
+		for i, task := range tasks {
+			p.skip(ctx, resultCh, i, task, err)
+		}
+		close(resultCh)
+		return resultCh
+	}
+
+	go func() {
+		defer close(resultCh)
+
+		type finished struct {
+			index, slot int
+			result      R
+		}
+		// 容纳全部任务，收集方返回后仍在执行的任务也不会阻塞
+		completed := make(chan finished, len(tasks))
+
+		size := max(p.MaxConcurrency, 1)
+		free := make([]int, 0, size) // 空闲槽位，先分配编号小的
+		for slot := size - 1; slot >= 0; slot-- {
+			free = append(free, slot)
+		}
+
+		queue := &readyQueue{priority: make([]int, len(tasks))}
+		pending := make([]int, len(tasks)) // 尚未完成的依赖数
+		for i, task := range tasks {
+			if p.Priority != nil {
+				queue.priority[i] = p.Priority(task)
+			}
+			pending[i] = len(graph.deps[i])
+			if pending[i] == 0 {
+				queue.index = append(queue.index, i)
+			}
+		}
+		heap.Init(queue)
+
+		// 已完成任务的结果，供依赖它的任务读取
+		results := make([]R, len(tasks))
+		skipped := make([]bool, len(tasks))
+		remaining := len(tasks)
+
+		// skipDescendants 跳过 index 的全部后继任务
+		var skipDescendants func(index int, err error)
+		skipDescendants = func(index int, err error) {
+			for _, c := range graph.children[index] {
+				if skipped[c] {
+					continue
+				}
+				skipped[c] = true
+				remaining--
+				cause := &DependencyError{Dep: index, Err: err}
+				p.skip(ctx, resultCh, c, tasks[c], cause)
+				skipDescendants(c, cause)
+			}
+		}
+
+		for remaining > 0 {
+			for len(free) > 0 && queue.Len() > 0 {
+				index := heap.Pop(queue).(int)
+				slot := free[len(free)-1]
+				free = free[:len(free)-1]
+
+				deps := make(map[int]R, len(graph.deps[index]))
+				for _, d := range graph.deps[index] {
+					deps[d] = results[d]
+				}
+				taskCtx := context.WithValue(ctx, depResultsKey{}, deps)
+				go func() {
+					completed <- finished{index, slot, p.run(taskCtx, index, slot, tasks[index])}
+				}()
+			}
+
+			var f finished
+			select {
+			case f = <-completed:
+			case <-done:
+				// 收集方已返回，不再派发
+				return
+			}
+			free = append(free, f.slot)
+			remaining--
+			resultCh <- f.result
+
+			if p.Err != nil {
+				if err := p.Err(f.result); err != nil {
+					skipDescendants(f.index, err)
+					continue
+				}
+			}
+			results[f.index] = f.result
+			for _, c := range graph.children[f.index] {
+				if pending[c]--; pending[c] == 0 && !skipped[c] {
+					heap.Push(queue, c)
+				}
+			}
+		}
+	}()
+	return resultCh
+}
+
+// skip 以 Skip 生成未执行任务的结果，Skip 为nil时不输出结果
+func (p *Processor[T, R]) skip(ctx context.Context, resultCh chan<- R, index int, task T, err error) {
+	if p.Skip != nil {
+		resultCh <- p.Skip(ctx, index, task, err)
+	}
+}
@@ -0,0 +1,67 @@
+package batch
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// TaskFailedError ModeErrGroupFailFast 下首个失败的任务，作为其余任务 ctx 的 context.Cause，
+// 任务函数可据此区分"因其他任务失败被取消"与超时、外部取消
+type TaskFailedError struct {
+	Index int // 失败任务的序号
+	Err   error
+}
+
+func (e *TaskFailedError) Error() string {
+	return fmt.Sprintf("任务 %d 失败: %v", e.Index, e.Err)
+}
+
+func (e *TaskFailedError) Unwrap() error { return e.Err }
+
+// runErrGroup 以 errgroup.Group 执行任务，SetLimit 限制同时执行的数量
+// failFast 时使用 errgroup.WithContext：Processor.Err 对某个结果返回错误后取消其余任务的 ctx，
+// 此后开始的任务收到的 ctx 已取消，任务函数应直接返回；否则任务之间互不影响，收集全部结果
+func (p *Processor[T, R]) runErrGroup(ctx context.Context, tasks []T, done <-chan struct{}, failFast bool) <-chan R {
+	size := max(p.MaxConcurrency, 1)
+	resultCh := make(chan R, len(tasks))
+
+	g := &errgroup.Group{}
+	if failFast {
+		g, ctx = errgroup.WithContext(ctx)
+	}
+	g.SetLimit(size)
+
+	// errgroup 不提供槽位编号，并发数已由 SetLimit 限制，取槽位不会等待
+	slots := newSlotPool(size, size)
+
+	go func() {
+		order := DispatchOrder(tasks, p.Priority)
+		for i := range tasks {
+			index := taskAt(order, i)
+			// Go 在并发数占满时阻塞；收集方返回后不再执行剩余任务
+			g.Go(func() error {
+				select {
+				case <-done:
+					return nil
+				default:
+				}
+				slot := <-slots
+				defer func() { slots <- slot }()
+
+				result := p.run(ctx, index, slot, tasks[index])
+				resultCh <- result
+				if failFast && p.Err != nil {
+					if err := p.Err(result); err != nil {
+						return &TaskFailedError{Index: index, Err: err}
+					}
+				}
+				return nil
+			})
+		}
+		g.Wait()
+		close(resultCh)
+	}()
+	return resultCh
+}
@@ -0,0 +1,27 @@
+package batch
+
+import "sort"
+
+// DispatchOrder 返回任务的派发顺序（任务序号）：按 priority 从高到低，相同优先级保持提交顺序
+// priority 为nil时返回nil，表示按提交顺序派发
+func DispatchOrder[T any](tasks []T, priority func(T) int) []int {
+	if priority == nil {
+		return nil
+	}
+	order := make([]int, len(tasks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return priority(tasks[order[a]]) > priority(tasks[order[b]])
+	})
+	return order
+}
+
+// taskAt 返回派发顺序中第 i 个任务的序号
+func taskAt(order []int, i int) int {
+	if order == nil {
+		return i
+	}
+	return order[i]
+}
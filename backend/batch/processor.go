@@ -0,0 +1,352 @@
+// Package batch 通用的并发批处理引擎
+//
+// Processor 以固定并发数执行一组任务并收集结果，Stream 支持增量提交任务并按完成顺序输出结果。
+// 任务函数、结果类型均由调用方决定，本包不依赖项目中的其他包，可以直接复用到其他项目：
+//
+//	p := batch.Processor[string, int]{
+//		MaxConcurrency: 4,
+//		Timeout:        time.Minute,
+//		Run: func(ctx context.Context, index, slot int, s string) int {
+//			return len(s)
+//		},
+//	}
+//	results := p.Process(ctx, []string{"a", "bb"})
+package batch
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TaskFunc 在工作槽位 slot 上执行第 index 个任务
+// slot 取值为 [0, MaxConcurrency)（运行中调整过并发数时为 [0, 当前并发数)），同一时刻不会有两个任务占用同一个槽位
+type TaskFunc[T, R any] func(ctx context.Context, index, slot int, task T) R
+
+// Mode 任务的调度方式
+type Mode int
+
+const (
+	// ModePerTask 每个任务一个协程，由工作槽位限制同时执行的数量（默认）
+	// 任务之间互不等待调度，适合任务数不多的批次
+	ModePerTask Mode = iota
+	// ModeWorkerPool 固定 MaxConcurrency 个工作协程从任务通道拉取任务
+	// 协程数和通道容量不随任务数增长，适合上万个任务的大批次；
+	// 超时或取消后尚未开始的任务不再执行
+	ModeWorkerPool
+	// ModeErrGroup 以 errgroup.Group 和 SetLimit 限制并发，收集全部结果，语义同 ModePerTask
+	ModeErrGroup
+	// ModeErrGroupFailFast 以 errgroup.WithContext 执行：首个 Processor.Err 返回错误的结果取消其余任务，
+	// 其余任务的 ctx 随之取消（context.Cause 为 *TaskFailedError），尚未开始的任务应据此直接返回
+	ModeErrGroupFailFast
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeWorkerPool:
+		return "worker_pool"
+	case ModeErrGroup:
+		return "errgroup"
+	case ModeErrGroupFailFast:
+		return "errgroup_fail_fast"
+	}
+	return "per_task"
+}
+
+// Processor 批量任务处理器
+type Processor[T, R any] struct {
+	Mode           Mode
+	MaxConcurrency int           // 最大并发数，小于1时按1处理
+	Timeout        time.Duration // 收集结果的总时间，0表示不限制
+	Ramp           *Ramp         // 并发数预热（可选），只作用于 Process 和 Each，errgroup 模式下不生效
+	// Concurrency 运行中调整并发数（可选），只作用于 ModePerTask、ModeWorkerPool 的 Process 和 Each
+	Concurrency *Concurrency
+	// Chunks 分块执行（可选），只作用于 Process 和 Each，设置 Deps 时不生效；Timeout 包含块间暂停的时间
+	Chunks *Chunks
+	// RateLimit 限制每秒开始执行的任务数（可选），任务取得工作槽位后等待令牌，适用于全部调度方式和 Stream
+	RateLimit *RateLimiter
+	// Priority 返回任务的优先级（可选），并发数占满时优先级高的任务先执行，相同优先级按提交顺序；
+	// 只作用于 Process 和 Each，不影响任务序号
+	Priority func(task T) int
+	// Deps 返回任务依赖的其他任务的序号（可选）。设置后按依赖关系调度：依赖的任务全部完成且 Err 均为nil后才开始，
+	// 互不依赖的任务并发执行，任务函数可用 DependencyResults 读取依赖任务的结果；依赖的任务失败或被跳过时，
+	// 以 Skip 生成后继任务的结果。只作用于 Process 和 Each，此时不使用 Mode、Ramp 和 Concurrency，
+	// Priority 决定同时就绪的任务的先后
+	Deps func(task T) []int
+	// Skip 生成因依赖未成功而未执行的任务的结果（可选），err 为 *DependencyError 或依赖关系不合法的错误；
+	// 为nil时这些任务没有结果
+	Skip func(ctx context.Context, index int, task T, err error) R
+	Run  TaskFunc[T, R]
+	// Err 返回结果对应的错误（可选），成功时返回nil；ModeErrGroupFailFast 据此判断是否取消其余任务
+	Err func(result R) error
+	// OnResult 每收集到一个结果时调用（可选），在收集协程中串行执行
+	OnResult func(ctx context.Context, result R)
+}
+
+// Process 并发执行全部任务，返回按完成顺序收集到的结果
+// 超时或 ctx 取消时立即返回已收集的结果，未完成的任务不会出现在返回值中；
+// 返回时传给任务函数的 ctx 随之取消，这些任务的协程会在任务函数返回后退出
+func (p *Processor[T, R]) Process(ctx context.Context, tasks []T) []R {
+	results := make([]R, 0, len(tasks))
+	p.Each(ctx, tasks, func(result R) {
+		results = append(results, result)
+	})
+	return results
+}
+
+// Each 并发执行全部任务，每完成一个任务就以其结果调用 fn，不保留结果，返回收集到的结果数
+// fn 在调用方协程中串行执行，可以直接写出响应；fn 阻塞会延后后续结果的收集。
+// 配合 ModeWorkerPool 时内存占用不随任务数增长，适合逐条输出超大批次的结果。
+// 超时与取消的处理同 Process
+func (p *Processor[T, R]) Each(ctx context.Context, tasks []T, fn func(R)) int {
+	if len(tasks) == 0 {
+		return 0
+	}
+	if p.Chunks.enabled(len(tasks)) && p.Deps == nil {
+		return p.eachChunk(ctx, tasks, fn)
+	}
+
+	// 返回时（含超时）取消任务的 ctx，正在执行的任务函数应据此尽快退出
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// done 在返回时关闭，通知仍在运行的协程停止
+	done := make(chan struct{})
+	defer close(done)
+
+	// changed 为nil时并发数固定
+	var changed <-chan struct{}
+	if p.Concurrency != nil && p.Deps == nil && p.Mode != ModeErrGroup && p.Mode != ModeErrGroupFailFast {
+		changed = p.Concurrency.begin(max(p.MaxConcurrency, 1))
+		defer p.Concurrency.end()
+	}
+
+	var resultCh <-chan R
+	switch {
+	case p.Deps != nil:
+		resultCh = p.runDAG(ctx, tasks, done)
+	case p.Mode == ModeWorkerPool:
+		resultCh = p.runPool(ctx, tasks, done, changed)
+	case p.Mode == ModeErrGroup, p.Mode == ModeErrGroupFailFast:
+		resultCh = p.runErrGroup(ctx, tasks, done, p.Mode == ModeErrGroupFailFast)
+	default:
+		resultCh = p.runPerTask(ctx, tasks, done, changed)
+	}
+
+	// 收集结果
+	collector := Collector[R]{
+		Timeout: p.Timeout,
+		OnResult: func(result R) {
+			if p.OnResult != nil {
+				p.OnResult(ctx, result)
+			}
+			fn(result)
+		},
+	}
+	collected, _ := collector.Collect(ctx, resultCh)
+	return collected
+}
+
+// runPerTask 为每个任务启动一个协程，槽位池限制同时执行的数量
+func (p *Processor[T, R]) runPerTask(ctx context.Context, tasks []T, done <-chan struct{}, changed <-chan struct{}) <-chan R {
+	// 结果通道容纳全部任务，提前返回后剩余的任务也不会阻塞
+	resultCh := make(chan R, len(tasks))
+	var wg sync.WaitGroup
+
+	// 限制并发数，每个槽位对应一个工作位；预热时其余槽位随时间陆续放入
+	size := max(p.MaxConcurrency, 1)
+	capacity := size
+	if changed != nil {
+		capacity = MaxConcurrencyLimit
+	}
+	slots := newResizableSlotPool(capacity, size, p.Ramp.initial(size))
+	if p.Ramp != nil {
+		go p.Ramp.open(size, done, slots.add)
+	}
+	if changed != nil {
+		go p.Concurrency.watch(changed, done, slots.resize)
+	}
+
+	if order := DispatchOrder(tasks, p.Priority); order != nil {
+		// 指定优先级时按优先级依次取得槽位后再启动协程，而不是由各协程随机争抢槽位；
+		// 收集方返回后不再启动未开始的任务
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, index := range order {
+				var slot int
+				select {
+				case slot = <-slots.c:
+				case <-done:
+					return
+				}
+				wg.Add(1)
+				go func(index, slot int) {
+					defer wg.Done()
+					defer slots.release(slot)
+					resultCh <- p.run(ctx, index, slot, tasks[index])
+				}(index, slot)
+			}
+		}()
+	} else {
+		for i, task := range tasks {
+			wg.Add(1)
+			go func(index int, task T) {
+				defer wg.Done()
+
+				// 获取工作槽位
+				slot := <-slots.c
+				defer slots.release(slot)
+
+				resultCh <- p.run(ctx, index, slot, task)
+			}(i, task)
+		}
+	}
+
+	// 等待所有任务完成
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+	return resultCh
+}
+
+// runPool 启动固定数量的工作协程，每个协程独占一个槽位，从任务通道依次拉取任务
+// 运行中调高并发数时启动新的工作协程，调低时超出的工作协程完成当前任务后退出
+func (p *Processor[T, R]) runPool(ctx context.Context, tasks []T, done <-chan struct{}, changed <-chan struct{}) <-chan R {
+	workers := p.MaxConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(tasks) {
+		workers = len(tasks)
+	}
+
+	type job struct {
+		index int
+		task  T
+	}
+	jobs := make(chan job)
+	resultCh := make(chan R, workers)
+
+	// 按优先级（未指定时按提交顺序）派发任务，收集方返回后停止派发；dispatched 在派发结束时关闭
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+		defer close(jobs)
+		order := DispatchOrder(tasks, p.Priority)
+		for i := range tasks {
+			index := taskAt(order, i)
+			select {
+			case jobs <- job{index: index, task: tasks[index]}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		limit   = workers            // 当前并发数，编号不小于它的工作协程退出
+		running = make(map[int]bool) // 正在运行的工作协程的槽位
+	)
+	// active 工作协程是否继续拉取任务，不再继续时登记退出
+	active := func(slot int) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if slot >= limit {
+			running[slot] = false
+			return false
+		}
+		return true
+	}
+	startWorker := func(slot int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if slot >= limit || running[slot] {
+			return
+		}
+		running[slot] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for active(slot) {
+				j, ok := <-jobs
+				if !ok {
+					return
+				}
+				select {
+				case resultCh <- p.run(ctx, j.index, slot, j.task):
+				case <-done:
+					// 收集方已返回，丢弃结果
+				}
+			}
+		}()
+	}
+	start := p.Ramp.initial(workers)
+	for slot := 0; slot < start; slot++ {
+		startWorker(slot)
+	}
+	// 预热时其余工作协程随时间陆续启动，任务派发完后不再启动
+	if start < workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Ramp.open(workers, dispatched, startWorker)
+		}()
+	}
+	// 运行中调整并发数，任务派发完后不再调整
+	if changed != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Concurrency.watch(changed, dispatched, func(n int) {
+				n = min(n, len(tasks))
+				mu.Lock()
+				limit = n
+				mu.Unlock()
+				for slot := 0; slot < n; slot++ {
+					startWorker(slot)
+				}
+			})
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultCh)
+	}()
+	return resultCh
+}
+
+// Stream 并发执行全部任务，结果按完成顺序从返回的 Stream 逐个输出
+func (p *Processor[T, R]) Stream(ctx context.Context, tasks []T) *Stream[R] {
+	stream := NewStream[R](ctx, p.MaxConcurrency, Hooks[R]{OnResult: p.OnResult})
+	for _, task := range tasks {
+		task := task
+		stream.Submit(func(ctx context.Context, index, slot int) R {
+			return p.run(ctx, index, slot, task)
+		})
+	}
+	stream.Close()
+	return stream
+}
+
+// run 按限速等待后执行任务；等待期间 ctx 结束时仍以该 ctx 调用任务函数，由其按未开始处理
+func (p *Processor[T, R]) run(ctx context.Context, index, slot int, task T) R {
+	p.RateLimit.Wait(ctx)
+	return p.Run(ctx, index, slot, task)
+}
+
+// newSlotPool 创建容量为 size 的工作槽位池，用作带编号的信号量，初始放入槽位 [0, available)
+func newSlotPool(size, available int) chan int {
+	if size < 1 {
+		size = 1
+	}
+	slots := make(chan int, size)
+	for i := 0; i < min(max(available, 1), size); i++ {
+		slots <- i
+	}
+	return slots
+}
@@ -0,0 +1,37 @@
+package batch
+
+import "time"
+
+// Ramp 并发数预热：批次开始时只开放 Start 个工作槽位，在 Duration 内匀速增加到 MaxConcurrency
+// 用于冷启动的下游（自动扩容的服务、需要预热缓存的服务），避免批次一开始就以满并发压上去造成大量失败
+type Ramp struct {
+	Start    int           // 初始并发数，小于1时按1处理
+	Duration time.Duration // 从 Start 增加到 MaxConcurrency 所需的时间，不大于0时不预热
+}
+
+// initial 返回批次开始时开放的槽位数
+func (r *Ramp) initial(size int) int {
+	if r == nil || r.Duration <= 0 {
+		return size
+	}
+	return min(max(r.Start, 1), size)
+}
+
+// open 按预热进度依次以槽位编号 [initial, size) 调用 fn，stop 关闭时不再开放剩余的槽位
+func (r *Ramp) open(size int, stop <-chan struct{}, fn func(slot int)) {
+	start := r.initial(size)
+	if start >= size {
+		return
+	}
+	interval := max(r.Duration/time.Duration(size-start), time.Nanosecond)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for slot := start; slot < size; slot++ {
+		select {
+		case <-ticker.C:
+			fn(slot)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package batch
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter 令牌桶限速器，限制每秒开始执行的任务数，与并发数无关
+// 令牌以 rate 个/秒的速度补充，最多积攒 burst 个；多个批次共用同一个限速器时共享同一份配额，
+// 适合遵守下游接口的调用配额（如合作方接口限制 50 次/秒）
+type RateLimiter struct {
+	rate  float64
+	burst int
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter 创建每秒 rate 个令牌、最多积攒 burst 个的限速器，初始令牌为 burst 个
+// rate 不大于0时返回nil，表示不限速；burst 小于1时按1处理
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	burst = max(burst, 1)
+	return &RateLimiter{rate: rate, burst: burst, tokens: float64(burst), last: time.Now()}
+}
+
+// Rate 每秒补充的令牌数
+func (l *RateLimiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Burst 最多积攒的令牌数
+func (l *RateLimiter) Burst() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.burst
+}
+
+// SetRate 在运行中调整限速，使用该限速器的所有批次立即按新的速率取得令牌；已积攒的令牌保留（不超过新的 burst），
+// 正在等待的调用方仍按调整前计算的时间等待。rate 不大于0时不做调整，burst 小于1时按1处理
+func (l *RateLimiter) SetRate(rate float64, burst int) {
+	if rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, float64(l.burst))
+	l.last = now
+	l.rate = rate
+	l.burst = max(burst, 1)
+	l.tokens = min(l.tokens, float64(l.burst))
+}
+
+// Wait 取得一个令牌，令牌不足时等待；ctx 结束时归还预留的令牌并返回 ctx 的错误
+// l 为nil时直接返回
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	// 预留一个令牌，余额为负时按欠下的数量计算等待时间，先到的调用方先取得令牌
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*l.rate, float64(l.burst))
+	l.last = now
+	l.tokens--
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
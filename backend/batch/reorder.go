@@ -0,0 +1,39 @@
+package batch
+
+// Reorder 将按完成顺序到达的结果恢复为按任务序号输出：序号与已输出的结果连续时立即输出，
+// 否则暂存到前面的任务完成为止。前面的任务耗时较长时暂存的结果会随之增多
+type Reorder[R any] struct {
+	Index func(result R) int // 结果对应的任务序号，从0开始且各不相同
+	Emit  func(result R)
+
+	next    int
+	pending map[int]R
+}
+
+// Add 加入一个结果，并输出因此变得连续的全部结果
+func (r *Reorder[R]) Add(result R) {
+	if r.pending == nil {
+		r.pending = make(map[int]R)
+	}
+	r.pending[r.Index(result)] = result
+	for {
+		result, ok := r.pending[r.next]
+		if !ok {
+			return
+		}
+		delete(r.pending, r.next)
+		r.next++
+		r.Emit(result)
+	}
+}
+
+// Flush 按序号输出全部暂存的结果，跳过没有结果的任务；用于批次提前结束（超时、取消）时
+func (r *Reorder[R]) Flush() {
+	for len(r.pending) > 0 {
+		if result, ok := r.pending[r.next]; ok {
+			delete(r.pending, r.next)
+			r.Emit(result)
+		}
+		r.next++
+	}
+}
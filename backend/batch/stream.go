@@ -0,0 +1,141 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClosed 批次已关闭，不能再提交任务
+var ErrClosed = errors.New("批次已关闭")
+
+// Hooks 流式批次的回调，均为可选
+type Hooks[R any] struct {
+	// OnSubmit 任务编号后、开始执行前调用
+	OnSubmit func(index int)
+	// OnResult 每收集到一个结果时调用，在收集协程中串行执行
+	OnResult func(ctx context.Context, result R)
+}
+
+// Stream 增量提交任务、逐个返回结果的批次，适用于任务列表事先未知的生产者/消费者场景
+// 任务按提交顺序编号，并发数由工作槽位限制；结果交给消费者后不再保留，需要汇总时在 OnResult 中累计
+type Stream[R any] struct {
+	ctx      context.Context
+	slots    chan int
+	resultCh chan R
+	out      chan R
+	hooks    Hooks[R]
+	pending  chan struct{} // 已提交但结果尚未收集的任务，为nil时不限制
+
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+	next   int
+	closed bool
+}
+
+// NewStream 创建流式批次
+func NewStream[R any](ctx context.Context, maxConcurrency int, hooks Hooks[R]) *Stream[R] {
+	slots := newSlotPool(maxConcurrency, maxConcurrency)
+	s := &Stream[R]{
+		ctx:      ctx,
+		slots:    slots,
+		resultCh: make(chan R, cap(slots)),
+		out:      make(chan R, cap(slots)),
+		hooks:    hooks,
+	}
+
+	// 收集结果并转发给消费者
+	go func() {
+		defer close(s.out)
+		for result := range s.resultCh {
+			if hooks.OnResult != nil {
+				hooks.OnResult(ctx, result)
+			}
+			s.out <- result
+		}
+	}()
+
+	return s
+}
+
+// SetMaxPending 限制已提交但结果尚未收集的任务数，达到上限时 Submit 阻塞到有任务结束；应在提交任务之前调用
+// 未设置时不限制，每个提交的任务都立即占用一个等待工作槽位的协程
+func (s *Stream[R]) SetMaxPending(n int) {
+	if n > 0 {
+		s.pending = make(chan struct{}, n)
+	}
+}
+
+// Submit 提交一个任务，返回任务在批次中的序号
+// 设置了 SetMaxPending 时等待名额，等待期间 ctx 结束返回 ctx 的错误
+func (s *Stream[R]) Submit(run func(ctx context.Context, index, slot int) R) (int, error) {
+	if s.pending != nil {
+		select {
+		case s.pending <- struct{}{}:
+		case <-s.ctx.Done():
+			return 0, s.ctx.Err()
+		}
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		s.release()
+		return 0, ErrClosed
+	}
+	index := s.next
+	s.next++
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	if s.hooks.OnSubmit != nil {
+		s.hooks.OnSubmit(index)
+	}
+
+	go func() {
+		defer s.wg.Done()
+
+		// 获取工作槽位
+		slot := <-s.slots
+		defer func() { s.slots <- slot }()
+
+		s.resultCh <- run(s.ctx, index, slot)
+		s.release()
+	}()
+
+	return index, nil
+}
+
+// release 释放一个等待中的任务名额
+func (s *Stream[R]) release() {
+	if s.pending != nil {
+		<-s.pending
+	}
+}
+
+// Results 返回结果通道，Close 之后全部任务结束时关闭
+// 消费者必须持续读取，否则会阻塞任务执行
+func (s *Stream[R]) Results() <-chan R {
+	return s.out
+}
+
+// Close 停止接收新任务，已提交的任务继续执行
+func (s *Stream[R]) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	go func() {
+		s.wg.Wait()
+		close(s.resultCh)
+	}()
+}
+
+// Submitted 返回已提交的任务数
+func (s *Stream[R]) Submitted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"concurrency-web-app/backend/middleware"
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAccessLogLimit 单次查询访问日志的最大条数
+const maxAccessLogLimit = 1000
+
+// accessLog 抽样记录 /api 下的请求，写入访问日志服务
+func (h *BatchHandler) accessLog() gin.HandlerFunc {
+	return middleware.AccessLog(middleware.AccessLogConfig{
+		SampleRate:    0.1,
+		SlowThreshold: time.Second,
+		User:          requestUser,
+		Record: func(r middleware.AccessRecord) {
+			// 删除客户数据的请求本身不能留下客户ID
+			if r.Route == customerDataRoute {
+				r.Path = r.Route
+			}
+			h.AccessLogs.Record(models.AccessLog{
+				Method:     r.Method,
+				Path:       r.Path,
+				Route:      r.Route,
+				Status:     r.Status,
+				LatencyMs:  r.Latency.Milliseconds(),
+				Size:       r.Size,
+				User:       r.User,
+				ClientIP:   r.ClientIP,
+				SampleRate: r.SampleRate,
+				CreatedAt:  r.Time,
+			})
+		},
+	})
+}
+
+// ListAccessLogs 查询最近的访问日志
+// 支持参数：route（路由模板）、user、min_status、since（日期或RFC3339）、limit（默认100，最大1000）
+func (h *BatchHandler) ListAccessLogs(c *gin.Context) {
+	since, err := parseDateQuery(c, "since", false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since 参数格式错误"})
+		return
+	}
+	minStatus, _ := strconv.Atoi(c.Query("min_status"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit > maxAccessLogLimit {
+		limit = maxAccessLogLimit
+	}
+
+	logs, err := h.AccessLogs.Recent(services.AccessLogQuery{
+		Route:     c.Query("route"),
+		User:      c.Query("user"),
+		MinStatus: minStatus,
+		Since:     since,
+		Limit:     limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询访问日志失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "访问日志获取成功",
+		"data":    logs,
+	})
+}
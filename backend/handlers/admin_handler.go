@@ -0,0 +1,540 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TenantRequest 创建/更新租户请求
+type TenantRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// APIKeyRequest 创建/更新API密钥请求
+type APIKeyRequest struct {
+	TenantID  uint       `json:"tenant_id"`
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// QuotaRequest 设置配额请求
+type QuotaRequest struct {
+	TenantID uint   `json:"tenant_id" binding:"required"`
+	Resource string `json:"resource" binding:"required"`
+	Limit    int64  `json:"limit"`
+	Period   string `json:"period"`
+}
+
+// WebhookRequest 创建/更新回调订阅请求
+type WebhookRequest struct {
+	TenantID uint   `json:"tenant_id" binding:"required"`
+	URL      string `json:"url" binding:"required"`
+	Events   string `json:"events"`
+	Secret   string `json:"secret"` // 更新时留空表示不修改
+	Active   *bool  `json:"active"`
+}
+
+// RequireAdmin 只允许管理员访问
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := currentUser(c)
+		if user == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+			return
+		}
+		if user.Role != services.RoleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// pathID 解析路径中的数字ID，失败时返回400
+func pathID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID 参数错误"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// queryTenantID 解析可选的 tenant_id 查询参数
+func queryTenantID(c *gin.Context) (uint, bool) {
+	raw := c.Query("tenant_id")
+	if raw == "" {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tenant_id 参数错误"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// adminError 将管理接口的错误映射为HTTP状态码
+func adminError(c *gin.Context, err error, notFound string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	case errors.Is(err, services.ErrInvalidTenant), errors.Is(err, services.ErrInvalidQuota), errors.Is(err, services.ErrInvalidWebhook):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTenantExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ListTenants 列出租户
+func (h *BatchHandler) ListTenants(c *gin.Context) {
+	tenants, err := h.Admin.ListTenants()
+	if err != nil {
+		adminError(c, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "租户列表获取成功", "data": tenants})
+}
+
+// GetTenant 获取租户
+func (h *BatchHandler) GetTenant(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	tenant, err := h.Admin.GetTenant(id)
+	if err != nil {
+		adminError(c, err, "租户不存在")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "租户获取成功", "data": tenant})
+}
+
+// CreateTenant 创建租户
+func (h *BatchHandler) CreateTenant(c *gin.Context) {
+	var req TenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	tenant := &models.Tenant{Name: req.Name, Description: req.Description}
+	if err := h.Admin.SaveTenant(tenant); err != nil {
+		adminError(c, err, "租户不存在")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "租户已创建", "data": tenant})
+}
+
+// UpdateTenant 更新租户
+func (h *BatchHandler) UpdateTenant(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	var req TenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	tenant := &models.Tenant{ID: id, Name: req.Name, Description: req.Description}
+	if err := h.Admin.SaveTenant(tenant); err != nil {
+		adminError(c, err, "租户不存在")
+		return
+	}
+	tenant, _ = h.Admin.GetTenant(id)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "租户已更新", "data": tenant})
+}
+
+// DeleteTenant 删除租户及其下属的密钥、配额、回调订阅和结果数据密钥
+func (h *BatchHandler) DeleteTenant(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if err := h.Admin.DeleteTenant(id); err != nil {
+		adminError(c, err, "租户不存在")
+		return
+	}
+	if h.Results != nil {
+		h.Results.Forget(id)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "租户已删除"})
+}
+
+// tenantCipher 检查已配置结果加密且租户存在，否则写入错误响应
+func (h *BatchHandler) tenantCipher(c *gin.Context) (uint, bool) {
+	id, ok := pathID(c)
+	if !ok {
+		return 0, false
+	}
+	if h.Results == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": services.ErrResultEncryptionDisabled.Error()})
+		return 0, false
+	}
+	if _, err := h.Admin.GetTenant(id); err != nil {
+		adminError(c, err, "租户不存在")
+		return 0, false
+	}
+	return id, true
+}
+
+// ListTenantKeys 列出租户的结果数据密钥版本，不含密钥内容
+func (h *BatchHandler) ListTenantKeys(c *gin.Context) {
+	id, ok := h.tenantCipher(c)
+	if !ok {
+		return
+	}
+	keys, err := h.Results.Keys(id)
+	if err != nil {
+		adminError(c, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "数据密钥列表获取成功", "data": keys})
+}
+
+// RotateTenantKey 轮换租户的结果数据密钥，之后保存的结果使用新版本加密，已保存的结果仍可用旧版本解密
+func (h *BatchHandler) RotateTenantKey(c *gin.Context) {
+	id, ok := h.tenantCipher(c)
+	if !ok {
+		return
+	}
+	key, err := h.Results.Rotate(id)
+	if err != nil {
+		adminError(c, err, "")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "数据密钥已轮换", "data": key})
+}
+
+// ListAPIKeys 列出API密钥，可按 tenant_id 过滤
+func (h *BatchHandler) ListAPIKeys(c *gin.Context) {
+	tenantID, ok := queryTenantID(c)
+	if !ok {
+		return
+	}
+	keys, err := h.Admin.ListAPIKeys(tenantID)
+	if err != nil {
+		adminError(c, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "API密钥列表获取成功", "data": keys})
+}
+
+// CreateAPIKey 生成API密钥，明文密钥只在本次响应中返回
+func (h *BatchHandler) CreateAPIKey(c *gin.Context) {
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.TenantID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: 需要 tenant_id"})
+		return
+	}
+	secret, key, err := h.Admin.CreateAPIKey(req.TenantID, req.Name, req.ExpiresAt)
+	if err != nil {
+		adminError(c, err, "租户不存在")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "API密钥已创建，请妥善保存，之后无法再次查看",
+		"data": gin.H{
+			"key":     key,
+			"api_key": secret,
+		},
+	})
+}
+
+// UpdateAPIKey 修改API密钥的名称和过期时间
+func (h *BatchHandler) UpdateAPIKey(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	key, err := h.Admin.UpdateAPIKey(id, req.Name, req.ExpiresAt)
+	if err != nil {
+		adminError(c, err, "API密钥不存在")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "API密钥已更新", "data": key})
+}
+
+// RevokeAPIKey 吊销API密钥
+func (h *BatchHandler) RevokeAPIKey(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	key, err := h.Admin.RevokeAPIKey(id)
+	if err != nil {
+		adminError(c, err, "API密钥不存在")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "API密钥已吊销", "data": key})
+}
+
+// ListQuotas 列出配额，可按 tenant_id 过滤
+func (h *BatchHandler) ListQuotas(c *gin.Context) {
+	tenantID, ok := queryTenantID(c)
+	if !ok {
+		return
+	}
+	quotas, err := h.Admin.ListQuotas(tenantID)
+	if err != nil {
+		adminError(c, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "配额列表获取成功", "data": quotas})
+}
+
+// SetQuota 设置租户某项资源的配额，已存在时覆盖
+func (h *BatchHandler) SetQuota(c *gin.Context) {
+	var req QuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	quota := &models.Quota{
+		TenantID: req.TenantID,
+		Resource: req.Resource,
+		Limit:    req.Limit,
+		Period:   req.Period,
+	}
+	if err := h.Admin.SetQuota(quota); err != nil {
+		adminError(c, err, "租户不存在")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "配额已设置", "data": quota})
+}
+
+// DeleteQuota 删除配额
+func (h *BatchHandler) DeleteQuota(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if err := h.Admin.DeleteQuota(id); err != nil {
+		adminError(c, err, "配额不存在")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "配额已删除"})
+}
+
+// ListWebhooks 列出回调订阅，可按 tenant_id 过滤
+func (h *BatchHandler) ListWebhooks(c *gin.Context) {
+	tenantID, ok := queryTenantID(c)
+	if !ok {
+		return
+	}
+	hooks, err := h.Admin.ListWebhooks(tenantID)
+	if err != nil {
+		adminError(c, err, "")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "回调订阅列表获取成功", "data": hooks})
+}
+
+// CreateWebhook 创建回调订阅
+func (h *BatchHandler) CreateWebhook(c *gin.Context) {
+	h.saveWebhook(c, 0)
+}
+
+// UpdateWebhook 更新回调订阅
+func (h *BatchHandler) UpdateWebhook(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	h.saveWebhook(c, id)
+}
+
+func (h *BatchHandler) saveWebhook(c *gin.Context, id uint) {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	hook := &models.WebhookSubscription{
+		ID:       id,
+		TenantID: req.TenantID,
+		URL:      req.URL,
+		Events:   req.Events,
+		Secret:   req.Secret,
+		Active:   req.Active == nil || *req.Active,
+	}
+	if err := h.Admin.SaveWebhook(hook); err != nil {
+		adminError(c, err, "租户或回调订阅不存在")
+		return
+	}
+
+	status, message := http.StatusOK, "回调订阅已更新"
+	if id == 0 {
+		status, message = http.StatusCreated, "回调订阅已创建"
+	}
+	c.JSON(status, gin.H{"success": true, "message": message, "data": hook})
+}
+
+// DeleteWebhook 删除回调订阅
+func (h *BatchHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if err := h.Admin.DeleteWebhook(id); err != nil {
+		adminError(c, err, "回调订阅不存在")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "回调订阅已删除"})
+}
+
+// GetWorkerPools 命名工作池的使用情况和路由规则，未配置工作池时均为空
+func (h *BatchHandler) GetWorkerPools(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "工作池获取成功",
+		"data":    gin.H{"pools": h.Pools.Stats(), "rules": h.Pools.Rules()},
+	})
+}
+
+// ListJobGroups 有任务执行或排队的互斥组
+func (h *BatchHandler) ListJobGroups(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "互斥组获取成功", "data": h.Jobs.Groups()})
+}
+
+// GetJobQueue 全局队列的执行名额和按执行顺序排队的任务
+func (h *BatchHandler) GetJobQueue(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "全局队列获取成功", "data": h.Jobs.Queue()})
+}
+
+// MaintenanceRequest 开启或关闭维护模式请求
+type MaintenanceRequest struct {
+	Enabled    bool   `json:"enabled"`
+	Message    string `json:"message" binding:"max=500"`                       // 拒绝新批次时返回的提示，为空时使用默认提示
+	RetryAfter int    `json:"retry_after" binding:"omitempty,min=1,max=86400"` // Retry-After 的秒数，默认300
+}
+
+// maintenanceStatus 维护模式的状态和排空进度：active_jobs 为尚未结束的任务数，drained 表示可以安全地停止服务
+func (h *BatchHandler) maintenanceStatus(state services.MaintenanceState) gin.H {
+	active := h.Jobs.ActiveJobs()
+	return gin.H{
+		"maintenance": state,
+		"active_jobs": active,
+		"drained":     state.Enabled && active == 0,
+	}
+}
+
+// GetMaintenance 查询维护模式的状态和排空进度
+func (h *BatchHandler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "维护模式获取成功", "data": h.maintenanceStatus(h.Maintenance.State())})
+}
+
+// SetMaintenance 开启或关闭只读维护模式：开启后批量接口返回 503，执行中的批次照常执行完
+func (h *BatchHandler) SetMaintenance(c *gin.Context) {
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	var state services.MaintenanceState
+	if req.Enabled {
+		state = h.Maintenance.Enable(req.Message, req.RetryAfter, requestUser(c))
+		log.Printf("%s 开启了维护模式，不再接收新批次", requestUser(c))
+	} else {
+		state = h.Maintenance.Disable()
+		log.Printf("%s 关闭了维护模式", requestUser(c))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "维护模式已更新", "data": h.maintenanceStatus(state)})
+}
+
+// rateLimitTarget 可在运行中调整的限速器及使用它的任务类型
+type rateLimitTarget struct {
+	limiter  *batch.RateLimiter
+	jobTypes []string
+}
+
+// rateLimitTargets 各服务的限速器，按服务名索引；流水线的 fetch 阶段与 API 调用共用限速器
+func (h *BatchHandler) rateLimitTargets() map[string]rateLimitTarget {
+	targets := map[string]rateLimitTarget{
+		"order": {h.OrderService.RateLimit, []string{"order"}},
+		"api":   {h.APIService.RateLimit, []string{"api", "pipeline"}},
+		"file":  {h.FileService.RateLimit, []string{"file"}},
+	}
+	for _, name := range h.Tasks.Names() {
+		if service, err := h.Tasks.Get(name); err == nil {
+			targets[name] = rateLimitTarget{service.RateLimit, []string{name}}
+		}
+	}
+	return targets
+}
+
+// ListRateLimits 各服务当前的限速，未配置限速的服务为 null
+func (h *BatchHandler) ListRateLimits(c *gin.Context) {
+	limits := make(map[string]*services.RateSetting)
+	for name, target := range h.rateLimitTargets() {
+		if target.limiter == nil {
+			limits[name] = nil
+			continue
+		}
+		setting := services.RateSettingOf(target.limiter)
+		limits[name] = &setting
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "限速获取成功", "data": limits})
+}
+
+// RateLimitRequest 调整服务限速请求
+type RateLimitRequest struct {
+	Rate  float64 `json:"rate" binding:"required,gt=0,max=100000"` // 每秒开始执行的任务数
+	Burst int     `json:"burst" binding:"required,min=1,max=100000"`
+}
+
+// SetRateLimit 调整服务的限速（管理员），该服务所有执行中的批次立即按新的速率执行；
+// 调整前后的值记入这些任务的配置调整，供之后分析性能时参考。未配置限速的服务不能在运行中开启限速
+func (h *BatchHandler) SetRateLimit(c *gin.Context) {
+	var req RateLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	service := c.Param("service")
+	target, ok := h.rateLimitTargets()[service]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "服务不存在: " + service})
+		return
+	}
+	if target.limiter == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "该服务未配置限速: " + service})
+		return
+	}
+
+	before := services.RateSettingOf(target.limiter)
+	target.limiter.SetRate(req.Rate, req.Burst)
+	after := services.RateSettingOf(target.limiter)
+	var jobs []string
+	if after != before {
+		jobs = h.Jobs.RecordConfigChange(target.jobTypes, services.ConfigChange{
+			Setting: services.ConfigRateLimit, Scope: service, Before: before, After: after, By: requestUser(c), Time: time.Now(),
+		})
+		log.Printf("%s 将 %s 服务的限速从 %s 调整为 %s，影响 %d 个执行中的任务", requestUser(c), service, before, after, len(jobs))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "限速已调整",
+		"data":    gin.H{"service": service, "before": before, "after": after, "jobs": jobs},
+	})
+}
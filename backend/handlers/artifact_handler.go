@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListArtifacts 列出任务产出物，可按存储级别过滤
+func (h *BatchHandler) ListArtifacts(c *gin.Context) {
+	artifacts, err := h.Artifacts.List(c.Query("storage_class"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取产出物列表失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "产出物列表获取成功",
+		"data":    artifacts,
+	})
+}
+
+// ArchiveArtifacts 立即归档超过指定天数的产出物，未指定时使用默认策略
+func (h *BatchHandler) ArchiveArtifacts(c *gin.Context) {
+	age := h.Artifacts.ArchiveAfter
+	if days := c.Query("older_than_days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "older_than_days 参数错误"})
+			return
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	}
+
+	archived, err := h.Artifacts.ArchiveOlderThan(age)
+	if errors.Is(err, models.ErrLockHeld) {
+		c.JSON(http.StatusConflict, gin.H{"error": "其他实例正在归档，请稍后重试"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "archived": archived})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "产出物归档完成",
+		"data": gin.H{
+			"archived": archived,
+			"backend":  h.Artifacts.Cold.Name(),
+		},
+	})
+}
+
+// SweepRetention 立即按批次模板的保留策略和结果的保留时长清理过期的任务记录和明细
+func (h *BatchHandler) SweepRetention(c *gin.Context) {
+	report, err := h.Retention.Sweep(time.Now())
+	if errors.Is(err, models.ErrLockHeld) {
+		c.JSON(http.StatusConflict, gin.H{"error": "其他实例正在清理，请稍后重试"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "data": report})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已按保留策略完成清理",
+		"data":    report,
+	})
+}
+
+// RestoreArtifact 将任务产出物从冷存储恢复
+func (h *BatchHandler) RestoreArtifact(c *gin.Context) {
+	artifact, err := h.Artifacts.Restore(c.Param("id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "产出物不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复产出物失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "产出物已恢复",
+		"data":    artifact,
+	})
+}
+
+// DownloadJobArtifact 以文件下载已结束任务的结果，结果较大时代替 GET /api/jobs/:id/result
+// format=json（默认）为保存的任务信息和批次结果，format=csv 为每个子任务一行；
+// 从保存的产出物读取（已归档的直接从冷存储读取），产出物不存在时使用内存中的结果
+func (h *BatchHandler) DownloadJobArtifact(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 参数只支持 json 或 csv"})
+		return
+	}
+
+	job, inMemory := h.Jobs.Get(c.Param("id"))
+	if inMemory {
+		if job.Info().Owner != requestUser(c) {
+			c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+			return
+		}
+		if job.Result() == nil {
+			c.JSON(http.StatusAccepted, gin.H{
+				"success": true,
+				"message": "任务尚未结束",
+				"data":    gin.H{"job": job.Info()},
+			})
+			return
+		}
+	}
+
+	info, result, err := h.Artifacts.LoadJobResult(c.Param("id"), requestUser(c))
+	if errors.Is(err, services.ErrJobOutputNotFound) && inMemory {
+		// 产出物保存失败或尚未写入
+		result = job.Result()
+		current := job.Info()
+		info, err = &current, nil
+	}
+	if errors.Is(err, services.ErrJobOutputNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if info.EndTime != nil && notModified(c, fmt.Sprintf(`"%s-%d-%s"`, info.ID, info.EndTime.UnixNano(), format)) {
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="job_%s.%s"`, info.ID, format))
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		err = services.WriteResultCSV(c.Writer, result)
+	} else {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Status(http.StatusOK)
+		err = json.NewEncoder(c.Writer).Encode(gin.H{"job": info, "result": result})
+	}
+	// 已开始写入响应，只能记录日志
+	if err != nil {
+		log.Printf("下载任务 %s 的结果失败: %v", info.ID, err)
+	}
+}
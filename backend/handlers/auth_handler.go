@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionCookie 保存会话令牌的 cookie
+const sessionCookie = "session_id"
+
+// AuthRequest 注册/登录请求
+type AuthRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// sessionToken 从 cookie 或 Authorization: Bearer 请求头读取会话令牌
+func sessionToken(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	token, _ := c.Cookie(sessionCookie)
+	return token
+}
+
+// Authenticate 识别已登录的用户，未登录的请求按匿名处理继续执行（开启 TrustUserHeader 时按 X-User-ID 请求头）
+// 携带 X-API-Key 请求头时同时识别所属的租户，密钥无效时返回401
+func (h *BatchHandler) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := sessionToken(c); token != "" {
+			user, err := h.Accounts.Authenticate(token)
+			switch {
+			case err == nil:
+				c.Set(currentUserKey, user)
+			case !errors.Is(err, services.ErrSessionInvalid):
+				log.Printf("校验会话失败: %v", err)
+			}
+		}
+		if h.TrustUserHeader && currentUser(c) == nil {
+			if user := c.GetHeader("X-User-ID"); user != "" {
+				c.Set(headerUserKey, user)
+			}
+		}
+		if secret := c.GetHeader("X-API-Key"); secret != "" {
+			tenantID, err := h.Admin.TenantForAPIKey(secret)
+			switch {
+			case err == nil:
+				c.Set(currentTenantKey, tenantID)
+			case errors.Is(err, services.ErrAPIKeyInvalid):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "校验API密钥失败: " + err.Error()})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// Register 注册用户
+func (h *BatchHandler) Register(c *gin.Context) {
+	var req AuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	user, err := h.Accounts.Register(req.Username, req.Password)
+	switch {
+	case errors.Is(err, services.ErrInvalidUsername), errors.Is(err, services.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "注册成功",
+		"data":    user,
+	})
+}
+
+// Login 登录，会话令牌写入 HttpOnly cookie，同时在响应中返回供脚本通过 Bearer 使用
+func (h *BatchHandler) Login(c *gin.Context) {
+	var req AuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	token, session, user, err := h.Accounts.Login(req.Username, req.Password)
+	if errors.Is(err, services.ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败: " + err.Error()})
+		return
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "登录成功",
+		"data": gin.H{
+			"user":       user,
+			"token":      token,
+			"expires_at": session.ExpiresAt,
+		},
+	})
+}
+
+// Logout 注销当前会话
+func (h *BatchHandler) Logout(c *gin.Context) {
+	if token := sessionToken(c); token != "" {
+		if err := h.Accounts.Logout(token); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "注销失败: " + err.Error()})
+			return
+		}
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已注销",
+	})
+}
+
+// GetCurrentUser 返回当前登录的用户
+func (h *BatchHandler) GetCurrentUser(c *gin.Context) {
+	user := currentUser(c)
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未登录"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "用户信息获取成功",
+		"data":    user,
+	})
+}
@@ -2,17 +2,28 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sync"
 	"time"
 
+	"concurrency-web-app/backend/batch"
+	"concurrency-web-app/backend/middleware"
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/openapi"
 	"concurrency-web-app/backend/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // BatchHandler 批量处理控制器
@@ -20,31 +31,306 @@ type BatchHandler struct {
 	OrderService *services.OrderProcessService
 	APIService   *services.APICallService
 	FileService  *services.FileProcessService
+	Jobs         *services.JobManager
+	JobHistory   *services.DBProgressStore
+	Files        *services.FileMetadataService
+	Artifacts    *services.ArtifactService
+	OrderStats   *services.OrderStatsService
+	APICalls     *services.APICallStore
+	Accounts     *services.AccountService
+	Admin        *services.AdminService
+	AccessLogs   *services.AccessLogService
+	Pipelines    *services.PipelineService
+	Pools        *services.WorkerPools
+	Tasks        *services.TaskRegistry // 注册的任务类型，须在 SetupRoutes 之前注册
+	Results      *services.ResultCipher // 按租户加密保存的结果，未配置主密钥时为nil
+	Customers    *services.CustomerDataService
+	Templates    *services.TemplateService
+	Retention    *services.RetentionJanitor
+	Limits       services.RunLimits // 批量请求中 max_concurrency、timeout_ms 的上限，为0时不允许覆盖
+	Maintenance  *services.MaintenanceMode
+	FullResults  services.ResultLimit // 超出大小限制的完整结果，通过 GET /api/jobs/:id/results/:task/full 下载
+	MaxJobWait   time.Duration        // GET /api/jobs/:id 长轮询（wait 参数）的最长等待时间，超出按此处理
+	// TrustUserHeader 未登录的请求以 X-User-ID 请求头标识用户，任何人都可以借此冒充其他用户，只用于本地开发，默认关闭
+	TrustUserHeader bool
+
+	background sync.WaitGroup // 不随 HTTP 请求结束的批次：WebSocket 批次和异步批次
+}
+
+// APIRetryPolicy 返回API调用启用重试时的策略，attempts 为最大尝试次数（含首次调用）
+// 只重试暂时性错误，非幂等的请求只在请求未发出时重试；下游整体故障时不靠重试放大压力：每批最多重试任务数的 10%
+func APIRetryPolicy(attempts int) *services.RetryPolicy {
+	return &services.RetryPolicy{
+		MaxAttempts: attempts,
+		Backoff:     500 * time.Millisecond,
+		Multiplier:  2,
+		MaxBackoff:  5 * time.Second,
+		Jitter:      0.2,
+		Retryable:   services.RetryTransient,
+		Budget:      0.1,
+	}
 }
 
 // NewBatchHandler 创建新的批量处理控制器
-func NewBatchHandler() *BatchHandler {
-	return &BatchHandler{
+// readDB 为只读副本，列表、搜索、统计等查询接口走副本，写入仍走主库
+// locks 用于多实例部署时互斥执行归档等维护任务，pools 为各服务共享的命名工作池，可以为nil
+// results 用于按租户加密保存到磁盘的任务结果（产出物和超出大小限制的完整结果），为nil时以明文保存
+func NewBatchHandler(db, readDB *gorm.DB, locks *models.LockManager, pools *services.WorkerPools, results *services.ResultCipher) *BatchHandler {
+	progress := &services.DBProgressStore{DB: db, ReadDB: readDB}
+	// 超过 64KB 的任务结果截断，完整内容保存到磁盘
+	resultLimit := services.ResultLimit{MaxBytes: 64 << 10, Dir: "./artifacts/results", Cipher: results}
+
+	h := &BatchHandler{
 		OrderService: &services.OrderProcessService{
+			// 订单批次可能有上万个任务，使用固定数量的工作协程
+			Mode:           batch.ModeWorkerPool,
 			MaxConcurrency: 10,
 			Timeout:        30 * time.Second,
+			PerTaskTimeout: 10 * time.Second,
+			Pools:          pools,
+			ResultLimit:    resultLimit,
 		},
 		APIService: &services.APICallService{
 			MaxConcurrency: 5,
-			Timeout:        60 * time.Second,
-			Client:         &http.Client{Timeout: 10 * time.Second},
+			Timeouts: services.APITimeouts{
+				Connect: 3 * time.Second,
+				Request: 10 * time.Second,
+				Task:    30 * time.Second,
+				Batch:   60 * time.Second,
+			},
+			// 默认直接以满并发执行，下游为自动扩容的服务时通过 API_RAMP_DURATION 启用并发预热
+			// 默认不限速，下游接口有调用配额时通过 API_RATE_LIMIT 限制所有批次合计每秒开始的调用数
+			// 同一批次的调用通常集中在少数几个主机，预解析并缓存域名
+			DNS: &services.DNSCache{TTL: services.DefaultDNSTTL, NegativeTTL: services.DefaultDNSNegativeTTL},
+			// 某个主机持续失败时减少发往它的并发，其余主机不受影响，恢复后逐步加回
+			Throttle: &services.HostThrottle{MaxConcurrency: 5},
+			Pools:    pools,
+			// 默认不重试，调用方确认下游可以重复处理时通过 API_RETRY_ATTEMPTS 启用（见 APIRetryPolicy）
+			ResultLimit: resultLimit,
 		},
 		FileService: &services.FileProcessService{
-			MaxConcurrency: 3,
-			Timeout:        120 * time.Second,
-			UploadDir:      "./uploads",
+			MaxConcurrency:     3,
+			Timeout:            120 * time.Second,
+			PerTaskTimeout:     30 * time.Second,
+			Pools:              pools,
+			ResultLimit:        resultLimit,
+			UploadDir:          "./uploads",
+			UploadPolicy:       services.UploadPolicy{BlockExecutables: true},
+			OnConflict:         services.ConflictVersion,
+			ChecksumWorker:     4,
+			MinFreeBytes:       512 << 20,
+			WarnFreeBytes:      2 << 30,
+			HashIOConcurrency:  8,
+			HashCPUConcurrency: runtime.NumCPU(),
+			// 文件被其他进程锁定或占用时稍后重试
+			Retry: &services.RetryPolicy{
+				MaxAttempts: 3,
+				Backoff:     200 * time.Millisecond,
+				Multiplier:  2,
+				Jitter:      0.2,
+				Retryable:   services.RetryTransient,
+			},
 		},
+		Jobs:       services.NewJobManager(progress),
+		JobHistory: progress,
+		Files:      &services.FileMetadataService{DB: db, ReadDB: readDB},
+		Artifacts: &services.ArtifactService{
+			DB:           db,
+			ReadDB:       readDB,
+			Locks:        locks,
+			Dir:          "./artifacts",
+			Cold:         &services.LocalArchiveStorage{Dir: "./archive"},
+			ArchiveAfter: 30 * 24 * time.Hour,
+			Cipher:       results,
+		},
+		OrderStats: &services.OrderStatsService{DB: db, ReadDB: readDB},
+		APICalls:   &services.APICallStore{DB: db},
+		Accounts:   &services.AccountService{DB: db, SessionTTL: 7 * 24 * time.Hour},
+		Admin:      &services.AdminService{DB: db, ReadDB: readDB},
+		Templates:  &services.TemplateService{DB: db, ReadDB: readDB},
+		AccessLogs: services.NewAccessLogService(db, readDB, 10000),
+		Pools:      pools,
+		Tasks:      &services.TaskRegistry{},
+		Results:    results,
+		// 各服务共用的结果大小限制，下载完整结果时从同一目录读取
+		FullResults: resultLimit,
+	}
+	// 数据库暂时不可用时批次照常在内存中执行，任务记录的写入排队，在后台重试
+	h.Jobs.Persist = &services.PersistQueue{}
+	// 排队的任务达到上限时批量接口返回 429，避免无限制地接收批次耗尽内存
+	h.Jobs.MaxQueued = 1000
+	// 子任务超过 5 分钟没有心跳（如阻塞在已断开的连接上）时强制取消，释放工作槽位
+	h.Jobs.StallTimeout = 5 * time.Minute
+	// 启用调试捕获的批次为失败的任务保存调试包，与超出大小限制的结果一样按租户加密
+	h.Jobs.Debug = &services.DebugCapture{Dir: "./artifacts/debug", Cipher: results}
+	// 异步批次保存任务清单，服务意外退出后重启时从剩余的任务恢复执行
+	h.Jobs.Checkpoints = &services.CheckpointStore{DB: db}
+	// 任务的状态变更（登记、排队、执行、暂停、结束）写入数据库，供排查和审计
+	h.Jobs.Transitions = &services.TransitionStore{DB: db}
+	h.Customers = &services.CustomerDataService{DB: db, Jobs: h.Jobs, Artifacts: h.Artifacts, Debug: h.Jobs.Debug, FullResults: resultLimit}
+	// 由模板执行的批次按模板的保留策略清理任务记录和明细
+	h.Retention = &services.RetentionJanitor{DB: db, Locks: locks, Artifacts: h.Artifacts, Jobs: h.Jobs, DetailDirs: []string{resultLimit.Dir, h.Jobs.Debug.Dir}}
+	// 管理员开启维护模式后拒绝新批次，查询接口照常可用
+	h.Maintenance = &services.MaintenanceMode{}
+	// 每个批次可以在请求中调整并发数和批次超时，但不能超过这里的上限
+	h.Limits = services.RunLimits{MaxConcurrency: 50, Timeout: 10 * time.Minute}
+	// 长轮询最多保持 60 秒，避免连接长时间被占用
+	h.MaxJobWait = 60 * time.Second
+	// 流水线的 fetch 阶段与批量API调用共用HTTP客户端、域名缓存和重试策略
+	h.Pipelines = &services.PipelineService{
+		Fetcher:   h.APIService,
+		OutputDir: "./artifacts/pipelines",
+		Timeout:   120 * time.Second,
 	}
+	return h
+}
+
+// SelfTest 按处理器的配置创建启动自检：检查数据库、上传和产出物等存储目录，并以小批量执行订单、API调用和文件处理
+func (h *BatchHandler) SelfTest() *services.SelfTest {
+	dirs := []string{h.FileService.UploadDir, h.Artifacts.Dir}
+	if cold, ok := h.Artifacts.Cold.(*services.LocalArchiveStorage); ok {
+		dirs = append(dirs, cold.Dir)
+	}
+	if h.Files.Scanner != nil {
+		dirs = append(dirs, h.Files.Scanner.QuarantineDir)
+	}
+	return &services.SelfTest{
+		DB:     h.Files.DB,
+		ReadDB: h.Files.ReadDB,
+		Dirs:   dirs,
+		Orders: h.OrderService,
+		APIs:   h.APIService,
+		Files:  h.FileService,
+	}
+}
+
+// Warmup 返回启动预热，预热主库连接和 API 调用的 HTTP 连接，预热的连接数、主机和协程数由调用方设置
+func (h *BatchHandler) Warmup() *services.Warmup {
+	return &services.Warmup{DB: h.Files.DB, APIs: h.APIService}
+}
+
+// BatchOptions 批量处理接口共用的执行选项
+type BatchOptions struct {
+	FailFast   bool    `json:"fail_fast"`                                   // 首个任务失败后取消其余任务，返回已完成的结果
+	SampleRate float64 `json:"sample_rate" binding:"omitempty,min=0,max=1"` // 只随机执行该比例的任务并外推全量估算，0表示全部执行
+	SampleSeed int64   `json:"sample_seed"`                                 // 抽样种子，0表示使用批次种子，相同种子抽中相同的任务
+	Seed       int64   `json:"seed"`                                        // 批次种子，0表示随机生成；以相同的种子和配置重新提交可复现抽样和重试等待时间
+	// Ordered 结果是否按任务序号排列：true 时流式返回也按序号输出（先完成的结果暂存到前面的任务完成），
+	// false 时完整返回也按完成顺序排列；未指定时完整返回按序号，流式返回按完成顺序
+	Ordered *bool `json:"ordered,omitempty"`
+	// ChunkSize 分块执行时每块的任务数，0表示不分块；各块依次执行，块内仍按服务的并发数执行
+	ChunkSize int `json:"chunk_size" binding:"omitempty,min=1"`
+	// ChunkPauseMs 上一块全部完成后等待多少毫秒开始下一块，暂停时间计入批次超时
+	ChunkPauseMs int `json:"chunk_pause_ms" binding:"omitempty,min=0,max=600000"`
+	// ChainedFrom 上游任务ID，由 POST /api/jobs/:id/chain 填写，记录在批次的配置快照中
+	ChainedFrom string `json:"chained_from,omitempty"`
+	// TemplateID 批次模板ID，由 POST /api/templates/:id/run 填写，记录在批次的配置快照中
+	TemplateID uint `json:"template_id,omitempty"`
+	// Group 互斥组（如 nightly-reconciliation），同组的批次同一时间只执行一个，其余按提交顺序排队
+	Group string `json:"group,omitempty" binding:"omitempty,max=64"`
+	// Priority 全局队列中的优先级类别：high、normal（默认）、low，执行中的批次数达到上限时高优先级的先执行
+	Priority string `json:"priority,omitempty" binding:"omitempty,oneof=high normal low"`
+	// DependsOn 上游任务ID（须为当前用户的任务），上游成功完成后才开始执行；上游失败或被取消时不执行任何任务，任务状态为 skipped
+	DependsOn string `json:"depends_on,omitempty"`
+	// DebugCapture 为失败和超时的任务保存调试包（完整输入、执行环境和下游响应），通过 GET /api/jobs/:id/debug 下载
+	DebugCapture bool `json:"debug_capture"`
+	// WorkerUsage 在报告中记录每个工作槽位的线程 CPU 时间（仅 Linux），执行任务期间协程固定在线程上，只在分析资源消耗时启用
+	WorkerUsage bool `json:"worker_usage"`
+	// Async 立即返回 202 和 job_id，批次在后台执行，通过 GET /api/jobs/:id 轮询进度、/api/jobs/:id/result 获取结果
+	Async bool `json:"async"`
+	// Expected 各任务的预期输出，按任务序号对应，不比较的任务填 null；执行成功但结果不符的任务状态为 mismatch
+	Expected []json.RawMessage `json:"expected,omitempty"`
+	// MaxConcurrency 覆盖服务配置的并发数，0表示按服务的配置；不能超过服务端的上限（BatchHandler.Limits）
+	MaxConcurrency int `json:"max_concurrency" binding:"omitempty,min=1"`
+	// TimeoutMs 覆盖服务配置的批次超时（毫秒），0表示按服务的配置；不能超过服务端的上限，排队时间不计入
+	TimeoutMs int `json:"timeout_ms" binding:"omitempty,min=1"`
+}
+
+// newRun 记录批次的种子和配置快照，service 为执行该批次的服务的配置
+// 预期输出属于输入数据，不记入配置快照
+func (o BatchOptions) newRun(service map[string]interface{}) *services.RunRecord {
+	o.Expected = nil
+	return services.NewRunRecord(o.Seed, gin.H{"service": service, "options": o})
+}
+
+// expectations 解析预期输出，项数与提交的任务数不符或格式错误时返回 400
+func (o BatchOptions) expectations(c *gin.Context, tasks int) (*services.Expectations, bool) {
+	expect, err := services.NewExpectations(o.Expected, tasks)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "预期输出错误: " + err.Error()})
+		return nil, false
+	}
+	return expect, true
+}
+
+// batchContext 指定上游任务时等上游成功完成后、指定互斥组时等同组的前序任务结束后、再在全局队列中取得执行名额后返回，
+// 等待和排队时间不计入批次超时。先等待上游、再进入互斥组，等待中的批次不占用互斥组和全局队列的名额；
+// 排队中被取消或上游未成功完成时返回的 ctx 已取消或任务已软取消，批次不会执行任何任务
+func (h *BatchHandler) batchContext(ctx context.Context, job *services.Job, group string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	h.enterJob(ctx, job, group)
+	return context.WithTimeout(ctx, timeout)
+}
+
+// enterJob 依次等待上游任务、互斥组和全局队列，见 batchContext
+func (h *BatchHandler) enterJob(ctx context.Context, job *services.Job, group string) {
+	if h.Jobs.WaitDependency(ctx, job) == nil && h.Jobs.EnterGroup(ctx, job, group) == nil {
+		h.Jobs.EnterQueue(ctx, job)
+	}
+}
+
+// limits 请求覆盖的并发数和批次超时
+func (o BatchOptions) limits() services.RunLimits {
+	return services.RunLimits{MaxConcurrency: o.MaxConcurrency, Timeout: time.Duration(o.TimeoutMs) * time.Millisecond}
+}
+
+// timeout 批次超时：请求指定了 timeout_ms 时取请求的值，否则为服务配置的 d
+func (o BatchOptions) timeout(d time.Duration) time.Duration {
+	if o.TimeoutMs > 0 {
+		return time.Duration(o.TimeoutMs) * time.Millisecond
+	}
+	return d
+}
+
+// checkLimits 检查请求覆盖的并发数和批次超时，超出服务端的上限时返回 400
+func (h *BatchHandler) checkLimits(c *gin.Context, o BatchOptions) bool {
+	if err := o.limits().Check(h.Limits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
+
+// resultOrder 结果的顺序
+func (o BatchOptions) resultOrder() services.ResultOrder {
+	switch {
+	case o.Ordered == nil:
+		return services.ResultOrderDefault
+	case *o.Ordered:
+		return services.ResultOrderInput
+	}
+	return services.ResultOrderCompletion
+}
+
+// chunks 分块执行的设置，未指定 chunk_size 时返回nil
+func (o BatchOptions) chunks() *batch.Chunks {
+	if o.ChunkSize == 0 {
+		return nil
+	}
+	return &batch.Chunks{Size: o.ChunkSize, Pause: time.Duration(o.ChunkPauseMs) * time.Millisecond}
+}
+
+// sampleSeed 抽样使用的种子，未单独指定时使用批次种子
+func (o BatchOptions) sampleSeed(run *services.RunRecord) int64 {
+	if o.SampleSeed != 0 {
+		return o.SampleSeed
+	}
+	return run.Seed
 }
 
 // BatchProcessOrdersRequest 批量处理订单请求
 type BatchProcessOrdersRequest struct {
 	Orders []services.OrderTask `json:"orders" binding:"required"`
+	BatchOptions
 }
 
 // BatchProcessOrders 批量处理订单
@@ -54,57 +340,154 @@ func (h *BatchHandler) BatchProcessOrders(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
 		return
 	}
+	if err := services.CheckOrderDeps(req.Orders, req.SampleRate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "订单依赖关系错误: " + err.Error()})
+		return
+	}
+	fields, ok := resultFields(c)
+	if !ok {
+		return
+	}
+	expect, ok := req.expectations(c, len(req.Orders))
+	if !ok {
+		return
+	}
+	if !h.checkLimits(c, req.BatchOptions) {
+		return
+	}
+
+	// 记录种子和配置快照，便于之后复现该批次
+	run := req.newRun(h.OrderService.RunConfig())
 
-	// 创建上下文，设置超时
-	ctx, cancel := context.WithTimeout(context.Background(), h.OrderService.Timeout)
+	// 指定抽样比例时只执行抽中的订单
+	orders, sample := services.SampleTasks(req.Orders, req.SampleRate, req.sampleSeed(run))
+
+	if !h.checkDependsOn(c, req.DependsOn) {
+		return
+	}
+
+	// 登记任务，便于通过 DELETE /api/jobs/:id 取消
+	job, ctx := h.startJob(c, "order", len(orders))
+	job.SetTenant(requestTenant(c))
+	markDegraded(c, job)
+	job.SetRun(run)
+	job.SetChunks(req.chunks())
+	job.SetLimits(req.limits())
+	job.SetResultOrder(req.resultOrder())
+	job.SetPriority(req.Priority)
+	job.SetDependsOn(req.DependsOn)
+	job.SetTemplate(c.GetUint(templateRunKey), c.GetDuration(templateRetentionKey))
+	if req.FailFast {
+		job.EnableFailFast()
+	}
+	if req.DebugCapture {
+		job.EnableDebugCapture()
+	}
+	if req.WorkerUsage {
+		job.EnableWorkerUsage()
+	}
+
+	// 执行批量处理，订单汇总按抽样子集内的序号累计，之后再还原为原批次的序号
+	execute := func(ctx context.Context) *services.BatchResult {
+		result := h.OrderService.BatchProcessOrders(ctx, orders)
+		h.recordOrderRollup(job, orders, result)
+		return expect.Apply(sample.Apply(result))
+	}
+	if req.Async {
+		if !hasOrderDeps(orders) {
+			h.saveCheckpoint(c, job, orders, req.BatchOptions)
+		}
+		h.startAsync(c, ctx, job, req.Group, req.timeout(h.OrderService.Timeout), execute)
+		return
+	}
+
+	// 指定互斥组时等同组的前序任务结束后再执行，批次超时从开始执行时计算
+	ctx, cancel := h.batchContext(ctx, job, req.Group, req.timeout(h.OrderService.Timeout))
 	defer cancel()
 
-	// 执行批量处理
-	result := h.OrderService.BatchProcessOrders(ctx, req.Orders)
+	// 客户端要求 NDJSON 时逐行返回结果
+	if wantsNDJSON(c) {
+		// 订单汇总随结果逐个累计，不需要保留全部结果
+		rollup := services.NewOrderRollup(job.ID(), orders)
+		result := h.streamNDJSON(c, job, fields, func(emit func(services.TaskResult)) *services.BatchResult {
+			return expect.Summarize(sample.Apply(h.OrderService.EachOrder(ctx, orders, func(r services.TaskResult) {
+				rollup.Add(r)
+				emit(expect.Check(sample.Remap(r)))
+			})))
+		})
+		if _, err := h.OrderStats.Save(rollup, result); err != nil {
+			log.Printf("保存订单批次 %s 的汇总失败: %v", job.ID(), err)
+		}
+		return
+	}
+
+	result := execute(ctx)
+	h.finishJob(job, result)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "批量订单处理完成",
-		"data":    result,
+		"job_id":  job.ID(),
+		"data":    fields.batch(result),
 	})
 }
 
+// recordOrderRollup 保存订单批次的汇总和每个订单的处理记录
+func (h *BatchHandler) recordOrderRollup(job *services.Job, orders []services.OrderTask, result *services.BatchResult) {
+	if _, err := h.OrderStats.Record(job.ID(), orders, result); err != nil {
+		log.Printf("保存订单批次 %s 的汇总失败: %v", job.ID(), err)
+	}
+}
+
+// recordAPICalls 保存API批次中每个调用的记录
+func (h *BatchHandler) recordAPICalls(job *services.Job, tasks []services.APICallTask, result *services.BatchResult) {
+	if err := h.APICalls.Record(job.ID(), tasks, result); err != nil {
+		log.Printf("保存API批次 %s 的调用记录失败: %v", job.ID(), err)
+	}
+}
+
 // GenerateOrdersRequest 生成订单请求
 type GenerateOrdersRequest struct {
-	Count int `json:"count" binding:"required,min=1,max=1000"`
+	Count int   `json:"count" binding:"required,min=1,max=1000"`
+	Seed  int64 `json:"seed"` // 0 表示随机生成，相同的种子和规则生成相同的订单
+	// Fields 覆盖或增加字段的生成规则（取值范围、分布、错误注入），null 表示去掉默认字段，未指定的字段使用默认规则
+	Fields map[string]*services.FieldSpec `json:"fields,omitempty"`
 }
 
-// GenerateOrders 生成测试订单
+// GenerateOrders 按生成规则生成测试订单
 func (h *BatchHandler) GenerateOrders(c *gin.Context) {
 	var req GenerateOrdersRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
 		return
 	}
-
-	orders := make([]services.OrderTask, req.Count)
-	products := []string{"iPhone 15", "MacBook Pro", "iPad Air", "Apple Watch", "AirPods Pro"}
-
-	for i := 0; i < req.Count; i++ {
-		orders[i] = services.OrderTask{
-			ID:          i + 1,
-			CustomerID:  fmt.Sprintf("CUST_%04d", i+1),
-			ProductName: products[i%len(products)],
-			Quantity:    (i % 5) + 1,
-			Price:       float64(100 + (i%10)*50),
-		}
+	generated, ok := generate(c, services.GeneratorSpec{Count: req.Count, Seed: req.Seed, Fields: req.Fields}, services.DefaultOrderFields())
+	if !ok {
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "订单生成成功",
-		"data":    orders,
+		"data":    generated.Items,
+		"seed":    generated.Seed,
 	})
 }
 
+// generate 按规则生成测试数据，规则不正确时返回 400
+func generate(c *gin.Context, spec services.GeneratorSpec, defaults map[string]services.FieldSpec) (*services.GeneratedData, bool) {
+	generated, err := spec.Generate(defaults)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return generated, true
+}
+
 // BatchCallAPIsRequest 批量API调用请求
 type BatchCallAPIsRequest struct {
 	APIs []services.APICallTask `json:"apis" binding:"required"`
+	BatchOptions
 }
 
 // BatchCallAPIs 批量调用API
@@ -114,58 +497,116 @@ func (h *BatchHandler) BatchCallAPIs(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
 		return
 	}
+	fields, ok := resultFields(c)
+	if !ok {
+		return
+	}
+	expect, ok := req.expectations(c, len(req.APIs))
+	if !ok {
+		return
+	}
+	if !h.checkLimits(c, req.BatchOptions) {
+		return
+	}
 
-	// 创建上下文，设置超时
-	ctx, cancel := context.WithTimeout(context.Background(), h.APIService.Timeout)
-	defer cancel()
+	// 记录种子和配置快照，便于之后复现该批次
+	run := req.newRun(h.APIService.RunConfig())
+
+	// 指定抽样比例时只执行抽中的任务
+	tasks, sample := services.SampleTasks(req.APIs, req.SampleRate, req.sampleSeed(run))
+
+	if !h.checkDependsOn(c, req.DependsOn) {
+		return
+	}
+
+	// 登记任务，便于通过 DELETE /api/jobs/:id 取消
+	job, ctx := h.startJob(c, "api", len(tasks))
+	job.SetTenant(requestTenant(c))
+	markDegraded(c, job)
+	job.SetRun(run)
+	job.SetChunks(req.chunks())
+	job.SetLimits(req.limits())
+	job.SetResultOrder(req.resultOrder())
+	job.SetPriority(req.Priority)
+	job.SetDependsOn(req.DependsOn)
+	job.SetTemplate(c.GetUint(templateRunKey), c.GetDuration(templateRetentionKey))
+	if req.FailFast {
+		job.EnableFailFast()
+	}
+	if req.DebugCapture {
+		job.EnableDebugCapture()
+	}
+	if req.WorkerUsage {
+		job.EnableWorkerUsage()
+	}
 
 	// 执行批量调用
-	result := h.APIService.BatchCallAPIs(ctx, req.APIs)
+	execute := func(ctx context.Context) *services.BatchResult {
+		result := h.APIService.BatchCallAPIs(ctx, tasks)
+		h.recordAPICalls(job, tasks, result)
+		return expect.Apply(sample.Apply(result))
+	}
+	if req.Async {
+		h.saveCheckpoint(c, job, tasks, req.BatchOptions)
+		h.startAsync(c, ctx, job, req.Group, req.timeout(h.APIService.Timeouts.Batch), execute)
+		return
+	}
+
+	// 指定互斥组时等同组的前序任务结束后再执行，批次超时从开始执行时计算
+	ctx, cancel := h.batchContext(ctx, job, req.Group, req.timeout(h.APIService.Timeouts.Batch))
+	defer cancel()
+
+	// 客户端要求 NDJSON 时逐行返回结果
+	if wantsNDJSON(c) {
+		calls := services.NewAPICallLog(job.ID(), tasks)
+		h.streamNDJSON(c, job, fields, func(emit func(services.TaskResult)) *services.BatchResult {
+			return expect.Summarize(sample.Apply(h.APIService.EachAPICall(ctx, tasks, func(r services.TaskResult) {
+				calls.Add(r)
+				emit(expect.Check(sample.Remap(r)))
+			})))
+		})
+		if err := h.APICalls.Save(calls); err != nil {
+			log.Printf("保存API批次 %s 的调用记录失败: %v", job.ID(), err)
+		}
+		return
+	}
+
+	result := execute(ctx)
+	h.finishJob(job, result)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "批量API调用完成",
-		"data":    result,
+		"job_id":  job.ID(),
+		"data":    fields.batch(result),
 	})
 }
 
 // GenerateAPICallsRequest 生成API调用请求
 type GenerateAPICallsRequest struct {
-	Count int `json:"count" binding:"required,min=1,max=50"`
+	Count int   `json:"count" binding:"required,min=1,max=50"`
+	Seed  int64 `json:"seed"` // 0 表示随机生成
+	// Fields 覆盖或增加字段的生成规则，同 GenerateOrdersRequest
+	Fields map[string]*services.FieldSpec `json:"fields,omitempty"`
 }
 
-// GenerateAPICalls 生成测试API调用
+// GenerateAPICalls 按生成规则生成测试API调用
 func (h *BatchHandler) GenerateAPICalls(c *gin.Context) {
 	var req GenerateAPICallsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
 		return
 	}
-
-	apis := make([]services.APICallTask, req.Count)
-	testAPIs := []string{
-		"https://jsonplaceholder.typicode.com/posts",
-		"https://httpbin.org/get",
-		"https://api.github.com/users/octocat",
-		"https://httpbin.org/delay/1",
-		"https://httpbin.org/status/200",
-	}
-
-	for i := 0; i < req.Count; i++ {
-		apis[i] = services.APICallTask{
-			ID:     i + 1,
-			URL:    testAPIs[i%len(testAPIs)],
-			Method: "GET",
-			Headers: map[string]string{
-				"User-Agent": "ConcurrencyApp/1.0",
-			},
-		}
+	generated, ok := generate(c, services.GeneratorSpec{Count: req.Count, Seed: req.Seed, Fields: req.Fields}, services.DefaultAPICallFields())
+	if !ok {
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "API调用列表生成成功",
-		"data":    apis,
+		"data":    generated.Items,
+		"seed":    generated.Seed,
 	})
 }
 
@@ -183,39 +624,204 @@ func (h *BatchHandler) UploadFiles(c *gin.Context) {
 		return
 	}
 
-	// 确保上传目录存在
-	uploadDir := h.FileService.UploadDir
+	// 确保上传目录存在，按日期分区存储
+	uploadDir := services.PartitionDir(h.FileService.UploadDir, time.Now())
 	if err := os.MkdirAll(uploadDir, 0755); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传目录失败: " + err.Error()})
 		return
 	}
 
-	var uploadedFiles []map[string]interface{}
-
+	// 检查剩余空间，空间不足时拒绝上传
+	var incoming int64
 	for _, file := range files {
-		// 生成唯一文件名
-		filename := fmt.Sprintf("%d_%s", time.Now().Unix(), file.Filename)
-		filePath := filepath.Join(uploadDir, filename)
+		incoming += file.Size
+	}
+	warning, err := h.FileService.CheckCapacity(incoming)
+	if errors.Is(err, services.ErrInsufficientStorage) {
+		c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查磁盘空间失败: " + err.Error()})
+		return
+	}
+
+	// 同名文件冲突策略，未指定时使用服务默认值
+	onConflict := c.DefaultPostForm("on_conflict", h.FileService.OnConflict)
+	if !services.ValidConflictPolicy(onConflict) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的冲突策略: " + onConflict})
+		return
+	}
+
+	// 同名文件只在当前用户上传的文件中查找，覆盖不会影响其他用户的文件
+	owner := requestUser(c)
+
+	// 先校验所有文件，任一文件不符合策略时整体拒绝
+	mimeTypes := make([]string, len(files))
+	for i, file := range files {
+		if onConflict == services.ConflictReject {
+			existing, err := h.Files.LatestVersion(owner, file.Filename)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件信息失败: " + err.Error()})
+				return
+			}
+			if existing != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": services.ErrFileExists.Error() + ": " + file.Filename})
+				return
+			}
+		}
+
+		head, err := h.readUploadedHead(file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取文件失败: " + err.Error()})
+			return
+		}
+
+		mimeType, err := h.FileService.UploadPolicy.Inspect(file.Filename, head)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "文件校验失败: " + err.Error()})
+			return
+		}
+		mimeTypes[i] = mimeType
+	}
+
+	// 标签和描述作用于本次上传的所有文件
+	tags := services.NormalizeTags(form.Value["tags"])
+	description := c.PostForm("description")
+
+	// 启用扫描时文件先登记为等待扫描，扫描通过前不能处理
+	scanStatus := ""
+	if h.Files.Scanner != nil {
+		scanStatus = services.ScanStatusPending
+	}
+	records := make([]*models.FileTask, 0, len(files))
+
+	for i, file := range files {
+		existing, err := h.Files.LatestVersion(owner, file.Filename)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件信息失败: " + err.Error()})
+			return
+		}
+
+		record := &models.FileTask{
+			OriginalName: file.Filename,
+			Owner:        owner,
+			FileSize:     file.Size,
+			MimeType:     mimeTypes[i],
+			ScanStatus:   scanStatus,
+			Tags:         tags,
+			Description:  description,
+		}
+
+		switch {
+		case existing != nil && onConflict == services.ConflictOverwrite:
+			// 覆盖最新版本，沿用原有的存储路径；被隔离的版本改存回上传目录
+			record = existing
+			if record.Status == services.FileStatusQuarantined {
+				record.FilePath = filepath.Join(uploadDir, record.FileName)
+			}
+		default:
+			// 生成唯一文件名，同名文件作为新版本保存，版本号在登记时分配
+			record.FileName = fmt.Sprintf("%d_%s", time.Now().UnixNano(), file.Filename)
+		}
+		if record.FilePath == "" {
+			record.FilePath = filepath.Join(uploadDir, record.FileName)
+		}
 
 		// 保存文件
-		if err := h.saveUploadedFile(file, filePath); err != nil {
+		if err := h.saveUploadedFile(file, record.FilePath); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败: " + err.Error()})
 			return
 		}
 
-		uploadedFiles = append(uploadedFiles, map[string]interface{}{
-			"original_name": file.Filename,
-			"saved_name":    filename,
-			"file_path":     filePath,
-			"size":          file.Size,
-		})
+		// 计算校验和，供后续完整性校验使用
+		checksum, err := services.ComputeChecksum(c.Request.Context(), record.FilePath, h.FileService.ChecksumWorker)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "计算校验和失败: " + err.Error()})
+			return
+		}
+
+		// 登记文件元数据
+		if record == existing {
+			err = h.Files.ReplaceUpload(existing, &models.FileTask{
+				FileSize:    file.Size,
+				MimeType:    mimeTypes[i],
+				Checksum:    checksum.Checksum,
+				ChunkHashes: checksum.JoinedChunkHashes(),
+				ScanStatus:  scanStatus,
+				Tags:        tags,
+				Description: description,
+			})
+			record.Status = "uploaded"
+			record.ScanStatus = scanStatus
+			record.ScanSignature = ""
+			record.ScannedAt = nil
+		} else {
+			record.Checksum = checksum.Checksum
+			record.ChunkHashes = checksum.JoinedChunkHashes()
+			err = h.Files.RecordUpload(record)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件信息失败: " + err.Error()})
+			return
+		}
+		records = append(records, record)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	// 并发扫描本次上传的文件，发现威胁的文件被隔离
+	if h.Files.Scanner != nil {
+		if err := h.Files.ScanUploads(c.Request.Context(), records); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存扫描结果失败: " + err.Error()})
+			return
+		}
+	}
+
+	uploadedFiles := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		uploadedFiles[i] = map[string]interface{}{
+			"id":            record.ID,
+			"original_name": record.OriginalName,
+			"saved_name":    record.FileName,
+			"file_path":     record.FilePath,
+			"version":       record.Version,
+			"size":          files[i].Size,
+			"mime_type":     mimeTypes[i],
+			"checksum":      record.Checksum,
+			"tags":          record.Tags,
+			"description":   record.Description,
+			"status":        record.Status,
+		}
+		if record.ScanStatus != "" {
+			uploadedFiles[i]["scan_status"] = record.ScanStatus
+			uploadedFiles[i]["scan_signature"] = record.ScanSignature
+		}
+	}
+
+	response := gin.H{
 		"success": true,
 		"message": "文件上传成功",
 		"data":    uploadedFiles,
-	})
+	}
+	if warning != "" {
+		response["warning"] = warning
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// readUploadedHead 读取上传文件的头部用于内容嗅探
+func (h *BatchHandler) readUploadedHead(file *multipart.FileHeader) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	head := make([]byte, services.SniffLen)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
 }
 
 // saveUploadedFile 保存上传的文件
@@ -239,6 +845,7 @@ func (h *BatchHandler) saveUploadedFile(file *multipart.FileHeader, dst string)
 // BatchProcessFilesRequest 批量处理文件请求
 type BatchProcessFilesRequest struct {
 	Files []services.FileTask `json:"files" binding:"required"`
+	BatchOptions
 }
 
 // BatchProcessFiles 批量处理文件
@@ -248,48 +855,156 @@ func (h *BatchHandler) BatchProcessFiles(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
 		return
 	}
+	fields, ok := resultFields(c)
+	if !ok {
+		return
+	}
+	expect, ok := req.expectations(c, len(req.Files))
+	if !ok {
+		return
+	}
+	if !h.checkLimits(c, req.BatchOptions) {
+		return
+	}
 
-	// 创建上下文，设置超时
-	ctx, cancel := context.WithTimeout(context.Background(), h.FileService.Timeout)
-	defer cancel()
+	// 记录种子和配置快照，便于之后复现该批次
+	run := req.newRun(h.FileService.RunConfig())
+
+	// 指定抽样比例时只执行抽中的任务，只需解析抽中的文件
+	tasks, sample := services.SampleTasks(req.Files, req.SampleRate, req.sampleSeed(run))
+
+	// 按文件ID或版本解析出实际的存储路径
+	if err := h.resolveFileTasks(tasks, requestUser(c)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "解析文件失败: " + err.Error()})
+		return
+	}
+
+	if !h.checkDependsOn(c, req.DependsOn) {
+		return
+	}
+
+	// 登记任务，便于通过 DELETE /api/jobs/:id 取消
+	job, ctx := h.startJob(c, "file", len(tasks))
+	job.SetTenant(requestTenant(c))
+	markDegraded(c, job)
+	job.SetRun(run)
+	job.SetChunks(req.chunks())
+	job.SetLimits(req.limits())
+	job.SetResultOrder(req.resultOrder())
+	job.SetPriority(req.Priority)
+	job.SetDependsOn(req.DependsOn)
+	job.SetTemplate(c.GetUint(templateRunKey), c.GetDuration(templateRetentionKey))
+	if req.FailFast {
+		job.EnableFailFast()
+	}
+	if req.DebugCapture {
+		job.EnableDebugCapture()
+	}
+	if req.WorkerUsage {
+		job.EnableWorkerUsage()
+	}
 
 	// 执行批量处理
-	result := h.FileService.BatchProcessFiles(ctx, req.Files)
+	execute := func(ctx context.Context) *services.BatchResult {
+		return expect.Apply(sample.Apply(h.FileService.BatchProcessFiles(ctx, tasks)))
+	}
+	if req.Async {
+		h.saveCheckpoint(c, job, tasks, req.BatchOptions)
+		h.startAsync(c, ctx, job, req.Group, req.timeout(h.FileService.Timeout), execute)
+		return
+	}
+
+	// 指定互斥组时等同组的前序任务结束后再执行，批次超时从开始执行时计算
+	ctx, cancel := h.batchContext(ctx, job, req.Group, req.timeout(h.FileService.Timeout))
+	defer cancel()
+
+	// 客户端要求 NDJSON 时逐行返回结果
+	if wantsNDJSON(c) {
+		h.streamNDJSON(c, job, fields, func(emit func(services.TaskResult)) *services.BatchResult {
+			return expect.Summarize(sample.Apply(h.FileService.EachFile(ctx, tasks, func(r services.TaskResult) {
+				emit(expect.Check(sample.Remap(r)))
+			})))
+		})
+		return
+	}
+
+	result := execute(ctx)
+	h.finishJob(job, result)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "批量文件处理完成",
-		"data":    result,
+		"job_id":  job.ID(),
+		"data":    fields.batch(result),
 	})
 }
 
-// ListUploadedFiles 列出已上传的文件
-func (h *BatchHandler) ListUploadedFiles(c *gin.Context) {
-	uploadDir := h.FileService.UploadDir
+// resolveFileTasks 将按文件ID或版本指定的任务解析为实际的存储路径
+func (h *BatchHandler) resolveFileTasks(tasks []services.FileTask, owner string) error {
+	for i, task := range tasks {
+		resolved, err := h.resolveFileTask(task, owner)
+		if err != nil {
+			return err
+		}
+		tasks[i] = resolved
+	}
+	return nil
+}
 
-	// 读取目录
-	entries, err := os.ReadDir(uploadDir)
+// resolveFileTask 解析单个任务，未按文件ID或版本指定时原样返回；按文件ID或版本指定时只查找 owner 上传的文件
+func (h *BatchHandler) resolveFileTask(task services.FileTask, owner string) (services.FileTask, error) {
+	var (
+		file *models.FileTask
+		err  error
+	)
+	switch {
+	case task.FileID != 0:
+		file, err = h.Files.Get(task.FileID, owner)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = fmt.Errorf("文件 %d 不存在", task.FileID)
+		}
+	case task.Version != 0:
+		file, err = h.Files.FindVersion(owner, task.FileName, task.Version)
+	default:
+		return task, nil
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取目录失败: " + err.Error()})
+		return task, err
+	}
+	if err := services.CheckScanned(file); err != nil {
+		return task, err
+	}
+	task.FilePath = file.FilePath
+	task.FileName = file.FileName
+	return task, nil
+}
+
+// ListUploadedFiles 列出当前用户上传的文件
+// 文件按日期分区存储，列表以登记的文件信息为准，客户端无需关心物理目录结构；分区之前保存的文件在启动时登记，见 RegisterExistingUploads
+func (h *BatchHandler) ListUploadedFiles(c *gin.Context) {
+	records, err := h.Files.List(requestUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取文件列表失败: " + err.Error()})
 		return
 	}
 
 	var files []map[string]interface{}
-	for i, entry := range entries {
-		if !entry.IsDir() {
-			info, err := entry.Info()
-			if err != nil {
-				continue
-			}
+	for i, record := range records {
+		files = append(files, map[string]interface{}{
+			"id":            i + 1,
+			"file_id":       record.ID,
+			"file_name":     record.FileName,
+			"original_name": record.OriginalName,
+			"version":       record.Version,
+			"file_path":     record.FilePath,
+			"size":          record.FileSize,
+			"mod_time":      record.UpdatedAt,
+		})
+	}
 
-			files = append(files, map[string]interface{}{
-				"id":        i + 1,
-				"file_name": entry.Name(),
-				"file_path": filepath.Join(uploadDir, entry.Name()),
-				"size":      info.Size(),
-				"mod_time":  info.ModTime(),
-			})
-		}
+	// 列表未变化时客户端可以直接使用缓存
+	if etag, err := contentETag(files); err == nil && notModified(c, etag) {
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -299,39 +1014,290 @@ func (h *BatchHandler) ListUploadedFiles(c *gin.Context) {
 	})
 }
 
+// maxBatchBodyBytes 批量接口请求体的字节数上限，超出时返回 413
+const maxBatchBodyBytes = 32 << 20
+
 // SetupRoutes 设置路由
+// 路由注册时同时声明接口定义，生成 /api/openapi.json 并据此校验请求，文档与实际行为不会脱节
 func (h *BatchHandler) SetupRoutes(r *gin.Engine) {
-	api := r.Group("/api")
+	spec := openapi.NewSpec("Concurrency Web App API", "1.0.0")
+	api := openapi.NewRouter(r.Group("/api", h.accessLog(), h.Authenticate()), spec)
 	{
+		idParam := []openapi.Param{{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "integer", Minimum: openapi.Float(1)}}}
+		tenantFilter := []openapi.Param{openapi.QueryInt("tenant_id", "按租户过滤", openapi.Float(1), nil)}
+		// 路由级并发限制，与任务级并发数相互独立，防止大量请求同时占用内存；
+		// 注册在路由的第一个处理函数，重复提交检测、排队检查和接口定义校验读取请求体之前先取得名额
+		uploadLimit := middleware.ConcurrencyLimit(middleware.LimitConfig{Max: 5, Queue: 20, Wait: 30 * time.Second})
+		batchLimit := func() gin.HandlerFunc {
+			return middleware.ConcurrencyLimit(middleware.LimitConfig{Max: 10, Queue: 50, Wait: 30 * time.Second, MaxBodyBytes: maxBatchBodyBytes})
+		}
+		limited := map[int]string{200: "成功", 413: "请求体过大", 429: "同时处理的请求或排队的任务过多", 503: "服务正在关闭或维护中"}
+		// 短时间内重复提交相同批次：订单处理有副作用，直接拒绝；API调用和文件处理只在响应头中标记
+		duplicateGuard := func(policy middleware.DuplicatePolicy) gin.HandlerFunc {
+			return middleware.DuplicateGuard(middleware.DuplicateConfig{Window: 10 * time.Second, Policy: policy, Key: requestUser})
+		}
+
+		fieldsParam := []openapi.Param{openapi.Query("fields", "只返回任务结果中的指定字段，逗号分隔，如 id,success,duration")}
+		dateRange := []openapi.Param{
+			openapi.Query("from", "开始时间，2006-01-02 或 RFC3339"),
+			openapi.Query("to", "结束时间，2006-01-02 或 RFC3339，只有日期时包含当天"),
+		}
+
+		// 用户账号相关路由
+		auth := api.Group("/auth")
+		{
+			tags := []string{"auth"}
+			auth.POST("/register", openapi.Operation{Summary: "注册用户", Tags: tags, Body: AuthRequest{},
+				Responses: map[int]string{201: "注册成功", 409: "用户名已存在"}}, h.Register)
+			auth.POST("/login", openapi.Operation{Summary: "登录", Tags: tags, Body: AuthRequest{},
+				Responses: map[int]string{200: "登录成功", 401: "用户名或密码错误"}}, h.Login)
+			auth.POST("/logout", openapi.Operation{Summary: "注销当前会话", Tags: tags}, h.Logout)
+			auth.GET("/me", openapi.Operation{Summary: "当前登录用户", Tags: tags,
+				Responses: map[int]string{200: "成功", 401: "未登录"}}, h.GetCurrentUser)
+		}
+
 		// 订单处理相关路由
 		orders := api.Group("/orders")
 		{
-			orders.POST("/generate", h.GenerateOrders)
-			orders.POST("/batch-process", h.BatchProcessOrders)
+			tags := []string{"orders"}
+			orders.POST("/generate", openapi.Operation{Summary: "生成测试订单", Tags: tags, Body: GenerateOrdersRequest{}}, h.GenerateOrders)
+			orders.POST("/batch-process", openapi.Operation{Summary: "批量处理订单，Accept: application/x-ndjson 时流式返回", Tags: tags,
+				Params: fieldsParam, Body: BatchProcessOrdersRequest{},
+				Responses: map[int]string{200: "成功", 409: "相同批次重复提交", 413: "请求体过大", 429: "同时处理的请求或排队的任务过多", 503: "服务正在关闭或维护中"}},
+				batchLimit(), duplicateGuard(middleware.DuplicateReject), h.acceptingBatches(), h.BatchProcessOrders)
+			orders.POST("/validate", openapi.Operation{Summary: "预检批量订单，只校验不执行", Tags: tags,
+				Body: BatchProcessOrdersRequest{}}, h.ValidateOrders)
 		}
 
 		// API调用相关路由
 		apiCalls := api.Group("/api-calls")
 		{
-			apiCalls.POST("/generate", h.GenerateAPICalls)
-			apiCalls.POST("/batch-call", h.BatchCallAPIs)
+			tags := []string{"api-calls"}
+			apiCalls.POST("/generate", openapi.Operation{Summary: "生成测试API调用", Tags: tags, Body: GenerateAPICallsRequest{}}, h.GenerateAPICalls)
+			apiCalls.POST("/batch-call", openapi.Operation{Summary: "批量调用API，Accept: application/x-ndjson 时流式返回", Tags: tags,
+				Params: fieldsParam, Body: BatchCallAPIsRequest{}, Responses: limited},
+				batchLimit(), duplicateGuard(middleware.DuplicateWarn), h.acceptingBatches(), h.BatchCallAPIs)
+			apiCalls.POST("/validate", openapi.Operation{Summary: "预检批量API调用，只校验不发出请求", Tags: tags,
+				Body: BatchCallAPIsRequest{}}, h.ValidateAPICalls)
 		}
 
 		// 文件处理相关路由
 		files := api.Group("/files")
 		{
-			files.POST("/upload", h.UploadFiles)
-			files.GET("/list", h.ListUploadedFiles)
-			files.POST("/batch-process", h.BatchProcessFiles)
+			tags := []string{"files"}
+			files.POST("/upload", openapi.Operation{Summary: "上传文件（字段 files，可选 on_conflict、tags、description）", Tags: tags,
+				ContentType: "multipart/form-data", Responses: limited}, uploadLimit, h.UploadFiles)
+			files.GET("/list", openapi.Operation{Summary: "已上传文件列表，支持 ETag", Tags: tags}, h.ListUploadedFiles)
+			files.GET("/search", openapi.Operation{Summary: "搜索文件", Tags: tags, Params: append([]openapi.Param{
+				openapi.Query("tag", "标签"),
+				openapi.Query("name", "文件名包含"),
+				openapi.QueryInt("min_size", "最小字节数", openapi.Float(0), nil),
+				openapi.QueryInt("max_size", "最大字节数", openapi.Float(0), nil),
+			}, dateRange...)}, h.SearchFiles)
+			files.GET("/usage", openapi.Operation{Summary: "存储用量", Tags: tags}, h.GetStorageUsage)
+			files.PUT("/:id/metadata", openapi.Operation{Summary: "更新文件标签和描述", Tags: tags, Params: idParam,
+				Body: UpdateFileMetadataRequest{}}, h.UpdateFileMetadata)
+			files.POST("/:id/verify", openapi.Operation{Summary: "校验文件完整性", Tags: tags, Params: idParam}, h.VerifyFile)
+			files.POST("/batch-process", openapi.Operation{Summary: "批量处理文件，Accept: application/x-ndjson 时流式返回", Tags: tags,
+				Params: fieldsParam, Body: BatchProcessFilesRequest{}, Responses: limited},
+				batchLimit(), duplicateGuard(middleware.DuplicateWarn), h.acceptingBatches(), h.BatchProcessFiles)
+			files.POST("/validate", openapi.Operation{Summary: "预检批量文件处理，只校验不读取文件内容", Tags: tags,
+				Body: BatchProcessFilesRequest{}}, h.ValidateFiles)
 		}
 
-		// 健康检查
-		api.GET("/health", func(c *gin.Context) {
+		// 多阶段流水线
+		pipelines := api.Group("/pipelines")
+		{
+			tags := []string{"pipelines"}
+			pipelines.POST("/run", openapi.Operation{Summary: "执行多阶段流水线（如 fetch → split_lines → store）", Tags: tags,
+				Body: RunPipelineRequest{}, Responses: limited}, batchLimit(), h.acceptingBatches(), h.RunPipeline)
+		}
+
+		// 注册的任务类型，每种类型一个批量接口
+		kinds := api.Group("/tasks")
+		{
+			tags := []string{"tasks"}
+			kinds.GET("/kinds", openapi.Operation{Summary: "已注册的任务类型", Tags: tags}, h.ListTaskKinds)
+			kinds.GET("/error-codes", openapi.Operation{Summary: "任务错误码及其消息模板，未指定 lang 时按 Accept-Language 选择语言", Tags: tags,
+				Params: []openapi.Param{openapi.Query("lang", "语言，如 zh、en")}}, h.ListErrorCodes)
+			for _, name := range h.Tasks.Names() {
+				service, _ := h.Tasks.Get(name)
+				kinds.POST("/"+name+"/batch-process", openapi.Operation{Summary: "批量执行 " + name + " 任务，Accept: application/x-ndjson 时流式返回", Tags: tags,
+					Params: fieldsParam, Body: BatchProcessTasksRequest{}, Responses: limited},
+					batchLimit(), duplicateGuard(middleware.DuplicateWarn), h.acceptingBatches(), h.batchProcessTasks(service))
+			}
+		}
+
+		// 任务管理相关路由
+		jobs := api.Group("/jobs")
+		{
+			tags := []string{"jobs"}
+			jobs.GET("", openapi.Operation{Summary: "当前用户的运行中任务，带查询参数时分页查询任务记录", Tags: tags, Params: historyParams}, h.ListJobs)
+			jobs.GET("/stream", openapi.Operation{Summary: "WebSocket 增量提交任务", Tags: tags,
+				Responses: map[int]string{101: "切换到 WebSocket 协议", 429: "排队的任务过多", 503: "服务正在关闭或维护中"}}, h.acceptingBatches(), h.StreamBatch)
+			jobs.GET("/history", openapi.Operation{Summary: "任务历史", Tags: tags, Params: historyParams}, h.ListJobHistory)
+			jobs.GET("/:id", openapi.Operation{Summary: "任务状态", Tags: tags, Params: []openapi.Param{
+				openapi.Query("wait", "等待任务结束的最长时间，如 30s，最长 60s"),
+			}}, h.GetJob)
+			jobs.GET("/:id/history", openapi.Operation{Summary: "任务的状态变更记录（时间和触发者）", Tags: tags,
+				Responses: map[int]string{200: "成功", 404: "任务不存在"}}, h.GetJobTransitions)
+			jobs.GET("/:id/progress", openapi.Operation{Summary: "任务进度、吞吐量和预计剩余时间", Tags: tags}, h.GetJobProgress)
+			jobs.GET("/:id/inflight", openapi.Operation{Summary: "正在执行的子任务", Tags: tags}, h.GetJobInflight)
+			jobs.GET("/:id/debug", openapi.Operation{Summary: "失败任务的调试包列表", Tags: tags}, h.ListDebugBundles)
+			jobs.GET("/:id/debug/:task", openapi.Operation{Summary: "下载失败任务的调试包", Tags: tags,
+				Responses: map[int]string{200: "成功", 400: "任务序号不正确", 404: "调试包不存在"}}, h.DownloadDebugBundle)
+			jobs.GET("/:id/result", openapi.Operation{Summary: "任务结果，支持 ETag", Tags: tags, Params: fieldsParam,
+				Responses: map[int]string{200: "成功", 202: "任务仍在运行", 304: "未修改"}}, h.GetJobResult)
+			jobs.GET("/:id/results", openapi.Operation{Summary: "按快照分页查询任务结果，执行中的任务也可查询", Tags: tags, Params: append([]openapi.Param{
+				openapi.Query("cursor", "上一页返回的 next_cursor，为空时建立快照并返回第一页"),
+				openapi.QueryInt("page_size", "每页结果数，默认100", openapi.Float(1), openapi.Float(maxResultPageSize)),
+			}, fieldsParam...), Responses: map[int]string{200: "成功", 400: "参数或游标无效", 404: "任务不存在", 409: "批次不保留任务结果", 410: "游标已过期"}}, h.ListJobResults)
+			jobs.GET("/:id/results/:task/full", openapi.Operation{Summary: "下载超出大小限制被截断的任务结果的完整内容", Tags: tags,
+				Responses: map[int]string{200: "成功", 400: "任务序号无效", 404: "任务或完整结果不存在"}}, h.DownloadFullResult)
+			jobs.GET("/:id/report", openapi.Operation{Summary: "任务性能报告（HTML 或 JSON）", Tags: tags, Params: []openapi.Param{
+				openapi.QueryEnum("format", "报告格式，默认 html", "html", "json"),
+			}, Responses: map[int]string{200: "成功", 202: "任务仍在运行"}}, h.GetJobReport)
+			jobs.GET("/:id/events", openapi.Operation{Summary: "任务事件（SSE），支持 Last-Event-ID 续传", Tags: tags, Params: []openapi.Param{
+				openapi.QueryEnum("on_disconnect", "订阅者断开时的处理方式", disconnectBuffer, disconnectCancel),
+				openapi.Query("last_event_id", "续传起点，等同 Last-Event-ID 请求头"),
+			}}, h.StreamJobEvents)
+			jobs.DELETE("/:id", openapi.Operation{Summary: "取消任务", Tags: tags, Params: []openapi.Param{
+				openapi.QueryEnum("mode", "取消模式，默认 soft", string(services.CancelModeSoft), string(services.CancelModeHard)),
+			}}, h.CancelJob)
+			jobs.POST("/:id/chain", openapi.Operation{Summary: "以任务结果作为下游批次（orders、apis、files、pipeline）的输入", Tags: tags,
+				Body: ChainJobRequest{}, Responses: map[int]string{200: "成功", 400: "映射表达式错误", 404: "任务不存在或尚未结束",
+					409: "任务没有可映射的结果", 413: "请求体过大", 429: "同时处理的请求或排队的任务过多", 503: "服务正在关闭或维护中"}}, batchLimit(), h.acceptingBatches(), h.ChainJob)
+			jobs.GET("/:id/artifact", openapi.Operation{Summary: "以文件下载任务结果（JSON 或 CSV）", Tags: tags, Params: []openapi.Param{
+				openapi.QueryEnum("format", "文件格式，默认 json", "json", "csv"),
+			}, Responses: map[int]string{200: "成功", 202: "任务仍在运行", 304: "未修改", 404: "任务不存在"}}, h.DownloadJobArtifact)
+			jobs.POST("/:id/artifact/restore", openapi.Operation{Summary: "从冷存储恢复任务产出物", Tags: tags}, h.RestoreArtifact)
+		}
+
+		// 批次模板
+		templates := api.Group("/templates")
+		{
+			tags := []string{"templates"}
+			templates.GET("", openapi.Operation{Summary: "当前用户的批次模板", Tags: tags}, h.ListTemplates)
+			templates.POST("", openapi.Operation{Summary: "创建批次模板", Tags: tags, Body: TemplateRequest{},
+				Responses: map[int]string{201: "已创建", 400: "参数错误", 409: "模板名称已存在"}}, h.CreateTemplate)
+			templates.GET("/:id", openapi.Operation{Summary: "批次模板", Tags: tags, Params: idParam}, h.GetTemplate)
+			templates.PUT("/:id", openapi.Operation{Summary: "更新批次模板", Tags: tags, Params: idParam, Body: TemplateRequest{},
+				Responses: map[int]string{200: "成功", 400: "参数错误", 404: "模板不存在", 409: "模板名称已存在"}}, h.UpdateTemplate)
+			templates.DELETE("/:id", openapi.Operation{Summary: "删除批次模板", Tags: tags, Params: idParam}, h.DeleteTemplate)
+			templates.POST("/:id/run", openapi.Operation{Summary: "执行批次模板，请求体中的字段覆盖模板中的同名字段", Tags: tags, Params: idParam,
+				Responses: map[int]string{200: "成功", 404: "模板不存在", 413: "请求体过大", 429: "同时处理的请求或排队的任务过多", 503: "服务正在关闭或维护中"}},
+				batchLimit(), h.acceptingBatches(), h.RunTemplate)
+		}
+
+		// 任务产出物相关路由
+		artifacts := api.Group("/artifacts")
+		{
+			tags := []string{"artifacts"}
+			artifacts.GET("", openapi.Operation{Summary: "任务产出物列表", Tags: tags, Params: []openapi.Param{
+				openapi.QueryEnum("storage_class", "存储级别", services.StorageClassHot, services.StorageClassCold),
+			}}, h.ListArtifacts)
+			artifacts.POST("/archive", openapi.Operation{Summary: "立即归档产出物", Tags: tags, Params: []openapi.Param{
+				openapi.QueryInt("older_than_days", "归档超过指定天数的产出物", openapi.Float(0), nil),
+			}, Responses: map[int]string{200: "成功", 409: "其他实例正在归档"}}, h.ArchiveArtifacts)
+			artifacts.POST("/retention", openapi.Operation{Summary: "立即按批次模板的保留策略清理任务记录和明细", Tags: tags,
+				Responses: map[int]string{200: "成功", 409: "其他实例正在清理"}}, h.SweepRetention)
+		}
+
+		// 统计相关路由
+		stats := api.Group("/stats")
+		{
+			stats.GET("/orders", openapi.Operation{Summary: "订单处理统计时间序列", Tags: []string{"stats"}, Params: append([]openapi.Param{
+				openapi.QueryEnum("interval", "聚合粒度，默认 day", services.StatsIntervalHour, services.StatsIntervalDay),
+			}, dateRange...)}, h.GetOrderStats)
+		}
+
+		// 客户数据删除，处理数据删除请求，仅管理员可访问
+		customers := api.Group("/customers", RequireAdmin())
+		{
+			customers.DELETE("/:id/data", openapi.Operation{Summary: "删除或匿名化引用客户ID的订单、任务结果和访问日志，返回删除报告", Tags: []string{"customers"},
+				Params:    []openapi.Param{{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}},
+				Responses: map[int]string{200: "已删除", 400: "客户ID不正确", 500: "部分数据未能删除"}}, h.DeleteCustomerData)
+		}
+
+		// 管理后台数据接口，仅管理员可访问
+		admin := api.Group("/admin", RequireAdmin())
+		{
+			tags := []string{"admin"}
+			admin.GET("/tenants", openapi.Operation{Summary: "租户列表", Tags: tags}, h.ListTenants)
+			admin.POST("/tenants", openapi.Operation{Summary: "创建租户", Tags: tags, Body: TenantRequest{},
+				Responses: map[int]string{201: "已创建", 409: "租户名称已存在"}}, h.CreateTenant)
+			admin.GET("/tenants/:id", openapi.Operation{Summary: "获取租户", Tags: tags, Params: idParam}, h.GetTenant)
+			admin.PUT("/tenants/:id", openapi.Operation{Summary: "更新租户", Tags: tags, Params: idParam, Body: TenantRequest{}}, h.UpdateTenant)
+			admin.DELETE("/tenants/:id", openapi.Operation{Summary: "删除租户", Tags: tags, Params: idParam}, h.DeleteTenant)
+
+			admin.GET("/tenants/:id/keys", openapi.Operation{Summary: "租户的结果数据密钥版本", Tags: tags, Params: idParam,
+				Responses: map[int]string{200: "成功", 404: "租户不存在", 409: "未配置结果加密主密钥"}}, h.ListTenantKeys)
+			admin.POST("/tenants/:id/keys/rotate", openapi.Operation{Summary: "轮换租户的结果数据密钥", Tags: tags, Params: idParam,
+				Responses: map[int]string{201: "已生成新版本", 404: "租户不存在", 409: "未配置结果加密主密钥"}}, h.RotateTenantKey)
+
+			admin.GET("/api-keys", openapi.Operation{Summary: "API密钥列表", Tags: tags, Params: tenantFilter}, h.ListAPIKeys)
+			admin.POST("/api-keys", openapi.Operation{Summary: "生成API密钥", Tags: tags, Body: APIKeyRequest{},
+				Responses: map[int]string{201: "已创建，明文密钥只返回一次"}}, h.CreateAPIKey)
+			admin.PUT("/api-keys/:id", openapi.Operation{Summary: "更新API密钥", Tags: tags, Params: idParam, Body: APIKeyRequest{}}, h.UpdateAPIKey)
+			admin.DELETE("/api-keys/:id", openapi.Operation{Summary: "吊销API密钥", Tags: tags, Params: idParam}, h.RevokeAPIKey)
+
+			admin.GET("/quotas", openapi.Operation{Summary: "配额列表", Tags: tags, Params: tenantFilter}, h.ListQuotas)
+			admin.PUT("/quotas", openapi.Operation{Summary: "设置配额", Tags: tags, Body: QuotaRequest{}}, h.SetQuota)
+			admin.DELETE("/quotas/:id", openapi.Operation{Summary: "删除配额", Tags: tags, Params: idParam}, h.DeleteQuota)
+
+			admin.GET("/webhooks", openapi.Operation{Summary: "回调订阅列表", Tags: tags, Params: tenantFilter}, h.ListWebhooks)
+			admin.POST("/webhooks", openapi.Operation{Summary: "创建回调订阅", Tags: tags, Body: WebhookRequest{},
+				Responses: map[int]string{201: "已创建"}}, h.CreateWebhook)
+			admin.PUT("/webhooks/:id", openapi.Operation{Summary: "更新回调订阅", Tags: tags, Params: idParam, Body: WebhookRequest{}}, h.UpdateWebhook)
+			admin.DELETE("/webhooks/:id", openapi.Operation{Summary: "删除回调订阅", Tags: tags, Params: idParam}, h.DeleteWebhook)
+
+			admin.PUT("/jobs/:id/concurrency", openapi.Operation{Summary: "调整执行中任务的并发数", Tags: tags, Body: JobConcurrencyRequest{},
+				Responses: map[int]string{200: "成功", 404: "任务不存在", 409: "任务已结束或不支持调整并发数"}}, h.SetJobConcurrency)
+			admin.GET("/rate-limits", openapi.Operation{Summary: "各服务当前的限速", Tags: tags}, h.ListRateLimits)
+			admin.PUT("/rate-limits/:service", openapi.Operation{Summary: "调整服务的限速，记入执行中任务的配置调整", Tags: tags, Body: RateLimitRequest{},
+				Params:    []openapi.Param{{Name: "service", In: "path", Required: true, Description: "服务名：order、api（含流水线）、file 或注册的任务类型", Schema: &openapi.Schema{Type: "string"}}},
+				Responses: map[int]string{200: "成功", 404: "服务不存在", 409: "该服务未配置限速"}}, h.SetRateLimit)
+
+			admin.GET("/access-logs", openapi.Operation{Summary: "最近的访问日志（抽样）", Tags: tags, Params: []openapi.Param{
+				openapi.Query("route", "路由模板，如 /api/jobs/:id"),
+				openapi.Query("user", "用户"),
+				openapi.QueryInt("min_status", "最小状态码", openapi.Float(100), openapi.Float(599)),
+				openapi.Query("since", "开始时间，2006-01-02 或 RFC3339"),
+				openapi.QueryInt("limit", "条数，默认100", openapi.Float(1), openapi.Float(maxAccessLogLimit)),
+			}}, h.ListAccessLogs)
+			admin.GET("/job-groups", openapi.Operation{Summary: "互斥组中执行和排队的任务", Tags: tags}, h.ListJobGroups)
+			admin.GET("/job-queue", openapi.Operation{Summary: "全局队列中执行和排队的任务", Tags: tags}, h.GetJobQueue)
+			admin.GET("/pools", openapi.Operation{Summary: "命名工作池的使用情况和路由规则", Tags: tags}, h.GetWorkerPools)
+			admin.GET("/maintenance", openapi.Operation{Summary: "维护模式的状态和尚未结束的任务数", Tags: tags}, h.GetMaintenance)
+			admin.PUT("/maintenance", openapi.Operation{Summary: "开启或关闭只读维护模式", Tags: tags, Body: MaintenanceRequest{}}, h.SetMaintenance)
+			admin.GET("/metrics", openapi.Operation{Summary: "运行指标（expvar），含 http_panics_total、task_panics_total", Tags: tags}, gin.WrapH(expvar.Handler()))
+		}
+
+		// 获取 CSRF 令牌，前端也可以直接读取 csrf_token cookie
+		api.GET("/csrf-token", openapi.Operation{Summary: "获取 CSRF 令牌", Tags: []string{"system"}}, func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"csrf_token": middleware.CSRFToken(c)})
+		})
+
+		// 健康检查，任务记录的写入在排队重试时 status 为 degraded，批次仍可提交；维护模式下 maintenance 为 true，查询接口照常可用
+		api.GET("/health", openapi.Operation{Summary: "健康检查", Tags: []string{"system"}}, func(c *gin.Context) {
+			persistence := h.Jobs.Persist.Status()
+			status := "ok"
+			if persistence.Degraded {
+				status = "degraded"
+			}
 			c.JSON(http.StatusOK, gin.H{
-				"status":    "ok",
-				"timestamp": time.Now(),
-				"message":   "Concurrency Web App is running",
+				"status":      status,
+				"timestamp":   time.Now(),
+				"message":     "Concurrency Web App is running",
+				"persistence": persistence,
+				"maintenance": h.Maintenance.State().Enabled,
 			})
 		})
+
+		// 接口定义
+		api.GET("/openapi.json", openapi.Operation{Summary: "OpenAPI 文档", Tags: []string{"system"}}, spec.Handler())
 	}
+
+	// 监控面板的 WebSocket 连接，不属于 REST 接口，不列入接口定义
+	r.GET("/ws/jobs", h.accessLog(), h.Authenticate(), h.MonitorJobs)
 }
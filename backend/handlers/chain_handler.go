@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChainJobRequest 以已结束任务的结果作为下游批次的输入
+type ChainJobRequest struct {
+	Target  string                 `json:"target" binding:"required,oneof=orders apis files pipeline"` // 下游批次的类型
+	Status  string                 `json:"status"`                                                     // 只映射该状态的结果，默认 success，all 表示全部
+	Each    string                 `json:"each"`                                                       // 结果中列表字段的路径，列表中的每一项生成一个任务
+	Map     interface{}            `json:"map" binding:"required"`                                     // 下游任务的模板，字符串按 text/template 求值
+	Options map[string]interface{} `json:"options"`                                                    // 下游请求的其他字段，如 fail_fast、流水线的 stages
+}
+
+// chainTarget 下游批次对应的批量接口及其请求中任务列表的字段名
+type chainTarget struct {
+	field  string
+	handle func(h *BatchHandler, c *gin.Context)
+}
+
+var chainTargets = map[string]chainTarget{
+	"orders":   {"orders", (*BatchHandler).BatchProcessOrders},
+	"apis":     {"apis", (*BatchHandler).BatchCallAPIs},
+	"files":    {"files", (*BatchHandler).BatchProcessFiles},
+	"pipeline": {"items", (*BatchHandler).RunPipeline},
+}
+
+// ChainJob 以已结束任务保存的结果作为下游批次的输入：按映射生成任务列表后交给对应的批量接口执行，
+// 校验、任务登记、产出物保存与直接提交相同，响应同下游接口；下游批次的配置快照中记录上游任务ID（chained_from）。
+// 依次链接即可组成多阶段的工作流，如 批量下载 → 压缩下载结果 → 上报汇总
+func (h *BatchHandler) ChainJob(c *gin.Context) {
+	var req ChainJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	output, err := h.Artifacts.LoadJobOutput(c.Param("id"), requestUser(c))
+	switch {
+	case errors.Is(err, services.ErrJobOutputNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrJobOutputEmpty):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取任务产出物失败: " + err.Error()})
+		return
+	}
+
+	mapping := services.ChainMapping{Status: req.Status, Each: req.Each, Map: req.Map}
+	tasks, err := mapping.Tasks(output.Results)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(tasks) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "上游任务没有符合条件的结果"})
+		return
+	}
+
+	// 生成的请求体交给下游批量接口，由其完成参数绑定和执行
+	target := chainTargets[req.Target]
+	body := make(map[string]interface{}, len(req.Options)+2)
+	for k, v := range req.Options {
+		body[k] = v
+	}
+	body[target.field] = tasks
+	body["chained_from"] = output.Job.ID
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "生成下游请求失败: " + err.Error()})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+	c.Request.ContentLength = int64(len(data))
+	target.handle(h, c)
+}
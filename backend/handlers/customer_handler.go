@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// customerDataRoute 客户数据删除接口的路由模板，访问日志中只记录模板，不记录含客户ID的路径
+const customerDataRoute = "/api/customers/:id/data"
+
+// DeleteCustomerData 删除或匿名化引用客户ID的订单、任务结果和访问日志，返回删除报告
+// 部分数据处理失败时返回500和报告，重新提交即可重试
+func (h *BatchHandler) DeleteCustomerData(c *gin.Context) {
+	report, err := h.Customers.Delete(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, services.ErrInvalidCustomerID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除客户数据失败: " + err.Error()})
+		return
+	}
+	if len(report.Errors) > 0 {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "部分数据未能删除，请重试", "data": report})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "客户数据已删除", "data": report})
+}
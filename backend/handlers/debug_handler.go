@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListDebugBundles 列出任务已保存的失败任务调试包
+func (h *BatchHandler) ListDebugBundles(c *gin.Context) {
+	job, ok := h.userJob(c)
+	if !ok {
+		return
+	}
+
+	bundles, err := h.Jobs.Debug.List(job.ID())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "调试包列表获取成功",
+		"data":    bundles,
+	})
+}
+
+// DownloadDebugBundle 下载单个失败任务的调试包（JSON，已解密）
+func (h *BatchHandler) DownloadDebugBundle(c *gin.Context) {
+	job, ok := h.userJob(c)
+	if !ok {
+		return
+	}
+	taskID, err := strconv.Atoi(c.Param("task"))
+	if err != nil || taskID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "任务序号必须为非负整数"})
+		return
+	}
+
+	data, err := h.Jobs.Debug.Load(job.ID(), taskID)
+	if errors.Is(err, services.ErrDebugBundleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="debug_%s_task_%d.json"`, job.ID(), taskID))
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// taskResultFields 可通过 ?fields= 选择的任务结果字段，键为 JSON 字段名
+var taskResultFields = map[string]func(services.TaskResult) interface{}{
+	"id":           func(r services.TaskResult) interface{} { return r.ID },
+	"success":      func(r services.TaskResult) interface{} { return r.Success },
+	"status":       func(r services.TaskResult) interface{} { return r.Status },
+	"data":         func(r services.TaskResult) interface{} { return r.Data },
+	"error":        func(r services.TaskResult) interface{} { return r.Error },
+	"error_class":  func(r services.TaskResult) interface{} { return r.ErrorClass },
+	"error_code":   func(r services.TaskResult) interface{} { return r.ErrorCode },
+	"error_params": func(r services.TaskResult) interface{} { return r.ErrorParams },
+	"duration":     func(r services.TaskResult) interface{} { return r.Duration },
+	"attempts":     func(r services.TaskResult) interface{} { return r.Attempts },
+	"mismatches":   func(r services.TaskResult) interface{} { return r.Mismatches },
+	"env":          func(r services.TaskResult) interface{} { return r.Env },
+}
+
+// fieldSet 客户端选择的任务结果字段，为空时返回完整结果
+type fieldSet []string
+
+// parseFields 解析 ?fields=id,success,duration，包含未知字段时返回错误
+func parseFields(c *gin.Context) (fieldSet, error) {
+	raw := c.Query("fields")
+	if raw == "" {
+		return nil, nil
+	}
+	var fields fieldSet
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := taskResultFields[name]; !ok {
+			return nil, fmt.Errorf("未知的结果字段: %s", name)
+		}
+		seen[name] = true
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// resultFields 解析 ?fields=，不合法时返回 400
+func resultFields(c *gin.Context) (fieldSet, bool) {
+	fields, err := parseFields(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return fields, true
+}
+
+// task 只保留选择的字段，未选择字段时原样返回
+func (f fieldSet) task(r services.TaskResult) interface{} {
+	if len(f) == 0 {
+		return r
+	}
+	return f.project(r)
+}
+
+// project 按选择的字段生成结果对象
+func (f fieldSet) project(r services.TaskResult) map[string]interface{} {
+	out := make(map[string]interface{}, len(f))
+	for _, name := range f {
+		out[name] = taskResultFields[name](r)
+	}
+	return out
+}
+
+// sparseBatchResult 只含选择字段的批次结果，Results 覆盖内嵌结构中的同名字段
+type sparseBatchResult struct {
+	*services.BatchResult
+	Results []map[string]interface{} `json:"results"`
+}
+
+// batch 对批次中的每个任务结果只保留选择的字段，汇总计数不受影响
+func (f fieldSet) batch(r *services.BatchResult) interface{} {
+	if len(f) == 0 || r == nil {
+		return r
+	}
+	results := make([]map[string]interface{}, len(r.Results))
+	for i, result := range r.Results {
+		results[i] = f.project(result)
+	}
+	return sparseBatchResult{BatchResult: r, Results: results}
+}
+
+// sparseResultPage 含任务信息的分页结果，Results 覆盖内嵌结构中的同名字段
+type sparseResultPage struct {
+	Job services.JobInfo `json:"job"`
+	*services.ResultPage
+	Results interface{} `json:"results"`
+}
+
+// page 对分页结果中的每个任务结果只保留选择的字段
+func (f fieldSet) page(info services.JobInfo, p *services.ResultPage) sparseResultPage {
+	if len(f) == 0 {
+		return sparseResultPage{Job: info, ResultPage: p, Results: p.Results}
+	}
+	results := make([]map[string]interface{}, len(p.Results))
+	for i, result := range p.Results {
+		results[i] = f.project(result)
+	}
+	return sparseResultPage{Job: info, ResultPage: p, Results: results}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RegisterExistingUploads 登记上传目录中还没有文件信息的文件（按日期分区之前保存的文件），归属匿名用户，
+// 未登录的客户端仍能在文件列表中看到并按文件ID处理这些文件；返回新登记的文件数
+func (h *BatchHandler) RegisterExistingUploads() (int, error) {
+	return h.Files.RegisterExisting(h.FileService.UploadDir, anonymousUser)
+}
+
+// UpdateFileMetadataRequest 更新文件元数据请求
+type UpdateFileMetadataRequest struct {
+	Tags        []string `json:"tags"`
+	Description string   `json:"description"`
+}
+
+// UpdateFileMetadata 更新文件的标签和描述
+func (h *BatchHandler) UpdateFileMetadata(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件ID无效"})
+		return
+	}
+
+	var req UpdateFileMetadataRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	file, err := h.Files.UpdateMetadata(uint(id), requestUser(c), req.Tags, req.Description)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新文件信息失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "文件信息更新成功",
+		"data":    file,
+	})
+}
+
+// VerifyFile 重新计算文件校验和并与上传时记录的值比较
+func (h *BatchHandler) VerifyFile(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "文件ID无效"})
+		return
+	}
+
+	file, err := h.Files.Get(uint(id), requestUser(c))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件信息失败: " + err.Error()})
+		return
+	}
+
+	startTime := time.Now()
+	result, err := services.VerifyChecksum(c.Request.Context(), file.FilePath, file.Checksum, file.ChunkHashes, h.FileService.ChecksumWorker)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "校验文件失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "文件校验完成",
+		"data": gin.H{
+			"file_id":   file.ID,
+			"file_path": file.FilePath,
+			"result":    result,
+			"duration":  time.Since(startTime).Milliseconds(),
+		},
+	})
+}
+
+// SearchFiles 按标签、文件名、大小范围和上传日期搜索文件
+func (h *BatchHandler) SearchFiles(c *gin.Context) {
+	query := services.FileSearchQuery{
+		Owner: requestUser(c),
+		Tag:   c.Query("tag"),
+		Name:  c.Query("name"),
+	}
+
+	var err error
+	if query.MinSize, err = parseInt64Query(c, "min_size"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_size 参数错误: " + err.Error()})
+		return
+	}
+	if query.MaxSize, err = parseInt64Query(c, "max_size"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_size 参数错误: " + err.Error()})
+		return
+	}
+	if query.From, err = parseDateQuery(c, "from", false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from 参数错误: " + err.Error()})
+		return
+	}
+	if query.To, err = parseDateQuery(c, "to", true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to 参数错误: " + err.Error()})
+		return
+	}
+
+	files, err := h.Files.Search(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索文件失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "文件搜索成功",
+		"data":    files,
+	})
+}
+
+// GetStorageUsage 查看存储用量：总量、按用户统计以及所在卷的可用空间
+func (h *BatchHandler) GetStorageUsage(c *gin.Context) {
+	if err := os.MkdirAll(h.FileService.UploadDir, 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传目录失败: " + err.Error()})
+		return
+	}
+
+	owners, err := h.Files.UsageByOwner()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计存储用量失败: " + err.Error()})
+		return
+	}
+
+	volume, err := h.FileService.Volume()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取磁盘容量失败: " + err.Error()})
+		return
+	}
+
+	var totalFiles, totalBytes int64
+	for _, owner := range owners {
+		totalFiles += owner.Files
+		totalBytes += owner.Bytes
+	}
+
+	data := gin.H{
+		"total_files":     totalFiles,
+		"total_bytes":     totalBytes,
+		"users":           owners,
+		"volume":          volume,
+		"min_free_bytes":  h.FileService.MinFreeBytes,
+		"warn_free_bytes": h.FileService.WarnFreeBytes,
+	}
+	if warning, err := h.FileService.CheckCapacity(0); err != nil {
+		data["warning"] = err.Error()
+	} else if warning != "" {
+		data["warning"] = warning
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "存储用量获取成功",
+		"data":    data,
+	})
+}
+
+// parseInt64Query 解析整数查询参数，缺省时返回0
+func parseInt64Query(c *gin.Context, key string) (int64, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// parseDateQuery 解析日期查询参数，支持 2006-01-02 和 RFC3339 格式
+// endOfDay 为 true 时只有日期的参数取当天结束，用于包含整天的区间上限
+func parseDateQuery(c *gin.Context, key string, endOfDay bool) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,690 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/openapi"
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// acceptingBatches 服务正在关闭时拒绝新批次，返回 503 和 Retry-After，客户端稍后重试会由其他实例或重启后的服务处理；
+// 维护模式下同样返回 503，提示和 Retry-After 由管理员开启维护模式时指定；
+// 排队的任务达到上限时返回 429 和 Retry-After，不再无限制地接收批次；请求体中 priority 为 high 的批次可以抢占排队的 low 任务
+func (h *BatchHandler) acceptingBatches() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if draining(h.Jobs) {
+			c.Header("Retry-After", "30")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "服务正在关闭，不再接收新批次"})
+			return
+		}
+		if maintenance := h.Maintenance.State(); maintenance.Enabled {
+			c.Header("Retry-After", strconv.Itoa(maintenance.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": maintenance.Message, "maintenance": true})
+			return
+		}
+		admission, err := h.Jobs.Admit("")
+		if errors.Is(err, services.ErrQueueFull) {
+			admission, err = h.Jobs.Admit(submittedPriority(c))
+		}
+		if err != nil {
+			c.Header("Retry-After", "10")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		// 预留的名额由 startJob 交给任务；请求被拒绝、没有登记任务时在这里释放
+		c.Set(admissionKey, admission)
+		c.Next()
+		admission.Release()
+	}
+}
+
+// admissionKey 请求上下文中 acceptingBatches 预留的排队名额
+const admissionKey = "admission"
+
+// startJob 登记请求提交的批次，acceptingBatches 预留的排队名额交给任务，在任务排队、开始执行或结束时释放
+func (h *BatchHandler) startJob(c *gin.Context, jobType string, totalTasks int) (*services.Job, context.Context) {
+	job, ctx := h.Jobs.Start(context.Background(), jobType, requestUser(c), totalTasks)
+	h.Jobs.Claim(job, requestAdmission(c))
+	return job, ctx
+}
+
+// requestAdmission 返回 acceptingBatches 为请求预留的排队名额，未预留时返回nil
+func requestAdmission(c *gin.Context) *services.Admission {
+	value, _ := c.Get(admissionKey)
+	admission, _ := value.(*services.Admission)
+	return admission
+}
+
+// submittedPriority 读取请求体中的 priority 字段，请求体原样放回供处理函数绑定；只在排队已满时读取
+// 读取失败（如超出 http.MaxBytesReader 的上限）时之后的读取返回同样的错误，由接口定义校验处理
+func submittedPriority(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	original := c.Request.Body
+	body, err := io.ReadAll(original)
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), original), original}
+	if err != nil {
+		return ""
+	}
+	var req struct {
+		Priority string `json:"priority"`
+	}
+	json.Unmarshal(body, &req)
+	return req.Priority
+}
+
+// checkDependsOn 检查 depends_on 指定的上游任务是否为当前用户的任务，不存在时返回 400
+// 上游任务须仍在内存中（服务重启前提交），已结束的上游任务按其结果立即判定
+func (h *BatchHandler) checkDependsOn(c *gin.Context, upstreamID string) bool {
+	if upstreamID == "" {
+		return true
+	}
+	if upstream, ok := h.Jobs.Get(upstreamID); ok && upstream.Info().Owner == requestUser(c) {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "depends_on 指定的上游任务不存在: " + upstreamID})
+	return false
+}
+
+// WaitBackground 等待 WebSocket 批次和异步批次汇总并保存结果，ctx 结束时返回其错误
+// 这些批次不随 HTTP 请求结束，服务关闭时 http.Server.Shutdown 不会等待它们
+func (h *BatchHandler) WaitBackground(ctx context.Context) error {
+	return waitGroup(ctx, &h.background)
+}
+
+// degradedWarning 数据库不可用时批次响应中的提示
+const degradedWarning = "数据库暂时不可用，批次只在内存中执行，任务记录将在数据库恢复后补写，服务重启前请保存结果"
+
+// markDegraded 任务登记时数据库不可用的，设置 X-Persistence-Degraded 响应头
+func markDegraded(c *gin.Context, job *services.Job) {
+	if job.Info().Degraded {
+		c.Header("X-Persistence-Degraded", "true")
+	}
+}
+
+// startAsync 在后台执行批次，立即返回 202 和任务ID，批次结束后保存结果和产出物；
+// 互斥组的排队也在后台进行，排队时间不计入批次超时
+// 登记任务时数据库不可用的改为同步执行，随响应返回结果和降级提示：任务记录尚未保存，服务重启后无法再获取结果
+func (h *BatchHandler) startAsync(c *gin.Context, ctx context.Context, job *services.Job, group string, timeout time.Duration, execute func(context.Context) *services.BatchResult) {
+	if job.Info().Degraded {
+		ctx, cancel := h.batchContext(ctx, job, group, timeout)
+		defer cancel()
+		result := execute(ctx)
+		h.finishJob(job, result)
+		c.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"message":  "批次已同步执行完成",
+			"job_id":   job.ID(),
+			"degraded": true,
+			"warning":  degradedWarning,
+			"data":     result,
+		})
+		return
+	}
+
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		ctx, cancel := h.batchContext(ctx, job, group, timeout)
+		defer cancel()
+		h.finishJob(job, execute(ctx))
+	}()
+
+	statusURL := "/api/jobs/" + job.ID()
+	c.Header("Location", statusURL)
+	c.JSON(http.StatusAccepted, gin.H{
+		"success":      true,
+		"message":      "批次已提交，正在后台执行",
+		"job_id":       job.ID(),
+		"status_url":   statusURL,
+		"result_url":   statusURL + "/result",
+		"results_url":  statusURL + "/results",
+		"artifact_url": statusURL + "/artifact",
+	})
+}
+
+// waitGroup 等待 wg 归零，ctx 结束时返回其错误
+func waitGroup(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// finishJob 记录任务结果并保存产出物
+func (h *BatchHandler) finishJob(job *services.Job, result *services.BatchResult) {
+	h.Jobs.Finish(job, result)
+
+	if _, err := h.Artifacts.SaveJobResult(job.Info(), result); err != nil {
+		log.Printf("保存任务 %s 的产出物失败: %v", job.ID(), err)
+	}
+}
+
+// userJob 按路径参数 id 查找当前用户的任务，不存在或属于其他用户时返回 404
+func (h *BatchHandler) userJob(c *gin.Context) (*services.Job, bool) {
+	job, ok := h.Jobs.Get(c.Param("id"))
+	if !ok || job.Info().Owner != requestUser(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+		return nil, false
+	}
+	return job, true
+}
+
+// ListJobs 列出当前用户的批量任务
+// 带任务记录的查询参数（historyParams 中的 type、status、page 等）时改为分页查询持久化的任务记录，同 ListJobHistory；
+// 其他查询参数（如防缓存的 _=123）不影响返回的数据
+func (h *BatchHandler) ListJobs(c *gin.Context) {
+	for _, param := range historyParams {
+		if _, ok := c.GetQuery(param.Name); ok {
+			h.ListJobHistory(c)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "任务列表获取成功",
+		"data":    h.Jobs.List(requestUser(c)),
+	})
+}
+
+// ListJobHistory 分页查询当前用户持久化的任务记录（包括服务重启前的任务）
+// 支持参数：type、status（failed 表示有失败任务的已结束任务）、from、to（开始时间范围，RFC 3339 或日期）、
+// sort（start_time、duration、failed_tasks）、order（asc、desc，默认desc）、page（默认1）、page_size（默认20，最大200）
+func (h *BatchHandler) ListJobHistory(c *gin.Context) {
+	query, ok := historyQuery(c)
+	if !ok {
+		return
+	}
+	query.Owner = requestUser(c)
+
+	jobs, total, err := h.JobHistory.History(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询任务记录失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"message":   "任务记录获取成功",
+		"data":      jobs,
+		"total":     total,
+		"page":      query.Page,
+		"page_size": query.PageSize,
+	})
+}
+
+// historyStatuses 任务记录可按其筛选的状态，参数校验、错误信息和接口文档共用
+var historyStatuses = []string{
+	services.JobStatusQueued,
+	services.JobStatusRunning,
+	services.JobStatusCompleted,
+	services.JobStatusCancelled,
+	services.JobStatusSkipped,
+	services.JobStatusInterrupted,
+	services.HistoryStatusFailed,
+}
+
+// validHistoryStatus 判断 status 是否为任务记录可筛选的状态
+func validHistoryStatus(status string) bool {
+	for _, s := range historyStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// historyParams 任务记录的查询参数
+var historyParams = []openapi.Param{
+	openapi.Query("type", "任务类型，如 order、api、file、pipeline"),
+	openapi.QueryEnum("status", "任务状态，failed 表示有失败任务的已结束任务", historyStatuses...),
+	openapi.Query("from", "开始时间下限（含），RFC 3339 或日期，如 2024-01-02"),
+	openapi.Query("to", "开始时间上限（不含），RFC 3339 或日期"),
+	openapi.QueryEnum("sort", "排序字段，默认 start_time", services.HistorySortStartTime, services.HistorySortDuration, services.HistorySortFailed),
+	openapi.QueryEnum("order", "排序方向，默认 desc", "asc", "desc"),
+	openapi.QueryInt("page", "页码，默认1", openapi.Float(1), nil),
+	openapi.QueryInt("page_size", "每页条数，默认20", openapi.Float(1), openapi.Float(200)),
+}
+
+// historyQuery 解析任务记录的查询参数，参数不正确时返回 400
+func historyQuery(c *gin.Context) (services.HistoryQuery, bool) {
+	query := services.HistoryQuery{Type: c.Query("type"), Status: c.Query("status")}
+	fail := func(message string) (services.HistoryQuery, bool) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return query, false
+	}
+
+	var err error
+	if query.Page, err = strconv.Atoi(c.DefaultQuery("page", "1")); err != nil || query.Page < 1 {
+		return fail("page 参数必须为正整数")
+	}
+	if query.PageSize, err = strconv.Atoi(c.DefaultQuery("page_size", "20")); err != nil || query.PageSize < 1 || query.PageSize > 200 {
+		return fail("page_size 参数必须在 1-200 之间")
+	}
+	if query.Status != "" && !validHistoryStatus(query.Status) {
+		return fail("status 参数只支持 " + strings.Join(historyStatuses, "、"))
+	}
+	switch sort := c.DefaultQuery("sort", services.HistorySortStartTime); sort {
+	case services.HistorySortStartTime, services.HistorySortDuration, services.HistorySortFailed:
+		query.Sort = sort
+	default:
+		return fail("sort 参数只支持 start_time、duration 或 failed_tasks")
+	}
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		query.Asc = true
+	case "desc":
+	default:
+		return fail("order 参数只支持 asc 或 desc")
+	}
+	for _, bound := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &query.From}, {"to", &query.To}} {
+		value := c.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseHistoryTime(value)
+		if err != nil {
+			return fail(bound.name + " 参数格式错误，例如 2024-01-02 或 2024-01-02T15:04:05Z")
+		}
+		*bound.dst = &t
+	}
+	return query, true
+}
+
+// parseHistoryTime 解析 RFC 3339 时间或按服务器时区解析日期
+func parseHistoryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+// GetJob 获取任务信息
+// 指定 wait（如 wait=30s）时保持请求直到任务结束或等待超时，超过 MaxJobWait（默认 60s）按 MaxJobWait 处理
+func (h *BatchHandler) GetJob(c *gin.Context) {
+	job, ok := h.Jobs.Get(c.Param("id"))
+	if !ok {
+		h.getSavedJob(c)
+		return
+	}
+	if job.Info().Owner != requestUser(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+		return
+	}
+
+	if value := c.Query("wait"); value != "" {
+		wait, err := time.ParseDuration(value)
+		if err != nil || wait < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "wait 参数格式错误，例如 30s"})
+			return
+		}
+		if wait > h.MaxJobWait {
+			wait = h.MaxJobWait
+		}
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-job.Done():
+		case <-timer.C:
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+
+	info := job.Info()
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "任务信息获取成功",
+		"finished": info.EndTime != nil,
+		"data":     info,
+	})
+}
+
+// getSavedJob 内存中没有的任务（服务重启前结束或中断的任务）返回任务记录，状态为 interrupted 的任务在服务意外退出时未能恢复执行
+func (h *BatchHandler) getSavedJob(c *gin.Context) {
+	record, err := h.JobHistory.Get(c.Param("id"), requestUser(c))
+	if errors.Is(err, services.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询任务记录失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "任务记录获取成功",
+		"finished": record.EndTime != nil,
+		"data":     record,
+	})
+}
+
+// GetJobTransitions 获取任务的状态变更记录：每次变更的前后状态、时间、触发者（用户或 system）和原因，按发生的顺序。
+// 内存中没有的任务（服务重启前的任务）从数据库读取，没有配置任务记录时返回 404，没有配置状态变更存储时返回空列表
+func (h *BatchHandler) GetJobTransitions(c *gin.Context) {
+	var info interface{}
+	var transitions []models.JobTransition
+	if job, ok := h.Jobs.Get(c.Param("id")); ok {
+		if job.Info().Owner != requestUser(c) {
+			c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+			return
+		}
+		info, transitions = job.Info(), job.Transitions()
+	} else if h.JobHistory == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+		return
+	} else {
+		record, err := h.JobHistory.Get(c.Param("id"), requestUser(c))
+		if errors.Is(err, services.ErrJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询任务记录失败: " + err.Error()})
+			return
+		}
+		if transitions, err = h.Jobs.Transitions.Load(record.JobID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询状态变更失败: " + err.Error()})
+			return
+		}
+		info = record
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "状态变更获取成功",
+		"data": gin.H{
+			"job":         info,
+			"transitions": transitions,
+		},
+	})
+}
+
+// GetJobProgress 获取任务的实时进度（完成数、当前吞吐量和预计剩余时间）
+func (h *BatchHandler) GetJobProgress(c *gin.Context) {
+	job, ok := h.userJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "任务进度获取成功",
+		"data":    job.Progress(),
+	})
+}
+
+// GetJobResult 获取已结束任务的结果
+// 结束的任务结果不会再变化，通过 ETag 让轮询的客户端在结果未变时收到 304；
+// 内存中没有的任务（如服务重启前结束的任务）从保存的产出物读取
+func (h *BatchHandler) GetJobResult(c *gin.Context) {
+	fields, ok := resultFields(c)
+	if !ok {
+		return
+	}
+	job, ok := h.Jobs.Get(c.Param("id"))
+	if !ok {
+		h.getSavedJobResult(c, fields)
+		return
+	}
+	if job.Info().Owner != requestUser(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+		return
+	}
+
+	// 先取结果再取信息，结果存在时信息中一定已有结束时间
+	result := job.Result()
+	info := job.Info()
+	if result == nil {
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"message": "任务尚未结束",
+			"data":    gin.H{"job": info},
+		})
+		return
+	}
+
+	etag := fmt.Sprintf(`"%s-%d"`, info.ID, info.EndTime.UnixNano())
+	if notModified(c, etag) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "任务结果获取成功",
+		"data": gin.H{
+			"job":    info,
+			"result": fields.batch(result),
+		},
+	})
+}
+
+// getSavedJobResult 从任务产出物读取已结束任务的结果
+func (h *BatchHandler) getSavedJobResult(c *gin.Context, fields fieldSet) {
+	info, result, err := h.Artifacts.LoadJobResult(c.Param("id"), requestUser(c))
+	if errors.Is(err, services.ErrJobOutputNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if info.EndTime != nil && notModified(c, fmt.Sprintf(`"%s-%d"`, info.ID, info.EndTime.UnixNano())) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "任务结果获取成功",
+		"data": gin.H{
+			"job":    info,
+			"result": fields.batch(result),
+		},
+	})
+}
+
+// maxResultPageSize 分页查询任务结果时每页的最大结果数
+const maxResultPageSize = 1000
+
+// ListJobResults 按快照分页查询任务结果，执行中的任务也可查询已结束的任务
+// 不带 cursor 时以当前已结束的任务建立快照并返回第一页，之后携带 next_cursor 只在同一快照中按任务序号翻页，
+// 新结束的任务不会让页发生偏移；has_newer 为 true 时翻完后不带 cursor 重新查询可取得快照之后的结果。
+// 内存中没有的任务从保存的产出物读取最终结果
+func (h *BatchHandler) ListJobResults(c *gin.Context) {
+	fields, ok := resultFields(c)
+	if !ok {
+		return
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("page_size", "100"))
+	if err != nil || size < 1 || size > maxResultPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("page_size 参数必须在 1-%d 之间", maxResultPageSize)})
+		return
+	}
+	cursor := c.Query("cursor")
+
+	var info services.JobInfo
+	var page *services.ResultPage
+	if job, ok := h.Jobs.Get(c.Param("id")); ok {
+		if job.Info().Owner != requestUser(c) {
+			c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+			return
+		}
+		page, err = job.ResultPage(cursor, size)
+		info = job.Info()
+	} else {
+		saved, result, loadErr := h.Artifacts.LoadJobResult(c.Param("id"), requestUser(c))
+		if errors.Is(loadErr, services.ErrJobOutputNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+			return
+		}
+		if loadErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": loadErr.Error()})
+			return
+		}
+		info = *saved
+		page, err = services.FinalResultPage(info, result, cursor, size)
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidCursor):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrCursorExpired):
+		c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrResultsNotRetained):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "任务结果获取成功",
+		"data":    fields.page(info, page),
+	})
+}
+
+// DownloadFullResult 下载超出大小限制被截断的任务结果的完整内容（JSON，已解密），即截断结果中 full_result_url 指向的地址。
+// 内存中没有的任务按任务记录确认归属；完整结果按保留策略与产出物一同删除
+func (h *BatchHandler) DownloadFullResult(c *gin.Context) {
+	jobID := c.Param("id")
+	if job, ok := h.Jobs.Get(jobID); ok {
+		if job.Info().Owner != requestUser(c) {
+			c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+			return
+		}
+	} else if _, err := h.JobHistory.Get(jobID, requestUser(c)); err != nil {
+		if errors.Is(err, services.ErrJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询任务记录失败: " + err.Error()})
+		return
+	}
+	taskID, err := strconv.Atoi(c.Param("task"))
+	if err != nil || taskID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "任务序号必须为非负整数"})
+		return
+	}
+
+	data, err := h.FullResults.Load(jobID, taskID)
+	if errors.Is(err, services.ErrFullResultNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="result_%s_task_%d.json"`, jobID, taskID))
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// GetJobInflight 列出任务中正在执行的子任务及其占用的工作槽位
+func (h *BatchHandler) GetJobInflight(c *gin.Context) {
+	job, ok := h.userJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "执行中任务获取成功",
+		"data": gin.H{
+			"job":      job.Info(),
+			"inflight": job.Inflight(),
+		},
+	})
+}
+
+// CancelJob 取消批量任务
+// mode=soft（默认）停止派发新任务并等待执行中的任务完成；mode=hard 立即取消所有任务
+func (h *BatchHandler) CancelJob(c *gin.Context) {
+	mode := services.CancelMode(c.DefaultQuery("mode", string(services.CancelModeSoft)))
+
+	if _, ok := h.userJob(c); !ok {
+		return
+	}
+
+	info, err := h.Jobs.Cancel(c.Param("id"), mode, requestUser(c))
+	switch {
+	case errors.Is(err, services.ErrInvalidCancelMode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error() + ": " + string(mode)})
+		return
+	case errors.Is(err, services.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrJobFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "data": info})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "任务取消请求已受理",
+		"data":    info,
+	})
+}
+
+// JobConcurrencyRequest 调整任务并发数请求
+type JobConcurrencyRequest struct {
+	MaxConcurrency int `json:"max_concurrency" binding:"required,min=1,max=1000"`
+}
+
+// SetJobConcurrency 调整执行中的任务的并发数（管理员），下游开始限流时调低、恢复后调高
+// 调高立即生效，调低时正在执行的子任务不受影响；errgroup 调度方式、WebSocket 增量批次和流水线不支持调整
+func (h *BatchHandler) SetJobConcurrency(c *gin.Context) {
+	var req JobConcurrencyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	info, err := h.Jobs.SetConcurrency(c.Param("id"), req.MaxConcurrency, requestUser(c))
+	switch {
+	case errors.Is(err, services.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrJobFinished), errors.Is(err, services.ErrConcurrencyNotAdjustable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "data": info})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "并发数已调整",
+		"data":    info,
+	})
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxResumes 同一批次最多恢复执行的次数，超出时标记为 interrupted，避免导致服务退出的批次反复恢复
+const maxResumes = 3
+
+// checkpointRequest 恢复异步批次所需的请求信息，随检查点保存
+type checkpointRequest struct {
+	Options    BatchOptions        `json:"options"`
+	Run        *services.RunRecord `json:"run,omitempty"`
+	TenantID   uint                `json:"tenant_id,omitempty"`
+	TemplateID uint                `json:"template_id,omitempty"`
+	Retention  time.Duration       `json:"retention,omitempty"`
+}
+
+// saveCheckpoint 保存异步批次的任务清单，服务意外退出后从剩余的任务恢复执行，tasks 为按序号排列的执行的任务；
+// 同步批次（客户端随连接断开）、抽样执行和指定了上游任务的批次不保存，服务意外退出后标记为 interrupted
+func (h *BatchHandler) saveCheckpoint(c *gin.Context, job *services.Job, tasks interface{}, o BatchOptions) {
+	if !o.Async || (o.SampleRate > 0 && o.SampleRate < 1) || o.DependsOn != "" {
+		return
+	}
+	info := job.Info()
+	req := checkpointRequest{
+		Options:    o,
+		Run:        info.Run,
+		TenantID:   info.TenantID,
+		TemplateID: c.GetUint(templateRunKey),
+		Retention:  c.GetDuration(templateRetentionKey),
+	}
+	// 保存失败时批次照常执行，只是服务意外退出后不能恢复
+	if err := h.Jobs.SaveCheckpoint(job, tasks, req); err != nil {
+		log.Printf("保存任务 %s 的检查点失败: %v", job.ID(), err)
+	}
+}
+
+// RecoverJobs 检查服务上次退出时本实例未结束的任务：保存了检查点的异步批次在后台从剩余的任务恢复执行，
+// 其余（同步批次、WebSocket 增量批次、流水线等）标记为 interrupted。应在注册任务类型之后、开始接收请求之前调用
+func (h *BatchHandler) RecoverJobs() (resumed, interrupted int, err error) {
+	if err := h.Jobs.Checkpoints.DeleteOrphaned(); err != nil {
+		return 0, 0, fmt.Errorf("清理残留的检查点失败: %w", err)
+	}
+	records, err := h.Jobs.Checkpoints.Unfinished()
+	if err != nil {
+		return 0, 0, fmt.Errorf("查询未结束的任务失败: %w", err)
+	}
+
+	for _, record := range records {
+		if err := h.resumeJob(record); err != nil {
+			log.Printf("任务 %s 未能恢复执行，标记为 %s: %v", record.JobID, services.JobStatusInterrupted, err)
+			if err := h.Jobs.Checkpoints.MarkInterrupted(record.JobID, "服务意外退出后未能恢复执行: "+err.Error()); err != nil {
+				return resumed, interrupted, fmt.Errorf("标记任务 %s 中断失败: %w", record.JobID, err)
+			}
+			interrupted++
+			continue
+		}
+		resumed++
+	}
+	return resumed, interrupted, nil
+}
+
+// resumeJob 按检查点重新登记任务，在后台执行剩余的任务，结束后与检查点中已结束的任务合并为整个批次的结果
+func (h *BatchHandler) resumeJob(record models.BatchJobResult) error {
+	if record.Resumes >= maxResumes {
+		return fmt.Errorf("已恢复执行 %d 次仍未结束", record.Resumes)
+	}
+	cp, err := h.Jobs.Checkpoints.Load(record)
+	if err != nil {
+		return err
+	}
+	var req checkpointRequest
+	if err := json.Unmarshal(cp.Request, &req); err != nil {
+		return fmt.Errorf("检查点格式错误: %w", err)
+	}
+	o := req.Options
+	expect, err := services.NewExpectations(o.Expected, len(cp.Tasks))
+	if err != nil {
+		return fmt.Errorf("预期输出错误: %w", err)
+	}
+
+	// 先解析任务，解析失败时不登记任务；执行函数在登记后才会调用
+	var job *services.Job
+	var process func(context.Context) *services.BatchResult
+	var timeout time.Duration
+	remaining := cp.Remaining()
+	switch record.JobType {
+	case "order":
+		orders, err := decodeTasks[services.OrderTask](cp.Tasks)
+		if err != nil {
+			return err
+		}
+		subset := pickTasks(orders, remaining)
+		timeout = o.timeout(h.OrderService.Timeout)
+		process = func(ctx context.Context) *services.BatchResult {
+			result := cp.Merge(ctx, h.OrderService.BatchProcessOrders(ctx, subset))
+			h.recordOrderRollup(job, orders, result)
+			return result
+		}
+	case "api":
+		tasks, err := decodeTasks[services.APICallTask](cp.Tasks)
+		if err != nil {
+			return err
+		}
+		subset := pickTasks(tasks, remaining)
+		timeout = o.timeout(h.APIService.Timeouts.Batch)
+		process = func(ctx context.Context) *services.BatchResult {
+			result := cp.Merge(ctx, h.APIService.BatchCallAPIs(ctx, subset))
+			h.recordAPICalls(job, tasks, result)
+			return result
+		}
+	case "file":
+		tasks, err := decodeTasks[services.FileTask](pickTasks(cp.Tasks, remaining))
+		if err != nil {
+			return err
+		}
+		timeout = o.timeout(h.FileService.Timeout)
+		process = func(ctx context.Context) *services.BatchResult {
+			return cp.Merge(ctx, h.FileService.BatchProcessFiles(ctx, tasks))
+		}
+	default:
+		service, err := h.Tasks.Get(record.JobType)
+		if err != nil {
+			return fmt.Errorf("不支持恢复的任务类型: %s", record.JobType)
+		}
+		tasks, err := service.Decode(pickTasks(cp.Tasks, remaining))
+		if err != nil {
+			return fmt.Errorf("任务格式错误: %w", err)
+		}
+		timeout = o.timeout(service.Timeout)
+		process = func(ctx context.Context) *services.BatchResult {
+			return cp.Merge(ctx, service.BatchProcess(ctx, tasks))
+		}
+	}
+
+	job, ctx, err := h.Jobs.Resume(context.Background(), cp)
+	if err != nil {
+		return err
+	}
+	job.SetTenant(req.TenantID)
+	job.SetRun(req.Run)
+	job.SetChunks(o.chunks())
+	job.SetLimits(o.limits())
+	job.SetResultOrder(o.resultOrder())
+	job.SetPriority(o.Priority)
+	job.SetTemplate(req.TemplateID, req.Retention)
+	if o.FailFast {
+		job.EnableFailFast()
+	}
+	if o.DebugCapture {
+		job.EnableDebugCapture()
+	}
+	if o.WorkerUsage {
+		job.EnableWorkerUsage()
+	}
+	log.Printf("任务 %s 从检查点恢复执行：已结束 %d 个任务，剩余 %d 个", job.ID(), len(cp.Done), len(remaining))
+
+	// 恢复的批次重新进入互斥组和全局队列排队，批次超时从重新开始执行时计算
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		ctx, cancel := h.batchContext(ctx, job, o.Group, timeout)
+		defer cancel()
+		h.finishJob(job, expect.Apply(process(ctx)))
+	}()
+	return nil
+}
+
+// decodeTasks 解析检查点中的任务
+func decodeTasks[T any](raws []json.RawMessage) ([]T, error) {
+	tasks := make([]T, len(raws))
+	for i, raw := range raws {
+		if err := json.Unmarshal(raw, &tasks[i]); err != nil {
+			return nil, fmt.Errorf("任务格式错误: %w", err)
+		}
+	}
+	return tasks, nil
+}
+
+// pickTasks 按序号取出任务
+func pickTasks[T any](tasks []T, indices []int) []T {
+	picked := make([]T, len(indices))
+	for i, index := range indices {
+		picked[i] = tasks[index]
+	}
+	return picked
+}
+
+// hasOrderDeps 批次中是否有订单声明了依赖；依赖按批次中的序号指定，只执行剩余的订单时无法还原，不保存检查点
+func hasOrderDeps(orders []services.OrderTask) bool {
+	for _, order := range orders {
+		if len(order.DependsOn) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"strings"
+	"sync"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// 监控连接的消息类型
+const (
+	monitorMsgSubscribe   = "subscribe"   // 客户端：订阅指定任务，job_ids 为空时订阅全部任务
+	monitorMsgUnsubscribe = "unsubscribe" // 客户端：取消订阅指定任务，job_ids 为空时暂停接收
+	monitorMsgSubscribed  = "subscribed"  // 服务端：当前的订阅范围
+	monitorMsgDropped     = "dropped"     // 服务端：接收过慢，有事件被丢弃
+	monitorMsgError       = "error"       // 服务端：消息处理失败
+)
+
+// monitorMessage 监控连接的控制消息，生命周期事件直接以 services.LifecycleEvent 发送
+type monitorMessage struct {
+	Type    string   `json:"type"`
+	JobIDs  []string `json:"job_ids,omitempty"`
+	All     bool     `json:"all,omitempty"`     // 订阅全部任务
+	Dropped int64    `json:"dropped,omitempty"` // 丢弃的事件数
+	Error   string   `json:"error,omitempty"`
+}
+
+// monitorFilter 连接的订阅范围
+type monitorFilter struct {
+	mu  sync.Mutex
+	all bool
+	ids map[string]bool
+}
+
+// apply 按控制消息调整订阅范围，返回调整后的范围
+func (f *monitorFilter) apply(msg monitorMessage) monitorMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case msg.Type == monitorMsgSubscribe && len(msg.JobIDs) == 0:
+		f.all, f.ids = true, map[string]bool{}
+	case msg.Type == monitorMsgSubscribe:
+		f.all = false
+		for _, id := range msg.JobIDs {
+			f.ids[id] = true
+		}
+	case len(msg.JobIDs) == 0:
+		f.all, f.ids = false, map[string]bool{}
+	default:
+		for _, id := range msg.JobIDs {
+			delete(f.ids, id)
+		}
+	}
+	reply := monitorMessage{Type: monitorMsgSubscribed, All: f.all, JobIDs: []string{}}
+	for id := range f.ids {
+		reply.JobIDs = append(reply.JobIDs, id)
+	}
+	return reply
+}
+
+func (f *monitorFilter) match(jobID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.all || f.ids[jobID]
+}
+
+// MonitorJobs 通过 WebSocket 推送任务生命周期事件（queued、started、task_done、finished），供监控面板实时展示
+// 默认推送全部可见任务的事件，?job_id=a,b 或 subscribe 消息只推送指定任务；
+// 管理员可以看到所有用户的任务，其他用户只能看到自己的任务
+func (h *BatchHandler) MonitorJobs(c *gin.Context) {
+	owner := requestUser(c)
+	user := currentUser(c)
+	admin := user != nil && user.Role == services.RoleAdmin
+	filter := &monitorFilter{all: true, ids: map[string]bool{}}
+	if ids := c.Query("job_id"); ids != "" {
+		filter.apply(monitorMessage{Type: monitorMsgSubscribe, JobIDs: strings.Split(ids, ",")})
+	}
+
+	server := websocket.Server{
+		Handshake: checkSameOrigin,
+		Handler: func(conn *websocket.Conn) {
+			h.serveMonitor(conn, filter, func(event services.LifecycleEvent) bool {
+				return admin || event.Owner == owner
+			})
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// serveMonitor 推送订阅范围内的事件，同时处理客户端的订阅消息，连接断开时取消订阅
+func (h *BatchHandler) serveMonitor(conn *websocket.Conn, filter *monitorFilter, visible func(services.LifecycleEvent) bool) {
+	defer conn.Close()
+	sub := h.Jobs.Monitor.Subscribe()
+	defer h.Jobs.Monitor.Unsubscribe(sub)
+
+	var sendMu sync.Mutex
+	send := func(msg interface{}) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return websocket.JSON.Send(conn, msg)
+	}
+
+	// 读取订阅消息，读取失败说明客户端已断开
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var msg monitorMessage
+			if err := websocket.JSON.Receive(conn, &msg); err != nil {
+				return
+			}
+			if msg.Type != monitorMsgSubscribe && msg.Type != monitorMsgUnsubscribe {
+				send(monitorMessage{Type: monitorMsgError, Error: "不支持的消息类型: " + msg.Type})
+				continue
+			}
+			send(filter.apply(msg))
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event := <-sub.Events():
+			if !visible(event) || !filter.match(event.JobID) {
+				continue
+			}
+			if dropped := sub.Dropped(); dropped > 0 {
+				if send(monitorMessage{Type: monitorMsgDropped, Dropped: dropped}) != nil {
+					return
+				}
+			}
+			if send(event) != nil {
+				return
+			}
+		}
+	}
+}
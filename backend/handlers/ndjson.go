@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ndjsonContentType 逐行输出结果的内容类型
+const ndjsonContentType = "application/x-ndjson"
+
+// 流式结果结束时写入的 HTTP trailer
+const (
+	trailerComplete  = "X-Batch-Complete"
+	trailerTotal     = "X-Total-Tasks"
+	trailerSuccess   = "X-Success-Tasks"
+	trailerFailed    = "X-Failed-Tasks"
+	trailerCancelled = "X-Cancelled-Tasks"
+)
+
+// ndjsonSummary 流的最后一行，客户端没有读到它说明流被截断
+type ndjsonSummary struct {
+	Type           string                   `json:"type"`
+	JobID          string                   `json:"job_id"`
+	Complete       bool                     `json:"complete"`
+	TotalTasks     int                      `json:"total_tasks"`
+	SuccessTasks   int                      `json:"success_tasks"`
+	FailedTasks    int                      `json:"failed_tasks"`
+	TimeoutTasks   int                      `json:"timeout_tasks"`
+	NotStarted     int                      `json:"not_started_tasks"`
+	CancelledTasks int                      `json:"cancelled_tasks"`
+	CancelMode     services.CancelMode      `json:"cancel_mode,omitempty"`
+	Duration       int64                    `json:"duration"`
+	Sample         *services.SampleEstimate `json:"sample,omitempty"`
+	BusinessErrors int                      `json:"business_error_tasks,omitempty"`
+	InfraErrors    int                      `json:"infra_error_tasks,omitempty"`
+	Stalled        int                      `json:"stalled_tasks,omitempty"`
+	Mismatches     int                      `json:"mismatch_tasks,omitempty"`
+}
+
+// wantsNDJSON 客户端是否要求以 NDJSON 逐行返回结果
+func wantsNDJSON(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), ndjsonContentType)
+}
+
+// streamNDJSON 按完成顺序逐行写出任务结果，最后写出汇总对象和携带计数的 HTTP trailer
+// run 执行批次，每完成一个任务调用 emit 写出一行；结果写出后即丢弃，内存占用不随批次大小增长，
+// 因此任务记录和产出物中只保存汇总计数。fields 非空时每行只含选择的字段
+func (h *BatchHandler) streamNDJSON(c *gin.Context, job *services.Job, fields fieldSet, run func(emit func(services.TaskResult)) *services.BatchResult) *services.BatchResult {
+	job.DiscardResults()
+	header := c.Writer.Header()
+	header.Set("Content-Type", ndjsonContentType)
+	header.Set("X-Job-ID", job.ID())
+	header.Set("Trailer", strings.Join([]string{
+		trailerComplete, trailerTotal, trailerSuccess, trailerFailed, trailerCancelled,
+	}, ", "))
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	result := run(func(result services.TaskResult) {
+		if len(fields) > 0 {
+			line := fields.project(result)
+			line["type"] = "result"
+			encoder.Encode(line)
+		} else {
+			encoder.Encode(struct {
+				Type string `json:"type"`
+				services.TaskResult
+			}{"result", result})
+		}
+		c.Writer.Flush()
+	})
+	h.finishJob(job, result)
+
+	complete := result.CancelMode == ""
+	encoder.Encode(ndjsonSummary{
+		Type:           "summary",
+		JobID:          job.ID(),
+		Complete:       complete,
+		TotalTasks:     result.TotalTasks,
+		SuccessTasks:   result.SuccessTasks,
+		FailedTasks:    result.FailedTasks,
+		TimeoutTasks:   result.TimeoutTasks,
+		NotStarted:     result.NotStartedTasks,
+		CancelledTasks: result.CancelledTasks,
+		CancelMode:     result.CancelMode,
+		Duration:       result.Duration,
+		Sample:         result.Sample,
+		BusinessErrors: result.BusinessErrorTasks,
+		InfraErrors:    result.InfraErrorTasks,
+		Stalled:        result.StalledTasks,
+		Mismatches:     result.MismatchTasks,
+	})
+
+	header.Set(trailerComplete, strconv.FormatBool(complete))
+	header.Set(trailerTotal, strconv.Itoa(result.TotalTasks))
+	header.Set(trailerSuccess, strconv.Itoa(result.SuccessTasks))
+	header.Set(trailerFailed, strconv.Itoa(result.FailedTasks))
+	header.Set(trailerCancelled, strconv.Itoa(result.CancelledTasks))
+	return result
+}
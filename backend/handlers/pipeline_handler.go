@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PipelineStageRequest 流水线中的一个阶段
+type PipelineStageRequest struct {
+	Name        string `json:"name"` // 为空时使用动作名
+	Action      string `json:"action" binding:"required,oneof=fetch split_lines trim upper lower sha256 store"`
+	Concurrency int    `json:"concurrency" binding:"omitempty,min=1,max=50"` // 该阶段的工作协程数，默认1
+	Buffer      int    `json:"buffer" binding:"omitempty,min=0,max=1000"`    // 该阶段输入通道的容量，默认0（无缓冲）
+}
+
+// RunPipelineRequest 执行流水线请求，items 中的每一项依次进入第一个阶段
+type RunPipelineRequest struct {
+	Items    []string               `json:"items" binding:"required,min=1,max=1000"`
+	Stages   []PipelineStageRequest `json:"stages" binding:"required,min=1,max=10,dive"`
+	FailFast bool                   `json:"fail_fast"` // 首个输入失败后取消其余输入
+	Seed     int64                  `json:"seed"`      // 批次种子，0表示随机生成，决定 fetch 阶段重试的等待时间
+	// ChainedFrom 上游任务ID，由 POST /api/jobs/:id/chain 填写
+	ChainedFrom string `json:"chained_from,omitempty"`
+	// TemplateID 批次模板ID，由 POST /api/templates/:id/run 填写
+	TemplateID uint `json:"template_id,omitempty"`
+	// Group 互斥组，同组的批次和流水线依次执行
+	Group string `json:"group,omitempty" binding:"omitempty,max=64"`
+	// Priority 全局队列中的优先级类别：high、normal（默认）、low
+	Priority string `json:"priority,omitempty" binding:"omitempty,oneof=high normal low"`
+	// DependsOn 上游任务ID，上游成功完成后才开始执行
+	DependsOn string `json:"depends_on,omitempty"`
+	// Async 立即返回 202 和 job_id，流水线在后台执行；结果中不含各阶段的统计
+	Async bool `json:"async"`
+}
+
+// RunPipeline 执行多阶段流水线
+// 每个输入对应结果中的一个任务，data 为其到达最后一个阶段的全部数据项；stages 给出各阶段的处理统计
+func (h *BatchHandler) RunPipeline(c *gin.Context) {
+	var req RunPipelineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	specs := make([]services.PipelineStageSpec, len(req.Stages))
+	for i, stage := range req.Stages {
+		specs[i] = services.PipelineStageSpec{
+			Name:        stage.Name,
+			Action:      stage.Action,
+			Concurrency: stage.Concurrency,
+			Buffer:      stage.Buffer,
+		}
+	}
+
+	if !h.checkDependsOn(c, req.DependsOn) {
+		return
+	}
+
+	// 登记任务，便于通过 DELETE /api/jobs/:id 取消
+	job, ctx := h.startJob(c, "pipeline", len(req.Items))
+	job.SetTenant(requestTenant(c))
+	markDegraded(c, job)
+	job.SetRun(services.NewRunRecord(req.Seed, gin.H{
+		"stages":       req.Stages,
+		"fail_fast":    req.FailFast,
+		"timeout":      h.Pipelines.Timeout.String(),
+		"fetch":        h.Pipelines.Fetcher.RunConfig(),
+		"chained_from": req.ChainedFrom,
+		"template_id":  req.TemplateID,
+		"group":        req.Group,
+		"priority":     req.Priority,
+		"depends_on":   req.DependsOn,
+	}))
+	job.SetPriority(req.Priority)
+	job.SetDependsOn(req.DependsOn)
+	job.SetTemplate(c.GetUint(templateRunKey), c.GetDuration(templateRetentionKey))
+	if req.FailFast {
+		job.EnableFailFast()
+	}
+	if req.Async {
+		h.startAsync(c, ctx, job, req.Group, h.Pipelines.Timeout, func(ctx context.Context) *services.BatchResult {
+			result, err := h.Pipelines.Run(ctx, job.ID(), specs, req.Items)
+			if err != nil {
+				log.Printf("流水线 %s 执行失败: %v", job.ID(), err)
+				return pipelineNotStarted(len(req.Items))
+			}
+			return result.BatchResult
+		})
+		return
+	}
+	// 等待和排队时间不计入流水线的超时
+	h.enterJob(ctx, job, req.Group)
+
+	result, err := h.Pipelines.Run(ctx, job.ID(), specs, req.Items)
+	if err != nil {
+		h.Jobs.Finish(job, pipelineNotStarted(len(req.Items)))
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnknownPipelineAction) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	h.finishJob(job, result.BatchResult)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "流水线执行完成",
+		"job_id":  job.ID(),
+		"data":    result,
+	})
+}
+
+// pipelineNotStarted 流水线未能开始执行（输出文件创建失败等）时的结果，所有输入都未开始执行
+func pipelineNotStarted(n int) *services.BatchResult {
+	return &services.BatchResult{TotalTasks: n, FailedTasks: n, NotStartedTasks: n}
+}
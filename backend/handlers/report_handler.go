@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJobReport 生成已结束任务的性能报告：耗时分布、并发时间线、错误分组以及与上一次同类型任务的对比
+// format=html（默认）返回服务端渲染的页面，可在浏览器中打印为 PDF；format=json 返回报告数据
+func (h *BatchHandler) GetJobReport(c *gin.Context) {
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 参数只支持 html 或 json"})
+		return
+	}
+	job, ok := h.userJob(c)
+	if !ok {
+		return
+	}
+
+	result := job.Result()
+	info := job.Info()
+	if result == nil {
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"message": "任务尚未结束",
+			"data":    gin.H{"job": info},
+		})
+		return
+	}
+
+	// 对比失败不影响报告本身
+	previous, err := h.JobHistory.PreviousRun(info.Owner, info.Type, info.StartTime)
+	if err != nil {
+		log.Printf("查询任务 %s 的上一次执行失败: %v", info.ID, err)
+	}
+	report := services.BuildJobReport(info, result, job.ConcurrencyTimeline(), previous)
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "任务报告生成成功",
+			"data":    report,
+		})
+		return
+	}
+
+	var page strings.Builder
+	if err := reportTemplate.Execute(&page, report); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成任务报告失败: " + err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page.String()))
+}
+
+// reportTemplate 任务报告页面，图表以纯 CSS 和内联 SVG 绘制，不依赖外部资源
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	// width 返回 n 占 total 的百分比宽度
+	"width": func(n, total int) string {
+		if total == 0 {
+			return "0%"
+		}
+		return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+	},
+	// bucketMax 直方图中最大的区间计数
+	"bucketMax": func(buckets []services.LatencyBucket) int {
+		m := 0
+		for _, b := range buckets {
+			m = max(m, b.Count)
+		}
+		return m
+	},
+	// bucketLabel 直方图区间的说明
+	"bucketLabel": func(buckets []services.LatencyBucket, i int) string {
+		if buckets[i].UpperBound == 0 {
+			return fmt.Sprintf("> %dms", buckets[i-1].UpperBound)
+		}
+		return fmt.Sprintf("≤ %dms", buckets[i].UpperBound)
+	},
+	// timeline 以并发时间线生成 SVG 折线的坐标，宽 600、高 120
+	"timeline": func(points []int) string {
+		peak := 1
+		for _, n := range points {
+			peak = max(peak, n)
+		}
+		// 只有一秒时画一条水平线
+		if len(points) == 1 {
+			points = []int{points[0], points[0]}
+		}
+		var b strings.Builder
+		step := 600.0 / float64(max(len(points)-1, 1))
+		for i, n := range points {
+			fmt.Fprintf(&b, "%.1f,%.1f ", float64(i)*step, 120-float64(n)*110/float64(peak))
+		}
+		return b.String()
+	},
+	"signed": func(v float64) string { return fmt.Sprintf("%+.1f", v) },
+	// mib 以 MiB 显示字节数
+	"mib": func(n uint64) string { return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20)) },
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>任务报告 {{.Job.ID}}</title>
+<style>
+body { font-family: -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; margin: 32px; color: #222; }
+h1 { font-size: 22px; } h2 { font-size: 17px; margin-top: 28px; border-bottom: 1px solid #ddd; padding-bottom: 4px; }
+table { border-collapse: collapse; } td, th { padding: 4px 12px; text-align: left; border-bottom: 1px solid #eee; }
+.bar { background: #4a90d9; height: 14px; } .muted { color: #888; }
+.up { color: #c0392b; } .down { color: #27ae60; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>任务报告 {{.Job.ID}}</h1>
+<p class="muted">类型 {{.Job.Type}} · 状态 {{.Job.Status}} · 开始于 {{.Job.StartTime.Format "2006-01-02 15:04:05"}} · 生成于 {{.GeneratedAt.Format "2006-01-02 15:04:05"}}</p>
+
+<h2>概览</h2>
+<table>
+<tr><th>任务数</th><td>{{.Summary.TotalTasks}}</td><th>成功率</th><td>{{printf "%.1f" .Summary.SuccessRate}}%</td></tr>
+<tr><th>成功</th><td>{{.Summary.SuccessTasks}}</td><th>失败</th><td>{{.Summary.FailedTasks}}</td></tr>
+<tr><th>超时</th><td>{{.Summary.TimeoutTasks}}</td><th>取消 / 未开始</th><td>{{.Summary.CancelledTasks}} / {{.Summary.NotStartedTasks}}</td></tr>
+<tr><th>耗时</th><td>{{.Summary.Duration}}ms</td><th>吞吐</th><td>{{printf "%.1f" .Summary.TasksPerSecond}} 任务/秒</td></tr>
+<tr><th>并发峰值</th><td>{{.Summary.PeakConcurrency}}</td><th></th><td></td></tr>
+</table>
+
+<h2>资源消耗</h2>
+{{with .Resources}}
+<table>
+<tr><th>CPU 时间</th><td>{{printf "%.1f" .CPUTotalMs}}ms（用户态 {{printf "%.1f" .CPUUserMs}}ms，内核态 {{printf "%.1f" .CPUSystemMs}}ms）</td><th>每个任务</th><td>{{printf "%.2f" .CPUPerTaskMs}}ms</td></tr>
+<tr><th>CPU 利用率</th><td>{{printf "%.2f" .CPUUtil}} 核</td><th>GC 次数</th><td>{{.GCCycles}}</td></tr>
+<tr><th>堆分配</th><td>{{mib .AllocBytes}}</td><th>分配对象数</th><td>{{.AllocObjects}}</td></tr>
+</table>
+<p class="muted">进程级数据（来源 {{.CPUSource}}），HTTP 服务本身的消耗也计入在内{{if .Overlapped}}；<b>执行期间有其他批次同时执行，数据包含它们的消耗</b>{{end}}</p>
+{{if .Workers}}
+<table>
+<tr><th>工作槽位</th><th>任务数</th><th>线程 CPU 时间</th></tr>
+{{range .Workers}}<tr><td>#{{.Slot}}</td><td>{{.Tasks}}</td><td>{{printf "%.1f" .CPUMs}}ms</td></tr>
+{{end}}</table>
+{{end}}
+{{else}}<p class="muted">没有任务执行，未记录资源消耗</p>{{end}}
+
+<h2>耗时分布</h2>
+{{if .Latency.Count}}
+<p>P50 {{.Latency.P50}}ms · P90 {{.Latency.P90}}ms · P99 {{.Latency.P99}}ms · 最大 {{.Latency.Max}}ms</p>
+{{$buckets := .Latency.Histogram}}{{$max := bucketMax $buckets}}
+<table>
+{{range $i, $b := $buckets}}<tr><td>{{bucketLabel $buckets $i}}</td><td style="width:400px"><div class="bar" style="width:{{width $b.Count $max}}"></div></td><td>{{$b.Count}}</td></tr>
+{{end}}</table>
+{{else}}<p class="muted">没有任务明细（流式返回的批次不保留明细）</p>{{end}}
+
+<h2>并发时间线</h2>
+{{if .Concurrency}}
+<svg width="600" height="120" viewBox="0 0 600 120" style="border:1px solid #eee">
+<polyline fill="none" stroke="#4a90d9" stroke-width="2" points="{{timeline .Concurrency}}"/>
+</svg>
+<p class="muted">横轴为任务开始后的秒数（共 {{len .Concurrency}} 秒），纵轴为每秒的最大并发数（峰值 {{.Summary.PeakConcurrency}}）</p>
+{{else}}<p class="muted">没有并发记录</p>{{end}}
+{{if .Job.ConfigChanges}}
+<table>
+<tr><th>开始后</th><th>配置</th><th>调整前</th><th>调整后</th><th>操作人</th></tr>
+{{range .Job.ConfigChanges}}<tr><td>{{.Elapsed}}ms</td><td>{{.Setting}}{{if .Scope}}（{{.Scope}}）{{end}}</td><td>{{.Before}}</td><td>{{.After}}</td><td>{{.By}}</td></tr>
+{{end}}</table>
+<p class="muted">执行期间通过管理接口调整的配置，调整前后的耗时和吞吐不宜直接比较</p>
+{{end}}
+
+<h2>错误分组</h2>
+{{if .Errors}}
+<table>
+<tr><th>状态</th><th>分类</th><th>错误</th><th>数量</th><th>示例任务</th></tr>
+{{range .Errors}}<tr><td>{{.Status}}</td><td>{{.Class}}</td><td>{{.Message}}</td><td>{{.Count}}</td><td>#{{.Example}}</td></tr>
+{{end}}</table>
+{{else}}<p class="muted">没有失败的任务</p>{{end}}
+
+<h2>与上一次对比</h2>
+{{with .Previous}}
+<p class="muted">上一次同类型任务 {{.JobID}}，开始于 {{.StartTime.Format "2006-01-02 15:04:05"}}</p>
+<table>
+<tr><th></th><th>上一次</th><th>本次相比</th></tr>
+<tr><td>任务数</td><td>{{.TotalTasks}}</td><td></td></tr>
+<tr><td>成功率</td><td>{{printf "%.1f" .SuccessRate}}%</td><td class="{{if lt .SuccessRateDiff 0.0}}up{{else}}down{{end}}">{{signed .SuccessRateDiff}} 个百分点</td></tr>
+<tr><td>耗时</td><td>{{.Duration}}ms</td><td class="{{if gt .DurationChange 0.0}}up{{else}}down{{end}}">{{signed .DurationChange}}%</td></tr>
+</table>
+{{else}}<p class="muted">没有可对比的上一次任务</p>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"concurrency-web-app/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// anonymousUser 未标识身份的请求使用的用户名
+const anonymousUser = "anonymous"
+
+// currentUserKey 已登录用户在 gin.Context 中的键
+const currentUserKey = "current_user"
+
+// headerUserKey 开启 TrustUserHeader 时未登录请求的 X-User-ID 在 gin.Context 中的键
+const headerUserKey = "header_user"
+
+// currentTenantKey 通过 X-API-Key 标识的租户ID在 gin.Context 中的键
+const currentTenantKey = "current_tenant"
+
+// requestUser 返回发起请求的用户
+// 已登录时为账号用户名；未登录时为匿名用户，只有开启 TrustUserHeader 时以 X-User-ID 请求头标识
+func requestUser(c *gin.Context) string {
+	if user := currentUser(c); user != nil {
+		return user.Username
+	}
+	if user := c.GetString(headerUserKey); user != "" {
+		return user
+	}
+	return anonymousUser
+}
+
+// currentUser 返回已登录的用户，未登录时为nil
+func currentUser(c *gin.Context) *models.User {
+	user, _ := c.Get(currentUserKey)
+	u, _ := user.(*models.User)
+	return u
+}
+
+// requestTenant 返回通过 X-API-Key 请求头标识的租户，未携带API密钥时为 0
+func requestTenant(c *gin.Context) uint {
+	return c.GetUint(currentTenantKey)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamJobEvents 以 SSE 推送任务事件（每个任务结果和最终汇总）
+// 断线重连时携带 Last-Event-ID 请求头（或 last_event_id 参数）从断点续传；
+// on_disconnect=buffer（默认）订阅者断开后任务继续执行，on_disconnect=cancel 订阅者断开时硬取消任务
+func (h *BatchHandler) StreamJobEvents(c *gin.Context) {
+	job, ok := h.userJob(c)
+	if !ok {
+		return
+	}
+
+	mode := c.DefaultQuery("on_disconnect", disconnectBuffer)
+	if !validDisconnectMode(mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的断开处理方式: " + mode})
+		return
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var after int64
+	if lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseInt(lastEventID, 10, 64); err != nil || after < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Last-Event-ID 格式错误"})
+			return
+		}
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	err := job.Follow(ctx, after, func(event services.JobEvent) error {
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, services.ErrEventsMissed):
+		// 断点之后的事件已被丢弃，客户端应改为获取完整结果
+		data, _ := json.Marshal(gin.H{
+			"error":  err.Error(),
+			"result": "/api/jobs/" + job.ID() + "/result",
+		})
+		fmt.Fprintf(c.Writer, "event: reset\ndata: %s\n\n", data)
+		c.Writer.Flush()
+	case ctx.Err() != nil && mode == disconnectCancel:
+		h.Jobs.Cancel(job.ID(), services.CancelModeHard, requestUser(c))
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetOrderStats 订单批量处理统计时间序列
+// 支持参数：from、to（日期或RFC3339）、interval（hour|day，默认day）
+func (h *BatchHandler) GetOrderStats(c *gin.Context) {
+	from, err := parseDateQuery(c, "from", false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from 参数格式错误"})
+		return
+	}
+	to, err := parseDateQuery(c, "to", true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to 参数格式错误"})
+		return
+	}
+	interval := c.DefaultQuery("interval", services.StatsIntervalDay)
+
+	series, err := h.OrderStats.Series(from, to, interval)
+	if errors.Is(err, services.ErrInvalidStatsInterval) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error() + ": " + interval})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "统计查询失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"message":  "订单统计获取成功",
+		"interval": interval,
+		"data":     series,
+	})
+}
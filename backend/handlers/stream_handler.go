@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// 流式批次的消息类型
+const (
+	streamMsgOpen     = "open"     // 客户端：打开批次，指定 job_type
+	streamMsgTask     = "task"     // 客户端：提交一个任务
+	streamMsgClose    = "close"    // 客户端：不再提交任务，等待已提交的任务结束
+	streamMsgOpened   = "opened"   // 服务端：批次已打开，返回 job_id
+	streamMsgAccepted = "accepted" // 服务端：任务已接收，返回任务序号
+	streamMsgResult   = "result"   // 服务端：单个任务结果
+	streamMsgSummary  = "summary"  // 服务端：全部任务结束后的汇总
+	streamMsgThrottle = "throttle" // 服务端：按下游主机的失败率调整了并发，result 为调整内容
+	streamMsgError    = "error"    // 服务端：消息处理失败
+)
+
+// 订阅者断开时的处理方式
+const (
+	disconnectCancel = "cancel" // 硬取消任务，立即释放资源
+	disconnectBuffer = "buffer" // 任务继续执行，事件缓冲供重连后续传
+)
+
+// maxStreamPending 一个流式批次中已提交但尚未结束的任务数上限，达到上限时暂停读取客户端的消息，
+// 由 WebSocket 连接的流量控制让客户端等待，不会为每个提交的任务都占用协程
+const maxStreamPending = 1000
+
+// validDisconnectMode 断开处理方式是否合法，空值表示使用默认值
+func validDisconnectMode(mode string) bool {
+	return mode == "" || mode == disconnectCancel || mode == disconnectBuffer
+}
+
+// streamMessage 流式批次的消息
+type streamMessage struct {
+	Type    string `json:"type"`
+	JobType string `json:"job_type,omitempty"`
+	JobID   string `json:"job_id,omitempty"`
+	EventID int64  `json:"event_id,omitempty"` // 结果和汇总对应的任务事件ID，断线后可用于续传
+	// OnDisconnect 连接断开时的处理方式：cancel（默认）立即硬取消批次释放资源；
+	// buffer 停止接收新任务，已提交的任务继续执行，结果缓冲在任务事件中，
+	// 可通过 GET /api/jobs/:id/events 携带 Last-Event-ID 续传
+	OnDisconnect string          `json:"on_disconnect,omitempty"`
+	Task         json.RawMessage `json:"task,omitempty"`
+	Index        *int            `json:"index,omitempty"`
+	Result       interface{}     `json:"result,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// StreamBatch 通过 WebSocket 增量提交任务并逐个返回结果
+// 协议：客户端先发送 open，之后发送任意数量的 task，最后发送 close；
+// 服务端对每个任务回复 accepted，任务完成时推送 result，全部结束后推送 summary 并关闭连接。
+// 连接意外断开时按 open 消息中的 on_disconnect 处理
+func (h *BatchHandler) StreamBatch(c *gin.Context) {
+	owner, tenantID := requestUser(c), requestTenant(c)
+	// 流式批次不在全局队列中排队，预留的名额不保留到连接关闭
+	requestAdmission(c).Release()
+	server := websocket.Server{
+		Handshake: checkSameOrigin,
+		Handler: func(conn *websocket.Conn) {
+			h.serveBatchStream(conn, owner, tenantID)
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// checkSameOrigin 拒绝来自其他站点的浏览器连接，非浏览器客户端不带 Origin 时放行
+func checkSameOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host != req.Host {
+		return fmt.Errorf("不允许的来源: %s", origin)
+	}
+	return nil
+}
+
+func (h *BatchHandler) serveBatchStream(conn *websocket.Conn, owner string, tenantID uint) {
+	defer conn.Close()
+
+	var sendMu sync.Mutex
+	send := func(msg streamMessage) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return websocket.JSON.Send(conn, msg)
+	}
+
+	// 第一条消息必须是 open
+	var open streamMessage
+	if err := websocket.JSON.Receive(conn, &open); err != nil {
+		return
+	}
+	submit, concurrency, err := h.streamSubmitter(open, owner)
+	if err == nil && !validDisconnectMode(open.OnDisconnect) {
+		err = fmt.Errorf("不支持的断开处理方式: %s", open.OnDisconnect)
+	}
+	if err != nil {
+		send(streamMessage{Type: streamMsgError, Error: err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	job, ctx := h.Jobs.Start(ctx, open.JobType, owner, 0)
+	job.SetTenant(tenantID)
+	h.background.Add(1)
+	defer h.background.Done()
+	stream := services.NewBatchStream(ctx, concurrency)
+	stream.SetMaxPending(maxStreamPending)
+	send(streamMessage{Type: streamMsgOpened, JobID: job.ID()})
+
+	// 结果通过任务事件推送，这里只负责读空结果通道
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for range stream.Results() {
+		}
+	}()
+
+	// 推送任务事件，发送失败说明客户端已断开，立即停止推送
+	followCtx, stopFollow := context.WithCancel(context.Background())
+	defer stopFollow()
+	pushed := make(chan struct{})
+	go func() {
+		defer close(pushed)
+		job.Follow(followCtx, 0, func(event services.JobEvent) error {
+			msg := streamMessage{Type: streamMsgResult, EventID: event.ID, Result: event.Data}
+			switch event.Type {
+			case services.JobEventSummary:
+				msg.Type = streamMsgSummary
+				msg.JobID = job.ID()
+			case services.JobEventThrottle:
+				msg.Type = streamMsgThrottle
+			}
+			return send(msg)
+		})
+	}()
+
+	// 服务关闭时停止接收新任务：中断等待中的读取，已提交的任务执行完后照常推送汇总
+	receiving := make(chan struct{})
+	go func() {
+		select {
+		case <-h.Jobs.Draining():
+			send(streamMessage{Type: streamMsgError, Error: "服务正在关闭，不再接收新任务"})
+			conn.SetReadDeadline(time.Now())
+		case <-receiving:
+		}
+	}()
+
+	// 接收任务
+	for {
+		var msg streamMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			if draining(h.Jobs) {
+				break
+			}
+			// 客户端断开
+			stopFollow()
+			if open.OnDisconnect != disconnectBuffer {
+				h.Jobs.Cancel(job.ID(), services.CancelModeHard, owner)
+			}
+			break
+		}
+		if msg.Type == streamMsgClose {
+			break
+		}
+		if msg.Type != streamMsgTask {
+			send(streamMessage{Type: streamMsgError, Error: "不支持的消息类型: " + msg.Type})
+			continue
+		}
+
+		index, err := submit(stream, msg.Task)
+		if err != nil {
+			send(streamMessage{Type: streamMsgError, Error: err.Error()})
+			continue
+		}
+		send(streamMessage{Type: streamMsgAccepted, Index: &index})
+	}
+
+	close(receiving)
+	stream.Close()
+	<-drained
+
+	h.finishJob(job, stream.Result())
+	<-pushed
+}
+
+// draining 服务是否正在关闭
+func draining(jobs *services.JobManager) bool {
+	select {
+	case <-jobs.Draining():
+		return true
+	default:
+		return false
+	}
+}
+
+// streamSubmitter 按任务类型返回解析并提交任务的函数及并发数，按版本指定的文件只查找 owner 上传的文件
+func (h *BatchHandler) streamSubmitter(open streamMessage, owner string) (func(*services.BatchStream, json.RawMessage) (int, error), int, error) {
+	if open.Type != streamMsgOpen {
+		return nil, 0, fmt.Errorf("第一条消息必须为 %s", streamMsgOpen)
+	}
+
+	switch open.JobType {
+	case "order":
+		return func(stream *services.BatchStream, raw json.RawMessage) (int, error) {
+			var task services.OrderTask
+			if err := json.Unmarshal(raw, &task); err != nil {
+				return 0, fmt.Errorf("任务格式错误: %v", err)
+			}
+			return h.OrderService.SubmitOrder(stream, task)
+		}, h.OrderService.MaxConcurrency, nil
+	case "api":
+		return func(stream *services.BatchStream, raw json.RawMessage) (int, error) {
+			var task services.APICallTask
+			if err := json.Unmarshal(raw, &task); err != nil {
+				return 0, fmt.Errorf("任务格式错误: %v", err)
+			}
+			return h.APIService.SubmitAPICall(stream, task)
+		}, h.APIService.MaxConcurrency, nil
+	case "file":
+		return func(stream *services.BatchStream, raw json.RawMessage) (int, error) {
+			tasks := make([]services.FileTask, 1)
+			if err := json.Unmarshal(raw, &tasks[0]); err != nil {
+				return 0, fmt.Errorf("任务格式错误: %v", err)
+			}
+			if err := h.resolveFileTasks(tasks, owner); err != nil {
+				return 0, err
+			}
+			return h.FileService.SubmitFile(stream, tasks[0])
+		}, h.FileService.MaxConcurrency, nil
+	default:
+		service, err := h.Tasks.Get(open.JobType)
+		if err != nil {
+			return nil, 0, fmt.Errorf("不支持的任务类型: %s", open.JobType)
+		}
+		return func(stream *services.BatchStream, raw json.RawMessage) (int, error) {
+			task, err := service.Kind.Decode(raw)
+			if err != nil {
+				return 0, fmt.Errorf("任务格式错误: %v", err)
+			}
+			return service.SubmitTask(stream, task)
+		}, service.MaxConcurrency, nil
+	}
+}
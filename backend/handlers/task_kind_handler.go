@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchProcessTasksRequest 注册任务类型的批量处理请求，tasks 中每一项由任务类型的 Decode 解析
+type BatchProcessTasksRequest struct {
+	Tasks []json.RawMessage `json:"tasks" binding:"required"`
+	BatchOptions
+}
+
+// batchProcessTasks 返回注册任务类型的批量处理接口，执行选项、任务登记和 NDJSON 流式返回与内置的批量接口相同
+func (h *BatchHandler) batchProcessTasks(service *services.KindService) gin.HandlerFunc {
+	kind := service.Kind.Name()
+	return func(c *gin.Context) {
+		var req BatchProcessTasksRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+			return
+		}
+		decoded, err := service.Decode(req.Tasks)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "任务格式错误: " + err.Error()})
+			return
+		}
+		fields, ok := resultFields(c)
+		if !ok {
+			return
+		}
+		expect, ok := req.expectations(c, len(decoded))
+		if !ok {
+			return
+		}
+		if !h.checkLimits(c, req.BatchOptions) {
+			return
+		}
+
+		// 记录种子和配置快照，便于之后复现该批次
+		run := req.newRun(service.RunConfig())
+
+		// 指定抽样比例时只执行抽中的任务
+		tasks, sample := services.SampleTasks(decoded, req.SampleRate, req.sampleSeed(run))
+
+		if !h.checkDependsOn(c, req.DependsOn) {
+			return
+		}
+
+		// 登记任务，便于通过 DELETE /api/jobs/:id 取消
+		job, ctx := h.startJob(c, kind, len(tasks))
+		job.SetTenant(requestTenant(c))
+		markDegraded(c, job)
+		job.SetRun(run)
+		job.SetChunks(req.chunks())
+		job.SetLimits(req.limits())
+		job.SetResultOrder(req.resultOrder())
+		job.SetPriority(req.Priority)
+		job.SetDependsOn(req.DependsOn)
+		job.SetTemplate(c.GetUint(templateRunKey), c.GetDuration(templateRetentionKey))
+		if req.FailFast {
+			job.EnableFailFast()
+		}
+		if req.DebugCapture {
+			job.EnableDebugCapture()
+		}
+		if req.WorkerUsage {
+			job.EnableWorkerUsage()
+		}
+
+		execute := func(ctx context.Context) *services.BatchResult {
+			return expect.Apply(sample.Apply(service.BatchProcess(ctx, tasks)))
+		}
+		if req.Async {
+			// 保存提交的原始任务，恢复时按任务类型重新解析
+			h.saveCheckpoint(c, job, req.Tasks, req.BatchOptions)
+			h.startAsync(c, ctx, job, req.Group, req.timeout(service.Timeout), execute)
+			return
+		}
+
+		// 指定互斥组时等同组的前序任务结束后再执行，批次超时从开始执行时计算
+		ctx, cancel := h.batchContext(ctx, job, req.Group, req.timeout(service.Timeout))
+		defer cancel()
+
+		// 客户端要求 NDJSON 时逐行返回结果
+		if wantsNDJSON(c) {
+			h.streamNDJSON(c, job, fields, func(emit func(services.TaskResult)) *services.BatchResult {
+				return expect.Summarize(sample.Apply(service.EachTask(ctx, tasks, func(r services.TaskResult) {
+					emit(expect.Check(sample.Remap(r)))
+				})))
+			})
+			return
+		}
+
+		result := execute(ctx)
+		h.finishJob(job, result)
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "批量处理完成: " + kind,
+			"job_id":  job.ID(),
+			"data":    fields.batch(result),
+		})
+	}
+}
+
+// ListTaskKinds 已注册的任务类型
+func (h *BatchHandler) ListTaskKinds(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "任务类型获取成功", "data": h.Tasks.Names()})
+}
+
+// ListErrorCodes 任务错误码及其消息模板，客户端按 TaskResult.ErrorCode 查找模板并以 ErrorParams 替换其中的 {参数}
+func (h *BatchHandler) ListErrorCodes(c *gin.Context) {
+	lang := c.Query("lang")
+	if lang == "" {
+		lang = c.GetHeader("Accept-Language")
+	}
+	lang = services.MatchErrorLanguage(lang)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "错误码获取成功", "data": gin.H{
+		"lang":      lang,
+		"languages": services.ErrorLanguages(),
+		"messages":  services.ErrorCatalog(lang),
+	}})
+}
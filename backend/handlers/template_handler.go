@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// templateRunKey RunTemplate 在 gin 上下文中记录执行的模板ID，批量接口据此将批次关联到模板的保留策略；
+// 请求体中的 template_id 可由客户端随意填写，只记入配置快照
+const templateRunKey = "template_run"
+
+// templateRetentionKey RunTemplate 在 gin 上下文中记录模板保留策略中明细的保留时间，用于计算批次结果的过期时间
+const templateRetentionKey = "template_retention"
+
+// TemplateRequest 创建/更新批次模板请求
+type TemplateRequest struct {
+	Name        string          `json:"name" binding:"required,max=100"`
+	Description string          `json:"description"`
+	Target      string          `json:"target" binding:"required,oneof=orders apis files pipeline"` // 执行时使用的批量接口
+	Request     json.RawMessage `json:"request" binding:"required"`                                 // 批量接口的请求体，如 {"orders": [...], "fail_fast": true}
+	// SummaryRetentionDays 执行的批次的任务记录保留天数，0表示一直保留
+	SummaryRetentionDays int `json:"summary_retention_days" binding:"omitempty,min=0,max=36500"`
+	// DetailRetentionDays 执行的批次的产出物等明细保留天数，0表示与任务记录一同清理；须不超过 SummaryRetentionDays
+	DetailRetentionDays int `json:"detail_retention_days" binding:"omitempty,min=0,max=36500"`
+}
+
+// templateResponse 返回给客户端的模板，请求体按 JSON 原样返回
+type templateResponse struct {
+	*models.BatchTemplate
+	Request json.RawMessage `json:"request"`
+}
+
+func newTemplateResponse(template *models.BatchTemplate) templateResponse {
+	return templateResponse{BatchTemplate: template, Request: json.RawMessage(template.Request)}
+}
+
+// templateError 将模板接口的错误映射为HTTP状态码
+func templateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTemplateExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// ListTemplates 列出当前用户的批次模板
+func (h *BatchHandler) ListTemplates(c *gin.Context) {
+	templates, err := h.Templates.List(requestUser(c))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	data := make([]templateResponse, len(templates))
+	for i := range templates {
+		data[i] = newTemplateResponse(&templates[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "模板列表获取成功", "data": data})
+}
+
+// GetTemplate 获取批次模板
+func (h *BatchHandler) GetTemplate(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	template, err := h.Templates.Get(id, requestUser(c))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "模板获取成功", "data": newTemplateResponse(template)})
+}
+
+// CreateTemplate 创建批次模板
+func (h *BatchHandler) CreateTemplate(c *gin.Context) {
+	h.saveTemplate(c, 0)
+}
+
+// UpdateTemplate 更新批次模板
+func (h *BatchHandler) UpdateTemplate(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	h.saveTemplate(c, id)
+}
+
+func (h *BatchHandler) saveTemplate(c *gin.Context, id uint) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	template := &models.BatchTemplate{
+		ID:          id,
+		Owner:       requestUser(c),
+		Name:        req.Name,
+		Description: req.Description,
+		Target:      req.Target,
+		Request:     string(req.Request),
+		SummaryDays: req.SummaryRetentionDays,
+		DetailDays:  req.DetailRetentionDays,
+	}
+	if err := h.Templates.Save(template); err != nil {
+		templateError(c, err)
+		return
+	}
+
+	status, message := http.StatusOK, "模板已更新"
+	if id == 0 {
+		status, message = http.StatusCreated, "模板已创建"
+	}
+	c.JSON(status, gin.H{"success": true, "message": message, "data": newTemplateResponse(template)})
+}
+
+// DeleteTemplate 删除批次模板
+func (h *BatchHandler) DeleteTemplate(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	if err := h.Templates.Delete(id, requestUser(c)); err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "模板已删除"})
+}
+
+// RunTemplate 执行批次模板：以模板保存的请求体调用对应的批量接口，校验、任务登记和响应与直接提交相同。
+// 请求体可选，其中的字段覆盖模板中的同名字段（如 {"async": true, "priority": "high"}）；批次的配置快照中记录模板ID（template_id）
+func (h *BatchHandler) RunTemplate(c *gin.Context) {
+	id, ok := pathID(c)
+	if !ok {
+		return
+	}
+	template, err := h.Templates.Get(id, requestUser(c))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(template.Request), &body); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "模板的请求体已损坏: " + err.Error()})
+		return
+	}
+	var overrides map[string]interface{}
+	if err := json.NewDecoder(c.Request.Body).Decode(&overrides); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	for k, v := range overrides {
+		body[k] = v
+	}
+	body["template_id"] = template.ID
+
+	// 生成的请求体交给模板对应的批量接口，由其完成参数绑定和执行
+	data, err := json.Marshal(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "生成批次请求失败: " + err.Error()})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+	c.Request.ContentLength = int64(len(data))
+	c.Set(templateRunKey, template.ID)
+	c.Set(templateRetentionKey, services.DetailRetention(*template))
+	chainTargets[template.Target].handle(h, c)
+}
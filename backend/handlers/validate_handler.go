@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	"concurrency-web-app/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 预检接口与对应的批量处理接口使用相同的请求体，只并发校验每个任务，不执行、不登记任务
+// 客户端可在提交超大批次前先预检，按返回的序号修正有问题的任务
+
+// ValidateOrders 预检批量订单
+func (h *BatchHandler) ValidateOrders(c *gin.Context) {
+	var req BatchProcessOrdersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	report := h.OrderService.ValidateOrders(c.Request.Context(), req.Orders)
+	respondValidation(c, report)
+}
+
+// ValidateAPICalls 预检批量API调用
+func (h *BatchHandler) ValidateAPICalls(c *gin.Context) {
+	var req BatchCallAPIsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	report := h.APIService.ValidateAPICalls(c.Request.Context(), req.APIs)
+	respondValidation(c, report)
+}
+
+// ValidateFiles 预检批量文件处理，按文件ID或版本指定的任务同时检查能否解析
+func (h *BatchHandler) ValidateFiles(c *gin.Context) {
+	var req BatchProcessFilesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	owner := requestUser(c)
+	report := h.FileService.ValidateFiles(c.Request.Context(), req.Files, func(task services.FileTask) (services.FileTask, error) {
+		return h.resolveFileTask(task, owner)
+	})
+	respondValidation(c, report)
+}
+
+// respondValidation 返回预检结果，是否全部通过由 valid 字段给出
+func respondValidation(c *gin.Context, report *services.ValidationReport) {
+	valid := report.InvalidTasks == 0 && report.CheckedTasks == report.TotalTasks
+	message := "全部任务校验通过"
+	if !valid {
+		message = "部分任务未通过校验"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"valid":   valid,
+		"message": message,
+		"data":    report,
+	})
+}
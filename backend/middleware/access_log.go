@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessRecord 一条访问记录
+type AccessRecord struct {
+	Time       time.Time
+	Method     string
+	Path       string
+	Route      string // 匹配的路由模板，未匹配时为空
+	Status     int
+	Latency    time.Duration
+	Size       int
+	User       string
+	ClientIP   string
+	SampleRate float64 // 被记录时的抽样率，错误和慢请求为 1
+}
+
+// AccessLogConfig 访问日志配置
+type AccessLogConfig struct {
+	SampleRate    float64                   // 普通请求的抽样率，0-1
+	SlowThreshold time.Duration             // 超过该耗时的请求必录，0表示不按耗时必录
+	User          func(*gin.Context) string // 请求用户，处理完成后调用，可为nil
+	Record        func(AccessRecord)        // 写入记录，应尽快返回
+}
+
+// AccessLog 抽样记录访问日志
+// 状态码 >= 500 和慢请求全部记录，其余请求按 SampleRate 抽样，统计时可按 1/sample_rate 还原总量
+func AccessLog(cfg AccessLogConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		rate := cfg.SampleRate
+		if c.Writer.Status() >= 500 || (cfg.SlowThreshold > 0 && latency >= cfg.SlowThreshold) {
+			rate = 1
+		} else if rate <= 0 || rand.Float64() >= rate {
+			return
+		}
+
+		record := AccessRecord{
+			Time:       start,
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			Route:      c.FullPath(),
+			Status:     c.Writer.Status(),
+			Latency:    latency,
+			Size:       c.Writer.Size(),
+			ClientIP:   c.ClientIP(),
+			SampleRate: rate,
+		}
+		if record.Size < 0 {
+			record.Size = 0
+		}
+		if cfg.User != nil {
+			record.User = cfg.User(c)
+		}
+		cfg.Record(record)
+	}
+}
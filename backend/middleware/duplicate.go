@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DuplicateHeader 判定为重复提交时的响应头，值为距首次提交的时间
+const DuplicateHeader = "X-Duplicate-Submission"
+
+// duplicatesTotal 检测到的重复提交次数，通过 expvar 导出
+var duplicatesTotal = expvar.NewInt("duplicate_submissions_total")
+
+// DuplicatePolicy 检测到重复提交时的处理方式
+type DuplicatePolicy string
+
+const (
+	// DuplicateWarn 照常处理，只在响应头中标记
+	DuplicateWarn DuplicatePolicy = "warn"
+	// DuplicateReject 返回 409，不执行处理函数
+	DuplicateReject DuplicatePolicy = "reject"
+)
+
+// DuplicateConfig 重复提交检测配置
+type DuplicateConfig struct {
+	Window time.Duration             // 相同请求体在该时间内再次提交视为重复
+	Policy DuplicatePolicy           // 默认 DuplicateWarn
+	Key    func(*gin.Context) string // 区分提交者，通常为请求用户，可为nil
+}
+
+// DuplicateGuard 检测短时间内重复提交的相同请求体，防止前端双击等造成重复执行批次
+// 请求体为 JSON 时按规范化后的内容（键排序、去除空白）计算哈希，格式不同但内容相同的请求同样视为重复；
+// 首次请求失败（状态码 >= 400）时清除记录，客户端修正后或限流后重试不受影响。
+// 每次调用返回独立的检测器，记录只保存在当前实例的内存中
+func DuplicateGuard(cfg DuplicateConfig) gin.HandlerFunc {
+	g := &duplicateGuard{window: cfg.Window, seen: make(map[string]submission)}
+
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body.Close()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("请求体超过 %d 字节", tooLarge.Limit)})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败"})
+			return
+		}
+		// 放回请求体供处理函数绑定
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		user := ""
+		if cfg.Key != nil {
+			user = cfg.Key(c)
+		}
+		key := c.Request.Method + " " + c.FullPath() + "\x00" + user + "\x00" + payloadHash(body)
+
+		id, age, duplicate := g.check(key, time.Now())
+		if duplicate {
+			duplicatesTotal.Add(1)
+			c.Header(DuplicateHeader, age.Round(time.Millisecond).String())
+			if cfg.Policy == DuplicateReject {
+				retryAfter := int(math.Ceil((cfg.Window - age).Seconds()))
+				c.Header("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+					"error": fmt.Sprintf("相同的请求已在 %s 前提交，请勿重复提交", age.Round(time.Millisecond)),
+				})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		c.Next()
+		if c.Writer.Status() >= http.StatusBadRequest {
+			g.forget(key, id)
+		}
+	}
+}
+
+// submission 窗口内首次提交的记录
+type submission struct {
+	id   uint64
+	time time.Time
+}
+
+type duplicateGuard struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]submission
+	nextID    uint64
+	lastPrune time.Time
+}
+
+// check 窗口内已有相同提交时返回距首次提交的时间，否则登记本次提交并返回其编号
+func (g *duplicateGuard) check(key string, now time.Time) (uint64, time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	// 定期清理过期记录，避免内存随请求数增长
+	if now.Sub(g.lastPrune) > g.window {
+		for k, s := range g.seen {
+			if now.Sub(s.time) >= g.window {
+				delete(g.seen, k)
+			}
+		}
+		g.lastPrune = now
+	}
+
+	if s, ok := g.seen[key]; ok && now.Sub(s.time) < g.window {
+		return 0, now.Sub(s.time), true
+	}
+	g.nextID++
+	g.seen[key] = submission{id: g.nextID, time: now}
+	return g.nextID, 0, false
+}
+
+// forget 清除本次提交的记录，期间被更新的提交登记的记录不受影响
+func (g *duplicateGuard) forget(key string, id uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if s, ok := g.seen[key]; ok && s.id == id {
+		delete(g.seen, key)
+	}
+}
+
+// payloadHash 计算请求体的哈希，JSON 先规范化，使键顺序和空白不同的相同内容得到相同的哈希
+func payloadHash(body []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err == nil {
+		if canonical, err := json.Marshal(value); err == nil {
+			body = canonical
+		}
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
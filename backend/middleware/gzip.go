@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gzip 响应压缩中间件
+// 客户端声明支持 gzip 时压缩响应体；流式接口（SSE、WebSocket、NDJSON）和 skipPaths 前缀下的路径不压缩，
+// 以免缓冲破坏逐条推送
+func Gzip(level int, skipPaths ...string) gin.HandlerFunc {
+	pool := sync.Pool{
+		New: func() interface{} {
+			writer, _ := gzip.NewWriterLevel(io.Discard, level)
+			return writer
+		},
+	}
+
+	return func(c *gin.Context) {
+		if !shouldCompress(c.Request, skipPaths) {
+			c.Next()
+			return
+		}
+
+		writer := &gzipWriter{ResponseWriter: c.Writer, pool: &pool}
+		c.Writer = writer
+		c.Header("Vary", "Accept-Encoding")
+		defer writer.close()
+
+		c.Next()
+	}
+}
+
+// shouldCompress 判断请求是否需要压缩响应
+func shouldCompress(req *http.Request, skipPaths []string) bool {
+	if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+		return false
+	}
+	accept := req.Header.Get("Accept")
+	if req.Header.Get("Upgrade") != "" || strings.Contains(accept, "text/event-stream") || strings.Contains(accept, "application/x-ndjson") {
+		return false
+	}
+	// 范围请求返回的是部分内容，压缩后偏移量就不对了
+	if req.Header.Get("Range") != "" {
+		return false
+	}
+	for _, prefix := range skipPaths {
+		if strings.HasPrefix(req.URL.Path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// gzipWriter 在首次写入响应体时才开始压缩，没有响应体的响应（如204、304）保持原样
+type gzipWriter struct {
+	gin.ResponseWriter
+	pool *sync.Pool
+	gz   *gzip.Writer
+}
+
+func (w *gzipWriter) start() {
+	if w.gz != nil {
+		return
+	}
+	header := w.Header()
+	// 已经编码过的内容不再压缩
+	if header.Get("Content-Encoding") != "" {
+		return
+	}
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	w.gz = w.pool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	w.start()
+	if w.gz == nil {
+		return w.ResponseWriter.Write(data)
+	}
+	return w.gz.Write(data)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 先刷出已压缩的数据再刷新底层连接
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipWriter) close() {
+	if w.gz == nil {
+		return
+	}
+	w.gz.Close()
+	w.gz.Reset(io.Discard)
+	w.pool.Put(w.gz)
+	w.gz = nil
+}
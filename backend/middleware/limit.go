@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LimitConfig 路由级并发限制配置
+type LimitConfig struct {
+	Max   int           // 同时执行的请求数上限
+	Queue int           // 等待执行的请求数上限，超出时直接返回429，0表示不排队
+	Wait  time.Duration // 排队的最长时间，超时返回429，0表示一直等到客户端断开
+	// MaxBodyBytes 取得名额后请求体的字节数上限，超出时读取请求体失败（*http.MaxBytesError），0表示不限
+	MaxBodyBytes int64
+}
+
+// ConcurrencyLimit 限制同一路由同时执行的处理函数数量，与任务级的并发限制相互独立
+// 超出上限的请求排队等待，队列已满或等待超时返回 429 并带 Retry-After，避免上传风暴等场景耗尽内存。
+// 应注册在读取请求体的中间件（重复提交检测、接口定义校验等）之前，排队和被拒绝的请求不会先缓冲整个请求体。
+// 每次调用返回独立的限制器，需要多个路由共享上限时复用同一个返回值
+func ConcurrencyLimit(cfg LimitConfig) gin.HandlerFunc {
+	if cfg.Max < 1 {
+		cfg.Max = 1
+	}
+	slots := make(chan struct{}, cfg.Max)
+	var waiting int64
+
+	retryAfter := "1"
+	if cfg.Wait > time.Second {
+		retryAfter = strconv.Itoa(int(cfg.Wait / time.Second))
+	}
+	reject := func(c *gin.Context, message string) {
+		c.Header("Retry-After", retryAfter)
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message})
+	}
+
+	return func(c *gin.Context) {
+		select {
+		case slots <- struct{}{}:
+		default:
+			// 没有空闲名额，进入队列
+			if atomic.AddInt64(&waiting, 1) > int64(cfg.Queue) {
+				atomic.AddInt64(&waiting, -1)
+				reject(c, "请求过多，请稍后重试")
+				return
+			}
+
+			var timeout <-chan time.Time
+			if cfg.Wait > 0 {
+				timer := time.NewTimer(cfg.Wait)
+				defer timer.Stop()
+				timeout = timer.C
+			}
+
+			select {
+			case slots <- struct{}{}:
+				atomic.AddInt64(&waiting, -1)
+			case <-timeout:
+				atomic.AddInt64(&waiting, -1)
+				reject(c, "排队等待超时，请稍后重试")
+				return
+			case <-c.Request.Context().Done():
+				atomic.AddInt64(&waiting, -1)
+				c.Abort()
+				return
+			}
+		}
+		defer func() { <-slots }()
+
+		if cfg.MaxBodyBytes > 0 && c.Request.Body != nil {
+			c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxBodyBytes)
+		}
+		c.Next()
+	}
+}
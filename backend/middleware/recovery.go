@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IncidentHeader 返回给调用方的事故ID响应头
+const IncidentHeader = "X-Incident-ID"
+
+// panicsTotal 处理函数 panic 的累计次数，通过 expvar 导出
+var panicsTotal = expvar.NewInt("http_panics_total")
+
+// Recovery 替代 gin 默认的恢复中间件
+// 处理函数 panic 时生成事故ID，连同请求信息和调用栈写入日志，计入 http_panics_total，
+// 并在响应头和 500 响应体中返回事故ID，便于根据用户反馈的ID定位日志
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// net/http 约定的中止信号，交给 net/http 处理
+			if err, ok := rec.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(rec)
+			}
+
+			id := newIncidentID()
+			panicsTotal.Add(1)
+			log.Printf("[PANIC] incident=%s %s %s user_agent=%q: %v\n%s",
+				id, c.Request.Method, c.Request.URL.Path, c.Request.UserAgent(), rec, debug.Stack())
+
+			if c.Writer.Written() {
+				// 响应已经开始发送，只能中断
+				c.Abort()
+				return
+			}
+			c.Header(IncidentHeader, id)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error":       "服务器内部错误，请携带事故ID联系管理员",
+				"incident_id": id,
+			})
+		}()
+		c.Next()
+	}
+}
+
+// newIncidentID 生成事故ID，格式为 时间戳-随机串，按时间排序即可对应日志顺序
+func newIncidentID() string {
+	buf := make([]byte, 4)
+	rand.Read(buf)
+	return fmt.Sprintf("%s-%s", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(buf))
+}
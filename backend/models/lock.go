@@ -0,0 +1,165 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLockHeld 锁已被其他实例持有
+var ErrLockHeld = errors.New("锁已被其他实例持有")
+
+// 锁名称
+const (
+	LockMigrations = "migrations"
+	LockArchiver   = "artifact-archiver"
+	LockRetention  = "retention-janitor"
+)
+
+// DistributedLock SQLite 下使用的锁表，过期的锁可以被其他实例抢占
+type DistributedLock struct {
+	Name      string    `json:"name" gorm:"primarykey;size:100"`
+	Owner     string    `json:"owner" gorm:"size:100;not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LockManager 基于数据库的分布式锁，保证多实例部署时维护任务不会并发执行
+// PostgreSQL 使用会话级 advisory lock，SQLite 使用锁表加过期时间
+type LockManager struct {
+	DB     *gorm.DB
+	Driver string
+	Owner  string        // 当前实例标识
+	TTL    time.Duration // 锁表中锁的有效期，持有期间按 TTL/3 续期
+	Poll   time.Duration // Lock 等待时的轮询间隔
+}
+
+// NewLockManager 创建锁管理器
+func NewLockManager(db *gorm.DB, driver string) *LockManager {
+	host, _ := os.Hostname()
+	return &LockManager{
+		DB:     db,
+		Driver: driver,
+		Owner:  fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+		TTL:    time.Minute,
+		Poll:   time.Second,
+	}
+}
+
+// TryLock 尝试获取锁，锁被占用时返回 ErrLockHeld；成功时返回释放函数
+func (m *LockManager) TryLock(ctx context.Context, name string) (func(), error) {
+	if m.Driver == "postgres" {
+		return m.tryAdvisoryLock(ctx, name)
+	}
+	return m.tryTableLock(ctx, name)
+}
+
+// Lock 阻塞直到获取锁或上下文取消
+func (m *LockManager) Lock(ctx context.Context, name string) (func(), error) {
+	for {
+		release, err := m.TryLock(ctx, name)
+		if !errors.Is(err, ErrLockHeld) {
+			return release, err
+		}
+
+		select {
+		case <-time.After(m.Poll):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// tryAdvisoryLock 在独占的连接上获取 advisory lock，连接关闭时锁自动释放
+func (m *LockManager) tryAdvisoryLock(ctx context.Context, name string) (func(), error) {
+	sqlDB, err := m.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock(hashtext($1))", name).Scan(&locked); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !locked {
+		conn.Close()
+		return nil, ErrLockHeld
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			unlockAdvisory(conn, name)
+		})
+	}, nil
+}
+
+func unlockAdvisory(conn *sql.Conn, name string) {
+	defer conn.Close()
+	conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", name)
+}
+
+// tryTableLock 插入锁记录，记录已存在且未过期时获取失败
+func (m *LockManager) tryTableLock(ctx context.Context, name string) (func(), error) {
+	now := time.Now()
+	lock := DistributedLock{
+		Name:      name,
+		Owner:     m.Owner,
+		ExpiresAt: now.Add(m.TTL),
+		CreatedAt: now,
+	}
+
+	result := m.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"owner", "expires_at", "created_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "distributed_locks.expires_at < ?", Vars: []interface{}{now}},
+		}},
+	}).Create(&lock)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrLockHeld
+	}
+
+	// 持有期间定期续期，避免长时间的维护任务被其他实例抢占
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(m.TTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.DB.Model(&DistributedLock{}).
+					Where("name = ? AND owner = ?", name, m.Owner).
+					Update("expires_at", time.Now().Add(m.TTL))
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-done
+			m.DB.Where("name = ? AND owner = ?", name, m.Owner).Delete(&DistributedLock{})
+		})
+	}, nil
+}
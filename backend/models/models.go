@@ -1,8 +1,12 @@
 package models
 
 import (
+	"context"
+	"fmt"
+	"os"
 	"time"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -10,6 +14,7 @@ import (
 // Order 订单模型
 type Order struct {
 	ID          uint       `json:"id" gorm:"primarykey"`
+	JobID       string     `json:"job_id" gorm:"size:64;index"` // 处理该订单的批量任务
 	CustomerID  string     `json:"customer_id" gorm:"size:100;not null"`
 	ProductName string     `json:"product_name" gorm:"size:200;not null"`
 	Quantity    int        `json:"quantity" gorm:"not null"`
@@ -23,6 +28,7 @@ type Order struct {
 // APICall API调用记录
 type APICall struct {
 	ID           uint      `json:"id" gorm:"primarykey"`
+	JobID        string    `json:"job_id" gorm:"size:64;index"` // 发出该调用的批量任务
 	URL          string    `json:"url" gorm:"size:500;not null"`
 	Method       string    `json:"method" gorm:"size:10;not null"`
 	Status       string    `json:"status" gorm:"size:50;default:'pending'"`
@@ -36,47 +42,319 @@ type APICall struct {
 
 // FileTask 文件处理任务
 type FileTask struct {
-	ID          uint       `json:"id" gorm:"primarykey"`
-	FileName    string     `json:"file_name" gorm:"size:255;not null"`
-	FilePath    string     `json:"file_path" gorm:"size:500;not null"`
-	FileSize    int64      `json:"file_size"`
-	Status      string     `json:"status" gorm:"size:50;default:'pending'"`
-	ProcessType string     `json:"process_type" gorm:"size:50;not null"` // compress, resize, convert等
-	Result      string     `json:"result" gorm:"type:text"`
-	Error       string     `json:"error" gorm:"type:text"`
-	ProcessedAt *time.Time `json:"processed_at"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
+	ID           uint   `json:"id" gorm:"primarykey"`
+	FileName     string `json:"file_name" gorm:"size:255;not null"`
+	OriginalName string `json:"original_name" gorm:"size:255;index;uniqueIndex:idx_file_version"` // 上传时的原始文件名，同一用户的同名文件按版本区分
+	Owner        string `json:"owner" gorm:"size:100;index;uniqueIndex:idx_file_version"`
+	Version      int    `json:"version" gorm:"default:1;uniqueIndex:idx_file_version"`
+	FilePath     string `json:"file_path" gorm:"size:500;not null"`
+	FileSize     int64  `json:"file_size"`
+	MimeType     string `json:"mime_type" gorm:"size:100"` // 上传时嗅探到的内容类型
+	Checksum     string `json:"checksum" gorm:"size:64"`   // 分块SHA-256校验和
+	ChunkHashes  string `json:"-" gorm:"type:text"`        // 逗号分隔的块摘要，用于定位损坏的块
+	// ScanStatus 病毒扫描状态：pending、clean、infected、failed，未启用扫描时为空
+	ScanStatus    string     `json:"scan_status,omitempty" gorm:"size:20;index"`
+	ScanSignature string     `json:"scan_signature,omitempty" gorm:"size:255"` // 命中的威胁特征名
+	ScannedAt     *time.Time `json:"scanned_at,omitempty"`
+	Status        string     `json:"status" gorm:"size:50;default:'pending'"`
+	ProcessType   string     `json:"process_type" gorm:"size:50;not null"` // compress, resize, convert等
+	Tags          string     `json:"tags" gorm:"size:500"`                 // 逗号分隔的标签
+	Description   string     `json:"description" gorm:"type:text"`
+	Result        string     `json:"result" gorm:"type:text"`
+	Error         string     `json:"error" gorm:"type:text"`
+	ProcessedAt   *time.Time `json:"processed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
 // BatchJobResult 批量任务结果
 type BatchJobResult struct {
+	ID             uint       `json:"id" gorm:"primarykey"`
+	JobID          string     `json:"job_id" gorm:"size:64;uniqueIndex"`
+	JobType        string     `json:"job_type" gorm:"size:50;not null"` // order, api, file
+	Owner          string     `json:"owner" gorm:"size:100;index"`
+	TotalTasks     int        `json:"total_tasks"`
+	CompletedTasks int        `json:"completed_tasks"` // 已结束的任务数（含成功、失败和取消）
+	SuccessTasks   int        `json:"success_tasks"`
+	FailedTasks    int        `json:"failed_tasks"`
+	CancelledTasks int        `json:"cancelled_tasks"`
+	RemainingTasks int        `json:"remaining_tasks"`
+	Progress       float64    `json:"progress"` // 完成百分比
+	Duration       int64      `json:"duration"` // 毫秒
+	Status         string     `json:"status" gorm:"size:50;default:'running'"`
+	StartTime      time.Time  `json:"start_time"`
+	EndTime        *time.Time `json:"end_time"`
+	Seed           int64      `json:"seed"`                               // 批次的随机种子，用于复现抽样和重试等待时间
+	Config         string     `json:"config" gorm:"type:text"`            // 执行时的配置快照，JSON
+	CodeVersion    string     `json:"code_version" gorm:"size:64"`        // 执行时的代码版本
+	Metrics        string     `json:"metrics" gorm:"type:text"`           // 结束时记录的指标（耗时、吞吐量、失败率等），JSON
+	TemplateID     uint       `json:"template_id,omitempty" gorm:"index"` // 由批次模板执行时的模板ID，按模板的保留策略清理
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`               // 结果（产出物和明细）的过期时间，为空时一直保留
+	Instance       string     `json:"instance" gorm:"size:100;index"`     // 执行该批次的服务实例，重启后只恢复本实例未结束的批次
+	Resumes        int        `json:"resumes,omitempty"`                  // 服务重启后恢复执行的次数
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// JobCheckpoint 执行中批次的任务清单，服务意外退出后据此从剩余的任务恢复执行；批次结束后删除
+type JobCheckpoint struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	JobID     string    `json:"job_id" gorm:"size:64;uniqueIndex"`
+	Tasks     string    `json:"-" gorm:"type:text"` // 任务清单，JSON 数组，按任务序号
+	Request   string    `json:"-" gorm:"type:text"` // 恢复执行所需的请求信息（执行选项、配置快照等），JSON
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// JobCheckpointTask 检查点中已结束的任务，与任务进度一起定期保存；恢复执行时跳过这些任务
+type JobCheckpointTask struct {
+	ID        uint   `json:"id" gorm:"primarykey"`
+	JobID     string `json:"job_id" gorm:"size:64;uniqueIndex:idx_checkpoint_task"`
+	TaskIndex int    `json:"task_index" gorm:"uniqueIndex:idx_checkpoint_task"`
+	Result    string `json:"-" gorm:"type:text"` // 任务结果，JSON
+}
+
+// JobTransition 任务的一次状态变更，用于排查和审计；随任务记录一起按保留策略删除
+type JobTransition struct {
+	ID     uint      `json:"-" gorm:"primarykey"`
+	JobID  string    `json:"-" gorm:"size:64;index"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Actor  string    `json:"actor" gorm:"size:100"` // 触发变更的用户，系统触发时为 system
+	Reason string    `json:"reason,omitempty" gorm:"size:255"`
+	Time   time.Time `json:"time"`
+}
+
+// User 用户账号
+type User struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	Username     string    `json:"username" gorm:"size:100;uniqueIndex;not null"`
+	PasswordHash string    `json:"-" gorm:"size:100;not null"`         // bcrypt
+	Role         string    `json:"role" gorm:"size:20;default:'user'"` // user, admin
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Session 登录会话，只保存令牌的SHA-256摘要
+type Session struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	TokenHash  string    `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	UserID     uint      `json:"user_id" gorm:"index;not null"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"index"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Tenant 租户
+type Tenant struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	Name        string    `json:"name" gorm:"size:100;uniqueIndex;not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// APIKey 租户的API密钥，只保存SHA-256摘要，明文只在创建时返回一次
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	TenantID   uint       `json:"tenant_id" gorm:"index;not null"`
+	Name       string     `json:"name" gorm:"size:100"`
+	Prefix     string     `json:"prefix" gorm:"size:16"` // 密钥前缀，用于在列表中辨认
+	KeyHash    string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Quota 租户的资源配额
+type Quota struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	TenantID  uint      `json:"tenant_id" gorm:"uniqueIndex:idx_quota_tenant_resource;not null"`
+	Resource  string    `json:"resource" gorm:"size:50;uniqueIndex:idx_quota_tenant_resource;not null"` // order_tasks, api_tasks, file_tasks, storage_bytes
+	Limit     int64     `json:"limit"`
+	Period    string    `json:"period" gorm:"size:20;default:'day'"` // day, month, total
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookSubscription 租户订阅的任务事件回调
+type WebhookSubscription struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	TenantID  uint      `json:"tenant_id" gorm:"index;not null"`
+	URL       string    `json:"url" gorm:"size:500;not null"`
+	Events    string    `json:"events" gorm:"size:200"` // 逗号分隔，如 job.completed,job.cancelled
+	Secret    string    `json:"-" gorm:"size:100"`      // 用于签名回调请求
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TenantKey 租户的结果数据密钥，以主密钥加密后保存；轮换后旧版本保留，用于解密轮换前写入的结果
+type TenantKey struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	TenantID   uint       `json:"tenant_id" gorm:"uniqueIndex:idx_tenant_key_version;not null"` // 0 表示未通过API密钥标识租户的请求
+	Version    int        `json:"version" gorm:"uniqueIndex:idx_tenant_key_version;not null"`
+	WrappedKey string     `json:"-" gorm:"type:text;not null"` // 主密钥加密后的数据密钥，base64
+	RetiredAt  *time.Time `json:"retired_at"`                  // 轮换的时间，为空表示当前使用的版本
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// AccessLog 抽样记录的访问日志
+type AccessLog struct {
+	ID         uint      `json:"id" gorm:"primarykey"`
+	Method     string    `json:"method" gorm:"size:10"`
+	Path       string    `json:"path" gorm:"size:500"`
+	Route      string    `json:"route" gorm:"size:200;index"` // 匹配的路由模板，如 /api/jobs/:id
+	Status     int       `json:"status" gorm:"index"`
+	LatencyMs  int64     `json:"latency_ms"`
+	Size       int       `json:"size"` // 响应体字节数
+	User       string    `json:"user" gorm:"column:username;size:100;index"`
+	ClientIP   string    `json:"client_ip" gorm:"size:64"`
+	SampleRate float64   `json:"sample_rate"` // 记录时的抽样率，1 表示必录（错误或慢请求）
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// OrderBatchRollup 每次订单批量处理的汇总
+type OrderBatchRollup struct {
+	ID               uint      `json:"id" gorm:"primarykey"`
+	JobID            string    `json:"job_id" gorm:"size:64;uniqueIndex"`
+	TotalOrders      int       `json:"total_orders"`
+	SuccessOrders    int       `json:"success_orders"`
+	FailedOrders     int       `json:"failed_orders"`
+	Units            int       `json:"units"`
+	Revenue          float64   `json:"revenue"`
+	UnitsByProduct   string    `json:"-" gorm:"type:text"` // JSON，商品名 -> 件数
+	FailuresByReason string    `json:"-" gorm:"type:text"` // JSON，失败原因 -> 订单数
+	Duration         int64     `json:"duration"`           // 毫秒
+	CreatedAt        time.Time `json:"created_at" gorm:"index"`
+}
+
+// JobArtifact 任务产出物（批量结果文件）
+type JobArtifact struct {
 	ID           uint       `json:"id" gorm:"primarykey"`
-	JobType      string     `json:"job_type" gorm:"size:50;not null"` // order, api, file
-	TotalTasks   int        `json:"total_tasks"`
-	SuccessTasks int        `json:"success_tasks"`
-	FailedTasks  int        `json:"failed_tasks"`
-	Duration     int64      `json:"duration"` // 毫秒
-	Status       string     `json:"status" gorm:"size:50;default:'running'"`
-	StartTime    time.Time  `json:"start_time"`
-	EndTime      *time.Time `json:"end_time"`
+	JobID        string     `json:"job_id" gorm:"size:64;uniqueIndex"`
+	JobType      string     `json:"job_type" gorm:"size:50"`
+	Path         string     `json:"path" gorm:"size:500"` // 热存储路径，归档后为空
+	ArchiveKey   string     `json:"archive_key" gorm:"size:500"`
+	Size         int64      `json:"size"`
+	StorageClass string     `json:"storage_class" gorm:"size:20;default:'hot';index"` // hot, cold
+	TemplateID   uint       `json:"template_id,omitempty" gorm:"index"`               // 由批次模板执行时的模板ID，按模板的保留策略清理
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" gorm:"index"`                // 过期时间，到期后由保留清理删除，为空时一直保留
+	ArchivedAt   *time.Time `json:"archived_at"`
+	RestoredAt   *time.Time `json:"restored_at"`
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
-// InitDB 初始化数据库
-func InitDB() (*gorm.DB, error) {
-	// 使用SQLite数据库
-	db, err := gorm.Open(sqlite.Open("concurrency_app.db"), &gorm.Config{})
+// BatchTemplate 保存的批次模板：批量接口的请求体（任务列表和执行选项），可以反复执行
+type BatchTemplate struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	Owner       string    `json:"owner" gorm:"size:100;uniqueIndex:idx_template_owner_name;not null"`
+	Name        string    `json:"name" gorm:"size:100;uniqueIndex:idx_template_owner_name;not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	Target      string    `json:"target" gorm:"size:50;not null"` // orders, apis, files, pipeline
+	Request     string    `json:"-" gorm:"type:text;not null"`    // 请求体，JSON
+	SummaryDays int       `json:"summary_retention_days"`         // 执行的批次的任务记录（汇总）保留天数，0表示一直保留
+	DetailDays  int       `json:"detail_retention_days"`          // 产出物等明细保留天数，0表示与任务记录一同清理
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DBConfig 数据库配置，从环境变量读取
+type DBConfig struct {
+	Driver     string // DB_DRIVER：sqlite（默认）或 postgres
+	DSN        string // DB_DSN：主库连接串，sqlite 时为数据库文件路径
+	ReplicaDSN string // DB_REPLICA_DSN：只读副本连接串，仅 postgres 有效
+}
+
+// LoadDBConfig 从环境变量读取数据库配置
+func LoadDBConfig() DBConfig {
+	cfg := DBConfig{
+		Driver:     os.Getenv("DB_DRIVER"),
+		DSN:        os.Getenv("DB_DSN"),
+		ReplicaDSN: os.Getenv("DB_REPLICA_DSN"),
+	}
+	if cfg.Driver == "" {
+		cfg.Driver = "sqlite"
+	}
+	if cfg.Driver == "sqlite" && cfg.DSN == "" {
+		cfg.DSN = "concurrency_app.db"
+	}
+	return cfg
+}
+
+// migrateTimeout 等待其他实例完成迁移的最长时间
+const migrateTimeout = 5 * time.Minute
+
+// migrate 持有迁移锁执行自动迁移
+func migrate(db *gorm.DB, driver string) error {
+	// SQLite 的锁表本身需要先建好
+	if driver != "postgres" {
+		if err := db.AutoMigrate(&DistributedLock{}); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+
+	release, err := NewLockManager(db, driver).Lock(ctx, LockMigrations)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("获取迁移锁失败: %v", err)
 	}
+	defer release()
 
-	// 自动迁移模式
-	err = db.AutoMigrate(&Order{}, &APICall{}, &FileTask{}, &BatchJobResult{})
+	return db.AutoMigrate(&Order{}, &APICall{}, &FileTask{}, &BatchJobResult{}, &JobArtifact{}, &OrderBatchRollup{}, &DistributedLock{}, &User{}, &Session{},
+		&Tenant{}, &APIKey{}, &Quota{}, &WebhookSubscription{}, &TenantKey{}, &AccessLog{}, &BatchTemplate{},
+		&JobCheckpoint{}, &JobCheckpointTask{}, &JobTransition{})
+}
+
+// dialector 根据驱动创建连接
+func (cfg DBConfig) dialector(dsn string) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "sqlite":
+		return sqlite.Open(dsn), nil
+	case "postgres":
+		if dsn == "" {
+			return nil, fmt.Errorf("使用 postgres 时必须设置 DB_DSN")
+		}
+		return postgres.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", cfg.Driver)
+	}
+}
+
+// InitDB 初始化数据库，返回主库和只读副本
+// 未配置只读副本时，副本与主库为同一个连接
+func InitDB(cfg DBConfig) (primary, replica *gorm.DB, err error) {
+	dialector, err := cfg.dialector(cfg.DSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	primary, err = gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 自动迁移模式，多实例同时启动时通过迁移锁串行执行
+	if err := migrate(primary, cfg.Driver); err != nil {
+		return nil, nil, err
+	}
+
+	// SQLite 是单文件数据库，不支持只读副本
+	if cfg.ReplicaDSN == "" || cfg.Driver != "postgres" {
+		return primary, primary, nil
+	}
+
+	dialector, err = cfg.dialector(cfg.ReplicaDSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	replica, err = gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
-		return nil, err
+		return nil, nil, fmt.Errorf("连接只读副本失败: %v", err)
 	}
 
-	return db, nil
+	return primary, replica, nil
 }
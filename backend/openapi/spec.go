@@ -0,0 +1,442 @@
+// Package openapi 根据路由注册时声明的接口定义生成 OpenAPI 3 文档，
+// 并在运行时按同一份定义校验请求，保证文档与实际行为一致
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Schema JSON Schema 的子集，足以描述本项目的请求与响应
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// Param 查询参数或路径参数
+type Param struct {
+	Name        string
+	In          string // query、path 或 header
+	Description string
+	Required    bool
+	Schema      *Schema
+}
+
+// Query 声明字符串查询参数
+func Query(name, description string) Param {
+	return Param{Name: name, In: "query", Description: description, Schema: &Schema{Type: "string"}}
+}
+
+// QueryInt 声明整数查询参数，min/max 为 nil 时不限制
+func QueryInt(name, description string, min, max *float64) Param {
+	return Param{Name: name, In: "query", Description: description, Schema: &Schema{Type: "integer", Minimum: min, Maximum: max}}
+}
+
+// QueryEnum 声明取值受限的查询参数
+func QueryEnum(name, description string, values ...string) Param {
+	return Param{Name: name, In: "query", Description: description, Schema: &Schema{Type: "string", Enum: values}}
+}
+
+// Float 返回指向 v 的指针，便于填写 Minimum/Maximum
+func Float(v float64) *float64 { return &v }
+
+// Operation 一个接口的定义
+type Operation struct {
+	Summary     string
+	Tags        []string
+	Params      []Param
+	Body        interface{} // JSON 请求体示例类型，如 LoginRequest{}，nil 表示无 JSON 请求体
+	ContentType string      // 非 JSON 请求体的类型，如 multipart/form-data，仅用于文档
+	Responses   map[int]string
+}
+
+// Spec 收集接口定义并生成文档
+type Spec struct {
+	Title   string
+	Version string
+
+	mu         sync.RWMutex
+	operations map[string]*compiled // key: METHOD + " " + gin 路由
+}
+
+// compiled 预先生成请求体 Schema 的接口定义
+type compiled struct {
+	path   string
+	method string
+	op     Operation
+	body   *Schema
+}
+
+// NewSpec 创建接口定义集合
+func NewSpec(title, version string) *Spec {
+	return &Spec{Title: title, Version: version, operations: make(map[string]*compiled)}
+}
+
+// add 登记接口定义
+func (s *Spec) add(method, path string, op Operation) {
+	c := &compiled{path: path, method: method, op: op}
+	if op.Body != nil {
+		c.body = SchemaOf(op.Body)
+	}
+	for _, name := range pathParams(path) {
+		if !hasParam(op.Params, name, "path") {
+			c.op.Params = append(c.op.Params, Param{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	s.mu.Lock()
+	s.operations[method+" "+path] = c
+	s.mu.Unlock()
+}
+
+// lookup 按请求方法和 gin 路由查找接口定义
+func (s *Spec) lookup(method, path string) *compiled {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.operations[method+" "+path]
+}
+
+// Document 生成 OpenAPI 3 文档
+func (s *Spec) Document() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0, len(s.operations))
+	for key := range s.operations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	paths := make(map[string]map[string]interface{})
+	for _, key := range keys {
+		c := s.operations[key]
+		path := openAPIPath(c.path)
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		paths[path][strings.ToLower(c.method)] = c.document()
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   s.Title,
+			"version": s.Version,
+		},
+		"paths": paths,
+	}
+}
+
+// Handler 以 JSON 返回 OpenAPI 文档
+func (s *Spec) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.Document())
+	}
+}
+
+func (c *compiled) document() map[string]interface{} {
+	doc := map[string]interface{}{
+		"summary":     c.op.Summary,
+		"operationId": operationID(c.method, c.path),
+	}
+	if len(c.op.Tags) > 0 {
+		doc["tags"] = c.op.Tags
+	}
+
+	if len(c.op.Params) > 0 {
+		params := make([]map[string]interface{}, 0, len(c.op.Params))
+		for _, p := range c.op.Params {
+			param := map[string]interface{}{
+				"name":     p.Name,
+				"in":       p.In,
+				"required": p.Required || p.In == "path",
+				"schema":   p.Schema,
+			}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			params = append(params, param)
+		}
+		doc["parameters"] = params
+	}
+
+	switch {
+	case c.body != nil:
+		doc["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": c.body},
+			},
+		}
+	case c.op.ContentType != "":
+		doc["requestBody"] = map[string]interface{}{
+			"content": map[string]interface{}{
+				c.op.ContentType: map[string]interface{}{"schema": &Schema{Type: "object"}},
+			},
+		}
+	}
+
+	responses := map[string]interface{}{}
+	for code, description := range c.op.Responses {
+		responses[strconv.Itoa(code)] = map[string]interface{}{"description": description}
+	}
+	if len(responses) == 0 {
+		responses["200"] = map[string]interface{}{"description": "成功"}
+	}
+	if c.body != nil || len(c.op.Params) > 0 {
+		if _, ok := responses["400"]; !ok {
+			responses["400"] = map[string]interface{}{"description": "请求不符合接口定义"}
+		}
+	}
+	doc["responses"] = responses
+	return doc
+}
+
+// Router 包装 gin.RouterGroup，注册路由的同时登记接口定义
+type Router struct {
+	group *gin.RouterGroup
+	spec  *Spec
+}
+
+// NewRouter 创建登记到 spec 的路由
+func NewRouter(group *gin.RouterGroup, spec *Spec) *Router {
+	return &Router{group: group, spec: spec}
+}
+
+// Group 创建子路由
+func (r *Router) Group(path string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{group: r.group.Group(path, handlers...), spec: r.spec}
+}
+
+// Handle 注册路由并登记接口定义
+// 按接口定义校验请求插在最后一个处理函数之前：校验会读取整个请求体，路由级中间件（如并发限制）应先于它执行
+func (r *Router) Handle(method, path string, op Operation, handlers ...gin.HandlerFunc) {
+	full := joinPath(r.group.BasePath(), path)
+	r.spec.add(method, full, op)
+	if n := len(handlers); n > 0 {
+		chain := make([]gin.HandlerFunc, 0, n+1)
+		chain = append(chain, handlers[:n-1]...)
+		handlers = append(chain, r.spec.validator(method, full), handlers[n-1])
+	}
+	r.group.Handle(method, path, handlers...)
+}
+
+// GET 注册 GET 路由
+func (r *Router) GET(path string, op Operation, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodGet, path, op, handlers...)
+}
+
+// POST 注册 POST 路由
+func (r *Router) POST(path string, op Operation, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodPost, path, op, handlers...)
+}
+
+// PUT 注册 PUT 路由
+func (r *Router) PUT(path string, op Operation, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodPut, path, op, handlers...)
+}
+
+// DELETE 注册 DELETE 路由
+func (r *Router) DELETE(path string, op Operation, handlers ...gin.HandlerFunc) {
+	r.Handle(http.MethodDelete, path, op, handlers...)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf 根据 Go 类型生成 Schema
+// 字段名取 json 标签，binding 标签中的 required、min、max、oneof 转换为对应约束
+func SchemaOf(v interface{}) *Schema {
+	return schemaOf(reflect.TypeOf(v))
+}
+
+func schemaOf(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+
+	var s *Schema
+	switch {
+	case t == timeType:
+		s = &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Bool:
+		s = &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = &Schema{Type: "integer"}
+		if t.Kind() >= reflect.Uint {
+			s.Minimum = Float(0)
+		}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = &Schema{Type: "number"}
+	case t.Kind() == reflect.String:
+		s = &Schema{Type: "string"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		// []byte 与 json.RawMessage：任意 JSON
+		s = &Schema{}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s = &Schema{Type: "array", Items: schemaOf(t.Elem())}
+		nullable = true
+	case t.Kind() == reflect.Map:
+		s = &Schema{Type: "object", AdditionalProperties: schemaOf(t.Elem())}
+		nullable = true
+	case t.Kind() == reflect.Struct:
+		s = structSchema(t)
+	default:
+		s = &Schema{}
+	}
+	s.Nullable = nullable && s.Type != ""
+	return s
+}
+
+func structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			embedded := schemaOf(field.Type)
+			for k, v := range embedded.Properties {
+				s.Properties[k] = v
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := schemaOf(field.Type)
+		if applyBinding(prop, field.Tag.Get("binding")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
+
+// jsonName 返回字段的 JSON 名称，json:"-" 的字段返回 false
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name := strings.Split(tag, ",")[0]
+	return name, true
+}
+
+// applyBinding 将 binding 标签转换为 Schema 约束，返回字段是否必填
+func applyBinding(s *Schema, binding string) bool {
+	required := false
+	for _, rule := range strings.Split(binding, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "oneof":
+			s.Enum = strings.Fields(value)
+		case "min", "max":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			setBound(s, key == "min", n)
+		}
+	}
+	return required
+}
+
+// setBound 按类型设置取值、长度或元素个数的上下限
+func setBound(s *Schema, min bool, n float64) {
+	count := int(n)
+	switch s.Type {
+	case "integer", "number":
+		if min {
+			s.Minimum = &n
+		} else {
+			s.Maximum = &n
+		}
+	case "string":
+		if min {
+			s.MinLength = &count
+		} else {
+			s.MaxLength = &count
+		}
+	case "array":
+		if min {
+			s.MinItems = &count
+		} else {
+			s.MaxItems = &count
+		}
+	}
+}
+
+// pathParams 返回 gin 路由中的路径参数名
+func pathParams(path string) []string {
+	var names []string
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			names = append(names, part[1:])
+		}
+	}
+	return names
+}
+
+func hasParam(params []Param, name, in string) bool {
+	for _, p := range params {
+		if p.Name == name && p.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+// openAPIPath 将 gin 路由 /jobs/:id 转换为 /jobs/{id}
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// operationID 由方法和路由生成唯一的操作ID，如 get_api_jobs_id
+func operationID(method, path string) string {
+	replacer := strings.NewReplacer("/", "_", ":", "", "*", "", "-", "_")
+	return strings.ToLower(method) + strings.TrimRight(replacer.Replace(path), "_")
+}
+
+func joinPath(base, path string) string {
+	if path == "" {
+		return base
+	}
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(path, "/")
+}
@@ -0,0 +1,231 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ValidationError 请求不符合接口定义
+type ValidationError struct {
+	Field   string // 出错的位置，如 body.orders[0].quantity、query.page
+	Message string
+	Err     error // 读取请求体失败时的原因，如 *http.MaxBytesError
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validator 按登记的接口定义校验查询参数和 JSON 请求体，不符合时返回400，请求体超出 http.MaxBytesReader 的上限时返回413
+// 由 Router 插在路由的最后一个处理函数之前，路由级中间件（如并发限制）先于读取请求体执行
+func (s *Spec) validator(method, path string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op := s.lookup(method, path)
+		if op == nil {
+			c.Next()
+			return
+		}
+
+		if err := op.validateRequest(c.Request); err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": "请求不符合接口定义: " + err.Error()})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (c *compiled) validateRequest(req *http.Request) error {
+	query := req.URL.Query()
+	for _, p := range c.op.Params {
+		if p.In != "query" {
+			continue
+		}
+		field := "query." + p.Name
+		if _, ok := query[p.Name]; !ok {
+			if p.Required {
+				return &ValidationError{Field: field, Message: "缺少必填参数"}
+			}
+			continue
+		}
+		if err := validateParam(p.Schema, query.Get(p.Name), field); err != nil {
+			return err
+		}
+	}
+
+	if c.body == nil {
+		return nil
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return &ValidationError{Field: "body", Message: "读取请求体失败", Err: err}
+	}
+	// 放回请求体供处理函数绑定
+	req.Body = io.NopCloser(bytes.NewReader(data))
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &ValidationError{Field: "body", Message: "缺少请求体"}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return &ValidationError{Field: "body", Message: "不是合法的 JSON"}
+	}
+	return validateValue(c.body, value, "body")
+}
+
+// validateParam 校验查询参数的字符串取值
+func validateParam(s *Schema, raw, field string) error {
+	switch s.Type {
+	case "integer":
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return &ValidationError{Field: field, Message: "应为整数"}
+		}
+		return checkRange(s, float64(n), field)
+	case "number":
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return &ValidationError{Field: field, Message: "应为数字"}
+		}
+		return checkRange(s, n, field)
+	case "boolean":
+		if _, err := strconv.ParseBool(raw); err != nil {
+			return &ValidationError{Field: field, Message: "应为 true 或 false"}
+		}
+	}
+	return checkString(s, raw, field)
+}
+
+// validateValue 校验解码后的 JSON 值，数字需以 json.Number 解码
+func validateValue(s *Schema, value interface{}, field string) error {
+	if s.Type == "" {
+		return nil
+	}
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return &ValidationError{Field: field, Message: "不能为 null"}
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(field, "对象")
+		}
+		for _, name := range s.Required {
+			if v, ok := obj[name]; !ok || v == nil {
+				return &ValidationError{Field: field + "." + name, Message: "缺少必填字段"}
+			}
+		}
+		for name, v := range obj {
+			prop := s.Properties[name]
+			if prop == nil {
+				prop = s.AdditionalProperties
+			}
+			if prop == nil {
+				continue
+			}
+			if err := validateValue(prop, v, field+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return typeError(field, "数组")
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			return &ValidationError{Field: field, Message: fmt.Sprintf("至少需要 %d 项", *s.MinItems)}
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			return &ValidationError{Field: field, Message: fmt.Sprintf("最多 %d 项", *s.MaxItems)}
+		}
+		for i, item := range items {
+			if err := validateValue(s.Items, item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+				return err
+			}
+		}
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			return typeError(field, "数字")
+		}
+		if s.Type == "integer" {
+			if _, err := num.Int64(); err != nil {
+				return typeError(field, "整数")
+			}
+		}
+		n, err := num.Float64()
+		if err != nil {
+			return typeError(field, "数字")
+		}
+		return checkRange(s, n, field)
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return typeError(field, "字符串")
+		}
+		return checkString(s, str, field)
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(field, "布尔值")
+		}
+	}
+	return nil
+}
+
+func checkRange(s *Schema, n float64, field string) error {
+	if s.Minimum != nil && n < *s.Minimum {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("不能小于 %v", *s.Minimum)}
+	}
+	if s.Maximum != nil && n > *s.Maximum {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("不能大于 %v", *s.Maximum)}
+	}
+	return nil
+}
+
+func checkString(s *Schema, str, field string) error {
+	length := utf8.RuneCountInString(str)
+	if s.MinLength != nil && length < *s.MinLength {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("长度不能小于 %d", *s.MinLength)}
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		return &ValidationError{Field: field, Message: fmt.Sprintf("长度不能大于 %d", *s.MaxLength)}
+	}
+	if len(s.Enum) > 0 {
+		for _, v := range s.Enum {
+			if v == str {
+				return nil
+			}
+		}
+		return &ValidationError{Field: field, Message: "取值应为 " + strings.Join(s.Enum, "、") + " 之一"}
+	}
+	return nil
+}
+
+func typeError(field, want string) error {
+	return &ValidationError{Field: field, Message: "应为" + want}
+}
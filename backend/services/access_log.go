@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// accessLogsDropped 缓冲区已满而丢弃的访问日志数
+var accessLogsDropped = expvar.NewInt("access_logs_dropped_total")
+
+// AccessLogQuery 访问日志查询条件
+type AccessLogQuery struct {
+	Route     string
+	User      string
+	MinStatus int       // 只查状态码不小于该值的记录，0表示不限制
+	Since     time.Time // 零值表示不限制
+	Limit     int
+}
+
+// AccessLogService 访问日志的异步批量写入与查询
+// 记录先进入缓冲通道，由 Run 按批写库，请求路径上不做数据库写入
+type AccessLogService struct {
+	DB            *gorm.DB
+	ReadDB        *gorm.DB      // 只读副本，查询走这里，未配置时使用主库
+	BatchSize     int           // 攒够多少条写一次
+	FlushInterval time.Duration // 不足一批时的最长等待
+	Retention     time.Duration // 保留时长，0表示不清理
+
+	entries chan models.AccessLog
+}
+
+// NewAccessLogService 创建访问日志服务，buffer 为缓冲的记录数
+func NewAccessLogService(db, readDB *gorm.DB, buffer int) *AccessLogService {
+	return &AccessLogService{
+		DB:            db,
+		ReadDB:        readDB,
+		BatchSize:     100,
+		FlushInterval: 2 * time.Second,
+		Retention:     7 * 24 * time.Hour,
+		entries:       make(chan models.AccessLog, buffer),
+	}
+}
+
+// Record 提交一条访问日志，缓冲区已满时丢弃，不阻塞请求
+func (s *AccessLogService) Record(entry models.AccessLog) {
+	select {
+	case s.entries <- entry:
+	default:
+		accessLogsDropped.Add(1)
+	}
+}
+
+// Run 批量写入访问日志并定期清理过期记录，ctx 取消时写完缓冲区后返回
+func (s *AccessLogService) Run(ctx context.Context) {
+	batch := make([]models.AccessLog, 0, s.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.DB.CreateInBatches(batch, s.BatchSize).Error; err != nil {
+			log.Printf("写入访问日志失败: %v", err)
+		}
+		batch = batch[:0]
+	}
+
+	ticker := time.NewTicker(s.FlushInterval)
+	defer ticker.Stop()
+	lastPrune := time.Now()
+
+	for {
+		select {
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) >= s.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			if s.Retention > 0 && time.Since(lastPrune) > time.Hour {
+				lastPrune = time.Now()
+				if err := s.DB.Where("created_at < ?", time.Now().Add(-s.Retention)).Delete(&models.AccessLog{}).Error; err != nil {
+					log.Printf("清理访问日志失败: %v", err)
+				}
+			}
+		case <-ctx.Done():
+			for {
+				select {
+				case entry := <-s.entries:
+					batch = append(batch, entry)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Recent 按时间倒序查询最近的访问日志
+func (s *AccessLogService) Recent(q AccessLogQuery) ([]models.AccessLog, error) {
+	query := readerDB(s.DB, s.ReadDB).Order("created_at DESC, id DESC").Limit(q.Limit)
+	if q.Route != "" {
+		query = query.Where("route = ?", q.Route)
+	}
+	if q.User != "" {
+		query = query.Where("username = ?", q.User)
+	}
+	if q.MinStatus > 0 {
+		query = query.Where("status >= ?", q.MinStatus)
+	}
+	if !q.Since.IsZero() {
+		query = query.Where("created_at >= ?", q.Since)
+	}
+
+	var logs []models.AccessLog
+	err := query.Find(&logs).Error
+	return logs, err
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUserExists 用户名已被注册
+	ErrUserExists = errors.New("用户名已存在")
+	// ErrInvalidUsername 用户名格式不正确
+	ErrInvalidUsername = errors.New("用户名只能包含字母、数字、下划线和连字符，长度 3-32")
+	// ErrWeakPassword 密码强度不足
+	ErrWeakPassword = errors.New("密码长度至少为 8 个字符")
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	// ErrSessionInvalid 会话不存在或已过期
+	ErrSessionInvalid = errors.New("会话不存在或已过期")
+)
+
+// 用户角色
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin" // 可访问 /api/admin 管理接口
+)
+
+// usernamePattern 合法的用户名
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+// AccountService 用户账号与登录会话
+type AccountService struct {
+	DB         *gorm.DB
+	SessionTTL time.Duration // 会话有效期，每次使用后顺延
+}
+
+// Register 注册新用户
+func (s *AccountService) Register(username, password string) (*models.User, error) {
+	if !usernamePattern.MatchString(username) {
+		return nil, ErrInvalidUsername
+	}
+	if len(password) < 8 {
+		return nil, ErrWeakPassword
+	}
+
+	var count int64
+	if err := s.DB.Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrUserExists
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	// 注册的用户都是普通用户，管理员由部署时的配置指定，见 EnsureAdmin
+	user := &models.User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         RoleUser,
+	}
+	if err := s.DB.Create(user).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// EnsureAdmin 确保 username 为管理员：用户不存在时以 password 创建，已存在时只提升为管理员、不修改密码。
+// 服务启动时按配置调用，多个实例同时调用时结果相同
+func (s *AccountService) EnsureAdmin(username, password string) (*models.User, error) {
+	user, err := s.Register(username, password)
+	switch {
+	case errors.Is(err, ErrInvalidUsername), errors.Is(err, ErrWeakPassword):
+		return nil, err
+	case err != nil:
+		// 用户已存在，或另一个实例同时创建了该用户（插入违反唯一索引）
+		user = &models.User{}
+		if lookupErr := s.DB.Where("username = ?", username).First(user).Error; lookupErr != nil {
+			if errors.Is(lookupErr, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			return nil, lookupErr
+		}
+	}
+	if err := s.DB.Model(user).Update("role", RoleAdmin).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login 校验密码并创建会话，返回会话令牌（只在此时返回明文）
+func (s *AccountService) Login(username, password string) (string, *models.Session, *models.User, error) {
+	var user models.User
+	err := s.DB.Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return "", nil, nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return "", nil, nil, ErrInvalidCredentials
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, nil, err
+	}
+	token := hex.EncodeToString(buf)
+
+	now := time.Now()
+	session := &models.Session{
+		TokenHash:  hashToken(token),
+		UserID:     user.ID,
+		ExpiresAt:  now.Add(s.SessionTTL),
+		LastSeenAt: now,
+	}
+	if err := s.DB.Create(session).Error; err != nil {
+		return "", nil, nil, err
+	}
+	return token, session, &user, nil
+}
+
+// Authenticate 根据会话令牌查找用户，有效的会话顺延过期时间
+func (s *AccountService) Authenticate(token string) (*models.User, error) {
+	var session models.Session
+	err := s.DB.Where("token_hash = ?", hashToken(token)).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if now.After(session.ExpiresAt) {
+		s.DB.Delete(&session)
+		return nil, ErrSessionInvalid
+	}
+
+	var user models.User
+	if err := s.DB.First(&user, session.UserID).Error; err != nil {
+		return nil, ErrSessionInvalid
+	}
+
+	// 距上次顺延超过一分钟才写库，避免每个请求都更新
+	if now.Sub(session.LastSeenAt) > time.Minute {
+		s.DB.Model(&session).Updates(map[string]interface{}{
+			"last_seen_at": now,
+			"expires_at":   now.Add(s.SessionTTL),
+		})
+	}
+	return &user, nil
+}
+
+// Logout 注销会话
+func (s *AccountService) Logout(token string) error {
+	return s.DB.Where("token_hash = ?", hashToken(token)).Delete(&models.Session{}).Error
+}
+
+// hashToken 计算会话令牌的摘要，数据库中不保存明文令牌
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTenantExists 租户名称已存在
+	ErrTenantExists = errors.New("租户名称已存在")
+	// ErrInvalidTenant 租户参数不正确
+	ErrInvalidTenant = errors.New("租户名称不能为空")
+	// ErrInvalidQuota 配额参数不正确
+	ErrInvalidQuota = errors.New("配额参数不正确")
+	// ErrInvalidWebhook 回调订阅参数不正确
+	ErrInvalidWebhook = errors.New("回调地址必须为 http(s) URL")
+	// ErrAPIKeyInvalid API密钥不存在、已吊销或已过期
+	ErrAPIKeyInvalid = errors.New("API密钥无效")
+)
+
+// apiKeyPrefix API密钥明文的固定前缀，便于识别泄露的密钥
+const apiKeyPrefix = "ak_"
+
+// 可配置配额的资源
+var quotaResources = map[string]bool{
+	"order_tasks":   true,
+	"api_tasks":     true,
+	"file_tasks":    true,
+	"storage_bytes": true,
+}
+
+// 配额统计周期
+var quotaPeriods = map[string]bool{
+	"day":   true,
+	"month": true,
+	"total": true,
+}
+
+// AdminService 租户、API密钥、配额和回调订阅的管理
+type AdminService struct {
+	DB     *gorm.DB
+	ReadDB *gorm.DB // 只读副本，列表查询走这里，未配置时使用主库
+}
+
+// ListTenants 列出所有租户
+func (s *AdminService) ListTenants() ([]models.Tenant, error) {
+	var tenants []models.Tenant
+	err := readerDB(s.DB, s.ReadDB).Order("id").Find(&tenants).Error
+	return tenants, err
+}
+
+// GetTenant 按ID获取租户
+func (s *AdminService) GetTenant(id uint) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := s.DB.First(&tenant, id).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// SaveTenant 创建或更新租户，tenant.ID 为 0 时创建
+func (s *AdminService) SaveTenant(tenant *models.Tenant) error {
+	tenant.Name = strings.TrimSpace(tenant.Name)
+	if tenant.Name == "" {
+		return ErrInvalidTenant
+	}
+
+	var count int64
+	if err := s.DB.Model(&models.Tenant{}).
+		Where("name = ? AND id <> ?", tenant.Name, tenant.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTenantExists
+	}
+
+	if tenant.ID == 0 {
+		return s.DB.Create(tenant).Error
+	}
+	if _, err := s.GetTenant(tenant.ID); err != nil {
+		return err
+	}
+	return s.DB.Model(tenant).Select("name", "description").Updates(tenant).Error
+}
+
+// DeleteTenant 删除租户及其API密钥、配额、回调订阅和结果数据密钥
+// 数据密钥删除后，该租户已加密保存的结果无法再解密
+func (s *AdminService) DeleteTenant(id uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Tenant{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		for _, model := range []interface{}{&models.APIKey{}, &models.Quota{}, &models.WebhookSubscription{}, &models.TenantKey{}} {
+			if err := tx.Where("tenant_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListAPIKeys 列出API密钥，tenantID 为 0 时列出全部
+func (s *AdminService) ListAPIKeys(tenantID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	query := readerDB(s.DB, s.ReadDB).Order("id")
+	if tenantID != 0 {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	err := query.Find(&keys).Error
+	return keys, err
+}
+
+// CreateAPIKey 为租户生成API密钥，返回的明文密钥只在此时可见
+func (s *AdminService) CreateAPIKey(tenantID uint, name string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	if _, err := s.GetTenant(tenantID); err != nil {
+		return "", nil, err
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	secret := apiKeyPrefix + hex.EncodeToString(buf)
+
+	key := &models.APIKey{
+		TenantID:  tenantID,
+		Name:      name,
+		Prefix:    secret[:len(apiKeyPrefix)+8],
+		KeyHash:   hashToken(secret),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.DB.Create(key).Error; err != nil {
+		return "", nil, err
+	}
+	return secret, key, nil
+}
+
+// TenantForAPIKey 返回API密钥所属的租户，并记录最近使用时间
+func (s *AdminService) TenantForAPIKey(secret string) (uint, error) {
+	var key models.APIKey
+	err := s.DB.Where("key_hash = ?", hashToken(secret)).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && key.ExpiresAt.Before(now)) {
+		return 0, ErrAPIKeyInvalid
+	}
+	if err := s.DB.Model(&key).Update("last_used_at", now).Error; err != nil {
+		return 0, err
+	}
+	return key.TenantID, nil
+}
+
+// UpdateAPIKey 修改API密钥的名称和过期时间
+func (s *AdminService) UpdateAPIKey(id uint, name string, expiresAt *time.Time) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.DB.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	key.Name = name
+	key.ExpiresAt = expiresAt
+	if err := s.DB.Model(&key).Select("name", "expires_at").Updates(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey 吊销API密钥，保留记录便于审计
+func (s *AdminService) RevokeAPIKey(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.DB.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if err := s.DB.Model(&key).Update("revoked_at", now).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &key, nil
+}
+
+// ListQuotas 列出配额，tenantID 为 0 时列出全部
+func (s *AdminService) ListQuotas(tenantID uint) ([]models.Quota, error) {
+	var quotas []models.Quota
+	query := readerDB(s.DB, s.ReadDB).Order("tenant_id, resource")
+	if tenantID != 0 {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	err := query.Find(&quotas).Error
+	return quotas, err
+}
+
+// SetQuota 设置租户某项资源的配额，已存在时覆盖
+func (s *AdminService) SetQuota(quota *models.Quota) error {
+	if quota.Period == "" {
+		quota.Period = "day"
+	}
+	if !quotaResources[quota.Resource] || !quotaPeriods[quota.Period] || quota.Limit < 0 {
+		return ErrInvalidQuota
+	}
+	if _, err := s.GetTenant(quota.TenantID); err != nil {
+		return err
+	}
+
+	err := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "resource"}},
+		DoUpdates: clause.AssignmentColumns([]string{"limit", "period", "updated_at"}),
+	}).Create(quota).Error
+	if err != nil {
+		return err
+	}
+	// 冲突更新时 quota.ID 不可靠，重新读取
+	return s.DB.Where("tenant_id = ? AND resource = ?", quota.TenantID, quota.Resource).First(quota).Error
+}
+
+// DeleteQuota 删除配额
+func (s *AdminService) DeleteQuota(id uint) error {
+	return deleteByID(s.DB, &models.Quota{}, id)
+}
+
+// ListWebhooks 列出回调订阅，tenantID 为 0 时列出全部
+func (s *AdminService) ListWebhooks(tenantID uint) ([]models.WebhookSubscription, error) {
+	var hooks []models.WebhookSubscription
+	query := readerDB(s.DB, s.ReadDB).Order("id")
+	if tenantID != 0 {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+	err := query.Find(&hooks).Error
+	return hooks, err
+}
+
+// SaveWebhook 创建或更新回调订阅，hook.ID 为 0 时创建
+func (s *AdminService) SaveWebhook(hook *models.WebhookSubscription) error {
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhook
+	}
+	if _, err := s.GetTenant(hook.TenantID); err != nil {
+		return err
+	}
+
+	if hook.ID == 0 {
+		return s.DB.Create(hook).Error
+	}
+	var existing models.WebhookSubscription
+	if err := s.DB.First(&existing, hook.ID).Error; err != nil {
+		return err
+	}
+	columns := []string{"tenant_id", "url", "events", "active"}
+	if hook.Secret != "" {
+		columns = append(columns, "secret")
+	}
+	return s.DB.Model(hook).Select(columns).Updates(hook).Error
+}
+
+// DeleteWebhook 删除回调订阅
+func (s *AdminService) DeleteWebhook(id uint) error {
+	return deleteByID(s.DB, &models.WebhookSubscription{}, id)
+}
+
+// deleteByID 按ID删除记录，记录不存在时返回 gorm.ErrRecordNotFound
+func deleteByID(db *gorm.DB, model interface{}, id uint) error {
+	result := db.Delete(model, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// apiCallRecordBatchSize 每条 INSERT 写入的调用记录数
+const apiCallRecordBatchSize = 200
+
+// APICallStore 把批量API调用的每个调用写入 api_calls 表，服务重启后仍可查询
+type APICallStore struct {
+	DB *gorm.DB
+}
+
+// APICallLog 逐个收集API调用的结果，流式处理时不需要保留全部结果
+type APICallLog struct {
+	jobID   string
+	tasks   []APICallTask
+	records []models.APICall
+}
+
+// NewAPICallLog 创建批次的调用记录，tasks 为批次的全部调用，按任务序号对应
+func NewAPICallLog(jobID string, tasks []APICallTask) *APICallLog {
+	return &APICallLog{jobID: jobID, tasks: tasks}
+}
+
+// Add 记录一个任务结果，应在收集结果的协程中串行调用
+// 只保存响应状态码和响应体，不保存请求头和请求体，其中可能含有凭据
+func (l *APICallLog) Add(task TaskResult) {
+	if task.ID < 0 || task.ID >= len(l.tasks) {
+		return
+	}
+	call := l.tasks[task.ID]
+	record := models.APICall{
+		JobID:    l.jobID,
+		URL:      call.URL,
+		Method:   call.Method,
+		Status:   task.Status,
+		Duration: task.Duration,
+		Error:    task.Error,
+	}
+	if data, ok := task.Data.(map[string]interface{}); ok {
+		record.ResponseCode, _ = data["status_code"].(int)
+		record.ResponseBody, _ = data["response_body"].(string)
+	}
+	l.records = append(l.records, record)
+}
+
+// Record 写入批量处理结果中的全部调用
+func (s *APICallStore) Record(jobID string, tasks []APICallTask, result *BatchResult) error {
+	calls := NewAPICallLog(jobID, tasks)
+	for _, task := range result.Results {
+		calls.Add(task)
+	}
+	return s.Save(calls)
+}
+
+// Save 写入收集的调用记录，s 为nil时不保存
+func (s *APICallStore) Save(calls *APICallLog) error {
+	if s == nil || s.DB == nil || len(calls.records) == 0 {
+		return nil
+	}
+	return s.DB.CreateInBatches(calls.records, apiCallRecordBatchSize).Error
+}
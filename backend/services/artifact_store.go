@@ -0,0 +1,355 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 产出物存储级别
+const (
+	StorageClassHot  = "hot"
+	StorageClassCold = "cold"
+)
+
+// ColdStorage 冷存储后端，可替换为对象存储的归档存储类型
+type ColdStorage interface {
+	Name() string
+	Put(key string, r io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// LocalArchiveStorage 本地压缩归档，内容以 gzip 压缩保存
+type LocalArchiveStorage struct {
+	Dir string
+}
+
+// Name 后端名称
+func (s *LocalArchiveStorage) Name() string {
+	return "local-gzip"
+}
+
+// Put 压缩写入归档
+func (s *LocalArchiveStorage) Put(key string, r io.Reader) error {
+	path := filepath.Join(s.Dir, key+".gz")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := gzip.NewWriter(file)
+	if _, err := io.Copy(writer, r); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// Get 读取并解压归档
+func (s *LocalArchiveStorage) Get(key string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(s.Dir, key+".gz"))
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &gzipReadCloser{Reader: reader, file: file}, nil
+}
+
+// Delete 删除归档
+func (s *LocalArchiveStorage) Delete(key string) error {
+	return os.Remove(filepath.Join(s.Dir, key+".gz"))
+}
+
+// gzipReadCloser 关闭时同时关闭底层文件
+type gzipReadCloser struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *gzipReadCloser) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+// ArtifactService 任务产出物服务
+type ArtifactService struct {
+	DB           *gorm.DB
+	ReadDB       *gorm.DB            // 只读副本，为nil时查询走主库
+	Locks        *models.LockManager // 为nil时不加锁，仅适用于单实例部署
+	Dir          string              // 热存储目录
+	Cold         ColdStorage         // 冷存储后端
+	ArchiveAfter time.Duration       // 产出物超过该时长后归档，0表示不自动归档
+	Cipher       *ResultCipher       // 按租户加密产出物，为nil时以明文保存
+}
+
+// SaveJobResult 将任务结果写入热存储并登记，配置了 Cipher 时以任务所属租户的数据密钥加密
+func (s *ArtifactService) SaveJobResult(info JobInfo, result *BatchResult) (*models.JobArtifact, error) {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"job": info, "result": result})
+	if err != nil {
+		return nil, err
+	}
+	if data, err = s.Cipher.sealResult(info, info.ID, data); err != nil {
+		return nil, fmt.Errorf("加密产出物失败: %w", err)
+	}
+
+	path := filepath.Join(s.Dir, info.ID+".json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+
+	artifact := &models.JobArtifact{
+		JobID:        info.ID,
+		JobType:      info.Type,
+		Path:         path,
+		Size:         int64(len(data)),
+		StorageClass: StorageClassHot,
+		TemplateID:   info.TemplateID,
+		ExpiresAt:    info.ExpiresAt,
+	}
+	return artifact, s.DB.Create(artifact).Error
+}
+
+// readArtifact 读取并解密任务的产出物，已归档的产出物直接从冷存储读取，不恢复到热存储
+func (s *ArtifactService) readArtifact(jobID string) ([]byte, error) {
+	var artifact models.JobArtifact
+	err := readerDB(s.DB, s.ReadDB).Where("job_id = ?", jobID).First(&artifact).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobOutputNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reader io.ReadCloser
+	if artifact.StorageClass == StorageClassCold {
+		reader, err = s.Cold.Get(artifact.ArchiveKey)
+	} else {
+		reader, err = os.Open(artifact.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = s.Cipher.Open(jobID, data); err != nil {
+		return nil, fmt.Errorf("读取任务 %s 的产出物失败: %w", jobID, err)
+	}
+	return data, nil
+}
+
+// LoadJobResult 读取已结束任务保存的任务信息和批次结果，用于服务重启后内存中已没有的任务
+// owner 不是任务的发起人时同样返回 ErrJobOutputNotFound
+func (s *ArtifactService) LoadJobResult(jobID, owner string) (*JobInfo, *BatchResult, error) {
+	data, err := s.readArtifact(jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var saved struct {
+		Job    JobInfo      `json:"job"`
+		Result *BatchResult `json:"result"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, nil, fmt.Errorf("读取任务 %s 的产出物失败: %w", jobID, err)
+	}
+	if saved.Job.Owner != owner || saved.Result == nil {
+		return nil, nil, ErrJobOutputNotFound
+	}
+	return &saved.Job, saved.Result, nil
+}
+
+// List 列出产出物，storageClass 为空时列出全部
+func (s *ArtifactService) List(storageClass string) ([]models.JobArtifact, error) {
+	db := readerDB(s.DB, s.ReadDB).Model(&models.JobArtifact{})
+	if storageClass != "" {
+		db = db.Where("storage_class = ?", storageClass)
+	}
+
+	var artifacts []models.JobArtifact
+	err := db.Order("created_at DESC").Find(&artifacts).Error
+	return artifacts, err
+}
+
+// ArchiveOlderThan 将超过指定时长的热存储产出物移入冷存储，返回归档数量
+// 多实例部署时同一时间只有一个实例执行，锁被占用时返回 models.ErrLockHeld
+func (s *ArtifactService) ArchiveOlderThan(age time.Duration) (int, error) {
+	if s.Locks != nil {
+		release, err := s.Locks.TryLock(context.Background(), models.LockArchiver)
+		if err != nil {
+			return 0, err
+		}
+		defer release()
+	}
+
+	var artifacts []models.JobArtifact
+	// 恢复过的产出物从恢复时间重新计时
+	err := s.DB.Where("storage_class = ? AND COALESCE(restored_at, created_at) < ?", StorageClassHot, time.Now().Add(-age)).
+		Find(&artifacts).Error
+	if err != nil {
+		return 0, err
+	}
+
+	archived := 0
+	for i := range artifacts {
+		if err := s.archive(&artifacts[i]); err != nil {
+			return archived, fmt.Errorf("归档任务 %s 失败: %v", artifacts[i].JobID, err)
+		}
+		archived++
+	}
+	return archived, nil
+}
+
+// archive 归档单个产出物：先写入冷存储并更新记录，再删除热存储文件
+func (s *ArtifactService) archive(artifact *models.JobArtifact) error {
+	path := artifact.Path
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	key := artifact.JobType + "/" + artifact.JobID + ".json"
+	err = s.Cold.Put(key, file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = s.DB.Model(artifact).Updates(map[string]interface{}{
+		"storage_class": StorageClassCold,
+		"archive_key":   key,
+		"archived_at":   &now,
+		"path":          "",
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// Restore 将冷存储中的产出物恢复到热存储
+func (s *ArtifactService) Restore(jobID string) (*models.JobArtifact, error) {
+	var artifact models.JobArtifact
+	if err := s.DB.Where("job_id = ?", jobID).First(&artifact).Error; err != nil {
+		return nil, err
+	}
+	if artifact.StorageClass == StorageClassHot {
+		return &artifact, nil
+	}
+
+	reader, err := s.Cold.Get(artifact.ArchiveKey)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(s.Dir, artifact.JobID+".json")
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.DB.Model(&artifact).Updates(map[string]interface{}{
+		"storage_class": StorageClassHot,
+		"path":          path,
+		"restored_at":   &now,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Cold.Delete(artifact.ArchiveKey); err != nil {
+		log.Printf("删除归档 %s 失败: %v", artifact.ArchiveKey, err)
+	}
+	return &artifact, nil
+}
+
+// Delete 删除任务的产出物（热存储文件或冷存储归档）及其记录，没有产出物时返回nil
+func (s *ArtifactService) Delete(jobID string) error {
+	var artifact models.JobArtifact
+	err := s.DB.Where("job_id = ?", jobID).First(&artifact).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if artifact.StorageClass == StorageClassCold {
+		err = s.Cold.Delete(artifact.ArchiveKey)
+	} else {
+		err = os.Remove(artifact.Path)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return s.DB.Delete(&artifact).Error
+}
+
+// RunArchiver 定期归档过期的产出物，直到上下文取消
+func (s *ArtifactService) RunArchiver(ctx context.Context, interval time.Duration) {
+	if s.ArchiveAfter <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			archived, err := s.ArchiveOlderThan(s.ArchiveAfter)
+			if errors.Is(err, models.ErrLockHeld) {
+				// 其他实例正在归档
+				continue
+			}
+			if err != nil {
+				log.Printf("归档任务产出物失败: %v", err)
+			}
+			if archived > 0 {
+				log.Printf("已归档 %d 个任务产出物到 %s", archived, s.Cold.Name())
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
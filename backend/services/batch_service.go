@@ -2,39 +2,328 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"concurrency-web-app/backend/batch"
 )
 
 // TaskResult 通用任务结果
 type TaskResult struct {
-	ID       int         `json:"id"`
-	Success  bool        `json:"success"`
-	Data     interface{} `json:"data"`
-	Error    string      `json:"error,omitempty"`
-	Duration int64       `json:"duration"` // 毫秒
+	ID      int         `json:"id"`
+	Success bool        `json:"success"`
+	Status  string      `json:"status"`
+	Data    interface{} `json:"data"`
+	Error   string      `json:"error,omitempty"`
+	// ErrorClass 需要单独区分的失败原因：ErrorClassDNS、ErrorClassBusiness 或 ErrorClassInfra
+	ErrorClass string `json:"error_class,omitempty"`
+	// ErrorCode 未成功的任务的错误码，见 error_catalog.go；ErrorParams 为错误码消息模板中的参数
+	ErrorCode   string                 `json:"error_code,omitempty"`
+	ErrorParams map[string]interface{} `json:"error_params,omitempty"`
+	Duration    int64                  `json:"duration"` // 毫秒
+	Attempts    []TaskAttempt          `json:"attempts,omitempty"`
+	// Mismatches 状态为 mismatch 时与预期输出不符的字段，见 Expectations
+	Mismatches []FieldMismatch `json:"mismatches,omitempty"`
+	// Env 执行任务的实例、工作池、工作槽位和尝试序号，未开始执行的任务为nil
+	Env *TaskEnv `json:"env,omitempty"`
+}
+
+// err 返回失败任务的错误，成功、已取消和未开始的任务返回nil
+func (r TaskResult) err() error {
+	if r.Success || r.Status == TaskStatusCancelled || r.Status == TaskStatusNotStarted {
+		return nil
+	}
+	return errors.New(r.Error)
 }
 
-// BatchResult 批量处理结果
+// 单个任务的结果状态
+const (
+	TaskStatusSuccess    = "success"
+	TaskStatusFailed     = "failed"
+	TaskStatusTimeout    = "timeout"     // 执行中因单个任务或批次的时间预算耗尽而中止
+	TaskStatusCancelled  = "cancelled"   // 被软取消、硬取消或快速失败取消
+	TaskStatusNotStarted = "not_started" // 批次超时时尚未开始执行
+	TaskStatusSkipped    = "skipped"     // 依赖的任务未成功，任务未执行
+)
+
+// ResultOrder 批次结果的顺序
+type ResultOrder string
+
+const (
+	ResultOrderDefault    ResultOrder = ""           // 完整返回的结果按任务序号，NDJSON 流式返回按完成顺序
+	ResultOrderInput      ResultOrder = "input"      // 按任务序号，流式返回时暂存先完成的结果，直到前面的任务完成
+	ResultOrderCompletion ResultOrder = "completion" // 按完成顺序，没有收集到结果的任务排在最后
+)
+
+// BatchResult 批量处理结果，Results 中每个提交的任务恰好有一条记录（NDJSON 流式批次除外）
 type BatchResult struct {
-	TotalTasks   int          `json:"total_tasks"`
-	SuccessTasks int          `json:"success_tasks"`
-	FailedTasks  int          `json:"failed_tasks"`
-	Results      []TaskResult `json:"results"`
-	Duration     int64        `json:"duration"` // 毫秒
+	TotalTasks      int             `json:"total_tasks"`
+	SuccessTasks    int             `json:"success_tasks"`
+	FailedTasks     int             `json:"failed_tasks"` // 含超时和未开始的任务
+	TimeoutTasks    int             `json:"timeout_tasks"`
+	NotStartedTasks int             `json:"not_started_tasks"`
+	CancelledTasks  int             `json:"cancelled_tasks"`
+	SkippedTasks    int             `json:"skipped_tasks,omitempty"` // 因依赖的任务未成功而未执行，计入 FailedTasks
+	CancelMode      CancelMode      `json:"cancel_mode,omitempty"`
+	Results         []TaskResult    `json:"results"`
+	Duration        int64           `json:"duration"`                  // 毫秒
+	TotalBytes      int64           `json:"total_bytes,omitempty"`     // 处理的总字节数（hash 批次）
+	Throughput      float64         `json:"throughput_mbps,omitempty"` // 总吞吐量 MB/s（hash 批次）
+	Sample          *SampleEstimate `json:"sample,omitempty"`          // 抽样执行时的全量估算
+
+	// BusinessErrorTasks、InfraErrorTasks 按错误分类统计的失败任务，计入 FailedTasks
+	BusinessErrorTasks int `json:"business_error_tasks,omitempty"`
+	InfraErrorTasks    int `json:"infra_error_tasks,omitempty"`
+	// StalledTasks 长时间没有心跳、被回收器强制取消的任务，计入 FailedTasks
+	StalledTasks int `json:"stalled_tasks,omitempty"`
+	// MismatchTasks 执行成功但结果与预期输出不符的任务，计入 FailedTasks
+	MismatchTasks int `json:"mismatch_tasks,omitempty"`
+}
+
+// checkDispatch 检查任务能否开始执行，不能执行时返回对应的任务结果
+func checkDispatch(ctx context.Context, index int, taskStart time.Time) (TaskResult, bool) {
+	// 任务已被取消，不再派发
+	if job := JobFromContext(ctx); job != nil && job.SoftCancelled() {
+		if job.CancelMode() == CancelModeDependency {
+			return TaskResult{
+				ID:        index,
+				Success:   false,
+				Status:    TaskStatusSkipped,
+				Error:     "上游任务未成功完成，任务未执行",
+				ErrorCode: ErrCodeDependency,
+				Duration:  time.Since(taskStart).Milliseconds(),
+			}, false
+		}
+		return TaskResult{
+			ID:        index,
+			Success:   false,
+			Status:    TaskStatusCancelled,
+			Error:     "任务已取消，未开始执行",
+			ErrorCode: ErrCodeCancelled,
+			Duration:  time.Since(taskStart).Milliseconds(),
+		}, false
+	}
+
+	// 批次已超时或被硬取消
+	select {
+	case <-ctx.Done():
+		result := TaskResult{
+			ID:        index,
+			Success:   false,
+			Status:    TaskStatusNotStarted,
+			Error:     "批次超时，任务未开始执行",
+			ErrorCode: ErrCodeNotStarted,
+			Duration:  time.Since(taskStart).Milliseconds(),
+		}
+		if job := JobFromContext(ctx); job != nil && job.CancelMode().aborts() {
+			result.Status = TaskStatusCancelled
+			result.Error = "任务已取消，未开始执行"
+			result.ErrorCode = cancelCode(ctx)
+		}
+		if failedByOtherTask(ctx) {
+			result.Status = TaskStatusCancelled
+			result.Error = "其他任务失败，任务未开始执行"
+			result.ErrorCode = ErrCodeFailFast
+		}
+		return result, false
+	default:
+	}
+
+	startTrackerFromContext(ctx).start(index)
+	return TaskResult{}, true
+}
+
+// skippedResult 依赖的任务未成功时生成的任务结果
+func skippedResult[T any](_ context.Context, index int, _ T, err error) TaskResult {
+	return TaskResult{
+		ID:        index,
+		Success:   false,
+		Status:    TaskStatusSkipped,
+		Error:     err.Error() + "，任务未执行",
+		ErrorCode: ErrCodeDependency,
+	}
+}
+
+// withTaskTimeout 为单个任务设置时间预算（覆盖全部重试和退避），d 为0时只受批次预算限制
+// 单个任务超时只让该任务失败，批次中的其他任务继续执行
+func withTaskTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+// taskTimeoutError 任务因自身的时间预算耗尽而失败时在错误中注明，与批次超时区分；被回收器取消的任务包装为 TaskStalledError
+func taskTimeoutError(batchCtx, taskCtx context.Context, d time.Duration, err error) error {
+	var stalled *TaskStalledError
+	if err = stalledError(taskCtx, err); errors.As(err, &stalled) {
+		return err
+	}
+	if err != nil && batchCtx.Err() == nil && errors.Is(taskCtx.Err(), context.DeadlineExceeded) {
+		return &TaskTimeoutError{Timeout: d, Err: err}
+	}
+	return err
+}
+
+// batchTally 按结果状态累计的任务数
+type batchTally struct {
+	collected  int
+	success    int
+	cancelled  int
+	timeout    int
+	notStarted int
+	skipped    int
+	business   int
+	infra      int
+	stalled    int
+	// 收集到结果的任务中已开始执行的数量，用于推算没有结果的任务中有多少是执行中被中止的
+	collectedStarted int
+}
+
+func (t *batchTally) add(ctx context.Context, result TaskResult) {
+	t.collected++
+	switch result.Status {
+	case TaskStatusSuccess:
+		t.success++
+	case TaskStatusCancelled:
+		t.cancelled++
+	case TaskStatusTimeout:
+		t.timeout++
+	case TaskStatusNotStarted:
+		t.notStarted++
+	case TaskStatusSkipped:
+		t.skipped++
+	}
+	switch result.ErrorClass {
+	case ErrorClassBusiness:
+		t.business++
+	case ErrorClassInfra:
+		t.infra++
+	case ErrorClassStalled:
+		t.stalled++
+	}
+	if startTrackerFromContext(ctx).isStarted(result.ID) {
+		t.collectedStarted++
+	}
+}
+
+// summarize 由计数生成不含任务明细的批次汇总
+// 没有收集到结果的任务：硬取消或快速失败时计为取消；批次超时时已开始的计为超时，其余计为未开始
+func (t batchTally) summarize(ctx context.Context, startTime time.Time, totalTasks int) *BatchResult {
+	batch := &BatchResult{
+		TotalTasks:      totalTasks,
+		SuccessTasks:    t.success,
+		CancelledTasks:  t.cancelled,
+		TimeoutTasks:    t.timeout,
+		NotStartedTasks: t.notStarted,
+		SkippedTasks:    t.skipped,
+		Results:         []TaskResult{},
+
+		BusinessErrorTasks: t.business,
+		InfraErrorTasks:    t.infra,
+		StalledTasks:       t.stalled,
+	}
+	if job := JobFromContext(ctx); job != nil {
+		batch.CancelMode = job.CancelMode()
+	}
+	if missing := totalTasks - t.collected; missing > 0 {
+		if batch.CancelMode.aborts() {
+			batch.CancelledTasks += missing
+		} else {
+			running := startTrackerFromContext(ctx).startedCount() - t.collectedStarted
+			running = max(0, min(running, missing))
+			batch.TimeoutTasks += running
+			batch.NotStartedTasks += missing - running
+		}
+	}
+	batch.FailedTasks = totalTasks - batch.SuccessTasks - batch.CancelledTasks
+	batch.Duration = time.Since(startTime).Milliseconds()
+	return batch
+}
+
+// buildBatchResult 汇总任务结果，为没有收集到结果的任务补充记录，使每个任务恰好有一条结果：
+// 硬取消、快速失败或服务关闭时记为取消；批次超时时已开始执行的记为超时，其余记为未开始
+func buildBatchResult(ctx context.Context, startTime time.Time, totalTasks int, results []TaskResult) *BatchResult {
+	collected := make(map[int]bool, len(results))
+	for _, result := range results {
+		collected[result.ID] = true
+	}
+
+	var cancelMode CancelMode
+	if job := JobFromContext(ctx); job != nil {
+		cancelMode = job.CancelMode()
+	}
+	tracker := startTrackerFromContext(ctx)
+	for i := 0; i < totalTasks; i++ {
+		if collected[i] {
+			continue
+		}
+		result := TaskResult{ID: i, Success: false}
+		switch {
+		case cancelMode == CancelModeFailFast:
+			result.Status = TaskStatusCancelled
+			result.Error = "其他任务失败，任务已取消（fail_fast）"
+			result.ErrorCode = ErrCodeFailFast
+		case cancelMode == CancelModeHard:
+			result.Status = TaskStatusCancelled
+			result.Error = "任务已被硬取消"
+			result.ErrorCode = ErrCodeHardCancelled
+		case cancelMode == CancelModeShutdown:
+			result.Status = TaskStatusCancelled
+			result.Error = "服务关闭，任务已取消"
+			result.ErrorCode = ErrCodeShutdown
+		case tracker.isStarted(i):
+			result.Status = TaskStatusTimeout
+			result.Error = "批次超时，任务执行中被中止"
+			result.ErrorCode = ErrCodeBatchTimeout
+		default:
+			result.Status = TaskStatusNotStarted
+			result.Error = "批次超时，任务未开始执行"
+			result.ErrorCode = ErrCodeNotStarted
+		}
+		results = append(results, result)
+	}
+
+	var tally batchTally
+	for _, result := range results {
+		tally.add(ctx, result)
+	}
+	batch := tally.summarize(ctx, startTime, totalTasks)
+
+	// 按ID排序，要求按完成顺序时保持收集顺序
+	if jobResultOrder(ctx) != ResultOrderCompletion {
+		sort.Slice(results, func(i, j int) bool {
+			return results[i].ID < results[j].ID
+		})
+	}
+	if results != nil {
+		batch.Results = results
+	}
+	return batch
 }
 
 // OrderProcessService 订单处理服务
 type OrderProcessService struct {
 	MaxConcurrency int
-	Timeout        time.Duration
+	Mode           batch.Mode         // 调度方式，默认每个任务一个协程；大批次可用 batch.ModeWorkerPool
+	Timeout        time.Duration      // 整个批次的预算，到期后未完成的任务记为超时或未开始
+	PerTaskTimeout time.Duration      // 单个任务的预算（含重试和退避），超时只让该任务失败，为0时只受批次预算限制
+	Retry          *RetryPolicy       // 为nil时不重试
+	RateLimit      *batch.RateLimiter // 每秒开始执行的任务数上限，该服务的所有批次共享；为nil时只受并发数限制
+	Pools          *WorkerPools       // 按 order 类型路由到的命名工作池；为nil时不使用
+	ResultLimit    ResultLimit
 }
 
 // OrderTask 订单处理任务
@@ -44,17 +333,49 @@ type OrderTask struct {
 	ProductName string  `json:"product_name"`
 	Quantity    int     `json:"quantity"`
 	Price       float64 `json:"price"`
+	Priority    int     `json:"priority,omitempty"` // 优先级，数值越大越先执行，默认0
+	// DependsOn 依赖的订单在批次中的序号（从0开始），这些订单全部成功后才处理本订单，其中任一失败时本订单跳过
+	DependsOn []int `json:"depends_on,omitempty"`
+}
+
+// orderDeps 批次中有订单声明依赖时返回按依赖调度所需的函数，否则返回nil，按 Mode 调度
+func orderDeps(orders []OrderTask) func(OrderTask) []int {
+	for _, o := range orders {
+		if len(o.DependsOn) > 0 {
+			return func(o OrderTask) []int { return o.DependsOn }
+		}
+	}
+	return nil
 }
 
-// ProcessOrder 处理单个订单
-func (s *OrderProcessService) ProcessOrder(order OrderTask) (interface{}, error) {
+// CheckOrderDeps 检查订单之间的依赖关系，没有订单声明依赖时返回nil
+// 抽样执行会改变订单的序号，声明了依赖的批次不能抽样
+func CheckOrderDeps(orders []OrderTask, sampleRate float64) error {
+	deps := orderDeps(orders)
+	if deps == nil {
+		return nil
+	}
+	if sampleRate > 0 && sampleRate < 1 {
+		return errors.New("声明了依赖的批次不能抽样执行")
+	}
+	return batch.CheckDeps(orders, deps)
+}
+
+// ProcessOrder 处理单个订单，ctx 结束时中止
+func (s *OrderProcessService) ProcessOrder(ctx context.Context, order OrderTask) (interface{}, error) {
 	// 模拟订单处理时间
 	processingTime := time.Duration(100+order.ID*10) * time.Millisecond
-	time.Sleep(processingTime)
+	if err := sleepContext(ctx, processingTime); err != nil {
+		return nil, fmt.Errorf("订单 %d 处理中止: %w", order.ID, err)
+	}
 
 	// 模拟某些订单处理失败
 	if order.ID%7 == 0 {
-		return nil, fmt.Errorf("订单 %d 库存不足", order.ID)
+		return nil, &BusinessError{
+			Code:   ErrCodeOutOfStock,
+			Params: map[string]interface{}{"order_id": order.ID},
+			Err:    fmt.Errorf("订单 %d 库存不足", order.ID),
+		}
 	}
 
 	// 计算总价
@@ -72,117 +393,126 @@ func (s *OrderProcessService) ProcessOrder(order OrderTask) (interface{}, error)
 	}, nil
 }
 
-// BatchProcessOrders 批量处理订单
-func (s *OrderProcessService) BatchProcessOrders(ctx context.Context, orders []OrderTask) *BatchResult {
-	startTime := time.Now()
-	totalTasks := len(orders)
+// runOrderTask 在指定工作槽位上处理单个订单（含取消检查和重试）
+func (s *OrderProcessService) runOrderTask(ctx context.Context, index, slot int, task OrderTask) (result TaskResult) {
+	taskStart := time.Now()
+	defer recoverTask(ctx, index, taskStart, &result)
 
-	if totalTasks == 0 {
-		return &BatchResult{
-			TotalTasks: 0,
-			Results:    []TaskResult{},
-			Duration:   time.Since(startTime).Milliseconds(),
+	// 检查是否已取消或超时
+	if result, ok := checkDispatch(ctx, index, taskStart); !ok {
+		return result
+	}
+	ctx, untrack := trackInflight(ctx, index, slot)
+	defer untrack()
+
+	// 处理订单，单个订单超时不影响批次中的其他订单
+	taskCtx, cancel := withTaskTimeout(ctx, s.PerTaskTimeout)
+	defer cancel()
+	data, attempts, err := runWithRetry(taskCtx, s.Retry, strconv.Itoa(index), func(ctx context.Context) (interface{}, error) {
+		release, err := s.Pools.Acquire(ctx, PoolTaskOrder, "")
+		if err != nil {
+			return nil, err
 		}
+		defer release()
+		return s.ProcessOrder(ctx, task)
+	})
+	err = taskTimeoutError(ctx, taskCtx, s.PerTaskTimeout, err)
+
+	result = TaskResult{
+		ID:       index,
+		Success:  err == nil,
+		Status:   TaskStatusSuccess,
+		Data:     s.ResultLimit.apply(ctx, index, data),
+		Duration: time.Since(taskStart).Milliseconds(),
+		Attempts: attempts,
+		Env:      newTaskEnv(s.Pools, PoolTaskOrder, "", slot, attempts),
 	}
 
-	resultCh := make(chan TaskResult, totalTasks)
-	var wg sync.WaitGroup
-
-	// 限制并发数
-	semaphore := make(chan struct{}, s.MaxConcurrency)
-
-	for i, order := range orders {
-		wg.Add(1)
-		go func(index int, task OrderTask) {
-			defer wg.Done()
-
-			// 获取信号量
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			taskStart := time.Now()
-
-			// 检查超时
-			select {
-			case <-ctx.Done():
-				resultCh <- TaskResult{
-					ID:       index,
-					Success:  false,
-					Error:    "任务超时",
-					Duration: time.Since(taskStart).Milliseconds(),
-				}
-				return
-			default:
-			}
-
-			// 处理订单
-			data, err := s.ProcessOrder(task)
-
-			result := TaskResult{
-				ID:       index,
-				Success:  err == nil,
-				Data:     data,
-				Duration: time.Since(taskStart).Milliseconds(),
-			}
-
-			if err != nil {
-				result.Error = err.Error()
-			}
-
-			resultCh <- result
-		}(i, order)
-	}
-
-	// 等待所有任务完成
-	go func() {
-		wg.Wait()
-		close(resultCh)
-	}()
-
-	// 收集结果
-	var results []TaskResult
-	successCount := 0
-
-	timeout := time.NewTimer(s.Timeout)
-	defer timeout.Stop()
-
-	for {
-		select {
-		case result, ok := <-resultCh:
-			if !ok {
-				goto DONE
-			}
-			results = append(results, result)
-			if result.Success {
-				successCount++
-			}
-		case <-timeout.C:
-			goto DONE
-		case <-ctx.Done():
-			goto DONE
-		}
+	if err != nil {
+		result.Status = failureStatus(taskCtx, err)
+		result.Error = err.Error()
+		result.classify(ctx, err)
+		captureFailure(ctx, result, task, err)
 	}
 
-DONE:
-	// 按ID排序
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].ID < results[j].ID
-	})
+	return result
+}
 
-	return &BatchResult{
-		TotalTasks:   totalTasks,
-		SuccessTasks: successCount,
-		FailedTasks:  totalTasks - successCount,
-		Results:      results,
-		Duration:     time.Since(startTime).Milliseconds(),
+// processor 返回执行这批订单的批处理引擎，有订单声明依赖时按依赖关系调度
+func (s *OrderProcessService) processor(ctx context.Context, orders []OrderTask) *batch.Processor[OrderTask, TaskResult] {
+	return &batch.Processor[OrderTask, TaskResult]{
+		Mode:           s.Mode,
+		MaxConcurrency: jobMaxConcurrency(ctx, s.MaxConcurrency),
+		Timeout:        jobTimeout(ctx, s.Timeout),
+		Priority:       func(t OrderTask) int { return t.Priority },
+		Deps:           orderDeps(orders),
+		Skip:           skippedResult[OrderTask],
+		Run:            s.runOrderTask,
+		Err:            TaskResult.err,
+		RateLimit:      s.RateLimit,
+		Concurrency:    jobConcurrency(ctx),
+		Chunks:         jobChunks(ctx),
+		OnResult:       reportProgress,
 	}
 }
 
+// BatchProcessOrders 批量处理订单
+func (s *OrderProcessService) BatchProcessOrders(ctx context.Context, orders []OrderTask) *BatchResult {
+	startTime := time.Now()
+
+	ctx = withStartTracker(ctx, len(orders))
+	ctx = withRetryBudget(ctx, len(orders))
+	results := s.processor(ctx, orders).Process(ctx, orders)
+	return buildBatchResult(ctx, startTime, len(orders), results)
+}
+
+// APITimeouts API调用的超时层级，从内到外依次为：
+// 建立连接 < 单次请求 < 单个任务（含重试和退避） < 整个批次
+type APITimeouts struct {
+	Connect time.Duration // 建立TCP连接的超时
+	Request time.Duration // 单次HTTP请求的超时（含读取响应体）
+	Task    time.Duration // 单个任务的总预算，为0时只受批次预算限制
+	Batch   time.Duration // 整个批次的预算
+}
+
 // APICallService API调用服务
 type APICallService struct {
 	MaxConcurrency int
-	Timeout        time.Duration
-	Client         *http.Client
+	Mode           batch.Mode // 调度方式，默认每个任务一个协程；大批次可用 batch.ModeWorkerPool
+	Timeouts       APITimeouts
+	Ramp           *batch.Ramp        // 并发数预热，下游冷启动时避免开始阶段集中失败；为nil时直接以满并发执行
+	Retry          *RetryPolicy       // 为nil时不重试
+	RateLimit      *batch.RateLimiter // 每秒开始执行的调用数上限，所有批次和流水线的 fetch 阶段共享，用于遵守下游接口的配额；重试不另占配额
+	Client         *http.Client       // 为nil时按Timeouts创建
+	DNS            *DNSCache          // 域名解析缓存，批次开始前预解析全部主机；为nil时每次连接都由系统解析，注入 Client 时只用于预解析
+	Throttle       *HostThrottle      // 按主机最近的失败率自动降低和恢复并发，每次调整记入任务事件；为nil时不按主机限制
+	Pools          *WorkerPools       // 命名工作池，按任务类型和主机路由，限制跨服务、跨批次同时进行的调用数；为nil时不使用
+	ResultLimit    ResultLimit
+
+	clientOnce sync.Once
+}
+
+// httpClient 返回共享的HTTP客户端，未注入时按超时配置创建
+func (s *APICallService) httpClient() *http.Client {
+	s.clientOnce.Do(func() {
+		if s.Client != nil {
+			return
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		dialer := &net.Dialer{
+			Timeout:   s.Timeouts.Connect,
+			KeepAlive: 30 * time.Second,
+		}
+		transport.DialContext = dialer.DialContext
+		if s.DNS != nil {
+			transport.DialContext = s.DNS.DialContext(dialer)
+		}
+		s.Client = &http.Client{
+			Transport: transport,
+			Timeout:   s.Timeouts.Request,
+		}
+	})
+	return s.Client
 }
 
 // APICallTask API调用任务
@@ -192,21 +522,40 @@ type APICallTask struct {
 	Method  string            `json:"method"`
 	Headers map[string]string `json:"headers"`
 	Body    string            `json:"body"`
+	// Priority 优先级，数值越大越先执行，默认0
+	Priority int `json:"priority,omitempty"`
 }
 
-// CallAPI 调用单个API
-func (s *APICallService) CallAPI(task APICallTask) (interface{}, error) {
-	client := s.Client
-	if client == nil {
-		client = &http.Client{Timeout: 10 * time.Second}
+// callLimited 取得主机所属工作池的槽位和主机限流的许可后调用API，主机限流的调整记入当前任务的事件
+func (s *APICallService) callLimited(ctx context.Context, taskType string, task APICallTask) (interface{}, error) {
+	host := urlHost(task.URL)
+	release, err := s.Pools.Acquire(ctx, taskType, host)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	done, err := s.Throttle.Acquire(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.CallAPI(ctx, task)
+	if decision := done(err); decision != nil {
+		recordThrottle(ctx, decision)
 	}
+	return data, err
+}
+
+// CallAPI 调用单个API，ctx 结束时中止请求
+func (s *APICallService) CallAPI(ctx context.Context, task APICallTask) (interface{}, error) {
+	client := s.httpClient()
 
 	var bodyReader io.Reader
 	if task.Body != "" {
 		bodyReader = strings.NewReader(task.Body)
 	}
 
-	req, err := http.NewRequest(task.Method, task.URL, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, task.Method, task.URL, bodyReader)
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %v", err)
 	}
@@ -218,13 +567,18 @@ func (s *APICallService) CallAPI(task APICallTask) (interface{}, error) {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %v", err)
+		return nil, fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	Heartbeat(ctx)
+	body, err := io.ReadAll(contextReader{ctx: ctx, r: resp.Body})
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %v", err)
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	// 上游暂时不可用时按失败处理，交给重试策略判断是否重试
+	if transientStatus(resp.StatusCode) {
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
 	}
 
 	return map[string]interface{}{
@@ -236,117 +590,149 @@ func (s *APICallService) CallAPI(task APICallTask) (interface{}, error) {
 	}, nil
 }
 
-// BatchCallAPIs 批量调用API
-func (s *APICallService) BatchCallAPIs(ctx context.Context, tasks []APICallTask) *BatchResult {
-	startTime := time.Now()
-	totalTasks := len(tasks)
+// runAPITask 在指定工作槽位上执行单个API调用（含取消检查、任务预算和重试）
+func (s *APICallService) runAPITask(ctx context.Context, index, slot int, apiTask APICallTask) (result TaskResult) {
+	taskStart := time.Now()
+	defer recoverTask(ctx, index, taskStart, &result)
 
-	if totalTasks == 0 {
-		return &BatchResult{
-			TotalTasks: 0,
-			Results:    []TaskResult{},
-			Duration:   time.Since(startTime).Milliseconds(),
+	// 检查是否已取消或超时
+	if result, ok := checkDispatch(ctx, index, taskStart); !ok {
+		return result
+	}
+	// 域名解析失败的任务直接失败，不重试，也不占用工作槽位等待连接
+	if err := s.resolveHost(ctx, apiTask); err != nil {
+		result = TaskResult{
+			ID:         index,
+			Status:     TaskStatusFailed,
+			Error:      err.Error(),
+			ErrorClass: ErrorClassDNS,
+			ErrorCode:  ErrCodeDNS,
+			Duration:   time.Since(taskStart).Milliseconds(),
 		}
+		captureFailure(ctx, result, apiTask, err)
+		return result
 	}
+	ctx, untrack := trackInflight(ctx, index, slot)
+	defer untrack()
 
-	resultCh := make(chan TaskResult, totalTasks)
-	var wg sync.WaitGroup
+	// 调用API
+	// 单个任务的时间预算，覆盖全部重试和退避
+	taskCtx, cancel := withTaskTimeout(ctx, s.Timeouts.Task)
+	defer cancel()
 
-	// 限制并发数
-	semaphore := make(chan struct{}, s.MaxConcurrency)
+	data, attempts, err := runWithRetry(taskCtx, s.retryPolicy(apiTask), strconv.Itoa(index), func(ctx context.Context) (interface{}, error) {
+		return s.callLimited(ctx, PoolTaskAPI, apiTask)
+	})
+	err = taskTimeoutError(ctx, taskCtx, s.Timeouts.Task, err)
+
+	result = TaskResult{
+		ID:       index,
+		Success:  err == nil,
+		Status:   TaskStatusSuccess,
+		Data:     s.ResultLimit.apply(ctx, index, data),
+		Duration: time.Since(taskStart).Milliseconds(),
+		Attempts: attempts,
+		Env:      newTaskEnv(s.Pools, PoolTaskAPI, urlHost(apiTask.URL), slot, attempts),
+	}
 
-	for i, task := range tasks {
-		wg.Add(1)
-		go func(index int, apiTask APICallTask) {
-			defer wg.Done()
-
-			// 获取信号量
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			taskStart := time.Now()
-
-			// 检查超时
-			select {
-			case <-ctx.Done():
-				resultCh <- TaskResult{
-					ID:       index,
-					Success:  false,
-					Error:    "任务超时",
-					Duration: time.Since(taskStart).Milliseconds(),
-				}
-				return
-			default:
-			}
-
-			// 调用API
-			data, err := s.CallAPI(apiTask)
-
-			result := TaskResult{
-				ID:       index,
-				Success:  err == nil,
-				Data:     data,
-				Duration: time.Since(taskStart).Milliseconds(),
-			}
-
-			if err != nil {
-				result.Error = err.Error()
-			}
-
-			resultCh <- result
-		}(i, task)
-	}
-
-	// 等待所有任务完成
-	go func() {
-		wg.Wait()
-		close(resultCh)
-	}()
-
-	// 收集结果
-	var results []TaskResult
-	successCount := 0
-
-	timeout := time.NewTimer(s.Timeout)
-	defer timeout.Stop()
-
-	for {
-		select {
-		case result, ok := <-resultCh:
-			if !ok {
-				goto DONE
-			}
-			results = append(results, result)
-			if result.Success {
-				successCount++
-			}
-		case <-timeout.C:
-			goto DONE
-		case <-ctx.Done():
-			goto DONE
-		}
+	if err != nil {
+		result.Status = failureStatus(taskCtx, err)
+		result.Error = err.Error()
+		result.classify(ctx, err)
+		captureFailure(ctx, result, apiTask, err)
 	}
 
-DONE:
-	// 按ID排序
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].ID < results[j].ID
-	})
+	return result
+}
+
+// retryPolicy 返回任务使用的重试策略：非幂等的请求（POST、PUT等且没有幂等键）超时或连接被重置时上游可能已经处理，
+// 只在请求未发出时重试，避免重复下单、重复扣款
+func (s *APICallService) retryPolicy(task APICallTask) *RetryPolicy {
+	if !s.Retry.Enabled() || idempotentRequest(task.Method, task.Headers) {
+		return s.Retry
+	}
+	policy := *s.Retry
+	retryable := s.Retry.Retryable
+	policy.Retryable = func(err error) bool {
+		return requestNotSent(err) && (retryable == nil || retryable(err))
+	}
+	return &policy
+}
+
+// resolveHost 从解析缓存中查询任务的主机，只在解析失败时返回错误
+func (s *APICallService) resolveHost(ctx context.Context, task APICallTask) error {
+	host := urlHost(task.URL)
+	if s.DNS == nil || host == "" {
+		return nil
+	}
+	_, err := s.DNS.Lookup(ctx, host)
+	var resolveErr *ResolveError
+	if errors.As(err, &resolveErr) {
+		return err
+	}
+	return nil
+}
 
-	return &BatchResult{
-		TotalTasks:   totalTasks,
-		SuccessTasks: successCount,
-		FailedTasks:  totalTasks - successCount,
-		Results:      results,
-		Duration:     time.Since(startTime).Milliseconds(),
+// preResolve 批次开始前并发解析全部任务的主机，解析结果留在缓存中供任务使用
+func (s *APICallService) preResolve(ctx context.Context, tasks []APICallTask) {
+	if s.DNS == nil {
+		return
+	}
+	hosts := make([]string, len(tasks))
+	for i, task := range tasks {
+		hosts[i] = urlHost(task.URL)
 	}
+	s.DNS.Prime(ctx, hosts)
+}
+
+// processor 返回执行API调用任务的批处理引擎
+func (s *APICallService) processor(ctx context.Context) *batch.Processor[APICallTask, TaskResult] {
+	return &batch.Processor[APICallTask, TaskResult]{
+		Mode:           s.Mode,
+		MaxConcurrency: jobMaxConcurrency(ctx, s.MaxConcurrency),
+		Timeout:        jobTimeout(ctx, s.Timeouts.Batch),
+		Ramp:           s.Ramp,
+		Priority:       func(t APICallTask) int { return t.Priority },
+		Run:            s.runAPITask,
+		Err:            TaskResult.err,
+		RateLimit:      s.RateLimit,
+		Concurrency:    jobConcurrency(ctx),
+		Chunks:         jobChunks(ctx),
+		OnResult:       reportProgress,
+	}
+}
+
+// BatchCallAPIs 批量调用API
+func (s *APICallService) BatchCallAPIs(ctx context.Context, tasks []APICallTask) *BatchResult {
+	startTime := time.Now()
+
+	ctx = withStartTracker(ctx, len(tasks))
+	ctx = withRetryBudget(ctx, len(tasks))
+	s.preResolve(ctx, tasks)
+	results := s.processor(ctx).Process(ctx, tasks)
+	return buildBatchResult(ctx, startTime, len(tasks), results)
 }
 
 // FileProcessService 文件处理服务
 type FileProcessService struct {
 	MaxConcurrency int
-	Timeout        time.Duration
+	Mode           batch.Mode         // 调度方式，默认每个任务一个协程；大批次可用 batch.ModeWorkerPool
+	Timeout        time.Duration      // 整个批次的预算，到期后未完成的任务记为超时或未开始
+	PerTaskTimeout time.Duration      // 单个任务的预算（含重试和退避），超时只让该任务失败，为0时只受批次预算限制
+	Retry          *RetryPolicy       // 为nil时不重试
+	RateLimit      *batch.RateLimiter // 每秒开始执行的任务数上限，该服务的所有批次共享；为nil时只受并发数限制
+	Pools          *WorkerPools       // 按 file 类型路由到的命名工作池，如与其他磁盘任务共享的 disk-io；为nil时不使用
+	ResultLimit    ResultLimit
 	UploadDir      string
+	UploadPolicy   UploadPolicy
+	OnConflict     string // 同名文件上传冲突的默认策略
+	ChecksumWorker int    // 计算校验和时并发读取的协程数
+	MinFreeBytes   int64  // 上传后剩余空间低于该值时拒绝上传，0表示不限制
+	WarnFreeBytes  int64  // 上传后剩余空间低于该值时返回告警，0表示不告警
+
+	// hash 批次的流水线并发度，IO 和 CPU 分别调节
+	HashIOConcurrency  int // 读取文件的协程数
+	HashCPUConcurrency int // 计算哈希的协程数
 }
 
 // FileTask 文件处理任务
@@ -354,18 +740,23 @@ type FileTask struct {
 	ID          int    `json:"id"`
 	FilePath    string `json:"file_path"`
 	FileName    string `json:"file_name"`
-	ProcessType string `json:"process_type"` // info, copy, move, compress
+	ProcessType string `json:"process_type"`       // info, copy, compress, hash
+	Version     int    `json:"version,omitempty"`  // 指定时按 file_name（原始文件名）处理对应版本
+	FileID      uint   `json:"file_id,omitempty"`  // 指定时按文件ID处理，无需关心存储路径
+	Priority    int    `json:"priority,omitempty"` // 优先级，数值越大越先执行，默认0
 }
 
-// ProcessFile 处理单个文件
-func (s *FileProcessService) ProcessFile(task FileTask) (interface{}, error) {
+// ProcessFile 处理单个文件，ctx 结束时中止等待和读写
+func (s *FileProcessService) ProcessFile(ctx context.Context, task FileTask) (interface{}, error) {
 	// 模拟文件处理时间
-	time.Sleep(time.Duration(200+task.ID*50) * time.Millisecond)
+	if err := sleepContext(ctx, time.Duration(200+task.ID*50)*time.Millisecond); err != nil {
+		return nil, fmt.Errorf("文件处理中止: %w", err)
+	}
 
 	// 获取文件信息
 	fileInfo, err := os.Stat(task.FilePath)
 	if err != nil {
-		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+		return nil, fmt.Errorf("获取文件信息失败: %w", err)
 	}
 
 	result := map[string]interface{}{
@@ -388,11 +779,17 @@ func (s *FileProcessService) ProcessFile(task FileTask) (interface{}, error) {
 	case "copy":
 		// 模拟文件复制
 		copyPath := filepath.Join(s.UploadDir, "copy_"+task.FileName)
-		err := s.copyFile(task.FilePath, copyPath)
+		err := s.copyFile(ctx, task.FilePath, copyPath)
 		if err != nil {
-			return nil, fmt.Errorf("复制文件失败: %v", err)
+			return nil, fmt.Errorf("复制文件失败: %w", err)
 		}
 		result["copy_path"] = copyPath
+	case "hash":
+		checksum, err := s.hashFile(ctx, task.FilePath)
+		if err != nil {
+			return nil, fmt.Errorf("计算哈希失败: %w", err)
+		}
+		result["sha256"] = checksum
 	case "compress":
 		// 模拟文件压缩（这里只是示例，实际项目中需要真正的压缩逻辑）
 		result["compressed_size"] = fileInfo.Size() / 2 // 模拟压缩后大小
@@ -404,8 +801,23 @@ func (s *FileProcessService) ProcessFile(task FileTask) (interface{}, error) {
 	return result, nil
 }
 
+// hashFile 计算文件的SHA-256
+func (s *FileProcessService) hashFile(ctx context.Context, path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, contextReader{ctx: ctx, r: file}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
 // copyFile 复制文件
-func (s *FileProcessService) copyFile(src, dst string) error {
+func (s *FileProcessService) copyFile(ctx context.Context, src, dst string) error {
 	sourceFile, err := os.Open(src)
 	if err != nil {
 		return err
@@ -418,112 +830,87 @@ func (s *FileProcessService) copyFile(src, dst string) error {
 	}
 	defer destFile.Close()
 
-	_, err = io.Copy(destFile, sourceFile)
-	return err
+	if _, err := io.Copy(destFile, contextReader{ctx: ctx, r: sourceFile}); err != nil {
+		// 中途失败或取消时删除不完整的副本
+		destFile.Close()
+		os.Remove(dst)
+		return err
+	}
+	return nil
 }
 
-// BatchProcessFiles 批量处理文件
-func (s *FileProcessService) BatchProcessFiles(ctx context.Context, tasks []FileTask) *BatchResult {
-	startTime := time.Now()
-	totalTasks := len(tasks)
+// runFileTask 在指定工作槽位上处理单个文件（含取消检查和重试）
+func (s *FileProcessService) runFileTask(ctx context.Context, index, slot int, fileTask FileTask) (result TaskResult) {
+	taskStart := time.Now()
+	defer recoverTask(ctx, index, taskStart, &result)
 
-	if totalTasks == 0 {
-		return &BatchResult{
-			TotalTasks: 0,
-			Results:    []TaskResult{},
-			Duration:   time.Since(startTime).Milliseconds(),
+	// 检查是否已取消或超时
+	if result, ok := checkDispatch(ctx, index, taskStart); !ok {
+		return result
+	}
+	ctx, untrack := trackInflight(ctx, index, slot)
+	defer untrack()
+
+	// 处理文件，单个文件超时不影响批次中的其他文件
+	taskCtx, cancel := withTaskTimeout(ctx, s.PerTaskTimeout)
+	defer cancel()
+	data, attempts, err := runWithRetry(taskCtx, s.Retry, strconv.Itoa(index), func(ctx context.Context) (interface{}, error) {
+		release, err := s.Pools.Acquire(ctx, PoolTaskFile, "")
+		if err != nil {
+			return nil, err
 		}
+		defer release()
+		return s.ProcessFile(ctx, fileTask)
+	})
+	err = taskTimeoutError(ctx, taskCtx, s.PerTaskTimeout, err)
+
+	result = TaskResult{
+		ID:       index,
+		Success:  err == nil,
+		Status:   TaskStatusSuccess,
+		Data:     s.ResultLimit.apply(ctx, index, data),
+		Duration: time.Since(taskStart).Milliseconds(),
+		Attempts: attempts,
+		Env:      newTaskEnv(s.Pools, PoolTaskFile, "", slot, attempts),
+	}
+
+	if err != nil {
+		result.Status = failureStatus(taskCtx, err)
+		result.Error = err.Error()
+		result.classify(ctx, err)
+		captureFailure(ctx, result, fileTask, err)
 	}
 
-	resultCh := make(chan TaskResult, totalTasks)
-	var wg sync.WaitGroup
+	return result
+}
 
-	// 限制并发数
-	semaphore := make(chan struct{}, s.MaxConcurrency)
+// processor 返回执行文件任务的批处理引擎
+func (s *FileProcessService) processor(ctx context.Context) *batch.Processor[FileTask, TaskResult] {
+	return &batch.Processor[FileTask, TaskResult]{
+		Mode:           s.Mode,
+		MaxConcurrency: jobMaxConcurrency(ctx, s.MaxConcurrency),
+		Timeout:        jobTimeout(ctx, s.Timeout),
+		Priority:       func(t FileTask) int { return t.Priority },
+		Run:            s.runFileTask,
+		Err:            TaskResult.err,
+		RateLimit:      s.RateLimit,
+		Concurrency:    jobConcurrency(ctx),
+		Chunks:         jobChunks(ctx),
+		OnResult:       reportProgress,
+	}
+}
 
-	for i, task := range tasks {
-		wg.Add(1)
-		go func(index int, fileTask FileTask) {
-			defer wg.Done()
-
-			// 获取信号量
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			taskStart := time.Now()
-
-			// 检查超时
-			select {
-			case <-ctx.Done():
-				resultCh <- TaskResult{
-					ID:       index,
-					Success:  false,
-					Error:    "任务超时",
-					Duration: time.Since(taskStart).Milliseconds(),
-				}
-				return
-			default:
-			}
-
-			// 处理文件
-			data, err := s.ProcessFile(fileTask)
-
-			result := TaskResult{
-				ID:       index,
-				Success:  err == nil,
-				Data:     data,
-				Duration: time.Since(taskStart).Milliseconds(),
-			}
-
-			if err != nil {
-				result.Error = err.Error()
-			}
-
-			resultCh <- result
-		}(i, task)
-	}
-
-	// 等待所有任务完成
-	go func() {
-		wg.Wait()
-		close(resultCh)
-	}()
-
-	// 收集结果
-	var results []TaskResult
-	successCount := 0
-
-	timeout := time.NewTimer(s.Timeout)
-	defer timeout.Stop()
-
-	for {
-		select {
-		case result, ok := <-resultCh:
-			if !ok {
-				goto DONE
-			}
-			results = append(results, result)
-			if result.Success {
-				successCount++
-			}
-		case <-timeout.C:
-			goto DONE
-		case <-ctx.Done():
-			goto DONE
-		}
+// BatchProcessFiles 批量处理文件
+func (s *FileProcessService) BatchProcessFiles(ctx context.Context, tasks []FileTask) *BatchResult {
+	// 全部为 hash 任务时使用读取/哈希两阶段流水线
+	if isHashBatch(tasks) {
+		return s.batchHashFiles(ctx, tasks)
 	}
 
-DONE:
-	// 按ID排序
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].ID < results[j].ID
-	})
+	startTime := time.Now()
 
-	return &BatchResult{
-		TotalTasks:   totalTasks,
-		SuccessTasks: successCount,
-		FailedTasks:  totalTasks - successCount,
-		Results:      results,
-		Duration:     time.Since(startTime).Milliseconds(),
-	}
+	ctx = withStartTracker(ctx, len(tasks))
+	ctx = withRetryBudget(ctx, len(tasks))
+	results := s.processor(ctx).Process(ctx, tasks)
+	return buildBatchResult(ctx, startTime, len(tasks), results)
 }
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// ErrStreamClosed 批次已关闭，不能再提交任务
+var ErrStreamClosed = batch.ErrClosed
+
+// BatchStream 增量提交任务、逐个返回结果的批次，适用于任务列表事先未知的生产者/消费者场景
+// 基于 batch.Stream，在其上汇报任务进度并汇总为 BatchResult，与一次性提交的批量处理共用同一套任务执行逻辑
+type BatchStream struct {
+	ctx       context.Context
+	startTime time.Time
+	stream    *batch.Stream[TaskResult]
+
+	mu      sync.Mutex
+	results []TaskResult
+}
+
+// NewBatchStream 创建流式批次，ctx 取消后未执行的任务按取消或超时处理
+// 任务总数事先未知，每提交一个任务累加到所属任务的总数中
+func NewBatchStream(ctx context.Context, maxConcurrency int) *BatchStream {
+	b := &BatchStream{ctx: ctx, startTime: time.Now()}
+	hooks := batch.Hooks[TaskResult]{
+		OnResult: func(ctx context.Context, result TaskResult) {
+			b.mu.Lock()
+			b.results = append(b.results, result)
+			b.mu.Unlock()
+			reportProgress(ctx, result)
+		},
+	}
+	if job := JobFromContext(ctx); job != nil {
+		hooks.OnSubmit = func(int) { job.addTasks(1) }
+	}
+	b.stream = batch.NewStream(ctx, maxConcurrency, hooks)
+	return b
+}
+
+// SetMaxPending 限制已提交但尚未结束的任务数，达到上限时提交阻塞到有任务结束；应在提交任务之前调用
+func (b *BatchStream) SetMaxPending(n int) {
+	b.stream.SetMaxPending(n)
+}
+
+// Results 返回结果通道，Close 之后全部任务结束时关闭
+// 消费者必须持续读取，否则会阻塞任务执行
+func (b *BatchStream) Results() <-chan TaskResult {
+	return b.stream.Results()
+}
+
+// Close 停止接收新任务，已提交的任务继续执行
+func (b *BatchStream) Close() {
+	b.stream.Close()
+}
+
+// Result 汇总批次结果，应在 Results 通道关闭后调用
+func (b *BatchStream) Result() *BatchResult {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return buildBatchResult(b.ctx, b.startTime, b.stream.Submitted(), b.results)
+}
+
+// eachResult 以 processor 执行任务，结果逐个交给 emit 后丢弃，返回不含任务明细的汇总
+func eachResult[T any](ctx context.Context, processor *batch.Processor[T, TaskResult], tasks []T, emit func(TaskResult)) *BatchResult {
+	startTime := time.Now()
+	ctx = withStartTracker(ctx, len(tasks))
+	ctx = withRetryBudget(ctx, len(tasks))
+	var tally batchTally
+	// 要求按任务序号输出时先暂存先完成的结果，批次结束后输出剩余的暂存结果
+	if jobResultOrder(ctx) == ResultOrderInput {
+		reorder := &batch.Reorder[TaskResult]{Index: func(r TaskResult) int { return r.ID }, Emit: emit}
+		defer reorder.Flush()
+		emit = reorder.Add
+	}
+	processor.Each(ctx, tasks, func(result TaskResult) {
+		tally.add(ctx, result)
+		emit(result)
+	})
+	return tally.summarize(ctx, startTime, len(tasks))
+}
+
+// EachOrder 批量处理订单，每完成一个任务调用 emit，不在内存中保留结果
+// 返回的汇总只有计数，Results 为空，适合逐行输出超大批次
+func (s *OrderProcessService) EachOrder(ctx context.Context, orders []OrderTask, emit func(TaskResult)) *BatchResult {
+	return eachResult(ctx, s.processor(ctx, orders), orders, emit)
+}
+
+// EachAPICall 批量调用API，每完成一个任务调用 emit，不在内存中保留结果
+func (s *APICallService) EachAPICall(ctx context.Context, tasks []APICallTask, emit func(TaskResult)) *BatchResult {
+	s.preResolve(ctx, tasks)
+	return eachResult(ctx, s.processor(ctx), tasks, emit)
+}
+
+// EachFile 批量处理文件，每完成一个任务调用 emit，不在内存中保留结果
+func (s *FileProcessService) EachFile(ctx context.Context, tasks []FileTask, emit func(TaskResult)) *BatchResult {
+	return eachResult(ctx, s.processor(ctx), tasks, emit)
+}
+
+// SubmitOrder 向流式批次提交一个订单
+func (s *OrderProcessService) SubmitOrder(stream *BatchStream, task OrderTask) (int, error) {
+	return stream.stream.Submit(func(ctx context.Context, index, slot int) TaskResult {
+		return s.runOrderTask(ctx, index, slot, task)
+	})
+}
+
+// SubmitAPICall 向流式批次提交一个API调用
+func (s *APICallService) SubmitAPICall(stream *BatchStream, task APICallTask) (int, error) {
+	return stream.stream.Submit(func(ctx context.Context, index, slot int) TaskResult {
+		return s.runAPITask(ctx, index, slot, task)
+	})
+}
+
+// SubmitFile 向流式批次提交一个文件处理任务
+func (s *FileProcessService) SubmitFile(stream *BatchStream, task FileTask) (int, error) {
+	return stream.stream.Submit(func(ctx context.Context, index, slot int) TaskResult {
+		return s.runFileTask(ctx, index, slot, task)
+	})
+}
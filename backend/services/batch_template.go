@@ -0,0 +1,126 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTemplateNotFound 模板不存在或属于其他用户
+	ErrTemplateNotFound = errors.New("模板不存在")
+	// ErrTemplateExists 同一用户的模板名称已存在
+	ErrTemplateExists = errors.New("模板名称已存在")
+	// ErrInvalidTemplate 模板参数不正确
+	ErrInvalidTemplate = errors.New("模板参数不正确")
+)
+
+// TemplateTargets 模板可以执行的批量接口及其请求中任务列表的字段名
+var TemplateTargets = map[string]string{
+	"orders":   "orders",
+	"apis":     "apis",
+	"files":    "files",
+	"pipeline": "items",
+}
+
+// TemplateService 批次模板：保存批量接口的请求体（任务列表和执行选项），演示场景和定期执行的批次不必每次重新提交
+// 模板按用户隔离，只能查看、修改和执行自己的模板
+type TemplateService struct {
+	DB     *gorm.DB
+	ReadDB *gorm.DB // 只读副本，为nil时使用 DB
+}
+
+// CheckTemplateRequest 检查模板的请求体：须为 JSON 对象，且任务列表字段为非空数组；其余字段在执行时由批量接口校验
+func CheckTemplateRequest(target string, request json.RawMessage) error {
+	field, ok := TemplateTargets[target]
+	if !ok {
+		return fmt.Errorf("%w: 不支持的 target %q，可选 orders、apis、files、pipeline", ErrInvalidTemplate, target)
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(request, &body); err != nil || body == nil {
+		return fmt.Errorf("%w: request 须为 JSON 对象", ErrInvalidTemplate)
+	}
+	var tasks []json.RawMessage
+	if err := json.Unmarshal(body[field], &tasks); err != nil || len(tasks) == 0 {
+		return fmt.Errorf("%w: request.%s 须为非空数组", ErrInvalidTemplate, field)
+	}
+	return nil
+}
+
+// List 列出用户的模板，按名称排序
+func (s *TemplateService) List(owner string) ([]models.BatchTemplate, error) {
+	var templates []models.BatchTemplate
+	err := readerDB(s.DB, s.ReadDB).Where("owner = ?", owner).Order("name").Find(&templates).Error
+	return templates, err
+}
+
+// Get 读取用户的模板
+func (s *TemplateService) Get(id uint, owner string) (*models.BatchTemplate, error) {
+	var template models.BatchTemplate
+	err := readerDB(s.DB, s.ReadDB).Where("id = ? AND owner = ?", id, owner).First(&template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// Save 创建或更新模板，template.ID 为 0 时创建；更新时只能修改 template.Owner 自己的模板
+func (s *TemplateService) Save(template *models.BatchTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return fmt.Errorf("%w: 名称不能为空", ErrInvalidTemplate)
+	}
+	if err := CheckTemplateRequest(template.Target, json.RawMessage(template.Request)); err != nil {
+		return err
+	}
+	if template.SummaryDays < 0 || template.DetailDays < 0 {
+		return fmt.Errorf("%w: 保留天数不能为负数", ErrInvalidTemplate)
+	}
+	if template.SummaryDays > 0 && template.DetailDays > template.SummaryDays {
+		return fmt.Errorf("%w: 明细的保留天数不能超过任务记录的保留天数", ErrInvalidTemplate)
+	}
+
+	var count int64
+	if err := s.DB.Model(&models.BatchTemplate{}).
+		Where("owner = ? AND name = ? AND id <> ?", template.Owner, template.Name, template.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTemplateExists
+	}
+
+	if template.ID == 0 {
+		return s.DB.Create(template).Error
+	}
+	var existing models.BatchTemplate
+	err := s.DB.Where("id = ? AND owner = ?", template.ID, template.Owner).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrTemplateNotFound
+	}
+	if err != nil {
+		return err
+	}
+	template.CreatedAt = existing.CreatedAt
+	return s.DB.Model(template).Select("name", "description", "target", "request", "summary_days", "detail_days", "updated_at").Updates(template).Error
+}
+
+// Delete 删除用户的模板
+func (s *TemplateService) Delete(id uint, owner string) error {
+	result := s.DB.Where("owner = ?", owner).Delete(&models.BatchTemplate{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// sleepContext 等待 d，ctx 先结束时提前返回 ctx 的错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// contextReader 每次读取前检查 ctx，任务取消后复制大文件也能及时中止；读到数据时发送任务心跳
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	if n > 0 {
+		Heartbeat(r.ctx)
+	}
+	return n, err
+}
@@ -0,0 +1,449 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// RedactedCustomerID 匿名化后替换客户ID的值
+const RedactedCustomerID = "[已删除]"
+
+// ErrInvalidCustomerID 客户ID为空或过长
+var ErrInvalidCustomerID = errors.New("客户ID不能为空且不超过100个字符")
+
+// DeletionReport 客户数据删除的结果
+type DeletionReport struct {
+	CustomerID          string   `json:"customer_id"`
+	OrdersDeleted       int64    `json:"orders_deleted"`        // 删除的订单记录
+	APICallsDeleted     int      `json:"api_calls_deleted"`     // URL 或响应体中含客户ID、整个删除的API调用记录
+	ArtifactsScanned    int      `json:"artifacts_scanned"`     // 检查的任务产出物（含冷存储归档）
+	ArtifactsRedacted   int      `json:"artifacts_redacted"`    // 匿名化的任务产出物
+	JobsRedacted        []string `json:"jobs_redacted"`         // 内存中或产出物里结果被匿名化的任务
+	ResultsRedacted     int      `json:"results_redacted"`      // 匿名化的任务结果条数（内存中的结果和产出物分别计数）
+	AccessLogsRedacted  int64    `json:"access_logs_redacted"`  // 路径中的客户ID被替换的访问日志
+	DebugBundles        int      `json:"debug_bundles"`         // 删除的失败任务调试包
+	FullResultsRedacted int      `json:"full_results_redacted"` // 匿名化的超出大小限制的完整结果
+	FullResultsDeleted  int      `json:"full_results_deleted"`  // 匿名化后字符串中仍含客户ID（如响应体）、整个删除的完整结果
+	Errors              []string `json:"errors,omitempty"`      // 未能处理的部分，重新提交删除请求即可重试
+	Duration            int64    `json:"duration"`              // 毫秒
+}
+
+// CustomerDataService 删除或匿名化已保存的数据中引用某个客户ID的部分
+//
+// 订单记录和 URL 或响应体中含客户ID的API调用记录直接删除；任务结果（内存中的结果和事件、磁盘上的产出物）中值等于客户ID的字段替换为 RedactedCustomerID，
+// 保留批次的计数和其他字段；访问日志路径中的客户ID同样替换。订单汇总只按商品和失败原因统计，不含客户ID。
+// 执行中的批次不在处理范围内，结束后需要再次删除
+type CustomerDataService struct {
+	DB        *gorm.DB
+	Jobs      *JobManager
+	Artifacts *ArtifactService
+	Debug     *DebugCapture // 调试包保存完整的任务输入，含客户ID的整个删除
+	// FullResults 超出大小限制的完整结果，与产出物一样匿名化；字符串中仍含客户ID的整个删除
+	FullResults ResultLimit
+}
+
+// Delete 删除客户的数据，各部分互不影响，失败的部分记录在报告的 Errors 中
+func (s *CustomerDataService) Delete(ctx context.Context, customerID string) (*DeletionReport, error) {
+	if strings.TrimSpace(customerID) == "" || len(customerID) > 100 {
+		return nil, ErrInvalidCustomerID
+	}
+	start := time.Now()
+	report := &DeletionReport{CustomerID: customerID, JobsRedacted: []string{}}
+	jobs := make(map[string]bool)
+	fail := func(part string, err error) {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", part, err))
+	}
+
+	result := s.DB.Where("customer_id = ?", customerID).Delete(&models.Order{})
+	if result.Error != nil {
+		fail("订单", result.Error)
+	}
+	report.OrdersDeleted = result.RowsAffected
+
+	// LIKE 中的 % 和 _ 需要转义，客户ID常含下划线
+	pattern := "%" + escapeLike(customerID) + "%"
+	deleted, err := s.deleteAPICalls(customerID, pattern)
+	if err != nil {
+		fail("API调用记录", err)
+	}
+	report.APICallsDeleted = deleted
+
+	for jobID, n := range s.Jobs.redactResults(customerID) {
+		jobs[jobID] = true
+		report.ResultsRedacted += n
+	}
+
+	if err := s.Artifacts.redactCustomer(ctx, customerID, report, jobs); err != nil {
+		fail("任务产出物", err)
+	}
+
+	result = s.DB.Model(&models.AccessLog{}).
+		Where(`path LIKE ? ESCAPE '\'`, pattern).
+		Update("path", gorm.Expr("REPLACE(path, ?, ?)", customerID, RedactedCustomerID))
+	if result.Error != nil {
+		fail("访问日志", result.Error)
+	}
+	report.AccessLogsRedacted = result.RowsAffected
+
+	deleted, err = s.Debug.deleteCustomer(customerID)
+	if err != nil {
+		fail("调试包", err)
+	}
+	report.DebugBundles = deleted
+
+	if err := s.FullResults.redactCustomer(customerID, report, jobs); err != nil {
+		fail("完整结果", err)
+	}
+
+	for jobID := range jobs {
+		report.JobsRedacted = append(report.JobsRedacted, jobID)
+	}
+	sort.Strings(report.JobsRedacted)
+	report.Duration = time.Since(start).Milliseconds()
+	return report, nil
+}
+
+// deleteAPICalls 删除 URL 或响应体中含客户ID的API调用记录，pattern 为转义后的 LIKE 模式，按单词边界确认后删除
+func (s *CustomerDataService) deleteAPICalls(customerID, pattern string) (int, error) {
+	var calls []models.APICall
+	err := s.DB.Select("id", "url", "response_body").
+		Where(`url LIKE ? ESCAPE '\' OR response_body LIKE ? ESCAPE '\'`, pattern, pattern).
+		Find(&calls).Error
+	if err != nil {
+		return 0, err
+	}
+	var ids []uint
+	for _, call := range calls {
+		if containsID([]byte(call.URL), customerID) || containsID([]byte(call.ResponseBody), customerID) {
+			ids = append(ids, call.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := s.DB.Delete(&models.APICall{}, ids).Error; err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// redactValue 将值等于 id 的字符串替换为 RedactedCustomerID，返回替换的个数
+func redactValue(v interface{}, id string) (interface{}, int) {
+	switch value := v.(type) {
+	case string:
+		if value == id {
+			return RedactedCustomerID, 1
+		}
+	case map[string]interface{}:
+		n := 0
+		for key, item := range value {
+			redacted, count := redactValue(item, id)
+			value[key] = redacted
+			n += count
+		}
+		return value, n
+	case []interface{}:
+		n := 0
+		for i, item := range value {
+			redacted, count := redactValue(item, id)
+			value[i] = redacted
+			n += count
+		}
+		return value, n
+	}
+	return v, 0
+}
+
+// redactData 返回匿名化后的任务结果数据，不修改原数据；不含客户ID时返回 false
+func redactData(data interface{}, id string) (interface{}, bool) {
+	if data == nil {
+		return nil, false
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return data, false
+	}
+	quoted, _ := json.Marshal(id)
+	if !bytes.Contains(raw, quoted) {
+		return data, false
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return data, false
+	}
+	redacted, n := redactValue(generic, id)
+	return redacted, n > 0
+}
+
+// redactResults 匿名化已结束任务的结果和事件缓冲中的任务结果，返回各任务匿名化的结果条数
+// 只有事件被匿名化的任务条数为 0
+func (m *JobManager) redactResults(id string) map[string]int {
+	m.mu.RLock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.mu.RUnlock()
+
+	redacted := make(map[string]int)
+	for _, job := range jobs {
+		n := job.redactResult(id)
+		if job.events.redact(id) || n > 0 {
+			redacted[job.ID()] = n
+		}
+	}
+	return redacted
+}
+
+// redactResult 匿名化任务的最终结果，返回匿名化的结果条数
+// 结果替换为副本，正在读取旧结果的请求不受影响
+func (j *Job) redactResult(id string) int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.result == nil {
+		return 0
+	}
+	var results []TaskResult
+	n := 0
+	for i, r := range j.result.Results {
+		data, ok := redactData(r.Data, id)
+		if !ok {
+			continue
+		}
+		if results == nil {
+			results = append([]TaskResult(nil), j.result.Results...)
+		}
+		results[i].Data = data
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	copied := *j.result
+	copied.Results = results
+	j.result = &copied
+	return n
+}
+
+// redact 匿名化缓冲中的任务结果事件，返回是否有事件被修改
+func (l *eventLog) redact(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	changed := false
+	for i, event := range l.events {
+		result, ok := event.Data.(TaskResult)
+		if event.Type != JobEventResult || !ok {
+			continue
+		}
+		if data, ok := redactData(result.Data, id); ok {
+			result.Data = data
+			l.events[i].Data = result
+			changed = true
+		}
+	}
+	return changed
+}
+
+// redactCustomer 匿名化产出物中的客户ID，已加密的产出物解密后处理再以原租户的数据密钥加密
+// 与归档互斥执行，避免处理过程中产出物被移入冷存储
+func (s *ArtifactService) redactCustomer(ctx context.Context, id string, report *DeletionReport, jobs map[string]bool) error {
+	if s.Locks != nil {
+		release, err := s.Locks.Lock(ctx, models.LockArchiver)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	var artifacts []models.JobArtifact
+	if err := s.DB.Find(&artifacts).Error; err != nil {
+		return err
+	}
+	var errs []string
+	for i := range artifacts {
+		report.ArtifactsScanned++
+		n, err := s.redactArtifact(&artifacts[i], id)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", artifacts[i].JobID, err))
+			continue
+		}
+		if n > 0 {
+			report.ArtifactsRedacted++
+			report.ResultsRedacted += n
+			jobs[artifacts[i].JobID] = true
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// redactArtifact 匿名化单个产出物，返回匿名化的任务结果条数
+func (s *ArtifactService) redactArtifact(artifact *models.JobArtifact, id string) (int, error) {
+	var reader io.ReadCloser
+	var err error
+	if artifact.StorageClass == StorageClassCold {
+		reader, err = s.Cold.Get(artifact.ArchiveKey)
+	} else {
+		reader, err = os.Open(artifact.Path)
+	}
+	if err != nil {
+		return 0, err
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return 0, err
+	}
+	if data, err = s.Cipher.Open(artifact.JobID, data); err != nil {
+		return 0, err
+	}
+
+	quoted, _ := json.Marshal(id)
+	if !bytes.Contains(data, quoted) {
+		return 0, nil
+	}
+	var saved struct {
+		Job    JobInfo                `json:"job"`
+		Result map[string]interface{} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return 0, err
+	}
+	results, _ := saved.Result["results"].([]interface{})
+	redacted := 0
+	for _, r := range results {
+		if result, ok := r.(map[string]interface{}); ok {
+			if _, n := redactValue(result["data"], id); n > 0 {
+				redacted++
+			}
+		}
+	}
+	if redacted == 0 {
+		return 0, nil
+	}
+
+	if data, err = json.Marshal(map[string]interface{}{"job": saved.Job, "result": saved.Result}); err != nil {
+		return 0, err
+	}
+	if data, err = s.Cipher.sealResult(saved.Job, artifact.JobID, data); err != nil {
+		return 0, err
+	}
+	if artifact.StorageClass == StorageClassCold {
+		err = s.Cold.Put(artifact.ArchiveKey, bytes.NewReader(data))
+	} else {
+		err = os.WriteFile(artifact.Path, data, 0644)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return redacted, s.DB.Model(artifact).Update("size", len(data)).Error
+}
+
+// redactCustomer 匿名化完整结果中值等于客户ID的字段，加密的完整结果解密处理后以原租户的数据密钥重新加密；
+// 匿名化后仍含客户ID（如在响应体字符串中）的整个删除
+func (l ResultLimit) redactCustomer(id string, report *DeletionReport, jobs map[string]bool) error {
+	if l.Dir == "" {
+		return nil
+	}
+	var errs []string
+	err := filepath.WalkDir(l.Dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		redacted, deleted, err := l.redactFile(path, id)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+			return nil
+		}
+		if redacted || deleted {
+			jobs[filepath.Base(filepath.Dir(path))] = true
+		}
+		if redacted {
+			report.FullResultsRedacted++
+		}
+		if deleted {
+			report.FullResultsDeleted++
+		}
+		return nil
+	})
+	if err == nil && len(errs) > 0 {
+		err = errors.New(strings.Join(errs, "; "))
+	}
+	return err
+}
+
+// redactFile 匿名化单个完整结果，返回是否匿名化、是否整个删除
+func (l ResultLimit) redactFile(path, id string) (redacted, deleted bool, err error) {
+	sealed, err := os.ReadFile(path)
+	if err != nil {
+		return false, false, err
+	}
+	data, err := l.Cipher.Open(path, sealed)
+	if err != nil {
+		return false, false, err
+	}
+	if !containsID(data, id) {
+		return false, false, nil
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return false, false, err
+	}
+	if generic, n := redactValue(generic, id); n > 0 {
+		if data, err = json.Marshal(generic); err != nil {
+			return false, false, err
+		}
+		redacted = true
+	}
+	if containsID(data, id) {
+		return false, true, os.Remove(path)
+	}
+
+	var envelope ResultEnvelope
+	if json.Unmarshal(sealed, &envelope) == nil && envelope.Envelope != "" {
+		if data, err = l.Cipher.Seal(envelope.TenantID, path, data); err != nil {
+			return false, false, err
+		}
+	}
+	return redacted, false, os.WriteFile(path, data, 0644)
+}
+
+// containsID data 中是否出现客户ID，前后紧邻字母、数字、下划线或连字符的不算（如 CUST_0001 不匹配 CUST_00010）
+func containsID(data []byte, id string) bool {
+	word := func(b byte) bool {
+		return b == '_' || b == '-' || b >= '0' && b <= '9' || b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z'
+	}
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], []byte(id))
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(id)
+		if (start == 0 || !word(data[start-1])) && (end == len(data) || !word(data[end])) {
+			return true
+		}
+		offset = start + 1
+	}
+}
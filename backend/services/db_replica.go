@@ -0,0 +1,12 @@
+package services
+
+import "gorm.io/gorm"
+
+// readerDB 查询接口使用的连接，配置了只读副本时走副本，否则走主库
+// 只用于列表、搜索、统计等只读接口；写入后需要立即读到结果的地方仍使用主库
+func readerDB(primary, replica *gorm.DB) *gorm.DB {
+	if replica != nil {
+		return replica
+	}
+	return primary
+}
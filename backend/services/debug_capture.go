@@ -0,0 +1,268 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrDebugBundleNotFound 任务没有该序号的调试包
+var ErrDebugBundleNotFound = errors.New("调试包不存在")
+
+// DebugBundle 失败任务的调试包：完整输入、执行环境和下游响应，用于事后复现
+type DebugBundle struct {
+	JobID       string              `json:"job_id"`
+	JobType     string              `json:"job_type"`
+	TaskID      int                 `json:"task_id"`
+	CapturedAt  time.Time           `json:"captured_at"`
+	Input       interface{}         `json:"input"`
+	Result      TaskResult          `json:"result"` // 含错误和每次尝试的记录
+	Environment DebugEnvironment    `json:"environment"`
+	Response    *DownstreamResponse `json:"response,omitempty"` // 最后一次尝试的下游响应，没有时为空
+}
+
+// DebugEnvironment 任务失败时的执行环境
+type DebugEnvironment struct {
+	Host           string     `json:"host"`
+	PID            int        `json:"pid"`
+	GoVersion      string     `json:"go_version"`
+	CodeVersion    string     `json:"code_version"`
+	Run            *RunRecord `json:"run,omitempty"` // 批次的种子和配置快照
+	Attempts       int        `json:"attempts"`      // 已进行的尝试次数
+	MaxConcurrency int        `json:"max_concurrency,omitempty"`
+	TenantID       uint       `json:"tenant_id,omitempty"`
+}
+
+// DownstreamResponse 下游返回的响应
+type DownstreamResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	Truncated  bool        `json:"truncated,omitempty"` // 响应体超出 MaxResponseBytes 被截断
+}
+
+// DebugBundleInfo 调试包列表中的一项
+type DebugBundleInfo struct {
+	TaskID     int       `json:"task_id"`
+	Size       int64     `json:"size"`
+	CapturedAt time.Time `json:"captured_at"`
+}
+
+// DebugCapture 批次启用调试捕获时，为失败和超时的任务保存调试包
+// 调试包按任务ID分目录保存，配置了结果加密时按租户加密（调试包含任务的完整输入）
+type DebugCapture struct {
+	Dir              string
+	Cipher           *ResultCipher
+	MaxResponseBytes int // 保存的下游响应体上限，默认64KB
+}
+
+// EnableDebugCapture 为失败的任务保存调试包
+func (j *Job) EnableDebugCapture() {
+	j.mu.Lock()
+	j.debugCapture = true
+	j.mu.Unlock()
+}
+
+// captureFailure 批次启用调试捕获且任务失败或超时时保存调试包，保存失败只记录日志
+func captureFailure(ctx context.Context, result TaskResult, input interface{}, err error) {
+	if result.Status != TaskStatusFailed && result.Status != TaskStatusTimeout {
+		return
+	}
+	job := JobFromContext(ctx)
+	if job == nil || job.debug == nil {
+		return
+	}
+	job.mu.RLock()
+	enabled := job.debugCapture
+	job.mu.RUnlock()
+	if !enabled {
+		return
+	}
+	if err := job.debug.save(job, result, input, err); err != nil {
+		log.Printf("保存任务 %s 子任务 %d 的调试包失败: %v", job.ID(), result.ID, err)
+	}
+}
+
+// save 生成并写入调试包
+func (d *DebugCapture) save(job *Job, result TaskResult, input interface{}, taskErr error) error {
+	info := job.Info()
+	host, _ := os.Hostname()
+	bundle := DebugBundle{
+		JobID:      info.ID,
+		JobType:    info.Type,
+		TaskID:     result.ID,
+		CapturedAt: time.Now(),
+		Input:      debugInput(input),
+		Result:     result,
+		Environment: DebugEnvironment{
+			Host:           host,
+			PID:            os.Getpid(),
+			GoVersion:      runtime.Version(),
+			CodeVersion:    currentCodeVersion(),
+			Run:            info.Run,
+			Attempts:       max(len(result.Attempts), 1),
+			MaxConcurrency: info.MaxConcurrency,
+			TenantID:       info.TenantID,
+		},
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(taskErr, &statusErr) {
+		bundle.Response = d.response(statusErr)
+	}
+
+	raw, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := d.path(info.ID, result.ID)
+	// 与超出大小限制的完整结果相同，以文件位置作为附加数据
+	sealed, err := d.Cipher.sealResult(info, path, raw)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, sealed, 0600); err != nil {
+		return err
+	}
+
+	job.mu.Lock()
+	job.info.DebugBundles++
+	job.mu.Unlock()
+	return nil
+}
+
+// credentialHeaders 调试包中隐去值的请求头
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-API-Key"}
+
+// debugInput 返回写入调试包的任务输入，API调用的凭据类请求头隐去值
+func debugInput(input interface{}) interface{} {
+	task, ok := input.(APICallTask)
+	if !ok || len(task.Headers) == 0 {
+		return input
+	}
+	headers := make(map[string]string, len(task.Headers))
+	for key, value := range task.Headers {
+		for _, name := range credentialHeaders {
+			if strings.EqualFold(key, name) {
+				value = "[已隐去]"
+			}
+		}
+		headers[key] = value
+	}
+	task.Headers = headers
+	return task
+}
+
+// response 由上游状态码错误生成下游响应，响应体超出上限时截断
+func (d *DebugCapture) response(statusErr *HTTPStatusError) *DownstreamResponse {
+	limit := d.MaxResponseBytes
+	if limit <= 0 {
+		limit = 64 << 10
+	}
+	response := &DownstreamResponse{StatusCode: statusErr.StatusCode, Header: statusErr.Header}
+	body := string(statusErr.Body)
+	if len(body) > limit {
+		body = truncateUTF8(body, limit)
+		response.Truncated = true
+	}
+	response.Body = body
+	return response
+}
+
+func (d *DebugCapture) path(jobID string, taskID int) string {
+	return filepath.Join(d.Dir, jobID, fmt.Sprintf("task_%d.json", taskID))
+}
+
+// List 列出任务的调试包，按任务序号排列
+func (d *DebugCapture) List(jobID string) ([]DebugBundleInfo, error) {
+	entries, err := os.ReadDir(filepath.Join(d.Dir, jobID))
+	if errors.Is(err, os.ErrNotExist) {
+		return []DebugBundleInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	bundles := make([]DebugBundleInfo, 0, len(entries))
+	for _, entry := range entries {
+		name := strings.TrimSuffix(strings.TrimPrefix(entry.Name(), "task_"), ".json")
+		taskID, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		bundles = append(bundles, DebugBundleInfo{TaskID: taskID, Size: stat.Size(), CapturedAt: stat.ModTime()})
+	}
+	sort.Slice(bundles, func(i, k int) bool { return bundles[i].TaskID < bundles[k].TaskID })
+	return bundles, nil
+}
+
+// Load 读取并解密调试包
+func (d *DebugCapture) Load(jobID string, taskID int) ([]byte, error) {
+	path := d.path(jobID, taskID)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrDebugBundleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.Cipher.Open(path, data)
+}
+
+// deleteCustomer 删除包含客户ID的调试包，调试包用于排查问题，不做匿名化；返回删除的个数
+func (d *DebugCapture) deleteCustomer(id string) (int, error) {
+	if d == nil {
+		return 0, nil
+	}
+	quoted, _ := json.Marshal(id)
+	deleted := 0
+	var errs []string
+	err := filepath.WalkDir(d.Dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err == nil {
+			data, err = d.Cipher.Open(path, data)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+			return nil
+		}
+		if !bytes.Contains(data, quoted) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", path, err))
+			return nil
+		}
+		deleted++
+		return nil
+	})
+	if err == nil && len(errs) > 0 {
+		err = errors.New(strings.Join(errs, "; "))
+	}
+	return deleted, err
+}
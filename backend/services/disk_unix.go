@@ -0,0 +1,14 @@
+//go:build !windows
+
+package services
+
+import "syscall"
+
+// VolumeStats 返回目录所在卷的总容量和可用空间（字节）
+func VolumeStats(path string) (total, free uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Blocks * uint64(stat.Bsize), stat.Bavail * uint64(stat.Bsize), nil
+}
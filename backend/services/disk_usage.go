@@ -0,0 +1,78 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"concurrency-web-app/backend/models"
+)
+
+// ErrInsufficientStorage 可用空间低于阈值
+var ErrInsufficientStorage = errors.New("存储空间不足")
+
+// OwnerUsage 单个用户的存储用量
+type OwnerUsage struct {
+	Owner string `json:"owner"`
+	Files int64  `json:"files"`
+	Bytes int64  `json:"bytes"`
+}
+
+// VolumeUsage 上传目录所在卷的容量
+type VolumeUsage struct {
+	Path        string  `json:"path"`
+	TotalBytes  uint64  `json:"total_bytes"`
+	FreeBytes   uint64  `json:"free_bytes"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// UsageByOwner 按用户统计已上传文件的数量和大小
+func (s *FileMetadataService) UsageByOwner() ([]OwnerUsage, error) {
+	var usages []OwnerUsage
+	err := readerDB(s.DB, s.ReadDB).Model(&models.FileTask{}).
+		Select("owner, COUNT(*) AS files, COALESCE(SUM(file_size), 0) AS bytes").
+		Where("process_type = ?", "upload").
+		Group("owner").
+		Order("bytes DESC").
+		Scan(&usages).Error
+	return usages, err
+}
+
+// Volume 返回上传目录所在卷的容量
+func (s *FileProcessService) Volume() (*VolumeUsage, error) {
+	total, free, err := VolumeStats(s.UploadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &VolumeUsage{
+		Path:       s.UploadDir,
+		TotalBytes: total,
+		FreeBytes:  free,
+	}
+	if total > 0 {
+		usage.UsedPercent = float64(total-free) / float64(total) * 100
+	}
+	return usage, nil
+}
+
+// CheckCapacity 检查写入 incoming 字节后的剩余空间：
+// 低于 MinFreeBytes 时拒绝写入，低于 WarnFreeBytes 时返回告警信息
+func (s *FileProcessService) CheckCapacity(incoming int64) (string, error) {
+	if s.MinFreeBytes <= 0 && s.WarnFreeBytes <= 0 {
+		return "", nil
+	}
+
+	volume, err := s.Volume()
+	if err != nil {
+		return "", err
+	}
+
+	remaining := int64(volume.FreeBytes) - incoming
+	if s.MinFreeBytes > 0 && remaining < s.MinFreeBytes {
+		return "", fmt.Errorf("%w: 写入后剩余 %d 字节，低于下限 %d 字节", ErrInsufficientStorage, remaining, s.MinFreeBytes)
+	}
+	if s.WarnFreeBytes > 0 && remaining < s.WarnFreeBytes {
+		return fmt.Sprintf("可用空间即将不足: 剩余 %d 字节，告警阈值 %d 字节", remaining, s.WarnFreeBytes), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,29 @@
+//go:build windows
+
+package services
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// VolumeStats 返回目录所在卷的总容量和当前用户可用的空间（字节）
+func VolumeStats(path string) (total, free uint64, err error) {
+	dir, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	var available, totalBytes, totalFree uint64
+	ok, _, callErr := procGetDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(dir)),
+		uintptr(unsafe.Pointer(&available)),
+		uintptr(unsafe.Pointer(&totalBytes)),
+		uintptr(unsafe.Pointer(&totalFree)),
+	)
+	if ok == 0 {
+		return 0, 0, callErr
+	}
+	return totalBytes, available, nil
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DNS 缓存的默认过期时间
+const (
+	DefaultDNSTTL         = 30 * time.Second
+	DefaultDNSNegativeTTL = 5 * time.Second
+
+	dnsResolveTimeout = 5 * time.Second // 单次解析的超时，与调用方的 ctx 无关
+)
+
+// ErrorClassDNS 域名解析失败的任务，TaskResult.ErrorClass 取该值
+const ErrorClassDNS = "dns"
+
+// ResolveError 域名解析失败
+type ResolveError struct {
+	Host string
+	Err  error
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("解析域名 %s 失败: %v", e.Host, e.Err)
+}
+
+func (e *ResolveError) Unwrap() error { return e.Err }
+
+// DNSCache 按主机缓存域名解析结果，供共享的 HTTP Transport 建立连接时使用
+// 同一主机的并发解析合并为一次；解析失败同样缓存 NegativeTTL，指向该主机的任务直接失败，不再逐个等待解析。
+// 标准库的解析器不返回记录的 TTL，过期时间按配置计算
+type DNSCache struct {
+	TTL         time.Duration // 解析成功的缓存时间，为0时使用 DefaultDNSTTL
+	NegativeTTL time.Duration // 解析失败的缓存时间，为0时使用 DefaultDNSNegativeTTL
+	Resolver    *net.Resolver // 为nil时使用 net.DefaultResolver
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+	pruned  time.Time // 上次清理过期缓存项的时间
+}
+
+// dnsEntry 单个主机的解析结果，ready 关闭前解析仍在进行
+type dnsEntry struct {
+	ready   chan struct{}
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// Lookup 返回主机的地址，缓存未命中或已过期时解析；IP 地址原样返回
+// 解析在独立的协程中进行，调用方的 ctx 结束只会让本次调用返回，不影响同时等待该主机的其他调用
+func (c *DNSCache) Lookup(ctx context.Context, host string) ([]string, error) {
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*dnsEntry)
+	}
+	e, ok := c.entries[host]
+	if ok {
+		select {
+		case <-e.ready:
+			ok = time.Now().Before(e.expires)
+		default:
+			// 解析进行中，等待其结果
+		}
+	}
+	if !ok {
+		c.prune(time.Now())
+		e = &dnsEntry{ready: make(chan struct{})}
+		c.entries[host] = e
+		go c.resolve(host, e)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.addrs, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// prune 删除已过期的缓存项，避免访问过的主机越积越多；每隔 NegativeTTL 最多清理一次，调用方需持有 c.mu
+func (c *DNSCache) prune(now time.Time) {
+	if now.Sub(c.pruned) < durationOr(c.NegativeTTL, DefaultDNSNegativeTTL) {
+		return
+	}
+	c.pruned = now
+	for host, e := range c.entries {
+		select {
+		case <-e.ready:
+			if !now.Before(e.expires) {
+				delete(c.entries, host)
+			}
+		default:
+			// 解析进行中
+		}
+	}
+}
+
+// Len 返回缓存的主机数，含解析进行中的主机
+func (c *DNSCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// resolve 解析主机并写入缓存项
+func (c *DNSCache) resolve(host string, e *dnsEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), dnsResolveTimeout)
+	defer cancel()
+
+	resolver := c.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err := resolver.LookupHost(ctx, host)
+
+	ttl := durationOr(c.TTL, DefaultDNSTTL)
+	if err == nil && len(addrs) == 0 {
+		err = fmt.Errorf("没有可用的地址")
+	}
+	if err != nil {
+		ttl = durationOr(c.NegativeTTL, DefaultDNSNegativeTTL)
+		err = &ResolveError{Host: host, Err: err}
+		addrs = nil
+	}
+	e.addrs, e.err, e.expires = addrs, err, time.Now().Add(ttl)
+	close(e.ready)
+}
+
+// Prime 并发预解析一组主机（重复的主机只解析一次），返回解析失败的主机及其错误
+func (c *DNSCache) Prime(ctx context.Context, hosts []string) map[string]error {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed = make(map[string]error)
+		seen   = make(map[string]bool)
+	)
+	for _, host := range hosts {
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			if _, err := c.Lookup(ctx, host); err != nil {
+				mu.Lock()
+				failed[host] = err
+				mu.Unlock()
+			}
+		}(host)
+	}
+	wg.Wait()
+	return failed
+}
+
+// DialContext 返回经过缓存解析地址的拨号函数，依次尝试主机的各个地址
+func (c *DNSCache) DialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		addrs, err := c.Lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range addrs {
+			var conn net.Conn
+			conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil || ctx.Err() != nil {
+				return conn, err
+			}
+		}
+		return nil, err
+	}
+}
+
+// urlHost 返回 URL 的主机名，无法解析时返回空字符串
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// durationOr d 不大于0时返回 fallback
+func durationOr(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
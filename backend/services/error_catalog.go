@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 任务错误码，记录在 TaskResult.ErrorCode，参数记录在 TaskResult.ErrorParams。
+// 客户端按错误码判断失败原因，按 ErrorMessage 或 GET /api/error-codes 的消息模板显示本地化的文本
+const (
+	ErrCodeTaskFailed     = "task_failed"               // 未分类的处理失败
+	ErrCodeInfrastructure = "infrastructure_error"      // 网络、上游或存储的暂时性故障
+	ErrCodeUpstreamStatus = "upstream_status"           // 上游返回错误状态码，参数 status
+	ErrCodeDNS            = "dns_failure"               // 域名解析失败，参数 host
+	ErrCodeTaskPanic      = "task_panic"                // 任务执行中 panic
+	ErrCodeTaskTimeout    = "task_timeout"              // 单个任务的时间预算耗尽，参数 timeout
+	ErrCodeStalled        = "task_stalled"              // 任务长时间没有心跳，被强制取消，参数 idle
+	ErrCodeBatchTimeout   = "batch_timeout"             // 批次超时，任务执行中被中止
+	ErrCodeNotStarted     = "batch_timeout_not_started" // 批次超时，任务未开始执行
+	ErrCodeCancelled      = "cancelled"                 // 任务被取消
+	ErrCodeHardCancelled  = "hard_cancelled"            // 任务执行中被硬取消
+	ErrCodeFailFast       = "fail_fast_cancelled"       // 其他任务失败，任务被取消
+	ErrCodeShutdown       = "shutdown_cancelled"        // 服务关闭，任务被取消
+	ErrCodeDependency     = "dependency_failed"         // 依赖的任务未成功，任务未执行
+	ErrCodeMismatch       = "result_mismatch"           // 结果与预期不符，参数 count
+	ErrCodeOutOfStock     = "out_of_stock"              // 库存不足，参数 order_id
+)
+
+// DefaultErrorLanguage 未指定语言或没有对应翻译时使用的语言，与 TaskResult.Error 的语言相同
+const DefaultErrorLanguage = "zh"
+
+// errorCatalog 各语言的消息模板，{name} 替换为同名参数
+var (
+	errorCatalogMu sync.RWMutex
+	errorCatalog   = map[string]map[string]string{
+		"zh": {
+			ErrCodeTaskFailed:     "任务处理失败",
+			ErrCodeInfrastructure: "基础设施暂时故障",
+			ErrCodeUpstreamStatus: "上游返回状态码 {status}",
+			ErrCodeDNS:            "解析域名 {host} 失败",
+			ErrCodeTaskPanic:      "任务执行异常",
+			ErrCodeTaskTimeout:    "单个任务超时（{timeout}）",
+			ErrCodeStalled:        "任务超过 {idle} 没有心跳，已被强制取消",
+			ErrCodeBatchTimeout:   "批次超时，任务执行中被中止",
+			ErrCodeNotStarted:     "批次超时，任务未开始执行",
+			ErrCodeCancelled:      "任务已取消",
+			ErrCodeHardCancelled:  "任务已被硬取消",
+			ErrCodeFailFast:       "其他任务失败，任务已取消",
+			ErrCodeShutdown:       "服务关闭，任务已取消",
+			ErrCodeDependency:     "依赖的任务未成功，任务未执行",
+			ErrCodeMismatch:       "结果与预期不符（{count} 处）",
+			ErrCodeOutOfStock:     "订单 {order_id} 库存不足",
+		},
+		"en": {
+			ErrCodeTaskFailed:     "Task failed",
+			ErrCodeInfrastructure: "Temporary infrastructure failure",
+			ErrCodeUpstreamStatus: "Upstream returned status {status}",
+			ErrCodeDNS:            "Failed to resolve host {host}",
+			ErrCodeTaskPanic:      "Task crashed unexpectedly",
+			ErrCodeTaskTimeout:    "Task timed out ({timeout})",
+			ErrCodeStalled:        "Task made no progress for {idle} and was forcibly cancelled",
+			ErrCodeBatchTimeout:   "Batch timed out while the task was running",
+			ErrCodeNotStarted:     "Batch timed out before the task started",
+			ErrCodeCancelled:      "Task was cancelled",
+			ErrCodeHardCancelled:  "Task was forcibly cancelled",
+			ErrCodeFailFast:       "Task was cancelled because another task failed",
+			ErrCodeShutdown:       "Task was cancelled because the server is shutting down",
+			ErrCodeDependency:     "Task skipped because a dependency did not succeed",
+			ErrCodeMismatch:       "Result does not match the expected output ({count} differences)",
+			ErrCodeOutOfStock:     "Order {order_id} is out of stock",
+		},
+	}
+)
+
+// RegisterErrorMessages 添加或覆盖某种语言的消息模板，注册的任务类型可以为自己的业务错误码提供翻译
+func RegisterErrorMessages(lang string, messages map[string]string) {
+	errorCatalogMu.Lock()
+	defer errorCatalogMu.Unlock()
+	catalog, ok := errorCatalog[lang]
+	if !ok {
+		catalog = make(map[string]string, len(messages))
+		errorCatalog[lang] = catalog
+	}
+	for code, message := range messages {
+		catalog[code] = message
+	}
+}
+
+// ErrorLanguages 有消息模板的语言，按字母顺序
+func ErrorLanguages() []string {
+	errorCatalogMu.RLock()
+	defer errorCatalogMu.RUnlock()
+	langs := make([]string, 0, len(errorCatalog))
+	for lang := range errorCatalog {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// MatchErrorLanguage 按 Accept-Language 的格式（如 en-US,en;q=0.9,zh;q=0.8）依次匹配有消息模板的语言，
+// 区域码不同时按主语言匹配，都不匹配时返回 DefaultErrorLanguage。不处理 q 值，按出现顺序
+func MatchErrorLanguage(accept string) string {
+	errorCatalogMu.RLock()
+	defer errorCatalogMu.RUnlock()
+	for _, part := range strings.Split(accept, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if tag == "" {
+			continue
+		}
+		if _, ok := errorCatalog[tag]; ok {
+			return tag
+		}
+		if base, _, found := strings.Cut(tag, "-"); found {
+			if _, ok := errorCatalog[base]; ok {
+				return base
+			}
+		}
+	}
+	return DefaultErrorLanguage
+}
+
+// ErrorCatalog 返回某种语言的全部消息模板，没有该语言时返回 DefaultErrorLanguage 的模板
+func ErrorCatalog(lang string) map[string]string {
+	errorCatalogMu.RLock()
+	defer errorCatalogMu.RUnlock()
+	catalog, ok := errorCatalog[lang]
+	if !ok {
+		catalog = errorCatalog[DefaultErrorLanguage]
+	}
+	copied := make(map[string]string, len(catalog))
+	for code, message := range catalog {
+		copied[code] = message
+	}
+	return copied
+}
+
+// ErrorMessage 按语言渲染错误码的消息，该语言没有对应模板时使用 DefaultErrorLanguage，都没有时返回空字符串
+func ErrorMessage(lang, code string, params map[string]interface{}) string {
+	errorCatalogMu.RLock()
+	message, ok := errorCatalog[lang][code]
+	if !ok {
+		message = errorCatalog[DefaultErrorLanguage][code]
+	}
+	errorCatalogMu.RUnlock()
+	for name, value := range params {
+		message = strings.ReplaceAll(message, "{"+name+"}", fmt.Sprint(value))
+	}
+	return message
+}
+
+// TaskTimeoutError 任务因自身的时间预算耗尽而失败，与批次超时区分
+type TaskTimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TaskTimeoutError) Error() string {
+	return fmt.Sprintf("单个任务超时（%v）: %v", e.Timeout, e.Err)
+}
+
+func (e *TaskTimeoutError) Unwrap() error { return e.Err }
+
+// cancelCode 按任务的取消方式返回被取消的任务的错误码
+func cancelCode(ctx context.Context) string {
+	if failedByOtherTask(ctx) {
+		return ErrCodeFailFast
+	}
+	if job := JobFromContext(ctx); job != nil {
+		switch job.CancelMode() {
+		case CancelModeHard:
+			return ErrCodeHardCancelled
+		case CancelModeFailFast:
+			return ErrCodeFailFast
+		case CancelModeShutdown:
+			return ErrCodeShutdown
+		}
+	}
+	return ErrCodeCancelled
+}
+
+// errorCode 由任务的最终状态和错误得出错误码和参数
+func errorCode(ctx context.Context, status string, err error) (string, map[string]interface{}) {
+	var business *BusinessError
+	var timeout *TaskTimeoutError
+	var upstream *HTTPStatusError
+	var resolve *ResolveError
+	var panicked *TaskPanicError
+	var stalled *TaskStalledError
+	switch {
+	case errors.As(err, &stalled):
+		return ErrCodeStalled, map[string]interface{}{"idle": stalled.Idle.String()}
+	case status == TaskStatusCancelled:
+		return cancelCode(ctx), nil
+	case errors.As(err, &timeout):
+		return ErrCodeTaskTimeout, map[string]interface{}{"timeout": timeout.Timeout.String()}
+	case status == TaskStatusTimeout:
+		return ErrCodeBatchTimeout, nil
+	case errors.As(err, &business) && business.Code != "":
+		return business.Code, business.Params
+	case errors.As(err, &panicked):
+		return ErrCodeTaskPanic, nil
+	case errors.As(err, &resolve):
+		return ErrCodeDNS, map[string]interface{}{"host": resolve.Host}
+	case errors.As(err, &upstream):
+		return ErrCodeUpstreamStatus, map[string]interface{}{"status": upstream.StatusCode}
+	}
+	if class, _ := classifyError(err); class == ErrorClassInfra {
+		return ErrCodeInfrastructure, nil
+	}
+	return ErrCodeTaskFailed, nil
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 分块校验和参数
+const (
+	ChecksumAlgorithm = "sha256-chunked" // 每块单独计算SHA-256，再对所有块摘要计算SHA-256
+	ChecksumChunkSize = 4 << 20          // 每块4MB
+)
+
+// FileChecksum 文件的分块校验和
+type FileChecksum struct {
+	Checksum    string   `json:"checksum"`
+	ChunkHashes []string `json:"chunk_hashes,omitempty"`
+}
+
+// JoinedChunkHashes 以逗号拼接的块摘要，用于持久化
+func (c *FileChecksum) JoinedChunkHashes() string {
+	return strings.Join(c.ChunkHashes, ",")
+}
+
+// ComputeChecksum 分块并发计算文件校验和，多个协程通过 ReadAt 各自读取不同的块
+func ComputeChecksum(ctx context.Context, path string, concurrency int) (*FileChecksum, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// 空文件也按一个空块计算
+	chunks := int((info.Size() + ChecksumChunkSize - 1) / ChecksumChunkSize)
+	if chunks == 0 {
+		chunks = 1
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > chunks {
+		concurrency = chunks
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	hashes := make([]string, chunks)
+	indexCh := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexCh {
+				section := io.NewSectionReader(file, int64(index)*ChecksumChunkSize, ChecksumChunkSize)
+				hasher := sha256.New()
+				if _, err := io.Copy(hasher, section); err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("读取第 %d 块失败: %v", index, err)
+						cancel()
+					})
+					continue
+				}
+				hashes[index] = hex.EncodeToString(hasher.Sum(nil))
+			}
+		}()
+	}
+
+	// 派发分块，出错或取消时停止派发
+DISPATCH:
+	for index := 0; index < chunks; index++ {
+		select {
+		case indexCh <- index:
+		case <-ctx.Done():
+			break DISPATCH
+		}
+	}
+	close(indexCh)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &FileChecksum{
+		Checksum:    combineChunkHashes(hashes),
+		ChunkHashes: hashes,
+	}, nil
+}
+
+// combineChunkHashes 对所有块摘要计算总校验和
+func combineChunkHashes(hashes []string) string {
+	hasher := sha256.New()
+	for _, hash := range hashes {
+		raw, _ := hex.DecodeString(hash)
+		hasher.Write(raw)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// 校验结果状态
+const (
+	VerifyStatusOK         = "ok"
+	VerifyStatusCorrupted  = "corrupted"
+	VerifyStatusMissing    = "missing"
+	VerifyStatusUnrecorded = "unrecorded"
+)
+
+// VerifyResult 文件校验结果
+type VerifyResult struct {
+	Status          string `json:"status"`
+	Algorithm       string `json:"algorithm"`
+	Expected        string `json:"expected"`
+	Actual          string `json:"actual,omitempty"`
+	Chunks          int    `json:"chunks"`
+	CorruptedChunks []int  `json:"corrupted_chunks,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// VerifyChecksum 重新计算文件校验和并与记录值比较，定位损坏的块
+func VerifyChecksum(ctx context.Context, path, expected, expectedChunks string, concurrency int) (*VerifyResult, error) {
+	result := &VerifyResult{
+		Algorithm: ChecksumAlgorithm,
+		Expected:  expected,
+	}
+
+	actual, err := ComputeChecksum(ctx, path, concurrency)
+	if os.IsNotExist(err) {
+		result.Status = VerifyStatusMissing
+		result.Error = err.Error()
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.Actual = actual.Checksum
+	result.Chunks = len(actual.ChunkHashes)
+
+	switch {
+	case expected == "":
+		result.Status = VerifyStatusUnrecorded
+	case expected == actual.Checksum:
+		result.Status = VerifyStatusOK
+	default:
+		result.Status = VerifyStatusCorrupted
+		var recorded []string
+		if expectedChunks != "" {
+			recorded = strings.Split(expectedChunks, ",")
+		}
+		for i, hash := range actual.ChunkHashes {
+			if i >= len(recorded) || recorded[i] != hash {
+				result.CorruptedChunks = append(result.CorruptedChunks, i)
+			}
+		}
+		// 文件被截断时，缺失的块同样视为损坏
+		for i := len(actual.ChunkHashes); i < len(recorded); i++ {
+			result.CorruptedChunks = append(result.CorruptedChunks, i)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// hashReadSize 读取阶段每次读取的块大小
+const hashReadSize = 1 << 20
+
+// hashFileJob 读取阶段交给哈希阶段的单个文件
+type hashFileJob struct {
+	index     int
+	task      FileTask
+	taskStart time.Time
+	chunks    chan []byte // 读取协程按顺序写入，关闭表示读取结束
+	err       error       // 读取错误，在 chunks 关闭前写入
+	untrack   func()
+}
+
+// isHashBatch 批次中的任务是否全部为 hash 类型
+func isHashBatch(tasks []FileTask) bool {
+	for _, task := range tasks {
+		if task.ProcessType != "hash" {
+			return false
+		}
+	}
+	return len(tasks) > 0
+}
+
+// batchHashFiles 两阶段流水线批量计算文件哈希：
+// 读取阶段按 HashIOConcurrency 并发读文件，哈希阶段按 HashCPUConcurrency 并发计算，
+// 两阶段之间通过有界通道衔接，单个文件的数据块按顺序交给同一个哈希协程
+func (s *FileProcessService) batchHashFiles(ctx context.Context, tasks []FileTask) *BatchResult {
+	// 收集结束（含超时）时取消仍在读取的文件
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	totalTasks := len(tasks)
+	ctx = withStartTracker(ctx, totalTasks)
+
+	ioConcurrency := s.HashIOConcurrency
+	if ioConcurrency < 1 {
+		ioConcurrency = 1
+	}
+	cpuConcurrency := s.HashCPUConcurrency
+	if cpuConcurrency < 1 {
+		cpuConcurrency = 1
+	}
+
+	taskCh := make(chan int)
+	hashQueue := make(chan *hashFileJob, cpuConcurrency)
+	resultCh := make(chan TaskResult, totalTasks)
+	var totalBytes int64
+
+	// 按优先级派发任务
+	go func() {
+		defer close(taskCh)
+		for _, index := range batch.DispatchOrder(tasks, func(t FileTask) int { return t.Priority }) {
+			taskCh <- index
+		}
+	}()
+
+	// 读取阶段
+	var readers sync.WaitGroup
+	for slot := 0; slot < ioConcurrency; slot++ {
+		readers.Add(1)
+		go func(slot int) {
+			defer readers.Done()
+			for index := range taskCh {
+				taskStart := time.Now()
+
+				// 检查是否已取消或超时
+				if result, ok := checkDispatch(ctx, index, taskStart); !ok {
+					resultCh <- result
+					continue
+				}
+
+				taskCtx, untrack := trackInflight(ctx, index, slot)
+				job := &hashFileJob{
+					index:     index,
+					task:      tasks[index],
+					taskStart: taskStart,
+					chunks:    make(chan []byte, 4),
+					untrack:   untrack,
+				}
+
+				select {
+				case hashQueue <- job:
+				case <-ctx.Done():
+					job.untrack()
+					result, _ := checkDispatch(ctx, index, taskStart)
+					resultCh <- result
+					continue
+				}
+
+				s.readFileChunks(taskCtx, job)
+			}
+		}(slot)
+	}
+
+	go func() {
+		readers.Wait()
+		close(hashQueue)
+	}()
+
+	// 哈希阶段
+	var hashers sync.WaitGroup
+	for i := 0; i < cpuConcurrency; i++ {
+		hashers.Add(1)
+		go func() {
+			defer hashers.Done()
+			for job := range hashQueue {
+				hasher := sha256.New()
+				var size int64
+				for chunk := range job.chunks {
+					hasher.Write(chunk)
+					size += int64(len(chunk))
+				}
+				job.untrack()
+				atomic.AddInt64(&totalBytes, size)
+
+				result := TaskResult{
+					ID:       job.index,
+					Success:  job.err == nil,
+					Status:   TaskStatusSuccess,
+					Duration: time.Since(job.taskStart).Milliseconds(),
+				}
+				if job.err != nil {
+					result.Status = failureStatus(ctx, job.err)
+					result.Error = "读取文件失败: " + job.err.Error()
+					result.classify(ctx, job.err)
+				} else {
+					result.Data = map[string]interface{}{
+						"file_path":    job.task.FilePath,
+						"file_name":    job.task.FileName,
+						"process_type": job.task.ProcessType,
+						"file_size":    size,
+						"sha256":       hex.EncodeToString(hasher.Sum(nil)),
+						"processed_at": time.Now(),
+					}
+				}
+				resultCh <- result
+			}
+		}()
+	}
+
+	go func() {
+		hashers.Wait()
+		close(resultCh)
+	}()
+
+	// 收集结果
+	var results []TaskResult
+	collector := batch.Collector[TaskResult]{
+		Timeout: s.Timeout,
+		OnResult: func(result TaskResult) {
+			results = append(results, result)
+			reportProgress(ctx, result)
+		},
+	}
+	collector.Collect(ctx, resultCh)
+
+	result := buildBatchResult(ctx, startTime, totalTasks, results)
+	result.TotalBytes = atomic.LoadInt64(&totalBytes)
+	if seconds := time.Since(startTime).Seconds(); seconds > 0 {
+		mbps := float64(result.TotalBytes) / (1 << 20) / seconds
+		result.Throughput = math.Round(mbps*100) / 100
+	}
+	return result
+}
+
+// readFileChunks 按顺序读取文件内容并写入 job.chunks，结束时关闭通道
+func (s *FileProcessService) readFileChunks(ctx context.Context, job *hashFileJob) {
+	defer close(job.chunks)
+	// panic 时按读取失败处理，哈希阶段照常输出该任务的结果
+	defer func() {
+		if rec := recover(); rec != nil {
+			job.err = panicError(ctx, job.index, rec)
+		}
+	}()
+
+	file, err := os.Open(job.task.FilePath)
+	if err != nil {
+		job.err = err
+		return
+	}
+	defer file.Close()
+
+	for {
+		buf := make([]byte, hashReadSize)
+		n, err := io.ReadFull(file, buf)
+		if n > 0 {
+			select {
+			case job.chunks <- buf[:n]:
+				Heartbeat(ctx)
+			case <-ctx.Done():
+				job.err = stalledError(ctx, ctx.Err())
+				return
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return
+		}
+		if err != nil {
+			job.err = err
+			return
+		}
+	}
+}
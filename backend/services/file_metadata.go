@@ -0,0 +1,282 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 同名文件上传冲突策略
+const (
+	ConflictVersion   = "version"   // 保留旧文件，新文件作为新版本
+	ConflictOverwrite = "overwrite" // 覆盖最新版本
+	ConflictReject    = "reject"    // 拒绝上传
+)
+
+// ErrFileExists 同名文件已存在
+var ErrFileExists = errors.New("同名文件已存在")
+
+// ValidConflictPolicy 是否为支持的冲突策略
+func ValidConflictPolicy(policy string) bool {
+	switch policy {
+	case ConflictVersion, ConflictOverwrite, ConflictReject:
+		return true
+	}
+	return false
+}
+
+// FileMetadataService 上传文件元数据服务
+type FileMetadataService struct {
+	DB      *gorm.DB
+	ReadDB  *gorm.DB       // 只读副本，为nil时查询走主库
+	Scanner *UploadScanner // 上传的文件扫描通过后才能处理，为nil时不扫描
+}
+
+// FileSearchQuery 文件搜索条件，零值字段不参与过滤
+type FileSearchQuery struct {
+	Owner   string // 只搜索该用户上传的文件
+	Tag     string
+	Name    string
+	MinSize int64
+	MaxSize int64
+	From    time.Time
+	To      time.Time
+}
+
+// NormalizeTags 规范化标签：去除空白、空值和重复项，以逗号拼接
+func NormalizeTags(tags []string) string {
+	seen := make(map[string]bool, len(tags))
+	var normalized []string
+	for _, tag := range tags {
+		for _, part := range strings.Split(tag, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" || seen[part] {
+				continue
+			}
+			seen[part] = true
+			normalized = append(normalized, part)
+		}
+	}
+	return strings.Join(normalized, ",")
+}
+
+// maxVersionRetries 并发上传同名文件时分配版本号的最大尝试次数
+const maxVersionRetries = 5
+
+// RecordUpload 登记上传的文件，版本号取同一用户同名文件的最新版本加1（第一个版本为1）。
+// 版本号在事务中分配，(owner, original_name, version) 上的唯一索引保证并发上传得到不同的版本，冲突时重新分配
+func (s *FileMetadataService) RecordUpload(file *models.FileTask) error {
+	file.Status = "uploaded"
+	file.ProcessType = "upload"
+	for attempt := 1; ; attempt++ {
+		file.ID = 0
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			var latest int
+			err := tx.Model(&models.FileTask{}).
+				Where("process_type = ? AND owner = ? AND original_name = ?", "upload", file.Owner, file.OriginalName).
+				Select("COALESCE(MAX(version), 0)").Scan(&latest).Error
+			if err != nil {
+				return err
+			}
+			file.Version = latest + 1
+			return tx.Create(file).Error
+		})
+		if err == nil || attempt == maxVersionRetries || !s.versionTaken(file) {
+			return err
+		}
+	}
+}
+
+// versionTaken 同一用户同名文件的该版本是否已被登记，用于判断插入失败是否因并发上传分配了相同的版本
+func (s *FileMetadataService) versionTaken(file *models.FileTask) bool {
+	var count int64
+	s.DB.Model(&models.FileTask{}).
+		Where("owner = ? AND original_name = ? AND version = ?", file.Owner, file.OriginalName, file.Version).
+		Count(&count)
+	return count > 0
+}
+
+// ReplaceUpload 用新上传文件的信息覆盖已有版本，被隔离的版本恢复为已上传状态
+func (s *FileMetadataService) ReplaceUpload(existing *models.FileTask, upload *models.FileTask) error {
+	updates := map[string]interface{}{
+		"status":       "uploaded",
+		"file_path":    existing.FilePath,
+		"file_size":    upload.FileSize,
+		"mime_type":    upload.MimeType,
+		"checksum":     upload.Checksum,
+		"chunk_hashes": upload.ChunkHashes,
+		"scan_status":  upload.ScanStatus,
+	}
+	// 未指定标签和描述时保留原值
+	if upload.Tags != "" {
+		updates["tags"] = upload.Tags
+	}
+	if upload.Description != "" {
+		updates["description"] = upload.Description
+	}
+	return s.DB.Model(existing).Updates(updates).Error
+}
+
+// LatestVersion 返回用户 owner 上传的同名文件的最新版本，不存在时返回nil；不同用户的同名文件互不影响
+func (s *FileMetadataService) LatestVersion(owner, originalName string) (*models.FileTask, error) {
+	var files []models.FileTask
+	err := s.DB.Where("process_type = ? AND owner = ? AND original_name = ?", "upload", owner, originalName).
+		Order("version DESC").Limit(1).Find(&files).Error
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	return &files[0], nil
+}
+
+// FindVersion 查找用户 owner 上传的同名文件的指定版本，version 为0时返回最新版本
+func (s *FileMetadataService) FindVersion(owner, originalName string, version int) (*models.FileTask, error) {
+	if version == 0 {
+		file, err := s.LatestVersion(owner, originalName)
+		if err == nil && file == nil {
+			err = fmt.Errorf("文件 %s 不存在", originalName)
+		}
+		return file, err
+	}
+
+	var file models.FileTask
+	err := s.DB.Where("process_type = ? AND owner = ? AND original_name = ? AND version = ?", "upload", owner, originalName, version).
+		First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("文件 %s 不存在版本 v%d", originalName, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// PartitionDir 返回按上传日期分区的存储目录：<根目录>/YYYY/MM/DD
+func PartitionDir(root string, t time.Time) string {
+	return filepath.Join(root, t.Format("2006"), t.Format("01"), t.Format("02"))
+}
+
+// Get 按ID获取用户 owner 上传的文件，其他用户的文件按不存在处理（gorm.ErrRecordNotFound）
+func (s *FileMetadataService) Get(id uint, owner string) (*models.FileTask, error) {
+	var file models.FileTask
+	if err := s.DB.Where("process_type = ? AND owner = ?", "upload", owner).First(&file, id).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// List 列出用户 owner 上传的文件，按上传顺序排列
+func (s *FileMetadataService) List(owner string) ([]models.FileTask, error) {
+	var files []models.FileTask
+	err := readerDB(s.DB, s.ReadDB).Where("process_type = ? AND owner = ?", "upload", owner).Order("id ASC").Find(&files).Error
+	return files, err
+}
+
+// RegisterExisting 登记 root 下还没有文件信息的文件（如按日期分区之前直接保存在上传目录中的文件），归属 owner，
+// 没有归属用户的已登记文件同样归属 owner；按存储路径跳过已登记的文件，可以重复执行。返回新登记的文件数
+func (s *FileMetadataService) RegisterExisting(root, owner string) (int, error) {
+	err := s.DB.Model(&models.FileTask{}).Where("process_type = ? AND owner = ?", "upload", "").Update("owner", owner).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var paths []string
+	if err := s.DB.Model(&models.FileTask{}).Where("process_type = ?", "upload").Pluck("file_path", &paths).Error; err != nil {
+		return 0, err
+	}
+	known := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		known[filepath.Clean(path)] = true
+	}
+
+	registered := 0
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() || known[filepath.Clean(path)] {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		file := &models.FileTask{
+			FileName:     entry.Name(),
+			OriginalName: entry.Name(),
+			Owner:        owner,
+			FilePath:     path,
+			FileSize:     info.Size(),
+			CreatedAt:    info.ModTime(),
+		}
+		if err := s.RecordUpload(file); err != nil {
+			return fmt.Errorf("登记文件 %s 失败: %w", path, err)
+		}
+		registered++
+		return nil
+	})
+	return registered, err
+}
+
+// UpdateMetadata 更新用户 owner 上传的文件的标签和描述
+func (s *FileMetadataService) UpdateMetadata(id uint, owner string, tags []string, description string) (*models.FileTask, error) {
+	file, err := s.Get(id, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Model(file).Updates(map[string]interface{}{
+		"tags":        NormalizeTags(tags),
+		"description": description,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// likeEscaper 转义 LIKE 模式中的通配符，配合 ESCAPE '\' 按字面匹配
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike 转义 text 中的 \、% 和 _，用于拼接 LIKE 模式
+func escapeLike(text string) string {
+	return likeEscaper.Replace(text)
+}
+
+// Search 按条件搜索用户 query.Owner 上传的文件，按上传时间倒序
+func (s *FileMetadataService) Search(query FileSearchQuery) ([]models.FileTask, error) {
+	db := readerDB(s.DB, s.ReadDB).Model(&models.FileTask{}).Where("process_type = ? AND owner = ?", "upload", query.Owner)
+
+	if query.Tag != "" {
+		// 首尾补逗号，保证按完整标签匹配
+		db = db.Where(`(',' || tags || ',') LIKE ? ESCAPE '\'`, "%,"+escapeLike(strings.TrimSpace(query.Tag))+",%")
+	}
+	if query.Name != "" {
+		db = db.Where(`file_name LIKE ? ESCAPE '\'`, "%"+escapeLike(query.Name)+"%")
+	}
+	if query.MinSize > 0 {
+		db = db.Where("file_size >= ?", query.MinSize)
+	}
+	if query.MaxSize > 0 {
+		db = db.Where("file_size <= ?", query.MaxSize)
+	}
+	if !query.From.IsZero() {
+		db = db.Where("created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("created_at < ?", query.To)
+	}
+
+	var files []models.FileTask
+	err := db.Order("created_at DESC").Find(&files).Error
+	return files, err
+}
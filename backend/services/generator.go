@@ -0,0 +1,272 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// 生成器中数值的分布
+const (
+	DistUniform     = "uniform"     // 在 [min, max] 内均匀分布（默认）
+	DistNormal      = "normal"      // 正态分布，超出 [min, max] 时取边界值
+	DistExponential = "exponential" // 从 min 开始的指数分布，超过 max 时取 max，适合模拟长尾
+	DistSequence    = "sequence"    // 按任务序号递增：数值为 min + 序号*step，values 依次轮换
+)
+
+// ErrInvalidGenerator 生成器参数不正确
+var ErrInvalidGenerator = errors.New("生成器参数不正确")
+
+// FieldSpec 一个字段的生成规则：values 非空时从中选取，否则在 [min, max] 内按分布生成数值
+type FieldSpec struct {
+	Values       []interface{} `json:"values,omitempty"`  // 候选值，可以是任意 JSON
+	Weights      []float64     `json:"weights,omitempty"` // values 的权重，与 values 一一对应，为空时等概率
+	Min          *float64      `json:"min,omitempty"`
+	Max          *float64      `json:"max,omitempty"`
+	Distribution string        `json:"distribution,omitempty" binding:"omitempty,oneof=uniform normal exponential sequence"`
+	Mean         *float64      `json:"mean,omitempty"`                                      // normal 默认取区间中点，exponential 默认取区间长度的 1/4
+	StdDev       *float64      `json:"stddev,omitempty"`                                    // normal 的标准差，默认取区间长度的 1/6
+	Step         float64       `json:"step,omitempty"`                                      // sequence 的步长，默认1
+	Integer      bool          `json:"integer,omitempty"`                                   // 数值取整
+	Decimals     *int          `json:"decimals,omitempty" binding:"omitempty,min=0,max=10"` // 非整数保留的小数位，默认2
+	Format       string        `json:"format,omitempty"`                                    // 按 fmt 格式生成字符串，如 "CUST_%04d"（%d 需配合 integer）
+	Error        *FieldError   `json:"error,omitempty"`                                     // 错误注入
+}
+
+// FieldError 错误注入：按 rate 的概率将字段替换为 value（如 -1、""、null），模拟脏数据触发校验或业务失败
+type FieldError struct {
+	Rate  float64     `json:"rate" binding:"min=0,max=1"`
+	Value interface{} `json:"value"`
+}
+
+// GeneratorSpec 测试数据生成器，API 的生成接口和压测工具共用：生成 count 个任务，各字段按 fields 的规则生成，
+// fields 覆盖或增加默认规则中的字段，值为 null 时去掉该默认字段。相同的种子和规则生成相同的数据
+type GeneratorSpec struct {
+	Count  int                   `json:"count"`
+	Seed   int64                 `json:"seed"` // 0 表示随机生成，实际使用的种子随结果返回
+	Fields map[string]*FieldSpec `json:"fields,omitempty"`
+}
+
+// GeneratedData 生成的任务，字段按 JSON 原样提交给批量接口
+type GeneratedData struct {
+	Seed  int64                    `json:"seed"`
+	Items []map[string]interface{} `json:"items"`
+}
+
+// DefaultOrderFields 订单的默认生成规则
+func DefaultOrderFields() map[string]FieldSpec {
+	return map[string]FieldSpec{
+		"id":           {Distribution: DistSequence, Min: floatPtr(1), Integer: true},
+		"customer_id":  {Distribution: DistSequence, Min: floatPtr(1), Integer: true, Format: "CUST_%04d"},
+		"product_name": {Values: []interface{}{"iPhone 15", "MacBook Pro", "iPad Air", "Apple Watch", "AirPods Pro"}},
+		"quantity":     {Min: floatPtr(1), Max: floatPtr(5), Integer: true},
+		"price":        {Min: floatPtr(100), Max: floatPtr(550)},
+	}
+}
+
+// DefaultAPICallFields API调用的默认生成规则
+func DefaultAPICallFields() map[string]FieldSpec {
+	return map[string]FieldSpec{
+		"id": {Distribution: DistSequence, Min: floatPtr(1), Integer: true},
+		"url": {Values: []interface{}{
+			"https://jsonplaceholder.typicode.com/posts",
+			"https://httpbin.org/get",
+			"https://api.github.com/users/octocat",
+			"https://httpbin.org/delay/1",
+			"https://httpbin.org/status/200",
+		}},
+		"method":  {Values: []interface{}{"GET"}},
+		"headers": {Values: []interface{}{map[string]interface{}{"User-Agent": "ConcurrencyApp/1.0"}}},
+	}
+}
+
+// floatPtr 返回 v 的指针，用于默认规则中的 min、max
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+// Generate 按默认规则和 Fields 生成任务
+func (s GeneratorSpec) Generate(defaults map[string]FieldSpec) (*GeneratedData, error) {
+	if s.Count < 1 {
+		return nil, fmt.Errorf("%w: count 须为正整数", ErrInvalidGenerator)
+	}
+	fields := make(map[string]FieldSpec, len(defaults)+len(s.Fields))
+	for name, field := range defaults {
+		fields[name] = field
+	}
+	for name, field := range s.Fields {
+		if field == nil {
+			delete(fields, name)
+			continue
+		}
+		if err := field.validate(); err != nil {
+			return nil, fmt.Errorf("%w: fields.%s: %v", ErrInvalidGenerator, name, err)
+		}
+		fields[name] = *field
+	}
+
+	// 按字段名的顺序取随机数，保证相同的种子生成相同的数据
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	seed := s.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	data := &GeneratedData{Seed: seed, Items: make([]map[string]interface{}, s.Count)}
+	for i := range data.Items {
+		item := make(map[string]interface{}, len(names))
+		for _, name := range names {
+			item[name] = fields[name].value(rng, i)
+		}
+		data.Items[i] = item
+	}
+	return data, nil
+}
+
+// validate 检查字段规则
+func (f *FieldSpec) validate() error {
+	if len(f.Weights) > 0 {
+		if len(f.Weights) != len(f.Values) {
+			return errors.New("weights 须与 values 一一对应")
+		}
+		total := 0.0
+		for _, w := range f.Weights {
+			if w < 0 {
+				return errors.New("weights 不能为负数")
+			}
+			total += w
+		}
+		if total == 0 {
+			return errors.New("weights 不能全为0")
+		}
+	}
+	switch f.Distribution {
+	case "", DistUniform, DistNormal, DistExponential, DistSequence:
+	default:
+		return fmt.Errorf("不支持的分布 %q，可选 uniform、normal、exponential、sequence", f.Distribution)
+	}
+	if len(f.Values) == 0 && f.Distribution != DistSequence {
+		if f.Min == nil || f.Max == nil {
+			return errors.New("须指定 values 或 min、max")
+		}
+		if *f.Min > *f.Max {
+			return errors.New("min 不能大于 max")
+		}
+	}
+	if f.StdDev != nil && *f.StdDev <= 0 {
+		return errors.New("stddev 须为正数")
+	}
+	if f.Mean != nil && f.Distribution == DistExponential && *f.Mean <= 0 {
+		return errors.New("exponential 的 mean 须为正数")
+	}
+	if f.Decimals != nil && (*f.Decimals < 0 || *f.Decimals > 10) {
+		return errors.New("decimals 须在 0-10 之间")
+	}
+	if f.Error != nil && (f.Error.Rate < 0 || f.Error.Rate > 1) {
+		return errors.New("error.rate 须在 0-1 之间")
+	}
+	return nil
+}
+
+// value 生成第 i 个任务的字段值
+func (f FieldSpec) value(rng *rand.Rand, i int) interface{} {
+	if f.Error != nil && f.Error.Rate > 0 && rng.Float64() < f.Error.Rate {
+		return f.Error.Value
+	}
+	var v interface{}
+	if len(f.Values) > 0 {
+		v = f.Values[f.pick(rng, i)]
+	} else {
+		v = f.number(rng, i)
+	}
+	if f.Format != "" {
+		return fmt.Sprintf(f.Format, v)
+	}
+	return v
+}
+
+// pick 选取候选值的下标
+func (f FieldSpec) pick(rng *rand.Rand, i int) int {
+	switch {
+	case f.Distribution == DistSequence:
+		return i % len(f.Values)
+	case len(f.Weights) > 0:
+		total := 0.0
+		for _, w := range f.Weights {
+			total += w
+		}
+		r := rng.Float64() * total
+		for j, w := range f.Weights {
+			if r < w {
+				return j
+			}
+			r -= w
+		}
+		return len(f.Weights) - 1
+	default:
+		return rng.Intn(len(f.Values))
+	}
+}
+
+// number 按分布生成数值，integer 时为 int64，否则按 decimals 舍入
+func (f FieldSpec) number(rng *rand.Rand, i int) interface{} {
+	min, max := 0.0, 0.0
+	if f.Min != nil {
+		min = *f.Min
+	}
+	if f.Max != nil {
+		max = *f.Max
+	}
+
+	var x float64
+	switch f.Distribution {
+	case DistSequence:
+		step := f.Step
+		if step == 0 {
+			step = 1
+		}
+		x = min + float64(i)*step
+	case DistNormal:
+		mean, stddev := (min+max)/2, (max-min)/6
+		if f.Mean != nil {
+			mean = *f.Mean
+		}
+		if f.StdDev != nil {
+			stddev = *f.StdDev
+		}
+		x = math.Max(min, math.Min(max, mean+rng.NormFloat64()*stddev))
+	case DistExponential:
+		mean := (max - min) / 4
+		if f.Mean != nil {
+			mean = *f.Mean
+		}
+		x = math.Min(max, min+rng.ExpFloat64()*mean)
+	default:
+		if f.Integer {
+			lo, hi := math.Ceil(min), math.Floor(max)
+			if hi < lo {
+				return int64(lo)
+			}
+			return int64(lo) + rng.Int63n(int64(hi-lo)+1)
+		}
+		x = min + rng.Float64()*(max-min)
+	}
+
+	if f.Integer {
+		return int64(math.Round(x))
+	}
+	decimals := 2
+	if f.Decimals != nil {
+		decimals = *f.Decimals
+	}
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(x*scale) / scale
+}
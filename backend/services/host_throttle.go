@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 主机限流的默认参数
+const (
+	defaultThrottleWindow      = 20
+	defaultThrottleMinSamples  = 5
+	defaultThrottleFailureRate = 0.5
+	defaultThrottleRecoverRate = 0.1
+)
+
+// HostThrottle 按下游主机的健康状况自适应调整并发：主机最近的失败率达到 FailureRate 时，
+// 同时发往该主机的调用数减半（最少1个），失败率回落到 RecoverRate 以下后每 MinSamples 次调用加回1个，
+// 直到 MaxConcurrency。只有暂时性错误（RetryTransient）计为失败，同一服务的所有批次共享各主机的状态
+type HostThrottle struct {
+	MaxConcurrency int     // 每个主机的并发上限
+	Window         int     // 按最近多少次调用计算失败率，默认20
+	MinSamples     int     // 上次调整后至少再有多少次调用才再次调整，默认5
+	FailureRate    float64 // 失败率达到该值时减半，默认0.5
+	RecoverRate    float64 // 失败率低于该值时逐步恢复，默认0.1
+
+	mu    sync.Mutex
+	hosts map[string]*hostHealth
+}
+
+// hostHealth 单个主机的并发状态和最近的调用结果
+type hostHealth struct {
+	limit    int
+	inflight int
+	outcomes []bool // 最近的调用是否失败，上次调整后清空
+	freed    chan struct{}
+}
+
+// ThrottleDecision 一次并发调整，作为任务事件 JobEventThrottle 的数据
+type ThrottleDecision struct {
+	Host        string    `json:"host"`
+	Previous    int       `json:"previous"` // 调整前的并发数
+	Limit       int       `json:"limit"`    // 调整后的并发数
+	FailureRate float64   `json:"failure_rate"`
+	Samples     int       `json:"samples"` // 计算失败率的调用数
+	Reason      string    `json:"reason"`  // degraded 或 recovered
+	Time        time.Time `json:"time"`
+}
+
+// host 返回主机的状态，首次调用时以满并发开始
+func (t *HostThrottle) host(name string) *hostHealth {
+	if t.hosts == nil {
+		t.hosts = make(map[string]*hostHealth)
+	}
+	h, ok := t.hosts[name]
+	if !ok {
+		h = &hostHealth{limit: max(t.MaxConcurrency, 1), freed: make(chan struct{})}
+		t.hosts[name] = h
+	}
+	return h
+}
+
+// Acquire 等待发往 host 的并发数低于当前限制，返回调用结束时登记结果的函数；ctx 结束时返回其错误
+// 登记结果引起并发调整时，done 返回本次调整，否则返回nil。t 为nil或 host 为空时不限制
+func (t *HostThrottle) Acquire(ctx context.Context, host string) (done func(err error) *ThrottleDecision, err error) {
+	if t == nil || host == "" {
+		return func(error) *ThrottleDecision { return nil }, nil
+	}
+
+	t.mu.Lock()
+	for {
+		h := t.host(host)
+		if h.inflight < h.limit {
+			h.inflight++
+			break
+		}
+		freed := h.freed
+		t.mu.Unlock()
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		t.mu.Lock()
+	}
+	t.mu.Unlock()
+
+	return func(err error) *ThrottleDecision {
+		return t.release(host, err != nil && RetryTransient(err))
+	}, nil
+}
+
+// release 结束一次调用并登记结果，按最近的失败率调整并发数，唤醒等待的调用
+func (t *HostThrottle) release(host string, failed bool) *ThrottleDecision {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h := t.host(host)
+	h.inflight--
+	defer func() {
+		close(h.freed)
+		h.freed = make(chan struct{})
+	}()
+
+	h.outcomes = append(h.outcomes, failed)
+	if window := intOr(t.Window, defaultThrottleWindow); len(h.outcomes) > window {
+		h.outcomes = h.outcomes[len(h.outcomes)-window:]
+	}
+	samples := len(h.outcomes)
+	if samples < intOr(t.MinSamples, defaultThrottleMinSamples) {
+		return nil
+	}
+	failures := 0
+	for _, f := range h.outcomes {
+		if f {
+			failures++
+		}
+	}
+	rate := float64(failures) / float64(samples)
+
+	decision := &ThrottleDecision{Host: host, Previous: h.limit, FailureRate: rate, Samples: samples, Time: time.Now()}
+	switch {
+	case rate >= floatOr(t.FailureRate, defaultThrottleFailureRate) && h.limit > 1:
+		h.limit = max(h.limit/2, 1)
+		decision.Reason = "degraded"
+	case rate < floatOr(t.RecoverRate, defaultThrottleRecoverRate) && h.limit < max(t.MaxConcurrency, 1):
+		h.limit++
+		decision.Reason = "recovered"
+	default:
+		return nil
+	}
+	decision.Limit = h.limit
+	// 调整后重新积累调用结果，避免同一批失败连续触发调整
+	h.outcomes = h.outcomes[:0]
+	return decision
+}
+
+// Limits 返回各主机当前的并发数，只包含低于上限的主机
+func (t *HostThrottle) Limits() map[string]int {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limits := make(map[string]int)
+	for name, h := range t.hosts {
+		if h.limit < max(t.MaxConcurrency, 1) {
+			limits[name] = h.limit
+		}
+	}
+	return limits
+}
+
+// intOr n 不大于0时返回 fallback
+func intOr(n, fallback int) int {
+	if n <= 0 {
+		return fallback
+	}
+	return n
+}
+
+// floatOr f 不大于0时返回 fallback
+func floatOr(f, fallback float64) float64 {
+	if f <= 0 {
+		return fallback
+	}
+	return f
+}
+
+// recordThrottle 将并发调整记入当前任务的事件
+func recordThrottle(ctx context.Context, decision *ThrottleDecision) {
+	if job := JobFromContext(ctx); job != nil {
+		job.events.append(JobEventThrottle, decision)
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var (
+	// ErrJobOutputNotFound 任务没有产出物：任务不存在、尚未结束或属于其他用户
+	ErrJobOutputNotFound = errors.New("任务不存在或尚未结束")
+	// ErrJobOutputEmpty 任务的产出物不含任务明细（如 NDJSON 流式返回的批次）
+	ErrJobOutputEmpty = errors.New("任务没有保留结果明细")
+	// ErrInvalidChainMapping 映射表达式不合法或无法应用到上游任务的结果
+	ErrInvalidChainMapping = errors.New("映射表达式错误")
+)
+
+// JobOutput 已结束任务保存的产出物，Results 的字段同 TaskResult，data 保持原始的 JSON 结构
+type JobOutput struct {
+	Job     JobInfo                  `json:"job"`
+	Results []map[string]interface{} `json:"results"`
+}
+
+// LoadJobOutput 读取任务的产出物，已归档的产出物直接从冷存储读取，不恢复到热存储
+// owner 不是任务的发起人时同样返回 ErrJobOutputNotFound
+func (s *ArtifactService) LoadJobOutput(jobID, owner string) (*JobOutput, error) {
+	data, err := s.readArtifact(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	var saved struct {
+		Job    JobInfo `json:"job"`
+		Result struct {
+			Results []map[string]interface{} `json:"results"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("读取任务 %s 的产出物失败: %w", jobID, err)
+	}
+	if saved.Job.Owner != owner {
+		return nil, ErrJobOutputNotFound
+	}
+	if len(saved.Result.Results) == 0 {
+		return nil, ErrJobOutputEmpty
+	}
+	return &JobOutput{Job: saved.Job, Results: saved.Result.Results}, nil
+}
+
+// ChainMapping 将上游任务的结果映射为下游任务的输入
+//
+// Map 为每个下游任务的模板，可以是对象、数组或字符串，其中的字符串按 text/template 求值，
+// 数据为上游的一条结果（.id、.status、.data 等），指定 Each 时另有 .item 表示列表中的当前项。
+// 只由一个字段引用组成的字符串（如 "{{.data.output_path}}"）保留字段原本的类型，不转为字符串
+type ChainMapping struct {
+	Status string      `json:"status"` // 只映射该状态的结果，默认 success，"all" 表示全部
+	Each   string      `json:"each"`   // 结果中列表字段的路径（如 data），列表中的每一项生成一个任务；为空时每条结果生成一个任务
+	Map    interface{} `json:"map"`
+}
+
+// chainFieldRef 只由一个字段引用组成的模板
+var chainFieldRef = regexp.MustCompile(`^\{\{\s*\.([A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*)\s*\}\}$`)
+
+// Tasks 按映射生成下游任务，结果按上游任务的序号排列
+func (m ChainMapping) Tasks(results []map[string]interface{}) ([]interface{}, error) {
+	render, err := compileChainTemplate(m.Map)
+	if err != nil {
+		return nil, err
+	}
+	status := m.Status
+	if status == "" {
+		status = TaskStatusSuccess
+	}
+
+	tasks := []interface{}{}
+	for _, result := range results {
+		if status != "all" && result["status"] != status {
+			continue
+		}
+		if m.Each == "" {
+			task, err := render(result)
+			if err != nil {
+				return nil, fmt.Errorf("%w: 结果 %v: %v", ErrInvalidChainMapping, result["id"], err)
+			}
+			tasks = append(tasks, task)
+			continue
+		}
+
+		value, err := lookupField(result, strings.Split(m.Each, "."))
+		if err != nil {
+			return nil, fmt.Errorf("%w: 结果 %v: %v", ErrInvalidChainMapping, result["id"], err)
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: 结果 %v 的 %s 不是列表", ErrInvalidChainMapping, result["id"], m.Each)
+		}
+		for _, item := range items {
+			data := make(map[string]interface{}, len(result)+1)
+			for k, v := range result {
+				data[k] = v
+			}
+			data["item"] = item
+			task, err := render(data)
+			if err != nil {
+				return nil, fmt.Errorf("%w: 结果 %v: %v", ErrInvalidChainMapping, result["id"], err)
+			}
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+// compileChainTemplate 预先解析模板中的全部字符串，返回以一条结果生成任务的函数
+func compileChainTemplate(v interface{}) (func(data map[string]interface{}) (interface{}, error), error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("%w: map 不能为空", ErrInvalidChainMapping)
+	case string:
+		if m := chainFieldRef.FindStringSubmatch(v); m != nil {
+			path := strings.Split(m[1], ".")
+			return func(data map[string]interface{}) (interface{}, error) {
+				return lookupField(data, path)
+			}, nil
+		}
+		tmpl, err := template.New("map").Option("missingkey=error").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidChainMapping, err)
+		}
+		return func(data map[string]interface{}) (interface{}, error) {
+			var b strings.Builder
+			err := tmpl.Execute(&b, data)
+			return b.String(), err
+		}, nil
+	case map[string]interface{}:
+		fields := make(map[string]func(map[string]interface{}) (interface{}, error), len(v))
+		for key, field := range v {
+			render, err := compileChainTemplate(field)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = render
+		}
+		return func(data map[string]interface{}) (interface{}, error) {
+			task := make(map[string]interface{}, len(fields))
+			for key, render := range fields {
+				value, err := render(data)
+				if err != nil {
+					return nil, err
+				}
+				task[key] = value
+			}
+			return task, nil
+		}, nil
+	case []interface{}:
+		elems := make([]func(map[string]interface{}) (interface{}, error), len(v))
+		for i, elem := range v {
+			render, err := compileChainTemplate(elem)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = render
+		}
+		return func(data map[string]interface{}) (interface{}, error) {
+			list := make([]interface{}, len(elems))
+			for i, render := range elems {
+				value, err := render(data)
+				if err != nil {
+					return nil, err
+				}
+				list[i] = value
+			}
+			return list, nil
+		}, nil
+	}
+	// 数字、布尔值原样使用
+	return func(map[string]interface{}) (interface{}, error) { return v, nil }, nil
+}
+
+// lookupField 按路径读取嵌套对象中的字段
+func lookupField(data map[string]interface{}, path []string) (interface{}, error) {
+	var value interface{} = data
+	for i, key := range path {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s 不是对象", strings.Join(path[:i], "."))
+		}
+		if value, ok = obj[key]; !ok {
+			return nil, fmt.Errorf("字段 %s 不存在", strings.Join(path[:i+1], "."))
+		}
+	}
+	return value, nil
+}
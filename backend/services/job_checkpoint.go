@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobStatusInterrupted 服务意外退出时尚未结束、重启后未能恢复执行的任务
+const JobStatusInterrupted = "interrupted"
+
+// InstanceID 服务实例的标识，多个实例共用数据库时区分各实例登记的任务，重启后只检查本实例未结束的任务；
+// 为空时取主机名，主机名随部署变化时（如容器重建）应通过环境变量 INSTANCE_ID 指定固定的值
+var InstanceID string
+
+var instanceOnce sync.Once
+
+// currentInstance 返回 InstanceID，未指定时取主机名
+func currentInstance() string {
+	instanceOnce.Do(func() {
+		if InstanceID == "" {
+			InstanceID, _ = os.Hostname()
+		}
+	})
+	return InstanceID
+}
+
+// ErrCheckpointNotFound 任务没有保存检查点，不能恢复执行
+var ErrCheckpointNotFound = errors.New("任务没有保存检查点")
+
+// CheckpointStore 保存执行中批次的任务清单和已结束的任务，服务意外退出后据此从剩余的任务恢复执行
+type CheckpointStore struct {
+	DB *gorm.DB
+}
+
+// Checkpoint 中断的批次的检查点
+type Checkpoint struct {
+	Record  models.BatchJobResult // 服务退出前的任务记录，计数为最后一次刷新时的值
+	Request json.RawMessage       // 保存检查点时的请求信息，由调用方解析
+	Tasks   []json.RawMessage     // 任务清单，按任务序号
+	Done    []TaskResult          // 已结束的任务，按任务序号
+}
+
+// Save 保存任务清单，request 为恢复执行所需的请求信息，二者均按 JSON 序列化
+func (s *CheckpointStore) Save(jobID string, tasks, request interface{}) error {
+	rawTasks, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	}
+	rawRequest, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	return s.DB.Create(&models.JobCheckpoint{JobID: jobID, Tasks: string(rawTasks), Request: string(rawRequest)}).Error
+}
+
+// saveResults 保存已结束的任务，已保存的任务（恢复执行前已结束的）不覆盖
+func (s *CheckpointStore) saveResults(jobID string, results []TaskResult) error {
+	rows := make([]models.JobCheckpointTask, 0, len(results))
+	for _, result := range results {
+		raw, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, models.JobCheckpointTask{JobID: jobID, TaskIndex: result.ID, Result: string(raw)})
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// Delete 删除任务的检查点
+func (s *CheckpointStore) Delete(jobID string) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job_id = ?", jobID).Delete(&models.JobCheckpointTask{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("job_id = ?", jobID).Delete(&models.JobCheckpoint{}).Error
+	})
+}
+
+// Unfinished 本实例登记的、尚未结束的任务（服务退出前正在执行或排队），按开始时间排列
+func (s *CheckpointStore) Unfinished() ([]models.BatchJobResult, error) {
+	var records []models.BatchJobResult
+	err := s.DB.Where("instance = ? AND status IN ?", currentInstance(), []string{JobStatusRunning, JobStatusQueued}).
+		Order("start_time").
+		Find(&records).Error
+	return records, err
+}
+
+// Load 读取任务的检查点，没有保存检查点时返回 ErrCheckpointNotFound
+func (s *CheckpointStore) Load(record models.BatchJobResult) (*Checkpoint, error) {
+	var saved models.JobCheckpoint
+	err := s.DB.Where("job_id = ?", record.JobID).First(&saved).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCheckpointNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cp := &Checkpoint{Record: record, Request: json.RawMessage(saved.Request)}
+	if err := json.Unmarshal([]byte(saved.Tasks), &cp.Tasks); err != nil {
+		return nil, fmt.Errorf("读取任务 %s 的检查点失败: %w", record.JobID, err)
+	}
+	var rows []models.JobCheckpointTask
+	if err := s.DB.Where("job_id = ?", record.JobID).Order("task_index").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		var result TaskResult
+		if err := json.Unmarshal([]byte(row.Result), &result); err != nil {
+			return nil, fmt.Errorf("读取任务 %s 的检查点失败: %w", record.JobID, err)
+		}
+		if result.ID >= 0 && result.ID < len(cp.Tasks) {
+			cp.Done = append(cp.Done, result)
+		}
+	}
+	return cp, nil
+}
+
+// MarkInterrupted 将未能恢复执行的任务标记为 interrupted 并删除其检查点，计数保留服务退出前最后一次刷新的值；
+// reason 记入状态变更
+func (s *CheckpointStore) MarkInterrupted(jobID, reason string) error {
+	now := time.Now()
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.BatchJobResult
+		if err := tx.Select("status").Where("job_id = ?", jobID).First(&record).Error; err != nil {
+			return err
+		}
+		err := tx.Model(&models.BatchJobResult{}).
+			Where("job_id = ?", jobID).
+			Updates(map[string]interface{}{"status": JobStatusInterrupted, "end_time": &now}).Error
+		if err != nil {
+			return err
+		}
+		transition := models.JobTransition{JobID: jobID, From: record.Status, To: JobStatusInterrupted, Actor: ActorSystem, Reason: truncateUTF8(reason, maxTransitionReason), Time: now}
+		return tx.Create(&transition).Error
+	})
+	if err != nil {
+		return err
+	}
+	return s.Delete(jobID)
+}
+
+// resumed 记录任务恢复执行：状态改回 running，计数改为检查点中的值
+func (s *CheckpointStore) resumed(info JobInfo) error {
+	return s.DB.Model(&models.BatchJobResult{}).
+		Where("job_id = ?", info.ID).
+		Updates(map[string]interface{}{
+			"status":          JobStatusRunning,
+			"completed_tasks": info.CompletedTasks,
+			"success_tasks":   info.SuccessTasks,
+			"failed_tasks":    info.FailedTasks,
+			"cancelled_tasks": info.CancelledTasks,
+			"remaining_tasks": info.RemainingTasks,
+			"progress":        info.Progress,
+			"resumes":         info.Resumes,
+		}).Error
+}
+
+// DeleteOrphaned 删除已结束的任务残留的检查点（批次结束后、删除检查点前服务退出时会残留）
+func (s *CheckpointStore) DeleteOrphaned() error {
+	finished := s.DB.Model(&models.BatchJobResult{}).Select("job_id").
+		Where("status NOT IN ?", []string{JobStatusRunning, JobStatusQueued})
+	if err := s.DB.Where("job_id IN (?)", finished).Delete(&models.JobCheckpointTask{}).Error; err != nil {
+		return err
+	}
+	return s.DB.Where("job_id IN (?)", finished).Delete(&models.JobCheckpoint{}).Error
+}
+
+// Remaining 尚未结束的任务在批次中的序号
+func (c *Checkpoint) Remaining() []int {
+	done := make(map[int]bool, len(c.Done))
+	for _, result := range c.Done {
+		done[result.ID] = true
+	}
+	remaining := make([]int, 0, len(c.Tasks)-len(done))
+	for i := range c.Tasks {
+		if !done[i] {
+			remaining = append(remaining, i)
+		}
+	}
+	return remaining
+}
+
+// Merge 将恢复执行的剩余任务的结果（序号为在剩余任务中的序号）与检查点中已结束的任务合并为整个批次的结果，
+// 耗时从批次最初开始时计算，含服务停止的时间
+func (c *Checkpoint) Merge(ctx context.Context, result *BatchResult) *BatchResult {
+	remaining := c.Remaining()
+	results := append([]TaskResult(nil), c.Done...)
+	for _, r := range result.Results {
+		if r.ID >= 0 && r.ID < len(remaining) {
+			r.ID = remaining[r.ID]
+		}
+		results = append(results, r)
+	}
+	merged := buildBatchResult(ctx, c.Record.StartTime, len(c.Tasks), results)
+	merged.TotalBytes = result.TotalBytes
+	merged.Throughput = result.Throughput
+	return merged
+}
+
+// jobCheckpoint 任务的检查点，已结束的任务随进度一起刷新
+type jobCheckpoint struct {
+	store   *CheckpointStore
+	indices []int        // 恢复执行时剩余任务在批次中的序号，为nil时与执行的序号相同
+	pending []TaskResult // 尚未保存的已结束任务
+}
+
+// taskID 将执行的任务序号换算为在批次中的序号
+func (c *jobCheckpoint) taskID(id int) int {
+	if c.indices != nil && id >= 0 && id < len(c.indices) {
+		return c.indices[id]
+	}
+	return id
+}
+
+// add 记录一个已结束的任务，result.ID 为在批次中的序号，调用方持有 j.mu
+func (c *jobCheckpoint) add(result TaskResult) {
+	c.pending = append(c.pending, result)
+}
+
+// SaveCheckpoint 保存任务清单，服务意外退出后可从剩余的任务恢复执行，应在开始执行任务前调用；
+// tasks 为按序号排列的任务，request 为恢复执行所需的请求信息。未配置 Checkpoints 或任务只在内存中执行时不保存
+func (m *JobManager) SaveCheckpoint(job *Job, tasks, request interface{}) error {
+	if m.Checkpoints == nil || job.Info().Degraded {
+		return nil
+	}
+	if err := m.Checkpoints.Save(job.ID(), tasks, request); err != nil {
+		return err
+	}
+	job.mu.Lock()
+	job.checkpoint = &jobCheckpoint{store: m.Checkpoints}
+	job.mu.Unlock()
+	return nil
+}
+
+// Resume 按检查点重新登记中断的任务，沿用原任务ID、开始时间和已结束任务的计数，返回任务和绑定了任务的可取消上下文；
+// 调用方只执行 cp.Remaining() 中的任务，再以 cp.Merge 合并结果后结束任务
+func (m *JobManager) Resume(parent context.Context, cp *Checkpoint) (*Job, context.Context, error) {
+	ctx, cancel := context.WithCancel(parent)
+	now := time.Now()
+	record := cp.Record
+	info := JobInfo{
+		ID:         record.JobID,
+		Type:       record.JobType,
+		Owner:      record.Owner,
+		Status:     JobStatusRunning,
+		TotalTasks: len(cp.Tasks),
+		StartTime:  record.StartTime,
+		Resumes:    record.Resumes + 1,
+	}
+	for _, result := range cp.Done {
+		info.CompletedTasks++
+		switch result.Status {
+		case TaskStatusSuccess:
+			info.SuccessTasks++
+		case TaskStatusCancelled:
+			info.CancelledTasks++
+		default:
+			info.FailedTasks++
+		}
+	}
+	info.RemainingTasks = info.TotalTasks - info.CompletedTasks
+	info.Progress = progressPercent(info.CompletedTasks, info.TotalTasks)
+
+	// 沿用服务退出前保存的状态变更
+	var prior []models.JobTransition
+	if m.Transitions != nil {
+		var err error
+		if prior, err = m.Transitions.Load(info.ID); err != nil {
+			log.Printf("读取任务 %s 的状态变更失败: %v", info.ID, err)
+		}
+	}
+
+	m.mu.Lock()
+	if _, ok := m.jobs[info.ID]; ok {
+		m.mu.Unlock()
+		cancel()
+		return nil, nil, fmt.Errorf("任务 %s 已在执行", info.ID)
+	}
+	job := &Job{
+		info:            info,
+		inflight:        make(map[int]InflightTask),
+		cancel:          cancel,
+		stopCh:          make(chan struct{}),
+		done:            make(chan struct{}),
+		events:          newEventLog(m.EventBuffer),
+		store:           m.store,
+		lastFlush:       now,
+		flushEvery:      m.FlushEvery,
+		flushInterval:   m.FlushInterval,
+		monitor:         m.Monitor,
+		debug:           m.Debug,
+		resources:       &m.resources,
+		checkpoint:      &jobCheckpoint{store: m.Checkpoints, indices: cp.Remaining()},
+		transitionStore: m.Transitions,
+	}
+	job.transitions, job.savedTransitions = prior, len(prior)
+	job.transition(JobStateSubmitted, ActorSystem, fmt.Sprintf("服务重启后从检查点恢复执行（第 %d 次）", info.Resumes))
+	// 服务退出前已结束的任务计入分页查询的快照
+	for _, result := range cp.Done {
+		job.landed = append(job.landed, result)
+		job.landedIDs = append(job.landedIDs, result.ID)
+	}
+	m.jobs[info.ID] = job
+	m.mu.Unlock()
+
+	if err := m.Checkpoints.resumed(info); err != nil {
+		log.Printf("更新任务 %s 的恢复记录失败: %v", info.ID, err)
+	}
+	job.saveTransitions()
+	job.events.append(JobEventResumed, ResumeEvent{Resumes: info.Resumes, Completed: info.CompletedTasks, Remaining: info.RemainingTasks, Time: now})
+	m.Monitor.publishJob(LifecycleQueued, info)
+
+	return job, WithJob(ctx, job), nil
+}
+
+// ResumeEvent 任务在服务重启后恢复执行，见 JobEventResumed
+type ResumeEvent struct {
+	Resumes   int       `json:"resumes"`   // 第几次恢复执行
+	Completed int       `json:"completed"` // 服务退出前已结束、不再执行的任务数
+	Remaining int       `json:"remaining"` // 恢复执行的任务数
+	Time      time.Time `json:"time"`
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// 运行中可通过管理接口调整的配置项
+const (
+	ConfigMaxConcurrency = "max_concurrency" // 单个任务的并发数，值为 int
+	ConfigRateLimit      = "rate_limit"      // 服务共享的限速，值为 RateSetting
+)
+
+// ConfigChange 任务执行期间通过管理接口调整的运行时配置，记入任务信息的 config_changes 和任务事件 JobEventConfigChange，
+// 分析性能时据此区分调整前后的阶段
+type ConfigChange struct {
+	Setting string      `json:"setting"`
+	Scope   string      `json:"scope,omitempty"` // 共享配置的范围，如限速所属的服务；只作用于该任务时为空
+	Before  interface{} `json:"before"`
+	After   interface{} `json:"after"`
+	By      string      `json:"by,omitempty"` // 调整配置的用户
+	Time    time.Time   `json:"time"`
+	Elapsed int64       `json:"elapsed"` // 距任务开始的毫秒数，与报告中的并发时间线对应
+}
+
+// RateSetting 限速器的设置
+type RateSetting struct {
+	Rate  float64 `json:"rate"`  // 每秒开始执行的任务数
+	Burst int     `json:"burst"` // 最多积攒的令牌数
+}
+
+// RateSettingOf 返回限速器当前的设置
+func RateSettingOf(l *batch.RateLimiter) RateSetting {
+	return RateSetting{Rate: l.Rate(), Burst: l.Burst()}
+}
+
+// String 报告中显示的限速
+func (r RateSetting) String() string {
+	return fmt.Sprintf("%g/秒（突发 %d）", r.Rate, r.Burst)
+}
+
+// recordConfigChange 记录一次配置调整，任务已结束时不记录
+func (j *Job) recordConfigChange(change ConfigChange) bool {
+	j.mu.Lock()
+	if j.info.EndTime != nil {
+		j.mu.Unlock()
+		return false
+	}
+	change.Elapsed = change.Time.Sub(j.info.StartTime).Milliseconds()
+	j.info.ConfigChanges = append(j.info.ConfigChanges, change)
+	j.mu.Unlock()
+
+	j.events.append(JobEventConfigChange, change)
+	return true
+}
+
+// RecordConfigChange 将作用于多个任务的配置调整（如服务共享的限速器）记入 jobTypes 类型的所有未结束任务，返回受影响的任务ID
+func (m *JobManager) RecordConfigChange(jobTypes []string, change ConfigChange) []string {
+	m.mu.RLock()
+	var jobs []*Job
+	for _, job := range m.jobs {
+		for _, t := range jobTypes {
+			if job.Info().Type == t {
+				jobs = append(jobs, job)
+				break
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	affected := []string{}
+	for _, job := range jobs {
+		if job.recordConfigChange(change) {
+			affected = append(affected, job.ID())
+		}
+	}
+	return affected
+}
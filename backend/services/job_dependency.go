@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// JobStatusSkipped 依赖的上游任务未成功完成，批次没有执行任何任务
+const JobStatusSkipped = "skipped"
+
+// ErrDependencyFailed 上游任务未成功完成（有失败或取消的任务、被取消、被跳过，或已不在内存中）
+var ErrDependencyFailed = errors.New("上游任务未成功完成")
+
+// Dependency 上游任务结束后的判定，作为任务事件 JobEventDependency 的数据
+type Dependency struct {
+	JobID     string    `json:"job_id"`
+	DependsOn string    `json:"depends_on"`
+	Status    string    `json:"status"` // 上游任务的最终状态，上游已不在内存中时为空
+	Succeeded bool      `json:"succeeded"`
+	Time      time.Time `json:"time"`
+}
+
+// SetDependsOn 设置任务依赖的上游任务，应在 WaitDependency 前调用；上游是否存在、是否属于同一用户由调用方检查
+func (j *Job) SetDependsOn(upstreamID string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.DependsOn = upstreamID
+}
+
+// succeeded 上游任务是否成功完成：正常结束，且没有失败（含超时、未开始和跳过）或取消的子任务
+func (info JobInfo) succeeded() bool {
+	return info.Status == JobStatusCompleted && info.FailedTasks == 0 && info.CancelledTasks == 0
+}
+
+// WaitDependency 等到任务依赖的上游任务（见 SetDependsOn）结束后返回，没有依赖时立即返回。
+// 等待期间任务状态为 queued。上游成功完成时返回nil；否则以 CancelModeDependency 取消任务并返回 ErrDependencyFailed，
+// 调用方仍应照常执行并结束任务，批次不会执行任何任务，全部记为跳过，任务状态为 skipped。
+// 等待中被取消或 ctx 结束时返回错误，处理方式同 EnterGroup。判定结果记入任务事件
+func (m *JobManager) WaitDependency(ctx context.Context, job *Job) error {
+	job.mu.Lock()
+	upstreamID := job.info.DependsOn
+	if upstreamID != "" {
+		job.setStatus(JobStatusQueued, ActorSystem, "等待上游任务 "+upstreamID+" 结束")
+	}
+	job.mu.Unlock()
+	if upstreamID == "" {
+		return nil
+	}
+	// 等待上游的任务不计入排队数，预留的名额在等待前释放
+	m.mu.Lock()
+	m.releaseAdmission(job)
+	m.mu.Unlock()
+
+	dependency := Dependency{JobID: job.ID(), DependsOn: upstreamID}
+	if upstream, ok := m.Get(upstreamID); ok {
+		select {
+		case <-upstream.Done():
+		case <-job.stopCh:
+			job.dependencyResolved()
+			return context.Canceled
+		case <-ctx.Done():
+			job.dependencyResolved()
+			return ctx.Err()
+		}
+		info := upstream.Info()
+		dependency.Status, dependency.Succeeded = info.Status, info.succeeded()
+	}
+	dependency.Time = time.Now()
+	job.events.append(JobEventDependency, dependency)
+
+	if dependency.Succeeded {
+		job.dependencyResolved()
+		return nil
+	}
+	log.Printf("任务 %s 依赖的上游任务 %s 未成功完成（%s），跳过该任务", job.ID(), upstreamID, dependency.Status)
+	job.cancelWith(CancelModeDependency, "")
+	return ErrDependencyFailed
+}
+
+// dependencyResolved 结束对上游任务的等待，任务回到执行状态
+func (j *Job) dependencyResolved() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.info.Status == JobStatusQueued {
+		j.info.Status = JobStatusRunning
+	}
+}
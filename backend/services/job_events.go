@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// 任务事件类型
+const (
+	JobEventResult   = "result"   // 单个任务结果
+	JobEventSummary  = "summary"  // 任务结束，数据为 JobInfo
+	JobEventThrottle = "throttle" // 按主机调整并发，数据为 ThrottleDecision
+	// JobEventPreemption 排队已满时高优先级的批次抢占低优先级排队任务的位置，同时记入双方的事件，数据为 Preemption
+	JobEventPreemption = "preemption"
+	// JobEventDependency 上游任务结束，数据为 Dependency
+	JobEventDependency = "dependency"
+	// JobEventConfigChange 执行期间通过管理接口调整了并发数或限速，数据为 ConfigChange
+	JobEventConfigChange = "config_change"
+	// JobEventResumed 服务重启后任务从检查点恢复执行，数据为 ResumeEvent
+	JobEventResumed = "resumed"
+)
+
+// ErrEventsMissed 请求的事件已超出缓冲范围，客户端需要改为获取完整结果
+var ErrEventsMissed = errors.New("事件已超出缓冲范围")
+
+// JobEvent 任务事件，ID 从1开始递增，用于断线重连时续传
+type JobEvent struct {
+	ID   int64       `json:"id"`
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// eventLog 任务事件缓冲，只保留最近的 max 条
+type eventLog struct {
+	mu     sync.Mutex
+	events []JobEvent
+	nextID int64
+	max    int
+	closed bool
+	notify chan struct{} // 有新事件或关闭时关闭并替换
+}
+
+func newEventLog(max int) *eventLog {
+	return &eventLog{
+		nextID: 1,
+		max:    max,
+		notify: make(chan struct{}),
+	}
+}
+
+// append 追加事件并唤醒等待的订阅者
+func (l *eventLog) append(eventType string, data interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+
+	l.events = append(l.events, JobEvent{ID: l.nextID, Type: eventType, Data: data})
+	l.nextID++
+	if l.max > 0 && len(l.events) > l.max {
+		l.events = append([]JobEvent(nil), l.events[len(l.events)-l.max:]...)
+	}
+
+	close(l.notify)
+	l.notify = make(chan struct{})
+}
+
+// close 任务结束后不再追加事件
+func (l *eventLog) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return
+	}
+	l.closed = true
+	close(l.notify)
+}
+
+// since 返回 after 之后的事件、下次等待的通道以及是否已关闭
+func (l *eventLog) since(after int64) ([]JobEvent, <-chan struct{}, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// 缓冲中最早的事件之前还有未读的事件，说明已被丢弃
+	if len(l.events) > 0 && after < l.events[0].ID-1 {
+		return nil, nil, false, ErrEventsMissed
+	}
+
+	var events []JobEvent
+	for i := range l.events {
+		if l.events[i].ID > after {
+			events = append(events, l.events[i:]...)
+			break
+		}
+	}
+	return events, l.notify, l.closed, nil
+}
+
+// Follow 从 after 之后的事件开始依次回调 fn，直到任务结束、ctx 取消或 fn 返回错误
+// after 为0时从第一条事件开始；断线重连时传入最后收到的事件ID
+func (j *Job) Follow(ctx context.Context, after int64, fn func(JobEvent) error) error {
+	for {
+		events, notify, closed, err := j.events.since(after)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+			after = event.ID
+		}
+		if closed {
+			return nil
+		}
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"sort"
+)
+
+// JobStatusQueued 任务正在排队：等待互斥组中同组的前序任务结束，或等待全局队列的执行名额
+const JobStatusQueued = "queued"
+
+// jobGroup 互斥组：同一时间只有一个任务执行，其余任务按进入顺序排队
+type jobGroup struct {
+	holder *Job
+	queue  []*Job
+}
+
+// GroupInfo 互斥组的当前状态
+type GroupInfo struct {
+	Name    string   `json:"name"`
+	Running string   `json:"running"` // 执行中的任务ID
+	Queued  []string `json:"queued"`  // 排队中的任务ID，按执行顺序
+}
+
+// EnterGroup 将任务加入互斥组（如 nightly-reconciliation），等到同组的前序任务全部结束后返回，
+// 任务结束（Finish）时自动离开互斥组，唤醒下一个排队的任务。group 为空时立即返回。
+// 排队期间任务状态为 queued；排队中被取消或 ctx 结束时离开队列并返回错误，调用方仍应照常执行并结束任务，
+// 批次会因已取消而不执行任何任务。互斥组只在本实例内生效
+func (m *JobManager) EnterGroup(ctx context.Context, job *Job, group string) error {
+	if group == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	if m.groups == nil {
+		m.groups = make(map[string]*jobGroup)
+	}
+	g, ok := m.groups[group]
+	if !ok {
+		g = &jobGroup{}
+		m.groups[group] = g
+	}
+	job.mu.Lock()
+	job.info.Group = group
+	job.groupReady = make(chan struct{})
+	if g.holder == nil {
+		g.holder = job
+		close(job.groupReady)
+	} else {
+		g.queue = append(g.queue, job)
+		m.releaseAdmission(job)
+		job.setStatus(JobStatusQueued, ActorSystem, "等待互斥组 "+group+" 中的任务结束")
+	}
+	ready := job.groupReady
+	job.mu.Unlock()
+	m.mu.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-job.stopCh:
+	case <-ctx.Done():
+	}
+
+	// 排队中被取消：离开队列；同时被唤醒时已成为执行者，由 Finish 离开互斥组
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case <-ready:
+		return nil
+	default:
+	}
+	for i, queued := range g.queue {
+		if queued == job {
+			g.queue = append(g.queue[:i], g.queue[i+1:]...)
+			break
+		}
+	}
+	job.mu.Lock()
+	job.info.Group = ""
+	job.info.Status = JobStatusRunning
+	job.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// 软取消：ctx 未取消，但批次不会再派发任务
+	return context.Canceled
+}
+
+// leaveGroup 任务结束时离开互斥组，唤醒队首的任务
+func (m *JobManager) leaveGroup(job *Job, group string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g, ok := m.groups[group]
+	if !ok || g.holder != job {
+		return
+	}
+	if len(g.queue) == 0 {
+		delete(m.groups, group)
+		return
+	}
+	next := g.queue[0]
+	g.queue = g.queue[1:]
+	g.holder = next
+	next.mu.Lock()
+	if next.info.Status == JobStatusQueued {
+		next.info.Status = JobStatusRunning
+	}
+	close(next.groupReady)
+	next.mu.Unlock()
+}
+
+// Groups 返回当前有任务的互斥组
+func (m *JobManager) Groups() []GroupInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	groups := make([]GroupInfo, 0, len(m.groups))
+	for name, g := range m.groups {
+		info := GroupInfo{Name: name, Running: g.holder.ID(), Queued: make([]string, len(g.queue))}
+		for i, job := range g.queue {
+			info.Queued[i] = job.ID()
+		}
+		groups = append(groups, info)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
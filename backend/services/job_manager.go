@@ -0,0 +1,691 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+	"concurrency-web-app/backend/models"
+)
+
+// 任务状态
+const (
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusCancelled = "cancelled"
+)
+
+// CancelMode 取消模式
+type CancelMode string
+
+const (
+	// CancelModeSoft 软取消：停止派发新任务，等待执行中的任务完成
+	CancelModeSoft CancelMode = "soft"
+	// CancelModeHard 硬取消：立即取消上下文，未完成的任务全部标记为已取消
+	CancelModeHard CancelMode = "hard"
+	// CancelModeFailFast 快速失败：首个任务失败后自动取消，处理方式同硬取消，由请求的 fail_fast 选项触发
+	CancelModeFailFast CancelMode = "fail_fast"
+	// CancelModeShutdown 服务关闭：等待期限内未完成的任务在退出前自动取消，处理方式同硬取消，已完成的结果照常保存
+	CancelModeShutdown CancelMode = "shutdown"
+	// CancelModeDependency 上游任务未成功完成：不派发任何任务，全部任务记为跳过，任务状态为 skipped
+	CancelModeDependency CancelMode = "dependency"
+)
+
+// aborts 是否立即中止执行中的任务，未完成的任务计为已取消
+func (m CancelMode) aborts() bool {
+	return m == CancelModeHard || m == CancelModeFailFast || m == CancelModeShutdown
+}
+
+var (
+	// ErrJobNotFound 任务不存在
+	ErrJobNotFound = errors.New("任务不存在")
+	// ErrJobFinished 任务已结束
+	ErrJobFinished = errors.New("任务已结束")
+	// ErrInvalidCancelMode 不支持的取消模式
+	ErrInvalidCancelMode = errors.New("不支持的取消模式")
+	// ErrConcurrencyNotAdjustable 任务当前没有可调整并发数的批次在执行（尚未开始、已结束或调度方式不支持）
+	ErrConcurrencyNotAdjustable = errors.New("任务当前不支持调整并发数")
+)
+
+// JobInfo 任务信息快照
+type JobInfo struct {
+	ID             string         `json:"id"`
+	Type           string         `json:"type"`
+	Owner          string         `json:"owner"`
+	Status         string         `json:"status"`
+	TotalTasks     int            `json:"total_tasks"`
+	SuccessTasks   int            `json:"success_tasks"`
+	FailedTasks    int            `json:"failed_tasks"`
+	CancelledTasks int            `json:"cancelled_tasks"`
+	CompletedTasks int            `json:"completed_tasks"`
+	RemainingTasks int            `json:"remaining_tasks"`
+	Progress       float64        `json:"progress"` // 完成百分比
+	CancelMode     CancelMode     `json:"cancel_mode,omitempty"`
+	StartTime      time.Time      `json:"start_time"`
+	EndTime        *time.Time     `json:"end_time,omitempty"`
+	Run            *RunRecord     `json:"run,omitempty"`             // 复现该批次所需的种子、配置快照和代码版本
+	MaxConcurrency int            `json:"max_concurrency,omitempty"` // 执行中的批次当前的并发数，不支持调整时为0
+	Group          string         `json:"group,omitempty"`           // 所属的互斥组，同组任务依次执行
+	Priority       string         `json:"priority,omitempty"`        // 在全局队列中的优先级类别：high、normal、low
+	DependsOn      string         `json:"depends_on,omitempty"`      // 上游任务ID，上游成功完成后才开始执行
+	TemplateID     uint           `json:"template_id,omitempty"`     // 由批次模板执行时的模板ID，决定任务记录和明细的保留时间
+	ExpiresAt      *time.Time     `json:"expires_at,omitempty"`      // 任务结束时按保留策略计算的结果过期时间，之后不能再获取结果，为空时一直保留
+	ConfigChanges  []ConfigChange `json:"config_changes,omitempty"`  // 执行期间通过管理接口调整的并发数和限速，按时间顺序
+	Resumes        int            `json:"resumes,omitempty"`         // 服务意外退出后从检查点恢复执行的次数
+	TenantID       uint           `json:"tenant_id,omitempty"`       // 通过API密钥标识的租户，决定保存结果时使用的数据密钥
+	Metrics        *JobMetrics    `json:"metrics,omitempty"`         // 任务结束时记录的耗时、吞吐量和失败率
+	DebugBundles   int            `json:"debug_bundles,omitempty"`   // 已保存的失败任务调试包数
+	Degraded       bool           `json:"degraded,omitempty"`        // 登记时数据库不可用，任务只在内存中执行，结束后在后台补写记录
+}
+
+// InflightTask 正在执行的任务
+type InflightTask struct {
+	ID        int       `json:"id"`   // 任务在批次中的序号
+	Slot      int       `json:"slot"` // 占用的工作槽位
+	StartTime time.Time `json:"start_time"`
+	Elapsed   int64     `json:"elapsed"` // 毫秒
+	// LastHeartbeat 最近一次心跳的时间，见 Heartbeat
+	LastHeartbeat time.Time `json:"last_heartbeat"`
+
+	beat *taskBeat
+}
+
+// Job 运行中的批量任务
+type Job struct {
+	mu       sync.RWMutex
+	info     JobInfo
+	result   *BatchResult
+	inflight map[int]InflightTask
+	running  int   // 正在执行的子任务数
+	timeline []int // 每秒的最大并发数，按距任务开始的秒数索引
+	cancel   context.CancelFunc
+	stopCh   chan struct{} // 软取消时关闭，停止派发新任务
+	failFast bool          // 首个任务失败时自动取消其余任务
+	chunks   *batch.Chunks // 分块执行，为nil时不分块
+	limits   RunLimits     // 请求覆盖的并发数和批次超时，为零值时按服务的配置
+	order    ResultOrder   // 结果的顺序
+	done     chan struct{} // 任务结束时关闭
+	events   *eventLog
+	// groupReady 轮到任务在互斥组中执行时关闭
+	groupReady chan struct{}
+	// queueReady 任务在全局队列中取得执行名额时关闭，queueSlot 表示名额尚未释放
+	queueReady chan struct{}
+	queueSlot  bool
+	// admission 提交时预留的排队名额，进入排队或开始执行时释放，由 JobManager.mu 保护
+	admission *Admission
+	// concurrency 执行中的批次的并发数，可在运行中调整
+	concurrency batch.Concurrency
+
+	store         ProgressStore
+	pending       ProgressDelta // 尚未刷新到存储的结果数
+	lastFlush     time.Time
+	flushEvery    int
+	flushInterval time.Duration
+	recent        []time.Time // 最近 throughputWindow 内结束的任务的时间，用于计算当前吞吐量
+
+	monitor *JobMonitor
+	started bool // 是否已发送 started 事件
+
+	debug        *DebugCapture
+	debugCapture bool // 为失败的任务保存调试包
+
+	retention time.Duration // 模板保留策略中明细的保留时间，0表示按 JobManager.ResultTTL
+
+	checkpoint *jobCheckpoint // 保存了检查点时随进度刷新已结束的任务，为nil时不保存
+
+	// landed 执行中已结束的任务结果，按结束顺序，供分页查询；任务结束后释放，只保留 landedIDs 中的任务序号
+	landed         []TaskResult
+	landedIDs      []int
+	discardResults bool // 不保留已结束的任务结果，见 DiscardResults
+
+	// transitions 状态变更记录，前 savedTransitions 条已保存到 transitionStore
+	transitions      []models.JobTransition
+	savedTransitions int
+	transitionStore  *TransitionStore
+	cancelBy         string // 请求取消的用户，为空时由服务自身取消
+
+	resources   *resourceTracker
+	usageStart  resourceSnapshot // 第一个子任务开始时的资源消耗
+	overlapped  atomic.Bool      // 执行期间有其他批次同时执行
+	workerUsage bool             // 记录每个工作槽位的线程 CPU 时间
+	workers     map[int]*WorkerUsage
+}
+
+// ID 返回任务ID
+func (j *Job) ID() string {
+	return j.info.ID
+}
+
+// Info 返回任务信息快照
+func (j *Job) Info() JobInfo {
+	j.mu.RLock()
+	info := j.info
+	j.mu.RUnlock()
+	info.MaxConcurrency = j.concurrency.Limit()
+	return info
+}
+
+// Result 返回任务的最终结果，任务未结束时为nil
+func (j *Job) Result() *BatchResult {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.result
+}
+
+// Done 返回任务结束时关闭的通道
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// CancelMode 返回任务的取消模式，未取消时为空
+func (j *Job) CancelMode() CancelMode {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.info.CancelMode
+}
+
+// Inflight 返回正在执行的任务，按已执行时间倒序
+func (j *Job) Inflight() []InflightTask {
+	j.mu.RLock()
+	tasks := make([]InflightTask, 0, len(j.inflight))
+	for _, task := range j.inflight {
+		task.Elapsed = time.Since(task.StartTime).Milliseconds()
+		task.LastHeartbeat = time.Unix(0, task.beat.last.Load())
+		tasks = append(tasks, task)
+	}
+	j.mu.RUnlock()
+
+	sort.Slice(tasks, func(a, b int) bool {
+		return tasks[a].Elapsed > tasks[b].Elapsed
+	})
+	return tasks
+}
+
+// EnableFailFast 开启快速失败：首个任务失败后取消其余任务，已完成的结果照常返回
+func (j *Job) EnableFailFast() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.failFast = true
+}
+
+// SetChunks 设置分块执行：每块的任务数和块间暂停，应在开始执行任务前调用
+func (j *Job) SetChunks(chunks *batch.Chunks) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if chunks != nil {
+		// 块间暂停记入状态变更
+		c := *chunks
+		c.OnPause = j.chunkPaused
+		chunks = &c
+	}
+	j.chunks = chunks
+}
+
+// SetLimits 以请求指定的并发数和批次超时覆盖服务的配置，字段为0时按服务的配置；应在开始执行任务前调用，
+// 是否超出服务端的上限由调用方检查
+func (j *Job) SetLimits(limits RunLimits) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.limits = limits
+}
+
+// SetResultOrder 设置结果的顺序，应在开始执行任务前调用
+func (j *Job) SetResultOrder(order ResultOrder) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.order = order
+}
+
+// SetRun 记录复现该批次所需的信息，应在开始执行任务前调用；随任务结束一起写入任务记录
+func (j *Job) SetRun(run *RunRecord) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Run = run
+}
+
+// SetTemplate 记录执行该批次的模板，0 表示不是由模板执行；随任务结束一起写入任务记录和产出物。
+// retention 为模板保留策略中明细的保留时间，用于计算结果的过期时间，0 表示模板未设置保留策略
+func (j *Job) SetTemplate(templateID uint, retention time.Duration) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.TemplateID = templateID
+	j.retention = retention
+}
+
+// SetTenant 记录任务所属的租户，0 表示请求未通过API密钥标识租户
+func (j *Job) SetTenant(tenantID uint) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.TenantID = tenantID
+}
+
+// SoftCancelled 是否已停止派发新任务
+func (j *Job) SoftCancelled() bool {
+	select {
+	case <-j.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+type jobContextKey struct{}
+
+// WithJob 将任务绑定到上下文
+func WithJob(ctx context.Context, job *Job) context.Context {
+	return context.WithValue(ctx, jobContextKey{}, job)
+}
+
+// JobFromContext 从上下文中取出任务，没有绑定时返回nil
+func JobFromContext(ctx context.Context) *Job {
+	job, _ := ctx.Value(jobContextKey{}).(*Job)
+	return job
+}
+
+// jobConcurrency 返回 ctx 所属任务的可调整并发数，不在任务中执行时返回nil
+func jobConcurrency(ctx context.Context) *batch.Concurrency {
+	if job := JobFromContext(ctx); job != nil {
+		return &job.concurrency
+	}
+	return nil
+}
+
+// jobChunks 返回 ctx 所属任务的分块设置，不在任务中执行或不分块时返回nil
+func jobChunks(ctx context.Context) *batch.Chunks {
+	job := JobFromContext(ctx)
+	if job == nil {
+		return nil
+	}
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+	return job.chunks
+}
+
+// jobMaxConcurrency 返回 ctx 所属任务的并发数，请求未覆盖或不在任务中执行时返回服务的配置 n
+func jobMaxConcurrency(ctx context.Context, n int) int {
+	if job := JobFromContext(ctx); job != nil {
+		job.mu.RLock()
+		defer job.mu.RUnlock()
+		if job.limits.MaxConcurrency > 0 {
+			return job.limits.MaxConcurrency
+		}
+	}
+	return n
+}
+
+// jobTimeout 返回 ctx 所属任务的批次超时，请求未覆盖或不在任务中执行时返回服务的配置 d
+func jobTimeout(ctx context.Context, d time.Duration) time.Duration {
+	if job := JobFromContext(ctx); job != nil {
+		job.mu.RLock()
+		defer job.mu.RUnlock()
+		if job.limits.Timeout > 0 {
+			return job.limits.Timeout
+		}
+	}
+	return d
+}
+
+// jobResultOrder 返回 ctx 所属任务要求的结果顺序，不在任务中执行时返回 ResultOrderDefault
+func jobResultOrder(ctx context.Context) ResultOrder {
+	job := JobFromContext(ctx)
+	if job == nil {
+		return ResultOrderDefault
+	}
+	job.mu.RLock()
+	defer job.mu.RUnlock()
+	return job.order
+}
+
+// trackInflight 登记正在执行的任务，返回任务的上下文和在任务结束时注销登记的函数
+// 任务应在返回的上下文中执行：其中记录任务的心跳（见 Heartbeat），回收器取消卡住的任务时只取消该上下文
+func trackInflight(ctx context.Context, index, slot int) (context.Context, func()) {
+	job := JobFromContext(ctx)
+	if job == nil {
+		return ctx, func() {}
+	}
+	job.markStarted()
+	workerDone := job.trackWorkerCPU(slot)
+
+	beat := &taskBeat{}
+	ctx, beat.cancel = context.WithCancelCause(ctx)
+	ctx = context.WithValue(ctx, taskBeatKey{}, beat)
+
+	job.mu.Lock()
+	now := time.Now()
+	beat.last.Store(now.UnixNano())
+	job.inflight[index] = InflightTask{
+		ID:        index,
+		Slot:      slot,
+		StartTime: now,
+		beat:      beat,
+	}
+	job.recordRunning(now, 1)
+	job.mu.Unlock()
+
+	return ctx, func() {
+		workerDone()
+		beat.cancel(nil)
+		job.mu.Lock()
+		delete(job.inflight, index)
+		job.recordRunning(time.Now(), -1)
+		job.mu.Unlock()
+	}
+}
+
+// maxTimelineSeconds 并发时间线记录的最长时间，超出后不再记录
+const maxTimelineSeconds = 24 * 60 * 60
+
+// recordRunning 更新正在执行的子任务数并记入当前秒的最大并发数，调用方持有 j.mu
+func (j *Job) recordRunning(now time.Time, delta int) {
+	second := int(now.Sub(j.info.StartTime) / time.Second)
+	if second >= 0 && second < maxTimelineSeconds {
+		// 此前没有子任务开始或结束的秒内，并发数保持不变
+		for len(j.timeline) <= second {
+			j.timeline = append(j.timeline, j.running)
+		}
+	}
+	j.running += delta
+	if second >= 0 && second < len(j.timeline) {
+		j.timeline[second] = max(j.timeline[second], j.running)
+	}
+}
+
+// ConcurrencyTimeline 返回任务开始后每秒的最大并发数
+func (j *Job) ConcurrencyTimeline() []int {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return append([]int(nil), j.timeline...)
+}
+
+// JobManager 批量任务管理器
+type JobManager struct {
+	mu    sync.RWMutex
+	jobs  map[string]*Job
+	seq   uint64
+	store ProgressStore
+
+	FlushEvery    int              // 累计多少个结果刷新一次进度
+	FlushInterval time.Duration    // 距上次刷新超过该时间也会刷新
+	EventBuffer   int              // 每个任务缓冲的事件数，供断线重连的订阅者补发
+	Pushgateway   *Pushgateway     // 任务结束时推送指标，为nil时只记录在任务信息和任务记录中
+	Monitor       *JobMonitor      // 广播任务生命周期事件
+	Debug         *DebugCapture    // 保存失败任务的调试包，为nil时不支持调试捕获
+	Persist       *PersistQueue    // 数据库不可用时暂存任务记录的写入，为nil时写入失败只记录日志
+	MaxRunning    int              // 全局队列同时执行的任务数，超出时按优先级排队，0表示不限
+	MaxQueued     int              // 排队的任务数上限，达到上限时拒绝新批次，0表示不限
+	StallTimeout  time.Duration    // 子任务超过该时间没有心跳时由 ReapStalled 强制取消，0表示不检查
+	ResultTTL     time.Duration    // 结束的任务的结果保留时长，过期后由保留清理删除，0表示一直保留；模板设置了保留策略时按模板
+	Checkpoints   *CheckpointStore // 保存异步批次的检查点，服务意外退出后恢复执行，为nil时不保存
+	Transitions   *TransitionStore // 保存任务的状态变更记录，为nil时只在内存中保留
+	FinishedTTL   time.Duration    // 结束的任务在内存中保留的时长，之后由 EvictExpired 移除，0表示不按时长移除
+	MaxFinished   int              // 内存中最多保留的结束的任务数，任务结束时超出的最早结束的任务被移除，0表示不限
+
+	drainOnce sync.Once
+	drain     chan struct{} // 服务关闭时关闭，之后不再接收新批次
+
+	groups map[string]*jobGroup // 有任务执行或排队的互斥组，由 mu 保护
+	queue  jobQueue             // 全局队列，由 mu 保护
+
+	resources resourceTracker // 正在执行的批次，用于标记资源消耗互相重叠的批次
+}
+
+// NewJobManager 创建任务管理器，store 为nil时进度只保存在内存中
+func NewJobManager(store ProgressStore) *JobManager {
+	return &JobManager{
+		jobs:          make(map[string]*Job),
+		store:         store,
+		FlushEvery:    20,
+		FlushInterval: 500 * time.Millisecond,
+		EventBuffer:   1000,
+		FinishedTTL:   DefaultFinishedTTL,
+		MaxFinished:   DefaultMaxFinished,
+		Monitor:       &JobMonitor{},
+		drain:         make(chan struct{}),
+	}
+}
+
+// Drain 标记服务正在关闭：批量接口不再接收新批次，执行中的批次继续执行
+func (m *JobManager) Drain() {
+	m.drainOnce.Do(func() { close(m.drain) })
+}
+
+// Draining 返回服务开始关闭时关闭的通道
+func (m *JobManager) Draining() <-chan struct{} {
+	return m.drain
+}
+
+// CancelRunning 以指定模式取消全部执行中的任务，返回取消的任务数
+func (m *JobManager) CancelRunning(mode CancelMode) int {
+	m.mu.RLock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.mu.RUnlock()
+
+	cancelled := 0
+	for _, job := range jobs {
+		if _, err := job.cancelWith(mode, ""); err == nil {
+			cancelled++
+		}
+	}
+	return cancelled
+}
+
+// Start 登记一个新任务，返回任务和绑定了任务的可取消上下文
+func (m *JobManager) Start(parent context.Context, jobType, owner string, totalTasks int) (*Job, context.Context) {
+	ctx, cancel := context.WithCancel(parent)
+
+	m.mu.Lock()
+	m.seq++
+	now := time.Now()
+	job := &Job{
+		info: JobInfo{
+			ID:             fmt.Sprintf("job_%d_%04d", now.Unix(), m.seq),
+			Type:           jobType,
+			Owner:          owner,
+			Status:         JobStatusRunning,
+			TotalTasks:     totalTasks,
+			RemainingTasks: totalTasks,
+			Progress:       progressPercent(0, totalTasks),
+			StartTime:      now,
+		},
+		inflight:        make(map[int]InflightTask),
+		cancel:          cancel,
+		stopCh:          make(chan struct{}),
+		done:            make(chan struct{}),
+		events:          newEventLog(m.EventBuffer),
+		store:           m.store,
+		lastFlush:       now,
+		flushEvery:      m.FlushEvery,
+		flushInterval:   m.FlushInterval,
+		monitor:         m.Monitor,
+		debug:           m.Debug,
+		resources:       &m.resources,
+		transitions:     []models.JobTransition{{To: JobStateSubmitted, Actor: owner, Time: now}},
+		transitionStore: m.Transitions,
+	}
+	m.jobs[job.info.ID] = job
+	m.mu.Unlock()
+
+	if m.store != nil {
+		info := job.info
+		if !m.Persist.Do("登记任务 "+info.ID, func() error { return m.store.Create(info) }) {
+			// 数据库不可用时任务只在内存中执行，不刷新进度，登记和最终结果在后台补写
+			job.mu.Lock()
+			job.store = nil
+			job.info.Degraded = true
+			job.mu.Unlock()
+		}
+	}
+	// 降级时状态变更随最终结果一起补写
+	if !job.Info().Degraded {
+		job.saveTransitions()
+	}
+	m.Monitor.publishJob(LifecycleQueued, job.info)
+
+	return job, WithJob(ctx, job)
+}
+
+// Finish 记录任务的最终结果
+func (m *JobManager) Finish(job *Job, result *BatchResult) {
+	job.mu.Lock()
+	now := time.Now()
+	job.info.EndTime = &now
+	job.info.SuccessTasks = result.SuccessTasks
+	job.info.FailedTasks = result.FailedTasks
+	job.info.CancelledTasks = result.CancelledTasks
+	job.info.CompletedTasks = result.SuccessTasks + result.FailedTasks + result.CancelledTasks
+	job.info.RemainingTasks = job.info.TotalTasks - job.info.CompletedTasks
+	job.info.Progress = progressPercent(job.info.CompletedTasks, job.info.TotalTasks)
+	switch job.info.CancelMode {
+	case "":
+		job.info.Status = JobStatusCompleted
+	case CancelModeDependency:
+		job.info.Status = JobStatusSkipped
+	default:
+		job.info.Status = JobStatusCancelled
+	}
+	job.finalTransition()
+	transitions := job.unsavedTransitions()
+	job.info.ExpiresAt = job.expiresAt(now, m.ResultTTL)
+	metrics := NewJobMetrics(job.info, result)
+	metrics.Resources = job.finishUsage(result.TotalTasks - result.NotStartedTasks - result.SkippedTasks)
+	job.info.Metrics = &metrics
+	job.result = result
+	job.pending = ProgressDelta{}
+	job.landed = nil
+	if job.checkpoint != nil {
+		job.checkpoint.pending = nil
+	}
+	info := job.info
+	persist := job.store != nil || info.Degraded
+	job.mu.Unlock()
+
+	m.resources.end(job)
+	if info.Group != "" {
+		m.leaveGroup(job, info.Group)
+	}
+	m.leaveQueue(job)
+	job.cancel()
+	job.events.append(JobEventSummary, info)
+	job.events.close()
+	close(job.done)
+	job.monitor.publishJob(LifecycleFinished, info)
+
+	if persist {
+		m.Persist.Do("保存任务 "+info.ID+" 结果", func() error { return m.store.Complete(info, metrics) })
+	}
+	if len(transitions) > 0 {
+		m.Persist.Do("保存任务 "+info.ID+" 状态变更", func() error { return job.saveTransitionList(transitions) })
+	}
+	if job.checkpoint != nil {
+		if err := job.checkpoint.store.Delete(info.ID); err != nil {
+			log.Printf("删除任务 %s 的检查点失败: %v", info.ID, err)
+		}
+	}
+	// 同步推送，进程在批次结束后随即退出时指标也不会丢失
+	if m.Pushgateway != nil {
+		if err := m.Pushgateway.Push(context.Background(), info, metrics); err != nil {
+			log.Printf("推送任务 %s 的指标失败: %v", info.ID, err)
+		}
+	}
+	// 结束的任务超出上限时移除最早结束的任务，不必等到下一次保留清理
+	if m.MaxFinished > 0 {
+		m.EvictExpired(now)
+	}
+}
+
+// SetConcurrency 调整执行中的任务的并发数，n 超出 [1, batch.MaxConcurrencyLimit] 时取边界值
+// 调高立即生效，调低时正在执行的子任务不受影响，之后按新的并发数执行；并发数有变化时记入任务的配置调整，by 为调整的用户
+func (m *JobManager) SetConcurrency(id string, n int, by string) (JobInfo, error) {
+	job, ok := m.Get(id)
+	if !ok {
+		return JobInfo{}, ErrJobNotFound
+	}
+	select {
+	case <-job.done:
+		return job.Info(), ErrJobFinished
+	default:
+	}
+	before := job.concurrency.Limit()
+	after, ok := job.concurrency.Set(n)
+	if !ok {
+		return job.Info(), ErrConcurrencyNotAdjustable
+	}
+	if after != before {
+		job.recordConfigChange(ConfigChange{Setting: ConfigMaxConcurrency, Before: before, After: after, By: by, Time: time.Now()})
+	}
+	return job.Info(), nil
+}
+
+// Get 获取任务
+func (m *JobManager) Get(id string) (*Job, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	job, ok := m.jobs[id]
+	return job, ok
+}
+
+// List 列出用户的任务，按开始时间倒序；owner 为空时列出全部
+func (m *JobManager) List(owner string) []JobInfo {
+	m.mu.RLock()
+	infos := make([]JobInfo, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		info := job.Info()
+		if owner != "" && info.Owner != owner {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartTime.After(infos[j].StartTime)
+	})
+	return infos
+}
+
+// Cancel 按指定模式取消任务
+func (m *JobManager) Cancel(id string, mode CancelMode, by string) (JobInfo, error) {
+	if mode != CancelModeSoft && mode != CancelModeHard {
+		return JobInfo{}, ErrInvalidCancelMode
+	}
+
+	job, ok := m.Get(id)
+	if !ok {
+		return JobInfo{}, ErrJobNotFound
+	}
+	return job.cancelWith(mode, by)
+}
+
+// cancelWith 按指定模式取消任务，by 为请求取消的用户，由服务自身取消时为空
+func (j *Job) cancelWith(mode CancelMode, by string) (JobInfo, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.info.Status != JobStatusRunning && j.info.Status != JobStatusQueued {
+		return j.info, ErrJobFinished
+	}
+
+	// 软取消之后仍允许升级为硬取消，反之不行
+	switch j.info.CancelMode {
+	case "":
+		close(j.stopCh)
+	case CancelModeHard, CancelModeFailFast, CancelModeShutdown:
+		return j.info, nil
+	}
+	j.info.CancelMode = mode
+	j.cancelBy = by
+	if mode.aborts() {
+		j.cancel()
+	}
+
+	return j.info, nil
+}
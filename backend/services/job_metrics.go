@@ -0,0 +1,121 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// JobMetrics 任务结束时记录的关键指标，以 JSON 保存在 BatchJobResult.Metrics
+type JobMetrics struct {
+	DurationMs     int64   `json:"duration_ms"`
+	Throughput     float64 `json:"throughput"`   // 每秒结束的任务数
+	FailureRate    float64 `json:"failure_rate"` // 失败（含超时和未开始）的任务占已结束任务的比例，0-1
+	TotalTasks     int     `json:"total_tasks"`
+	SuccessTasks   int     `json:"success_tasks"`
+	FailedTasks    int     `json:"failed_tasks"`
+	CancelledTasks int     `json:"cancelled_tasks"`
+	ThroughputMBps float64 `json:"throughput_mbps,omitempty"` // 处理文件内容的批次的吞吐量
+
+	Resources *ResourceUsage `json:"resources,omitempty"` // 批次消耗的 CPU 时间和内存分配，没有任务执行时为空
+}
+
+// NewJobMetrics 根据任务的最终结果计算指标
+func NewJobMetrics(info JobInfo, result *BatchResult) JobMetrics {
+	metrics := JobMetrics{
+		DurationMs:     result.Duration,
+		TotalTasks:     info.TotalTasks,
+		SuccessTasks:   info.SuccessTasks,
+		FailedTasks:    info.FailedTasks,
+		CancelledTasks: info.CancelledTasks,
+		ThroughputMBps: result.Throughput,
+	}
+	if result.Duration > 0 {
+		metrics.Throughput = round2(float64(info.CompletedTasks) * 1000 / float64(result.Duration))
+	}
+	if info.CompletedTasks > 0 {
+		metrics.FailureRate = math.Round(float64(info.FailedTasks)*10000/float64(info.CompletedTasks)) / 10000
+	}
+	return metrics
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Pushgateway 任务结束时将指标推送到 Prometheus Pushgateway，进程退出前的批次也能被采集
+//
+// 每种任务类型一个分组（job=<Job>、job_type=<类型>，配置了 Instance 时另加 instance），
+// 以 PUT 覆盖，分组中保留该类型最近结束的任务的指标
+type Pushgateway struct {
+	URL      string        // 如 http://pushgateway:9091
+	Job      string        // 分组的 job 标签，默认 concurrency_web_app
+	Instance string        // 分组的 instance 标签，为空时不加
+	Timeout  time.Duration // 单次推送的超时，默认3秒
+	Client   *http.Client  // 为nil时使用 http.DefaultClient
+}
+
+// Push 推送任务的指标
+func (p *Pushgateway) Push(ctx context.Context, info JobInfo, metrics JobMetrics) error {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	job := p.Job
+	if job == "" {
+		job = "concurrency_web_app"
+	}
+	target := strings.TrimRight(p.URL, "/") + "/metrics/job/" + url.PathEscape(job) + "/job_type/" + url.PathEscape(info.Type)
+	if p.Instance != "" {
+		target += "/instance/" + url.PathEscape(p.Instance)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(metrics.exposition(info)))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Pushgateway 返回 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// exposition 以 Prometheus 文本格式输出指标，分组之外的标签为任务状态
+func (m JobMetrics) exposition(info JobInfo) []byte {
+	var buf bytes.Buffer
+	labels := fmt.Sprintf(`{status=%q}`, info.Status)
+	gauge := func(name, help string, value float64) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n%s%s %g\n", name, help, name, name, labels, value)
+	}
+	gauge("batch_job_duration_seconds", "最近结束的批次的耗时", float64(m.DurationMs)/1000)
+	gauge("batch_job_throughput_tasks_per_second", "最近结束的批次每秒结束的任务数", m.Throughput)
+	gauge("batch_job_failure_rate", "最近结束的批次失败任务的比例", m.FailureRate)
+	gauge("batch_job_tasks", "最近结束的批次的任务数", float64(m.TotalTasks))
+	gauge("batch_job_failed_tasks", "最近结束的批次失败的任务数", float64(m.FailedTasks))
+	if m.Resources != nil {
+		gauge("batch_job_cpu_seconds", "最近结束的批次消耗的 CPU 时间（进程级）", m.Resources.CPUTotalMs/1000)
+		gauge("batch_job_alloc_bytes", "最近结束的批次在堆上分配的字节数（进程级）", float64(m.Resources.AllocBytes))
+	}
+	if info.EndTime != nil {
+		gauge("batch_job_last_completion_timestamp_seconds", "最近结束的批次的结束时间", float64(info.EndTime.Unix()))
+	}
+	return buf.Bytes()
+}
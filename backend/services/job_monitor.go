@@ -0,0 +1,129 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 任务生命周期事件的类型
+const (
+	LifecycleQueued   = "queued"    // 任务已登记，等待执行（包括在互斥组中排队）
+	LifecycleStarted  = "started"   // 第一个子任务开始执行
+	LifecycleTaskDone = "task_done" // 一个子任务结束，数据为任务结果
+	LifecycleFinished = "finished"  // 任务结束（包括取消）
+)
+
+// LifecycleEvent 任务生命周期事件
+type LifecycleEvent struct {
+	Type    string      `json:"type"`
+	JobID   string      `json:"job_id"`
+	JobType string      `json:"job_type"`
+	Owner   string      `json:"owner"`
+	Time    time.Time   `json:"time"`
+	Job     *JobInfo    `json:"job,omitempty"`    // queued、started、finished 时的任务信息
+	Result  *TaskResult `json:"result,omitempty"` // task_done 的任务结果
+}
+
+// JobMonitor 向所有订阅者广播任务生命周期事件，供监控面板实时展示
+// 订阅者的缓冲已满时丢弃事件并计数，不阻塞任务执行
+type JobMonitor struct {
+	mu   sync.RWMutex
+	subs map[*MonitorSubscription]struct{}
+}
+
+// MonitorSubscription 一个订阅者
+type MonitorSubscription struct {
+	events  chan LifecycleEvent
+	dropped atomic.Int64
+}
+
+// monitorBuffer 每个订阅者缓冲的事件数
+const monitorBuffer = 256
+
+// Events 返回订阅者的事件通道，取消订阅后关闭
+func (s *MonitorSubscription) Events() <-chan LifecycleEvent {
+	return s.events
+}
+
+// Dropped 返回并清零因缓冲已满丢弃的事件数
+func (s *MonitorSubscription) Dropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+// Subscribe 订阅全部任务的生命周期事件
+func (m *JobMonitor) Subscribe() *MonitorSubscription {
+	sub := &MonitorSubscription{events: make(chan LifecycleEvent, monitorBuffer)}
+	m.mu.Lock()
+	if m.subs == nil {
+		m.subs = make(map[*MonitorSubscription]struct{})
+	}
+	m.subs[sub] = struct{}{}
+	m.mu.Unlock()
+	return sub
+}
+
+// Unsubscribe 取消订阅并关闭事件通道
+func (m *JobMonitor) Unsubscribe(sub *MonitorSubscription) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.subs[sub]; ok {
+		delete(m.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Subscribers 返回当前的订阅者数
+func (m *JobMonitor) Subscribers() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.subs)
+}
+
+// publish 向所有订阅者发送事件
+func (m *JobMonitor) publish(event LifecycleEvent) {
+	if m == nil {
+		return
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for sub := range m.subs {
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// publishJob 发送带任务信息的事件
+func (m *JobMonitor) publishJob(eventType string, info JobInfo) {
+	if m == nil {
+		return
+	}
+	m.publish(LifecycleEvent{
+		Type:    eventType,
+		JobID:   info.ID,
+		JobType: info.Type,
+		Owner:   info.Owner,
+		Time:    time.Now(),
+		Job:     &info,
+	})
+}
+
+// markStarted 第一个子任务开始执行时发送 started 事件
+// 持锁发送，其他子任务的 task_done 不会先于 started 到达订阅者
+func (j *Job) markStarted() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.started {
+		return
+	}
+	j.started = true
+	j.transition(JobStatusRunning, ActorSystem, "")
+	j.usageStart = takeResourceSnapshot()
+	if j.resources != nil {
+		j.resources.begin(j)
+	}
+	j.monitor.publishJob(LifecycleStarted, j.info)
+}
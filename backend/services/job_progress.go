@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ProgressDelta 两次刷新之间新增的任务数和任务结果数
+type ProgressDelta struct {
+	Total     int // 流式提交时新增的任务数
+	Completed int
+	Success   int
+	Failed    int
+	Cancelled int
+}
+
+// ProgressStore 任务进度持久化，为nil时只在内存中记录
+type ProgressStore interface {
+	Create(info JobInfo) error
+	Flush(jobID string, delta ProgressDelta) error
+	Complete(info JobInfo, metrics JobMetrics) error
+}
+
+// progressPercent 计算完成百分比，保留两位小数
+func progressPercent(completed, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(completed)*10000/float64(total)) / 100
+}
+
+// DBProgressStore 将任务进度写入 BatchJobResult 表
+type DBProgressStore struct {
+	DB     *gorm.DB
+	ReadDB *gorm.DB // 只读副本，为nil时查询走主库
+}
+
+// Create 登记新任务
+func (s *DBProgressStore) Create(info JobInfo) error {
+	return s.DB.Create(&models.BatchJobResult{
+		JobID:          info.ID,
+		JobType:        info.Type,
+		Owner:          info.Owner,
+		TotalTasks:     info.TotalTasks,
+		RemainingTasks: info.TotalTasks,
+		Progress:       progressPercent(0, info.TotalTasks),
+		Status:         info.Status,
+		StartTime:      info.StartTime,
+		CodeVersion:    currentCodeVersion(),
+		Instance:       currentInstance(),
+	}).Error
+}
+
+// Flush 累加新增的任务结果，单条 UPDATE 语句保证计数与进度原子地一起更新
+func (s *DBProgressStore) Flush(jobID string, delta ProgressDelta) error {
+	return s.DB.Model(&models.BatchJobResult{}).
+		Where("job_id = ?", jobID).
+		Updates(map[string]interface{}{
+			"total_tasks":     gorm.Expr("total_tasks + ?", delta.Total),
+			"completed_tasks": gorm.Expr("completed_tasks + ?", delta.Completed),
+			"success_tasks":   gorm.Expr("success_tasks + ?", delta.Success),
+			"failed_tasks":    gorm.Expr("failed_tasks + ?", delta.Failed),
+			"cancelled_tasks": gorm.Expr("cancelled_tasks + ?", delta.Cancelled),
+			"remaining_tasks": gorm.Expr("(total_tasks + ?) - (completed_tasks + ?)", delta.Total, delta.Completed),
+			"progress": gorm.Expr(
+				"CASE WHEN total_tasks + ? = 0 THEN 100 ELSE ROUND((completed_tasks + ?) * 100.0 / (total_tasks + ?), 2) END",
+				delta.Total, delta.Completed, delta.Total,
+			),
+		}).Error
+}
+
+// Complete 写入任务的最终结果，指标以 JSON 保存在 metrics 列
+func (s *DBProgressStore) Complete(info JobInfo, metrics JobMetrics) error {
+	annotations, err := json.Marshal(metrics)
+	if err != nil {
+		return err
+	}
+	updates := map[string]interface{}{
+		"total_tasks":     info.TotalTasks,
+		"completed_tasks": info.CompletedTasks,
+		"success_tasks":   info.SuccessTasks,
+		"failed_tasks":    info.FailedTasks,
+		"cancelled_tasks": info.CancelledTasks,
+		"remaining_tasks": info.RemainingTasks,
+		"progress":        info.Progress,
+		"status":          info.Status,
+		"duration":        metrics.DurationMs,
+		"end_time":        info.EndTime,
+		"metrics":         string(annotations),
+		"template_id":     info.TemplateID,
+		"expires_at":      info.ExpiresAt,
+	}
+	if run := info.Run; run != nil {
+		config, err := json.Marshal(run.Config)
+		if err != nil {
+			return err
+		}
+		updates["seed"] = run.Seed
+		updates["config"] = string(config)
+		updates["code_version"] = run.CodeVersion
+	}
+	return s.DB.Model(&models.BatchJobResult{}).
+		Where("job_id = ?", info.ID).
+		Updates(updates).Error
+}
+
+// reportProgress 记录一个已收集的任务结果
+func reportProgress(ctx context.Context, result TaskResult) {
+	if job := JobFromContext(ctx); job != nil {
+		job.record(result)
+	}
+}
+
+// addTasks 流式提交任务时增加任务总数，随下一次结果一起刷新
+func (j *Job) addTasks(n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.TotalTasks += n
+	j.info.RemainingTasks += n
+	j.info.Progress = progressPercent(j.info.CompletedTasks, j.info.TotalTasks)
+	j.pending.Total += n
+}
+
+// record 更新内存中的进度，达到刷新条件时写入持久化存储
+func (j *Job) record(result TaskResult) {
+	// 流水线等不经 trackInflight 登记的批次，以第一个执行过的任务结果标志任务已开始
+	switch result.Status {
+	case TaskStatusNotStarted, TaskStatusCancelled, TaskStatusSkipped:
+	default:
+		j.markStarted()
+	}
+	j.mu.Lock()
+	j.info.CompletedTasks++
+	j.pending.Completed++
+	switch result.Status {
+	case TaskStatusSuccess:
+		j.info.SuccessTasks++
+		j.pending.Success++
+	case TaskStatusCancelled:
+		j.info.CancelledTasks++
+		j.pending.Cancelled++
+	default:
+		j.info.FailedTasks++
+		j.pending.Failed++
+	}
+	j.info.RemainingTasks = j.info.TotalTasks - j.info.CompletedTasks
+	j.info.Progress = progressPercent(j.info.CompletedTasks, j.info.TotalTasks)
+	now := time.Now()
+	j.recent = append(trimRecent(j.recent, now), now)
+
+	var delta ProgressDelta
+	var checkpoint []TaskResult
+	var transitions []models.JobTransition
+	landed := result
+	if j.checkpoint != nil {
+		landed.ID = j.checkpoint.taskID(result.ID)
+		j.checkpoint.add(landed)
+	}
+	if !j.discardResults {
+		j.landed = append(j.landed, landed)
+		j.landedIDs = append(j.landedIDs, landed.ID)
+	}
+	flush := j.store != nil &&
+		(j.pending.Completed >= j.flushEvery || time.Since(j.lastFlush) >= j.flushInterval)
+	if flush {
+		delta = j.pending
+		j.pending = ProgressDelta{}
+		j.lastFlush = time.Now()
+		// 已结束的任务与进度一起保存，服务意外退出时最多重新执行最后一次刷新后结束的任务
+		if j.checkpoint != nil {
+			checkpoint = j.checkpoint.pending
+			j.checkpoint.pending = nil
+		}
+		transitions = j.unsavedTransitions()
+	}
+	failFast := j.failFast && (result.Status == TaskStatusFailed || result.Status == TaskStatusTimeout)
+	j.mu.Unlock()
+
+	j.events.append(JobEventResult, result)
+	if j.monitor != nil {
+		j.monitor.publish(LifecycleEvent{
+			Type:    LifecycleTaskDone,
+			JobID:   j.info.ID,
+			JobType: j.info.Type,
+			Owner:   j.info.Owner,
+			Time:    time.Now(),
+			Result:  &result,
+		})
+	}
+
+	// 快速失败：首个失败的结果到达后取消其余任务
+	if failFast {
+		j.cancelWith(CancelModeFailFast, "")
+	}
+
+	if flush {
+		if err := j.store.Flush(j.info.ID, delta); err != nil {
+			log.Printf("刷新任务 %s 进度失败: %v", j.info.ID, err)
+		}
+		if len(checkpoint) > 0 {
+			if err := j.checkpoint.store.saveResults(j.info.ID, checkpoint); err != nil {
+				log.Printf("保存任务 %s 的检查点失败: %v", j.info.ID, err)
+			}
+		}
+		if err := j.saveTransitionList(transitions); err != nil {
+			log.Printf("保存任务 %s 的状态变更失败: %v", j.info.ID, err)
+		}
+	}
+}
+
+// throughputWindow 计算当前吞吐量的时间窗口
+const throughputWindow = 10 * time.Second
+
+// JobProgress 任务的实时进度，供前端渲染进度条
+type JobProgress struct {
+	JobID          string   `json:"job_id"`
+	Status         string   `json:"status"`
+	Finished       bool     `json:"finished"`
+	TotalTasks     int      `json:"total_tasks"`
+	CompletedTasks int      `json:"completed_tasks"`
+	RemainingTasks int      `json:"remaining_tasks"`
+	Progress       float64  `json:"progress"`    // 完成百分比
+	Throughput     float64  `json:"throughput"`  // 最近10秒内每秒结束的任务数
+	ETASeconds     *float64 `json:"eta_seconds"` // 按当前吞吐量估算的剩余秒数，尚无任务结束时为 null
+	ElapsedMs      int64    `json:"elapsed_ms"`
+}
+
+// trimRecent 丢弃时间窗口之外的结束时间
+func trimRecent(recent []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(recent) && now.Sub(recent[i]) > throughputWindow {
+		i++
+	}
+	return recent[i:]
+}
+
+// Progress 返回任务的实时进度
+// 吞吐量按最近10秒内结束的任务数计算，任务开始不足10秒时按已执行时间计算
+func (j *Job) Progress() JobProgress {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	j.recent = trimRecent(j.recent, now)
+	info := j.info
+
+	progress := JobProgress{
+		JobID:          info.ID,
+		Status:         info.Status,
+		Finished:       info.EndTime != nil,
+		TotalTasks:     info.TotalTasks,
+		CompletedTasks: info.CompletedTasks,
+		RemainingTasks: info.RemainingTasks,
+		Progress:       info.Progress,
+		ElapsedMs:      now.Sub(info.StartTime).Milliseconds(),
+	}
+	if info.EndTime != nil {
+		progress.ElapsedMs = info.EndTime.Sub(info.StartTime).Milliseconds()
+		eta := 0.0
+		progress.ETASeconds = &eta
+		return progress
+	}
+
+	window := throughputWindow
+	if elapsed := now.Sub(info.StartTime); elapsed < window {
+		window = elapsed
+	}
+	if len(j.recent) > 0 && window > 0 {
+		progress.Throughput = round2(float64(len(j.recent)) / window.Seconds())
+	}
+	if progress.Throughput > 0 {
+		eta := round2(float64(info.RemainingTasks) / progress.Throughput)
+		progress.ETASeconds = &eta
+	}
+	return progress
+}
+
+// 任务记录的排序字段
+const (
+	HistorySortStartTime = "start_time"
+	HistorySortDuration  = "duration"
+	HistorySortFailed    = "failed_tasks"
+)
+
+// HistoryStatusFailed 按状态筛选任务记录时表示有失败任务的已结束任务（completed、cancelled 或 skipped）
+const HistoryStatusFailed = "failed"
+
+// HistoryQuery 任务记录的查询条件
+type HistoryQuery struct {
+	Owner    string     // 为空时查询全部用户
+	Type     string     // 为空时不限类型
+	Status   string     // queued、running、completed、cancelled、skipped、interrupted 或 HistoryStatusFailed，为空时不限
+	From, To *time.Time // 开始时间在 [From, To) 内，为nil时不限
+	Sort     string     // 排序字段，默认 HistorySortStartTime
+	Asc      bool       // 升序，默认倒序
+	Page     int
+	PageSize int
+}
+
+// History 分页查询已登记的任务，同一排序值按开始时间倒序
+func (s *DBProgressStore) History(q HistoryQuery) ([]models.BatchJobResult, int64, error) {
+	db := readerDB(s.DB, s.ReadDB).Model(&models.BatchJobResult{})
+	if q.Owner != "" {
+		db = db.Where("owner = ?", q.Owner)
+	}
+	if q.Type != "" {
+		db = db.Where("job_type = ?", q.Type)
+	}
+	switch q.Status {
+	case "":
+	case HistoryStatusFailed:
+		db = db.Where("status IN ? AND failed_tasks > 0", []string{JobStatusCompleted, JobStatusCancelled, JobStatusSkipped})
+	default:
+		db = db.Where("status = ?", q.Status)
+	}
+	if q.From != nil {
+		db = db.Where("start_time >= ?", *q.From)
+	}
+	if q.To != nil {
+		db = db.Where("start_time < ?", *q.To)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 排序字段拼入 SQL，只接受已定义的字段
+	column := HistorySortStartTime
+	if q.Sort == HistorySortDuration || q.Sort == HistorySortFailed {
+		column = q.Sort
+	}
+	direction := " DESC"
+	if q.Asc {
+		direction = " ASC"
+	}
+	var jobs []models.BatchJobResult
+	err := db.Order(column + direction).
+		Order("start_time DESC").
+		Offset((q.Page - 1) * q.PageSize).
+		Limit(q.PageSize).
+		Find(&jobs).Error
+	return jobs, total, err
+}
+
+// Get 查询用户的一个任务记录，不存在或属于其他用户时返回 ErrJobNotFound
+func (s *DBProgressStore) Get(jobID, owner string) (*models.BatchJobResult, error) {
+	var record models.BatchJobResult
+	err := readerDB(s.DB, s.ReadDB).Where("job_id = ? AND owner = ?", jobID, owner).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// PreviousRun 查询用户在 before 之前开始的同类型任务中最近一个已完成的，没有时返回 nil
+func (s *DBProgressStore) PreviousRun(owner, jobType string, before time.Time) (*models.BatchJobResult, error) {
+	var jobs []models.BatchJobResult
+	err := readerDB(s.DB, s.ReadDB).
+		Where("owner = ? AND job_type = ? AND status = ? AND start_time < ?", owner, jobType, JobStatusCompleted, before).
+		Order("start_time DESC").
+		Limit(1).
+		Find(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// 优先级类别，全局队列中高优先级的任务先执行，同类按进入顺序
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// priorityClasses 按执行顺序排列的优先级类别
+var priorityClasses = []string{PriorityHigh, PriorityNormal, PriorityLow}
+
+// ErrInvalidPriority 不支持的优先级类别
+var ErrInvalidPriority = errors.New("不支持的优先级，可选 high、normal、low")
+
+// priorityRank 优先级类别在 priorityClasses 中的位置，空字符串视为 normal
+func priorityRank(priority string) (int, error) {
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	for i, class := range priorityClasses {
+		if class == priority {
+			return i, nil
+		}
+	}
+	return 0, ErrInvalidPriority
+}
+
+// jobQueue 全局任务队列：执行中的任务数达到 JobManager.MaxRunning 时，新任务按优先级类别排队
+type jobQueue struct {
+	running  int
+	waiting  [][]*Job // 按 priorityClasses 的顺序，每类按进入顺序
+	reserved int      // 已接收（Admit）、尚未排队或开始执行的批次预留的名额
+}
+
+// pop 取出优先级最高、最早进入的排队任务
+func (q *jobQueue) pop() *Job {
+	for i, jobs := range q.waiting {
+		if len(jobs) > 0 {
+			q.waiting[i] = jobs[1:]
+			return jobs[0]
+		}
+	}
+	return nil
+}
+
+// remove 从队列中移除任务，返回任务是否在排队
+func (q *jobQueue) remove(job *Job) bool {
+	for i, jobs := range q.waiting {
+		for j, queued := range jobs {
+			if queued == job {
+				q.waiting[i] = append(jobs[:j], jobs[j+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requeueLow 将最早排队的低优先级任务移到队尾，没有排队的低优先级任务时返回nil
+func (q *jobQueue) requeueLow() *Job {
+	low := len(priorityClasses) - 1
+	if len(q.waiting) == 0 || len(q.waiting[low]) == 0 {
+		return nil
+	}
+	victim := q.waiting[low][0]
+	q.waiting[low] = append(q.waiting[low][1:], victim)
+	return victim
+}
+
+// ErrQueueFull 排队的任务数已达到上限
+var ErrQueueFull = errors.New("排队的任务过多，请稍后重试")
+
+// Preemption 一次抢占，作为任务事件 JobEventPreemption 的数据
+type Preemption struct {
+	JobID       string    `json:"job_id"`       // 抢占位置的高优先级任务
+	PreemptedID string    `json:"preempted_id"` // 被移到队尾重新排队的低优先级任务
+	Time        time.Time `json:"time"`
+}
+
+// pending 排队中的任务数（全局队列和各互斥组），含已接收、尚未排队的批次预留的名额，调用方需持有 m.mu
+func (m *JobManager) pending() int {
+	n := m.queue.reserved
+	for _, jobs := range m.queue.waiting {
+		n += len(jobs)
+	}
+	for _, g := range m.groups {
+		n += len(g.queue)
+	}
+	return n
+}
+
+// Admission 接收批次时预留的排队名额，预留期间计入排队数，避免同时提交的批次都通过检查后一起排队超出上限。
+// 交给任务（Claim）后在任务排队、开始执行或结束时释放；未交给任务（如请求参数错误）时由调用方 Release
+type Admission struct {
+	m        *JobManager
+	claimed  bool // 已交给任务，由 m.mu 保护
+	released bool // 由 m.mu 保护
+}
+
+// Release 释放未交给任务的名额，a 为nil或已交给任务时不做处理，可重复调用
+func (a *Admission) Release() {
+	if a == nil {
+		return
+	}
+	a.m.mu.Lock()
+	defer a.m.mu.Unlock()
+	if !a.claimed {
+		a.free()
+	}
+}
+
+// free 释放名额，调用方需持有 m.mu
+func (a *Admission) free() {
+	if !a.released {
+		a.released = true
+		a.m.queue.reserved--
+	}
+}
+
+// Admit 检查能否接收优先级为 priority 的新批次：排队中的任务（全局队列和各互斥组，含预留的名额）达到 MaxQueued 时返回 ErrQueueFull，
+// MaxQueued 为0时不限，返回nil。例外是 high 批次：全局队列中有排队的 low 任务时照常接收，进入全局队列时抢占其位置（见 EnterQueue）。
+// 接收时在同一把锁内预留一个名额，同时提交的批次不会都通过检查；已经接收的批次照常排队
+func (m *JobManager) Admit(priority string) (*Admission, error) {
+	if m.MaxQueued <= 0 {
+		return nil, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	low := len(priorityClasses) - 1
+	if m.pending() >= m.MaxQueued && (priority != PriorityHigh || len(m.queue.waiting) == 0 || len(m.queue.waiting[low]) == 0) {
+		return nil, ErrQueueFull
+	}
+	m.queue.reserved++
+	return &Admission{m: m}, nil
+}
+
+// Claim 将 Admit 预留的名额交给任务，任务进入排队、开始执行或结束时释放，a 为nil时不做处理
+func (m *JobManager) Claim(job *Job, a *Admission) {
+	if a == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !a.released {
+		a.claimed = true
+		job.admission = a
+	}
+}
+
+// releaseAdmission 释放任务持有的预留名额，调用方需持有 m.mu
+func (m *JobManager) releaseAdmission(job *Job) {
+	if job.admission != nil {
+		job.admission.free()
+		job.admission = nil
+	}
+}
+
+// QueuedJob 全局队列中排队的任务
+type QueuedJob struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Owner    string `json:"owner"`
+	Priority string `json:"priority"`
+}
+
+// QueueInfo 全局队列的当前状态
+type QueueInfo struct {
+	MaxRunning int         `json:"max_running"` // 0表示不限
+	MaxQueued  int         `json:"max_queued"`  // 0表示不限
+	Running    int         `json:"running"`
+	Pending    int         `json:"pending"` // 排队中的任务数，含在互斥组中排队的任务
+	Queued     []QueuedJob `json:"queued"`  // 全局队列中排队的任务，按执行顺序
+}
+
+// SetPriority 设置任务在全局队列中的优先级类别，应在进入队列前调用
+func (j *Job) SetPriority(priority string) error {
+	if _, err := priorityRank(priority); err != nil {
+		return err
+	}
+	if priority == "" {
+		priority = PriorityNormal
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Priority = priority
+	return nil
+}
+
+// EnterQueue 在全局队列中取得执行名额后返回，任务结束（Finish）时自动释放名额，唤醒优先级最高的排队任务。
+// 执行中的任务数未达到 MaxRunning（或 MaxRunning 为0）时立即返回。
+// 排队期间任务状态为 queued；排队中被取消或 ctx 结束时离开队列并返回错误，处理方式同 EnterGroup。
+// 排队数已达到 MaxQueued 时 high 任务抢占最早排队的 low 任务的位置，后者移到队尾重新排队，抢占记入双方的事件
+func (m *JobManager) EnterQueue(ctx context.Context, job *Job) error {
+	m.mu.Lock()
+	if m.queue.waiting == nil {
+		m.queue.waiting = make([][]*Job, len(priorityClasses))
+	}
+	// 预留的名额转为排队或执行，与判断排队数在同一把锁内
+	m.releaseAdmission(job)
+	full := m.MaxQueued > 0 && m.pending() >= m.MaxQueued
+	var preempted *Job
+	job.mu.Lock()
+	rank, _ := priorityRank(job.info.Priority)
+	job.queueReady = make(chan struct{})
+	if m.MaxRunning <= 0 || m.queue.running < m.MaxRunning {
+		m.queue.running++
+		job.queueSlot = true
+		close(job.queueReady)
+	} else {
+		if full && job.info.Priority == PriorityHigh {
+			preempted = m.queue.requeueLow()
+		}
+		m.queue.waiting[rank] = append(m.queue.waiting[rank], job)
+		job.setStatus(JobStatusQueued, ActorSystem, "同时执行的批次已达上限，按优先级排队")
+	}
+	ready := job.queueReady
+	job.mu.Unlock()
+	m.mu.Unlock()
+
+	if preempted != nil {
+		p := Preemption{JobID: job.ID(), PreemptedID: preempted.ID(), Time: time.Now()}
+		log.Printf("排队已满，高优先级任务 %s 抢占 %s 的位置，后者重新排队", p.JobID, p.PreemptedID)
+		job.events.append(JobEventPreemption, p)
+		preempted.events.append(JobEventPreemption, p)
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-job.stopCh:
+	case <-ctx.Done():
+	}
+
+	// 排队中被取消：离开队列；同时被唤醒时已取得名额，由 Finish 释放
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case <-ready:
+		return nil
+	default:
+	}
+	m.queue.remove(job)
+	job.mu.Lock()
+	job.info.Status = JobStatusRunning
+	job.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return context.Canceled
+}
+
+// leaveQueue 任务结束时释放执行名额，唤醒排队的任务
+func (m *JobManager) leaveQueue(job *Job) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.releaseAdmission(job)
+	job.mu.Lock()
+	held := job.queueSlot
+	job.queueSlot = false
+	job.mu.Unlock()
+	if !held {
+		return
+	}
+	m.queue.running--
+	for m.MaxRunning <= 0 || m.queue.running < m.MaxRunning {
+		next := m.queue.pop()
+		if next == nil {
+			return
+		}
+		m.queue.running++
+		next.mu.Lock()
+		next.queueSlot = true
+		if next.info.Status == JobStatusQueued {
+			next.info.Status = JobStatusRunning
+		}
+		close(next.queueReady)
+		next.mu.Unlock()
+	}
+}
+
+// Queue 返回全局队列的状态
+func (m *JobManager) Queue() QueueInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	info := QueueInfo{MaxRunning: m.MaxRunning, MaxQueued: m.MaxQueued, Running: m.queue.running, Pending: m.pending(), Queued: []QueuedJob{}}
+	for _, jobs := range m.queue.waiting {
+		for _, job := range jobs {
+			job.mu.RLock()
+			info.Queued = append(info.Queued, QueuedJob{ID: job.info.ID, Type: job.info.Type, Owner: job.info.Owner, Priority: job.info.Priority})
+			job.mu.RUnlock()
+		}
+	}
+	return info
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"regexp"
+	"sort"
+	"time"
+
+	"concurrency-web-app/backend/models"
+)
+
+// latencyBounds 耗时直方图各区间的上限（毫秒），最后一个区间不设上限
+var latencyBounds = []int64{10, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// maxReportErrors 报告中列出的错误分组数
+const maxReportErrors = 20
+
+// JobReport 任务的性能报告
+type JobReport struct {
+	Job         JobInfo           `json:"job"`
+	Summary     ReportSummary     `json:"summary"`
+	Latency     LatencyStats      `json:"latency"`
+	Concurrency []int             `json:"concurrency"` // 任务开始后每秒的最大并发数
+	Errors      []ErrorGroup      `json:"errors"`
+	Previous    *ReportComparison `json:"previous,omitempty"`
+	Resources   *ResourceUsage    `json:"resources,omitempty"` // 批次消耗的 CPU 时间和内存分配
+	GeneratedAt time.Time         `json:"generated_at"`
+}
+
+// ReportSummary 任务的总体指标
+type ReportSummary struct {
+	TotalTasks      int     `json:"total_tasks"`
+	SuccessTasks    int     `json:"success_tasks"`
+	FailedTasks     int     `json:"failed_tasks"`
+	TimeoutTasks    int     `json:"timeout_tasks"`
+	CancelledTasks  int     `json:"cancelled_tasks"`
+	NotStartedTasks int     `json:"not_started_tasks"`
+	SuccessRate     float64 `json:"success_rate"` // 百分比
+	Duration        int64   `json:"duration"`     // 毫秒
+	TasksPerSecond  float64 `json:"tasks_per_second"`
+	PeakConcurrency int     `json:"peak_concurrency"`
+}
+
+// LatencyStats 已执行任务的耗时分布，不含未开始和跳过的任务
+type LatencyStats struct {
+	Count     int             `json:"count"` // 已执行的任务数
+	Histogram []LatencyBucket `json:"histogram"`
+	P50       int64           `json:"p50"`
+	P90       int64           `json:"p90"`
+	P99       int64           `json:"p99"`
+	Max       int64           `json:"max"`
+}
+
+// LatencyBucket 耗时直方图的一个区间，UpperBound 为0表示不设上限
+type LatencyBucket struct {
+	UpperBound int64 `json:"upper_bound"` // 毫秒
+	Count      int   `json:"count"`
+}
+
+// ErrorGroup 按状态、错误分类和错误信息归并的失败任务，信息中的数字归一为 N
+type ErrorGroup struct {
+	Status  string `json:"status"`
+	Class   string `json:"class,omitempty"`
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+	Example int    `json:"example_id"` // 其中一个任务的序号
+}
+
+// ReportComparison 与同一用户同类型的上一个已完成任务的对比
+type ReportComparison struct {
+	JobID           string    `json:"job_id"`
+	StartTime       time.Time `json:"start_time"`
+	TotalTasks      int       `json:"total_tasks"`
+	SuccessRate     float64   `json:"success_rate"`
+	Duration        int64     `json:"duration"`
+	SuccessRateDiff float64   `json:"success_rate_diff"` // 本次减上次，百分点
+	DurationChange  float64   `json:"duration_change"`   // 耗时变化的百分比，上次耗时为0时为0
+}
+
+// reportDigits 归并错误信息时替换的数字
+var reportDigits = regexp.MustCompile(`\d+`)
+
+// BuildJobReport 由任务信息、最终结果和并发时间线生成报告，previous 为用于对比的上一个任务（可为nil）
+// NDJSON 流式返回的批次不保留任务明细，报告中没有耗时分布和错误分组
+func BuildJobReport(info JobInfo, result *BatchResult, timeline []int, previous *models.BatchJobResult) *JobReport {
+	report := &JobReport{
+		Job:         info,
+		Concurrency: timeline,
+		GeneratedAt: time.Now(),
+		Summary: ReportSummary{
+			TotalTasks:      result.TotalTasks,
+			SuccessTasks:    result.SuccessTasks,
+			FailedTasks:     result.FailedTasks,
+			TimeoutTasks:    result.TimeoutTasks,
+			CancelledTasks:  result.CancelledTasks,
+			NotStartedTasks: result.NotStartedTasks,
+			SuccessRate:     percent(result.SuccessTasks, result.TotalTasks),
+			Duration:        result.Duration,
+		},
+	}
+	if result.Duration > 0 {
+		report.Summary.TasksPerSecond = float64(result.TotalTasks-result.NotStartedTasks) * 1000 / float64(result.Duration)
+	}
+	for _, n := range timeline {
+		report.Summary.PeakConcurrency = max(report.Summary.PeakConcurrency, n)
+	}
+
+	if info.Metrics != nil {
+		report.Resources = info.Metrics.Resources
+	}
+
+	report.Latency = latencyStats(result.Results)
+	report.Errors = errorGroups(result.Results)
+
+	if previous != nil {
+		rate := percent(previous.SuccessTasks, previous.TotalTasks)
+		report.Previous = &ReportComparison{
+			JobID:           previous.JobID,
+			StartTime:       previous.StartTime,
+			TotalTasks:      previous.TotalTasks,
+			SuccessRate:     rate,
+			Duration:        previous.Duration,
+			SuccessRateDiff: report.Summary.SuccessRate - rate,
+		}
+		if previous.Duration > 0 {
+			report.Previous.DurationChange = float64(result.Duration-previous.Duration) * 100 / float64(previous.Duration)
+		}
+	}
+	return report
+}
+
+// latencyStats 统计已执行任务的耗时分布
+func latencyStats(results []TaskResult) LatencyStats {
+	var durations []int64
+	for _, r := range results {
+		if r.Status != TaskStatusNotStarted && r.Status != TaskStatusSkipped {
+			durations = append(durations, r.Duration)
+		}
+	}
+	sort.Slice(durations, func(a, b int) bool { return durations[a] < durations[b] })
+
+	stats := LatencyStats{Histogram: make([]LatencyBucket, len(latencyBounds)+1)}
+	for i, bound := range latencyBounds {
+		stats.Histogram[i].UpperBound = bound
+	}
+	for _, d := range durations {
+		i := sort.Search(len(latencyBounds), func(i int) bool { return d <= latencyBounds[i] })
+		stats.Histogram[i].Count++
+	}
+	stats.Count = len(durations)
+	if n := len(durations); n > 0 {
+		stats.P50 = percentile(durations, 50)
+		stats.P90 = percentile(durations, 90)
+		stats.P99 = percentile(durations, 99)
+		stats.Max = durations[n-1]
+	}
+	return stats
+}
+
+// percentile 按最近秩法返回已排序数据的第 p 百分位数
+func percentile(sorted []int64, p int) int64 {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// errorGroups 归并失败任务的错误，按数量倒序，最多 maxReportErrors 组
+func errorGroups(results []TaskResult) []ErrorGroup {
+	type key struct{ status, class, message string }
+	index := make(map[key]int)
+	var groups []ErrorGroup
+	for _, r := range results {
+		if r.Success {
+			continue
+		}
+		k := key{r.Status, r.ErrorClass, reportDigits.ReplaceAllString(r.Error, "N")}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, ErrorGroup{Status: k.status, Class: k.class, Message: k.message, Example: r.ID})
+		}
+		groups[i].Count++
+	}
+	sort.SliceStable(groups, func(a, b int) bool { return groups[a].Count > groups[b].Count })
+	if len(groups) > maxReportErrors {
+		groups = groups[:maxReportErrors]
+	}
+	return groups
+}
+
+// percent 返回 n 占 total 的百分比，total 为0时返回0
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CPU 时间的来源
+const (
+	CPUSourceRusage  = "rusage"  // 操作系统记录的进程 CPU 时间
+	CPUSourceRuntime = "runtime" // 不支持 getrusage 的平台上，Go 运行时估算的 CPU 时间
+)
+
+// ResourceUsage 批次从第一个子任务开始到结束消耗的 CPU 时间和内存分配
+//
+// 进程级的数据：同时执行的其他批次（Overlapped 为 true）、HTTP 服务本身的消耗都计入在内，
+// 只适合在单独执行时对比不同的并发模式和并发数
+type ResourceUsage struct {
+	CPUUserMs    float64 `json:"cpu_user_ms"`
+	CPUSystemMs  float64 `json:"cpu_system_ms"`
+	CPUTotalMs   float64 `json:"cpu_total_ms"`
+	CPUSource    string  `json:"cpu_source"`
+	CPUPerTaskMs float64 `json:"cpu_per_task_ms"` // 按已执行的任务数平均
+	CPUUtil      float64 `json:"cpu_utilization"` // CPU 时间 / 墙钟时间，1 表示平均占满一个核
+	AllocBytes   uint64  `json:"alloc_bytes"`     // 堆上分配的字节数（含已回收的）
+	AllocObjects uint64  `json:"alloc_objects"`
+	GCCycles     uint64  `json:"gc_cycles"`
+	Overlapped   bool    `json:"overlapped"` // 执行期间有其他批次同时执行
+	// Workers 每个工作槽位上执行任务的线程 CPU 时间，批次启用 worker_usage 且平台支持时记录
+	Workers []WorkerUsage `json:"workers,omitempty"`
+}
+
+// WorkerUsage 一个工作槽位上执行的任务数和线程 CPU 时间
+// 只计入执行任务的协程本身，任务派生的协程（如 HTTP 连接的读写）不在其中
+type WorkerUsage struct {
+	Slot  int     `json:"slot"`
+	Tasks int     `json:"tasks"`
+	CPUMs float64 `json:"cpu_ms"`
+}
+
+// resourceSnapshot 某一时刻进程累计的资源消耗
+type resourceSnapshot struct {
+	at           time.Time
+	user, system time.Duration
+	source       string
+	allocBytes   uint64
+	allocObjects uint64
+	gcCycles     uint64
+}
+
+// runtimeSamples 从 runtime/metrics 读取的指标，顺序与 takeResourceSnapshot 中的下标对应
+var runtimeSamples = []string{
+	"/gc/heap/allocs:bytes",
+	"/gc/heap/allocs:objects",
+	"/gc/cycles/total:gc-cycles",
+	"/cpu/classes/user:cpu-seconds",
+	"/cpu/classes/gc/total:cpu-seconds",
+}
+
+func takeResourceSnapshot() resourceSnapshot {
+	samples := make([]metrics.Sample, len(runtimeSamples))
+	for i, name := range runtimeSamples {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	value := func(i int) uint64 {
+		if samples[i].Value.Kind() == metrics.KindUint64 {
+			return samples[i].Value.Uint64()
+		}
+		return 0
+	}
+	seconds := func(i int) time.Duration {
+		if samples[i].Value.Kind() == metrics.KindFloat64 {
+			return time.Duration(samples[i].Value.Float64() * float64(time.Second))
+		}
+		return 0
+	}
+
+	snapshot := resourceSnapshot{
+		at:           time.Now(),
+		allocBytes:   value(0),
+		allocObjects: value(1),
+		gcCycles:     value(2),
+	}
+	if user, system, ok := processCPU(); ok {
+		snapshot.user, snapshot.system, snapshot.source = user, system, CPUSourceRusage
+	} else {
+		// 运行时只区分用户代码和 GC，系统调用的时间无法得到
+		snapshot.user, snapshot.source = seconds(3)+seconds(4), CPUSourceRuntime
+	}
+	return snapshot
+}
+
+// usageSince 计算从 start 到现在的消耗，executed 为已执行的任务数
+func usageSince(start resourceSnapshot, executed int) *ResourceUsage {
+	end := takeResourceSnapshot()
+	ms := func(d time.Duration) float64 { return round2(float64(d) / float64(time.Millisecond)) }
+	usage := &ResourceUsage{
+		CPUUserMs:    ms(end.user - start.user),
+		CPUSystemMs:  ms(end.system - start.system),
+		CPUSource:    end.source,
+		AllocBytes:   end.allocBytes - start.allocBytes,
+		AllocObjects: end.allocObjects - start.allocObjects,
+		GCCycles:     end.gcCycles - start.gcCycles,
+	}
+	usage.CPUTotalMs = round2(usage.CPUUserMs + usage.CPUSystemMs)
+	if executed > 0 {
+		usage.CPUPerTaskMs = round2(usage.CPUTotalMs / float64(executed))
+	}
+	if wall := end.at.Sub(start.at); wall > 0 {
+		usage.CPUUtil = round2(usage.CPUTotalMs / ms(wall))
+	}
+	return usage
+}
+
+// resourceTracker 记录正在执行的批次，用于标记互相重叠、进程级消耗无法区分的批次
+type resourceTracker struct {
+	mu     sync.Mutex
+	active map[*Job]struct{}
+}
+
+// begin 批次的第一个子任务开始执行，与正在执行的批次互相标记为重叠
+func (t *resourceTracker) begin(job *Job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.active == nil {
+		t.active = make(map[*Job]struct{})
+	}
+	for other := range t.active {
+		other.overlapped.Store(true)
+		job.overlapped.Store(true)
+	}
+	t.active[job] = struct{}{}
+}
+
+// end 批次结束
+func (t *resourceTracker) end(job *Job) {
+	t.mu.Lock()
+	delete(t.active, job)
+	t.mu.Unlock()
+}
+
+// EnableWorkerUsage 记录每个工作槽位上执行任务的线程 CPU 时间
+// 执行任务期间协程固定在一个线程上，任务阻塞时运行时需要另起线程，只在分析资源消耗时启用
+func (j *Job) EnableWorkerUsage() {
+	j.mu.Lock()
+	j.workerUsage = threadCPUSupported
+	j.mu.Unlock()
+}
+
+// trackWorkerCPU 启用 worker_usage 时将当前协程固定在线程上，返回的函数记录任务的线程 CPU 时间并解除固定
+// 须在执行任务的协程中调用
+func (j *Job) trackWorkerCPU(slot int) func() {
+	j.mu.RLock()
+	enabled := j.workerUsage
+	j.mu.RUnlock()
+	if !enabled {
+		return func() {}
+	}
+
+	runtime.LockOSThread()
+	start, ok := threadCPU()
+	return func() {
+		end, endOK := threadCPU()
+		runtime.UnlockOSThread()
+		if !ok || !endOK {
+			return
+		}
+		j.mu.Lock()
+		if j.workers == nil {
+			j.workers = make(map[int]*WorkerUsage)
+		}
+		w := j.workers[slot]
+		if w == nil {
+			w = &WorkerUsage{Slot: slot}
+			j.workers[slot] = w
+		}
+		w.Tasks++
+		w.CPUMs += float64(end-start) / float64(time.Millisecond)
+		j.mu.Unlock()
+	}
+}
+
+// finishUsage 计算批次的资源消耗，没有任务开始执行时返回nil，调用方持有 j.mu
+func (j *Job) finishUsage(executed int) *ResourceUsage {
+	if j.usageStart.at.IsZero() {
+		return nil
+	}
+	usage := usageSince(j.usageStart, executed)
+	usage.Overlapped = j.overlapped.Load()
+	for _, w := range j.workers {
+		worker := *w
+		worker.CPUMs = round2(worker.CPUMs)
+		usage.Workers = append(usage.Workers, worker)
+	}
+	sort.Slice(usage.Workers, func(a, b int) bool { return usage.Workers[a].Slot < usage.Workers[b].Slot })
+	return usage
+}
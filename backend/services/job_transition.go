@@ -0,0 +1,136 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 只出现在状态变更记录中的状态，任务信息中的状态不会取这些值
+const (
+	JobStateSubmitted = "submitted" // 任务已登记，尚未排队或开始执行
+	JobStatePaused    = "paused"    // 分块执行的块间暂停，任务信息中的状态仍为 running
+)
+
+// ActorSystem 由服务自身触发的状态变更（排队、开始执行、快速失败、服务关闭等）的触发者
+const ActorSystem = "system"
+
+// maxTransitionReason 状态变更原因的最大字节数，与 models.JobTransition.Reason 的列宽一致
+const maxTransitionReason = 255
+
+// TransitionStore 保存任务的状态变更记录，服务重启后仍可查询
+type TransitionStore struct {
+	DB *gorm.DB
+}
+
+// save 追加状态变更记录，s 为nil时不保存
+func (s *TransitionStore) save(jobID string, transitions []models.JobTransition) error {
+	if s == nil || s.DB == nil || len(transitions) == 0 {
+		return nil
+	}
+	rows := make([]models.JobTransition, len(transitions))
+	for i, t := range transitions {
+		t.ID, t.JobID = 0, jobID
+		rows[i] = t
+	}
+	return s.DB.Create(&rows).Error
+}
+
+// Load 读取任务的状态变更记录，按发生的顺序；s 为nil时返回空记录
+func (s *TransitionStore) Load(jobID string) ([]models.JobTransition, error) {
+	if s == nil || s.DB == nil {
+		return []models.JobTransition{}, nil
+	}
+	var transitions []models.JobTransition
+	err := s.DB.Where("job_id = ?", jobID).Order("time, id").Find(&transitions).Error
+	return transitions, err
+}
+
+// transition 记录一次状态变更，与上一个状态相同时忽略；调用方持有 j.mu
+func (j *Job) transition(to, actor, reason string) {
+	from := ""
+	if n := len(j.transitions); n > 0 {
+		from = j.transitions[n-1].To
+	}
+	if from == to {
+		return
+	}
+	j.transitions = append(j.transitions, models.JobTransition{
+		From:   from,
+		To:     to,
+		Actor:  actor,
+		Reason: truncateUTF8(reason, maxTransitionReason),
+		Time:   time.Now(),
+	})
+}
+
+// setStatus 修改任务状态并记入状态变更，调用方持有 j.mu
+func (j *Job) setStatus(status, actor, reason string) {
+	j.info.Status = status
+	j.transition(status, actor, reason)
+}
+
+// unsavedTransitions 取出尚未保存的状态变更并标记为已保存，没有配置 TransitionStore 时返回nil；调用方持有 j.mu
+func (j *Job) unsavedTransitions() []models.JobTransition {
+	if j.transitionStore == nil || j.savedTransitions == len(j.transitions) {
+		return nil
+	}
+	unsaved := append([]models.JobTransition(nil), j.transitions[j.savedTransitions:]...)
+	j.savedTransitions = len(j.transitions)
+	return unsaved
+}
+
+// saveTransitions 保存尚未保存的状态变更，失败时只记录日志
+func (j *Job) saveTransitions() {
+	j.mu.Lock()
+	unsaved := j.unsavedTransitions()
+	j.mu.Unlock()
+	if err := j.saveTransitionList(unsaved); err != nil {
+		log.Printf("保存任务 %s 的状态变更失败: %v", j.info.ID, err)
+	}
+}
+
+func (j *Job) saveTransitionList(transitions []models.JobTransition) error {
+	if len(transitions) == 0 {
+		return nil
+	}
+	return j.transitionStore.save(j.info.ID, transitions)
+}
+
+// Transitions 返回任务的状态变更记录，按发生的顺序；服务重启后恢复执行的任务含重启前保存的记录
+func (j *Job) Transitions() []models.JobTransition {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return append([]models.JobTransition(nil), j.transitions...)
+}
+
+// chunkPaused 分块执行的块间暂停开始或结束
+func (j *Job) chunkPaused(paused bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if paused {
+		j.transition(JobStatePaused, ActorSystem, "分块执行的块间暂停")
+	} else {
+		j.transition(JobStatusRunning, ActorSystem, "开始执行下一块")
+	}
+}
+
+// finalTransition 任务结束时的状态变更：取消时触发者为请求取消的用户，原因为取消模式；调用方持有 j.mu
+func (j *Job) finalTransition() {
+	actor, reason := ActorSystem, ""
+	switch j.info.CancelMode {
+	case "":
+	case CancelModeDependency:
+		reason = fmt.Sprintf("上游任务 %s 未成功完成", j.info.DependsOn)
+	default:
+		reason = "取消模式: " + string(j.info.CancelMode)
+		if j.cancelBy != "" {
+			actor = j.cancelBy
+		}
+	}
+	j.transition(j.info.Status, actor, reason)
+}
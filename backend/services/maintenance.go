@@ -0,0 +1,78 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// 维护模式的默认提示和重试时间
+const (
+	DefaultMaintenanceMessage    = "服务正在维护，暂不接收新批次，请稍后重试"
+	DefaultMaintenanceRetryAfter = 300 // 秒
+)
+
+// MaintenanceMode 只读维护模式，用于安全升级：开启后批量接口拒绝新批次，已接收的批次（执行中和排队的）照常执行完，
+// 查询接口不受影响。状态只保存在本实例的内存中，多实例部署时须逐个实例开启；零值为关闭状态
+type MaintenanceMode struct {
+	mu    sync.RWMutex
+	state MaintenanceState
+}
+
+// MaintenanceState 维护模式的状态
+type MaintenanceState struct {
+	Enabled    bool       `json:"enabled"`
+	Message    string     `json:"message,omitempty"`     // 拒绝新批次时返回的提示
+	RetryAfter int        `json:"retry_after,omitempty"` // 拒绝新批次时 Retry-After 的秒数
+	Since      *time.Time `json:"since,omitempty"`       // 开启的时间
+	By         string     `json:"by,omitempty"`          // 开启维护模式的用户
+}
+
+// Enable 开启维护模式，message 为空、retryAfter 不大于0时使用默认值；已开启时只更新提示和重试时间
+func (m *MaintenanceMode) Enable(message string, retryAfter int, by string) MaintenanceState {
+	if message == "" {
+		message = DefaultMaintenanceMessage
+	}
+	if retryAfter <= 0 {
+		retryAfter = DefaultMaintenanceRetryAfter
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.state.Enabled {
+		now := time.Now()
+		m.state = MaintenanceState{Enabled: true, Since: &now, By: by}
+	}
+	m.state.Message = message
+	m.state.RetryAfter = retryAfter
+	return m.state
+}
+
+// Disable 关闭维护模式，恢复接收新批次
+func (m *MaintenanceMode) Disable() MaintenanceState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = MaintenanceState{}
+	return m.state
+}
+
+// State 返回当前状态
+func (m *MaintenanceMode) State() MaintenanceState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// ActiveJobs 尚未结束的任务数（执行中、排队和等待上游的），维护模式下降为0时可以安全地停止服务
+func (m *JobManager) ActiveJobs() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n := 0
+	for _, job := range m.jobs {
+		select {
+		case <-job.done:
+		default:
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"sort"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 统计时间粒度
+const (
+	StatsIntervalHour = "hour"
+	StatsIntervalDay  = "day"
+)
+
+// ErrInvalidStatsInterval 不支持的统计粒度
+var ErrInvalidStatsInterval = errors.New("不支持的统计粒度")
+
+// orderIDPattern 失败信息中的订单编号，归类失败原因时去掉
+var orderIDPattern = regexp.MustCompile(`^订单 \d+ `)
+
+// OrderStatsPoint 时间序列中的一个统计区间
+type OrderStatsPoint struct {
+	Start            time.Time      `json:"start"`
+	Batches          int            `json:"batches"`
+	TotalOrders      int            `json:"total_orders"`
+	SuccessOrders    int            `json:"success_orders"`
+	FailedOrders     int            `json:"failed_orders"`
+	Units            int            `json:"units"`
+	Revenue          float64        `json:"revenue"`
+	UnitsByProduct   map[string]int `json:"units_by_product"`
+	FailuresByReason map[string]int `json:"failures_by_reason"`
+}
+
+// OrderStatsService 订单批量处理汇总统计
+type OrderStatsService struct {
+	DB     *gorm.DB
+	ReadDB *gorm.DB // 只读副本，为nil时查询走主库
+}
+
+// OrderRollup 逐个累计订单任务结果，流式处理时不需要保留全部结果即可得到批次汇总
+type OrderRollup struct {
+	orders    []OrderTask
+	units     map[string]int
+	failures  map[string]int
+	cancelled int // 已累计的取消结果数
+	rollup    models.OrderBatchRollup
+	records   []models.Order // 每个有结果的订单的处理记录，与汇总一起写入 orders 表
+}
+
+// NewOrderRollup 创建批次汇总，orders 为批次的全部订单，按任务序号对应
+func NewOrderRollup(jobID string, orders []OrderTask) *OrderRollup {
+	return &OrderRollup{
+		orders:   orders,
+		units:    make(map[string]int),
+		failures: make(map[string]int),
+		rollup:   models.OrderBatchRollup{JobID: jobID},
+	}
+}
+
+// Add 累计一个任务结果，应在收集结果的协程中串行调用
+func (r *OrderRollup) Add(task TaskResult) {
+	if task.ID >= 0 && task.ID < len(r.orders) {
+		r.records = append(r.records, orderRecord(r.rollup.JobID, r.orders[task.ID], task))
+	}
+	if task.Status != TaskStatusSuccess {
+		if task.Status == TaskStatusCancelled {
+			r.cancelled++
+		}
+		r.failures[orderFailureReason(task)]++
+		return
+	}
+	if task.ID < 0 || task.ID >= len(r.orders) {
+		return
+	}
+	order := r.orders[task.ID]
+	r.units[order.ProductName] += order.Quantity
+	r.rollup.Units += order.Quantity
+	r.rollup.Revenue += order.Price * float64(order.Quantity)
+}
+
+// Record 根据批量处理结果写入一条汇总记录
+func (s *OrderStatsService) Record(jobID string, orders []OrderTask, result *BatchResult) (*models.OrderBatchRollup, error) {
+	rollup := NewOrderRollup(jobID, orders)
+	for _, task := range result.Results {
+		rollup.Add(task)
+	}
+	return s.Save(rollup, result)
+}
+
+// Save 以批次汇总计数补全累计结果，与订单的处理记录在同一事务中写入
+// 硬取消时没有产生结果的任务也计入取消原因，与 buildBatchResult 补充的取消记录一致
+func (s *OrderStatsService) Save(r *OrderRollup, result *BatchResult) (*models.OrderBatchRollup, error) {
+	rollup := r.rollup
+	rollup.TotalOrders = result.TotalTasks
+	rollup.SuccessOrders = result.SuccessTasks
+	rollup.FailedOrders = result.FailedTasks + result.CancelledTasks
+	rollup.Duration = result.Duration
+
+	failures := r.failures
+	if missing := result.CancelledTasks - r.cancelled; missing > 0 {
+		failures[orderFailureReason(TaskResult{Status: TaskStatusCancelled})] += missing
+	}
+
+	var err error
+	if rollup.UnitsByProduct, err = marshalCounts(r.units); err != nil {
+		return nil, err
+	}
+	if rollup.FailuresByReason, err = marshalCounts(failures); err != nil {
+		return nil, err
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rollup).Error; err != nil {
+			return err
+		}
+		if len(r.records) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(r.records, orderRecordBatchSize).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rollup, nil
+}
+
+// orderRecordBatchSize 每条 INSERT 写入的订单记录数
+const orderRecordBatchSize = 200
+
+// orderRecord 订单的处理记录：成功时状态为 processed 并记录处理时间，否则为任务状态
+func orderRecord(jobID string, order OrderTask, task TaskResult) models.Order {
+	record := models.Order{
+		JobID:       jobID,
+		CustomerID:  order.CustomerID,
+		ProductName: order.ProductName,
+		Quantity:    order.Quantity,
+		Price:       order.Price,
+		Status:      task.Status,
+	}
+	if task.Status == TaskStatusSuccess {
+		processedAt := time.Now()
+		if data, ok := task.Data.(map[string]interface{}); ok {
+			if t, ok := data["processed_at"].(time.Time); ok {
+				processedAt = t
+			}
+		}
+		record.Status, record.ProcessedAt = "processed", &processedAt
+	}
+	return record
+}
+
+// Series 按时间粒度聚合 [from, to) 区间内的汇总记录，零值时间表示不限
+func (s *OrderStatsService) Series(from, to time.Time, interval string) ([]OrderStatsPoint, error) {
+	if interval != StatsIntervalHour && interval != StatsIntervalDay {
+		return nil, ErrInvalidStatsInterval
+	}
+
+	query := readerDB(s.DB, s.ReadDB).Model(&models.OrderBatchRollup{})
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+
+	var rollups []models.OrderBatchRollup
+	if err := query.Order("created_at").Find(&rollups).Error; err != nil {
+		return nil, err
+	}
+
+	points := make(map[time.Time]*OrderStatsPoint)
+	for _, rollup := range rollups {
+		start := truncateInterval(rollup.CreatedAt, interval)
+		point, ok := points[start]
+		if !ok {
+			point = &OrderStatsPoint{
+				Start:            start,
+				UnitsByProduct:   make(map[string]int),
+				FailuresByReason: make(map[string]int),
+			}
+			points[start] = point
+		}
+
+		point.Batches++
+		point.TotalOrders += rollup.TotalOrders
+		point.SuccessOrders += rollup.SuccessOrders
+		point.FailedOrders += rollup.FailedOrders
+		point.Units += rollup.Units
+		point.Revenue += rollup.Revenue
+		mergeCounts(point.UnitsByProduct, rollup.UnitsByProduct)
+		mergeCounts(point.FailuresByReason, rollup.FailuresByReason)
+	}
+
+	series := make([]OrderStatsPoint, 0, len(points))
+	for _, point := range points {
+		series = append(series, *point)
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Start.Before(series[j].Start)
+	})
+	return series, nil
+}
+
+// orderFailureReason 归类失败原因，去掉具体的订单编号以便聚合
+func orderFailureReason(result TaskResult) string {
+	if result.Status == TaskStatusCancelled {
+		return "已取消"
+	}
+	if result.Error == "" {
+		return "未知错误"
+	}
+	return orderIDPattern.ReplaceAllString(result.Error, "")
+}
+
+// truncateInterval 取时间所在统计区间的起点（本地时区）
+func truncateInterval(t time.Time, interval string) time.Time {
+	t = t.Local()
+	if interval == StatsIntervalHour {
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, time.Local)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func marshalCounts(counts map[string]int) (string, error) {
+	data, err := json.Marshal(counts)
+	return string(data), err
+}
+
+// mergeCounts 将 JSON 计数累加到 dst，格式错误的记录忽略
+func mergeCounts(dst map[string]int, raw string) {
+	var counts map[string]int
+	if err := json.Unmarshal([]byte(raw), &counts); err != nil {
+		return
+	}
+	for key, count := range counts {
+		dst[key] += count
+	}
+}
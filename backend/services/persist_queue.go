@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// PersistQueue 数据库暂时不可用时暂存写入操作，在后台按提交顺序重试
+//
+// 队列中有未完成的操作时视为持久化降级：之后的写入直接排队，保证同一任务的登记先于结果写入；
+// 全部重试成功后恢复。操作只保存在内存中，进程退出时尚未写入的部分会丢失
+type PersistQueue struct {
+	Interval time.Duration // 重试间隔，默认5秒
+	MaxItems int           // 最多暂存的操作数，超出时丢弃最早的，默认10000
+
+	mu      sync.Mutex
+	items   []persistOp
+	dropped int64
+}
+
+type persistOp struct {
+	name     string
+	run      func() error
+	queuedAt time.Time
+}
+
+// PersistStatus 持久化队列的状态
+type PersistStatus struct {
+	Degraded bool       `json:"degraded"` // 有写入操作等待重试
+	Pending  int        `json:"pending"`
+	Dropped  int64      `json:"dropped"`          // 队列已满被丢弃的操作数
+	Oldest   *time.Time `json:"oldest,omitempty"` // 最早排队的操作的时间
+}
+
+// Do 执行写入操作，失败或队列中已有等待重试的操作时排队重试；返回操作是否已完成
+// q 为nil时只执行一次，失败时记录日志
+func (q *PersistQueue) Do(name string, run func() error) bool {
+	if q == nil {
+		if err := run(); err != nil {
+			log.Printf("%s失败: %v", name, err)
+			return false
+		}
+		return true
+	}
+
+	if !q.Degraded() {
+		err := run()
+		if err == nil {
+			return true
+		}
+		log.Printf("%s失败，稍后重试: %v", name, err)
+	}
+	q.enqueue(persistOp{name: name, run: run, queuedAt: time.Now()})
+	return false
+}
+
+func (q *PersistQueue) enqueue(op persistOp) {
+	limit := q.MaxItems
+	if limit <= 0 {
+		limit = 10000
+	}
+	q.mu.Lock()
+	if len(q.items) >= limit {
+		log.Printf("持久化队列已满，丢弃最早的操作: %s", q.items[0].name)
+		q.items = q.items[1:]
+		q.dropped++
+	}
+	q.items = append(q.items, op)
+	q.mu.Unlock()
+}
+
+// Degraded 是否有写入操作等待重试
+func (q *PersistQueue) Degraded() bool {
+	if q == nil {
+		return false
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items) > 0
+}
+
+// Status 返回队列的状态
+func (q *PersistQueue) Status() PersistStatus {
+	if q == nil {
+		return PersistStatus{}
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	status := PersistStatus{Degraded: len(q.items) > 0, Pending: len(q.items), Dropped: q.dropped}
+	if len(q.items) > 0 {
+		oldest := q.items[0].queuedAt
+		status.Oldest = &oldest
+	}
+	return status
+}
+
+// Retry 按顺序重试排队的操作，遇到失败即停止，返回完成的个数
+func (q *PersistQueue) Retry() int {
+	if q == nil {
+		return 0
+	}
+	done := 0
+	for {
+		q.mu.Lock()
+		if len(q.items) == 0 {
+			q.mu.Unlock()
+			return done
+		}
+		op := q.items[0]
+		q.mu.Unlock()
+
+		if err := op.run(); err != nil {
+			log.Printf("重试%s失败: %v", op.name, err)
+			return done
+		}
+		done++
+
+		q.mu.Lock()
+		// 执行期间队列可能因已满丢弃了该操作
+		if len(q.items) > 0 && q.items[0].queuedAt.Equal(op.queuedAt) && q.items[0].name == op.name {
+			q.items = q.items[1:]
+		}
+		remaining := len(q.items)
+		q.mu.Unlock()
+		if remaining == 0 {
+			log.Printf("持久化已恢复，补写了 %d 个操作", done)
+		}
+	}
+}
+
+// Run 定期重试排队的操作，直到 ctx 结束
+func (q *PersistQueue) Run(ctx context.Context) {
+	interval := q.Interval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			q.Retry()
+		}
+	}
+}
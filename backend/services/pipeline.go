@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// PipelineFunc 处理流水线中的一个数据项，返回交给下一阶段的数据项：
+// 返回多个即扇出，返回空切片表示该数据项到此为止（过滤）
+type PipelineFunc func(ctx context.Context, item interface{}) ([]interface{}, error)
+
+// Pipeline 多阶段流水线（如 下载 → 转换 → 存储）
+// 相邻阶段之间以有界通道相连，下游处理不过来时上游随之阻塞；每个阶段按各自的并发数启动工作协程，
+// 同一阶段的工作协程从同一通道读取（扇出），输出汇入下一阶段的通道（扇入）。
+// 每个输入对应结果中的一个任务，其全部下游数据项到达最后一个阶段后才算完成
+type Pipeline struct {
+	Timeout time.Duration // 整条流水线的时间预算，0表示不限制
+	stages  []pipelineStage
+}
+
+type pipelineStage struct {
+	name        string
+	concurrency int
+	buffer      int
+	run         PipelineFunc
+}
+
+// NewPipeline 创建流水线，通过 Stage 依次添加阶段
+func NewPipeline(timeout time.Duration) *Pipeline {
+	return &Pipeline{Timeout: timeout}
+}
+
+// Stage 在末尾添加一个阶段，concurrency 小于1时按1处理，buffer 为该阶段输入通道的容量
+func (p *Pipeline) Stage(name string, concurrency, buffer int, run PipelineFunc) *Pipeline {
+	p.stages = append(p.stages, pipelineStage{
+		name:        name,
+		concurrency: max(concurrency, 1),
+		buffer:      max(buffer, 0),
+		run:         run,
+	})
+	return p
+}
+
+// StageStats 单个阶段的执行统计
+type StageStats struct {
+	Name        string `json:"name"`
+	Concurrency int    `json:"concurrency"`
+	Received    int64  `json:"received"` // 处理的数据项数
+	Emitted     int64  `json:"emitted"`  // 交给下一阶段的数据项数
+	Failed      int64  `json:"failed"`
+	Busy        int64  `json:"busy"` // 工作协程累计处理时间，毫秒
+}
+
+// stageCounters 阶段执行中累计的计数，工作协程并发更新
+type stageCounters struct {
+	received, emitted, failed, busy atomic.Int64
+}
+
+// PipelineResult 流水线执行结果，Results 中每个任务对应一个输入，data 为其到达最后一个阶段的全部数据项
+type PipelineResult struct {
+	*BatchResult
+	Stages  []StageStats `json:"stages"`
+	Outputs []string     `json:"outputs,omitempty"` // store 阶段写入的文件
+}
+
+// pipelineItem 在阶段间传递的数据项，origin 为其来源输入的序号
+type pipelineItem struct {
+	origin int
+	value  interface{}
+}
+
+// pipelineOrigin 单个输入的执行状态
+type pipelineOrigin struct {
+	start   time.Time
+	pending int // 尚未到达最后一个阶段的数据项数
+	outputs []interface{}
+	err     error
+}
+
+// pipelineRun 一次执行的共享状态
+type pipelineRun struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	origins []pipelineOrigin
+	results chan TaskResult
+}
+
+// Run 执行流水线，inputs 中的每一项依次进入第一个阶段
+// 超时或取消时返回已完成的输入，其余输入按是否已进入流水线记为超时或未开始
+func (p *Pipeline) Run(ctx context.Context, inputs []interface{}) *PipelineResult {
+	startTime := time.Now()
+
+	var cancel context.CancelFunc
+	if p.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	// 返回时停止仍在运行的阶段
+	defer cancel()
+	ctx = withStartTracker(ctx, len(inputs))
+	ctx = withRetryBudget(ctx, len(inputs))
+
+	run := &pipelineRun{
+		ctx:     ctx,
+		origins: make([]pipelineOrigin, len(inputs)),
+		results: make(chan TaskResult, len(inputs)),
+	}
+	counters := make([]stageCounters, len(p.stages))
+	if len(p.stages) == 0 || len(inputs) == 0 {
+		return p.result(ctx, startTime, len(inputs), nil, counters)
+	}
+
+	// 第一个阶段的输入
+	source := make(chan pipelineItem, p.stages[0].buffer)
+	go func() {
+		defer close(source)
+		for i, value := range inputs {
+			taskStart := time.Now()
+			if result, ok := checkDispatch(ctx, i, taskStart); !ok {
+				run.results <- result
+				continue
+			}
+			run.begin(i, taskStart)
+			select {
+			case source <- pipelineItem{origin: i, value: value}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	in := source
+	for i, stage := range p.stages {
+		// 最后一个阶段的输出直接计入结果，其余阶段的输出进入下一阶段的通道
+		var out chan pipelineItem
+		if i < len(p.stages)-1 {
+			out = make(chan pipelineItem, p.stages[i+1].buffer)
+		}
+		p.startStage(run, stage, &counters[i], in, out)
+		in = out
+	}
+
+	// 收集结果，超时或取消时立即返回；每个输入恰好产生一个结果，收齐即结束
+	var results []TaskResult
+	collector := batch.Collector[TaskResult]{
+		Expected: len(inputs),
+		OnResult: func(result TaskResult) {
+			reportProgress(ctx, result)
+			results = append(results, result)
+		},
+	}
+	collector.Collect(ctx, run.results)
+
+	return p.result(ctx, startTime, len(inputs), results, counters)
+}
+
+// result 汇总结果和各阶段的统计，超时返回时仍在运行的阶段的计数为返回时的值
+func (p *Pipeline) result(ctx context.Context, startTime time.Time, totalTasks int, results []TaskResult, counters []stageCounters) *PipelineResult {
+	stats := make([]StageStats, len(p.stages))
+	for i, stage := range p.stages {
+		stats[i] = StageStats{
+			Name:        stage.name,
+			Concurrency: stage.concurrency,
+			Received:    counters[i].received.Load(),
+			Emitted:     counters[i].emitted.Load(),
+			Failed:      counters[i].failed.Load(),
+			Busy:        counters[i].busy.Load(),
+		}
+	}
+	return &PipelineResult{BatchResult: buildBatchResult(ctx, startTime, totalTasks, results), Stages: stats}
+}
+
+// startStage 启动阶段的工作协程，全部退出后关闭输出通道
+func (p *Pipeline) startStage(run *pipelineRun, stage pipelineStage, stats *stageCounters, in <-chan pipelineItem, out chan<- pipelineItem) {
+	ctx := run.ctx
+	var wg sync.WaitGroup
+	for w := 0; w < stage.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range in {
+				if ctx.Err() != nil {
+					return
+				}
+				itemStart := time.Now()
+				outputs, err := stage.call(ctx, item)
+				stats.busy.Add(time.Since(itemStart).Milliseconds())
+				stats.received.Add(1)
+				if err != nil {
+					stats.failed.Add(1)
+					run.fail(item.origin, fmt.Errorf("阶段 %s: %w", stage.name, err))
+					continue
+				}
+
+				// 先登记扇出的数据项，避免下游先处理完其中一部分时误判该输入已完成
+				run.fanOut(item.origin, len(outputs))
+				// 交出数据项前计数，下游处理完时统计已包含该数据项
+				for _, value := range outputs {
+					stats.emitted.Add(1)
+					if out == nil {
+						run.deliver(item.origin, value)
+						continue
+					}
+					select {
+					case out <- pipelineItem{origin: item.origin, value: value}:
+					case <-ctx.Done():
+						stats.emitted.Add(-1)
+						return
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		if out != nil {
+			close(out)
+		}
+	}()
+}
+
+// call 执行阶段函数，panic 时转换为该数据项的错误
+func (s pipelineStage) call(ctx context.Context, item pipelineItem) (outputs []interface{}, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = panicError(ctx, item.origin, rec)
+		}
+	}()
+	return s.run(ctx, item.value)
+}
+
+// begin 输入进入流水线
+func (r *pipelineRun) begin(origin int, start time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.origins[origin] = pipelineOrigin{start: start, pending: 1}
+}
+
+// fanOut 一个数据项处理完成并产生 n 个下游数据项
+func (r *pipelineRun) fanOut(origin, n int) {
+	r.settle(origin, n-1, nil, nil)
+}
+
+// fail 一个数据项处理失败，不再产生下游数据项；同一输入只记录第一个错误
+func (r *pipelineRun) fail(origin int, err error) {
+	r.settle(origin, -1, nil, err)
+}
+
+// deliver 一个数据项到达流水线末端
+func (r *pipelineRun) deliver(origin int, value interface{}) {
+	r.settle(origin, -1, value, nil)
+}
+
+// settle 更新输入的待处理数据项数，全部处理完时输出该输入的结果
+func (r *pipelineRun) settle(origin, delta int, output interface{}, err error) {
+	r.mu.Lock()
+	o := &r.origins[origin]
+	o.pending += delta
+	if output != nil {
+		o.outputs = append(o.outputs, output)
+	}
+	if err != nil && o.err == nil {
+		o.err = err
+	}
+	if o.pending > 0 {
+		r.mu.Unlock()
+		return
+	}
+	result := TaskResult{
+		ID:       origin,
+		Success:  o.err == nil,
+		Status:   TaskStatusSuccess,
+		Data:     o.outputs,
+		Duration: time.Since(o.start).Milliseconds(),
+	}
+	if o.err != nil {
+		result.Status = failureStatus(r.ctx, o.err)
+		result.Error = o.err.Error()
+		result.classify(r.ctx, o.err)
+	}
+	r.mu.Unlock()
+
+	r.results <- result
+}
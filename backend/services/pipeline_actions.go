@@ -0,0 +1,192 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 流水线的内置处理动作
+const (
+	PipelineFetch      = "fetch"       // 以数据项为 URL 发起 GET 请求，输出响应体
+	PipelineSplitLines = "split_lines" // 按行拆分，每个非空行作为一个数据项（扇出）
+	PipelineTrim       = "trim"        // 去除首尾空白，结果为空时丢弃
+	PipelineUpper      = "upper"
+	PipelineLower      = "lower"
+	PipelineSHA256     = "sha256" // 输出 SHA-256 十六进制摘要
+	PipelineStore      = "store"  // 逐行追加写入输出文件，数据项原样传给下一阶段
+)
+
+// ErrUnknownPipelineAction 阶段指定了不支持的处理动作
+var ErrUnknownPipelineAction = errors.New("不支持的处理动作")
+
+// PipelineStageSpec 由内置动作构成的阶段配置
+type PipelineStageSpec struct {
+	Name        string // 为空时使用动作名
+	Action      string
+	Concurrency int
+	Buffer      int
+}
+
+// PipelineService 按阶段配置组装并执行由内置动作构成的流水线
+type PipelineService struct {
+	Fetcher   *APICallService // fetch 动作使用其 HTTP 客户端和重试策略
+	OutputDir string          // store 动作的输出目录
+	Timeout   time.Duration   // 单次执行的时间预算
+}
+
+// Run 以 specs 组装流水线并处理 inputs，store 阶段写入的文件列在结果的 Outputs 中
+// 阶段配置无效或无法创建输出文件时返回错误，不执行任何阶段
+func (s *PipelineService) Run(ctx context.Context, id string, specs []PipelineStageSpec, inputs []string) (*PipelineResult, error) {
+	pipeline := NewPipeline(s.Timeout)
+	var sinks []*pipelineSink
+	defer func() {
+		for _, sink := range sinks {
+			sink.close()
+		}
+	}()
+
+	for i, spec := range specs {
+		name := spec.Name
+		if name == "" {
+			name = spec.Action
+		}
+		var run PipelineFunc
+		switch spec.Action {
+		case PipelineFetch:
+			run = s.fetch
+		case PipelineSplitLines:
+			run = stringStage(func(v string) []string {
+				var lines []string
+				for _, line := range strings.Split(v, "\n") {
+					if line = strings.TrimRight(line, "\r"); line != "" {
+						lines = append(lines, line)
+					}
+				}
+				return lines
+			})
+		case PipelineTrim:
+			run = stringStage(func(v string) []string {
+				if v = strings.TrimSpace(v); v == "" {
+					return nil
+				}
+				return []string{v}
+			})
+		case PipelineUpper:
+			run = stringStage(func(v string) []string { return []string{strings.ToUpper(v)} })
+		case PipelineLower:
+			run = stringStage(func(v string) []string { return []string{strings.ToLower(v)} })
+		case PipelineSHA256:
+			run = stringStage(func(v string) []string {
+				sum := sha256.Sum256([]byte(v))
+				return []string{hex.EncodeToString(sum[:])}
+			})
+		case PipelineStore:
+			// 文件名只使用任务ID和阶段序号，不拼接客户端提供的阶段名
+			sink, err := openPipelineSink(filepath.Join(s.OutputDir, fmt.Sprintf("%s_stage%d.txt", id, i+1)))
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+			run = sink.write
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPipelineAction, spec.Action)
+		}
+		pipeline.Stage(name, spec.Concurrency, spec.Buffer, run)
+	}
+
+	items := make([]interface{}, len(inputs))
+	for i, input := range inputs {
+		items[i] = input
+	}
+	result := pipeline.Run(ctx, items)
+	for _, sink := range sinks {
+		result.Outputs = append(result.Outputs, sink.path)
+	}
+	return result, nil
+}
+
+// fetch 以数据项为 URL 发起 GET 请求，按 Fetcher 的限速、工作池、主机限流和重试策略执行
+func (s *PipelineService) fetch(ctx context.Context, item interface{}) ([]interface{}, error) {
+	url, ok := item.(string)
+	if !ok {
+		return nil, fmt.Errorf("数据项不是字符串: %T", item)
+	}
+	// 与批量API调用共享调用配额
+	if err := s.Fetcher.RateLimit.Wait(ctx); err != nil {
+		return nil, err
+	}
+	data, _, err := runWithRetry(ctx, s.Fetcher.Retry, url, func(ctx context.Context) (interface{}, error) {
+		return s.Fetcher.callLimited(ctx, PoolTaskPipeline, APICallTask{URL: url, Method: "GET"})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{data.(map[string]interface{})["response_body"]}, nil
+}
+
+// stringStage 将字符串转换函数包装为阶段处理函数
+func stringStage(fn func(string) []string) PipelineFunc {
+	return func(ctx context.Context, item interface{}) ([]interface{}, error) {
+		v, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("数据项不是字符串: %T", item)
+		}
+		values := fn(v)
+		outputs := make([]interface{}, len(values))
+		for i, value := range values {
+			outputs[i] = value
+		}
+		return outputs, nil
+	}
+}
+
+// pipelineSink store 动作的输出文件，多个工作协程串行写入
+type pipelineSink struct {
+	path   string
+	mu     sync.Mutex
+	file   *os.File
+	w      *bufio.Writer
+	closed bool
+}
+
+func openPipelineSink(path string) (*pipelineSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建输出文件失败: %w", err)
+	}
+	return &pipelineSink{path: path, file: file, w: bufio.NewWriter(file)}, nil
+}
+
+// write 追加一行，数据项原样传给下一阶段
+func (s *pipelineSink) write(ctx context.Context, item interface{}) ([]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, fmt.Errorf("输出文件已关闭")
+	}
+	if _, err := fmt.Fprintln(s.w, item); err != nil {
+		return nil, fmt.Errorf("写入输出文件失败: %w", err)
+	}
+	return []interface{}{item}, nil
+}
+
+// close 刷新缓冲并关闭文件；流水线超时返回后仍在运行的阶段写入的内容可能不完整
+func (s *pipelineSink) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.w.Flush()
+	s.file.Close()
+}
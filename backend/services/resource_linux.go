@@ -0,0 +1,23 @@
+//go:build linux
+
+package services
+
+import (
+	"syscall"
+	"time"
+)
+
+// rusageThread getrusage 的 RUSAGE_THREAD，syscall 包中没有定义
+const rusageThread = 1
+
+// threadCPUSupported 当前平台能否获取单个线程的 CPU 时间
+const threadCPUSupported = true
+
+// threadCPU 返回当前线程累计的 CPU 时间（用户态与内核态之和）
+func threadCPU() (time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(rusageThread, &usage); err != nil {
+		return 0, false
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package services
+
+import "time"
+
+// threadCPUSupported 当前平台能否获取单个线程的 CPU 时间
+const threadCPUSupported = false
+
+// threadCPU 只有 Linux 支持获取单个线程的 CPU 时间
+func threadCPU() (time.Duration, bool) {
+	return 0, false
+}
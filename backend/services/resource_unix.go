@@ -0,0 +1,17 @@
+//go:build !windows
+
+package services
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPU 返回进程累计的用户态和内核态 CPU 时间
+func processCPU() (user, system time.Duration, ok bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, 0, false
+	}
+	return time.Duration(usage.Utime.Nano()), time.Duration(usage.Stime.Nano()), true
+}
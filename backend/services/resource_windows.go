@@ -0,0 +1,10 @@
+//go:build windows
+
+package services
+
+import "time"
+
+// processCPU Windows 下暂不支持，改用 Go 运行时的估算
+func processCPU() (user, system time.Duration, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrResultEncryptionDisabled 未配置主密钥，结果以明文保存
+	ErrResultEncryptionDisabled = errors.New("未配置结果加密主密钥")
+	// ErrInvalidMasterKey 主密钥格式不正确
+	ErrInvalidMasterKey = errors.New("主密钥必须是 base64 编码的 32 字节")
+)
+
+// envelopeVersion 加密结果的格式版本
+const envelopeVersion = "v1"
+
+// ResultEnvelope 加密后的结果，以 JSON 保存，与明文结果按 envelope 字段区分
+type ResultEnvelope struct {
+	Envelope   string `json:"envelope"`
+	TenantID   uint   `json:"tenant_id"`
+	KeyVersion int    `json:"key_version"`
+	Data       string `json:"data"` // base64(nonce + 密文)
+}
+
+// ResultCipher 按租户加密保存的任务结果（信封加密）
+//
+// 每个租户有各自的数据密钥（AES-256-GCM），数据密钥以主密钥加密后保存在 tenant_keys 表，
+// 主密钥只在进程内存中，数据库管理员拿到数据库和产出物文件也无法解密。
+// 轮换后新结果使用新版本的数据密钥，旧版本保留用于解密轮换前写入的结果
+type ResultCipher struct {
+	DB     *gorm.DB
+	master cipher.AEAD
+
+	mu   sync.Mutex
+	keys map[tenantKeyID]cipher.AEAD // 已解开的数据密钥
+}
+
+type tenantKeyID struct {
+	tenant  uint
+	version int
+}
+
+// NewResultCipher 以 base64 编码的 32 字节主密钥创建，masterKey 为空时返回 nil（不加密）
+func NewResultCipher(db *gorm.DB, masterKey string) (*ResultCipher, error) {
+	if masterKey == "" {
+		return nil, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(masterKey)
+	if err != nil || len(raw) != 32 {
+		return nil, ErrInvalidMasterKey
+	}
+	master, err := newGCM(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &ResultCipher{DB: db, master: master, keys: make(map[tenantKeyID]cipher.AEAD)}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 加密，nonce 放在密文前
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// open 解密 seal 的输出
+func open(aead cipher.AEAD, data, additional []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("密文长度不正确")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additional)
+}
+
+// wrapLabel 数据密钥的附加数据，防止把一个租户的数据密钥记录换给另一个租户
+func wrapLabel(tenantID uint, version int) []byte {
+	return []byte(fmt.Sprintf("tenant:%d/v%d", tenantID, version))
+}
+
+// Seal 用租户当前的数据密钥加密结果，label 为结果的标识（如任务ID），解密时必须一致
+func (c *ResultCipher) Seal(tenantID uint, label string, plaintext []byte) ([]byte, error) {
+	version, aead, err := c.currentKey(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := seal(aead, plaintext, []byte(label))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(ResultEnvelope{
+		Envelope:   envelopeVersion,
+		TenantID:   tenantID,
+		KeyVersion: version,
+		Data:       base64.StdEncoding.EncodeToString(data),
+	})
+}
+
+// Open 解密 Seal 的输出；data 不是加密结果时原样返回，兼容启用加密前保存的结果
+func (c *ResultCipher) Open(label string, data []byte) ([]byte, error) {
+	var envelope ResultEnvelope
+	if json.Unmarshal(data, &envelope) != nil || envelope.Envelope == "" {
+		return data, nil
+	}
+	if c == nil {
+		return nil, ErrResultEncryptionDisabled
+	}
+	if envelope.Envelope != envelopeVersion {
+		return nil, fmt.Errorf("不支持的加密格式: %s", envelope.Envelope)
+	}
+	aead, err := c.key(envelope.TenantID, envelope.KeyVersion)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(envelope.Data)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(aead, sealed, []byte(label))
+	if err != nil {
+		return nil, fmt.Errorf("解密结果失败: %v", err)
+	}
+	return plaintext, nil
+}
+
+// Rotate 为租户生成新版本的数据密钥，之后的结果使用新密钥加密；旧版本标记为已轮换，仍可解密已有结果
+func (c *ResultCipher) Rotate(tenantID uint) (*models.TenantKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var key *models.TenantKey
+	err := c.DB.Transaction(func(tx *gorm.DB) error {
+		var latest models.TenantKey
+		err := tx.Where("tenant_id = ?", tenantID).Order("version DESC").First(&latest).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := tx.Model(&models.TenantKey{}).
+			Where("tenant_id = ? AND retired_at IS NULL", tenantID).
+			Update("retired_at", time.Now()).Error; err != nil {
+			return err
+		}
+		key, err = c.createKey(tx, tenantID, latest.Version+1)
+		return err
+	})
+	return key, err
+}
+
+// Keys 租户的数据密钥版本，按版本倒序
+func (c *ResultCipher) Keys(tenantID uint) ([]models.TenantKey, error) {
+	var keys []models.TenantKey
+	err := c.DB.Where("tenant_id = ?", tenantID).Order("version DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Forget 清除已解开的租户数据密钥缓存，租户删除后调用，之后该租户的结果无法再解密
+func (c *ResultCipher) Forget(tenantID uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.keys {
+		if id.tenant == tenantID {
+			delete(c.keys, id)
+		}
+	}
+}
+
+// currentKey 返回租户当前使用的数据密钥，租户还没有数据密钥时生成第一个版本
+func (c *ResultCipher) currentKey(tenantID uint) (int, cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var keys []models.TenantKey
+	err := c.DB.Where("tenant_id = ? AND retired_at IS NULL", tenantID).Order("version DESC").Limit(1).Find(&keys).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	var key *models.TenantKey
+	if len(keys) > 0 {
+		key = &keys[0]
+	} else if key, err = c.createKey(c.DB, tenantID, 1); err != nil {
+		// 其他实例同时生成了第一个版本
+		var existing models.TenantKey
+		if err := c.DB.Where("tenant_id = ? AND version = 1", tenantID).First(&existing).Error; err != nil {
+			return 0, nil, err
+		}
+		key = &existing
+	}
+	aead, err := c.unwrap(key)
+	return key.Version, aead, err
+}
+
+// key 返回租户指定版本的数据密钥
+func (c *ResultCipher) key(tenantID uint, version int) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if aead, ok := c.keys[tenantKeyID{tenantID, version}]; ok {
+		return aead, nil
+	}
+	var key models.TenantKey
+	err := c.DB.Where("tenant_id = ? AND version = ?", tenantID, version).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("租户 %d 的数据密钥 v%d 不存在（租户可能已删除）", tenantID, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c.unwrap(&key)
+}
+
+// createKey 生成数据密钥，以主密钥加密后保存
+func (c *ResultCipher) createKey(db *gorm.DB, tenantID uint, version int) (*models.TenantKey, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(c.master, raw, wrapLabel(tenantID, version))
+	if err != nil {
+		return nil, err
+	}
+	key := &models.TenantKey{TenantID: tenantID, Version: version, WrappedKey: base64.StdEncoding.EncodeToString(wrapped)}
+	if err := db.Create(key).Error; err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// unwrap 以主密钥解开数据密钥并缓存，调用方持有 c.mu
+func (c *ResultCipher) unwrap(key *models.TenantKey) (cipher.AEAD, error) {
+	id := tenantKeyID{key.TenantID, key.Version}
+	if aead, ok := c.keys[id]; ok {
+		return aead, nil
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(key.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := open(c.master, wrapped, wrapLabel(key.TenantID, key.Version))
+	if err != nil {
+		return nil, fmt.Errorf("解开租户 %d 的数据密钥 v%d 失败，主密钥可能不正确: %v", key.TenantID, key.Version, err)
+	}
+	aead, err := newGCM(raw)
+	if err != nil {
+		return nil, err
+	}
+	c.keys[id] = aead
+	return aead, nil
+}
+
+// sealResult 按任务所属的租户加密结果，c 为 nil 时原样返回
+func (c *ResultCipher) sealResult(info JobInfo, label string, data []byte) ([]byte, error) {
+	if c == nil {
+		return data, nil
+	}
+	return c.Seal(info.TenantID, label, data)
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// ResultCSVHeader 任务结果 CSV 的列
+var ResultCSVHeader = []string{"id", "status", "success", "duration_ms", "error_code", "error_class", "error", "data", "instance", "pool", "worker", "attempt"}
+
+// WriteResultCSV 将批次结果按子任务逐行写为 CSV，data 列为结果数据的 JSON，没有数据时为空；
+// instance、pool、worker 和 attempt 列为执行环境（见 TaskEnv），未开始执行的任务为空
+func WriteResultCSV(w io.Writer, result *BatchResult) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(ResultCSVHeader); err != nil {
+		return err
+	}
+	for _, r := range result.Results {
+		data := ""
+		if r.Data != nil {
+			raw, err := json.Marshal(r.Data)
+			if err != nil {
+				return err
+			}
+			data = string(raw)
+		}
+		env := []string{"", "", "", ""}
+		if r.Env != nil {
+			env = []string{r.Env.Instance, r.Env.Pool, strconv.Itoa(r.Env.Worker), strconv.Itoa(r.Env.Attempt)}
+		}
+		err := writer.Write(append([]string{
+			strconv.Itoa(r.ID),
+			r.Status,
+			strconv.FormatBool(r.Success),
+			strconv.FormatInt(r.Duration, 10),
+			r.ErrorCode,
+			r.ErrorClass,
+			r.Error,
+			data,
+		}, env...))
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
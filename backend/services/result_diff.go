@@ -0,0 +1,154 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync/atomic"
+)
+
+// TaskStatusMismatch 任务执行成功，但结果与提交的预期输出不符，计入失败任务
+const TaskStatusMismatch = "mismatch"
+
+// maxMismatches 每个任务最多记录的不符字段数
+const maxMismatches = 20
+
+// FieldMismatch 结果中与预期不符的字段
+type FieldMismatch struct {
+	Path     string      `json:"path"` // 字段路径，如 total_price、items[0].name，整个结果不符时为空
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"` // 结果中没有该字段时为nil
+}
+
+// Expectations 批次中各任务的预期输出（golden data），按提交时的任务序号对应，用于回归测试
+// 预期输出只需列出要比较的字段：对象按字段逐个比较，结果中多出的字段（如处理时间）不参与比较；
+// 数组要求长度相同并逐项比较；数字按数值比较。只比较执行成功的任务，失败的任务保持原状态。
+// 方法对 nil 安全：未提交预期输出时原样返回
+type Expectations struct {
+	outputs    []interface{} // 为nil的项不比较
+	mismatched atomic.Int64
+}
+
+// NewExpectations 解析各任务的预期输出，raw 为空时返回nil；raw 的长度须与任务数相同，不比较的任务填 null
+func NewExpectations(raw []json.RawMessage, tasks int) (*Expectations, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	if len(raw) != tasks {
+		return nil, fmt.Errorf("预期输出有 %d 项，任务有 %d 个", len(raw), tasks)
+	}
+	e := &Expectations{outputs: make([]interface{}, len(raw))}
+	for i, item := range raw {
+		if err := json.Unmarshal(item, &e.outputs[i]); err != nil {
+			return nil, fmt.Errorf("第 %d 项预期输出格式错误: %v", i, err)
+		}
+	}
+	return e, nil
+}
+
+// Check 比较单个任务的结果与预期输出，不符时状态改为 mismatch 并列出不符的字段
+// result.ID 须为提交时的任务序号（抽样执行时先 Remap）
+func (e *Expectations) Check(result TaskResult) TaskResult {
+	if e == nil || !result.Success || result.ID < 0 || result.ID >= len(e.outputs) || e.outputs[result.ID] == nil {
+		return result
+	}
+	// 结果经 JSON 往返后再比较，与客户端看到的结果一致（时间为字符串、数字为 float64）
+	var actual interface{}
+	raw, err := json.Marshal(result.Data)
+	if err == nil {
+		err = json.Unmarshal(raw, &actual)
+	}
+	var mismatches []FieldMismatch
+	if err != nil {
+		mismatches = []FieldMismatch{{Expected: e.outputs[result.ID], Actual: fmt.Sprint(result.Data)}}
+	} else {
+		mismatches = diffValue("", e.outputs[result.ID], actual, nil)
+	}
+	if len(mismatches) == 0 {
+		return result
+	}
+
+	e.mismatched.Add(1)
+	result.Success = false
+	result.Status = TaskStatusMismatch
+	result.Error = fmt.Sprintf("结果与预期不符（%d 处）", len(mismatches))
+	result.ErrorCode = ErrCodeMismatch
+	result.ErrorParams = map[string]interface{}{"count": len(mismatches)}
+	if len(mismatches) > maxMismatches {
+		result.Error = fmt.Sprintf("结果与预期不符（超过 %d 处）", maxMismatches)
+		result.ErrorParams["count"] = fmt.Sprintf("%d+", maxMismatches)
+		mismatches = mismatches[:maxMismatches]
+	}
+	result.Mismatches = mismatches
+	return result
+}
+
+// Summarize 将 Check 判定为不符的任务从成功数移到失败数，记入 MismatchTasks
+// 流式返回时结果逐个经过 Check，最后以 Summarize 修正汇总
+func (e *Expectations) Summarize(result *BatchResult) *BatchResult {
+	if e == nil {
+		return result
+	}
+	n := int(e.mismatched.Load())
+	result.MismatchTasks = n
+	result.SuccessTasks -= n
+	result.FailedTasks += n
+	return result
+}
+
+// Apply 比较批次中每个任务的结果，并修正汇总
+func (e *Expectations) Apply(result *BatchResult) *BatchResult {
+	if e == nil {
+		return result
+	}
+	for i := range result.Results {
+		result.Results[i] = e.Check(result.Results[i])
+	}
+	return e.Summarize(result)
+}
+
+// diffValue 递归比较预期值和实际值，返回不符的字段，超过 maxMismatches 后不再继续比较
+func diffValue(path string, expected, actual interface{}, mismatches []FieldMismatch) []FieldMismatch {
+	if len(mismatches) > maxMismatches {
+		return mismatches
+	}
+	switch want := expected.(type) {
+	case map[string]interface{}:
+		got, ok := actual.(map[string]interface{})
+		if !ok {
+			return append(mismatches, FieldMismatch{Path: path, Expected: expected, Actual: actual})
+		}
+		keys := make([]string, 0, len(want))
+		for key := range want {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			value, ok := got[key]
+			if !ok {
+				mismatches = append(mismatches, FieldMismatch{Path: child, Expected: want[key]})
+				continue
+			}
+			mismatches = diffValue(child, want[key], value, mismatches)
+		}
+		return mismatches
+	case []interface{}:
+		got, ok := actual.([]interface{})
+		if !ok || len(got) != len(want) {
+			return append(mismatches, FieldMismatch{Path: path, Expected: expected, Actual: actual})
+		}
+		for i := range want {
+			mismatches = diffValue(fmt.Sprintf("%s[%d]", path, i), want[i], got[i], mismatches)
+		}
+		return mismatches
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		mismatches = append(mismatches, FieldMismatch{Path: path, Expected: expected, Actual: actual})
+	}
+	return mismatches
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"unicode/utf8"
+)
+
+// ErrFullResultNotFound 任务结果没有超出大小限制，或完整结果已按保留策略删除
+var ErrFullResultNotFound = errors.New("完整结果不存在")
+
+// ResultLimit 单个任务结果数据的大小限制
+// 超出限制时完整结果作为任务的明细保存到磁盘，TaskResult.Data 中只保留截断后的内容和完整结果的下载地址
+type ResultLimit struct {
+	MaxBytes int           // 结果数据序列化后的最大字节数，0表示不限制
+	Dir      string        // 完整结果的保存目录，按任务ID分子目录
+	Cipher   *ResultCipher // 按租户加密完整结果，为nil时以明文保存
+}
+
+// FullResultURL 超出大小限制的任务结果的完整内容的下载地址
+func FullResultURL(jobID string, index int) string {
+	return fmt.Sprintf("/api/jobs/%s/results/%d/full", jobID, index)
+}
+
+// path 完整结果的保存位置，加密时同时作为附加数据
+func (l ResultLimit) path(jobID string, index int) string {
+	return filepath.Join(l.Dir, jobID, fmt.Sprintf("task_%d.json", index))
+}
+
+// apply 按限制处理任务结果数据；不属于任何任务的调用（如启动自检）只截断，不保存完整结果
+func (l ResultLimit) apply(ctx context.Context, index int, data interface{}) interface{} {
+	if l.MaxBytes <= 0 || data == nil {
+		return data
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil || len(raw) <= l.MaxBytes {
+		return data
+	}
+
+	saved := false
+	job := JobFromContext(ctx)
+	if job != nil {
+		if err := l.save(job.Info(), index, raw); err != nil {
+			log.Printf("保存任务 %s 的第 %d 个结果的完整内容失败: %v", job.ID(), index, err)
+		} else {
+			saved = true
+		}
+	}
+
+	truncated := map[string]interface{}{
+		"truncated": true,
+		"full_size": len(raw),
+	}
+	if saved {
+		truncated["full_result_url"] = FullResultURL(job.ID(), index)
+	}
+	// map 结果在总大小不超过 MaxBytes 的前提下尽量保留字段
+	if fields, ok := data.(map[string]interface{}); ok {
+		l.fit(truncated, fields)
+	}
+	return truncated
+}
+
+// fit 从小到大依次把 fields 中的字段加入 truncated，使序列化后的总大小不超过 MaxBytes：
+// 放不下的字符串字段（如响应体）截断到剩余的大小，其余放不下的字段（如响应头）整个丢弃，完整内容从 full_result_url 下载。
+// MaxBytes 小到连 truncated、full_size 等标记都放不下时只返回这些标记
+func (l ResultLimit) fit(truncated map[string]interface{}, fields map[string]interface{}) {
+	sizes := make(map[string]int, len(fields))
+	keys := make([]string, 0, len(fields))
+	for key, value := range fields {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		sizes[key] = len(encoded)
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if sizes[keys[i]] != sizes[keys[j]] {
+			return sizes[keys[i]] < sizes[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		truncated[key] = fields[key]
+		if jsonSize(truncated) <= l.MaxBytes {
+			continue
+		}
+		if text, ok := fields[key].(string); ok {
+			// 转义会使序列化后的字符串变长，二分查找放得下的最大长度
+			truncated[key] = ""
+			low, high := 0, min(len(text), l.MaxBytes-jsonSize(truncated))
+			for low < high {
+				mid := (low + high + 1) / 2
+				truncated[key] = truncateUTF8(text, mid)
+				if jsonSize(truncated) <= l.MaxBytes {
+					low = mid
+				} else {
+					high = mid - 1
+				}
+			}
+			if low > 0 {
+				truncated[key] = truncateUTF8(text, low)
+				continue
+			}
+		}
+		delete(truncated, key)
+	}
+}
+
+// jsonSize 返回 v 序列化后的字节数
+func jsonSize(v interface{}) int {
+	encoded, _ := json.Marshal(v)
+	return len(encoded)
+}
+
+// save 保存任务的第 index 个结果的完整内容
+func (l ResultLimit) save(info JobInfo, index int, raw []byte) error {
+	path := l.path(info.ID, index)
+	sealed, err := l.Cipher.sealResult(info, path, raw)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, sealed, 0644)
+}
+
+// Load 读取并解密任务的第 index 个结果的完整内容
+func (l ResultLimit) Load(jobID string, index int) ([]byte, error) {
+	path := l.path(jobID, index)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrFullResultNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return l.Cipher.Open(path, data)
+}
+
+// truncateUTF8 截断字符串到不超过 max 字节，不拆分多字节字符
+func truncateUTF8(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	for max > 0 && !utf8.RuneStart(text[max]) {
+		max--
+	}
+	return text[:max]
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	// ErrInvalidCursor 分页游标格式错误或不属于该任务
+	ErrInvalidCursor = errors.New("分页游标无效")
+	// ErrCursorExpired 游标所属的快照已不在内存中（服务重启后执行中任务的快照无法还原），应不带游标重新分页
+	ErrCursorExpired = errors.New("分页游标已过期，请不带游标重新查询")
+	// ErrResultsNotRetained 批次的任务结果逐个返回后即丢弃（NDJSON 流式返回），不能分页查询
+	ErrResultsNotRetained = errors.New("该批次不保留任务结果，不能分页查询")
+)
+
+// ResultPage 按快照分页的任务结果：第一页确定快照，之后携带游标翻页时只在快照中的结果里按任务序号翻页，
+// 执行中的任务陆续结束的新结果不会让已翻过的页发生偏移
+type ResultPage struct {
+	Results    []TaskResult `json:"results"`
+	SnapshotAt time.Time    `json:"snapshot_at"`           // 快照的时间，同一次分页的各页相同
+	Snapshot   int          `json:"snapshot_size"`         // 快照中的结果数
+	NextCursor string       `json:"next_cursor,omitempty"` // 下一页的游标，最后一页为空
+	// HasNewer 快照之后又有任务结束（或任务已结束、最终结果中有快照之外的任务），翻完后不带游标重新查询可取得
+	HasNewer bool `json:"has_newer"`
+	// Final 快照取自已结束任务的最终结果，不会再有新结果
+	Final bool `json:"final"`
+}
+
+// resultCursor 分页游标的内容，编码为 base64 的 JSON，对客户端不透明
+type resultCursor struct {
+	Job   string `json:"j"`
+	Size  int    `json:"n"`           // 快照中的结果数：执行中的快照为结束顺序中的前 Size 个结果
+	At    int64  `json:"t"`           // 快照的时间（UnixNano）
+	After int    `json:"a"`           // 上一页最后一个结果的任务序号
+	Final bool   `json:"f,omitempty"` // 快照取自最终结果
+}
+
+func (c resultCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor 解析任务 jobID 的游标
+func decodeCursor(jobID, value string) (resultCursor, error) {
+	var c resultCursor
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(raw, &c) != nil || c.Job != jobID || c.Size < 0 {
+		return resultCursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// page 按任务序号取出快照中序号大于 c.After 的前 size 个结果，还有剩余时返回下一页的游标
+func (c resultCursor) page(snapshot []TaskResult, size int) ([]TaskResult, string) {
+	rest := make([]TaskResult, 0, len(snapshot))
+	for _, result := range snapshot {
+		if result.ID > c.After {
+			rest = append(rest, result)
+		}
+	}
+	sort.Slice(rest, func(a, b int) bool { return rest[a].ID < rest[b].ID })
+	if len(rest) <= size {
+		return rest, ""
+	}
+	next := c
+	next.After = rest[size-1].ID
+	return rest[:size], next.encode()
+}
+
+// FinalResultPage 分页查询已结束任务的最终结果（如从产出物读取的结果），cursor 为空时从第一页开始；
+// 执行中取得的游标在任务不在内存中时无法还原快照，返回 ErrCursorExpired
+func FinalResultPage(info JobInfo, result *BatchResult, cursor string, size int) (*ResultPage, error) {
+	c := resultCursor{Job: info.ID, Size: len(result.Results), After: -1, Final: true}
+	if info.EndTime != nil {
+		c.At = info.EndTime.UnixNano()
+	}
+	if cursor != "" {
+		var err error
+		if c, err = decodeCursor(info.ID, cursor); err != nil {
+			return nil, err
+		}
+		if !c.Final {
+			return nil, ErrCursorExpired
+		}
+	}
+	results, next := c.page(result.Results, size)
+	return &ResultPage{
+		Results:    results,
+		SnapshotAt: time.Unix(0, c.At),
+		Snapshot:   c.Size,
+		NextCursor: next,
+		Final:      true,
+	}, nil
+}
+
+// ResultPage 分页查询任务的结果，执行中的任务也可查询已结束的任务，size 为每页的结果数。
+// cursor 为空时以当前已结束的任务（任务已结束时为最终结果）建立快照并返回第一页，否则按游标在同一快照中翻页
+func (j *Job) ResultPage(cursor string, size int) (*ResultPage, error) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if j.discardResults {
+		return nil, ErrResultsNotRetained
+	}
+
+	var c resultCursor
+	if cursor == "" {
+		if j.result != nil {
+			return FinalResultPage(j.info, j.result, "", size)
+		}
+		c = resultCursor{Job: j.info.ID, Size: len(j.landedIDs), At: time.Now().UnixNano(), After: -1}
+	} else {
+		var err error
+		if c, err = decodeCursor(j.info.ID, cursor); err != nil {
+			return nil, err
+		}
+		if c.Final {
+			if j.result == nil {
+				return nil, ErrInvalidCursor
+			}
+			return FinalResultPage(j.info, j.result, cursor, size)
+		}
+		if c.Size > len(j.landedIDs) {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	snapshot, newer := j.snapshot(c.Size)
+	results, next := c.page(snapshot, size)
+	return &ResultPage{
+		Results:    results,
+		SnapshotAt: time.Unix(0, c.At),
+		Snapshot:   c.Size,
+		NextCursor: next,
+		HasNewer:   newer,
+	}, nil
+}
+
+// snapshot 返回按结束顺序的前 n 个结果，以及快照之外是否还有结果；调用方持有 j.mu。
+// 任务结束后内存中只保留结束顺序中的任务序号，快照中的结果取自最终结果
+func (j *Job) snapshot(n int) ([]TaskResult, bool) {
+	if j.result == nil {
+		return j.landed[:n], len(j.landedIDs) > n
+	}
+	final := make(map[int]TaskResult, len(j.result.Results))
+	for _, result := range j.result.Results {
+		final[result.ID] = result
+	}
+	snapshot := make([]TaskResult, 0, n)
+	for _, id := range j.landedIDs[:n] {
+		if result, ok := final[id]; ok {
+			snapshot = append(snapshot, result)
+		}
+	}
+	return snapshot, len(j.result.Results) > n
+}
+
+// DiscardResults 不保留已结束的任务结果（结果逐个返回后即丢弃的批次），之后分页查询返回 ErrResultsNotRetained；
+// 应在开始执行任务前调用
+func (j *Job) DiscardResults() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.discardResults = true
+	j.landed, j.landedIDs = nil, nil
+}
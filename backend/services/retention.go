@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// RetentionJanitor 清理过期的批次结果，有两种保留策略：
+//   - 批次模板的保留策略：由模板执行的批次，明细（产出物、超出大小限制的完整结果、调试包）超过模板的 DetailDays 后删除，
+//     任务记录（汇总计数、配置快照、指标和订单汇总）超过 SummaryDays 后连同剩余的明细一起删除。按执行时关联的模板ID清理，
+//     修改模板的保留天数对已执行的批次同样生效
+//   - 结果的保留时长（JobManager.ResultTTL）：其余批次的明细在任务结束时记录的过期时间（expires_at）之后删除，任务记录保留。
+//     同时从内存中移除过期的任务，之后不能再获取其结果
+//
+// 未过期的已结束任务同样按 JobManager.FinishedTTL、MaxFinished 从内存中移除，之后从任务记录和产出物读取
+type RetentionJanitor struct {
+	DB         *gorm.DB
+	Locks      *models.LockManager // 为nil时不加锁，仅适用于单实例部署
+	Artifacts  *ArtifactService
+	Jobs       *JobManager // 从内存中移除结果过期的任务，为nil时不移除
+	DetailDirs []string    // 按任务ID分子目录保存明细的目录，如 ResultLimit.Dir、DebugCapture.Dir
+}
+
+// RetentionReport 一次清理的结果
+type RetentionReport struct {
+	Templates int `json:"templates"` // 设置了保留策略的模板数
+	Details   int `json:"details"`   // 按模板的保留策略删除明细的批次数
+	Summaries int `json:"summaries"` // 删除任务记录的批次数
+	Expired   int `json:"expired"`   // 结果过期、删除明细的批次数
+	Evicted   int `json:"evicted"`   // 从本实例内存中移除的已结束任务数
+}
+
+// DetailRetention 模板保留策略中明细的保留时间：DetailDays，未设置时明细与任务记录一同在 SummaryDays 后删除；
+// 都未设置时返回0
+func DetailRetention(template models.BatchTemplate) time.Duration {
+	days := template.DetailDays
+	if days == 0 {
+		days = template.SummaryDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// expiresAt 任务在 end 结束时结果的过期时间：模板设置了保留策略时按模板，否则按 ttl，都未设置时返回nil
+func (j *Job) expiresAt(end time.Time, ttl time.Duration) *time.Time {
+	if j.retention > 0 {
+		ttl = j.retention
+	}
+	if ttl <= 0 {
+		return nil
+	}
+	expires := end.Add(ttl)
+	return &expires
+}
+
+// 结束的任务在内存中保留的默认时长和数量。任务记录和产出物已经保存，超出后从内存中移除，
+// 之后获取任务和结果改为读取任务记录和产出物，进程长时间运行时内存不随已结束的任务增长
+const (
+	DefaultFinishedTTL = time.Hour
+	DefaultMaxFinished = 1000
+)
+
+// EvictExpired 从内存中移除已结束的任务，返回移除的任务数：结果在 now 之前过期的任务，
+// 结束超过 FinishedTTL 的任务，以及超出 MaxFinished 时最早结束的任务。
+// 登记时数据库不可用（degraded）的任务只在内存中保存，不按后两者移除
+func (m *JobManager) EvictExpired(now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	evicted := 0
+	var finished []JobInfo
+	for id, job := range m.jobs {
+		info := job.Info()
+		if info.EndTime == nil {
+			continue
+		}
+		expired := info.ExpiresAt != nil && info.ExpiresAt.Before(now)
+		stale := !info.Degraded && m.FinishedTTL > 0 && info.EndTime.Add(m.FinishedTTL).Before(now)
+		if expired || stale {
+			delete(m.jobs, id)
+			evicted++
+		} else if !info.Degraded {
+			finished = append(finished, info)
+		}
+	}
+
+	if m.MaxFinished > 0 && len(finished) > m.MaxFinished {
+		sort.Slice(finished, func(a, b int) bool { return finished[a].EndTime.Before(*finished[b].EndTime) })
+		for _, info := range finished[:len(finished)-m.MaxFinished] {
+			delete(m.jobs, info.ID)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Sweep 清理超过保留时间的任务记录和明细，now 为判断过期的当前时间。
+// 内存中的过期任务总是移除；数据库和明细的清理在多实例部署时同一时间只有一个实例执行，锁被占用时返回 models.ErrLockHeld
+func (j *RetentionJanitor) Sweep(now time.Time) (*RetentionReport, error) {
+	report := &RetentionReport{}
+	if j.Jobs != nil {
+		report.Evicted = j.Jobs.EvictExpired(now)
+	}
+	if j.Locks != nil {
+		release, err := j.Locks.TryLock(context.Background(), models.LockRetention)
+		if err != nil {
+			return report, err
+		}
+		defer release()
+	}
+
+	n, err := j.sweepExpired(now)
+	report.Expired = n
+	if err != nil {
+		return report, fmt.Errorf("清理过期的结果失败: %w", err)
+	}
+
+	var templates []models.BatchTemplate
+	if err := j.DB.Where("summary_days > 0 OR detail_days > 0").Find(&templates).Error; err != nil {
+		return report, err
+	}
+	report.Templates = len(templates)
+	for _, template := range templates {
+		if template.DetailDays > 0 {
+			n, err := j.sweepDetails(template.ID, now.AddDate(0, 0, -template.DetailDays))
+			report.Details += n
+			if err != nil {
+				return report, fmt.Errorf("清理模板 %d 的明细失败: %w", template.ID, err)
+			}
+		}
+		if template.SummaryDays > 0 {
+			n, err := j.sweepSummaries(template.ID, now.AddDate(0, 0, -template.SummaryDays))
+			report.Summaries += n
+			if err != nil {
+				return report, fmt.Errorf("清理模板 %d 的任务记录失败: %w", template.ID, err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// sweepExpired 删除过期时间在 now 之前的明细，返回清理的批次数；
+// 模板设置了保留策略的批次由 sweepDetails、sweepSummaries 按模板当前的保留天数清理
+func (j *RetentionJanitor) sweepExpired(now time.Time) (int, error) {
+	var jobIDs []string
+	err := j.DB.Model(&models.JobArtifact{}).
+		Where("expires_at < ?", now).
+		Where("template_id NOT IN (?)", j.DB.Model(&models.BatchTemplate{}).Select("id").Where("summary_days > 0 OR detail_days > 0")).
+		Pluck("job_id", &jobIDs).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, jobID := range jobIDs {
+		if err := j.deleteDetails(jobID); err != nil {
+			return i, err
+		}
+	}
+	return len(jobIDs), nil
+}
+
+// sweepDetails 删除模板在 cutoff 之前保存的产出物及同一批次的其他明细，返回清理的批次数
+func (j *RetentionJanitor) sweepDetails(templateID uint, cutoff time.Time) (int, error) {
+	var jobIDs []string
+	err := j.DB.Model(&models.JobArtifact{}).Where("template_id = ? AND created_at < ?", templateID, cutoff).
+		Pluck("job_id", &jobIDs).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, jobID := range jobIDs {
+		if err := j.deleteDetails(jobID); err != nil {
+			return i, err
+		}
+	}
+	return len(jobIDs), nil
+}
+
+// sweepSummaries 删除模板在 cutoff 之前结束的批次的任务记录和剩余的明细，返回清理的批次数
+func (j *RetentionJanitor) sweepSummaries(templateID uint, cutoff time.Time) (int, error) {
+	var jobIDs []string
+	err := j.DB.Model(&models.BatchJobResult{}).Where("template_id = ? AND end_time < ?", templateID, cutoff).
+		Pluck("job_id", &jobIDs).Error
+	if err != nil {
+		return 0, err
+	}
+	for i, jobID := range jobIDs {
+		if err := j.deleteDetails(jobID); err != nil {
+			return i, err
+		}
+		err := j.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("job_id = ?", jobID).Delete(&models.OrderBatchRollup{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("job_id = ?", jobID).Delete(&models.JobTransition{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("job_id = ?", jobID).Delete(&models.Order{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("job_id = ?", jobID).Delete(&models.APICall{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("job_id = ?", jobID).Delete(&models.BatchJobResult{}).Error
+		})
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(jobIDs), nil
+}
+
+// deleteDetails 删除批次的产出物和各明细目录下以任务ID命名的子目录
+func (j *RetentionJanitor) deleteDetails(jobID string) error {
+	if err := j.Artifacts.Delete(jobID); err != nil {
+		return err
+	}
+	for _, dir := range j.DetailDirs {
+		if err := os.RemoveAll(filepath.Join(dir, jobID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run 定期清理过期的任务记录和明细，直到上下文取消
+func (j *RetentionJanitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			report, err := j.Sweep(time.Now())
+			if errors.Is(err, models.ErrLockHeld) {
+				// 其他实例正在清理
+				continue
+			}
+			if err != nil {
+				log.Printf("按保留策略清理失败: %v", err)
+			}
+			if report != nil && report.Details+report.Summaries > 0 {
+				log.Printf("按保留策略清理了 %d 个批次的明细、%d 个批次的任务记录", report.Details, report.Summaries)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
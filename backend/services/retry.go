@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy 任务重试策略
+type RetryPolicy struct {
+	MaxAttempts int              // 最大尝试次数（含首次执行），小于等于1表示不重试
+	Backoff     time.Duration    // 首次失败后重试前的等待时间
+	Multiplier  float64          // 每次重试等待时间的增长倍数，小于等于1时固定为 Backoff
+	MaxBackoff  time.Duration    // 单次等待的上限，0表示不限制
+	Jitter      float64          // 随机抖动比例（0-1），等待时间在 [d*(1-Jitter), d] 间随机，避免大量任务同时重试
+	Retryable   func(error) bool // 判断错误是否值得重试，为nil时所有错误都重试
+	// Budget 批次的重试预算：整个批次最多重试 ceil(Budget × 任务数) 次，用完后失败的任务不再重试，0表示不限制。
+	// 下游整体故障时所有任务都会失败，预算避免重试把压力放大数倍，让批次尽快失败
+	Budget float64
+}
+
+// Enabled 是否启用了重试
+func (p *RetryPolicy) Enabled() bool {
+	return p != nil && p.MaxAttempts > 1
+}
+
+// Delay 返回第 attempt 次尝试失败后的等待时间（attempt 从1开始）
+func (p *RetryPolicy) Delay(attempt int) time.Duration {
+	return p.delay(attempt, rand.Float64)
+}
+
+// delay 以 random 生成抖动计算等待时间
+func (p *RetryPolicy) delay(attempt int, random func() float64) time.Duration {
+	d := float64(p.Backoff)
+	if p.Multiplier > 1 {
+		d *= math.Pow(p.Multiplier, float64(attempt-1))
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		d -= d * math.Min(p.Jitter, 1) * random()
+	}
+	return time.Duration(d)
+}
+
+// retryable 错误是否值得重试：BusinessError 从不重试，InfraError 总是重试，其余按 Retryable 判断
+func (p *RetryPolicy) retryable(err error) bool {
+	var business *BusinessError
+	if errors.As(err, &business) {
+		return false
+	}
+	var infra *InfraError
+	if errors.As(err, &infra) {
+		return true
+	}
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// ErrRetryBudgetExhausted 批次的重试预算已用完
+var ErrRetryBudgetExhausted = errors.New("批次重试预算已用完，不再重试")
+
+// retryBudget 批次已使用的重试次数
+type retryBudget struct {
+	total int // 批次的任务数
+	used  atomic.Int64
+}
+
+type retryBudgetKey struct{}
+
+// withRetryBudget 为 totalTasks 个任务的批次附加重试预算的计数，未附加时重试不受预算限制
+func withRetryBudget(ctx context.Context, totalTasks int) context.Context {
+	return context.WithValue(ctx, retryBudgetKey{}, &retryBudget{total: totalTasks})
+}
+
+// takeRetry 从 ctx 所属批次的预算中取出一次重试，预算已用完时返回 false
+func takeRetry(ctx context.Context, ratio float64) bool {
+	b, _ := ctx.Value(retryBudgetKey{}).(*retryBudget)
+	if b == nil || ratio <= 0 {
+		return true
+	}
+	limit := int64(math.Ceil(ratio * float64(b.total)))
+	return b.used.Add(1) <= limit
+}
+
+// HTTPStatusError 上游返回了表示暂时不可用的状态码
+type HTTPStatusError struct {
+	StatusCode int
+	Header     http.Header // 响应头和响应体保存在失败任务的调试包中
+	Body       []byte
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("上游暂时不可用: HTTP %d", e.StatusCode)
+}
+
+// transientStatus 视为暂时性失败的HTTP状态码
+func transientStatus(code int) bool {
+	switch code {
+	case 408, 429, 502, 503, 504:
+		return true
+	}
+	return false
+}
+
+// RetryTransient 只重试暂时性错误：上游暂时不可用的状态码、网络超时和连接被拒绝/重置、
+// 文件被锁定或占用；参数错误、文件不存在等重试也不会成功的错误不重试
+func RetryTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// 解析失败的结果在缓存期内不会变化，重试没有意义
+	var resolveErr *ResolveError
+	if errors.As(err, &resolveErr) {
+		return false
+	}
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.ETXTBSY)
+}
+
+// idempotentRequest 请求是否可以安全地重复发送：GET、HEAD、OPTIONS、TRACE，或带有 Idempotency-Key/X-Idempotency-Key 请求头，
+// 与 net/http 判断请求能否重放的规则一致；方法为空时按 GET 发送
+func idempotentRequest(method string, headers map[string]string) bool {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	for key := range headers {
+		if key := http.CanonicalHeaderKey(key); key == "Idempotency-Key" || key == "X-Idempotency-Key" {
+			return true
+		}
+	}
+	return false
+}
+
+// requestNotSent 请求是否在发出前失败：连接被拒绝或建立连接失败，上游没有收到请求
+func requestNotSent(err error) bool {
+	var opErr *net.OpError
+	return errors.Is(err, syscall.ECONNREFUSED) || (errors.As(err, &opErr) && opErr.Op == "dial")
+}
+
+// TaskAttempt 单次尝试记录
+type TaskAttempt struct {
+	Attempt   int       `json:"attempt"`
+	StartTime time.Time `json:"start_time"`
+	Duration  int64     `json:"duration"` // 毫秒
+	Error     string    `json:"error,omitempty"`
+	Backoff   int64     `json:"backoff"` // 本次失败后等待的毫秒数
+}
+
+// runWithRetry 按重试策略执行任务，启用重试时返回每次尝试的记录
+// fn 收到的 ctx 即传入的 ctx，任务应在 ctx 结束时尽快返回；ctx 结束后、错误不可重试或批次的重试预算用完时不再重试。
+// key 标识任务，等待时间的抖动由批次种子和 key 确定，相同种子下可以复现
+func runWithRetry(ctx context.Context, policy *RetryPolicy, key string, fn func(context.Context) (interface{}, error)) (interface{}, []TaskAttempt, error) {
+	if !policy.Enabled() {
+		data, err := fn(ctx)
+		return data, nil, err
+	}
+
+	var attempts []TaskAttempt
+	var rng *rand.Rand
+	for attempt := 1; ; attempt++ {
+		Heartbeat(ctx)
+		record := TaskAttempt{
+			Attempt:   attempt,
+			StartTime: time.Now(),
+		}
+
+		data, err := fn(ctx)
+		record.Duration = time.Since(record.StartTime).Milliseconds()
+		if err == nil {
+			attempts = append(attempts, record)
+			return data, attempts, nil
+		}
+		record.Error = err.Error()
+
+		if attempt >= policy.MaxAttempts || ctx.Err() != nil || !policy.retryable(err) {
+			attempts = append(attempts, record)
+			return nil, attempts, err
+		}
+		if !takeRetry(ctx, policy.Budget) {
+			attempts = append(attempts, record)
+			return nil, attempts, fmt.Errorf("%w（%w）", err, ErrRetryBudgetExhausted)
+		}
+
+		// 等待后重试，期间任务被取消则直接返回
+		if rng == nil {
+			rng = taskRand(ctx, key)
+		}
+		delay := policy.delay(attempt, rng.Float64)
+		record.Backoff = delay.Milliseconds()
+		attempts = append(attempts, record)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, attempts, fmt.Errorf("%v（重试已中止: %v）", err, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLimitExceeded 请求的并发数或批次超时超出服务端的上限
+var ErrLimitExceeded = errors.New("超出服务端的上限")
+
+// RunLimits 单个批次的并发数和批次超时：作为请求的覆盖时，0 表示按服务的配置；作为服务端的上限时，0 表示不允许覆盖
+type RunLimits struct {
+	MaxConcurrency int
+	Timeout        time.Duration
+}
+
+// Check 检查请求的覆盖是否在上限 ceiling 内
+func (l RunLimits) Check(ceiling RunLimits) error {
+	if l.MaxConcurrency > ceiling.MaxConcurrency {
+		return fmt.Errorf("%w: max_concurrency 最大为 %d", ErrLimitExceeded, ceiling.MaxConcurrency)
+	}
+	if l.Timeout > ceiling.Timeout {
+		return fmt.Errorf("%w: timeout_ms 最大为 %d", ErrLimitExceeded, ceiling.Timeout.Milliseconds())
+	}
+	return nil
+}
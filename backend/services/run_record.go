@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// CodeVersion 当前运行的代码版本，可在构建时通过
+// -ldflags "-X concurrency-web-app/backend/services.CodeVersion=v1.2.3" 指定；
+// 未指定时取构建信息中的 VCS 修订号（有未提交的修改时附加 -dirty），都没有时为 unknown
+var CodeVersion string
+
+var codeVersionOnce sync.Once
+
+// currentCodeVersion 返回 CodeVersion，未指定时从构建信息中读取
+func currentCodeVersion() string {
+	codeVersionOnce.Do(func() {
+		if CodeVersion != "" {
+			return
+		}
+		CodeVersion = "unknown"
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		var revision, modified string
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value
+			}
+		}
+		switch {
+		case revision != "" && modified == "true":
+			CodeVersion = revision + "-dirty"
+		case revision != "":
+			CodeVersion = revision
+		case info.Main.Version != "" && info.Main.Version != "(devel)":
+			CodeVersion = info.Main.Version
+		}
+	})
+	return CodeVersion
+}
+
+// RunRecord 复现一次批次所需的信息：随机种子、执行时的配置快照和代码版本
+// 以相同的请求、种子和配置在同一代码版本上重新提交即可得到相同的抽样和重试等待时间；
+// 并发调度的先后顺序取决于运行时，不在复现范围内
+type RunRecord struct {
+	Seed        int64                  `json:"seed"`
+	Config      map[string]interface{} `json:"config,omitempty"`
+	CodeVersion string                 `json:"code_version"`
+}
+
+// NewRunRecord 以 seed 和配置快照创建记录，seed 为0时随机生成；
+// config 按 JSON 序列化后保存，便于原样写入任务记录
+func NewRunRecord(seed int64, config interface{}) *RunRecord {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	record := &RunRecord{Seed: seed, CodeVersion: currentCodeVersion()}
+	if raw, err := json.Marshal(config); err == nil {
+		json.Unmarshal(raw, &record.Config)
+	}
+	return record
+}
+
+// taskRand 返回由批次种子和任务标识确定的随机数生成器，同一种子下同一任务得到相同的随机序列；
+// ctx 中的任务没有记录种子时按当前时间生成
+func taskRand(ctx context.Context, key string) *rand.Rand {
+	seed := time.Now().UnixNano()
+	if job := JobFromContext(ctx); job != nil {
+		if run := job.Info().Run; run != nil {
+			h := fnv.New64a()
+			h.Write([]byte(key))
+			seed = run.Seed ^ int64(h.Sum64())
+		}
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
+// RunConfig 订单批次的配置快照
+func (s *OrderProcessService) RunConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"mode":             s.Mode.String(),
+		"max_concurrency":  s.MaxConcurrency,
+		"timeout":          s.Timeout.String(),
+		"per_task_timeout": s.PerTaskTimeout.String(),
+		"retry":            retryConfig(s.Retry),
+		"rate_limit":       rateConfig(s.RateLimit),
+		"pools":            poolConfig(s.Pools),
+	}
+}
+
+// RunConfig API调用批次的配置快照
+func (s *APICallService) RunConfig() map[string]interface{} {
+	config := map[string]interface{}{
+		"mode":            s.Mode.String(),
+		"max_concurrency": s.MaxConcurrency,
+		"timeouts": map[string]string{
+			"connect": s.Timeouts.Connect.String(),
+			"request": s.Timeouts.Request.String(),
+			"task":    s.Timeouts.Task.String(),
+			"batch":   s.Timeouts.Batch.String(),
+		},
+		"retry":      retryConfig(s.Retry),
+		"rate_limit": rateConfig(s.RateLimit),
+		"pools":      poolConfig(s.Pools),
+	}
+	if s.Ramp != nil {
+		config["ramp"] = map[string]interface{}{"start": s.Ramp.Start, "duration": s.Ramp.Duration.String()}
+	}
+	if s.Throttle != nil {
+		// 记录开始时已被降低并发的主机，便于对比批次的失败是否与下游状态有关
+		config["host_throttle"] = map[string]interface{}{
+			"max_concurrency": s.Throttle.MaxConcurrency,
+			"failure_rate":    floatOr(s.Throttle.FailureRate, defaultThrottleFailureRate),
+			"limits":          s.Throttle.Limits(),
+		}
+	}
+	return config
+}
+
+// RunConfig 文件批次的配置快照
+func (s *FileProcessService) RunConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"mode":                 s.Mode.String(),
+		"max_concurrency":      s.MaxConcurrency,
+		"timeout":              s.Timeout.String(),
+		"per_task_timeout":     s.PerTaskTimeout.String(),
+		"retry":                retryConfig(s.Retry),
+		"rate_limit":           rateConfig(s.RateLimit),
+		"pools":                poolConfig(s.Pools),
+		"hash_io_concurrency":  s.HashIOConcurrency,
+		"hash_cpu_concurrency": s.HashCPUConcurrency,
+	}
+}
+
+// retryConfig 重试策略的配置快照，未启用重试时为nil
+func retryConfig(p *RetryPolicy) map[string]interface{} {
+	if !p.Enabled() {
+		return nil
+	}
+	return map[string]interface{}{
+		"max_attempts": p.MaxAttempts,
+		"backoff":      p.Backoff.String(),
+		"multiplier":   p.Multiplier,
+		"max_backoff":  p.MaxBackoff.String(),
+		"jitter":       p.Jitter,
+		"budget":       p.Budget,
+	}
+}
+
+// poolConfig 工作池的配置快照，未配置工作池时为nil
+func poolConfig(p *WorkerPools) map[string]interface{} {
+	if p == nil {
+		return nil
+	}
+	sizes := make(map[string]int, len(p.pools))
+	for name, pool := range p.pools {
+		sizes[name] = pool.Size
+	}
+	return map[string]interface{}{"sizes": sizes, "rules": p.rules}
+}
+
+// rateConfig 限速的配置快照，不限速时为nil
+func rateConfig(l *batch.RateLimiter) map[string]interface{} {
+	if l == nil {
+		return nil
+	}
+	return map[string]interface{}{"rate": l.Rate(), "burst": l.Burst()}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// TaskSample 抽样执行的任务子集，记录抽中任务在原批次中的序号，用于还原结果序号并外推全量估算
+// 方法对 nil 安全：未抽样时原样返回
+type TaskSample struct {
+	Rate    float64
+	Seed    int64
+	Total   int   // 提交的任务数
+	indices []int // 抽中任务在原批次中的序号
+}
+
+// SampleEstimate 按抽样比例外推的全量估算
+type SampleEstimate struct {
+	Rate               float64 `json:"rate"`
+	Seed               int64   `json:"seed"` // 使用相同的种子可重现同一批抽样
+	SubmittedTasks     int     `json:"submitted_tasks"`
+	SampledTasks       int     `json:"sampled_tasks"`
+	EstimatedSuccess   int     `json:"estimated_success_tasks"`
+	EstimatedFailed    int     `json:"estimated_failed_tasks"`
+	EstimatedCancelled int     `json:"estimated_cancelled_tasks"`
+	EstimatedDuration  int64   `json:"estimated_duration"` // 毫秒，按相同并发数线性外推
+}
+
+// SampleTasks 按 rate 随机抽取任务，rate 不在 (0, 1) 内时不抽样，返回全部任务和 nil
+// seed 为0时随机生成，实际使用的种子记录在返回值中；任务非空时至少抽中一个
+func SampleTasks[T any](tasks []T, rate float64, seed int64) ([]T, *TaskSample) {
+	if rate <= 0 || rate >= 1 || len(tasks) == 0 {
+		return tasks, nil
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	sample := &TaskSample{Rate: rate, Seed: seed, Total: len(tasks)}
+	var sampled []T
+	for i, task := range tasks {
+		if rng.Float64() < rate {
+			sample.indices = append(sample.indices, i)
+			sampled = append(sampled, task)
+		}
+	}
+	if len(sampled) == 0 {
+		i := rng.Intn(len(tasks))
+		sample.indices = []int{i}
+		sampled = []T{tasks[i]}
+	}
+	return sampled, sample
+}
+
+// Remap 将结果序号从抽样子集内的序号还原为原批次中的序号
+func (s *TaskSample) Remap(result TaskResult) TaskResult {
+	if s != nil && result.ID >= 0 && result.ID < len(s.indices) {
+		result.ID = s.indices[result.ID]
+	}
+	return result
+}
+
+// Apply 还原结果序号并附上全量估算；BatchResult 中的计数仍为实际执行的抽样任务
+func (s *TaskSample) Apply(result *BatchResult) *BatchResult {
+	if s == nil {
+		return result
+	}
+	for i := range result.Results {
+		result.Results[i] = s.Remap(result.Results[i])
+	}
+
+	scale := float64(s.Total) / float64(len(s.indices))
+	extrapolate := func(n int) int { return int(math.Round(float64(n) * scale)) }
+	result.Sample = &SampleEstimate{
+		Rate:               s.Rate,
+		Seed:               s.Seed,
+		SubmittedTasks:     s.Total,
+		SampledTasks:       len(s.indices),
+		EstimatedSuccess:   extrapolate(result.SuccessTasks),
+		EstimatedFailed:    extrapolate(result.FailedTasks),
+		EstimatedCancelled: extrapolate(result.CancelledTasks),
+		EstimatedDuration:  int64(math.Round(float64(result.Duration) * scale)),
+	}
+	return result
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// SelfTest 启动自检：检查数据库和存储目录的读写，并以小批量执行各类任务，
+// 用于部署后的冒烟测试和容器的健康检查。服务为nil的检查跳过
+type SelfTest struct {
+	DB      *gorm.DB
+	ReadDB  *gorm.DB // 只读副本，为nil或与 DB 相同时不单独检查
+	Dirs    []string // 需要读写的存储目录（上传、产出物、归档等），不存在时创建
+	Orders  *OrderProcessService
+	APIs    *APICallService
+	Files   *FileProcessService
+	Timeout time.Duration // 全部检查的超时时间，默认30秒
+}
+
+// SelfTestCheck 单项检查的结果
+type SelfTestCheck struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration"` // 毫秒
+}
+
+// SelfTestReport 自检结果，任一检查失败时 OK 为false
+type SelfTestReport struct {
+	OK     bool            `json:"ok"`
+	Checks []SelfTestCheck `json:"checks"`
+}
+
+// errSelfTestRollback 数据库检查写入测试记录后回滚事务
+var errSelfTestRollback = errors.New("selftest rollback")
+
+// Run 依次执行各项检查，某项失败不影响其余检查
+func (t *SelfTest) Run(ctx context.Context) *SelfTestReport {
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	report := &SelfTestReport{OK: true}
+	check := func(name string, run func(context.Context) error) {
+		start := time.Now()
+		err := run(ctx)
+		result := SelfTestCheck{Name: name, OK: err == nil, Duration: time.Since(start).Milliseconds()}
+		if err != nil {
+			result.Error = err.Error()
+			report.OK = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+
+	if t.DB != nil {
+		check("database", t.checkDB)
+		if t.ReadDB != nil && t.ReadDB != t.DB {
+			check("read_replica", func(ctx context.Context) error { return pingDB(ctx, t.ReadDB) })
+		}
+	}
+	for _, dir := range t.Dirs {
+		dir := dir
+		check("storage:"+dir, func(context.Context) error { return checkDir(dir) })
+	}
+	if t.Orders != nil {
+		check("orders", t.checkOrders)
+	}
+	if t.APIs != nil {
+		check("api_calls", t.checkAPIs)
+	}
+	if t.Files != nil {
+		check("files", t.checkFiles)
+	}
+	return report
+}
+
+// pingDB 检查数据库连接
+func pingDB(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// checkDB 检查主库的连接，并在回滚的事务中写入和读取一条任务记录
+func (t *SelfTest) checkDB(ctx context.Context) error {
+	if err := pingDB(ctx, t.DB); err != nil {
+		return fmt.Errorf("连接数据库失败: %w", err)
+	}
+	jobID := fmt.Sprintf("selftest_%d", time.Now().UnixNano())
+	err := t.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.BatchJobResult{JobID: jobID, JobType: "selftest", StartTime: time.Now()}).Error; err != nil {
+			return fmt.Errorf("写入失败: %w", err)
+		}
+		var count int64
+		if err := tx.Model(&models.BatchJobResult{}).Where("job_id = ?", jobID).Count(&count).Error; err != nil {
+			return fmt.Errorf("读取失败: %w", err)
+		}
+		if count != 1 {
+			return fmt.Errorf("读取到 %d 条测试记录", count)
+		}
+		return errSelfTestRollback
+	})
+	if errors.Is(err, errSelfTestRollback) {
+		return nil
+	}
+	return err
+}
+
+// checkDir 在目录中写入、读回并删除一个临时文件
+func checkDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %w", err)
+	}
+	file, err := os.CreateTemp(dir, ".selftest-*")
+	if err != nil {
+		return fmt.Errorf("创建文件失败: %w", err)
+	}
+	path := file.Name()
+	defer os.Remove(path)
+
+	payload := []byte("selftest " + time.Now().Format(time.RFC3339Nano))
+	_, err = file.Write(payload)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入文件失败: %w", err)
+	}
+	read, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %w", err)
+	}
+	if !bytes.Equal(read, payload) {
+		return errors.New("读回的内容与写入的不一致")
+	}
+	return nil
+}
+
+// checkBatch 批次中全部任务成功时返回nil，否则返回首个失败任务的错误
+func checkBatch(result *BatchResult) error {
+	if result.SuccessTasks == result.TotalTasks {
+		return nil
+	}
+	for _, r := range result.Results {
+		if !r.Success {
+			return fmt.Errorf("%d/%d 个任务失败，任务 %d: %s", result.TotalTasks-result.SuccessTasks, result.TotalTasks, r.ID, r.Error)
+		}
+	}
+	return fmt.Errorf("%d/%d 个任务失败", result.TotalTasks-result.SuccessTasks, result.TotalTasks)
+}
+
+// checkOrders 执行一个小的订单批次，订单序号避开模拟失败的7的倍数
+func (t *SelfTest) checkOrders(ctx context.Context) error {
+	orders := []OrderTask{
+		{ID: 1, CustomerID: "selftest", ProductName: "selftest", Quantity: 1, Price: 1},
+		{ID: 2, CustomerID: "selftest", ProductName: "selftest", Quantity: 2, Price: 1},
+		{ID: 3, CustomerID: "selftest", ProductName: "selftest", Quantity: 3, Price: 1},
+	}
+	return checkBatch(t.Orders.BatchProcessOrders(ctx, orders))
+}
+
+// checkAPIs 调用本机临时启动的模拟接口，不依赖外部网络
+func (t *SelfTest) checkAPIs(ctx context.Context) error {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"selftest":true,"method":%q}`, r.Method)
+	}))
+	defer server.Close()
+
+	tasks := []APICallTask{
+		{ID: 0, URL: server.URL + "/get", Method: http.MethodGet},
+		{ID: 1, URL: server.URL + "/post", Method: http.MethodPost, Body: `{"selftest":true}`,
+			Headers: map[string]string{"Content-Type": "application/json"}},
+	}
+	return checkBatch(t.APIs.BatchCallAPIs(ctx, tasks))
+}
+
+// checkFiles 在上传目录中创建临时文件，执行 info 和 hash 处理后删除
+func (t *SelfTest) checkFiles(ctx context.Context) error {
+	if err := os.MkdirAll(t.Files.UploadDir, 0755); err != nil {
+		return fmt.Errorf("创建上传目录失败: %w", err)
+	}
+	file, err := os.CreateTemp(t.Files.UploadDir, ".selftest-*.txt")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	path := file.Name()
+	defer os.Remove(path)
+	_, err = file.WriteString("concurrency web app selftest\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+
+	name := filepath.Base(path)
+	tasks := []FileTask{
+		{ID: 0, FilePath: path, FileName: name, ProcessType: "info"},
+		{ID: 1, FilePath: path, FileName: name, ProcessType: "hash"},
+	}
+	return checkBatch(t.Files.BatchProcessFiles(ctx, tasks))
+}
@@ -0,0 +1,22 @@
+package services
+
+// TaskEnv 执行任务的环境，多实例部署时据此将慢任务或失败任务定位到具体的节点和工作池
+type TaskEnv struct {
+	Instance string `json:"instance"`       // 执行任务的服务实例，见 InstanceID
+	Pool     string `json:"pool,omitempty"` // 任务路由到的命名工作池，没有规则匹配时为空
+	Worker   int    `json:"worker"`         // 批次中的工作槽位，同 InflightTask.Slot
+	Attempt  int    `json:"attempt"`        // 最后一次尝试的序号，未重试时为 1
+}
+
+// newTaskEnv 返回在工作槽位 slot 上执行、按 taskType 和 host 路由到工作池的任务的执行环境
+func newTaskEnv(pools *WorkerPools, taskType, host string, slot int, attempts []TaskAttempt) *TaskEnv {
+	env := &TaskEnv{
+		Instance: currentInstance(),
+		Worker:   slot,
+		Attempt:  max(len(attempts), 1),
+	}
+	if pool := pools.Route(taskType, host); pool != nil {
+		env.Pool = pool.Name
+	}
+	return env
+}
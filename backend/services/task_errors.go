@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+)
+
+// 任务失败的错误分类，记录在 TaskResult.ErrorClass
+const (
+	ErrorClassBusiness = "business"       // 业务规则拒绝（如库存不足），重试也不会成功
+	ErrorClassInfra    = "infrastructure" // 网络、上游或存储的暂时性故障，可以重试
+)
+
+// BusinessError 任务处理器返回的永久性业务错误，不论重试策略如何都不重试
+type BusinessError struct {
+	Code   string                 // 业务错误码，如 out_of_stock，记录在 TaskResult.ErrorCode
+	Params map[string]interface{} // 错误码消息模板中的参数，记录在 TaskResult.ErrorParams
+	Err    error
+}
+
+func (e *BusinessError) Error() string { return e.Err.Error() }
+
+func (e *BusinessError) Unwrap() error { return e.Err }
+
+// NewBusinessError 以业务错误码包装错误
+func NewBusinessError(code string, err error) error {
+	return &BusinessError{Code: code, Err: err}
+}
+
+// InfraError 任务处理器返回的基础设施错误，启用重试时总是重试
+type InfraError struct {
+	Err error
+}
+
+func (e *InfraError) Error() string { return e.Err.Error() }
+
+func (e *InfraError) Unwrap() error { return e.Err }
+
+// NewInfraError 将错误标记为可重试的基础设施错误
+func NewInfraError(err error) error {
+	return &InfraError{Err: err}
+}
+
+// classifyError 返回错误的分类和业务错误码；被回收器取消的任务分类为 stalled，未包装的错误按 RetryTransient 判断是否为基础设施错误，
+// 两者都不是时分类为空
+func classifyError(err error) (class, code string) {
+	var stalled *TaskStalledError
+	if errors.As(err, &stalled) {
+		return ErrorClassStalled, ""
+	}
+	var business *BusinessError
+	if errors.As(err, &business) {
+		return ErrorClassBusiness, business.Code
+	}
+	var infra *InfraError
+	if errors.As(err, &infra) || RetryTransient(err) {
+		return ErrorClassInfra, ""
+	}
+	return "", ""
+}
+
+// classify 为未成功的任务记录错误码；只为失败的任务记录错误分类，超时、取消等其他状态不分类
+func (r *TaskResult) classify(ctx context.Context, err error) {
+	if r.Status == TaskStatusFailed {
+		r.ErrorClass, _ = classifyError(err)
+	}
+	r.ErrorCode, r.ErrorParams = errorCode(ctx, r.Status, err)
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// ErrorClassStalled 长时间没有心跳、被回收器强制取消的任务，TaskResult.ErrorClass 取该值
+const ErrorClassStalled = "stalled"
+
+// TaskStalledError 任务超过 Idle 没有心跳，被回收器强制取消
+type TaskStalledError struct {
+	Idle time.Duration
+	Err  error // 任务因取消而返回的错误，作为取消原因时为nil
+}
+
+func (e *TaskStalledError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("任务超过 %v 没有心跳，已被强制取消", e.Idle)
+	}
+	return fmt.Sprintf("任务超过 %v 没有心跳，已被强制取消: %v", e.Idle, e.Err)
+}
+
+func (e *TaskStalledError) Unwrap() error { return e.Err }
+
+// taskBeat 正在执行的子任务最近一次心跳的时间，回收器据此判断任务是否卡住
+type taskBeat struct {
+	last   atomic.Int64 // UnixNano
+	reaped atomic.Bool
+	cancel context.CancelCauseFunc
+}
+
+type taskBeatKey struct{}
+
+// Heartbeat 报告子任务仍在推进。开始执行、每次尝试、每次读取（文件、响应体）时自动发送；
+// 注册任务类型的 Execute 中长时间的处理应定期调用，否则超过 JobManager.StallTimeout 会被当作卡住的任务强制取消
+func Heartbeat(ctx context.Context) {
+	if beat, _ := ctx.Value(taskBeatKey{}).(*taskBeat); beat != nil {
+		beat.last.Store(time.Now().UnixNano())
+	}
+}
+
+// stalledError 子任务被回收器取消时将错误包装为 TaskStalledError，其他情况原样返回
+func stalledError(taskCtx context.Context, err error) error {
+	var stalled *TaskStalledError
+	if err != nil && !errors.As(err, &stalled) && errors.As(context.Cause(taskCtx), &stalled) {
+		return &TaskStalledError{Idle: stalled.Idle, Err: err}
+	}
+	return err
+}
+
+// ReapStalled 强制取消超过 StallTimeout 没有心跳的子任务（如阻塞在已断开的 TCP 连接上），返回取消的数量。
+// 任务的上下文以 TaskStalledError 取消，阻塞的读取或等待随之返回、释放工作槽位，任务记为失败，错误分类为 stalled。
+// 不检查上下文的任务函数无法被中止，只能等它自行返回。StallTimeout 为0时不检查
+func (m *JobManager) ReapStalled() int {
+	if m.StallTimeout <= 0 {
+		return 0
+	}
+	m.mu.RLock()
+	jobs := make([]*Job, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, job)
+	}
+	m.mu.RUnlock()
+
+	type stalledTask struct {
+		job   *Job
+		index int
+		beat  *taskBeat
+		idle  time.Duration
+	}
+	var stalled []stalledTask
+	now := time.Now()
+	for _, job := range jobs {
+		job.mu.RLock()
+		for index, task := range job.inflight {
+			if task.beat == nil {
+				continue
+			}
+			idle := now.Sub(time.Unix(0, task.beat.last.Load()))
+			if idle >= m.StallTimeout && task.beat.reaped.CompareAndSwap(false, true) {
+				stalled = append(stalled, stalledTask{job, index, task.beat, idle})
+			}
+		}
+		job.mu.RUnlock()
+	}
+
+	for _, s := range stalled {
+		idle := s.idle.Round(time.Millisecond)
+		log.Printf("[STALLED] job=%s task=%d: 超过 %v 没有心跳，强制取消", s.job.ID(), s.index, idle)
+		s.beat.cancel(&TaskStalledError{Idle: idle})
+	}
+	return len(stalled)
+}
+
+// RunReaper 每隔 interval 回收一次卡住的子任务，直到 ctx 结束；StallTimeout 为0时立即返回
+func (m *JobManager) RunReaper(ctx context.Context, interval time.Duration) {
+	if m.StallTimeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.ReapStalled()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// Task 由 TaskKind 从请求解析出的一个任务
+type Task interface {
+	// Execute 执行任务，返回的数据作为结果的 data；ctx 结束时应尽快返回
+	Execute(ctx context.Context) (interface{}, error)
+}
+
+// TaskKind 可注册的任务类型，注册后自动获得 POST /api/tasks/<name>/batch-process 接口，
+// 并可用于 WebSocket 流式批次的 job_type。任务实现 Priority() int 时按优先级调度
+type TaskKind interface {
+	// Name 任务类型名称，用作批次的 job_type 和接口路径，只能包含小写字母、数字和 -
+	Name() string
+	// Decode 解析并校验请求中的一个任务，返回的错误作为400响应
+	Decode(raw json.RawMessage) (Task, error)
+}
+
+var (
+	// ErrTaskKindExists 同名的任务类型已注册，或与内置的任务类型重名
+	ErrTaskKindExists = errors.New("任务类型已存在")
+	// ErrTaskKindNotFound 任务类型未注册
+	ErrTaskKindNotFound = errors.New("任务类型不存在")
+)
+
+// taskKindName 任务类型名称的格式
+var taskKindName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+
+// builtinKinds 内置服务使用的 job_type，注册的任务类型不能与之重名
+var builtinKinds = map[string]bool{
+	PoolTaskOrder: true, PoolTaskAPI: true, PoolTaskFile: true, PoolTaskPipeline: true,
+}
+
+// KindService 执行一种注册任务类型的批次，与内置服务共用调度、重试、超时、工作池和结果大小限制
+type KindService struct {
+	Kind           TaskKind
+	MaxConcurrency int
+	Mode           batch.Mode         // 调度方式，默认每个任务一个协程；大批次可用 batch.ModeWorkerPool
+	Timeout        time.Duration      // 整个批次的预算
+	PerTaskTimeout time.Duration      // 单个任务的预算（含重试和退避），为0时只受批次预算限制
+	Retry          *RetryPolicy       // 为nil时不重试
+	RateLimit      *batch.RateLimiter // 每秒开始执行的任务数上限；为nil时只受并发数限制
+	Pools          *WorkerPools       // 按任务类型名称路由到的命名工作池；为nil时不使用
+	ResultLimit    ResultLimit
+}
+
+// Decode 解析请求中的任务列表，错误中注明任务序号
+func (s *KindService) Decode(raws []json.RawMessage) ([]Task, error) {
+	tasks := make([]Task, len(raws))
+	for i, raw := range raws {
+		task, err := s.Kind.Decode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("任务 %d: %w", i, err)
+		}
+		tasks[i] = task
+	}
+	return tasks, nil
+}
+
+// runTask 执行单个任务
+func (s *KindService) runTask(ctx context.Context, index, slot int, task Task) (result TaskResult) {
+	taskStart := time.Now()
+	defer recoverTask(ctx, index, taskStart, &result)
+
+	// 检查是否已取消或超时
+	if result, ok := checkDispatch(ctx, index, taskStart); !ok {
+		return result
+	}
+	ctx, untrack := trackInflight(ctx, index, slot)
+	defer untrack()
+
+	taskCtx, cancel := withTaskTimeout(ctx, s.PerTaskTimeout)
+	defer cancel()
+	data, attempts, err := runWithRetry(taskCtx, s.Retry, strconv.Itoa(index), func(ctx context.Context) (interface{}, error) {
+		release, err := s.Pools.Acquire(ctx, s.Kind.Name(), "")
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return task.Execute(ctx)
+	})
+	err = taskTimeoutError(ctx, taskCtx, s.PerTaskTimeout, err)
+
+	result = TaskResult{
+		ID:       index,
+		Success:  err == nil,
+		Status:   TaskStatusSuccess,
+		Data:     s.ResultLimit.apply(ctx, index, data),
+		Duration: time.Since(taskStart).Milliseconds(),
+		Attempts: attempts,
+		Env:      newTaskEnv(s.Pools, s.Kind.Name(), "", slot, attempts),
+	}
+
+	if err != nil {
+		result.Status = failureStatus(taskCtx, err)
+		result.Error = err.Error()
+		result.classify(ctx, err)
+		captureFailure(ctx, result, task, err)
+	}
+
+	return result
+}
+
+// taskPriority 任务实现 Priority() int 时返回其优先级，否则为0
+func taskPriority(task Task) int {
+	if p, ok := task.(interface{ Priority() int }); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
+// processor 返回执行该类型任务的批处理引擎
+func (s *KindService) processor(ctx context.Context) *batch.Processor[Task, TaskResult] {
+	return &batch.Processor[Task, TaskResult]{
+		Mode:           s.Mode,
+		MaxConcurrency: jobMaxConcurrency(ctx, s.MaxConcurrency),
+		Timeout:        jobTimeout(ctx, s.Timeout),
+		Priority:       taskPriority,
+		Run:            s.runTask,
+		Err:            TaskResult.err,
+		RateLimit:      s.RateLimit,
+		Concurrency:    jobConcurrency(ctx),
+		Chunks:         jobChunks(ctx),
+		OnResult:       reportProgress,
+	}
+}
+
+// BatchProcess 批量执行任务
+func (s *KindService) BatchProcess(ctx context.Context, tasks []Task) *BatchResult {
+	startTime := time.Now()
+
+	ctx = withStartTracker(ctx, len(tasks))
+	ctx = withRetryBudget(ctx, len(tasks))
+	results := s.processor(ctx).Process(ctx, tasks)
+	return buildBatchResult(ctx, startTime, len(tasks), results)
+}
+
+// EachTask 批量执行任务，每完成一个任务调用 emit，不在内存中保留结果
+func (s *KindService) EachTask(ctx context.Context, tasks []Task, emit func(TaskResult)) *BatchResult {
+	return eachResult(ctx, s.processor(ctx), tasks, emit)
+}
+
+// SubmitTask 向流式批次提交一个任务
+func (s *KindService) SubmitTask(stream *BatchStream, task Task) (int, error) {
+	return stream.stream.Submit(func(ctx context.Context, index, slot int) TaskResult {
+		return s.runTask(ctx, index, slot, task)
+	})
+}
+
+// RunConfig 批次的配置快照
+func (s *KindService) RunConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"kind":             s.Kind.Name(),
+		"mode":             s.Mode.String(),
+		"max_concurrency":  s.MaxConcurrency,
+		"timeout":          s.Timeout.String(),
+		"per_task_timeout": s.PerTaskTimeout.String(),
+		"retry":            retryConfig(s.Retry),
+		"rate_limit":       rateConfig(s.RateLimit),
+		"pools":            poolConfig(s.Pools),
+	}
+}
+
+// TaskRegistry 已注册的任务类型
+type TaskRegistry struct {
+	mu    sync.RWMutex
+	kinds map[string]*KindService
+}
+
+// Register 注册任务类型，名称不合法、与内置类型或已注册的类型重名时返回错误
+// 接口在启动时按已注册的类型生成，应在设置路由之前注册
+func (r *TaskRegistry) Register(s *KindService) error {
+	name := s.Kind.Name()
+	if !taskKindName.MatchString(name) {
+		return fmt.Errorf("任务类型名称不合法: %q", name)
+	}
+	if s.MaxConcurrency <= 0 || s.Timeout <= 0 {
+		return fmt.Errorf("任务类型 %s 须设置 MaxConcurrency 和 Timeout", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.kinds[name]; ok || builtinKinds[name] {
+		return fmt.Errorf("%w: %s", ErrTaskKindExists, name)
+	}
+	if r.kinds == nil {
+		r.kinds = make(map[string]*KindService)
+	}
+	r.kinds[name] = s
+	return nil
+}
+
+// Get 返回任务类型的服务，未注册时返回 ErrTaskKindNotFound
+func (r *TaskRegistry) Get(name string) (*KindService, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.kinds[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTaskKindNotFound, name)
+	}
+	return s, nil
+}
+
+// Names 返回已注册的任务类型，按名称排序
+func (r *TaskRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.kinds))
+	for name := range r.kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
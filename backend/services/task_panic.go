@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// taskPanicsTotal 任务执行中 panic 的累计次数，通过 expvar 导出
+var taskPanicsTotal = expvar.NewInt("task_panics_total")
+
+// TaskPanicError 任务执行中发生 panic，Value 为 panic 的值
+type TaskPanicError struct {
+	Value interface{}
+}
+
+func (e *TaskPanicError) Error() string {
+	return fmt.Sprintf("任务执行异常: %v", e.Value)
+}
+
+// panicError 记录任务中的 panic（计入 task_panics_total，连同调用栈写入日志）并转换为错误
+func panicError(ctx context.Context, index int, rec interface{}) error {
+	taskPanicsTotal.Add(1)
+	jobID := ""
+	if job := JobFromContext(ctx); job != nil {
+		jobID = job.ID()
+	}
+	log.Printf("[PANIC] job=%s task=%d: %v\n%s", jobID, index, rec, debug.Stack())
+	return &TaskPanicError{Value: rec}
+}
+
+// recoverTask 将任务函数中的 panic 转换为失败结果，单个任务出错不会拖垮整个服务
+// 在任务函数开头以 defer recoverTask(ctx, index, taskStart, &result) 调用，result 为命名返回值
+func recoverTask(ctx context.Context, index int, taskStart time.Time, result *TaskResult) {
+	rec := recover()
+	if rec == nil {
+		return
+	}
+	err := panicError(ctx, index, rec)
+	*result = TaskResult{
+		ID:        index,
+		Success:   false,
+		Status:    TaskStatusFailed,
+		Error:     err.Error(),
+		ErrorCode: ErrCodeTaskPanic,
+		Duration:  time.Since(taskStart).Milliseconds(),
+	}
+}
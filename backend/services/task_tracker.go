@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// startTracker 记录批次中已开始执行的任务，批次超时后据此区分执行中被中止（timeout）和未开始（not_started）的任务
+type startTracker struct {
+	started []atomic.Bool
+	count   atomic.Int64
+}
+
+type startTrackerKey struct{}
+
+// withStartTracker 为 totalTasks 个任务的批次附加开始记录
+func withStartTracker(ctx context.Context, totalTasks int) context.Context {
+	t := &startTracker{started: make([]atomic.Bool, totalTasks)}
+	return context.WithValue(ctx, startTrackerKey{}, t)
+}
+
+// startTrackerFromContext 取出批次的开始记录，未附加时返回nil
+func startTrackerFromContext(ctx context.Context) *startTracker {
+	t, _ := ctx.Value(startTrackerKey{}).(*startTracker)
+	return t
+}
+
+// start 标记任务已开始执行
+func (t *startTracker) start(index int) {
+	if t == nil || index < 0 || index >= len(t.started) {
+		return
+	}
+	if !t.started[index].Swap(true) {
+		t.count.Add(1)
+	}
+}
+
+// isStarted 任务是否已开始执行
+func (t *startTracker) isStarted(index int) bool {
+	return t != nil && index >= 0 && index < len(t.started) && t.started[index].Load()
+}
+
+// startedCount 已开始执行的任务数
+func (t *startTracker) startedCount() int {
+	if t == nil {
+		return 0
+	}
+	return int(t.count.Load())
+}
+
+// failureStatus 判断执行失败的任务状态：时间预算耗尽为 timeout，被取消为 cancelled，其余为 failed
+func failureStatus(ctx context.Context, err error) string {
+	var stalled *TaskStalledError
+	switch {
+	case errors.As(err, &stalled):
+		// 被回收器取消的卡住的任务计为失败
+		return TaskStatusFailed
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return TaskStatusTimeout
+	case errors.Is(ctx.Err(), context.Canceled):
+		if failedByOtherTask(ctx) {
+			return TaskStatusCancelled
+		}
+		if job := JobFromContext(ctx); job != nil && job.CancelMode().aborts() {
+			return TaskStatusCancelled
+		}
+		// 批次计时器到期后收集结束，任务的上下文随之取消
+		return TaskStatusTimeout
+	case errors.Is(err, context.DeadlineExceeded):
+		return TaskStatusTimeout
+	}
+	return TaskStatusFailed
+}
+
+// failedByOtherTask ctx 是否因批次中其他任务失败而取消（batch.ModeErrGroupFailFast）
+func failedByOtherTask(ctx context.Context) bool {
+	var failed *batch.TaskFailedError
+	return errors.As(context.Cause(ctx), &failed)
+}
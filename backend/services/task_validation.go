@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// TaskProblem 任务校验发现的一个问题
+type TaskProblem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// TaskValidation 单个任务的校验结果
+type TaskValidation struct {
+	ID       int           `json:"id"` // 任务在批次中的序号
+	Problems []TaskProblem `json:"problems"`
+}
+
+// ValidationReport 批次的校验结果，只列出有问题的任务
+type ValidationReport struct {
+	TotalTasks   int              `json:"total_tasks"`
+	CheckedTasks int              `json:"checked_tasks"` // 超时时小于 TotalTasks
+	ValidTasks   int              `json:"valid_tasks"`
+	InvalidTasks int              `json:"invalid_tasks"`
+	Duration     int64            `json:"duration"` // 毫秒
+	Invalid      []TaskValidation `json:"invalid"`
+}
+
+// validateTasks 以工作池并发校验全部任务，不执行任务本身
+// 校验结果逐个汇总，通过的任务不保留，内存占用只与有问题的任务数有关
+func validateTasks[T any](ctx context.Context, maxConcurrency int, timeout time.Duration, tasks []T, check func(ctx context.Context, task T) []TaskProblem) *ValidationReport {
+	startTime := time.Now()
+	processor := &batch.Processor[T, TaskValidation]{
+		Mode:           batch.ModeWorkerPool,
+		MaxConcurrency: maxConcurrency,
+		Timeout:        timeout,
+		Run: func(ctx context.Context, index, slot int, task T) TaskValidation {
+			return TaskValidation{ID: index, Problems: check(ctx, task)}
+		},
+	}
+
+	report := &ValidationReport{TotalTasks: len(tasks), Invalid: []TaskValidation{}}
+	report.CheckedTasks = processor.Each(ctx, tasks, func(v TaskValidation) {
+		if len(v.Problems) == 0 {
+			report.ValidTasks++
+			return
+		}
+		report.InvalidTasks++
+		report.Invalid = append(report.Invalid, v)
+	})
+	sort.Slice(report.Invalid, func(i, j int) bool {
+		return report.Invalid[i].ID < report.Invalid[j].ID
+	})
+	report.Duration = time.Since(startTime).Milliseconds()
+	return report
+}
+
+// ValidateOrders 校验订单字段，不执行处理
+func (s *OrderProcessService) ValidateOrders(ctx context.Context, orders []OrderTask) *ValidationReport {
+	return validateTasks(ctx, s.MaxConcurrency, s.Timeout, orders, func(_ context.Context, order OrderTask) []TaskProblem {
+		var problems []TaskProblem
+		if strings.TrimSpace(order.CustomerID) == "" {
+			problems = append(problems, TaskProblem{"customer_id", "不能为空"})
+		}
+		if strings.TrimSpace(order.ProductName) == "" {
+			problems = append(problems, TaskProblem{"product_name", "不能为空"})
+		}
+		if order.Quantity <= 0 {
+			problems = append(problems, TaskProblem{"quantity", "必须大于0"})
+		}
+		if order.Price < 0 {
+			problems = append(problems, TaskProblem{"price", "不能为负数"})
+		}
+		return problems
+	})
+}
+
+// validMethods 允许批量调用的HTTP方法，空值按 GET 处理
+var validMethods = map[string]bool{
+	"": true, http.MethodGet: true, http.MethodHead: true, http.MethodPost: true,
+	http.MethodPut: true, http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// ValidateAPICalls 校验API调用的地址、方法和请求头，不发出请求
+func (s *APICallService) ValidateAPICalls(ctx context.Context, tasks []APICallTask) *ValidationReport {
+	return validateTasks(ctx, s.MaxConcurrency, s.Timeouts.Batch, tasks, func(_ context.Context, task APICallTask) []TaskProblem {
+		var problems []TaskProblem
+		if u, err := url.Parse(task.URL); err != nil {
+			problems = append(problems, TaskProblem{"url", "不是合法的URL: " + err.Error()})
+		} else if u.Scheme != "http" && u.Scheme != "https" {
+			problems = append(problems, TaskProblem{"url", "必须为 http(s) URL"})
+		} else if u.Host == "" {
+			problems = append(problems, TaskProblem{"url", "缺少主机名"})
+		}
+		if !validMethods[task.Method] {
+			problems = append(problems, TaskProblem{"method", "不支持的HTTP方法: " + task.Method})
+		}
+		for key := range task.Headers {
+			if key == "" || strings.ContainsAny(key, " \t\r\n:") {
+				problems = append(problems, TaskProblem{"headers", fmt.Sprintf("不合法的请求头名称: %q", key)})
+			}
+		}
+		return problems
+	})
+}
+
+// validProcessTypes 支持的文件处理类型
+var validProcessTypes = map[string]bool{"info": true, "copy": true, "compress": true, "hash": true}
+
+// ValidateFiles 校验文件处理类型并确认文件存在，不读取文件内容
+// resolve 将按文件ID或版本指定的任务解析为存储路径，为 nil 时直接使用 file_path
+func (s *FileProcessService) ValidateFiles(ctx context.Context, tasks []FileTask, resolve func(FileTask) (FileTask, error)) *ValidationReport {
+	return validateTasks(ctx, s.MaxConcurrency, s.Timeout, tasks, func(_ context.Context, task FileTask) []TaskProblem {
+		var problems []TaskProblem
+		if !validProcessTypes[task.ProcessType] {
+			problems = append(problems, TaskProblem{"process_type", "不支持的处理类型: " + task.ProcessType})
+		}
+		if resolve != nil {
+			resolved, err := resolve(task)
+			if err != nil {
+				return append(problems, TaskProblem{"file", err.Error()})
+			}
+			task = resolved
+		}
+		if task.FilePath == "" {
+			return append(problems, TaskProblem{"file_path", "不能为空"})
+		}
+		info, err := os.Stat(task.FilePath)
+		switch {
+		case err != nil:
+			problems = append(problems, TaskProblem{"file_path", "文件不可访问: " + err.Error()})
+		case info.IsDir():
+			problems = append(problems, TaskProblem{"file_path", "不能是目录"})
+		}
+		return problems
+	})
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// SniffLen 内容嗅探需要读取的字节数
+const SniffLen = 512
+
+// executableExtensions 可执行文件扩展名
+var executableExtensions = map[string]bool{
+	".exe": true, ".com": true, ".scr": true, ".msi": true, ".dll": true,
+	".bat": true, ".cmd": true, ".ps1": true, ".vbs": true, ".js": true,
+	".jar": true, ".sh": true, ".elf": true, ".bin": true, ".app": true,
+}
+
+// sniffableExtensions DetectContentType 能可靠识别的扩展名及其对应的MIME类型
+var sniffableExtensions = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".pdf":  "application/pdf",
+	".zip":  "application/zip",
+	".gz":   "application/x-gzip",
+	".mp3":  "audio/mpeg",
+	".mp4":  "video/mp4",
+	".wav":  "audio/wave",
+}
+
+// executableSignatures 可执行文件的魔数
+var executableSignatures = [][]byte{
+	[]byte("MZ"),             // Windows PE
+	[]byte("\x7fELF"),        // Linux ELF
+	[]byte("#!"),             // 脚本 shebang
+	{0xfe, 0xed, 0xfa, 0xce}, // Mach-O 32位
+	{0xfe, 0xed, 0xfa, 0xcf}, // Mach-O 64位
+	{0xcf, 0xfa, 0xed, 0xfe}, // Mach-O 64位（小端）
+	{0xca, 0xfe, 0xba, 0xbe}, // Mach-O 通用二进制 / Java class
+}
+
+// UploadPolicy 文件上传校验策略
+type UploadPolicy struct {
+	BlockExecutables bool // 拒绝可执行文件（扩展名或内容）
+	AllowMismatch    bool // 允许扩展名与嗅探到的内容类型不一致
+}
+
+// Inspect 校验文件名和文件头部内容，返回嗅探到的MIME类型
+func (p *UploadPolicy) Inspect(fileName string, head []byte) (string, error) {
+	detected := http.DetectContentType(head)
+	mediaType, _, err := mime.ParseMediaType(detected)
+	if err != nil {
+		mediaType = detected
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+
+	if p.BlockExecutables {
+		if executableExtensions[ext] {
+			if inner := strings.ToLower(filepath.Ext(strings.TrimSuffix(fileName, filepath.Ext(fileName)))); inner != "" {
+				return mediaType, fmt.Errorf("文件 %s 使用了双扩展名伪装可执行文件（%s%s）", fileName, inner, ext)
+			}
+			return mediaType, fmt.Errorf("不允许上传可执行文件: %s", fileName)
+		}
+		if isExecutableContent(head) {
+			return mediaType, fmt.Errorf("文件 %s 的内容为可执行程序", fileName)
+		}
+	}
+
+	if !p.AllowMismatch {
+		if expected, ok := sniffableExtensions[ext]; ok && expected != mediaType {
+			return mediaType, fmt.Errorf("文件 %s 的扩展名与实际内容不符（实际为 %s）", fileName, mediaType)
+		}
+	}
+
+	return mediaType, nil
+}
+
+// isExecutableContent 根据魔数判断内容是否为可执行程序
+func isExecutableContent(head []byte) bool {
+	for _, signature := range executableSignatures {
+		if bytes.HasPrefix(head, signature) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"concurrency-web-app/backend/models"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// 上传文件的扫描状态，为空表示上传时未启用扫描
+const (
+	ScanStatusPending  = "pending"  // 等待扫描结果，不能处理
+	ScanStatusClean    = "clean"    // 未发现威胁
+	ScanStatusInfected = "infected" // 发现威胁，文件已移入隔离目录
+	ScanStatusFailed   = "failed"   // 扫描出错，按未通过处理
+)
+
+// FileStatusQuarantined 被隔离的文件的状态
+const FileStatusQuarantined = "quarantined"
+
+// ErrFileNotScanned 文件未通过扫描，不能处理
+var ErrFileNotScanned = errors.New("文件未通过病毒扫描")
+
+// ScanVerdict 单个文件的扫描结论
+type ScanVerdict struct {
+	Infected  bool
+	Signature string // 命中的特征名，未发现威胁时为空
+}
+
+// Scanner 病毒扫描引擎
+type Scanner interface {
+	Scan(ctx context.Context, name string, r io.Reader) (ScanVerdict, error)
+}
+
+// CheckScanned 文件的扫描状态是否允许处理，未启用扫描时上传的文件不受限制
+func CheckScanned(file *models.FileTask) error {
+	switch file.ScanStatus {
+	case "", ScanStatusClean:
+		return nil
+	case ScanStatusInfected:
+		return fmt.Errorf("%w: %s 命中 %s", ErrFileNotScanned, file.OriginalName, file.ScanSignature)
+	default:
+		return fmt.Errorf("%w: %s 扫描状态为 %s", ErrFileNotScanned, file.OriginalName, file.ScanStatus)
+	}
+}
+
+// UploadScanner 在上传的文件可被处理前调用扫描引擎，发现威胁的文件移入隔离目录
+type UploadScanner struct {
+	Engine         Scanner
+	QuarantineDir  string
+	MaxConcurrency int           // 同时扫描的文件数，默认4
+	Timeout        time.Duration // 单个文件的扫描超时，默认60秒
+}
+
+// ScanUploads 并发扫描本次上传的文件，将结论写入文件信息；单个文件扫描出错时记为 failed，不影响其他文件
+func (s *FileMetadataService) ScanUploads(ctx context.Context, files []*models.FileTask) error {
+	scanner := s.Scanner
+	limit := scanner.MaxConcurrency
+	if limit <= 0 {
+		limit = 4
+	}
+	var g errgroup.Group
+	g.SetLimit(limit)
+	for _, file := range files {
+		g.Go(func() error {
+			updates := scanner.scan(ctx, file)
+			return s.DB.Model(file).Updates(updates).Error
+		})
+	}
+	return g.Wait()
+}
+
+// scan 扫描单个文件并在需要时隔离，返回要写入的字段，同时更新 file
+func (s *UploadScanner) scan(ctx context.Context, file *models.FileTask) map[string]interface{} {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	verdict, err := s.scanFile(ctx, file)
+	now := time.Now()
+	file.ScannedAt = &now
+	switch {
+	case err != nil:
+		log.Printf("扫描文件 %s 失败: %v", file.FilePath, err)
+		file.ScanStatus = ScanStatusFailed
+		file.ScanSignature = ""
+	case verdict.Infected:
+		file.ScanStatus = ScanStatusInfected
+		file.ScanSignature = verdict.Signature
+		if err := s.quarantine(file); err != nil {
+			log.Printf("隔离文件 %s 失败: %v", file.FilePath, err)
+		}
+	default:
+		file.ScanStatus = ScanStatusClean
+		file.ScanSignature = ""
+	}
+
+	updates := map[string]interface{}{
+		"scan_status":    file.ScanStatus,
+		"scan_signature": file.ScanSignature,
+		"scanned_at":     file.ScannedAt,
+	}
+	if file.Status == FileStatusQuarantined {
+		updates["status"] = file.Status
+		updates["file_path"] = file.FilePath
+	}
+	return updates
+}
+
+func (s *UploadScanner) scanFile(ctx context.Context, file *models.FileTask) (ScanVerdict, error) {
+	f, err := os.Open(file.FilePath)
+	if err != nil {
+		return ScanVerdict{}, err
+	}
+	defer f.Close()
+	return s.Engine.Scan(ctx, file.OriginalName, f)
+}
+
+// quarantine 将文件移入隔离目录，文件名不变
+func (s *UploadScanner) quarantine(file *models.FileTask) error {
+	if err := os.MkdirAll(s.QuarantineDir, 0700); err != nil {
+		return err
+	}
+	target := filepath.Join(s.QuarantineDir, filepath.Base(file.FilePath))
+	if err := os.Rename(file.FilePath, target); err != nil {
+		return err
+	}
+	file.FilePath = target
+	file.Status = FileStatusQuarantined
+	return nil
+}
+
+// ClamdScanner 通过 clamd 的 INSTREAM 命令扫描
+type ClamdScanner struct {
+	Network string // tcp 或 unix
+	Address string // 如 clamd:3310 或 /var/run/clamav/clamd.ctl
+}
+
+// clamdChunkSize INSTREAM 每块发送的字节数
+const clamdChunkSize = 64 << 10
+
+// Scan 将内容分块发送给 clamd，按回复判断结论
+func (s *ClamdScanner) Scan(ctx context.Context, name string, r io.Reader) (ScanVerdict, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.Network, s.Address)
+	if err != nil {
+		return ScanVerdict{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return ScanVerdict{}, err
+	}
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(append(size, buf[:n]...)); err != nil {
+				return ScanVerdict{}, err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ScanVerdict{}, err
+		}
+	}
+	// 长度为0的块表示内容结束
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return ScanVerdict{}, err
+	}
+
+	reply, err := io.ReadAll(conn)
+	if err != nil {
+		return ScanVerdict{}, err
+	}
+	return parseClamdReply(string(reply))
+}
+
+// parseClamdReply 解析 clamd 的回复，如 "stream: OK"、"stream: Eicar-Signature FOUND"
+func parseClamdReply(reply string) (ScanVerdict, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	result := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case result == "OK":
+		return ScanVerdict{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return ScanVerdict{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	default:
+		return ScanVerdict{}, fmt.Errorf("clamd 返回错误: %s", reply)
+	}
+}
+
+// HTTPScanner 调用外部扫描接口：以 POST 发送文件内容（X-File-Name 请求头为文件名），
+// 接口返回 {"infected": true, "signature": "..."}
+type HTTPScanner struct {
+	URL    string
+	Client *http.Client // 为nil时使用 http.DefaultClient
+}
+
+// Scan 发送文件内容并解析扫描结论
+func (s *HTTPScanner) Scan(ctx context.Context, name string, r io.Reader) (ScanVerdict, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, r)
+	if err != nil {
+		return ScanVerdict{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-File-Name", name)
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ScanVerdict{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return ScanVerdict{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return ScanVerdict{}, fmt.Errorf("扫描接口返回 %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var verdict struct {
+		Infected  bool   `json:"infected"`
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(body, &verdict); err != nil {
+		return ScanVerdict{}, fmt.Errorf("解析扫描结果失败: %w", err)
+	}
+	return ScanVerdict{Infected: verdict.Infected, Signature: verdict.Signature}, nil
+}
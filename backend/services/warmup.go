@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Warmup 启动预热：开始接收请求前预先建立数据库连接、HTTP 连接和工作协程，
+// 部署后的第一个大批次不必承担建立连接、TLS 握手和分配协程栈的延迟。字段为零值的项跳过
+type Warmup struct {
+	DB      *gorm.DB
+	DBConns int             // 预先建立的数据库连接数，连接保留在空闲池中（空闲连接上限随之调高）
+	APIs    *APICallService // 预先创建 API 调用共享的 HTTP Transport
+	Hosts   []string        // 预先发送 HEAD 请求的 URL，建立的连接保留在 Transport 的空闲池中，域名解析写入 DNS 缓存
+	Workers int             // 预先创建的工作协程数，退出后协程及其栈由运行时缓存，批次创建协程时直接复用
+	Timeout time.Duration   // 全部预热的超时时间，默认30秒
+}
+
+// WarmupStep 单项预热的结果
+type WarmupStep struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration"` // 毫秒
+}
+
+// WarmupReport 预热结果，预热失败不影响启动，第一个批次照常按需建立连接
+type WarmupReport struct {
+	OK    bool         `json:"ok"`
+	Steps []WarmupStep `json:"steps"`
+}
+
+// Run 并发执行各项预热，某项失败不影响其余项
+func (w *Warmup) Run(ctx context.Context) *WarmupReport {
+	timeout := w.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var steps []func() WarmupStep
+	step := func(name string, run func(context.Context) error) {
+		steps = append(steps, func() WarmupStep {
+			start := time.Now()
+			err := run(ctx)
+			result := WarmupStep{Name: name, OK: err == nil, Duration: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			return result
+		})
+	}
+
+	if w.DB != nil && w.DBConns > 0 {
+		step("database", w.warmDB)
+	}
+	if w.APIs != nil {
+		step("http_transport", func(context.Context) error {
+			w.APIs.httpClient()
+			return nil
+		})
+		for _, host := range w.Hosts {
+			host := host
+			step("host:"+host, func(ctx context.Context) error { return w.warmHost(ctx, host) })
+		}
+	}
+	if w.Workers > 0 {
+		step("workers", w.warmWorkers)
+	}
+
+	report := &WarmupReport{OK: true, Steps: make([]WarmupStep, len(steps))}
+	var wg sync.WaitGroup
+	for i, run := range steps {
+		wg.Add(1)
+		go func(i int, run func() WarmupStep) {
+			defer wg.Done()
+			report.Steps[i] = run()
+		}(i, run)
+	}
+	wg.Wait()
+	for _, s := range report.Steps {
+		if !s.OK {
+			report.OK = false
+		}
+	}
+	return report
+}
+
+// warmDB 依次取出 DBConns 个连接并 ping，全部取出前不归还，迫使连接池新建连接；归还后留在空闲池中
+func (w *Warmup) warmDB(ctx context.Context) error {
+	sqlDB, err := w.DB.DB()
+	if err != nil {
+		return err
+	}
+	if max := sqlDB.Stats().MaxOpenConnections; max > 0 && w.DBConns > max {
+		return fmt.Errorf("预热连接数 %d 超过连接数上限 %d", w.DBConns, max)
+	}
+	sqlDB.SetMaxIdleConns(w.DBConns)
+
+	conns := make([]*sql.Conn, 0, w.DBConns)
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}()
+	for i := 0; i < w.DBConns; i++ {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			return fmt.Errorf("建立第 %d 个连接失败: %w", i+1, err)
+		}
+		conns = append(conns, conn)
+		if err := conn.PingContext(ctx); err != nil {
+			return fmt.Errorf("第 %d 个连接不可用: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// warmHost 向 URL 发送 HEAD 请求，读完响应后连接回到空闲池；任何HTTP状态码都说明连接已建立
+func (w *Warmup) warmHost(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := w.APIs.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// warmWorkers 同时创建 Workers 个协程，全部启动后退出
+func (w *Warmup) warmWorkers(ctx context.Context) error {
+	var started, done sync.WaitGroup
+	release := make(chan struct{})
+	started.Add(w.Workers)
+	done.Add(w.Workers)
+	for i := 0; i < w.Workers; i++ {
+		go func() {
+			defer done.Done()
+			started.Done()
+			<-release
+		}()
+	}
+	started.Wait()
+	close(release)
+	done.Wait()
+	return ctx.Err()
+}
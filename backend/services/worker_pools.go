@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"sync/atomic"
+)
+
+// 工作池路由规则中的任务类型，与任务的 job_type 一致
+const (
+	PoolTaskOrder    = "order"
+	PoolTaskAPI      = "api"
+	PoolTaskFile     = "file"
+	PoolTaskPipeline = "pipeline" // 流水线 fetch 阶段的请求
+)
+
+// ErrInvalidPoolConfig 工作池配置不合法
+var ErrInvalidPoolConfig = errors.New("工作池配置错误")
+
+// PoolConfig 命名工作池的配置，一般从 WORKER_POOLS_CONFIG 指定的 JSON 文件读取：
+//
+//	{
+//	  "pools": {"external-apis": 20, "disk-io": 4},
+//	  "rules": [
+//	    {"host": "*.example.com", "pool": "external-apis"},
+//	    {"task_type": "file", "pool": "disk-io"}
+//	  ]
+//	}
+type PoolConfig struct {
+	Pools map[string]int `json:"pools"` // 池名 -> 同时执行的任务数上限
+	Rules []PoolRule     `json:"rules"` // 按顺序匹配，第一条匹配的规则决定任务使用的池
+}
+
+// PoolRule 工作池路由规则，TaskType 和 Host 都为空的规则匹配全部任务
+type PoolRule struct {
+	TaskType string `json:"task_type,omitempty"` // 任务类型，为空时不限
+	Host     string `json:"host,omitempty"`      // 主机名，支持 path.Match 通配符（如 *.example.com），为空时不限
+	Pool     string `json:"pool"`
+}
+
+// matches 任务是否匹配规则，没有主机的任务（订单、文件）不匹配指定了 Host 的规则
+func (r PoolRule) matches(taskType, host string) bool {
+	if r.TaskType != "" && r.TaskType != taskType {
+		return false
+	}
+	if r.Host == "" {
+		return true
+	}
+	ok, _ := path.Match(r.Host, host)
+	return host != "" && ok
+}
+
+// WorkerPool 命名工作池，限制路由到该池的任务同时执行的数量，所有服务和批次共享
+type WorkerPool struct {
+	Name    string
+	Size    int
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+// PoolStats 工作池当前的使用情况
+type PoolStats struct {
+	Name    string `json:"name"`
+	Size    int    `json:"size"`
+	InUse   int    `json:"in_use"`
+	Waiting int64  `json:"waiting"`
+}
+
+// WorkerPools 命名工作池和路由规则，服务执行任务前按任务类型和主机取得所属池的槽位
+// 池只在各服务自身的并发数之外增加跨服务、跨批次的上限，没有规则匹配的任务不受限制
+type WorkerPools struct {
+	pools map[string]*WorkerPool
+	rules []PoolRule
+}
+
+// NewWorkerPools 按配置创建工作池，池的大小须大于0，规则引用的池须已定义
+func NewWorkerPools(cfg PoolConfig) (*WorkerPools, error) {
+	p := &WorkerPools{pools: make(map[string]*WorkerPool, len(cfg.Pools))}
+	for name, size := range cfg.Pools {
+		if size <= 0 {
+			return nil, fmt.Errorf("%w: 池 %s 的大小须大于0", ErrInvalidPoolConfig, name)
+		}
+		p.pools[name] = &WorkerPool{Name: name, Size: size, slots: make(chan struct{}, size)}
+	}
+	for i, rule := range cfg.Rules {
+		if _, ok := p.pools[rule.Pool]; !ok {
+			return nil, fmt.Errorf("%w: 第 %d 条规则引用的池 %q 未定义", ErrInvalidPoolConfig, i+1, rule.Pool)
+		}
+		if _, err := path.Match(rule.Host, ""); err != nil {
+			return nil, fmt.Errorf("%w: 第 %d 条规则的主机 %q 不合法", ErrInvalidPoolConfig, i+1, rule.Host)
+		}
+	}
+	p.rules = cfg.Rules
+	return p, nil
+}
+
+// LoadWorkerPools 读取 JSON 格式的工作池配置，file 为空时返回nil，不使用工作池
+func LoadWorkerPools(file string) (*WorkerPools, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var cfg PoolConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPoolConfig, err)
+	}
+	return NewWorkerPools(cfg)
+}
+
+// Route 返回任务所属的池，没有规则匹配时返回nil
+func (p *WorkerPools) Route(taskType, host string) *WorkerPool {
+	if p == nil {
+		return nil
+	}
+	for _, rule := range p.rules {
+		if rule.matches(taskType, host) {
+			return p.pools[rule.Pool]
+		}
+	}
+	return nil
+}
+
+// Acquire 取得任务所属池的一个槽位，返回释放槽位的函数；池已满时等待，ctx 结束时返回其错误
+// p 为nil或没有规则匹配时立即返回
+func (p *WorkerPools) Acquire(ctx context.Context, taskType, host string) (release func(), err error) {
+	pool := p.Route(taskType, host)
+	if pool == nil {
+		return func() {}, nil
+	}
+	select {
+	case pool.slots <- struct{}{}:
+		return pool.release, nil
+	default:
+	}
+
+	pool.waiting.Add(1)
+	defer pool.waiting.Add(-1)
+	select {
+	case pool.slots <- struct{}{}:
+		return pool.release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (w *WorkerPool) release() { <-w.slots }
+
+// Stats 返回各池的使用情况，按池名排序
+func (p *WorkerPools) Stats() []PoolStats {
+	if p == nil {
+		return []PoolStats{}
+	}
+	stats := make([]PoolStats, 0, len(p.pools))
+	for _, pool := range p.pools {
+		stats = append(stats, PoolStats{Name: pool.Name, Size: pool.Size, InUse: len(pool.slots), Waiting: pool.waiting.Load()})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// Rules 返回路由规则
+func (p *WorkerPools) Rules() []PoolRule {
+	if p == nil {
+		return nil
+	}
+	return p.rules
+}
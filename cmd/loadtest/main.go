@@ -0,0 +1,174 @@
+// loadtest 压测工具：按生成规则（与 POST /api/orders/generate 相同的 DSL）在本地生成批次，
+// 以指定的并发数提交到运行中的服务，输出各批次的耗时分位数和任务的成功、失败数
+//
+//	go run ./cmd/loadtest -target orders -spec scenario.json -batches 20 -concurrency 4
+package main
+
+import (
+	"bytes"
+	"concurrency-web-app/backend/services"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// loadTargets 可以压测的批量接口：接口路径、请求中任务列表的字段名和默认生成规则
+var loadTargets = map[string]struct {
+	path     string
+	field    string
+	defaults func() map[string]services.FieldSpec
+}{
+	"orders": {"/api/orders/batch-process", "orders", services.DefaultOrderFields},
+	"apis":   {"/api/api-calls/batch-call", "apis", services.DefaultAPICallFields},
+}
+
+// batchOutcome 一个批次的提交结果
+type batchOutcome struct {
+	latency time.Duration
+	status  int
+	success int
+	failed  int
+	err     error
+}
+
+func main() {
+	server := flag.String("server", "http://localhost:8080", "服务地址")
+	target := flag.String("target", "orders", "压测的批量接口：orders 或 apis")
+	specPath := flag.String("spec", "", "生成规则文件（JSON，格式同生成接口的请求体），为空时使用默认规则")
+	count := flag.Int("count", 0, "每个批次的任务数，覆盖规则文件中的 count，默认 100")
+	seed := flag.Int64("seed", 0, "种子，覆盖规则文件中的 seed；第 i 个批次使用 seed+i，0 表示随机")
+	batches := flag.Int("batches", 10, "提交的批次数")
+	concurrency := flag.Int("concurrency", 2, "同时提交的批次数")
+	user := flag.String("user", "", "以 X-User-ID 标识的用户，服务端须开启 TRUST_USER_HEADER")
+	apiKey := flag.String("api-key", "", "X-API-Key")
+	flag.Parse()
+
+	t, ok := loadTargets[*target]
+	if !ok {
+		log.Fatalf("不支持的 target %q，可选 orders、apis", *target)
+	}
+	var spec services.GeneratorSpec
+	if *specPath != "" {
+		data, err := os.ReadFile(*specPath)
+		if err != nil {
+			log.Fatal("读取生成规则失败:", err)
+		}
+		if err := json.Unmarshal(data, &spec); err != nil {
+			log.Fatal("生成规则格式错误:", err)
+		}
+	}
+	if *count > 0 {
+		spec.Count = *count
+	}
+	if spec.Count == 0 {
+		spec.Count = 100
+	}
+	if *seed != 0 {
+		spec.Seed = *seed
+	}
+	// 提交前先检查规则，避免压测进行到一半才发现错误
+	if _, err := spec.Generate(t.defaults()); err != nil {
+		log.Fatal(err)
+	}
+
+	outcomes := make([]batchOutcome, *batches)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				batchSpec := spec
+				if spec.Seed != 0 {
+					batchSpec.Seed = spec.Seed + int64(i)
+				}
+				outcomes[i] = submit(*server+t.path, t.field, batchSpec, t.defaults(), *user, *apiKey)
+			}
+		}()
+	}
+	for i := 0; i < *batches; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	report(outcomes, time.Since(start), spec.Count)
+}
+
+// submit 生成一个批次并同步提交，等待批次执行完成
+func submit(url, field string, spec services.GeneratorSpec, defaults map[string]services.FieldSpec, user, apiKey string) batchOutcome {
+	generated, err := spec.Generate(defaults)
+	if err != nil {
+		return batchOutcome{err: err}
+	}
+	body, err := json.Marshal(map[string]interface{}{field: generated.Items})
+	if err != nil {
+		return batchOutcome{err: err}
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return batchOutcome{err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("X-User-ID", user)
+	}
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return batchOutcome{latency: time.Since(start), err: err}
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Data struct {
+			SuccessTasks int `json:"success_tasks"`
+			FailedTasks  int `json:"failed_tasks"`
+		} `json:"data"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	outcome := batchOutcome{latency: time.Since(start), status: resp.StatusCode, success: result.Data.SuccessTasks, failed: result.Data.FailedTasks}
+	if resp.StatusCode != http.StatusOK {
+		outcome.err = fmt.Errorf("HTTP %d", resp.StatusCode)
+	} else if err != nil {
+		outcome.err = err
+	}
+	return outcome
+}
+
+// report 输出批次耗时的分位数、任务计数和吞吐量
+func report(outcomes []batchOutcome, elapsed time.Duration, batchSize int) {
+	var latencies []time.Duration
+	success, failed, errored := 0, 0, 0
+	for i, o := range outcomes {
+		if o.err != nil {
+			errored++
+			log.Printf("批次 %d 失败: %v", i, o.err)
+			continue
+		}
+		latencies = append(latencies, o.latency)
+		success += o.success
+		failed += o.failed
+	}
+	fmt.Printf("批次: %d（每批 %d 个任务），请求失败 %d\n", len(outcomes), batchSize, errored)
+	fmt.Printf("任务: 成功 %d，失败 %d\n", success, failed)
+	fmt.Printf("总耗时: %v，吞吐量 %.1f 任务/秒\n", elapsed.Round(time.Millisecond), float64(success+failed)/elapsed.Seconds())
+	if len(latencies) == 0 {
+		return
+	}
+	sort.Slice(latencies, func(a, b int) bool { return latencies[a] < latencies[b] })
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))].Round(time.Millisecond)
+	}
+	fmt.Printf("批次耗时: p50 %v，p95 %v，p99 %v，最大 %v\n", percentile(0.5), percentile(0.95), percentile(0.99), latencies[len(latencies)-1].Round(time.Millisecond))
+}
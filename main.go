@@ -1,10 +1,24 @@
 package main
 
 import (
+	"compress/gzip"
+	"concurrency-web-app/backend/batch"
 	"concurrency-web-app/backend/handlers"
+	"concurrency-web-app/backend/middleware"
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+	"context"
 	_ "embed"
+	"flag"
 	"log"
+	"math"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -14,36 +28,368 @@ import (
 var indexHTML []byte
 
 func main() {
+	// --selftest 执行启动自检后退出，任一检查失败时退出码非0，可用作部署冒烟测试和容器健康检查
+	selfTest := flag.Bool("selftest", false, "检查数据库和存储目录的读写，以小批量执行各类任务后退出")
+	flag.Parse()
+
 	// 设置Gin模式
 	gin.SetMode(gin.ReleaseMode)
 
-	// 创建Gin路由器
-	r := gin.Default()
+	// 创建Gin路由器，panic 由 middleware.Recovery 记录事故ID后恢复
+	r := gin.New()
+	r.Use(gin.Logger(), middleware.Recovery())
+
+	// 支持明文 HTTP/2（h2c），大批量结果可以在同一连接上多路复用
+	r.UseH2C = true
 
 	// 配置CORS
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"http://localhost:3000", "http://127.0.0.1:3000"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", "X-CSRF-Token", "X-API-Key"}
 	r.Use(cors.New(config))
 
+	// 压缩响应，大体积的 BatchResult 传输量可显著减少
+	r.Use(middleware.Gzip(gzip.DefaultCompression))
+
+	// 内嵌前端与API同源，修改状态的请求校验 CSRF 令牌
+	r.Use(middleware.CSRF(middleware.CSRFConfig{SessionCookies: []string{"session_id"}}))
+
 	// 为根URL提供index.html
 	r.GET("/", func(c *gin.Context) {
 		c.Data(http.StatusOK, "text/html; charset=utf-8", indexHTML)
 	})
 
+	// 初始化数据库
+	dbConfig := models.LoadDBConfig()
+	db, readDB, err := models.InitDB(dbConfig)
+	if err != nil {
+		log.Fatal("初始化数据库失败:", err)
+	}
+
+	// 读取命名工作池配置，未设置 WORKER_POOLS_CONFIG 时各服务只受自身的并发数限制
+	pools, err := services.LoadWorkerPools(os.Getenv("WORKER_POOLS_CONFIG"))
+	if err != nil {
+		log.Fatal("读取工作池配置失败:", err)
+	}
+
+	// 读取结果加密主密钥，未设置 RESULT_MASTER_KEY 时任务结果以明文保存
+	results, err := services.NewResultCipher(db, os.Getenv("RESULT_MASTER_KEY"))
+	if err != nil {
+		log.Fatal("读取结果加密主密钥失败:", err)
+	}
+
 	// 创建处理器
-	batchHandler := handlers.NewBatchHandler()
+	batchHandler := handlers.NewBatchHandler(db, readDB, models.NewLockManager(db, dbConfig.Driver), pools, results)
+
+	// 登记按日期分区之前保存在上传目录中的文件，文件列表和按文件ID处理仍可使用
+	if n, err := batchHandler.RegisterExistingUploads(); err != nil {
+		log.Printf("登记已有的上传文件失败: %v", err)
+	} else if n > 0 {
+		log.Printf("已登记 %d 个按日期分区之前上传的文件", n)
+	}
+
+	// 设置 ADMIN_USERNAME、ADMIN_PASSWORD 时确保该账号为管理员（不存在时创建），注册的账号都是普通用户
+	if username := os.Getenv("ADMIN_USERNAME"); username != "" {
+		if _, err := batchHandler.Accounts.EnsureAdmin(username, os.Getenv("ADMIN_PASSWORD")); err != nil {
+			log.Fatal("初始化管理员账号失败:", err)
+		}
+	}
+
+	// TRUST_USER_HEADER=true 时未登录的请求以 X-User-ID 请求头标识用户，只用于本地开发
+	if value := os.Getenv("TRUST_USER_HEADER"); value != "" {
+		trust, err := strconv.ParseBool(value)
+		if err != nil {
+			log.Fatal("TRUST_USER_HEADER 应为 true 或 false:", value)
+		}
+		if trust {
+			log.Println("警告: 已开启 TRUST_USER_HEADER，未登录的请求可以通过 X-User-ID 冒充任意用户，不要在生产环境使用")
+		}
+		batchHandler.TrustUserHeader = trust
+	}
+
+	// 设置 MAX_RUNNING_JOBS 时全局最多同时执行该数量的批次，其余按优先级类别排队
+	if value := os.Getenv("MAX_RUNNING_JOBS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Fatal("MAX_RUNNING_JOBS 应为非负整数:", value)
+		}
+		batchHandler.Jobs.MaxRunning = n
+	}
+	// 设置 MAX_QUEUED_JOBS 时覆盖排队任务数的上限，0表示不限
+	if value := os.Getenv("MAX_QUEUED_JOBS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Fatal("MAX_QUEUED_JOBS 应为非负整数:", value)
+		}
+		batchHandler.Jobs.MaxQueued = n
+	}
+
+	// 设置 API_RETRY_ATTEMPTS 大于1时API调用按该最大尝试次数重试暂时性错误，默认不重试
+	if value := os.Getenv("API_RETRY_ATTEMPTS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Fatal("API_RETRY_ATTEMPTS 应为非负整数:", value)
+		}
+		if n > 1 {
+			batchHandler.APIService.Retry = handlers.APIRetryPolicy(n)
+		}
+	}
+
+	// 设置 API_RAMP_DURATION（如 2s）时API调用的批次先以 API_RAMP_START（默认2）个并发开始，在该时长内增加到满并发
+	if value := os.Getenv("API_RAMP_DURATION"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			log.Fatal("API_RAMP_DURATION 应为非负的时长，如 2s:", value)
+		}
+		start := 2
+		if value := os.Getenv("API_RAMP_START"); value != "" {
+			if start, err = strconv.Atoi(value); err != nil || start < 1 {
+				log.Fatal("API_RAMP_START 应为正整数:", value)
+			}
+		}
+		if d > 0 {
+			batchHandler.APIService.Ramp = &batch.Ramp{Start: start, Duration: d}
+		}
+	}
+
+	// 设置 API_RATE_LIMIT 时API调用的所有批次合计每秒最多开始该数量的调用，API_RATE_BURST 为最多积攒的令牌数（默认等于每秒的数量）
+	if value := os.Getenv("API_RATE_LIMIT"); value != "" {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 {
+			log.Fatal("API_RATE_LIMIT 应为非负数:", value)
+		}
+		burst := int(math.Ceil(rate))
+		if value := os.Getenv("API_RATE_BURST"); value != "" {
+			if burst, err = strconv.Atoi(value); err != nil || burst < 1 {
+				log.Fatal("API_RATE_BURST 应为正整数:", value)
+			}
+		}
+		batchHandler.APIService.RateLimit = batch.NewRateLimiter(rate, burst)
+	}
+
+	// 设置 TASK_STALL_TIMEOUT（如 90s）时覆盖卡住的子任务的回收时间，0表示不回收
+	if value := os.Getenv("TASK_STALL_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			log.Fatal("TASK_STALL_TIMEOUT 应为非负的时长，如 90s:", value)
+		}
+		batchHandler.Jobs.StallTimeout = d
+	}
+
+	// MAX_REQUEST_CONCURRENCY、MAX_REQUEST_TIMEOUT（如 10m）覆盖批量请求中 max_concurrency、timeout_ms 的上限，0表示不允许覆盖
+	if value := os.Getenv("MAX_REQUEST_CONCURRENCY"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Fatal("MAX_REQUEST_CONCURRENCY 应为非负整数:", value)
+		}
+		batchHandler.Limits.MaxConcurrency = n
+	}
+	if value := os.Getenv("MAX_REQUEST_TIMEOUT"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			log.Fatal("MAX_REQUEST_TIMEOUT 应为非负的时长，如 10m:", value)
+		}
+		batchHandler.Limits.Timeout = d
+	}
+
+	// 设置 RESULT_TTL（如 168h）时结束的任务的结果（产出物和明细）在该时长后删除，模板设置了保留策略的批次按模板
+	if value := os.Getenv("RESULT_TTL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			log.Fatal("RESULT_TTL 应为非负的时长，如 168h:", value)
+		}
+		batchHandler.Jobs.ResultTTL = d
+	}
+
+	// INSTANCE_ID 标识本实例登记的任务，重启后只恢复本实例未结束的任务；未设置时取主机名，多实例部署且主机名会变化时须设置
+	if value := os.Getenv("INSTANCE_ID"); value != "" {
+		services.InstanceID = value
+	}
+
+	// 设置 PUSHGATEWAY_URL 时批次结束后将指标推送到 Pushgateway
+	if pushURL := os.Getenv("PUSHGATEWAY_URL"); pushURL != "" {
+		batchHandler.Jobs.Pushgateway = &services.Pushgateway{URL: pushURL, Instance: os.Getenv("PUSHGATEWAY_INSTANCE")}
+	}
+
+	// 设置 CLAMD_ADDRESS（clamd 的 host:port 或 unix 套接字路径）或 SCAN_API_URL 时扫描上传的文件
+	var scanEngine services.Scanner
+	if address := os.Getenv("CLAMD_ADDRESS"); address != "" {
+		network := "tcp"
+		if strings.HasPrefix(address, "/") {
+			network = "unix"
+		}
+		scanEngine = &services.ClamdScanner{Network: network, Address: address}
+	} else if scanURL := os.Getenv("SCAN_API_URL"); scanURL != "" {
+		scanEngine = &services.HTTPScanner{URL: scanURL}
+	}
+	if scanEngine != nil {
+		batchHandler.Files.Scanner = &services.UploadScanner{Engine: scanEngine, QuarantineDir: "./quarantine"}
+	}
+
+	if *selfTest {
+		os.Exit(runSelfTest(batchHandler))
+	}
+
+	// 启动预热：WARMUP_DB_CONNS 预先建立的数据库连接数，WARMUP_HOSTS 预先连接的 URL（逗号分隔），
+	// WARMUP_WORKERS 预先创建的工作协程数；均未设置时不预热
+	if warmup := loadWarmup(batchHandler); warmup != nil {
+		runWarmup(warmup)
+	}
 
 	// 设置路由
 	batchHandler.SetupRoutes(r)
 
+	// 上次意外退出时未结束的异步批次从检查点恢复执行，其余标记为 interrupted
+	resumed, interrupted, err := batchHandler.RecoverJobs()
+	if err != nil {
+		log.Printf("恢复未结束的任务失败: %v", err)
+	} else if resumed+interrupted > 0 {
+		log.Printf("上次退出时有 %d 个任务未结束：%d 个恢复执行，%d 个标记为 %s", resumed+interrupted, resumed, interrupted, services.JobStatusInterrupted)
+	}
+
+	// 后台任务在服务关闭时停止，访问日志退出前写入缓冲中的记录
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// 定期将过期的任务产出物归档到冷存储
+	go batchHandler.Artifacts.RunArchiver(background, time.Hour)
+
+	// 定期按批次模板的保留策略和结果的保留时长清理过期的任务记录和明细
+	go batchHandler.Retention.Run(background, time.Hour)
+
+	// 数据库恢复后补写排队的任务记录
+	go batchHandler.Jobs.Persist.Run(background)
+
+	// 定期回收长时间没有心跳的子任务
+	go batchHandler.Jobs.RunReaper(background, 10*time.Second)
+
+	// 异步写入访问日志
+	accessLogsDone := make(chan struct{})
+	go func() {
+		defer close(accessLogsDone)
+		batchHandler.AccessLogs.Run(background)
+	}()
+
 	// 启动服务器
+	srv := &http.Server{Addr: ":8080", Handler: r.Handler()}
 	log.Println("服务器启动在端口 :8080")
 	log.Println("前端访问: http://localhost:8080")
 	log.Println("API文档: http://localhost:8080/api/health")
 
-	if err := r.Run(":8080"); err != nil {
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+	select {
+	case err := <-serveErr:
 		log.Fatal("启动服务器失败:", err)
+	case <-signals.Done():
+	}
+	stopSignals() // 再次收到信号时按默认方式立即退出
+
+	// 排空执行中的批次：不再接收新批次，等待执行中的批次完成，超时后取消剩余批次并保存已完成的结果
+	log.Printf("收到退出信号，最多等待 %v 让执行中的批次完成", shutdownGrace)
+	batchHandler.Jobs.Drain()
+	graceCtx, cancelGrace := context.WithTimeout(context.Background(), shutdownGrace)
+	defer cancelGrace()
+	if err := drain(graceCtx, srv, batchHandler); err != nil {
+		n := batchHandler.Jobs.CancelRunning(services.CancelModeShutdown)
+		log.Printf("等待超时，已取消 %d 个执行中的批次，保存已完成的结果", n)
+		finalCtx, cancelFinal := context.WithTimeout(context.Background(), shutdownSave)
+		defer cancelFinal()
+		if err := drain(finalCtx, srv, batchHandler); err != nil {
+			log.Printf("保存批次结果超时: %v", err)
+			srv.Close()
+		}
+	}
+
+	// 退出前最后补写一次排队的任务记录，仍未写入的只能丢弃
+	batchHandler.Jobs.Persist.Retry()
+	if status := batchHandler.Jobs.Persist.Status(); status.Pending > 0 {
+		log.Printf("数据库仍不可用，%d 个任务记录未能保存", status.Pending)
+	}
+
+	stopBackground()
+	<-accessLogsDone
+	log.Println("服务器已关闭")
+}
+
+// 服务关闭时等待执行中的批次的时间，以及超时取消后等待批次保存结果的时间
+const (
+	shutdownGrace = 60 * time.Second
+	shutdownSave  = 10 * time.Second
+)
+
+// runSelfTest 执行启动自检并逐项输出结果，返回进程的退出码
+func runSelfTest(h *handlers.BatchHandler) int {
+	report := h.SelfTest().Run(context.Background())
+	for _, check := range report.Checks {
+		if check.OK {
+			log.Printf("[selftest] %-24s ok (%dms)", check.Name, check.Duration)
+		} else {
+			log.Printf("[selftest] %-24s FAIL (%dms): %s", check.Name, check.Duration, check.Error)
+		}
+	}
+	if !report.OK {
+		log.Println("[selftest] 自检失败")
+		return 1
+	}
+	log.Println("[selftest] 自检通过")
+	return 0
+}
+
+// loadWarmup 按环境变量配置启动预热，均未设置时返回nil
+func loadWarmup(h *handlers.BatchHandler) *services.Warmup {
+	warmup := h.Warmup()
+	enabled := false
+	for name, target := range map[string]*int{"WARMUP_DB_CONNS": &warmup.DBConns, "WARMUP_WORKERS": &warmup.Workers} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Fatalf("%s 应为非负整数: %s", name, value)
+		}
+		*target = n
+		enabled = enabled || n > 0
+	}
+	for _, host := range strings.Split(os.Getenv("WARMUP_HOSTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			warmup.Hosts = append(warmup.Hosts, host)
+			enabled = true
+		}
+	}
+	if !enabled {
+		return nil
+	}
+	return warmup
+}
+
+// runWarmup 执行启动预热并记录各项结果，预热失败不影响启动
+func runWarmup(warmup *services.Warmup) {
+	start := time.Now()
+	report := warmup.Run(context.Background())
+	for _, step := range report.Steps {
+		if step.OK {
+			log.Printf("[warmup] %-24s ok (%dms)", step.Name, step.Duration)
+		} else {
+			log.Printf("[warmup] %-24s FAIL (%dms): %s", step.Name, step.Duration, step.Error)
+		}
+	}
+	log.Printf("[warmup] 预热完成，耗时 %v", time.Since(start).Round(time.Millisecond))
+}
+
+// drain 等待执行中的 HTTP 请求、WebSocket 批次和异步批次结束，ctx 结束时返回其错误
+func drain(ctx context.Context, srv *http.Server, h *handlers.BatchHandler) error {
+	httpDone := make(chan error, 1)
+	go func() { httpDone <- srv.Shutdown(ctx) }()
+	backgroundErr := h.WaitBackground(ctx)
+	// 关闭监听器的错误不影响排空，只关心是否在期限内结束
+	if err := <-httpDone; err != nil && ctx.Err() != nil {
+		return err
 	}
+	return backgroundErr
 }
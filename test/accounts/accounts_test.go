@@ -0,0 +1,70 @@
+package accounts
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+func newAccounts(t *testing.T) *services.AccountService {
+	t.Helper()
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "accounts.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &services.AccountService{DB: db}
+}
+
+// 注册的用户都是普通用户，包括同时注册的第一批用户
+func TestRegisterNeverGrantsAdmin(t *testing.T) {
+	accounts := newAccounts(t)
+	var wg sync.WaitGroup
+	users := make([]string, 4)
+	for i := range users {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if user, err := accounts.Register(fmt.Sprintf("user%d", i), "password123"); err == nil {
+				users[i] = user.Role
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i, role := range users {
+		if role != "" && role != services.RoleUser {
+			t.Errorf("用户 %d 注册后的角色为 %s", i, role)
+		}
+	}
+}
+
+// 管理员由配置指定：不存在时创建，已注册的用户提升为管理员且密码不变
+func TestEnsureAdmin(t *testing.T) {
+	accounts := newAccounts(t)
+	admin, err := accounts.EnsureAdmin("root", "password123")
+	if err != nil || admin.Role != services.RoleAdmin {
+		t.Fatalf("创建管理员 %+v，err=%v", admin, err)
+	}
+	if again, err := accounts.EnsureAdmin("root", "password123"); err != nil || again.ID != admin.ID {
+		t.Fatalf("重复调用 %+v，err=%v", again, err)
+	}
+
+	if _, err := accounts.Register("alice", "alice-password"); err != nil {
+		t.Fatal(err)
+	}
+	promoted, err := accounts.EnsureAdmin("alice", "another-password")
+	if err != nil || promoted.Role != services.RoleAdmin {
+		t.Fatalf("提升为管理员 %+v，err=%v", promoted, err)
+	}
+	if _, _, user, err := accounts.Login("alice", "alice-password"); err != nil || user.Role != services.RoleAdmin {
+		t.Fatalf("原密码登录 %+v，err=%v", user, err)
+	}
+
+	if _, err := accounts.EnsureAdmin("x", "password123"); !errors.Is(err, services.ErrInvalidUsername) {
+		t.Errorf("期望 ErrInvalidUsername，实际 %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package batch
+
+import (
+	"context"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// benchmarkMode 处理 n 个任务，报告内存分配和任务执行期间的协程数峰值
+// 运行：go test ./test/batch -bench . -benchmem
+func benchmarkMode(b *testing.B, mode batch.Mode, n int) {
+	tasks := make([]int, n)
+	for i := range tasks {
+		tasks[i] = i
+	}
+
+	var peak int64
+	p := batch.Processor[int, int]{
+		Mode:           mode,
+		MaxConcurrency: 10,
+		Run: func(ctx context.Context, index, slot, task int) int {
+			// 抽样记录协程数，避免每个任务都调用 NumGoroutine 影响结果
+			if index%100 == 0 {
+				g := int64(runtime.NumGoroutine())
+				for {
+					old := atomic.LoadInt64(&peak)
+					if g <= old || atomic.CompareAndSwapInt64(&peak, old, g) {
+						break
+					}
+				}
+			}
+			return task * 2
+		},
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if results := p.Process(context.Background(), tasks); len(results) != n {
+			b.Fatalf("期望 %d 个结果，实际 %d", n, len(results))
+		}
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&peak)), "peak-goroutines")
+}
+
+func BenchmarkPerTask10000(b *testing.B)    { benchmarkMode(b, batch.ModePerTask, 10000) }
+func BenchmarkWorkerPool10000(b *testing.B) { benchmarkMode(b, batch.ModeWorkerPool, 10000) }
@@ -0,0 +1,611 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// 并发数不超过 MaxConcurrency，全部结果都被收集
+func TestProcessorConcurrencyLimit(t *testing.T) {
+	var running, peak int32
+	p := batch.Processor[int, int]{
+		MaxConcurrency: 3,
+		Run: func(ctx context.Context, index, slot, task int) int {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+					break
+				}
+			}
+			if slot < 0 || slot >= 3 {
+				t.Errorf("槽位越界: %d", slot)
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			return task * 2
+		},
+	}
+
+	tasks := make([]int, 20)
+	for i := range tasks {
+		tasks[i] = i
+	}
+	results := p.Process(context.Background(), tasks)
+	if len(results) != len(tasks) {
+		t.Fatalf("期望 %d 个结果，实际 %d", len(tasks), len(results))
+	}
+	if peak > 3 {
+		t.Fatalf("并发峰值 %d 超过限制", peak)
+	}
+	sort.Ints(results)
+	for i, r := range results {
+		if r != i*2 {
+			t.Fatalf("结果不正确: %v", results)
+		}
+	}
+}
+
+// 超时后立即返回已收集的结果
+func TestProcessorTimeout(t *testing.T) {
+	p := batch.Processor[time.Duration, int]{
+		MaxConcurrency: 2,
+		Timeout:        50 * time.Millisecond,
+		Run: func(ctx context.Context, index, slot int, d time.Duration) int {
+			time.Sleep(d)
+			return index
+		},
+	}
+
+	start := time.Now()
+	results := p.Process(context.Background(), []time.Duration{0, time.Second})
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("超时后没有及时返回")
+	}
+	if len(results) != 1 || results[0] != 0 {
+		t.Fatalf("期望只收集到第一个结果，实际 %v", results)
+	}
+}
+
+// 流式批次按提交顺序编号，关闭后不能再提交
+func TestStreamSubmit(t *testing.T) {
+	var submitted int32
+	var collected int32
+	stream := batch.NewStream(context.Background(), 2, batch.Hooks[string]{
+		OnSubmit: func(int) { atomic.AddInt32(&submitted, 1) },
+		OnResult: func(context.Context, string) { atomic.AddInt32(&collected, 1) },
+	})
+
+	for i := 0; i < 5; i++ {
+		index, err := stream.Submit(func(ctx context.Context, index, slot int) string {
+			return "ok"
+		})
+		if err != nil || index != i {
+			t.Fatalf("期望序号 %d，实际 %d %v", i, index, err)
+		}
+	}
+	stream.Close()
+	if _, err := stream.Submit(func(context.Context, int, int) string { return "" }); !errors.Is(err, batch.ErrClosed) {
+		t.Fatalf("关闭后提交应返回 ErrClosed，实际 %v", err)
+	}
+
+	count := 0
+	for range stream.Results() {
+		count++
+	}
+	if total := stream.Submitted(); count != 5 || total != 5 || submitted != 5 || collected != 5 {
+		t.Fatalf("计数不正确: count=%d total=%d submitted=%d collected=%d", count, total, submitted, collected)
+	}
+}
+
+// 设置了等待任务上限时，提交阻塞到有任务结束；ctx 结束时不再等待
+func TestStreamMaxPending(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := batch.NewStream(ctx, 1, batch.Hooks[string]{})
+	stream.SetMaxPending(2)
+	go func() {
+		for range stream.Results() {
+		}
+	}()
+
+	release := make(chan struct{})
+	wait := func(ctx context.Context, index, slot int) string {
+		<-release
+		return "ok"
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := stream.Submit(wait); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	submitted := make(chan error, 1)
+	go func() {
+		_, err := stream.Submit(wait)
+		submitted <- err
+	}()
+	select {
+	case err := <-submitted:
+		t.Fatalf("达到上限时提交应阻塞，实际返回 %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release <- struct{}{}
+	select {
+	case err := <-submitted:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("有任务结束后提交仍阻塞")
+	}
+
+	go func() {
+		_, err := stream.Submit(wait)
+		submitted <- err
+	}()
+	cancel()
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("ctx 结束时期望 context.Canceled，实际 %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ctx 结束后提交仍阻塞")
+	}
+	close(release)
+	stream.Close()
+	if n := stream.Submitted(); n != 3 {
+		t.Errorf("期望提交 3 个任务，实际 %d", n)
+	}
+}
+
+// 工作池模式下全部任务都被执行，槽位即工作协程编号
+func TestWorkerPool(t *testing.T) {
+	var seen [4]int32
+	p := batch.Processor[int, int]{
+		Mode:           batch.ModeWorkerPool,
+		MaxConcurrency: 4,
+		Run: func(ctx context.Context, index, slot, task int) int {
+			atomic.AddInt32(&seen[slot], 1)
+			return index
+		},
+	}
+
+	results := p.Process(context.Background(), make([]int, 100))
+	if len(results) != 100 {
+		t.Fatalf("期望 100 个结果，实际 %d", len(results))
+	}
+	var total int32
+	for _, n := range seen {
+		total += n
+	}
+	if total != 100 {
+		t.Fatalf("执行次数不正确: %v", seen)
+	}
+}
+
+// 工作池模式超时后不再派发未开始的任务
+func TestWorkerPoolStopsAfterTimeout(t *testing.T) {
+	var started int32
+	p := batch.Processor[int, int]{
+		Mode:           batch.ModeWorkerPool,
+		MaxConcurrency: 1,
+		Timeout:        30 * time.Millisecond,
+		Run: func(ctx context.Context, index, slot, task int) int {
+			atomic.AddInt32(&started, 1)
+			time.Sleep(20 * time.Millisecond)
+			return index
+		},
+	}
+
+	p.Process(context.Background(), make([]int, 50))
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&started); n > 5 {
+		t.Fatalf("超时后仍在派发任务，已开始 %d 个", n)
+	}
+}
+
+// Each 逐个交出结果，返回收集到的结果数
+func TestEach(t *testing.T) {
+	p := batch.Processor[int, int]{
+		Mode:           batch.ModeWorkerPool,
+		MaxConcurrency: 3,
+		Run: func(ctx context.Context, index, slot, task int) int {
+			return task
+		},
+	}
+
+	sum := 0
+	tasks := []int{1, 2, 3, 4, 5}
+	if n := p.Each(context.Background(), tasks, func(r int) { sum += r }); n != len(tasks) || sum != 15 {
+		t.Fatalf("期望 5 个结果、合计 15，实际 %d 个、合计 %d", n, sum)
+	}
+}
+
+// 超时返回后任务的 ctx 被取消，运行中的任务可以及时退出
+func TestProcessorCancelsTasksOnTimeout(t *testing.T) {
+	stopped := make(chan struct{})
+	p := batch.Processor[int, int]{
+		MaxConcurrency: 1,
+		Timeout:        20 * time.Millisecond,
+		Run: func(ctx context.Context, index, slot, task int) int {
+			<-ctx.Done()
+			close(stopped)
+			return 0
+		},
+	}
+
+	p.Process(context.Background(), []int{1})
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("超时后任务的 ctx 没有被取消")
+	}
+}
+
+// 预热时开始阶段只按 Start 并发执行，随后增加到 MaxConcurrency；任务提前完成时不等待预热结束
+func TestProcessorRamp(t *testing.T) {
+	for _, mode := range []batch.Mode{batch.ModePerTask, batch.ModeWorkerPool} {
+		var running, early, peak int32
+		raise := func(v *int32, n int32) {
+			for {
+				old := atomic.LoadInt32(v)
+				if n <= old || atomic.CompareAndSwapInt32(v, old, n) {
+					return
+				}
+			}
+		}
+		start := time.Now()
+		p := batch.Processor[int, int]{
+			Mode:           mode,
+			MaxConcurrency: 4,
+			Ramp:           &batch.Ramp{Start: 1, Duration: 150 * time.Millisecond},
+			Run: func(ctx context.Context, index, slot, task int) int {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				raise(&peak, n)
+				if time.Since(start) < 30*time.Millisecond {
+					raise(&early, n)
+				}
+				time.Sleep(20 * time.Millisecond)
+				return index
+			},
+		}
+
+		if results := p.Process(context.Background(), make([]int, 40)); len(results) != 40 {
+			t.Fatalf("模式 %v: 期望 40 个结果，实际 %d", mode, len(results))
+		}
+		if early != 1 || peak != 4 {
+			t.Errorf("模式 %v: 开始阶段并发 %d（期望1），峰值并发 %d（期望4）", mode, early, peak)
+		}
+
+		// 预热时间远长于任务总耗时，批次仍在任务完成后立即返回
+		p.Ramp = &batch.Ramp{Start: 1, Duration: 10 * time.Second}
+		begin := time.Now()
+		p.Process(context.Background(), make([]int, 3))
+		if elapsed := time.Since(begin); elapsed > time.Second {
+			t.Errorf("模式 %v: 任务完成后等待预热结束，耗时 %v", mode, elapsed)
+		}
+	}
+}
+
+// 指定优先级时并发数占满后按优先级从高到低执行，相同优先级按提交顺序
+func TestProcessorPriority(t *testing.T) {
+	priorities := []int{0, 5, 1, 5, 0, 9}
+	want := []int{5, 1, 3, 2, 0, 4}
+	for _, mode := range []batch.Mode{batch.ModePerTask, batch.ModeWorkerPool, batch.ModeErrGroup} {
+		var order []int
+		p := batch.Processor[int, int]{
+			Mode:           mode,
+			MaxConcurrency: 1,
+			Priority:       func(priority int) int { return priority },
+			Run: func(ctx context.Context, index, slot, task int) int {
+				order = append(order, index)
+				return index
+			},
+		}
+		p.Process(context.Background(), priorities)
+		if len(order) != len(want) {
+			t.Fatalf("模式 %v: 期望执行 %d 个任务，实际 %d 个", mode, len(want), len(order))
+		}
+		for i := range want {
+			if order[i] != want[i] {
+				t.Fatalf("模式 %v: 执行顺序 %v，期望 %v", mode, order, want)
+			}
+		}
+	}
+}
+
+// errgroup 模式：默认收集全部结果；fail_fast 时首个失败的任务取消其余任务
+func TestProcessorErrGroup(t *testing.T) {
+	for _, mode := range []batch.Mode{batch.ModeErrGroup, batch.ModeErrGroupFailFast} {
+		var cancelled []int
+		p := batch.Processor[int, int]{
+			Mode:           mode,
+			MaxConcurrency: 1,
+			Run: func(ctx context.Context, index, slot, task int) int {
+				if ctx.Err() != nil {
+					var failed *batch.TaskFailedError
+					if !errors.As(context.Cause(ctx), &failed) || failed.Index != 1 {
+						t.Errorf("模式 %v: 任务 %d 的取消原因 %v", mode, index, context.Cause(ctx))
+					}
+					cancelled = append(cancelled, index)
+					return 0
+				}
+				return task
+			},
+			Err: func(result int) error {
+				if result < 0 {
+					return errors.New("失败")
+				}
+				return nil
+			},
+		}
+		results := p.Process(context.Background(), []int{1, -1, 3, 4})
+		if len(results) != 4 {
+			t.Fatalf("模式 %v: 期望收集4个结果，实际 %d 个", mode, len(results))
+		}
+		want := 0
+		if mode == batch.ModeErrGroupFailFast {
+			want = 2
+		}
+		if len(cancelled) != want {
+			t.Errorf("模式 %v: 被取消的任务 %v，期望 %d 个", mode, cancelled, want)
+		}
+	}
+}
+
+// 运行中调高并发数立即生效，调低后超出的槽位在任务完成后收回；批次返回后不能再调整
+func TestProcessorConcurrencyResize(t *testing.T) {
+	for _, mode := range []batch.Mode{batch.ModePerTask, batch.ModeWorkerPool} {
+		var (
+			control          batch.Concurrency
+			running, peak    atomic.Int64
+			lowered, checked atomic.Bool
+		)
+		p := batch.Processor[int, int]{
+			Mode:           mode,
+			MaxConcurrency: 2,
+			Concurrency:    &control,
+			Run: func(ctx context.Context, index, slot, task int) int {
+				n := running.Add(1)
+				defer running.Add(-1)
+				if lowered.Load() {
+					checked.Store(true)
+					if n > 1 || slot != 0 {
+						t.Errorf("模式 %v: 调低到1后并发数 %d，槽位 %d", mode, n, slot)
+					}
+				} else if n > peak.Load() {
+					peak.Store(n)
+				}
+				time.Sleep(10 * time.Millisecond)
+				return index
+			},
+		}
+
+		done := make(chan int)
+		go func() { done <- len(p.Process(context.Background(), make([]int, 60))) }()
+		for control.Limit() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		if n, ok := control.Set(6); !ok || n != 6 {
+			t.Fatalf("模式 %v: 调整并发数返回 %d, %v", mode, n, ok)
+		}
+		time.Sleep(50 * time.Millisecond)
+		control.Set(1)
+		// 等待调低前开始的任务完成
+		time.Sleep(30 * time.Millisecond)
+		lowered.Store(true)
+
+		if n := <-done; n != 60 {
+			t.Fatalf("模式 %v: 期望收集60个结果，实际 %d 个", mode, n)
+		}
+		if peak.Load() < 4 {
+			t.Errorf("模式 %v: 调高到6后并发数峰值只有 %d", mode, peak.Load())
+		}
+		if !checked.Load() {
+			t.Errorf("模式 %v: 调低后没有任务执行", mode)
+		}
+		if _, ok := control.Set(3); ok {
+			t.Errorf("模式 %v: 批次返回后仍可调整并发数", mode)
+		}
+	}
+}
+
+// 限速与并发数无关：并发数充足时开始执行的速度仍受令牌补充速度限制；ctx 结束时不再等待
+func TestProcessorRateLimit(t *testing.T) {
+	p := batch.Processor[int, int]{
+		MaxConcurrency: 10,
+		RateLimit:      batch.NewRateLimiter(100, 1),
+		Run: func(ctx context.Context, index, slot, task int) int {
+			return index
+		},
+	}
+	start := time.Now()
+	if n := len(p.Process(context.Background(), make([]int, 20))); n != 20 {
+		t.Fatalf("期望收集20个结果，实际 %d 个", n)
+	}
+	// 初始1个令牌，其余19个每10ms补充一个
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("20个任务在 %v 内全部开始，未按每秒100个限速", elapsed)
+	}
+
+	limiter := batch.NewRateLimiter(1, 1)
+	limiter.Wait(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ctx 到期后等待返回 %v", err)
+	}
+	if batch.NewRateLimiter(0, 1) != nil {
+		t.Error("rate 为0时应不限速")
+	}
+}
+
+// 运行中调高限速后，后续的调用按新的速率取得令牌；rate 不大于0时不做调整
+func TestRateLimiterSetRate(t *testing.T) {
+	limiter := batch.NewRateLimiter(1, 1)
+	limiter.Wait(context.Background())
+	limiter.SetRate(1000, 5)
+	if limiter.Rate() != 1000 || limiter.Burst() != 5 {
+		t.Fatalf("调整后的限速 %v/%d", limiter.Rate(), limiter.Burst())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 10; i++ {
+		if err := limiter.Wait(ctx); err != nil {
+			t.Fatalf("调高限速后第 %d 次等待返回 %v", i+1, err)
+		}
+	}
+
+	limiter.SetRate(0, 1)
+	if limiter.Rate() != 1000 || limiter.Burst() != 5 {
+		t.Errorf("rate 为0时不应调整，实际 %v/%d", limiter.Rate(), limiter.Burst())
+	}
+}
+
+// 按依赖关系调度：依赖的输出可读取，前置任务失败时后继任务逐层跳过
+func TestProcessorDeps(t *testing.T) {
+	type task struct {
+		value int
+		deps  []int
+	}
+	type result struct {
+		index, value int
+		err          error
+	}
+	// 任务2使用任务0、1的输出；任务3失败，依赖它的任务4以及依赖任务4的任务5跳过
+	tasks := []task{{value: 1}, {value: 2}, {deps: []int{0, 1}}, {value: -1}, {deps: []int{3}}, {deps: []int{4, 0}}}
+	var running, peak int32
+	p := batch.Processor[task, result]{
+		MaxConcurrency: 4,
+		Deps:           func(t task) []int { return t.deps },
+		Err:            func(r result) error { return r.err },
+		Skip: func(ctx context.Context, index int, t task, err error) result {
+			return result{index: index, err: err}
+		},
+		Run: func(ctx context.Context, index, slot int, t task) result {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			if t.value < 0 {
+				return result{index: index, err: errors.New("失败")}
+			}
+			value := t.value
+			for _, dep := range batch.DependencyResults[result](ctx) {
+				value += dep.value
+			}
+			return result{index: index, value: value}
+		},
+	}
+
+	results := p.Process(context.Background(), tasks)
+	if len(results) != len(tasks) {
+		t.Fatalf("期望 %d 个结果，实际 %d 个", len(tasks), len(results))
+	}
+	byIndex := make(map[int]result)
+	for _, r := range results {
+		byIndex[r.index] = r
+	}
+	if byIndex[2].value != 3 || byIndex[2].err != nil {
+		t.Errorf("任务2应读到任务0、1的输出，结果 %+v", byIndex[2])
+	}
+	var depErr *batch.DependencyError
+	if !errors.As(byIndex[4].err, &depErr) || depErr.Dep != 3 {
+		t.Errorf("任务4应因任务3失败而跳过，结果 %+v", byIndex[4])
+	}
+	if !errors.As(byIndex[5].err, &depErr) || depErr.Dep != 4 {
+		t.Errorf("任务5应因任务4被跳过而跳过，结果 %+v", byIndex[5])
+	}
+	if peak < 2 {
+		t.Errorf("互不依赖的任务应并发执行，峰值并发 %d", peak)
+	}
+
+	if err := batch.CheckDeps([]task{{deps: []int{1}}, {deps: []int{0}}}, p.Deps); !errors.Is(err, batch.ErrDependencyCycle) {
+		t.Errorf("循环依赖应返回 ErrDependencyCycle，实际 %v", err)
+	}
+	if err := batch.CheckDeps([]task{{deps: []int{5}}}, p.Deps); err == nil {
+		t.Error("依赖不存在的任务应返回错误")
+	}
+}
+
+// 分块执行：块内并发，上一块全部完成并暂停后才开始下一块；超时后不再开始剩余的块
+func TestProcessorChunks(t *testing.T) {
+	var mu sync.Mutex
+	var running, peak, finished int
+	var startedEarly []int
+	p := batch.Processor[int, int]{
+		MaxConcurrency: 10,
+		Chunks:         &batch.Chunks{Size: 4, Pause: 50 * time.Millisecond},
+		Run: func(ctx context.Context, index, slot, task int) int {
+			mu.Lock()
+			if finished < index/4*4 {
+				startedEarly = append(startedEarly, index)
+			}
+			running++
+			peak = max(peak, running)
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			finished++
+			mu.Unlock()
+			return index
+		},
+	}
+
+	start := time.Now()
+	results := p.Process(context.Background(), make([]int, 10))
+	sort.Ints(results)
+	for i, index := range results {
+		if index != i {
+			t.Fatalf("任务序号 %v 应覆盖 0-9", results)
+		}
+	}
+	if len(results) != 10 || peak > 4 || len(startedEarly) > 0 {
+		t.Errorf("结果 %d 个，峰值并发 %d，上一块完成前开始的任务 %v", len(results), peak, startedEarly)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("3块之间应各暂停50ms，实际耗时 %v", elapsed)
+	}
+
+	p.Timeout = 30 * time.Millisecond
+	p.Chunks.Pause = 100 * time.Millisecond
+	if n := len(p.Process(context.Background(), make([]int, 10))); n != 4 {
+		t.Errorf("超时发生在第一次暂停中，期望只收集到第一块的4个结果，实际 %d 个", n)
+	}
+}
+
+// Reorder 按任务序号输出按完成顺序到达的结果，Flush 跳过没有结果的任务
+func TestReorder(t *testing.T) {
+	var out []int
+	r := &batch.Reorder[int]{Index: func(i int) int { return i }, Emit: func(i int) { out = append(out, i) }}
+	for _, i := range []int{2, 0, 1, 5, 3} {
+		r.Add(i)
+	}
+	if want := []int{0, 1, 2, 3}; !reflect.DeepEqual(out, want) {
+		t.Errorf("输出 %v，期望 %v", out, want)
+	}
+	r.Flush()
+	if want := []int{0, 1, 2, 3, 5}; !reflect.DeepEqual(out, want) {
+		t.Errorf("Flush 后输出 %v，期望 %v", out, want)
+	}
+}
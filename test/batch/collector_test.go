@@ -0,0 +1,72 @@
+package batch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+)
+
+// 通道关闭时正常结束，结果按写入顺序交给 OnResult
+func TestCollectorCompleted(t *testing.T) {
+	results := make(chan int, 3)
+	results <- 1
+	results <- 2
+	results <- 3
+	close(results)
+
+	var got []int
+	c := batch.Collector[int]{OnResult: func(r int) { got = append(got, r) }}
+	n, reason := c.Collect(context.Background(), results)
+	if n != 3 || reason != batch.StopCompleted || len(got) != 3 || got[2] != 3 {
+		t.Fatalf("n=%d reason=%v got=%v", n, reason, got)
+	}
+}
+
+// 指定 Expected 时收齐即结束，不等待通道关闭
+func TestCollectorExpected(t *testing.T) {
+	results := make(chan int, 2)
+	results <- 1
+	results <- 2
+	c := batch.Collector[int]{Expected: 2}
+	if n, reason := c.Collect(context.Background(), results); n != 2 || reason != batch.StopCompleted {
+		t.Fatalf("n=%d reason=%v", n, reason)
+	}
+}
+
+// 取消时已写入通道的结果仍被收集，不会因为与取消同时就绪而被随机丢弃
+func TestCollectorKeepsReadyResultsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 50; i++ {
+		results := make(chan int, 5)
+		for j := 0; j < 5; j++ {
+			results <- j
+		}
+		c := batch.Collector[int]{}
+		if n, reason := c.Collect(ctx, results); n != 5 || reason != batch.StopCancelled {
+			t.Fatalf("期望收集5个结果并以取消结束，实际 n=%d reason=%v", n, reason)
+		}
+	}
+}
+
+// 超时后不再等待尚未写入通道的结果
+func TestCollectorTimeout(t *testing.T) {
+	results := make(chan int, 2)
+	results <- 1
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		results <- 2
+	}()
+
+	c := batch.Collector[int]{Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	n, reason := c.Collect(context.Background(), results)
+	if n != 1 || reason != batch.StopTimeout {
+		t.Fatalf("n=%d reason=%v", n, reason)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Fatalf("超时后未及时返回，耗时 %v", elapsed)
+	}
+}
@@ -0,0 +1,55 @@
+package chain
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 只映射成功的结果；单个字段引用保留原类型，其余字符串按模板求值；each 将列表中的每一项展开为一个任务
+func TestChainMapping(t *testing.T) {
+	var results []map[string]interface{}
+	json.Unmarshal([]byte(`[
+		{"id": 0, "status": "success", "data": {"output_path": "/tmp/a.gz", "size": 10, "lines": ["x", "y"]}},
+		{"id": 1, "status": "failed", "data": null, "error": "下载失败"},
+		{"id": 2, "status": "success", "data": {"output_path": "/tmp/c.gz", "size": 30, "lines": ["z"]}}
+	]`), &results)
+
+	mapping := services.ChainMapping{Map: map[string]interface{}{
+		"file_path":    "{{.data.output_path}}",
+		"file_name":    "report-{{.id}}.gz",
+		"process_type": "hash",
+		"priority":     "{{.data.size}}",
+	}}
+	tasks, err := mapping.Tasks(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []interface{}{
+		map[string]interface{}{"file_path": "/tmp/a.gz", "file_name": "report-0.gz", "process_type": "hash", "priority": float64(10)},
+		map[string]interface{}{"file_path": "/tmp/c.gz", "file_name": "report-2.gz", "process_type": "hash", "priority": float64(30)},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("映射结果 %v，期望 %v", tasks, want)
+	}
+
+	each := services.ChainMapping{Status: "success", Each: "data.lines", Map: "{{.item}}@{{.id}}"}
+	tasks, err = each.Tasks(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tasks, []interface{}{"x@0", "y@0", "z@2"}) {
+		t.Errorf("展开列表的结果 %v", tasks)
+	}
+
+	all := services.ChainMapping{Status: "all", Map: "{{.data.output_path}}"}
+	if _, err := all.Tasks(results); !errors.Is(err, services.ErrInvalidChainMapping) {
+		t.Errorf("失败结果的 data 为空时应返回映射错误，实际 %v", err)
+	}
+	if _, err := (services.ChainMapping{Map: "{{.id"}).Tasks(results); !errors.Is(err, services.ErrInvalidChainMapping) {
+		t.Errorf("模板语法错误应返回映射错误，实际 %v", err)
+	}
+}
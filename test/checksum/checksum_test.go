@@ -0,0 +1,74 @@
+package checksum
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"concurrency-web-app/backend/services"
+)
+
+// writeRandomFile 生成指定大小的随机文件
+func writeRandomFile(t *testing.T, size int) string {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "data.bin")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 并发度不同时计算结果必须一致
+func TestChecksumIndependentOfConcurrency(t *testing.T) {
+	path := writeRandomFile(t, services.ChecksumChunkSize*2+123)
+
+	single, err := services.ComputeChecksum(context.Background(), path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parallel, err := services.ComputeChecksum(context.Background(), path, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(single.ChunkHashes) != 3 {
+		t.Fatalf("块数错误: %d", len(single.ChunkHashes))
+	}
+	if single.Checksum != parallel.Checksum {
+		t.Fatalf("校验和不一致: %s != %s", single.Checksum, parallel.Checksum)
+	}
+}
+
+// 修改某一块后应定位到对应的损坏块
+func TestVerifyLocatesCorruptedChunk(t *testing.T) {
+	path := writeRandomFile(t, services.ChecksumChunkSize*3)
+	recorded, err := services.ComputeChecksum(context.Background(), path, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt([]byte{0xff, 0x00, 0xff}, services.ChecksumChunkSize*2+10); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	result, err := services.VerifyChecksum(context.Background(), path, recorded.Checksum, recorded.JoinedChunkHashes(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != services.VerifyStatusCorrupted {
+		t.Fatalf("期望状态 corrupted，实际为 %s", result.Status)
+	}
+	if len(result.CorruptedChunks) != 1 || result.CorruptedChunks[0] != 2 {
+		t.Fatalf("损坏块定位错误: %v", result.CorruptedChunks)
+	}
+}
@@ -0,0 +1,103 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+
+	"gorm.io/gorm"
+)
+
+var masterKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+
+// newCipher 在临时目录的 SQLite 数据库上创建结果加密
+func newCipher(t *testing.T) (*services.ResultCipher, *gorm.DB) {
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "crypto.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := services.NewResultCipher(db, masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, db
+}
+
+// 加密后不含明文，相同的标识可以解密，标识不同时解密失败
+func TestSealOpen(t *testing.T) {
+	c, _ := newCipher(t)
+	plaintext := []byte(`{"response_body":"secret-token"}`)
+
+	sealed, err := c.Seal(1, "job_1", plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(sealed), "secret-token") {
+		t.Fatalf("密文中包含明文: %s", sealed)
+	}
+	opened, err := c.Open("job_1", sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("解密结果不正确: %s, %v", opened, err)
+	}
+	if _, err := c.Open("job_2", sealed); err == nil {
+		t.Error("标识不同时应解密失败")
+	}
+
+	// 启用加密前保存的明文结果原样返回
+	if opened, err := c.Open("job_1", plaintext); err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("明文结果应原样返回: %s, %v", opened, err)
+	}
+}
+
+// 轮换后新结果使用新版本，旧结果仍可解密；各租户的密钥相互独立
+func TestRotate(t *testing.T) {
+	c, db := newCipher(t)
+	before, err := c.Seal(1, "job_1", []byte("v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := c.Rotate(1)
+	if err != nil || key.Version != 2 {
+		t.Fatalf("轮换后应为 v2: %+v, %v", key, err)
+	}
+	after, err := c.Seal(1, "job_2", []byte("v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(after), `"key_version":2`) {
+		t.Errorf("轮换后应使用新版本: %s", after)
+	}
+	if opened, err := c.Open("job_1", before); err != nil || string(opened) != "v1" {
+		t.Errorf("轮换前的结果应仍可解密: %s, %v", opened, err)
+	}
+
+	keys, err := c.Keys(1)
+	if err != nil || len(keys) != 2 || keys[0].RetiredAt != nil || keys[1].RetiredAt == nil {
+		t.Fatalf("密钥版本不正确: %+v, %v", keys, err)
+	}
+
+	// 主密钥不同的实例无法解开数据密钥
+	other, err := services.NewResultCipher(db, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open("job_2", after); err == nil {
+		t.Error("主密钥不同时应解密失败")
+	}
+}
+
+// 主密钥为空时不加密，格式不正确时报错
+func TestNewResultCipher(t *testing.T) {
+	if c, err := services.NewResultCipher(nil, ""); c != nil || err != nil {
+		t.Errorf("未配置主密钥时应返回 nil: %v, %v", c, err)
+	}
+	if _, err := services.NewResultCipher(nil, "c2hvcnQ="); !errors.Is(err, services.ErrInvalidMasterKey) {
+		t.Errorf("期望 ErrInvalidMasterKey，实际 %v", err)
+	}
+}
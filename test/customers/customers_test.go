@@ -0,0 +1,121 @@
+package customers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// 订单记录被删除，内存中的结果、加密的产出物和访问日志中的客户ID被匿名化，其他客户不受影响
+func TestDeleteCustomerData(t *testing.T) {
+	dir := t.TempDir()
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(dir, "customers.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := services.NewResultCipher(db, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := services.NewJobManager(nil)
+	artifacts := &services.ArtifactService{DB: db, Dir: filepath.Join(dir, "artifacts"), Cipher: cipher}
+	fullResults := services.ResultLimit{MaxBytes: 64, Dir: filepath.Join(dir, "results"), Cipher: cipher}
+	service := &services.CustomerDataService{DB: db, Jobs: jobs, Artifacts: artifacts, FullResults: fullResults}
+
+	for _, customer := range []string{"CUST_0001", "CUST_0001", "CUST_0002"} {
+		db.Create(&models.Order{CustomerID: customer, ProductName: "p", Quantity: 1, Price: 1})
+	}
+	db.Create(&models.AccessLog{Method: "GET", Path: "/api/orders/CUST_0001", Route: "/api/orders/:id"})
+	// API调用记录：URL 或响应体中含客户ID的删除，只含前缀相同的其他客户ID的保留
+	db.Create(&models.APICall{URL: "https://crm.example.com/customers/CUST_0001", Method: "GET"})
+	db.Create(&models.APICall{URL: "https://crm.example.com/orders", Method: "POST", ResponseBody: `{"customer":"CUST_0001"}`})
+	db.Create(&models.APICall{URL: "https://crm.example.com/customers/CUST_00010", Method: "GET"})
+
+	job, _ := jobs.Start(context.Background(), "order", "alice", 2)
+	jobs.Finish(job, &services.BatchResult{TotalTasks: 2, SuccessTasks: 2, Results: []services.TaskResult{
+		{ID: 0, Success: true, Status: services.TaskStatusSuccess, Data: map[string]interface{}{"customer_id": "CUST_0001", "quantity": 1}},
+		{ID: 1, Success: true, Status: services.TaskStatusSuccess, Data: map[string]interface{}{"customer_id": "CUST_0002", "quantity": 2}},
+	}})
+	artifact, err := artifacts.SaveJobResult(job.Info(), job.Result())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 超出大小限制的完整结果：加密的结果中的字段被匿名化，响应体字符串中含客户ID的整个删除，其他客户的不受影响
+	fullDir := filepath.Join(fullResults.Dir, job.ID())
+	os.MkdirAll(fullDir, 0755)
+	sealedPath := filepath.Join(fullDir, "task_0.json")
+	sealed, err := cipher.Seal(0, sealedPath, []byte(`{"customer_id":"CUST_0001","quantity":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(sealedPath, sealed, 0644)
+	os.WriteFile(filepath.Join(fullDir, "task_1.json"), []byte(`{"body":"order for CUST_0001 shipped"}`), 0644)
+	os.WriteFile(filepath.Join(fullDir, "task_2.json"), []byte(`{"customer_id":"CUST_00010"}`), 0644)
+
+	report, err := service.Delete(context.Background(), "CUST_0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) > 0 || report.OrdersDeleted != 2 || report.APICallsDeleted != 2 || report.ArtifactsRedacted != 1 || report.ResultsRedacted != 2 ||
+		report.AccessLogsRedacted != 1 || len(report.JobsRedacted) != 1 || report.JobsRedacted[0] != job.ID() ||
+		report.FullResultsRedacted != 1 || report.FullResultsDeleted != 1 {
+		t.Fatalf("删除报告不正确: %+v", report)
+	}
+
+	if full, err := fullResults.Load(job.ID(), 0); err != nil || !strings.Contains(string(full), services.RedactedCustomerID) || strings.Contains(string(full), "CUST_0001") {
+		t.Errorf("完整结果未匿名化: %s, %v", full, err)
+	}
+	if raw, _ := os.ReadFile(sealedPath); !strings.Contains(string(raw), `"envelope":"v1"`) {
+		t.Errorf("匿名化后的完整结果应仍为加密格式: %.80s", raw)
+	}
+	if _, err := os.Stat(filepath.Join(fullDir, "task_1.json")); !os.IsNotExist(err) {
+		t.Errorf("字符串中含客户ID的完整结果应删除: %v", err)
+	}
+	if full, _ := fullResults.Load(job.ID(), 2); !strings.Contains(string(full), "CUST_00010") {
+		t.Errorf("其他客户的完整结果不应修改: %s", full)
+	}
+
+	results := job.Result().Results
+	if results[0].Data.(map[string]interface{})["customer_id"] != services.RedactedCustomerID ||
+		results[1].Data.(map[string]interface{})["customer_id"] != "CUST_0002" {
+		t.Errorf("内存中的结果不正确: %+v", results)
+	}
+
+	output, err := artifacts.LoadJobOutput(job.ID(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := output.Results[0]["data"].(map[string]interface{}); data["customer_id"] != services.RedactedCustomerID {
+		t.Errorf("产出物未匿名化: %v", data)
+	}
+	raw, _ := os.ReadFile(artifact.Path)
+	if !strings.Contains(string(raw), `"envelope":"v1"`) {
+		t.Errorf("匿名化后的产出物应仍为加密格式: %.80s", raw)
+	}
+
+	var log models.AccessLog
+	db.First(&log)
+	if log.Path != "/api/orders/"+services.RedactedCustomerID {
+		t.Errorf("访问日志路径未匿名化: %s", log.Path)
+	}
+
+	var calls []models.APICall
+	db.Find(&calls)
+	if len(calls) != 1 || !strings.HasSuffix(calls[0].URL, "CUST_00010") {
+		t.Errorf("剩余的API调用记录 %+v", calls)
+	}
+
+	// 再次删除时没有可处理的数据
+	report, err = service.Delete(context.Background(), "CUST_0001")
+	if err != nil || report.OrdersDeleted != 0 || report.APICallsDeleted != 0 || report.ResultsRedacted != 0 || report.AccessLogsRedacted != 0 || report.FullResultsRedacted+report.FullResultsDeleted != 0 {
+		t.Errorf("重复删除应没有可处理的数据: %+v, %v", report, err)
+	}
+}
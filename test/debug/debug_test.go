@@ -0,0 +1,108 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+func newAPIService() *services.APICallService {
+	return &services.APICallService{
+		MaxConcurrency: 1,
+		Timeouts:       services.APITimeouts{Request: time.Second, Batch: 10 * time.Second},
+	}
+}
+
+// 启用调试捕获时，失败的任务保存输入、下游响应和执行环境，凭据类请求头隐去
+func TestCapturesFailedTask(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			w.Write([]byte("ok"))
+			return
+		}
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("维护中"))
+	}))
+	defer server.Close()
+
+	capture := &services.DebugCapture{Dir: t.TempDir()}
+	jobs := services.NewJobManager(nil)
+	jobs.Debug = capture
+	job, ctx := jobs.Start(context.Background(), "api", "alice", 2)
+	job.EnableDebugCapture()
+
+	tasks := []services.APICallTask{
+		{URL: server.URL + "/ok", Method: "GET"},
+		{URL: server.URL + "/down", Method: "GET", Headers: map[string]string{"Authorization": "Bearer secret", "X-Trace": "t1"}},
+	}
+	newAPIService().BatchCallAPIs(ctx, tasks)
+
+	bundles, err := capture.List(job.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundles) != 1 || bundles[0].TaskID != 1 {
+		t.Fatalf("调试包 %+v，期望只有任务 1 的调试包", bundles)
+	}
+	if n := job.Info().DebugBundles; n != 1 {
+		t.Fatalf("任务记录的调试包数 %d，期望 1", n)
+	}
+
+	data, err := capture.Load(job.ID(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bundle struct {
+		Input       services.APICallTask         `json:"input"`
+		Result      services.TaskResult          `json:"result"`
+		Environment services.DebugEnvironment    `json:"environment"`
+		Response    *services.DownstreamResponse `json:"response"`
+	}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Input.Headers["Authorization"] == "Bearer secret" || bundle.Input.Headers["X-Trace"] != "t1" {
+		t.Fatalf("请求头 %v，期望隐去 Authorization 并保留其他请求头", bundle.Input.Headers)
+	}
+	if bundle.Response == nil || bundle.Response.StatusCode != 503 || bundle.Response.Body != "维护中" ||
+		bundle.Response.Header.Get("Retry-After") != "5" {
+		t.Fatalf("下游响应 %+v", bundle.Response)
+	}
+	if bundle.Result.Status != services.TaskStatusFailed || bundle.Environment.GoVersion == "" || bundle.Environment.Attempts != 1 {
+		t.Fatalf("结果 %+v，环境 %+v", bundle.Result, bundle.Environment)
+	}
+
+	if _, err := capture.Load(job.ID(), 0); !errors.Is(err, services.ErrDebugBundleNotFound) {
+		t.Fatalf("成功的任务不应有调试包，err=%v", err)
+	}
+}
+
+// 未启用调试捕获的批次不保存调试包
+func TestCaptureIsOptIn(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	capture := &services.DebugCapture{Dir: t.TempDir()}
+	jobs := services.NewJobManager(nil)
+	jobs.Debug = capture
+	job, ctx := jobs.Start(context.Background(), "api", "alice", 1)
+
+	newAPIService().BatchCallAPIs(ctx, []services.APICallTask{{URL: server.URL, Method: "GET"}})
+
+	bundles, err := capture.List(job.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bundles) != 0 {
+		t.Fatalf("未启用调试捕获时保存了调试包: %+v", bundles)
+	}
+}
@@ -0,0 +1,110 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// failingResolver 每次查询都失败的解析器，queries 记录实际发出的查询次数
+func failingResolver(queries *int32) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			atomic.AddInt32(queries, 1)
+			return nil, errors.New("dns server unreachable")
+		},
+	}
+}
+
+// 解析失败的主机只解析一次，指向它的任务以 dns 错误类别直接失败，不重试
+func TestResolveFailureClassified(t *testing.T) {
+	var queries int32
+	s := &services.APICallService{
+		MaxConcurrency: 2,
+		Timeouts:       services.APITimeouts{Request: time.Second, Batch: 10 * time.Second},
+		DNS:            &services.DNSCache{Resolver: failingResolver(&queries)},
+		Retry:          &services.RetryPolicy{MaxAttempts: 3, Backoff: time.Second, Retryable: services.RetryTransient},
+	}
+	var tasks []services.APICallTask
+	for i := 0; i < 20; i++ {
+		tasks = append(tasks, services.APICallTask{URL: "http://api.cold-start.invalid/items", Method: "GET"})
+	}
+
+	start := time.Now()
+	result := s.BatchCallAPIs(context.Background(), tasks)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("解析失败的任务应直接失败，实际耗时 %v", elapsed)
+	}
+	if result.FailedTasks != len(tasks) {
+		t.Fatalf("期望全部失败，实际失败 %d 个", result.FailedTasks)
+	}
+	for _, r := range result.Results {
+		if r.ErrorClass != services.ErrorClassDNS || len(r.Attempts) != 0 || !strings.Contains(r.Error, "api.cold-start.invalid") {
+			t.Fatalf("错误类别或尝试记录不正确: class=%q attempts=%d error=%s", r.ErrorClass, len(r.Attempts), r.Error)
+		}
+	}
+
+	// 失败结果在 NegativeTTL 内命中缓存，不再发出查询
+	before := atomic.LoadInt32(&queries)
+	s.BatchCallAPIs(context.Background(), tasks)
+	if after := atomic.LoadInt32(&queries); after != before {
+		t.Errorf("缓存期内重复查询: %d -> %d", before, after)
+	}
+}
+
+// 连接经由缓存的解析结果建立，缓存过期后重新解析
+func TestCachedDial(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	cache := &services.DNSCache{TTL: 50 * time.Millisecond}
+	s := &services.APICallService{
+		MaxConcurrency: 4,
+		Timeouts:       services.APITimeouts{Request: time.Second, Batch: 10 * time.Second},
+		DNS:            cache,
+	}
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	result := s.BatchCallAPIs(context.Background(), []services.APICallTask{{URL: url, Method: "GET"}, {URL: url, Method: "GET"}})
+	if result.SuccessTasks != 2 {
+		t.Fatalf("期望2个成功，实际 %d 个，结果: %+v", result.SuccessTasks, result.Results)
+	}
+
+	if _, err := cache.Lookup(context.Background(), "localhost"); err != nil {
+		t.Fatalf("解析 localhost 失败: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if addrs, err := cache.Lookup(context.Background(), "localhost"); err != nil || len(addrs) == 0 {
+		t.Fatalf("过期后重新解析失败: %v %v", addrs, err)
+	}
+}
+
+// 解析新的主机时清理已过期的缓存项，访问过的主机不会一直留在缓存中
+func TestExpiredEntriesPruned(t *testing.T) {
+	var queries int32
+	cache := &services.DNSCache{NegativeTTL: 20 * time.Millisecond, Resolver: failingResolver(&queries)}
+	for _, host := range []string{"a.invalid", "b.invalid", "c.invalid"} {
+		cache.Lookup(context.Background(), host)
+	}
+	if n := cache.Len(); n != 3 {
+		t.Fatalf("缓存 %d 个主机，期望 3", n)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if _, err := cache.Lookup(context.Background(), "d.invalid"); err == nil {
+		t.Fatal("期望解析失败")
+	}
+	if n := cache.Len(); n != 1 {
+		t.Errorf("过期的缓存项应被清理，剩余 %d 个主机", n)
+	}
+}
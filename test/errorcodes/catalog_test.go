@@ -0,0 +1,77 @@
+package errorcodes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 未成功的任务按失败原因记录错误码和参数，成功的任务没有错误码
+func TestTaskErrorCodes(t *testing.T) {
+	service := &services.OrderProcessService{MaxConcurrency: 4, Timeout: 10 * time.Second, PerTaskTimeout: 300 * time.Millisecond}
+	result := service.BatchProcessOrders(context.Background(), []services.OrderTask{
+		{ID: 1, Quantity: 1, Price: 10},
+		{ID: 7, Quantity: 1, Price: 10},  // 库存不足
+		{ID: 50, Quantity: 1, Price: 10}, // 处理时间超过单个任务的预算
+		{ID: 2, Quantity: 1, Price: 10, DependsOn: []int{1}},
+	})
+
+	want := []struct {
+		code   string
+		params map[string]interface{}
+	}{
+		{"", nil},
+		{services.ErrCodeOutOfStock, map[string]interface{}{"order_id": 7}},
+		{services.ErrCodeTaskTimeout, map[string]interface{}{"timeout": "300ms"}},
+		{services.ErrCodeDependency, nil},
+	}
+	for _, r := range result.Results {
+		w := want[r.ID]
+		if r.ErrorCode != w.code || len(r.ErrorParams) != len(w.params) {
+			t.Errorf("任务 %d: 错误码 %q 参数 %v，期望 %q %v", r.ID, r.ErrorCode, r.ErrorParams, w.code, w.params)
+			continue
+		}
+		for name, value := range w.params {
+			if r.ErrorParams[name] != value {
+				t.Errorf("任务 %d: 参数 %s=%v，期望 %v", r.ID, name, r.ErrorParams[name], value)
+			}
+		}
+	}
+}
+
+// 消息按语言渲染，未知语言和缺少翻译的错误码回退到中文
+func TestErrorMessageCatalog(t *testing.T) {
+	params := map[string]interface{}{"order_id": 7}
+	if got := services.ErrorMessage("en", services.ErrCodeOutOfStock, params); got != "Order 7 is out of stock" {
+		t.Errorf("英文消息: %q", got)
+	}
+	if got := services.ErrorMessage("fr", services.ErrCodeOutOfStock, params); got != "订单 7 库存不足" {
+		t.Errorf("未知语言应使用中文: %q", got)
+	}
+
+	services.RegisterErrorMessages("ja", map[string]string{services.ErrCodeCancelled: "タスクはキャンセルされました"})
+	if got := services.ErrorMessage("ja", services.ErrCodeTaskPanic, nil); got != "任务执行异常" {
+		t.Errorf("缺少翻译时应使用中文: %q", got)
+	}
+
+	for accept, want := range map[string]string{
+		"en-US,en;q=0.9": "en",
+		"de, ja;q=0.5":   "ja",
+		"fr":             services.DefaultErrorLanguage,
+		"":               services.DefaultErrorLanguage,
+	} {
+		if got := services.MatchErrorLanguage(accept); got != want {
+			t.Errorf("Accept-Language %q: 匹配 %q，期望 %q", accept, got, want)
+		}
+	}
+
+	// 每个内置错误码都有英文翻译
+	en := services.ErrorCatalog("en")
+	for code := range services.ErrorCatalog(services.DefaultErrorLanguage) {
+		if en[code] == "" {
+			t.Errorf("错误码 %s 缺少英文翻译", code)
+		}
+	}
+}
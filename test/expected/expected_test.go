@@ -0,0 +1,85 @@
+package expected
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+func raw(items ...string) []json.RawMessage {
+	out := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		out[i] = json.RawMessage(item)
+	}
+	return out
+}
+
+// 只比较预期中列出的字段，不符的任务计为 mismatch 并从成功数移到失败数
+func TestExpectationsApply(t *testing.T) {
+	expect, err := services.NewExpectations(raw(
+		`{"total_price": 20, "status": "processed"}`,
+		`{"total_price": 99}`,
+		`null`,
+		`{"total_price": 1}`,
+	), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := func(id int, price float64) services.TaskResult {
+		return services.TaskResult{ID: id, Success: true, Status: services.TaskStatusSuccess, Data: map[string]interface{}{
+			"total_price": price, "status": "processed", "processed_at": time.Now(),
+		}}
+	}
+	result := expect.Apply(&services.BatchResult{TotalTasks: 4, SuccessTasks: 3, FailedTasks: 1, Results: []services.TaskResult{
+		order(0, 20),
+		order(1, 30),
+		order(2, 5),
+		{ID: 3, Status: services.TaskStatusFailed, Error: "订单 3 库存不足"},
+	}})
+
+	if result.SuccessTasks != 2 || result.FailedTasks != 2 || result.MismatchTasks != 1 {
+		t.Fatalf("汇总不正确: success=%d failed=%d mismatch=%d", result.SuccessTasks, result.FailedTasks, result.MismatchTasks)
+	}
+	if r := result.Results[0]; r.Status != services.TaskStatusSuccess || !r.Success {
+		t.Errorf("相符的任务应保持成功: %+v", r)
+	}
+	r := result.Results[1]
+	if r.Status != services.TaskStatusMismatch || r.Success || len(r.Mismatches) != 1 ||
+		r.Mismatches[0].Path != "total_price" || r.Mismatches[0].Expected != 99.0 || r.Mismatches[0].Actual != 30.0 {
+		t.Errorf("不符的任务: %+v", r)
+	}
+	if r := result.Results[2]; r.Status != services.TaskStatusSuccess {
+		t.Errorf("预期为 null 的任务不比较: %+v", r)
+	}
+	if r := result.Results[3]; r.Status != services.TaskStatusFailed || r.Mismatches != nil {
+		t.Errorf("失败的任务应保持原状态: %+v", r)
+	}
+}
+
+// 嵌套对象和数组给出不符字段的路径，缺少的字段 actual 为 nil
+func TestExpectationsNestedPaths(t *testing.T) {
+	expect, err := services.NewExpectations(raw(`{"items": [{"name": "a"}, {"name": "b"}], "meta": {"source": "x"}}`), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := expect.Check(services.TaskResult{ID: 0, Success: true, Status: services.TaskStatusSuccess, Data: map[string]interface{}{
+		"items": []map[string]string{{"name": "a"}, {"name": "c"}},
+		"meta":  map[string]interface{}{},
+	}})
+	if r.Status != services.TaskStatusMismatch || len(r.Mismatches) != 2 {
+		t.Fatalf("结果 %+v", r)
+	}
+	if r.Mismatches[0].Path != "items[1].name" || r.Mismatches[1].Path != "meta.source" || r.Mismatches[1].Actual != nil {
+		t.Errorf("不符字段 %+v", r.Mismatches)
+	}
+
+	if _, err := services.NewExpectations(raw(`{}`), 2); err == nil {
+		t.Error("预期输出项数与任务数不符时应返回错误")
+	}
+	if e, err := services.NewExpectations(nil, 2); e != nil || err != nil {
+		t.Errorf("未提交预期输出时应返回 nil: %v %v", e, err)
+	}
+}
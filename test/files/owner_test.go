@@ -0,0 +1,61 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+
+	"gorm.io/gorm"
+)
+
+// 按ID获取、列表、搜索和更新元数据都只在该用户上传的文件中进行
+func TestFilesScopedByOwner(t *testing.T) {
+	files := newFiles(t)
+	alice := upload(t, files, "alice", "data.csv")
+	upload(t, files, "bob", "other.csv")
+
+	if _, err := files.Get(alice.ID, "bob"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("其他用户按ID获取期望不存在，实际 %v", err)
+	}
+	if _, err := files.UpdateMetadata(alice.ID, "bob", []string{"x"}, ""); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("其他用户更新元数据期望不存在，实际 %v", err)
+	}
+	if list, err := files.List("alice"); err != nil || len(list) != 1 || list[0].ID != alice.ID {
+		t.Errorf("alice 的文件列表 %+v %v", list, err)
+	}
+	if found, err := files.Search(services.FileSearchQuery{Owner: "bob", Name: "data"}); err != nil || len(found) != 0 {
+		t.Errorf("bob 不应搜索到 alice 的文件: %+v %v", found, err)
+	}
+}
+
+// 上传目录中还没有文件信息的文件登记给指定用户，已登记的文件和没有归属的记录不会重复登记
+func TestRegisterExisting(t *testing.T) {
+	files := newFiles(t)
+	root := filepath.Join(t.TempDir(), "uploads")
+	os.MkdirAll(filepath.Join(root, "2024", "01", "02"), 0755)
+	os.WriteFile(filepath.Join(root, "legacy.txt"), []byte("old"), 0644)
+	known := filepath.Join(root, "2024", "01", "02", "known.txt")
+	os.WriteFile(known, []byte("new"), 0644)
+	if err := files.RecordUpload(&models.FileTask{FileName: "known.txt", OriginalName: "known.txt", FilePath: known}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := files.RegisterExisting(root, "anonymous")
+	if err != nil || n != 1 {
+		t.Fatalf("期望登记 1 个文件，实际 %d %v", n, err)
+	}
+	list, _ := files.List("anonymous")
+	if len(list) != 2 || list[1].OriginalName != "legacy.txt" || list[1].FileSize != 3 || list[1].Version != 1 {
+		t.Fatalf("登记后的文件列表 %+v", list)
+	}
+	if n, err := files.RegisterExisting(root, "anonymous"); err != nil || n != 0 {
+		t.Errorf("重复执行不应再登记，实际 %d %v", n, err)
+	}
+	if n, err := files.RegisterExisting(filepath.Join(root, "missing"), "anonymous"); err != nil || n != 0 {
+		t.Errorf("目录不存在时期望 0，实际 %d %v", n, err)
+	}
+}
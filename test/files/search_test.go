@@ -0,0 +1,65 @@
+package files
+
+import (
+	"testing"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 搜索条件中的 %、_ 和 \ 按字面匹配，不作为通配符
+func TestSearchEscapesWildcards(t *testing.T) {
+	files := newFiles(t)
+	for _, name := range []string{"report_2024.csv", "report-2024.csv", "100%.txt", "1000.txt", `a\b.txt`, "ab.txt"} {
+		record := upload(t, files, "alice", name)
+		if _, err := files.UpdateMetadata(record.ID, "alice", []string{"q_1", "q-1%"}, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	plain := upload(t, files, "alice", "plain.txt")
+	if _, err := files.UpdateMetadata(plain.ID, "alice", []string{"qx1"}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(query services.FileSearchQuery) []string {
+		t.Helper()
+		query.Owner = "alice"
+		found, err := files.Search(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, file := range found {
+			names = append(names, file.OriginalName)
+		}
+		return names
+	}
+
+	cases := []struct {
+		query services.FileSearchQuery
+		want  []string
+	}{
+		{services.FileSearchQuery{Name: "report_"}, []string{"report_2024.csv"}},
+		{services.FileSearchQuery{Name: "%"}, []string{"100%.txt"}},
+		{services.FileSearchQuery{Name: `\`}, []string{`a\b.txt`}},
+		{services.FileSearchQuery{Tag: "q_1"}, []string{"report_2024.csv", "report-2024.csv", "100%.txt", "1000.txt", `a\b.txt`, "ab.txt"}},
+		{services.FileSearchQuery{Tag: "q%"}, nil},
+		{services.FileSearchQuery{Tag: "q-1%", Name: "1000"}, []string{"1000.txt"}},
+	}
+	for _, c := range cases {
+		got := names(c.query)
+		if len(got) != len(c.want) {
+			t.Errorf("搜索 %+v 得到 %v，期望 %v", c.query, got, c.want)
+			continue
+		}
+		want := make(map[string]bool, len(c.want))
+		for _, name := range c.want {
+			want[name] = true
+		}
+		for _, name := range got {
+			if !want[name] {
+				t.Errorf("搜索 %+v 得到 %v，期望 %v", c.query, got, c.want)
+				break
+			}
+		}
+	}
+}
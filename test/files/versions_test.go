@@ -0,0 +1,69 @@
+package files
+
+import (
+	"path/filepath"
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+func newFiles(t *testing.T) *services.FileMetadataService {
+	t.Helper()
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "files.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &services.FileMetadataService{DB: db}
+}
+
+func upload(t *testing.T, files *services.FileMetadataService, owner, name string) *models.FileTask {
+	t.Helper()
+	record := &models.FileTask{FileName: name, OriginalName: name, Owner: owner, FilePath: filepath.Join(owner, name)}
+	if err := files.RecordUpload(record); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+// 同名文件按用户分别编号版本，查找同名文件只在该用户上传的文件中进行
+func TestUploadVersionsScopedByOwner(t *testing.T) {
+	files := newFiles(t)
+	if v := upload(t, files, "alice", "data.csv").Version; v != 1 {
+		t.Fatalf("alice 的第一个版本为 %d", v)
+	}
+	if v := upload(t, files, "alice", "data.csv").Version; v != 2 {
+		t.Fatalf("alice 的第二个版本为 %d", v)
+	}
+	bob := upload(t, files, "bob", "data.csv")
+	if bob.Version != 1 {
+		t.Fatalf("bob 的第一个版本为 %d", bob.Version)
+	}
+
+	latest, err := files.LatestVersion("bob", "data.csv")
+	if err != nil || latest == nil || latest.ID != bob.ID {
+		t.Fatalf("bob 的最新版本 %+v，err=%v", latest, err)
+	}
+	if latest, err := files.LatestVersion("carol", "data.csv"); err != nil || latest != nil {
+		t.Fatalf("carol 没有上传过同名文件，实际 %+v，err=%v", latest, err)
+	}
+	if _, err := files.FindVersion("bob", "data.csv", 2); err == nil {
+		t.Error("bob 没有第二个版本，不应找到 alice 的文件")
+	}
+	if file, err := files.FindVersion("alice", "data.csv", 2); err != nil || file.Owner != "alice" {
+		t.Errorf("alice 的第二个版本 %+v，err=%v", file, err)
+	}
+}
+
+// 唯一索引拒绝同一用户同名文件的重复版本（如另一个实例同时分配了相同的版本）
+func TestUploadVersionUnique(t *testing.T) {
+	files := newFiles(t)
+	upload(t, files, "alice", "data.csv")
+	duplicate := &models.FileTask{FileName: "x", OriginalName: "data.csv", Owner: "alice", Version: 1, FilePath: "x", ProcessType: "upload"}
+	if err := files.DB.Create(duplicate).Error; err == nil {
+		t.Fatal("重复的版本应违反唯一索引")
+	}
+	if v := upload(t, files, "alice", "data.csv").Version; v != 2 {
+		t.Errorf("之后的上传应分配版本 2，实际 %d", v)
+	}
+}
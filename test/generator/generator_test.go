@@ -0,0 +1,103 @@
+package generator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"concurrency-web-app/backend/services"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+// 相同的种子和规则生成相同的数据，未指定种子时返回实际使用的种子
+func TestGenerateDeterministic(t *testing.T) {
+	spec := services.GeneratorSpec{Count: 50, Seed: 42}
+	a, err := spec.Generate(services.DefaultOrderFields())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := spec.Generate(services.DefaultOrderFields())
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("相同种子生成的数据不一致")
+	}
+	if a.Items[2]["id"] != int64(3) || a.Items[2]["customer_id"] != "CUST_0003" {
+		t.Fatalf("默认订单字段不正确: %v", a.Items[2])
+	}
+
+	random, _ := services.GeneratorSpec{Count: 1}.Generate(services.DefaultOrderFields())
+	if random.Seed == 0 {
+		t.Fatal("未返回实际使用的种子")
+	}
+}
+
+// 数值落在 [min, max] 内，正态分布超出区间时取边界值
+func TestGenerateRanges(t *testing.T) {
+	spec := services.GeneratorSpec{Count: 500, Seed: 1, Fields: map[string]*services.FieldSpec{
+		"price":    {Min: ptr(10), Max: ptr(20), Distribution: services.DistNormal, StdDev: ptr(100)},
+		"quantity": {Min: ptr(1), Max: ptr(3), Integer: true, Distribution: services.DistExponential},
+	}}
+	data, err := spec.Generate(services.DefaultOrderFields())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range data.Items {
+		price := item["price"].(float64)
+		quantity := item["quantity"].(int64)
+		if price < 10 || price > 20 || quantity < 1 || quantity > 3 {
+			t.Fatalf("数值超出范围: %v", item)
+		}
+	}
+}
+
+// 权重为0的候选值不会被选中，null 去掉默认字段
+func TestGenerateWeightsAndRemove(t *testing.T) {
+	spec := services.GeneratorSpec{Count: 200, Seed: 3, Fields: map[string]*services.FieldSpec{
+		"product_name": {Values: []interface{}{"A", "B"}, Weights: []float64{1, 0}},
+		"customer_id":  nil,
+	}}
+	data, err := spec.Generate(services.DefaultOrderFields())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range data.Items {
+		if item["product_name"] != "A" {
+			t.Fatalf("选中了权重为0的候选值: %v", item)
+		}
+		if _, ok := item["customer_id"]; ok {
+			t.Fatal("未去掉 customer_id")
+		}
+	}
+}
+
+// 错误注入按概率替换字段值
+func TestGenerateErrorInjection(t *testing.T) {
+	spec := services.GeneratorSpec{Count: 20, Seed: 5, Fields: map[string]*services.FieldSpec{
+		"quantity": {Min: ptr(1), Max: ptr(5), Integer: true, Error: &services.FieldError{Rate: 1, Value: -1}},
+	}}
+	data, err := spec.Generate(services.DefaultOrderFields())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range data.Items {
+		if item["quantity"] != -1 {
+			t.Fatalf("未注入错误值: %v", item)
+		}
+	}
+}
+
+// 不正确的规则返回 ErrInvalidGenerator
+func TestGenerateInvalid(t *testing.T) {
+	cases := map[string]services.GeneratorSpec{
+		"count":   {Count: 0},
+		"min>max": {Count: 1, Fields: map[string]*services.FieldSpec{"price": {Min: ptr(5), Max: ptr(1)}}},
+		"weights": {Count: 1, Fields: map[string]*services.FieldSpec{"x": {Values: []interface{}{1, 2}, Weights: []float64{1}}}},
+		"range":   {Count: 1, Fields: map[string]*services.FieldSpec{"x": {Min: ptr(1)}}},
+		"dist":    {Count: 1, Fields: map[string]*services.FieldSpec{"x": {Min: ptr(1), Max: ptr(2), Distribution: "zipf"}}},
+	}
+	for name, spec := range cases {
+		if _, err := spec.Generate(services.DefaultOrderFields()); !errors.Is(err, services.ErrInvalidGenerator) {
+			t.Errorf("%s: 期望 ErrInvalidGenerator，得到 %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"concurrency-web-app/backend/handlers"
+)
+
+// 默认不信任 X-User-ID 请求头：未登录的请求为匿名用户，不能借请求头访问其他用户的任务
+func TestUserHeaderIgnoredByDefault(t *testing.T) {
+	r, h := newServer(t, nil)
+	job, _ := h.Jobs.Start(context.Background(), "order", "alice", 1)
+
+	if w := do(r, http.MethodGet, "/api/jobs/"+job.ID(), "", "X-User-ID", "alice"); w.Code != http.StatusNotFound {
+		t.Fatalf("冒充其他用户查询任务期望 404，实际 %d %s", w.Code, w.Body.String())
+	}
+	if w := do(r, http.MethodDelete, "/api/jobs/"+job.ID(), "", "X-User-ID", "alice"); w.Code == http.StatusOK {
+		t.Fatalf("冒充其他用户取消任务不应成功: %s", w.Body.String())
+	}
+	var list struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	decode(t, do(r, http.MethodGet, "/api/jobs", "", "X-User-ID", "alice"), &list)
+	if len(list.Data) != 0 {
+		t.Errorf("匿名用户的任务列表 %+v", list.Data)
+	}
+}
+
+// 开启 TrustUserHeader（本地开发）时未登录的请求按 X-User-ID 标识用户
+func TestUserHeaderTrustedWhenEnabled(t *testing.T) {
+	r, h := newServer(t, func(h *handlers.BatchHandler) { h.TrustUserHeader = true })
+	job, _ := h.Jobs.Start(context.Background(), "order", "alice", 1)
+
+	if w := do(r, http.MethodGet, "/api/jobs/"+job.ID(), "", "X-User-ID", "alice"); w.Code != http.StatusOK {
+		t.Fatalf("期望 200，实际 %d %s", w.Code, w.Body.String())
+	}
+	if w := do(r, http.MethodGet, "/api/jobs/"+job.ID(), "", "X-User-ID", "bob"); w.Code != http.StatusNotFound {
+		t.Fatalf("其他用户期望 404，实际 %d", w.Code)
+	}
+}
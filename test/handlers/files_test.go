@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"concurrency-web-app/backend/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+type uploadResponse struct {
+	Data []struct {
+		ID      uint   `json:"id"`
+		Version int    `json:"version"`
+		Path    string `json:"file_path"`
+	} `json:"data"`
+}
+
+// uploadFile 以 user 的身份上传一个文件
+func uploadFile(t *testing.T, r *gin.Engine, user, name, content, onConflict string) uploadResponse {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("files", name)
+	part.Write([]byte(content))
+	form.WriteField("on_conflict", onConflict)
+	form.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/files/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-User-ID", user)
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("上传失败 %d %s", w.Code, w.Body.String())
+	}
+	var resp uploadResponse
+	decode(t, w, &resp)
+	return resp
+}
+
+// 覆盖上传只覆盖当前用户的同名文件，其他用户的文件和元数据不受影响
+func TestUploadOverwriteScopedByOwner(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := newServer(t, func(h *handlers.BatchHandler) {
+		h.TrustUserHeader = true
+		h.FileService.UploadDir = dir
+		h.FileService.MinFreeBytes, h.FileService.WarnFreeBytes = 0, 0
+	})
+
+	alice := uploadFile(t, r, "alice", "notes.txt", "alice", "version").Data[0]
+	bob := uploadFile(t, r, "bob", "notes.txt", "bob", "overwrite").Data[0]
+	if bob.ID == alice.ID || bob.Version != 1 || bob.Path == alice.Path {
+		t.Fatalf("bob 的上传覆盖了 alice 的文件: alice=%+v bob=%+v", alice, bob)
+	}
+	if content, _ := os.ReadFile(alice.Path); string(content) != "alice" {
+		t.Errorf("alice 的文件内容变为 %q", content)
+	}
+
+	again := uploadFile(t, r, "alice", "notes.txt", "alice v2", "version").Data[0]
+	if again.Version != 2 {
+		t.Errorf("alice 的第二个版本为 %d", again.Version)
+	}
+}
+
+// 按文件ID处理、校验和列出文件只能访问自己上传的文件
+func TestFileIDScopedByOwner(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := newServer(t, func(h *handlers.BatchHandler) {
+		h.TrustUserHeader = true
+		h.FileService.UploadDir = dir
+		h.FileService.MinFreeBytes, h.FileService.WarnFreeBytes = 0, 0
+	})
+	alice := uploadFile(t, r, "alice", "secret.txt", "alice", "version").Data[0]
+	id := strconv.FormatUint(uint64(alice.ID), 10)
+
+	body := `{"files":[{"id":1,"file_id":` + id + `,"process_type":"info"}]}`
+	if w := do(r, http.MethodPost, "/api/files/batch-process", body, "X-User-ID", "bob"); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "不存在") {
+		t.Errorf("处理其他用户的文件期望 400，实际 %d %s", w.Code, w.Body.String())
+	}
+	if w := do(r, http.MethodPost, "/api/files/"+id+"/verify", "", "X-User-ID", "bob"); w.Code != http.StatusNotFound {
+		t.Errorf("校验其他用户的文件期望 404，实际 %d", w.Code)
+	}
+	var list struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	decode(t, do(r, http.MethodGet, "/api/files/list", "", "X-User-ID", "bob"), &list)
+	if len(list.Data) != 0 {
+		t.Errorf("bob 的文件列表 %+v", list.Data)
+	}
+	decode(t, do(r, http.MethodGet, "/api/files/list", "", "X-User-ID", "alice"), &list)
+	if len(list.Data) != 1 {
+		t.Errorf("alice 的文件列表 %+v", list.Data)
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/handlers"
+	"concurrency-web-app/backend/services"
+)
+
+// bigTask 返回超出结果大小限制的数据
+type bigTask struct{}
+
+func (bigTask) Execute(ctx context.Context) (interface{}, error) {
+	return map[string]interface{}{"body": strings.Repeat("x", 256)}, nil
+}
+
+type bigKind struct{}
+
+func (bigKind) Name() string { return "big" }
+
+func (bigKind) Decode(json.RawMessage) (services.Task, error) { return bigTask{}, nil }
+
+// 超出大小限制的结果返回完整内容的下载地址而不是服务器上的文件路径，只有任务的发起用户可以下载；
+// 不属于任何任务的调用只截断，不保存完整结果
+func TestFullResultDownload(t *testing.T) {
+	dir := t.TempDir()
+	r, h := newServer(t, func(h *handlers.BatchHandler) {
+		h.TrustUserHeader = true
+		h.FullResults = services.ResultLimit{MaxBytes: 64, Dir: dir}
+	})
+	service := &services.KindService{Kind: bigKind{}, MaxConcurrency: 1, Timeout: 10 * time.Second, ResultLimit: h.FullResults}
+
+	job, ctx := h.Jobs.Start(context.Background(), "big", "alice", 1)
+	result := service.BatchProcess(ctx, []services.Task{bigTask{}})
+	h.Jobs.Finish(job, result)
+	data, _ := result.Results[0].Data.(map[string]interface{})
+	url, _ := data["full_result_url"].(string)
+	if data["truncated"] != true || url != services.FullResultURL(job.ID(), 0) || data["full_result"] != nil {
+		t.Fatalf("截断的结果 %+v", data)
+	}
+
+	w := do(r, http.MethodGet, url, "", "X-User-ID", "alice")
+	var full map[string]string
+	decode(t, w, &full)
+	if w.Code != http.StatusOK || len(full["body"]) != 256 {
+		t.Fatalf("下载完整结果 %d %s", w.Code, w.Body.String())
+	}
+	if w := do(r, http.MethodGet, url, "", "X-User-ID", "bob"); w.Code != http.StatusNotFound {
+		t.Errorf("其他用户下载期望 404，实际 %d", w.Code)
+	}
+	if w := do(r, http.MethodGet, services.FullResultURL(job.ID(), 1), "", "X-User-ID", "alice"); w.Code != http.StatusNotFound {
+		t.Errorf("没有截断的任务期望 404，实际 %d", w.Code)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	jobless := service.BatchProcess(context.Background(), []services.Task{bigTask{}})
+	data, _ = jobless.Results[0].Data.(map[string]interface{})
+	if after, _ := os.ReadDir(dir); data["truncated"] != true || data["full_result_url"] != nil || len(after) != len(entries) {
+		t.Errorf("不属于任务的调用不应保存完整结果: %+v，目录 %d -> %d", data, len(entries), len(after))
+	}
+}
+
+// apiLikeTask 返回与 API 调用结果相同结构的数据：较大的响应头和需要转义的响应体
+type apiLikeTask struct{}
+
+func (apiLikeTask) Execute(ctx context.Context) (interface{}, error) {
+	headers := map[string][]string{}
+	for i := 0; i < 20; i++ {
+		headers["X-Header-"+strings.Repeat("h", i)] = []string{strings.Repeat("v", 20)}
+	}
+	return map[string]interface{}{
+		"status_code":   200,
+		"url":           "http://example.com",
+		"headers":       headers,
+		"response_body": strings.Repeat(`<"x">`, 200),
+	}, nil
+}
+
+// 截断后的结果序列化后不超过 MaxBytes：小字段完整保留，放不下的响应体截断，放不下的响应头整个丢弃
+func TestResultLimitBoundsTotalSize(t *testing.T) {
+	service := &services.KindService{Kind: bigKind{}, MaxConcurrency: 1, Timeout: 10 * time.Second,
+		ResultLimit: services.ResultLimit{MaxBytes: 300}}
+	result := service.BatchProcess(context.Background(), []services.Task{apiLikeTask{}})
+	data, _ := result.Results[0].Data.(map[string]interface{})
+	encoded, _ := json.Marshal(data)
+	if len(encoded) > 300 {
+		t.Fatalf("截断后 %d 字节，超出上限 300: %s", len(encoded), encoded)
+	}
+	body, _ := data["response_body"].(string)
+	if data["truncated"] != true || data["status_code"] != 200 || data["url"] != "http://example.com" ||
+		data["headers"] != nil || body == "" || !strings.HasPrefix(strings.Repeat(`<"x">`, 200), body) {
+		t.Errorf("截断的结果不正确: %s", encoded)
+	}
+}
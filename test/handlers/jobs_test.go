@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/handlers"
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// 只有任务记录的查询参数才把 GET /api/jobs 切换为分页查询任务记录，防缓存等其他参数仍返回当前的任务列表；
+// 接口文档和参数校验接受相同的状态
+func TestListJobsHistoryParams(t *testing.T) {
+	r, _ := newServer(t, nil)
+
+	var list map[string]interface{}
+	decode(t, do(r, http.MethodGet, "/api/jobs?_=123", ""), &list)
+	if _, paged := list["total"]; paged || list["message"] != "任务列表获取成功" {
+		t.Errorf("带其他参数时期望当前的任务列表，实际 %+v", list)
+	}
+
+	for _, status := range []string{"skipped", "interrupted", "failed"} {
+		w := do(r, http.MethodGet, "/api/jobs?status="+status, "")
+		var page map[string]interface{}
+		decode(t, w, &page)
+		if _, paged := page["total"]; w.Code != http.StatusOK || !paged {
+			t.Errorf("status=%s 期望分页的任务记录，实际 %d %s", status, w.Code, w.Body.String())
+		}
+	}
+
+	w := do(r, http.MethodGet, "/api/jobs/history?status=unknown", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("未知状态期望 400，实际 %d %s", w.Code, w.Body.String())
+	}
+}
+
+// 没有配置状态变更存储时，内存中已移除的任务返回空的状态变更记录，不存在的任务返回 404
+func TestJobTransitionsWithoutStore(t *testing.T) {
+	r, h := newServer(t, func(h *handlers.BatchHandler) {
+		h.TrustUserHeader = true
+		h.Jobs.Transitions = nil
+	})
+	job, _ := h.Jobs.Start(context.Background(), "order", "alice", 1)
+	h.Jobs.Finish(job, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+
+	var live struct {
+		Data struct {
+			Transitions []models.JobTransition `json:"transitions"`
+		} `json:"data"`
+	}
+	decode(t, do(r, http.MethodGet, "/api/jobs/"+job.ID()+"/history", "", "X-User-ID", "alice"), &live)
+	if len(live.Data.Transitions) != 2 {
+		t.Errorf("内存中任务的状态变更 %+v", live.Data.Transitions)
+	}
+
+	if h.Jobs.EvictExpired(time.Now().Add(2*services.DefaultFinishedTTL)) != 1 {
+		t.Fatal("已结束的任务没有从内存中移除")
+	}
+	w := do(r, http.MethodGet, "/api/jobs/"+job.ID()+"/history", "", "X-User-ID", "alice")
+	var saved struct {
+		Data struct {
+			Transitions []models.JobTransition `json:"transitions"`
+		} `json:"data"`
+	}
+	decode(t, w, &saved)
+	if w.Code != http.StatusOK || saved.Data.Transitions == nil || len(saved.Data.Transitions) != 0 {
+		t.Errorf("期望 200 和空的状态变更记录，实际 %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do(r, http.MethodGet, "/api/jobs/missing/history", "", "X-User-ID", "alice"); w.Code != http.StatusNotFound {
+		t.Errorf("不存在的任务期望 404，实际 %d", w.Code)
+	}
+}
+
+// jobPoll GET /api/jobs/:id 长轮询的响应
+type jobPoll struct {
+	Finished bool `json:"finished"`
+}
+
+// wait 参数格式错误时返回 400，超过 MaxJobWait 时按 MaxJobWait 等待，任务结束时立即返回
+func TestGetJobWait(t *testing.T) {
+	r, h := newServer(t, func(h *handlers.BatchHandler) {
+		h.TrustUserHeader = true
+		h.MaxJobWait = 100 * time.Millisecond
+	})
+	job, _ := h.Jobs.Start(context.Background(), "order", "alice", 1)
+	target := "/api/jobs/" + job.ID()
+
+	for _, wait := range []string{"abc", "-1s", "30"} {
+		if w := do(r, http.MethodGet, target+"?wait="+wait, "", "X-User-ID", "alice"); w.Code != http.StatusBadRequest {
+			t.Errorf("wait=%s 期望 400，实际 %d", wait, w.Code)
+		}
+	}
+
+	start := time.Now()
+	var poll jobPoll
+	decode(t, do(r, http.MethodGet, target+"?wait=1h", "", "X-User-ID", "alice"), &poll)
+	if waited := time.Since(start); poll.Finished || waited < 100*time.Millisecond || waited > time.Second {
+		t.Errorf("期望等待 MaxJobWait 后返回未结束，实际 finished=%v 等待 %s", poll.Finished, waited)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		h.Jobs.Finish(job, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+	}()
+	h.MaxJobWait = 10 * time.Second
+	start = time.Now()
+	decode(t, do(r, http.MethodGet, target+"?wait=10s", "", "X-User-ID", "alice"), &poll)
+	if waited := time.Since(start); !poll.Finished || waited > 2*time.Second {
+		t.Errorf("任务结束时应立即返回，实际 finished=%v 等待 %s", poll.Finished, waited)
+	}
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// 批量接口的请求体超过上限时返回 413，重复提交检测和接口定义校验不会缓冲整个请求体
+func TestBatchBodyTooLarge(t *testing.T) {
+	r, _ := newServer(t, nil)
+	body := `{"orders":[{"id":1,"customer_id":"` + strings.Repeat("x", 33<<20) + `"}]}`
+	if w := do(r, http.MethodPost, "/api/orders/batch-process", body); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("期望 413，实际 %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// 批量处理的每个订单和每个API调用写入 orders、api_calls 表，记录所属的任务
+func TestBatchRunsPersistTaskRecords(t *testing.T) {
+	r, h := newServer(t, nil)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	var batch struct {
+		JobID string `json:"job_id"`
+	}
+	orders := `{"orders":[
+		{"id":1,"customer_id":"CUST_00001","product_name":"笔记本","quantity":2,"price":10},
+		{"id":7,"customer_id":"CUST_00002","product_name":"鼠标","quantity":1,"price":5}]}`
+	decode(t, do(r, http.MethodPost, "/api/orders/batch-process", orders), &batch)
+
+	var saved []models.Order
+	if err := h.OrderStats.DB.Where("job_id = ?", batch.JobID).Order("customer_id").Find(&saved).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 2 || saved[0].Status != "processed" || saved[0].ProcessedAt == nil || saved[0].Quantity != 2 {
+		t.Fatalf("订单记录 %+v", saved)
+	}
+	if saved[1].Status != services.TaskStatusFailed || saved[1].ProcessedAt != nil {
+		t.Errorf("库存不足的订单记录 %+v", saved[1])
+	}
+
+	calls := `{"apis":[{"id":1,"url":"` + upstream.URL + `","method":"POST","headers":{"Authorization":"secret"}}]}`
+	decode(t, do(r, http.MethodPost, "/api/api-calls/batch-call", calls), &batch)
+
+	var called []models.APICall
+	if err := h.APICalls.DB.Where("job_id = ?", batch.JobID).Find(&called).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(called) != 1 || called[0].Status != services.TaskStatusSuccess || called[0].ResponseCode != http.StatusCreated || called[0].ResponseBody != "ok" {
+		t.Errorf("API调用记录 %+v", called)
+	}
+}
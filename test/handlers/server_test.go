@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"concurrency-web-app/backend/handlers"
+	"concurrency-web-app/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newServer 以临时目录中的 SQLite 数据库创建处理器并注册路由，configure 在注册路由前调整处理器的配置
+func newServer(t *testing.T, configure func(h *handlers.BatchHandler)) (*gin.Engine, *handlers.BatchHandler) {
+	t.Helper()
+	db, readDB, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "app.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	h := handlers.NewBatchHandler(db, readDB, models.NewLockManager(db, "sqlite"), nil, nil)
+	// 结束的批次保存产出物，不写入测试目录
+	h.Artifacts.Dir = filepath.Join(t.TempDir(), "artifacts")
+	if configure != nil {
+		configure(h)
+	}
+	r := gin.New()
+	h.SetupRoutes(r)
+	return r, h
+}
+
+// do 发送请求，headers 为依次排列的请求头名称和值
+func do(r *gin.Engine, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// decode 解析响应体
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("解析响应 %d %s: %v", w.Code, w.Body.String(), err)
+	}
+}
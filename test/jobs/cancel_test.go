@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// blockingTask 开始执行后等到 release 关闭或 ctx 结束，aborted 统计因 ctx 结束而中止的任务数
+type blockingTask struct {
+	started *atomic.Int32
+	aborted *atomic.Int32
+	release <-chan struct{}
+}
+
+func (t blockingTask) Execute(ctx context.Context) (interface{}, error) {
+	t.started.Add(1)
+	select {
+	case <-t.release:
+		return "ok", nil
+	case <-ctx.Done():
+		t.aborted.Add(1)
+		return nil, ctx.Err()
+	}
+}
+
+type blockingKind struct{}
+
+func (blockingKind) Name() string { return "blocking" }
+
+func (blockingKind) Decode(json.RawMessage) (services.Task, error) { return nil, nil }
+
+// cancelRun 以 2 个并发在后台执行 5 个阻塞的任务，等到 2 个任务开始执行后返回
+func cancelRun(t *testing.T, jobs *services.JobManager) (job *services.Job, release chan struct{}, aborted *atomic.Int32, done <-chan *services.BatchResult) {
+	t.Helper()
+	var started atomic.Int32
+	aborted = &atomic.Int32{}
+	release = make(chan struct{})
+	tasks := make([]services.Task, 5)
+	for i := range tasks {
+		tasks[i] = blockingTask{started: &started, aborted: aborted, release: release}
+	}
+	service := &services.KindService{Kind: blockingKind{}, MaxConcurrency: 2, Timeout: 10 * time.Second}
+
+	job, ctx := jobs.Start(context.Background(), "blocking", "alice", len(tasks))
+	results := make(chan *services.BatchResult, 1)
+	go func() {
+		result := service.BatchProcess(ctx, tasks)
+		jobs.Finish(job, result)
+		results <- result
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for started.Load() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("任务没有开始执行，已开始 %d 个", started.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return job, release, aborted, results
+}
+
+// statusCounts 按状态统计任务结果
+func statusCounts(result *services.BatchResult) map[string]int {
+	counts := map[string]int{}
+	for _, r := range result.Results {
+		counts[r.Status]++
+	}
+	return counts
+}
+
+// waitResult 等待批次结束
+func waitResult(t *testing.T, done <-chan *services.BatchResult) *services.BatchResult {
+	t.Helper()
+	select {
+	case result := <-done:
+		return result
+	case <-time.After(2 * time.Second):
+		t.Fatal("批次没有结束")
+		return nil
+	}
+}
+
+// waitAborted 等待 n 个执行中的任务因 ctx 结束而中止；硬取消后批次不等待执行中的任务返回
+func waitAborted(t *testing.T, aborted *atomic.Int32, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for aborted.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("期望 %d 个执行中的任务被中止，实际 %d", n, aborted.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 软取消后执行中的任务照常完成，尚未派发的任务标记为 cancelled
+func TestSoftCancelFinishesInflight(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	job, release, aborted, done := cancelRun(t, jobs)
+
+	info, err := jobs.Cancel(job.ID(), services.CancelModeSoft, "alice")
+	if err != nil || info.CancelMode != services.CancelModeSoft {
+		t.Fatalf("软取消失败: %+v %v", info, err)
+	}
+	close(release)
+
+	result := waitResult(t, done)
+	counts := statusCounts(result)
+	if counts[services.TaskStatusSuccess] != 2 || counts[services.TaskStatusCancelled] != 3 || aborted.Load() != 0 {
+		t.Fatalf("期望 2 个完成、3 个取消且没有中止，实际 %v，中止 %d", counts, aborted.Load())
+	}
+	for _, r := range result.Results {
+		if r.Status == services.TaskStatusCancelled && r.ErrorCode != services.ErrCodeCancelled {
+			t.Errorf("未派发的任务错误码为 %q", r.ErrorCode)
+		}
+	}
+	if status := job.Info().Status; status != services.JobStatusCancelled {
+		t.Errorf("任务状态为 %s，期望 cancelled", status)
+	}
+}
+
+// 硬取消立即中止执行中的任务，不等待其完成
+func TestHardCancelAbortsInflight(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	job, release, aborted, done := cancelRun(t, jobs)
+	defer close(release)
+
+	if _, err := jobs.Cancel(job.ID(), services.CancelModeHard, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	result := waitResult(t, done)
+	if counts := statusCounts(result); counts[services.TaskStatusSuccess] != 0 || counts[services.TaskStatusCancelled] != 5 {
+		t.Fatalf("期望 5 个任务全部取消，实际 %v", counts)
+	}
+	waitAborted(t, aborted, 2)
+}
+
+// 软取消可以升级为硬取消，中止仍在执行的任务；硬取消后不能降级为软取消
+func TestSoftCancelUpgradesToHard(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	job, release, aborted, done := cancelRun(t, jobs)
+	defer close(release)
+
+	jobs.Cancel(job.ID(), services.CancelModeSoft, "alice")
+	info, err := jobs.Cancel(job.ID(), services.CancelModeHard, "alice")
+	if err != nil || info.CancelMode != services.CancelModeHard {
+		t.Fatalf("升级为硬取消失败: %+v %v", info, err)
+	}
+	if info, _ := jobs.Cancel(job.ID(), services.CancelModeSoft, "alice"); info.CancelMode != services.CancelModeHard {
+		t.Errorf("硬取消后不应降级，实际 %s", info.CancelMode)
+	}
+
+	result := waitResult(t, done)
+	if counts := statusCounts(result); counts[services.TaskStatusSuccess] != 0 || counts[services.TaskStatusCancelled] != 5 {
+		t.Fatalf("升级后未完成的任务应全部取消，实际 %v", counts)
+	}
+	waitAborted(t, aborted, 2)
+	if _, err := jobs.Cancel(job.ID(), services.CancelModeHard, "alice"); err != services.ErrJobFinished {
+		t.Errorf("结束的任务期望 ErrJobFinished，实际 %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// doubleTask 返回 n 的两倍，hang 为 true 时在 ctx 结束前不返回，模拟服务退出时仍在执行的任务
+type doubleTask struct {
+	N    int `json:"n"`
+	hang *atomic.Bool
+}
+
+func (t doubleTask) Execute(ctx context.Context) (interface{}, error) {
+	if t.N%2 == 1 && t.hang.Load() {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return float64(t.N * 2), nil
+}
+
+type doubleKind struct{ hang *atomic.Bool }
+
+func (doubleKind) Name() string { return "double" }
+
+func (k doubleKind) Decode(raw json.RawMessage) (services.Task, error) {
+	task := doubleTask{hang: k.hang}
+	if err := json.Unmarshal(raw, &task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// 服务退出前已结束的任务随进度保存到检查点，重启后只执行剩余的任务，合并为整个批次的结果后删除检查点
+func TestResumeFromCheckpoint(t *testing.T) {
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "jobs.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := &services.DBProgressStore{DB: db}
+	checkpoints := &services.CheckpointStore{DB: db}
+	var hang atomic.Bool
+	hang.Store(true)
+	service := &services.KindService{Kind: doubleKind{hang: &hang}, MaxConcurrency: 4, Timeout: 10 * time.Second}
+
+	raws := []json.RawMessage{[]byte(`{"n":0}`), []byte(`{"n":1}`), []byte(`{"n":2}`), []byte(`{"n":3}`)}
+	tasks, err := service.Decode(raws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次执行：序号为偶数的任务结束后服务“退出”，不调用 Finish
+	before := services.NewJobManager(progress)
+	before.Checkpoints = checkpoints
+	before.FlushEvery = 1
+	job, ctx := before.Start(context.Background(), "double", "alice", len(tasks))
+	if err := before.SaveCheckpoint(job, raws, map[string]string{"note": "resume"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, stop := context.WithCancel(ctx)
+	var running sync.WaitGroup
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		service.BatchProcess(ctx, tasks)
+	}()
+	t.Cleanup(func() {
+		stop()
+		running.Wait()
+	})
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Info().CompletedTasks < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("等待任务结束超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 重启：本实例未结束的任务中有该任务，检查点中有两个已结束的任务
+	records, err := checkpoints.Unfinished()
+	if err != nil || len(records) != 1 || records[0].JobID != job.ID() {
+		t.Fatalf("未结束的任务 %+v，err=%v", records, err)
+	}
+	cp, err := checkpoints.Load(records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(cp.Request) != `{"note":"resume"}` || len(cp.Tasks) != 4 || len(cp.Done) != 2 {
+		t.Fatalf("检查点 request=%s tasks=%d done=%d", cp.Request, len(cp.Tasks), len(cp.Done))
+	}
+	remaining := cp.Remaining()
+	if len(remaining) != 2 || remaining[0] != 1 || remaining[1] != 3 {
+		t.Fatalf("剩余的任务 %v，期望 [1 3]", remaining)
+	}
+
+	hang.Store(false)
+	after := services.NewJobManager(progress)
+	after.Checkpoints = checkpoints
+	resumed, resumedCtx, err := after.Resume(context.Background(), cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := resumed.Info(); info.ID != job.ID() || info.CompletedTasks != 2 || info.Resumes != 1 || !info.StartTime.Equal(job.Info().StartTime) {
+		t.Fatalf("恢复的任务 %+v", info)
+	}
+	rest, err := service.Decode([]json.RawMessage{raws[1], raws[3]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := cp.Merge(resumedCtx, service.BatchProcess(resumedCtx, rest))
+	if result.TotalTasks != 4 || result.SuccessTasks != 4 || len(result.Results) != 4 {
+		t.Fatalf("合并的结果 %+v", result)
+	}
+	for i, r := range result.Results {
+		if r.ID != i || r.Data != float64(i*2) {
+			t.Errorf("任务 %d 的结果 %+v", i, r)
+		}
+	}
+	after.Finish(resumed, result)
+
+	record, err := progress.Get(job.ID(), "alice")
+	if err != nil || record.Status != services.JobStatusCompleted || record.Resumes != 1 || record.SuccessTasks != 4 {
+		t.Fatalf("任务记录 %+v，err=%v", record, err)
+	}
+	if _, err := checkpoints.Load(*record); !errors.Is(err, services.ErrCheckpointNotFound) {
+		t.Errorf("任务结束后应删除检查点，实际 %v", err)
+	}
+}
+
+// 没有保存检查点的任务重启后不能恢复，标记为 interrupted 后仍可查询到任务记录
+func TestMarkInterrupted(t *testing.T) {
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "jobs.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := &services.DBProgressStore{DB: db}
+	checkpoints := &services.CheckpointStore{DB: db}
+	job, _ := services.NewJobManager(progress).Start(context.Background(), "order", "alice", 3)
+
+	records, err := checkpoints.Unfinished()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("未结束的任务 %+v，err=%v", records, err)
+	}
+	if _, err := checkpoints.Load(records[0]); !errors.Is(err, services.ErrCheckpointNotFound) {
+		t.Fatalf("期望 ErrCheckpointNotFound，实际 %v", err)
+	}
+	if err := checkpoints.MarkInterrupted(job.ID(), "没有保存检查点"); err != nil {
+		t.Fatal(err)
+	}
+
+	record, err := progress.Get(job.ID(), "alice")
+	if err != nil || record.Status != services.JobStatusInterrupted || record.EndTime == nil {
+		t.Fatalf("任务记录 %+v，err=%v", record, err)
+	}
+	if records, _ := checkpoints.Unfinished(); len(records) != 0 {
+		t.Errorf("标记中断后不应再有未结束的任务: %+v", records)
+	}
+	if _, err := progress.Get(job.ID(), "bob"); !errors.Is(err, services.ErrJobNotFound) {
+		t.Errorf("其他用户查询应返回 ErrJobNotFound，实际 %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 共享配置的调整记入指定类型的未结束任务，任务信息和事件中均可看到调整前后的值
+func TestRecordConfigChange(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	api, _ := jobs.Start(context.Background(), "api", "", 1)
+	pipeline, _ := jobs.Start(context.Background(), "pipeline", "", 1)
+	order, _ := jobs.Start(context.Background(), "order", "", 1)
+	finished, _ := jobs.Start(context.Background(), "api", "", 1)
+	jobs.Finish(finished, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+
+	change := services.ConfigChange{
+		Setting: services.ConfigRateLimit,
+		Scope:   "api",
+		Before:  services.RateSetting{Rate: 50, Burst: 10},
+		After:   services.RateSetting{Rate: 20, Burst: 5},
+		By:      "admin",
+		Time:    time.Now(),
+	}
+	affected := jobs.RecordConfigChange([]string{"api", "pipeline"}, change)
+	if len(affected) != 2 {
+		t.Fatalf("期望影响 2 个任务，实际 %v", affected)
+	}
+	for _, id := range affected {
+		if id != api.ID() && id != pipeline.ID() {
+			t.Errorf("任务 %s 不应受影响", id)
+		}
+	}
+	if changes := order.Info().ConfigChanges; len(changes) != 0 {
+		t.Errorf("其他类型的任务不应记录调整: %+v", changes)
+	}
+	if changes := finished.Info().ConfigChanges; len(changes) != 0 {
+		t.Errorf("已结束的任务不应记录调整: %+v", changes)
+	}
+
+	changes := api.Info().ConfigChanges
+	if len(changes) != 1 || changes[0].After != change.After || changes[0].By != "admin" || changes[0].Elapsed < 0 {
+		t.Fatalf("任务记录的调整 %+v", changes)
+	}
+
+	// 结束后读取全部事件，其中应有一条配置调整
+	jobs.Finish(api, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+	found := false
+	api.Follow(context.Background(), 0, func(event services.JobEvent) error {
+		if event.Type == services.JobEventConfigChange {
+			found = event.Data.(services.ConfigChange).Before == change.Before
+		}
+		return nil
+	})
+	if !found {
+		t.Error("任务事件中没有配置调整")
+	}
+}
+
+// 不支持调整并发数的任务不记录调整
+func TestSetConcurrencyNotAdjustable(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	job, _ := jobs.Start(context.Background(), "order", "", 1)
+	if _, err := jobs.SetConcurrency(job.ID(), 3, "admin"); err != services.ErrConcurrencyNotAdjustable {
+		t.Fatalf("期望 ErrConcurrencyNotAdjustable，实际 %v", err)
+	}
+	if changes := job.Info().ConfigChanges; len(changes) != 0 {
+		t.Errorf("未调整时不应记录: %+v", changes)
+	}
+}
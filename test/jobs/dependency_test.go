@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// waitDependency 在后台等待上游任务，返回接收结果的通道；等到下游进入排队状态后返回
+func waitDependency(t *testing.T, jobs *services.JobManager, job *services.Job, ctx context.Context) <-chan error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- jobs.WaitDependency(ctx, job) }()
+	deadline := time.Now().Add(time.Second)
+	for job.Info().Status != services.JobStatusQueued {
+		if time.Now().After(deadline) {
+			t.Fatal("下游任务没有等待上游")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return done
+}
+
+// 上游全部成功后下游开始执行
+func TestDependencyStartsAfterUpstreamSucceeds(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	upstream, _ := jobs.Start(context.Background(), "order", "", 2)
+	downstream, ctx := jobs.Start(context.Background(), "order", "", 1)
+	downstream.SetDependsOn(upstream.ID())
+	done := waitDependency(t, jobs, downstream, ctx)
+
+	select {
+	case err := <-done:
+		t.Fatalf("上游未结束时下游不应开始: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	jobs.Finish(upstream, &services.BatchResult{TotalTasks: 2, SuccessTasks: 2})
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if info := downstream.Info(); info.Status != services.JobStatusRunning || info.DependsOn != upstream.ID() {
+		t.Errorf("下游任务 %+v", info)
+	}
+}
+
+// 上游有失败的任务时下游不执行任何任务，全部记为跳过，任务状态为 skipped；依赖沿链条传递
+func TestDependencySkipsWhenUpstreamFails(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	upstream, _ := jobs.Start(context.Background(), "order", "", 2)
+	downstream, ctx := jobs.Start(context.Background(), "beating", "", 2)
+	downstream.SetDependsOn(upstream.ID())
+	done := waitDependency(t, jobs, downstream, ctx)
+
+	jobs.Finish(upstream, &services.BatchResult{TotalTasks: 2, SuccessTasks: 1, FailedTasks: 1})
+	if err := <-done; !errors.Is(err, services.ErrDependencyFailed) {
+		t.Fatalf("期望 ErrDependencyFailed，实际 %v", err)
+	}
+
+	service := &services.KindService{Kind: beatingKind{}, MaxConcurrency: 2, Timeout: time.Second}
+	result := service.BatchProcess(ctx, []services.Task{beatingTask{steps: 1}, beatingTask{steps: 1}})
+	if result.SkippedTasks != 2 || result.SuccessTasks != 0 {
+		t.Fatalf("计数不正确: %+v", result)
+	}
+	for _, r := range result.Results {
+		if r.Status != services.TaskStatusSkipped || r.ErrorCode != services.ErrCodeDependency {
+			t.Errorf("任务结果 %+v", r)
+		}
+	}
+	jobs.Finish(downstream, result)
+	if status := downstream.Info().Status; status != services.JobStatusSkipped {
+		t.Errorf("下游任务状态为 %s", status)
+	}
+
+	third, ctx3 := jobs.Start(context.Background(), "order", "", 1)
+	third.SetDependsOn(downstream.ID())
+	if err := jobs.WaitDependency(ctx3, third); !errors.Is(err, services.ErrDependencyFailed) {
+		t.Errorf("上游被跳过时期望 ErrDependencyFailed，实际 %v", err)
+	}
+}
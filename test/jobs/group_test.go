@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 同组的任务依次执行：前一个任务结束后，排队的任务按进入顺序开始
+func TestJobGroupRunsOneAtATime(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	first, ctx := jobs.Start(context.Background(), "order", "", 1)
+	if err := jobs.EnterGroup(ctx, first, "nightly"); err != nil {
+		t.Fatal(err)
+	}
+
+	second, ctx2 := jobs.Start(context.Background(), "order", "", 1)
+	entered := make(chan error, 1)
+	go func() { entered <- jobs.EnterGroup(ctx2, second, "nightly") }()
+
+	// 等待第二个任务进入队列
+	deadline := time.Now().Add(time.Second)
+	for second.Info().Status != services.JobStatusQueued {
+		if time.Now().After(deadline) {
+			t.Fatal("第二个任务没有排队")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	groups := jobs.Groups()
+	if len(groups) != 1 || groups[0].Running != first.ID() || len(groups[0].Queued) != 1 {
+		t.Fatalf("互斥组状态不正确: %+v", groups)
+	}
+
+	// 其他组不受影响
+	other, ctx3 := jobs.Start(context.Background(), "order", "", 1)
+	if err := jobs.EnterGroup(ctx3, other, "hourly"); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs.Finish(first, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+	select {
+	case err := <-entered:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("前一个任务结束后排队的任务没有开始")
+	}
+	if info := second.Info(); info.Status != services.JobStatusRunning || info.Group != "nightly" {
+		t.Errorf("任务状态为 %s，所属组 %q", info.Status, info.Group)
+	}
+}
+
+// 排队中的任务被取消时离开队列，不影响后面排队的任务
+func TestJobGroupCancelWhileQueued(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	first, ctx := jobs.Start(context.Background(), "order", "", 1)
+	jobs.EnterGroup(ctx, first, "g")
+
+	queued, qctx := jobs.Start(context.Background(), "order", "", 1)
+	entered := make(chan error, 1)
+	go func() { entered <- jobs.EnterGroup(qctx, queued, "g") }()
+	for queued.Info().Status != services.JobStatusQueued {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := jobs.Cancel(queued.ID(), services.CancelModeHard, ""); err != nil {
+		t.Fatalf("排队中的任务应可以取消: %v", err)
+	}
+	if err := <-entered; !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望 context.Canceled，实际 %v", err)
+	}
+	if groups := jobs.Groups(); len(groups[0].Queued) != 0 {
+		t.Errorf("取消的任务仍在队列中: %+v", groups)
+	}
+
+	jobs.Finish(first, &services.BatchResult{})
+	if groups := jobs.Groups(); len(groups) != 0 {
+		t.Errorf("组内没有任务时应移除: %+v", groups)
+	}
+}
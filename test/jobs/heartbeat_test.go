@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// beatingTask 每 10ms 发送一次心跳，共执行 steps 次；hang 为 true 时阻塞到 ctx 结束，模拟卡在已断开的连接上
+type beatingTask struct {
+	steps int
+	hang  bool
+}
+
+func (t beatingTask) Execute(ctx context.Context) (interface{}, error) {
+	if t.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	for i := 0; i < t.steps; i++ {
+		services.Heartbeat(ctx)
+		time.Sleep(10 * time.Millisecond)
+	}
+	return "ok", nil
+}
+
+type beatingKind struct{}
+
+func (beatingKind) Name() string { return "beating" }
+
+func (beatingKind) Decode(raw json.RawMessage) (services.Task, error) { return nil, nil }
+
+// 没有心跳的子任务被回收器取消并记为 stalled 失败；持续发送心跳的任务即使总耗时超过阈值也不受影响
+func TestReapStalledTasks(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	jobs.StallTimeout = 100 * time.Millisecond
+	job, ctx := jobs.Start(context.Background(), "beating", "", 2)
+
+	reaperCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go jobs.RunReaper(reaperCtx, 20*time.Millisecond)
+
+	service := &services.KindService{Kind: beatingKind{}, MaxConcurrency: 2, Timeout: 10 * time.Second}
+	start := time.Now()
+	result := service.BatchProcess(ctx, []services.Task{beatingTask{hang: true}, beatingTask{steps: 25}})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("卡住的任务没有被及时回收，批次耗时 %v", elapsed)
+	}
+
+	if result.SuccessTasks != 1 || result.FailedTasks != 1 || result.StalledTasks != 1 {
+		t.Fatalf("计数不正确: %+v", result)
+	}
+	for _, r := range result.Results {
+		switch r.ID {
+		case 0:
+			if r.Status != services.TaskStatusFailed || r.ErrorClass != services.ErrorClassStalled || r.ErrorCode != services.ErrCodeStalled {
+				t.Errorf("卡住的任务: %+v", r)
+			}
+		case 1:
+			if !r.Success {
+				t.Errorf("持续发送心跳的任务不应被回收: %+v", r)
+			}
+		}
+	}
+	if inflight := job.Inflight(); len(inflight) != 0 {
+		t.Errorf("回收后不应有执行中的任务: %+v", inflight)
+	}
+}
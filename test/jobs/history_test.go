@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// 任务记录按类型、状态和开始时间筛选，按耗时或失败数排序并分页
+func TestJobHistoryQuery(t *testing.T) {
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "history.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := &services.DBProgressStore{DB: db}
+	jobs := services.NewJobManager(progress)
+
+	runs := []struct {
+		jobType          string
+		failed, duration int
+	}{
+		{"order", 0, 300},
+		{"order", 2, 100},
+		{"api", 5, 200},
+		{"order", 1, 500},
+	}
+	ids := make([]string, len(runs))
+	for i, run := range runs {
+		job, _ := jobs.Start(context.Background(), run.jobType, "alice", 5)
+		jobs.Finish(job, &services.BatchResult{TotalTasks: 5, SuccessTasks: 5 - run.failed, FailedTasks: run.failed, Duration: int64(run.duration)})
+		ids[i] = job.ID()
+	}
+	running, _ := jobs.Start(context.Background(), "order", "alice", 5)
+	jobs.Start(context.Background(), "order", "bob", 5)
+
+	query := func(q services.HistoryQuery) ([]string, int64) {
+		t.Helper()
+		q.Owner = "alice"
+		if q.Page == 0 {
+			q.Page, q.PageSize = 1, 20
+		}
+		records, total, err := progress.History(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range records {
+			got = append(got, r.JobID)
+		}
+		return got, total
+	}
+	expect := func(name string, got []string, want ...string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s: %v，期望 %v", name, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%s: %v，期望 %v", name, got, want)
+			}
+		}
+	}
+
+	got, total := query(services.HistoryQuery{Type: "order", Status: services.HistoryStatusFailed, Sort: services.HistorySortDuration})
+	expect("有失败任务的订单批次按耗时倒序", got, ids[3], ids[1])
+	if total != 2 {
+		t.Errorf("总数 %d，期望 2", total)
+	}
+
+	got, _ = query(services.HistoryQuery{Sort: services.HistorySortFailed, Asc: true, Status: services.JobStatusCompleted})
+	expect("已完成的批次按失败数升序", got, ids[0], ids[3], ids[1], ids[2])
+
+	got, _ = query(services.HistoryQuery{Status: services.JobStatusRunning})
+	expect("运行中的批次", got, running.ID())
+
+	got, total = query(services.HistoryQuery{Type: "order", Sort: services.HistorySortDuration, Page: 2, PageSize: 2})
+	if total != 4 || len(got) != 2 {
+		t.Fatalf("第2页 %v，总数 %d", got, total)
+	}
+
+	future := time.Now().Add(time.Hour)
+	if got, total := query(services.HistoryQuery{From: &future}); len(got) != 0 || total != 0 {
+		t.Errorf("开始时间晚于一小时后的批次应为空，实际 %v", got)
+	}
+}
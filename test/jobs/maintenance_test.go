@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 开启维护模式时使用默认的提示和重试时间，再次开启只更新提示，关闭后恢复零值
+func TestMaintenanceMode(t *testing.T) {
+	var m services.MaintenanceMode
+	if m.State().Enabled {
+		t.Fatal("零值应为关闭状态")
+	}
+
+	state := m.Enable("", 0, "admin")
+	if !state.Enabled || state.Message != services.DefaultMaintenanceMessage || state.RetryAfter != services.DefaultMaintenanceRetryAfter || state.Since == nil || state.By != "admin" {
+		t.Fatalf("开启后的状态 %+v", state)
+	}
+	since := *state.Since
+	state = m.Enable("升级数据库", 60, "other")
+	if state.Message != "升级数据库" || state.RetryAfter != 60 || !state.Since.Equal(since) || state.By != "admin" {
+		t.Errorf("再次开启应只更新提示和重试时间: %+v", state)
+	}
+
+	if state := m.Disable(); state.Enabled || m.State().Message != "" {
+		t.Errorf("关闭后的状态 %+v", m.State())
+	}
+}
+
+// 尚未结束的任务数随任务结束减少，降为0时排空
+func TestActiveJobs(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	a, _ := jobs.Start(context.Background(), "order", "", 1)
+	b, _ := jobs.Start(context.Background(), "order", "", 1)
+	if n := jobs.ActiveJobs(); n != 2 {
+		t.Fatalf("期望 2 个未结束的任务，实际 %d 个", n)
+	}
+	jobs.Finish(a, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+	jobs.Finish(b, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+	if n := jobs.ActiveJobs(); n != 0 {
+		t.Errorf("任务全部结束后应为0，实际 %d 个", n)
+	}
+}
@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 任务结束时计算耗时、吞吐量和失败率，记录在任务信息中并推送到 Pushgateway
+func TestJobMetricsPushed(t *testing.T) {
+	var method, path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(raw)
+	}))
+	defer gateway.Close()
+
+	jobs := services.NewJobManager(nil)
+	jobs.Pushgateway = &services.Pushgateway{URL: gateway.URL, Instance: "worker-1"}
+	job, _ := jobs.Start(context.Background(), "order", "", 4)
+	jobs.Finish(job, &services.BatchResult{TotalTasks: 4, SuccessTasks: 3, FailedTasks: 1, Duration: 2000})
+
+	metrics := job.Info().Metrics
+	if metrics == nil || metrics.DurationMs != 2000 || metrics.Throughput != 2 || metrics.FailureRate != 0.25 {
+		t.Fatalf("指标不正确: %+v", metrics)
+	}
+	if method != http.MethodPut || path != "/metrics/job/concurrency_web_app/job_type/order/instance/worker-1" {
+		t.Errorf("推送的分组不正确: %s %s", method, path)
+	}
+	for _, line := range []string{
+		`batch_job_duration_seconds{status="completed"} 2`,
+		`batch_job_throughput_tasks_per_second{status="completed"} 2`,
+		`batch_job_failure_rate{status="completed"} 0.25`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("推送内容缺少 %s:\n%s", line, body)
+		}
+	}
+}
+
+// burnTask 计算若干轮摘要消耗 CPU 并分配内存
+type burnTask struct{}
+
+func (burnTask) Execute(ctx context.Context) (interface{}, error) {
+	blocks := make([][]byte, 0, 20000)
+	sum := sha256.Sum256(nil)
+	for i := 0; i < 20000; i++ {
+		sum = sha256.Sum256(sum[:])
+		blocks = append(blocks, append([]byte(nil), sum[:]...))
+	}
+	return len(blocks), nil
+}
+
+type burnKind struct{}
+
+func (burnKind) Name() string { return "burn" }
+
+func (burnKind) Decode(raw json.RawMessage) (services.Task, error) { return burnTask{}, nil }
+
+// 任务结束时记录批次的 CPU 时间和内存分配，启用 worker_usage 时按工作槽位记录线程 CPU 时间
+func TestJobResourceUsage(t *testing.T) {
+	service := &services.KindService{Kind: burnKind{}, MaxConcurrency: 2, Timeout: 10 * time.Second}
+	jobs := services.NewJobManager(nil)
+	job, ctx := jobs.Start(context.Background(), "burn", "", 4)
+	job.EnableWorkerUsage()
+	result := service.BatchProcess(ctx, []services.Task{burnTask{}, burnTask{}, burnTask{}, burnTask{}})
+	jobs.Finish(job, result)
+
+	usage := job.Info().Metrics.Resources
+	if usage == nil || usage.CPUTotalMs <= 0 || usage.AllocBytes == 0 || usage.AllocObjects < 4*20000 || usage.Overlapped {
+		t.Fatalf("资源消耗不正确: %+v", usage)
+	}
+	if runtime.GOOS == "linux" {
+		tasks := 0
+		for _, w := range usage.Workers {
+			tasks += w.Tasks
+		}
+		if len(usage.Workers) == 0 || len(usage.Workers) > 2 || tasks != 4 {
+			t.Fatalf("工作槽位的记录不正确: %+v", usage.Workers)
+		}
+	}
+
+	// 没有任务执行的批次不记录资源消耗
+	empty, _ := jobs.Start(context.Background(), "burn", "", 0)
+	jobs.Finish(empty, &services.BatchResult{})
+	if empty.Info().Metrics.Resources != nil {
+		t.Errorf("没有任务执行时不应记录资源消耗")
+	}
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 订阅者依次收到 queued、started、每个子任务的 task_done 和 finished
+func TestJobMonitorLifecycle(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	sub := jobs.Monitor.Subscribe()
+
+	job, ctx := jobs.Start(context.Background(), "order", "alice", 3)
+	orders := []services.OrderTask{
+		{ID: 1, CustomerID: "CUST_0001", ProductName: "p", Quantity: 1, Price: 1},
+		{ID: 2, CustomerID: "CUST_0002", ProductName: "p", Quantity: 1, Price: 1},
+		{ID: 3, CustomerID: "CUST_0003", ProductName: "p", Quantity: 1, Price: 1},
+	}
+	service := &services.OrderProcessService{MaxConcurrency: 3}
+	jobs.Finish(job, service.BatchProcessOrders(ctx, orders))
+
+	var types []string
+	timeout := time.After(time.Second)
+	for len(types) < 6 {
+		select {
+		case event := <-sub.Events():
+			if event.JobID != job.ID() || event.Owner != "alice" {
+				t.Fatalf("事件不属于该任务: %+v", event)
+			}
+			if event.Type == services.LifecycleTaskDone && event.Result == nil {
+				t.Fatalf("task_done 应携带任务结果: %+v", event)
+			}
+			types = append(types, event.Type)
+		case <-timeout:
+			t.Fatalf("没有收到全部事件: %v", types)
+		}
+	}
+	want := []string{services.LifecycleQueued, services.LifecycleStarted,
+		services.LifecycleTaskDone, services.LifecycleTaskDone, services.LifecycleTaskDone, services.LifecycleFinished}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("事件顺序不正确: %v", types)
+		}
+	}
+
+	// 取消订阅后事件通道关闭，不再接收事件
+	jobs.Monitor.Unsubscribe(sub)
+	if _, ok := <-sub.Events(); ok || jobs.Monitor.Subscribers() != 0 {
+		t.Error("取消订阅后事件通道应关闭")
+	}
+}
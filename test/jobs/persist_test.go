@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"concurrency-web-app/backend/services"
+)
+
+// flakyStore 模拟可以断开的数据库，按顺序记录写入成功的操作
+type flakyStore struct {
+	mu     sync.Mutex
+	down   bool
+	writes []string
+}
+
+func (s *flakyStore) setDown(down bool) {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+}
+
+func (s *flakyStore) write(op string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("connection refused")
+	}
+	s.writes = append(s.writes, op)
+	return nil
+}
+
+func (s *flakyStore) Create(info services.JobInfo) error { return s.write("create " + info.ID) }
+func (s *flakyStore) Flush(jobID string, delta services.ProgressDelta) error {
+	return s.write("flush " + jobID)
+}
+func (s *flakyStore) Complete(info services.JobInfo, metrics services.JobMetrics) error {
+	return s.write("complete " + info.ID)
+}
+
+// 数据库不可用时任务照常执行并标记为降级，登记和结果在数据库恢复后按顺序补写
+func TestJobRunsWhenStoreIsDown(t *testing.T) {
+	store := &flakyStore{down: true}
+	jobs := services.NewJobManager(store)
+	jobs.Persist = &services.PersistQueue{}
+
+	job, _ := jobs.Start(context.Background(), "order", "alice", 1)
+	if !job.Info().Degraded {
+		t.Fatal("数据库不可用时任务应标记为降级")
+	}
+	if status := jobs.Persist.Status(); !status.Degraded || status.Pending != 1 {
+		t.Fatalf("队列状态 %+v，应有 1 个等待重试的登记", status)
+	}
+
+	// 数据库恢复后、补写之前的任务也排在队列后面，保证登记先于结果
+	store.setDown(false)
+	jobs.Finish(job, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+	if len(store.writes) != 0 {
+		t.Fatalf("补写之前不应有写入: %v", store.writes)
+	}
+
+	if n := jobs.Persist.Retry(); n != 2 {
+		t.Fatalf("补写了 %d 个操作，应为 2", n)
+	}
+	want := []string{"create " + job.ID(), "complete " + job.ID()}
+	if len(store.writes) != 2 || store.writes[0] != want[0] || store.writes[1] != want[1] {
+		t.Fatalf("写入顺序 %v，应为 %v", store.writes, want)
+	}
+	if jobs.Persist.Degraded() {
+		t.Error("全部补写后应恢复")
+	}
+
+	// 恢复后的任务直接写入，不再降级
+	next, _ := jobs.Start(context.Background(), "order", "alice", 1)
+	if next.Info().Degraded || store.writes[len(store.writes)-1] != "create "+next.ID() {
+		t.Fatalf("恢复后的任务 %+v，写入 %v", next.Info(), store.writes)
+	}
+}
+
+// 重试遇到失败即停止，之后的操作保持原顺序
+func TestPersistQueueStopsAtFailure(t *testing.T) {
+	store := &flakyStore{down: true}
+	queue := &services.PersistQueue{}
+	for _, op := range []string{"a", "b"} {
+		op := op
+		if queue.Do(op, func() error { return store.write(op) }) {
+			t.Fatalf("数据库不可用时 %s 不应完成", op)
+		}
+	}
+	if n := queue.Retry(); n != 0 || queue.Status().Pending != 2 {
+		t.Fatalf("数据库不可用时补写了 %d 个，剩余 %d 个", n, queue.Status().Pending)
+	}
+
+	store.setDown(false)
+	if n := queue.Retry(); n != 2 || len(store.writes) != 2 || store.writes[0] != "a" {
+		t.Fatalf("补写了 %d 个，写入 %v", n, store.writes)
+	}
+}
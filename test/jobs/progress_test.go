@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 进度包含完成数、当前吞吐量和按吞吐量估算的剩余时间，任务结束后剩余时间为 0
+func TestJobProgress(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	job, ctx := jobs.Start(context.Background(), "order", "", 8)
+
+	progress := job.Progress()
+	if progress.CompletedTasks != 0 || progress.Throughput != 0 || progress.ETASeconds != nil {
+		t.Fatalf("尚无任务结束时不应估算剩余时间: %+v", progress)
+	}
+
+	orders := make([]services.OrderTask, 4)
+	for i := range orders {
+		orders[i] = services.OrderTask{ID: i + 1, CustomerID: "CUST_0001", ProductName: "p", Quantity: 1, Price: 1}
+	}
+	service := &services.OrderProcessService{MaxConcurrency: 4}
+	service.EachOrder(ctx, orders, func(services.TaskResult) {})
+
+	progress = job.Progress()
+	if progress.CompletedTasks != 4 || progress.RemainingTasks != 4 || progress.Progress != 50 || progress.Finished {
+		t.Fatalf("进度不正确: %+v", progress)
+	}
+	if progress.Throughput <= 0 || progress.ETASeconds == nil || *progress.ETASeconds <= 0 {
+		t.Errorf("应根据吞吐量估算剩余时间: %+v", progress)
+	}
+
+	jobs.Finish(job, &services.BatchResult{TotalTasks: 8, SuccessTasks: 8})
+	progress = job.Progress()
+	if !progress.Finished || progress.ETASeconds == nil || *progress.ETASeconds != 0 {
+		t.Errorf("任务结束后剩余时间应为 0: %+v", progress)
+	}
+}
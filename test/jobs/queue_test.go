@@ -0,0 +1,294 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// waitQueued 等待任务进入排队状态
+func waitQueued(t *testing.T, job *services.Job) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for job.Info().Status != services.JobStatusQueued {
+		if time.Now().After(deadline) {
+			t.Fatalf("任务 %s 没有排队", job.ID())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 名额用完后排队的任务按优先级类别执行，同类按进入顺序
+func TestJobQueueRunsHighPriorityFirst(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	jobs.MaxRunning = 1
+
+	running, ctx := jobs.Start(context.Background(), "order", "", 1)
+	if err := jobs.EnterQueue(ctx, running); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 3)
+	enter := func(priority string) *services.Job {
+		job, ctx := jobs.Start(context.Background(), "order", "", 1)
+		if err := job.SetPriority(priority); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			if err := jobs.EnterQueue(ctx, job); err == nil {
+				order <- job.Info().Priority
+			}
+		}()
+		waitQueued(t, job)
+		return job
+	}
+	low := enter(services.PriorityLow)
+	normal := enter("")
+	high := enter(services.PriorityHigh)
+
+	queue := jobs.Queue()
+	if queue.Running != 1 || len(queue.Queued) != 3 || queue.Queued[0].ID != high.ID() ||
+		queue.Queued[1].ID != normal.ID() || queue.Queued[2].ID != low.ID() {
+		t.Fatalf("队列状态不正确: %+v", queue)
+	}
+
+	// 每结束一个任务只放行一个排队的任务
+	want := []string{services.PriorityHigh, services.PriorityNormal, services.PriorityLow}
+	next := map[string]*services.Job{services.PriorityHigh: high, services.PriorityNormal: normal, services.PriorityLow: low}
+	finished := running
+	for _, priority := range want {
+		jobs.Finish(finished, &services.BatchResult{})
+		select {
+		case got := <-order:
+			if got != priority {
+				t.Fatalf("期望 %s 先执行，实际 %s", priority, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s 任务没有开始", priority)
+		}
+		finished = next[priority]
+		if info := finished.Info(); info.Status != services.JobStatusRunning || info.Priority != priority {
+			t.Errorf("任务状态为 %s，优先级 %q", info.Status, info.Priority)
+		}
+	}
+	jobs.Finish(finished, &services.BatchResult{})
+	if queue := jobs.Queue(); queue.Running != 0 || len(queue.Queued) != 0 {
+		t.Errorf("全部结束后队列应为空: %+v", queue)
+	}
+}
+
+// 排队中被取消的任务离开队列，不占用名额
+func TestJobQueueCancelWhileQueued(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	jobs.MaxRunning = 1
+	first, ctx := jobs.Start(context.Background(), "order", "", 1)
+	jobs.EnterQueue(ctx, first)
+
+	queued, qctx := jobs.Start(context.Background(), "order", "", 1)
+	entered := make(chan error, 1)
+	go func() { entered <- jobs.EnterQueue(qctx, queued) }()
+	waitQueued(t, queued)
+
+	if _, err := jobs.Cancel(queued.ID(), services.CancelModeSoft, ""); err != nil {
+		t.Fatalf("排队中的任务应可以取消: %v", err)
+	}
+	if err := <-entered; !errors.Is(err, context.Canceled) {
+		t.Fatalf("期望 context.Canceled，实际 %v", err)
+	}
+	jobs.Finish(queued, &services.BatchResult{})
+	if queue := jobs.Queue(); queue.Running != 1 || len(queue.Queued) != 0 {
+		t.Errorf("取消的任务不应占用名额: %+v", queue)
+	}
+
+	jobs.Finish(first, &services.BatchResult{})
+	if queue := jobs.Queue(); queue.Running != 0 {
+		t.Errorf("名额应全部释放: %+v", queue)
+	}
+	if err := first.SetPriority("urgent"); !errors.Is(err, services.ErrInvalidPriority) {
+		t.Errorf("期望 ErrInvalidPriority，实际 %v", err)
+	}
+}
+
+// 排队的任务（全局队列和互斥组）达到上限后拒绝新批次，排队的任务开始执行后恢复接收
+func TestJobQueueAdmitLimit(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	jobs.MaxRunning = 1
+	jobs.MaxQueued = 2
+
+	running, ctx := jobs.Start(context.Background(), "order", "", 1)
+	jobs.EnterGroup(ctx, running, "g")
+	jobs.EnterQueue(ctx, running)
+
+	// 一个在全局队列中排队，一个在互斥组中排队
+	queued, qctx := jobs.Start(context.Background(), "order", "", 1)
+	go jobs.EnterQueue(qctx, queued)
+	waitQueued(t, queued)
+	admission, err := jobs.Admit("")
+	if err != nil {
+		t.Fatalf("未达到上限时应接收: %v", err)
+	}
+	admission.Release()
+	grouped, gctx := jobs.Start(context.Background(), "order", "", 1)
+	go jobs.EnterGroup(gctx, grouped, "g")
+	waitQueued(t, grouped)
+
+	if _, err := jobs.Admit(""); !errors.Is(err, services.ErrQueueFull) {
+		t.Fatalf("期望 ErrQueueFull，实际 %v", err)
+	}
+	if queue := jobs.Queue(); queue.Pending != 2 || len(queue.Queued) != 1 {
+		t.Fatalf("队列状态不正确: %+v", queue)
+	}
+
+	// 执行中的任务结束后排队的任务开始执行，排队数随之减少
+	jobs.Finish(running, &services.BatchResult{})
+	deadline := time.Now().Add(time.Second)
+	for queued.Info().Status != services.JobStatusRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("排队的任务没有开始")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := jobs.Admit(""); err != nil {
+		t.Errorf("排队数低于上限后应恢复接收: %v", err)
+	}
+}
+
+// firstEvent 返回任务的第一条事件
+func firstEvent(t *testing.T, job *services.Job) services.JobEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var got services.JobEvent
+	stop := errors.New("stop")
+	if err := job.Follow(ctx, 0, func(e services.JobEvent) error { got = e; return stop }); err != stop {
+		t.Fatalf("任务 %s 没有事件: %v", job.ID(), err)
+	}
+	return got
+}
+
+// 排队已满时 high 批次仍被接收，抢占最早排队的 low 任务的位置，后者移到队尾，抢占记入双方的事件
+func TestJobQueueHighPriorityPreempts(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	jobs.MaxRunning = 1
+	jobs.MaxQueued = 2
+
+	running, ctx := jobs.Start(context.Background(), "order", "", 1)
+	jobs.EnterQueue(ctx, running)
+	enter := func(priority string) *services.Job {
+		job, ctx := jobs.Start(context.Background(), "order", "", 1)
+		job.SetPriority(priority)
+		go jobs.EnterQueue(ctx, job)
+		waitQueued(t, job)
+		return job
+	}
+	first := enter(services.PriorityLow)
+	second := enter(services.PriorityLow)
+
+	if _, err := jobs.Admit(services.PriorityNormal); !errors.Is(err, services.ErrQueueFull) {
+		t.Fatalf("normal 批次期望 ErrQueueFull，实际 %v", err)
+	}
+	admission, err := jobs.Admit(services.PriorityHigh)
+	if err != nil {
+		t.Fatalf("有排队的 low 任务时应接收 high 批次: %v", err)
+	}
+	admission.Release()
+	high := enter(services.PriorityHigh)
+
+	queue := jobs.Queue()
+	if len(queue.Queued) != 3 || queue.Queued[0].ID != high.ID() || queue.Queued[1].ID != second.ID() || queue.Queued[2].ID != first.ID() {
+		t.Fatalf("被抢占的任务应移到队尾: %+v", queue.Queued)
+	}
+	for _, job := range []*services.Job{high, first} {
+		e := firstEvent(t, job)
+		p, ok := e.Data.(services.Preemption)
+		if e.Type != services.JobEventPreemption || !ok || p.JobID != high.ID() || p.PreemptedID != first.ID() {
+			t.Errorf("任务 %s 的抢占事件不正确: %+v", job.ID(), e)
+		}
+	}
+
+	// 全局队列中没有 low 任务时 high 批次同样被拒绝
+	admission, err = jobs.Admit(services.PriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admission.Release()
+	jobs.Cancel(first.ID(), services.CancelModeSoft, "")
+	jobs.Cancel(second.ID(), services.CancelModeSoft, "")
+	enter(services.PriorityHigh)
+	deadline := time.Now().Add(time.Second)
+	for len(jobs.Queue().Queued) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("取消的任务没有离开队列: %+v", jobs.Queue())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := jobs.Admit(services.PriorityHigh); !errors.Is(err, services.ErrQueueFull) {
+		t.Errorf("没有可抢占的任务时期望 ErrQueueFull，实际 %v", err)
+	}
+}
+
+// 同时提交的批次在同一把锁内预留名额，接收的批次数不超过上限；名额交给任务后在排队时转为排队数，未交给任务时释放
+func TestJobQueueAdmitReserves(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	jobs.MaxRunning = 1
+	jobs.MaxQueued = 3
+
+	running, ctx := jobs.Start(context.Background(), "order", "", 1)
+	jobs.EnterQueue(ctx, running)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var admitted []*services.Admission
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if a, err := jobs.Admit(""); err == nil {
+				mu.Lock()
+				admitted = append(admitted, a)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(admitted) != 3 || jobs.Queue().Pending != 3 {
+		t.Fatalf("期望接收 3 个批次，实际 %d 个，排队数 %d", len(admitted), jobs.Queue().Pending)
+	}
+
+	// 交给任务的名额在进入全局队列时转为排队，排队数不变，重复释放不影响
+	queued, qctx := jobs.Start(context.Background(), "order", "", 1)
+	jobs.Claim(queued, admitted[0])
+	go jobs.EnterQueue(qctx, queued)
+	waitQueued(t, queued)
+	admitted[0].Release()
+	if info := jobs.Queue(); info.Pending != 3 || len(info.Queued) != 1 {
+		t.Fatalf("名额转为排队后排队数应不变: %+v", info)
+	}
+
+	// 被拒绝的请求释放名额后可以接收新批次
+	admitted[1].Release()
+	admitted[1].Release()
+	if info := jobs.Queue(); info.Pending != 2 {
+		t.Fatalf("释放后排队数期望 2，实际 %d", info.Pending)
+	}
+	if _, err := jobs.Admit(""); err != nil {
+		t.Errorf("释放名额后应接收: %v", err)
+	}
+	if _, err := jobs.Admit(""); !errors.Is(err, services.ErrQueueFull) {
+		t.Errorf("期望 ErrQueueFull，实际 %v", err)
+	}
+
+	// 排队的任务开始执行后离开队列，名额随之释放
+	jobs.Finish(running, &services.BatchResult{})
+	deadline := time.Now().Add(time.Second)
+	for jobs.Queue().Pending != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("排队的任务开始执行后排队数应减少: %+v", jobs.Queue())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
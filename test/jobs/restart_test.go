@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// 服务重启后内存中已没有任务，结果从数据库登记的产出物读取，只有任务的发起人可以读取
+func TestJobResultSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(dir, "jobs.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := &services.DBProgressStore{DB: db}
+	artifacts := &services.ArtifactService{DB: db, Dir: filepath.Join(dir, "artifacts")}
+
+	jobs := services.NewJobManager(progress)
+	job, _ := jobs.Start(context.Background(), "order", "alice", 2)
+	jobs.Finish(job, &services.BatchResult{TotalTasks: 2, SuccessTasks: 1, FailedTasks: 1, Results: []services.TaskResult{
+		{ID: 0, Success: true, Status: services.TaskStatusSuccess, Data: map[string]interface{}{"total_price": 10.0}},
+		{ID: 1, Status: services.TaskStatusFailed, Error: "订单 2 库存不足", ErrorClass: services.ErrorClassBusiness},
+	}})
+	if _, err := artifacts.SaveJobResult(job.Info(), job.Result()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟重启：新的任务管理器中没有该任务
+	if _, ok := services.NewJobManager(progress).Get(job.ID()); ok {
+		t.Fatal("新的任务管理器不应有重启前的任务")
+	}
+	history, total, err := progress.History(services.HistoryQuery{Owner: "alice", Type: "order", Page: 1, PageSize: 20})
+	if err != nil || total != 1 || history[0].JobID != job.ID() || history[0].Status != "completed" {
+		t.Fatalf("任务记录 %+v，total=%d，err=%v", history, total, err)
+	}
+
+	info, result, err := artifacts.LoadJobResult(job.ID(), "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != job.ID() || info.EndTime == nil || result.SuccessTasks != 1 || len(result.Results) != 2 ||
+		result.Results[1].Error != "订单 2 库存不足" || result.Results[1].ErrorClass != services.ErrorClassBusiness {
+		t.Fatalf("读取的任务 %+v，结果 %+v", info, result)
+	}
+
+	if _, _, err := artifacts.LoadJobResult(job.ID(), "bob"); !errors.Is(err, services.ErrJobOutputNotFound) {
+		t.Errorf("其他用户读取应返回 ErrJobOutputNotFound，实际 %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// resultIDs 返回分页结果中的任务序号
+func resultIDs(page *services.ResultPage) []int {
+	ids := make([]int, len(page.Results))
+	for i, result := range page.Results {
+		ids[i] = result.ID
+	}
+	return ids
+}
+
+func sameIDs(got []int, want ...int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// gateTask 序号不小于 3 的任务等到 gate 关闭后才结束
+type gateTask struct {
+	N    int `json:"n"`
+	gate chan struct{}
+}
+
+func (t gateTask) Execute(ctx context.Context) (interface{}, error) {
+	if t.N >= 3 {
+		select {
+		case <-t.gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return t.N, nil
+}
+
+type gateKind struct{ gate chan struct{} }
+
+func (gateKind) Name() string { return "gate" }
+
+func (k gateKind) Decode(raw json.RawMessage) (services.Task, error) {
+	task := gateTask{gate: k.gate}
+	if err := json.Unmarshal(raw, &task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// 执行中的任务按快照分页：之后结束的任务不进入已建立的快照，翻页不偏移，has_newer 提示有新结果；
+// 任务结束后原快照的游标仍可继续翻页，不带游标时改为分页查询最终结果
+func TestResultPageSnapshot(t *testing.T) {
+	gate := make(chan struct{})
+	service := &services.KindService{Kind: gateKind{gate: gate}, MaxConcurrency: 6, Timeout: 10 * time.Second}
+	raws := make([]json.RawMessage, 6)
+	for i := range raws {
+		raws[i], _ = json.Marshal(map[string]int{"n": 5 - i})
+	}
+	tasks, err := service.Decode(raws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := services.NewJobManager(nil)
+	job, ctx := jobs.Start(context.Background(), "gate", "alice", len(tasks))
+	done := make(chan *services.BatchResult, 1)
+	go func() { done <- service.BatchProcess(ctx, tasks) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Info().CompletedTasks < 3 {
+		if time.Now().After(deadline) {
+			close(gate)
+			t.Fatal("等待任务结束超时")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 第一页建立快照：序号为 3-5 的任务（n 小于 3）已结束
+	first, err := job.ResultPage("", 2)
+	if err != nil {
+		close(gate)
+		t.Fatal(err)
+	}
+	if !sameIDs(resultIDs(first), 3, 4) || first.Snapshot != 3 || first.NextCursor == "" || first.HasNewer || first.Final {
+		close(gate)
+		t.Fatalf("第一页 %+v", first)
+	}
+
+	// 其余任务结束后按游标翻页，仍在原快照中
+	close(gate)
+	result := <-done
+	second, err := job.ResultPage(first.NextCursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameIDs(resultIDs(second), 5) || second.NextCursor != "" || !second.HasNewer || !second.SnapshotAt.Equal(first.SnapshotAt) {
+		t.Fatalf("第二页 %+v", second)
+	}
+	if fresh, _ := job.ResultPage("", 10); fresh == nil || !sameIDs(resultIDs(fresh), 0, 1, 2, 3, 4, 5) || fresh.HasNewer {
+		t.Fatalf("新快照 %+v", fresh)
+	}
+
+	// 任务结束后原快照的游标仍可使用，新的分页取自最终结果
+	jobs.Finish(job, result)
+	again, err := job.ResultPage(first.NextCursor, 2)
+	if err != nil || !sameIDs(resultIDs(again), 5) || !again.HasNewer {
+		t.Fatalf("任务结束后按原游标翻页 %+v，err=%v", again, err)
+	}
+	final, err := job.ResultPage("", 4)
+	if err != nil || !final.Final || final.Snapshot != 6 || !sameIDs(resultIDs(final), 0, 1, 2, 3) {
+		t.Fatalf("最终结果的第一页 %+v，err=%v", final, err)
+	}
+	if last, err := services.FinalResultPage(job.Info(), result, final.NextCursor, 4); err != nil || !sameIDs(resultIDs(last), 4, 5) || last.NextCursor != "" {
+		t.Fatalf("最终结果的第二页 %+v，err=%v", last, err)
+	}
+
+	// 执行中的快照在任务不在内存中时无法还原
+	if _, err := services.FinalResultPage(job.Info(), result, first.NextCursor, 2); !errors.Is(err, services.ErrCursorExpired) {
+		t.Errorf("期望 ErrCursorExpired，实际 %v", err)
+	}
+}
+
+// 游标格式错误或属于其他任务时拒绝；不保留结果的批次不能分页查询
+func TestResultPageErrors(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	job, _ := jobs.Start(context.Background(), "order", "alice", 1)
+	other, _ := jobs.Start(context.Background(), "order", "alice", 1)
+
+	if _, err := job.ResultPage("not-a-cursor", 10); !errors.Is(err, services.ErrInvalidCursor) {
+		t.Errorf("格式错误的游标期望 ErrInvalidCursor，实际 %v", err)
+	}
+	page, err := other.ResultPage("", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor != "" || len(page.Results) != 0 {
+		t.Fatalf("没有结果时的分页 %+v", page)
+	}
+
+	jobs.Finish(other, &services.BatchResult{TotalTasks: 1, Results: []services.TaskResult{{ID: 0}}})
+	if final, _ := other.ResultPage("", 10); final == nil || !final.Final {
+		t.Fatalf("已结束任务的分页 %+v", final)
+	}
+	cursor, _ := services.FinalResultPage(other.Info(), &services.BatchResult{Results: make([]services.TaskResult, 3)}, "", 1)
+	if _, err := job.ResultPage(cursor.NextCursor, 10); !errors.Is(err, services.ErrInvalidCursor) {
+		t.Errorf("其他任务的游标期望 ErrInvalidCursor，实际 %v", err)
+	}
+
+	job.DiscardResults()
+	if _, err := job.ResultPage("", 10); !errors.Is(err, services.ErrResultsNotRetained) {
+		t.Errorf("期望 ErrResultsNotRetained，实际 %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// transitionPath 返回状态变更经过的状态，如 "submitted>running>completed"
+func transitionPath(transitions []models.JobTransition) string {
+	states := make([]string, len(transitions))
+	for i, t := range transitions {
+		states[i] = t.To
+	}
+	return strings.Join(states, ">")
+}
+
+// 任务的状态变更按顺序记录触发者，任务结束后写入数据库，服务重启后仍可查询
+func TestJobTransitions(t *testing.T) {
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "jobs.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &services.TransitionStore{DB: db}
+	jobs := services.NewJobManager(&services.DBProgressStore{DB: db})
+	jobs.Transitions = store
+
+	service := &services.KindService{Kind: doubleKind{hang: new(atomic.Bool)}, MaxConcurrency: 2, Timeout: 10 * time.Second}
+	tasks, err := service.Decode([]json.RawMessage{[]byte(`{"n":1}`), []byte(`{"n":2}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, ctx := jobs.Start(context.Background(), "double", "alice", len(tasks))
+	jobs.Finish(job, service.BatchProcess(ctx, tasks))
+
+	transitions := job.Transitions()
+	if path := transitionPath(transitions); path != "submitted>running>completed" {
+		t.Fatalf("状态变更 %s", path)
+	}
+	if transitions[0].From != "" || transitions[0].Actor != "alice" || transitions[1].From != services.JobStateSubmitted || transitions[2].Actor != services.ActorSystem {
+		t.Errorf("状态变更 %+v", transitions)
+	}
+
+	saved, err := store.Load(job.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transitionPath(saved) != transitionPath(transitions) || saved[0].Actor != "alice" {
+		t.Errorf("保存的状态变更 %+v", saved)
+	}
+
+	// 服务重启后未能恢复的任务标记为 interrupted 时追加一条状态变更
+	other, _ := jobs.Start(context.Background(), "double", "alice", 1)
+	if err := (&services.CheckpointStore{DB: db}).MarkInterrupted(other.ID(), "没有保存检查点"); err != nil {
+		t.Fatal(err)
+	}
+	saved, err = store.Load(other.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := transitionPath(saved); path != "submitted>interrupted" || saved[1].From != services.JobStatusRunning || saved[1].Reason != "没有保存检查点" {
+		t.Errorf("中断任务的状态变更 %s %+v", path, saved)
+	}
+}
+
+// 用户取消时状态变更的触发者为该用户，原因为取消模式；排队时记录排队的原因
+func TestJobTransitionsCancelAndQueue(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	first, ctx := jobs.Start(context.Background(), "order", "alice", 1)
+	if err := jobs.EnterGroup(ctx, first, "nightly"); err != nil {
+		t.Fatal(err)
+	}
+	second, ctx2 := jobs.Start(context.Background(), "order", "alice", 1)
+	entered := make(chan error, 1)
+	go func() { entered <- jobs.EnterGroup(ctx2, second, "nightly") }()
+
+	deadline := time.Now().Add(time.Second)
+	for second.Info().Status != services.JobStatusQueued {
+		if time.Now().After(deadline) {
+			t.Fatal("第二个任务没有排队")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, err := jobs.Cancel(second.ID(), services.CancelModeHard, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	<-entered
+	jobs.Finish(second, &services.BatchResult{TotalTasks: 1, CancelledTasks: 1})
+	jobs.Finish(first, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+
+	transitions := second.Transitions()
+	if path := transitionPath(transitions); path != "submitted>queued>cancelled" {
+		t.Fatalf("状态变更 %s", path)
+	}
+	if queued := transitions[1]; queued.Actor != services.ActorSystem || !strings.Contains(queued.Reason, "nightly") {
+		t.Errorf("排队的状态变更 %+v", queued)
+	}
+	if cancelled := transitions[2]; cancelled.From != services.JobStatusQueued || cancelled.Actor != "bob" || !strings.Contains(cancelled.Reason, "hard") {
+		t.Errorf("取消的状态变更 %+v", cancelled)
+	}
+}
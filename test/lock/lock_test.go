@@ -0,0 +1,93 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/models"
+)
+
+// newLockManager 在临时目录的 SQLite 数据库上创建锁管理器
+func newLockManager(t *testing.T) *models.LockManager {
+	db, _, err := models.InitDB(models.DBConfig{
+		Driver: "sqlite",
+		DSN:    filepath.Join(t.TempDir(), "lock.db"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.NewLockManager(db, "sqlite")
+}
+
+// 锁被持有时其他实例获取失败，释放后可以重新获取
+func TestTableLockExclusive(t *testing.T) {
+	first := newLockManager(t)
+	second := models.NewLockManager(first.DB, "sqlite")
+	ctx := context.Background()
+
+	release, err := first.TryLock(ctx, "janitor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.TryLock(ctx, "janitor"); !errors.Is(err, models.ErrLockHeld) {
+		t.Fatalf("期望 ErrLockHeld，实际: %v", err)
+	}
+	if _, err := first.TryLock(ctx, "janitor"); !errors.Is(err, models.ErrLockHeld) {
+		t.Fatalf("同一实例重复获取也应失败，实际: %v", err)
+	}
+
+	release()
+	release2, err := second.TryLock(ctx, "janitor")
+	if err != nil {
+		t.Fatalf("释放后应能获取: %v", err)
+	}
+	release2()
+}
+
+// 持有者失联后锁过期，其他实例可以抢占
+func TestTableLockExpires(t *testing.T) {
+	first := newLockManager(t)
+	first.TTL = 300 * time.Millisecond
+	second := models.NewLockManager(first.DB, "sqlite")
+	ctx := context.Background()
+
+	// 直接写入一条已过期的锁，模拟持有者崩溃
+	err := first.DB.Create(&models.DistributedLock{
+		Name:      "janitor",
+		Owner:     "crashed",
+		ExpiresAt: time.Now().Add(-time.Second),
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := second.TryLock(ctx, "janitor")
+	if err != nil {
+		t.Fatalf("过期的锁应能被抢占: %v", err)
+	}
+	release()
+}
+
+// Lock 等待持有者释放
+func TestLockWaits(t *testing.T) {
+	first := newLockManager(t)
+	first.Poll = 20 * time.Millisecond
+	ctx := context.Background()
+
+	release, err := first.TryLock(ctx, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(100*time.Millisecond, release)
+
+	waitCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	release2, err := first.Lock(waitCtx, "migrations")
+	if err != nil {
+		t.Fatalf("等待锁失败: %v", err)
+	}
+	release2()
+}
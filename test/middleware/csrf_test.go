@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"concurrency-web-app/backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// csrfServer 注册受 CSRF 防护的接口，read 记录处理函数是否执行
+func csrfServer(read *bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.CSRF(middleware.CSRFConfig{SessionCookies: []string{"session"}}))
+	r.GET("/token", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"csrf_token": middleware.CSRFToken(c)})
+	})
+	r.POST("/submit", func(c *gin.Context) {
+		*read = true
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+// csrfCookie 请求 /token 并返回下发的令牌 cookie
+func csrfCookie(t *testing.T, r *gin.Engine) *http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/token", nil))
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "csrf_token" {
+			return cookie
+		}
+	}
+	t.Fatal("没有下发 csrf_token cookie")
+	return nil
+}
+
+// 没有令牌 cookie 时下发新令牌，与接口返回的令牌相同；已持有令牌时不重新下发
+func TestCSRFIssuesToken(t *testing.T) {
+	var read bool
+	r := csrfServer(&read)
+	cookie := csrfCookie(t, r)
+	if len(cookie.Value) != 64 || cookie.SameSite != http.SameSiteStrictMode {
+		t.Fatalf("令牌 cookie 不正确: %+v", cookie)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/token", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if len(w.Result().Cookies()) != 0 || !strings.Contains(w.Body.String(), cookie.Value) {
+		t.Errorf("已持有令牌时应沿用原令牌: %v %s", w.Result().Cookies(), w.Body.String())
+	}
+}
+
+// 携带 cookie 的修改请求须提交相同的令牌，缺失或不一致时返回 403；不带 cookie 的请求不校验
+func TestCSRFValidatesToken(t *testing.T) {
+	var read bool
+	r := csrfServer(&read)
+	cookie := csrfCookie(t, r)
+	session := &http.Cookie{Name: "session", Value: "s"}
+
+	cases := []struct {
+		name    string
+		cookies []*http.Cookie
+		header  string
+		form    url.Values
+		want    int
+	}{
+		{"请求头中的令牌一致", []*http.Cookie{cookie}, cookie.Value, nil, http.StatusOK},
+		{"表单中的令牌一致", []*http.Cookie{cookie}, "", url.Values{"csrf_token": {cookie.Value}}, http.StatusOK},
+		{"缺少令牌", []*http.Cookie{cookie}, "", nil, http.StatusForbidden},
+		{"令牌不一致", []*http.Cookie{cookie}, strings.Repeat("0", 64), nil, http.StatusForbidden},
+		{"只有会话 cookie", []*http.Cookie{session}, cookie.Value, nil, http.StatusForbidden},
+		{"不带 cookie", nil, "", nil, http.StatusOK},
+	}
+	for _, c := range cases {
+		read = false
+		var req *http.Request
+		if c.form != nil {
+			req = httptest.NewRequest(http.MethodPost, "/submit", strings.NewReader(c.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(http.MethodPost, "/submit", nil)
+		}
+		for _, cookie := range c.cookies {
+			req.AddCookie(cookie)
+		}
+		if c.header != "" {
+			req.Header.Set("X-CSRF-Token", c.header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != c.want || read != (c.want == http.StatusOK) {
+			t.Errorf("%s: 期望 %d，实际 %d，处理函数执行 %v", c.name, c.want, w.Code, read)
+		}
+	}
+}
+
+// multipart 请求的令牌字段不被读取，须通过请求头提交
+func TestCSRFIgnoresMultipartField(t *testing.T) {
+	var read bool
+	r := csrfServer(&read)
+	cookie := csrfCookie(t, r)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("csrf_token", cookie.Value)
+	form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/submit", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || read {
+		t.Errorf("multipart 表单中的令牌不应被接受，实际 %d", w.Code)
+	}
+}
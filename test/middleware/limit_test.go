@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limitedServer 注册一个受并发限制的接口，处理函数读取请求体后等待 release 关闭
+// reads 统计限制器之后读取请求体的次数
+func limitedServer(cfg middleware.LimitConfig, release <-chan struct{}, reads *int64) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/batch", middleware.ConcurrencyLimit(cfg), func(c *gin.Context) {
+		atomic.AddInt64(reads, 1)
+		if _, err := io.ReadAll(c.Request.Body); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.Status(http.StatusRequestEntityTooLarge)
+				return
+			}
+		}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+// post 在后台发送请求，结果写入返回的通道
+func post(r *gin.Engine, body string) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(body)))
+		done <- w
+	}()
+	return done
+}
+
+// waitReads 等待处理函数开始执行的请求数达到 n
+func waitReads(t *testing.T, reads *int64, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(reads) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("期望 %d 个请求开始执行，实际 %d", n, atomic.LoadInt64(reads))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// 超出并发上限的请求排队，名额释放后继续执行；队列已满时立即返回 429 和 Retry-After，且不读取请求体
+func TestConcurrencyLimitQueueAndOverflow(t *testing.T) {
+	release := make(chan struct{})
+	var reads int64
+	r := limitedServer(middleware.LimitConfig{Max: 1, Queue: 1, Wait: 5 * time.Second}, release, &reads)
+
+	first := post(r, "{}")
+	waitReads(t, &reads, 1)
+	queued := post(r, "{}")
+	time.Sleep(50 * time.Millisecond)
+
+	w := <-post(r, "{}")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "5" {
+		t.Fatalf("队列已满期望 429 和 Retry-After: 5，实际 %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if n := atomic.LoadInt64(&reads); n != 1 {
+		t.Errorf("排队和被拒绝的请求不应执行处理函数，实际执行 %d 次", n)
+	}
+
+	close(release)
+	if w := <-first; w.Code != http.StatusOK {
+		t.Errorf("第一个请求 %d", w.Code)
+	}
+	if w := <-queued; w.Code != http.StatusOK {
+		t.Errorf("排队的请求在名额释放后应执行，实际 %d", w.Code)
+	}
+}
+
+// 排队超过 Wait 时返回 429
+func TestConcurrencyLimitWaitTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var reads int64
+	r := limitedServer(middleware.LimitConfig{Max: 1, Queue: 5, Wait: 50 * time.Millisecond}, release, &reads)
+
+	post(r, "{}")
+	waitReads(t, &reads, 1)
+	start := time.Now()
+	w := <-post(r, "{}")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" || !strings.Contains(w.Body.String(), "超时") {
+		t.Fatalf("等待超时期望 429，实际 %d %q %s", w.Code, w.Header().Get("Retry-After"), w.Body.String())
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("应等待 Wait 后才返回，实际 %s", waited)
+	}
+}
+
+// 取得名额后请求体超过 MaxBodyBytes 时读取失败
+func TestConcurrencyLimitMaxBodyBytes(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	var reads int64
+	r := limitedServer(middleware.LimitConfig{Max: 1, MaxBodyBytes: 8}, release, &reads)
+
+	if w := <-post(r, strings.Repeat("x", 16)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("超出上限期望 413，实际 %d", w.Code)
+	}
+	if w := <-post(r, "{}"); w.Code != http.StatusOK {
+		t.Errorf("未超出上限期望 200，实际 %d", w.Code)
+	}
+}
@@ -0,0 +1,96 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"concurrency-web-app/backend/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createRequest struct {
+	Name  string   `json:"name" binding:"required"`
+	Count int      `json:"count" binding:"min=1,max=10"`
+	Tags  []string `json:"tags"`
+}
+
+// newServer 注册一个带请求体和查询参数的接口
+func newServer() (*gin.Engine, *openapi.Spec) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	spec := openapi.NewSpec("test", "1.0.0")
+	api := openapi.NewRouter(r.Group("/api"), spec)
+	api.POST("/items/:id", openapi.Operation{
+		Summary: "创建",
+		Body:    createRequest{},
+		Params:  []openapi.Param{openapi.QueryEnum("mode", "", "a", "b")},
+	}, func(c *gin.Context) {
+		var req createRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Status(http.StatusTeapot)
+			return
+		}
+		c.String(http.StatusOK, req.Name)
+	})
+	return r, spec
+}
+
+func do(r *gin.Engine, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// 符合定义的请求原样交给处理函数，请求体仍可绑定
+func TestValidRequestPassesThrough(t *testing.T) {
+	r, _ := newServer()
+	w := do(r, "/api/items/1?mode=a", `{"name":"x","count":3,"tags":["t"]}`)
+	if w.Code != http.StatusOK || w.Body.String() != "x" {
+		t.Fatalf("期望 200 x，实际 %d %s", w.Code, w.Body.String())
+	}
+}
+
+// 不符合定义的请求返回400并指出出错的字段
+func TestInvalidRequestRejected(t *testing.T) {
+	r, _ := newServer()
+	cases := map[string]struct{ target, body, field string }{
+		"缺少必填字段": {"/api/items/1", `{"count":3}`, "body.name"},
+		"超出范围":   {"/api/items/1", `{"name":"x","count":11}`, "body.count"},
+		"类型错误":   {"/api/items/1", `{"name":"x","tags":[1]}`, "body.tags[0]"},
+		"枚举参数":   {"/api/items/1?mode=c", `{"name":"x","count":1}`, "query.mode"},
+		"非法JSON": {"/api/items/1", `{`, "body"},
+	}
+	for name, tc := range cases {
+		w := do(r, tc.target, tc.body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tc.field) {
+			t.Errorf("%s: 期望 400 且包含 %s，实际 %d %s", name, tc.field, w.Code, w.Body.String())
+		}
+	}
+}
+
+// 文档中的路径、参数和请求体约束来自同一份定义
+func TestDocument(t *testing.T) {
+	_, spec := newServer()
+	paths := spec.Document()["paths"].(map[string]map[string]interface{})
+	op, ok := paths["/api/items/{id}"]["post"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("文档缺少 POST /api/items/{id}: %v", paths)
+	}
+	if params := op["parameters"].([]map[string]interface{}); len(params) != 2 {
+		t.Fatalf("期望 mode 和 id 两个参数，实际 %v", params)
+	}
+
+	schema := openapi.SchemaOf(createRequest{})
+	if len(schema.Required) != 1 || schema.Required[0] != "name" {
+		t.Fatalf("required 不正确: %v", schema.Required)
+	}
+	count := schema.Properties["count"]
+	if *count.Minimum != 1 || *count.Maximum != 10 {
+		t.Fatalf("count 范围不正确: %v %v", *count.Minimum, *count.Maximum)
+	}
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+func split(ctx context.Context, item interface{}) ([]interface{}, error) {
+	var out []interface{}
+	for _, part := range strings.Split(item.(string), ",") {
+		out = append(out, part)
+	}
+	return out, nil
+}
+
+// 扇出的数据项全部到达末端后输入才算完成，某个数据项失败时该输入失败，其余输入不受影响
+func TestPipelineFanOut(t *testing.T) {
+	var running, peak int32
+	p := services.NewPipeline(5*time.Second).
+		Stage("split", 1, 0, split).
+		Stage("check", 3, 4, func(ctx context.Context, item interface{}) ([]interface{}, error) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			if item == "bad" {
+				return nil, errors.New("无效数据")
+			}
+			return []interface{}{strings.ToUpper(item.(string))}, nil
+		})
+
+	result := p.Run(context.Background(), []interface{}{"a,b,c", "d,bad", "e"})
+	if result.TotalTasks != 3 || result.SuccessTasks != 2 || result.FailedTasks != 1 {
+		t.Fatalf("计数不正确: total=%d success=%d failed=%d", result.TotalTasks, result.SuccessTasks, result.FailedTasks)
+	}
+	for _, r := range result.Results {
+		switch r.ID {
+		case 0:
+			var got []string
+			for _, v := range r.Data.([]interface{}) {
+				got = append(got, v.(string))
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != "A,B,C" {
+				t.Errorf("输入0的输出为 %v", got)
+			}
+		case 1:
+			if r.Success || !strings.Contains(r.Error, "阶段 check") {
+				t.Errorf("输入1应在 check 阶段失败，实际 success=%v error=%s", r.Success, r.Error)
+			}
+		}
+	}
+	if peak < 2 || peak > 3 {
+		t.Errorf("check 阶段的并发峰值为 %d，期望不超过3且大于1", peak)
+	}
+	if s := result.Stages[1]; s.Received != 6 || s.Failed != 1 || s.Emitted != 5 {
+		t.Errorf("check 阶段统计不正确: %+v", s)
+	}
+}
+
+// 超时返回时已进入流水线的输入记为超时，尚未进入的记为未开始
+func TestPipelineTimeout(t *testing.T) {
+	p := services.NewPipeline(150*time.Millisecond).
+		Stage("slow", 1, 0, func(ctx context.Context, item interface{}) ([]interface{}, error) {
+			select {
+			case <-time.After(100 * time.Millisecond):
+				return []interface{}{item}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		})
+
+	result := p.Run(context.Background(), []interface{}{"a", "b", "c", "d"})
+	if len(result.Results) != 4 || result.SuccessTasks != 1 || result.TimeoutTasks < 1 || result.NotStartedTasks < 1 {
+		t.Fatalf("计数不正确: results=%d success=%d timeout=%d not_started=%d",
+			len(result.Results), result.SuccessTasks, result.TimeoutTasks, result.NotStartedTasks)
+	}
+}
+
+// store 阶段将到达的数据项逐行写入输出文件
+func TestPipelineServiceStore(t *testing.T) {
+	s := &services.PipelineService{OutputDir: t.TempDir(), Timeout: 5 * time.Second}
+	specs := []services.PipelineStageSpec{
+		{Action: services.PipelineSplitLines},
+		{Action: services.PipelineTrim, Concurrency: 2},
+		{Action: services.PipelineUpper, Concurrency: 2, Buffer: 8},
+		{Action: services.PipelineStore},
+	}
+
+	result, err := s.Run(context.Background(), "job1", specs, []string{"a\n b \n\n", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SuccessTasks != 2 || len(result.Outputs) != 1 {
+		t.Fatalf("期望2个成功、1个输出文件，实际 %d 个成功、%v", result.SuccessTasks, result.Outputs)
+	}
+	content, err := os.ReadFile(result.Outputs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Fields(string(content))
+	sort.Strings(lines)
+	if strings.Join(lines, ",") != "A,B,C" {
+		t.Errorf("输出文件内容为 %q", content)
+	}
+
+	if _, err := s.Run(context.Background(), "job2", []services.PipelineStageSpec{{Action: "unzip"}}, []string{"a"}); !errors.Is(err, services.ErrUnknownPipelineAction) {
+		t.Errorf("未知动作应返回 ErrUnknownPipelineAction，实际 %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package pools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+func newPools(t *testing.T) *services.WorkerPools {
+	t.Helper()
+	pools, err := services.NewWorkerPools(services.PoolConfig{
+		Pools: map[string]int{"external-apis": 2, "disk-io": 1},
+		Rules: []services.PoolRule{
+			{Host: "*.example.com", Pool: "external-apis"},
+			{TaskType: services.PoolTaskFile, Pool: "disk-io"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pools
+}
+
+// 按顺序匹配规则，没有规则匹配的任务不使用工作池
+func TestWorkerPoolsRoute(t *testing.T) {
+	pools := newPools(t)
+	cases := []struct {
+		taskType, host, want string
+	}{
+		{services.PoolTaskAPI, "api.example.com", "external-apis"},
+		{services.PoolTaskPipeline, "cdn.example.com", "external-apis"},
+		{services.PoolTaskAPI, "localhost", ""},
+		{services.PoolTaskFile, "", "disk-io"},
+		{services.PoolTaskOrder, "", ""},
+	}
+	for _, c := range cases {
+		got := ""
+		if pool := pools.Route(c.taskType, c.host); pool != nil {
+			got = pool.Name
+		}
+		if got != c.want {
+			t.Errorf("Route(%s, %s) = %q，期望 %q", c.taskType, c.host, got, c.want)
+		}
+	}
+}
+
+// 池满时等待槽位，ctx 结束时返回其错误
+func TestWorkerPoolsAcquireBlocks(t *testing.T) {
+	pools := newPools(t)
+	release, err := pools.Acquire(context.Background(), services.PoolTaskFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pools.Acquire(ctx, services.PoolTaskFile, ""); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望等待到超时，实际 %v", err)
+	}
+	if stats := pools.Stats(); stats[0].Name != "disk-io" || stats[0].InUse != 1 {
+		t.Errorf("使用情况不正确: %+v", stats)
+	}
+
+	release()
+	next, err := pools.Acquire(context.Background(), services.PoolTaskFile, "")
+	if err != nil {
+		t.Fatalf("释放后仍无法取得槽位: %v", err)
+	}
+	next()
+}
+
+// 规则引用未定义的池或池的大小不合法时拒绝配置
+func TestWorkerPoolsInvalidConfig(t *testing.T) {
+	configs := []services.PoolConfig{
+		{Pools: map[string]int{"a": 0}},
+		{Pools: map[string]int{"a": 1}, Rules: []services.PoolRule{{Pool: "b"}}},
+		{Pools: map[string]int{"a": 1}, Rules: []services.PoolRule{{Host: "[", Pool: "a"}}},
+	}
+	for _, cfg := range configs {
+		if _, err := services.NewWorkerPools(cfg); !errors.Is(err, services.ErrInvalidPoolConfig) {
+			t.Errorf("配置 %+v 期望 ErrInvalidPoolConfig，实际 %v", cfg, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package recovery
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+	"concurrency-web-app/backend/services"
+)
+
+// panicTransport 请求路径为 /panic 时 panic，其余请求直接返回 200
+type panicTransport struct{}
+
+func (panicTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/panic" {
+		panic("transport exploded")
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok")), Header: http.Header{}}, nil
+}
+
+// 任务中的 panic 转换为该任务的失败结果，同一批次的其他任务照常完成
+func TestTaskPanicBecomesFailedResult(t *testing.T) {
+	for _, mode := range []batch.Mode{batch.ModePerTask, batch.ModeWorkerPool} {
+		s := &services.APICallService{
+			Mode:           mode,
+			MaxConcurrency: 2,
+			Timeouts:       services.APITimeouts{Request: time.Second, Batch: 5 * time.Second},
+			Client:         &http.Client{Transport: panicTransport{}},
+		}
+		tasks := []services.APICallTask{
+			{URL: "http://upstream.test/ok", Method: "GET"},
+			{URL: "http://upstream.test/panic", Method: "GET"},
+			{URL: "http://upstream.test/ok", Method: "GET"},
+		}
+
+		result := s.BatchCallAPIs(context.Background(), tasks)
+		if result.SuccessTasks != 2 || result.FailedTasks != 1 {
+			t.Fatalf("模式 %v: 期望2个成功1个失败，实际成功 %d 失败 %d", mode, result.SuccessTasks, result.FailedTasks)
+		}
+		for _, r := range result.Results {
+			if r.ID == 1 && (r.Status != services.TaskStatusFailed || !strings.Contains(r.Error, "transport exploded")) {
+				t.Errorf("模式 %v: panic 的任务结果不正确: status=%s error=%s", mode, r.Status, r.Error)
+			}
+		}
+	}
+}
+
+// 流水线阶段中的 panic 只让对应的输入失败
+func TestPipelineStagePanic(t *testing.T) {
+	p := services.NewPipeline(5*time.Second).
+		Stage("explode", 2, 0, func(ctx context.Context, item interface{}) ([]interface{}, error) {
+			if item == "boom" {
+				panic("stage exploded")
+			}
+			return []interface{}{item}, nil
+		})
+
+	result := p.Run(context.Background(), []interface{}{"a", "boom", "b"})
+	if result.SuccessTasks != 2 || result.FailedTasks != 1 {
+		t.Fatalf("期望2个成功1个失败，实际成功 %d 失败 %d", result.SuccessTasks, result.FailedTasks)
+	}
+	for _, r := range result.Results {
+		if r.ID == 1 && !strings.Contains(r.Error, "stage exploded") {
+			t.Errorf("panic 的输入错误信息不正确: %s", r.Error)
+		}
+	}
+}
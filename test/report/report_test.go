@@ -0,0 +1,38 @@
+package report
+
+import (
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// 耗时分布按最近秩法取百分位数，错误信息中的数字归并后分组，并与上一次任务对比
+func TestBuildJobReport(t *testing.T) {
+	var results []services.TaskResult
+	for i := 0; i < 10; i++ {
+		results = append(results, services.TaskResult{ID: i, Success: true, Status: services.TaskStatusSuccess, Duration: int64(i+1) * 100})
+	}
+	results = append(results,
+		services.TaskResult{ID: 10, Status: services.TaskStatusFailed, Error: "订单 7 库存不足", Duration: 20},
+		services.TaskResult{ID: 11, Status: services.TaskStatusFailed, Error: "订单 14 库存不足", Duration: 20},
+		services.TaskResult{ID: 12, Status: services.TaskStatusNotStarted, Error: "批次超时，任务未开始执行"},
+	)
+	result := &services.BatchResult{TotalTasks: 13, SuccessTasks: 10, FailedTasks: 2, NotStartedTasks: 1, Duration: 2000, Results: results}
+	previous := &models.BatchJobResult{JobID: "job_prev", TotalTasks: 10, SuccessTasks: 10, Duration: 1000}
+
+	report := services.BuildJobReport(services.JobInfo{ID: "job_1"}, result, []int{2, 4, 3}, previous)
+
+	if report.Latency.Count != 12 || report.Latency.P50 != 400 || report.Latency.P99 != 1000 || report.Latency.Max != 1000 {
+		t.Errorf("耗时分布 %+v", report.Latency)
+	}
+	if report.Summary.PeakConcurrency != 4 {
+		t.Errorf("并发峰值 %d，期望 4", report.Summary.PeakConcurrency)
+	}
+	if len(report.Errors) != 2 || report.Errors[0].Message != "订单 N 库存不足" || report.Errors[0].Count != 2 {
+		t.Errorf("错误分组 %+v", report.Errors)
+	}
+	if report.Previous == nil || report.Previous.DurationChange != 100 || report.Previous.SuccessRateDiff >= 0 {
+		t.Errorf("对比 %+v", report.Previous)
+	}
+}
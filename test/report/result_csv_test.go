@@ -0,0 +1,36 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 每个子任务一行，结果数据以 JSON 写入 data 列，含逗号和换行的错误信息按 CSV 规则转义
+func TestWriteResultCSV(t *testing.T) {
+	result := &services.BatchResult{Results: []services.TaskResult{
+		{ID: 0, Success: true, Status: services.TaskStatusSuccess, Data: map[string]interface{}{"order_id": 1}, Duration: 12},
+		{ID: 1, Status: services.TaskStatusFailed, Error: "库存不足,\n请稍后重试", ErrorCode: "ORDER_STOCK", Duration: 3},
+	}}
+	var buf bytes.Buffer
+	if err := services.WriteResultCSV(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(buf.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("解析 CSV 失败: %v", err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(services.ResultCSVHeader, ",") {
+		t.Fatalf("CSV 行 %q", rows)
+	}
+	if got := rows[1]; got[0] != "0" || got[1] != "success" || got[2] != "true" || got[3] != "12" || got[7] != `{"order_id":1}` {
+		t.Errorf("成功任务的行 %q", got)
+	}
+	if got := rows[2]; got[4] != "ORDER_STOCK" || got[6] != "库存不足,\n请稍后重试" || got[7] != "" {
+		t.Errorf("失败任务的行 %q", got)
+	}
+}
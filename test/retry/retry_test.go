@@ -0,0 +1,252 @@
+package retry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 等待时间按倍数增长并受上限约束
+func TestDelayGrowsExponentially(t *testing.T) {
+	p := &services.RetryPolicy{Backoff: 100 * time.Millisecond, Multiplier: 2, MaxBackoff: 300 * time.Millisecond}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, w := range want {
+		if got := p.Delay(i + 1); got != w {
+			t.Errorf("第 %d 次等待 %v，期望 %v", i+1, got, w)
+		}
+	}
+}
+
+// 抖动只会缩短等待时间，不会低于 (1-Jitter) 倍
+func TestDelayJitterBounds(t *testing.T) {
+	p := &services.RetryPolicy{Backoff: 100 * time.Millisecond, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		if d := p.Delay(1); d < 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("等待时间 %v 超出 [50ms, 100ms]", d)
+		}
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&services.HTTPStatusError{StatusCode: 503}, true},
+		{fmt.Errorf("请求失败: %w", &services.HTTPStatusError{StatusCode: 429}), true},
+		{fmt.Errorf("获取文件信息失败: %w", os.ErrNotExist), false},
+		{context.DeadlineExceeded, false},
+		{fmt.Errorf("订单库存不足"), false},
+	}
+	for _, c := range cases {
+		if got := services.RetryTransient(c.err); got != c.want {
+			t.Errorf("RetryTransient(%v) = %v，期望 %v", c.err, got, c.want)
+		}
+	}
+}
+
+func newAPIService() *services.APICallService {
+	return &services.APICallService{
+		MaxConcurrency: 1,
+		Timeouts:       services.APITimeouts{Request: time.Second, Batch: 10 * time.Second},
+		Retry: &services.RetryPolicy{
+			MaxAttempts: 3,
+			Backoff:     10 * time.Millisecond,
+			Multiplier:  2,
+			Retryable:   services.RetryTransient,
+		},
+	}
+}
+
+// 上游返回 503 时自动重试，尝试次数记录在结果中
+func TestRetriesServiceUnavailable(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	result := newAPIService().BatchCallAPIs(context.Background(), []services.APICallTask{{URL: server.URL, Method: "GET"}})
+	r := result.Results[0]
+	if !r.Success || len(r.Attempts) != 3 {
+		t.Fatalf("期望第3次成功，实际 success=%v attempts=%d error=%s", r.Success, len(r.Attempts), r.Error)
+	}
+	if r.Attempts[0].Backoff != 10 || r.Attempts[1].Backoff != 20 {
+		t.Fatalf("退避时间未按倍数增长: %d, %d", r.Attempts[0].Backoff, r.Attempts[1].Backoff)
+	}
+}
+
+// 不可重试的错误只尝试一次
+func TestNonTransientErrorNotRetried(t *testing.T) {
+	result := newAPIService().BatchCallAPIs(context.Background(), []services.APICallTask{{URL: "unknown://host", Method: "GET"}})
+	r := result.Results[0]
+	if r.Success || len(r.Attempts) != 1 {
+		t.Fatalf("期望失败且只尝试一次，实际 success=%v attempts=%d", r.Success, len(r.Attempts))
+	}
+}
+
+// 非幂等的请求收到响应后不重试，带幂等键时按暂时性错误重试，请求未发出（连接被拒绝）时总是重试
+func TestNonIdempotentRetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	cases := []struct {
+		name     string
+		task     services.APICallTask
+		attempts int
+	}{
+		{"POST 收到 503", services.APICallTask{URL: server.URL, Method: "POST", Body: "{}"}, 1},
+		{"PUT 收到 503", services.APICallTask{URL: server.URL, Method: "PUT"}, 1},
+		{"POST 带幂等键", services.APICallTask{URL: server.URL, Method: "POST", Headers: map[string]string{"idempotency-key": "order-1"}}, 3},
+		{"DELETE 收到 503", services.APICallTask{URL: server.URL, Method: "DELETE"}, 1},
+		{"GET 收到 503", services.APICallTask{URL: server.URL, Method: "GET"}, 3},
+		{"POST 连接被拒绝", services.APICallTask{URL: closed.URL, Method: "POST"}, 3},
+	}
+	for _, c := range cases {
+		r := newAPIService().BatchCallAPIs(context.Background(), []services.APICallTask{c.task}).Results[0]
+		if r.Success || len(r.Attempts) != c.attempts {
+			t.Errorf("%s: 期望失败且尝试 %d 次，实际 success=%v attempts=%d error=%s", c.name, c.attempts, r.Success, len(r.Attempts), r.Error)
+		}
+	}
+}
+
+// backoffs 以 seed 执行一个始终返回 503 的调用，返回各次重试前的等待时间
+func backoffs(t *testing.T, url string, seed int64) []int64 {
+	service := newAPIService()
+	service.Retry.Backoff = 100 * time.Millisecond
+	service.Retry.Jitter = 1
+
+	job, ctx := services.NewJobManager(nil).Start(context.Background(), "api", "", 1)
+	job.SetRun(services.NewRunRecord(seed, nil))
+	result := service.BatchCallAPIs(ctx, []services.APICallTask{{URL: url, Method: "GET"}})
+
+	var delays []int64
+	for _, attempt := range result.Results[0].Attempts {
+		if attempt.Backoff > 0 {
+			delays = append(delays, attempt.Backoff)
+		}
+	}
+	if len(delays) != 2 {
+		t.Fatalf("重试等待 %v，期望2次", delays)
+	}
+	return delays
+}
+
+// 相同的批次种子得到相同的重试等待时间
+func TestSeedReproducesBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	first, second := backoffs(t, server.URL, 42), backoffs(t, server.URL, 42)
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("相同种子的等待时间不同: %v 和 %v", first, second)
+	}
+	if record := services.NewRunRecord(0, nil); record.Seed == 0 || record.CodeVersion == "" {
+		t.Errorf("未指定种子时应生成种子并记录代码版本: %+v", record)
+	}
+}
+
+// 下游整体不可用时，整个批次的重试次数不超过预算，预算用完后失败的任务直接失败
+func TestRetryBudget(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	s := newAPIService()
+	s.Retry.Budget = 0.1
+	tasks := make([]services.APICallTask, 20)
+	for i := range tasks {
+		tasks[i] = services.APICallTask{ID: i, URL: server.URL, Method: "GET"}
+	}
+	result := s.BatchCallAPIs(context.Background(), tasks)
+
+	// 20个任务各执行一次，预算允许的2次重试
+	if n := atomic.LoadInt32(&calls); n != 22 {
+		t.Errorf("上游收到 %d 次请求，期望 22 次", n)
+	}
+	exhausted := 0
+	for _, r := range result.Results {
+		if strings.Contains(r.Error, services.ErrRetryBudgetExhausted.Error()) {
+			exhausted++
+		}
+	}
+	if result.FailedTasks != 20 || exhausted != 19 {
+		t.Errorf("失败 %d 个，因预算用完未重试 %d 个，期望 20 和 19", result.FailedTasks, exhausted)
+	}
+}
+
+// flakyTask 前 failures 次返回 err，之后成功
+type flakyTask struct {
+	err      error
+	failures int32
+	calls    atomic.Int32
+}
+
+func (t *flakyTask) Execute(ctx context.Context) (interface{}, error) {
+	if t.calls.Add(1) <= t.failures {
+		return nil, t.err
+	}
+	return "ok", nil
+}
+
+type flakyKind struct{}
+
+func (flakyKind) Name() string { return "flaky" }
+
+func (flakyKind) Decode(raw json.RawMessage) (services.Task, error) { return nil, nil }
+
+// 业务错误不重试，基础设施错误即使 Retryable 判断为不重试也会重试；批次按分类分别计数
+func TestBusinessAndInfraErrors(t *testing.T) {
+	business := &flakyTask{err: services.NewBusinessError("out_of_stock", errors.New("库存不足")), failures: 5}
+	infra := &flakyTask{err: services.NewInfraError(errors.New("连接池耗尽")), failures: 1}
+	down := &flakyTask{err: services.NewInfraError(errors.New("上游不可用")), failures: 5}
+	service := &services.KindService{
+		Kind:           flakyKind{},
+		MaxConcurrency: 3,
+		Timeout:        5 * time.Second,
+		Retry:          &services.RetryPolicy{MaxAttempts: 3, Retryable: func(error) bool { return false }},
+	}
+
+	result := service.BatchProcess(context.Background(), []services.Task{business, infra, down})
+	if business.calls.Load() != 1 || infra.calls.Load() != 2 || down.calls.Load() != 3 {
+		t.Errorf("尝试次数不正确: business=%d infra=%d down=%d", business.calls.Load(), infra.calls.Load(), down.calls.Load())
+	}
+	if result.SuccessTasks != 1 || result.FailedTasks != 2 || result.BusinessErrorTasks != 1 || result.InfraErrorTasks != 1 {
+		t.Fatalf("计数不正确: %+v", result)
+	}
+	for _, r := range result.Results {
+		switch r.ID {
+		case 0:
+			if r.ErrorClass != services.ErrorClassBusiness || r.ErrorCode != "out_of_stock" {
+				t.Errorf("业务错误的分类不正确: %+v", r)
+			}
+		case 2:
+			if r.ErrorClass != services.ErrorClassInfra || r.ErrorCode != services.ErrCodeInfrastructure {
+				t.Errorf("基础设施错误的分类不正确: %+v", r)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package sampling
+
+import (
+	"reflect"
+	"testing"
+
+	"concurrency-web-app/backend/services"
+)
+
+func numbers(n int) []int {
+	tasks := make([]int, n)
+	for i := range tasks {
+		tasks[i] = i
+	}
+	return tasks
+}
+
+// 相同种子抽中相同的任务，抽样数量接近比例
+func TestSampleTasksDeterministic(t *testing.T) {
+	tasks := numbers(10000)
+	a, sa := services.SampleTasks(tasks, 0.1, 7)
+	b, _ := services.SampleTasks(tasks, 0.1, 7)
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("相同种子的抽样结果不一致")
+	}
+	if len(a) < 800 || len(a) > 1200 {
+		t.Fatalf("10%% 抽样得到 %d 个任务", len(a))
+	}
+	if sa.Seed != 7 || sa.Total != 10000 {
+		t.Fatalf("抽样信息不正确: %+v", sa)
+	}
+}
+
+// 比例为0或1时不抽样
+func TestSampleTasksDisabled(t *testing.T) {
+	tasks := numbers(10)
+	for _, rate := range []float64{0, 1} {
+		got, sample := services.SampleTasks(tasks, rate, 1)
+		if sample != nil || len(got) != len(tasks) {
+			t.Fatalf("rate=%v 时不应抽样", rate)
+		}
+	}
+}
+
+// 结果序号还原为原批次中的序号，计数按抽样比例外推
+func TestSampleApplyExtrapolates(t *testing.T) {
+	tasks := numbers(1000)
+	sampled, sample := services.SampleTasks(tasks, 0.1, 3)
+
+	result := &services.BatchResult{TotalTasks: len(sampled), SuccessTasks: len(sampled), Duration: 100}
+	for i := range sampled {
+		result.Results = append(result.Results, services.TaskResult{ID: i, Status: services.TaskStatusSuccess, Success: true})
+	}
+	sample.Apply(result)
+
+	for i, r := range result.Results {
+		if r.ID != sampled[i] {
+			t.Fatalf("第 %d 个结果的序号应还原为 %d，实际 %d", i, sampled[i], r.ID)
+		}
+	}
+	if result.Sample.EstimatedSuccess != 1000 || result.Sample.SampledTasks != len(sampled) {
+		t.Fatalf("估算不正确: %+v", result.Sample)
+	}
+}
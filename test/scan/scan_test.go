@@ -0,0 +1,125 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// 并发扫描上传的文件：命中特征的文件被隔离且不能处理，其余文件标记为 clean
+func TestScanUploads(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte("EICAR")) {
+			w.Write([]byte(`{"infected":true,"signature":"Eicar-Test-Signature"}`))
+			return
+		}
+		w.Write([]byte(`{"infected":false}`))
+	}))
+	defer api.Close()
+
+	dir := t.TempDir()
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(dir, "scan.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := &services.FileMetadataService{DB: db, Scanner: &services.UploadScanner{
+		Engine:        &services.HTTPScanner{URL: api.URL},
+		QuarantineDir: filepath.Join(dir, "quarantine"),
+	}}
+
+	var records []*models.FileTask
+	for name, content := range map[string]string{"clean.txt": "hello", "virus.txt": "X5O!P%@AP EICAR"} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		record := &models.FileTask{FileName: name, OriginalName: name, FilePath: path, ScanStatus: services.ScanStatusPending}
+		if err := files.RecordUpload(record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if err := files.ScanUploads(context.Background(), records); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, record := range records {
+		saved, err := files.Get(record.ID, record.Owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch saved.OriginalName {
+		case "clean.txt":
+			if saved.ScanStatus != services.ScanStatusClean || services.CheckScanned(saved) != nil {
+				t.Errorf("未命中的文件应可处理: %+v", saved)
+			}
+		case "virus.txt":
+			if saved.ScanStatus != services.ScanStatusInfected || saved.ScanSignature != "Eicar-Test-Signature" ||
+				saved.Status != services.FileStatusQuarantined || !strings.HasPrefix(saved.FilePath, filepath.Join(dir, "quarantine")) {
+				t.Errorf("命中的文件应被隔离: %+v", saved)
+			}
+			if _, err := os.Stat(saved.FilePath); err != nil {
+				t.Errorf("隔离目录中应有该文件: %v", err)
+			}
+			if err := services.CheckScanned(saved); !errors.Is(err, services.ErrFileNotScanned) {
+				t.Errorf("被隔离的文件不应可处理: %v", err)
+			}
+		}
+	}
+}
+
+// 按 INSTREAM 协议发送内容，并解析 clamd 的回复
+func TestClamdScanner(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			command := make([]byte, len("zINSTREAM\x00"))
+			io.ReadFull(conn, command)
+			var content []byte
+			for {
+				var size uint32
+				if binary.Read(conn, binary.BigEndian, &size) != nil || size == 0 {
+					break
+				}
+				chunk := make([]byte, size)
+				io.ReadFull(conn, chunk)
+				content = append(content, chunk...)
+			}
+			if bytes.Contains(content, []byte("EICAR")) {
+				conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+			} else {
+				conn.Write([]byte("stream: OK\x00"))
+			}
+			conn.Close()
+		}
+	}()
+
+	scanner := &services.ClamdScanner{Network: "tcp", Address: listener.Addr().String()}
+	verdict, err := scanner.Scan(context.Background(), "a.txt", strings.NewReader("EICAR"))
+	if err != nil || !verdict.Infected || verdict.Signature != "Eicar-Test-Signature" {
+		t.Errorf("应命中特征: %+v, %v", verdict, err)
+	}
+	verdict, err = scanner.Scan(context.Background(), "b.txt", strings.NewReader("hello"))
+	if err != nil || verdict.Infected {
+		t.Errorf("不应命中特征: %+v, %v", verdict, err)
+	}
+}
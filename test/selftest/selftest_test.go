@@ -0,0 +1,84 @@
+package selftest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+func newSelfTest(t *testing.T) *services.SelfTest {
+	dir := t.TempDir()
+	db, readDB, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(dir, "selftest.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &services.SelfTest{
+		DB:     db,
+		ReadDB: readDB,
+		Dirs:   []string{filepath.Join(dir, "artifacts"), filepath.Join(dir, "archive")},
+		Orders: &services.OrderProcessService{MaxConcurrency: 2, Timeout: 10 * time.Second},
+		APIs: &services.APICallService{MaxConcurrency: 2, Timeouts: services.APITimeouts{
+			Connect: time.Second, Request: 5 * time.Second, Task: 5 * time.Second, Batch: 10 * time.Second,
+		}},
+		Files: &services.FileProcessService{MaxConcurrency: 2, Timeout: 10 * time.Second, UploadDir: filepath.Join(dir, "uploads")},
+	}
+}
+
+// 全部检查通过，数据库中不留测试记录，目录中不留临时文件
+func TestSelfTestPasses(t *testing.T) {
+	selfTest := newSelfTest(t)
+	report := selfTest.Run(context.Background())
+	if !report.OK {
+		t.Fatalf("自检失败: %+v", report.Checks)
+	}
+	names := map[string]bool{}
+	for _, check := range report.Checks {
+		names[check.Name] = true
+	}
+	for _, name := range []string{"database", "orders", "api_calls", "files", "storage:" + selfTest.Dirs[0]} {
+		if !names[name] {
+			t.Errorf("缺少检查 %s: %+v", name, report.Checks)
+		}
+	}
+
+	var count int64
+	selfTest.DB.Model(&models.BatchJobResult{}).Count(&count)
+	if count != 0 {
+		t.Errorf("数据库检查应回滚，实际留下 %d 条记录", count)
+	}
+	for _, dir := range append(selfTest.Dirs, selfTest.Files.UploadDir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) != 0 {
+			t.Errorf("目录 %s 中留下 %d 个文件，err=%v", dir, len(entries), err)
+		}
+	}
+}
+
+// 存储目录不可写时自检失败，其余检查照常执行
+func TestSelfTestFailsOnUnwritableDir(t *testing.T) {
+	selfTest := newSelfTest(t)
+	// 以文件占据目录的位置，无论是否以 root 运行都无法在其中创建文件
+	blocked := filepath.Join(t.TempDir(), "blocked")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	selfTest.Dirs = []string{blocked}
+
+	report := selfTest.Run(context.Background())
+	if report.OK {
+		t.Fatal("存储目录不可写时自检应失败")
+	}
+	for _, check := range report.Checks {
+		if check.Name == "storage:"+blocked && (check.OK || check.Error == "") {
+			t.Errorf("存储检查应失败并给出原因: %+v", check)
+		}
+		if check.Name == "orders" && !check.OK {
+			t.Errorf("订单检查不应受影响: %+v", check)
+		}
+	}
+}
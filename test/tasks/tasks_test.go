@@ -0,0 +1,128 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// upperTask 将文本转为大写，文本为空时失败
+type upperTask struct {
+	Text     string `json:"text"`
+	Priority int    `json:"priority"`
+}
+
+func (t upperTask) Execute(ctx context.Context) (interface{}, error) {
+	if t.Text == "" {
+		return nil, errors.New("文本为空")
+	}
+	return strings.ToUpper(t.Text), nil
+}
+
+type upperKind struct{}
+
+func (upperKind) Name() string { return "upper" }
+
+func (upperKind) Decode(raw json.RawMessage) (services.Task, error) {
+	var task upperTask
+	if err := json.Unmarshal(raw, &task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func newService() *services.KindService {
+	return &services.KindService{Kind: upperKind{}, MaxConcurrency: 2, Timeout: 5 * time.Second}
+}
+
+// 注册的任务类型与内置服务一样执行并汇总结果
+func TestKindServiceBatchProcess(t *testing.T) {
+	service := newService()
+	tasks, err := service.Decode([]json.RawMessage{
+		json.RawMessage(`{"text":"a"}`), json.RawMessage(`{"text":""}`), json.RawMessage(`{"text":"c"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := service.BatchProcess(context.Background(), tasks)
+	if result.TotalTasks != 3 || result.SuccessTasks != 2 || result.FailedTasks != 1 {
+		t.Fatalf("汇总不正确: %+v", result)
+	}
+	if result.Results[0].Data != "A" || result.Results[1].Error != "文本为空" {
+		t.Errorf("结果不正确: %+v", result.Results)
+	}
+}
+
+// 执行过的任务记录所在的实例、路由到的工作池、工作槽位和最后一次尝试的序号
+func TestKindServiceTaskEnv(t *testing.T) {
+	pools, err := services.NewWorkerPools(services.PoolConfig{
+		Pools: map[string]int{"text": 1},
+		Rules: []services.PoolRule{{TaskType: "upper", Pool: "text"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := newService()
+	service.Pools = pools
+	service.Retry = &services.RetryPolicy{MaxAttempts: 3}
+	tasks, err := service.Decode([]json.RawMessage{json.RawMessage(`{"text":"a"}`), json.RawMessage(`{"text":""}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := service.BatchProcess(context.Background(), tasks)
+	for i, attempt := range []int{1, 3} {
+		env := result.Results[i].Env
+		if env == nil || env.Instance == "" || env.Instance != services.InstanceID || env.Pool != "text" || env.Attempt != attempt {
+			t.Errorf("任务 %d 的执行环境 %+v，期望第 %d 次尝试", i, env, attempt)
+		}
+		if env != nil && (env.Worker < 0 || env.Worker >= service.MaxConcurrency) {
+			t.Errorf("任务 %d 的工作槽位 %d 超出范围", i, env.Worker)
+		}
+	}
+}
+
+// 解析失败时错误中注明任务序号
+func TestKindServiceDecodeError(t *testing.T) {
+	_, err := newService().Decode([]json.RawMessage{json.RawMessage(`{"text":"a"}`), json.RawMessage(`[]`)})
+	if err == nil || !strings.HasPrefix(err.Error(), "任务 1:") {
+		t.Fatalf("期望任务 1 解析失败，实际 %v", err)
+	}
+}
+
+// 名称不合法、与内置类型或已注册的类型重名时拒绝注册
+func TestTaskRegistry(t *testing.T) {
+	registry := &services.TaskRegistry{}
+	if err := registry.Register(newService()); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(newService()); !errors.Is(err, services.ErrTaskKindExists) {
+		t.Errorf("重复注册期望 ErrTaskKindExists，实际 %v", err)
+	}
+	for _, name := range []string{"order", "Bad Name"} {
+		err := registry.Register(&services.KindService{Kind: namedKind(name), MaxConcurrency: 1, Timeout: time.Second})
+		if err == nil {
+			t.Errorf("名称 %q 不应注册成功", name)
+		}
+	}
+
+	if _, err := registry.Get("missing"); !errors.Is(err, services.ErrTaskKindNotFound) {
+		t.Errorf("期望 ErrTaskKindNotFound，实际 %v", err)
+	}
+	if names := registry.Names(); fmt.Sprint(names) != "[upper]" {
+		t.Errorf("已注册的类型不正确: %v", names)
+	}
+}
+
+type namedKind string
+
+func (k namedKind) Name() string { return string(k) }
+
+func (namedKind) Decode(json.RawMessage) (services.Task, error) { return nil, nil }
@@ -0,0 +1,203 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// 模板执行的批次按模板的保留天数清理：明细先过期，任务记录过期时连同剩余明细一起删除；不是由模板执行的批次不受影响
+func TestRetentionJanitor(t *testing.T) {
+	dir := t.TempDir()
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(dir, "retention.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &models.BatchTemplate{Owner: "alice", Name: "nightly", Target: "orders", Request: orders, SummaryDays: 365, DetailDays: 7}
+	if err := (&services.TemplateService{DB: db}).Save(template); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts := &services.ArtifactService{DB: db, Dir: filepath.Join(dir, "artifacts"), Cold: &services.LocalArchiveStorage{Dir: filepath.Join(dir, "archive")}}
+	details := filepath.Join(dir, "results")
+	janitor := &services.RetentionJanitor{DB: db, Artifacts: artifacts, DetailDirs: []string{details}}
+
+	now := time.Now()
+	jobs := []struct {
+		id       string
+		template uint
+		age      time.Duration
+	}{
+		{"recent", template.ID, time.Hour},
+		{"week_old", template.ID, 10 * 24 * time.Hour},
+		{"year_old", template.ID, 400 * 24 * time.Hour},
+		{"adhoc", 0, 400 * 24 * time.Hour},
+	}
+	for _, job := range jobs {
+		end := now.Add(-job.age)
+		info := services.JobInfo{ID: job.id, Type: "order", Owner: "alice", Status: services.JobStatusCompleted, TemplateID: job.template}
+		if err := db.Create(&models.BatchJobResult{JobID: job.id, JobType: "order", Owner: "alice", Status: info.Status, EndTime: &end, TemplateID: job.template}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if _, err := artifacts.SaveJobResult(info, &services.BatchResult{}); err != nil {
+			t.Fatal(err)
+		}
+		db.Model(&models.JobArtifact{}).Where("job_id = ?", job.id).Update("created_at", end)
+		os.MkdirAll(filepath.Join(details, job.id), 0755)
+		db.Create(&models.Order{JobID: job.id, CustomerID: "CUST_0001", ProductName: "p", Quantity: 1, Price: 1})
+	}
+
+	report, err := janitor.Sweep(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Templates != 1 || report.Details != 2 || report.Summaries != 1 {
+		t.Errorf("清理结果 %+v", report)
+	}
+
+	for _, c := range []struct {
+		id                string
+		summary, artifact bool
+	}{
+		{"recent", true, true},
+		{"week_old", true, false},
+		{"year_old", false, false},
+		{"adhoc", true, true},
+	} {
+		var summaries, artifactRows, orderRows int64
+		db.Model(&models.BatchJobResult{}).Where("job_id = ?", c.id).Count(&summaries)
+		db.Model(&models.JobArtifact{}).Where("job_id = ?", c.id).Count(&artifactRows)
+		db.Model(&models.Order{}).Where("job_id = ?", c.id).Count(&orderRows)
+		_, statErr := os.Stat(filepath.Join(details, c.id))
+		if (summaries == 1) != c.summary || (orderRows == 1) != c.summary || (artifactRows == 1) != c.artifact || (statErr == nil) != c.artifact {
+			t.Errorf("%s: 任务记录 %d，订单记录 %d，产出物 %d，明细目录 %v", c.id, summaries, orderRows, artifactRows, statErr)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(artifacts.Dir, "week_old.json")); !os.IsNotExist(err) {
+		t.Errorf("过期的产出物文件应已删除: %v", err)
+	}
+}
+
+// 明细的保留天数不能超过任务记录的保留天数
+func TestRetentionValidation(t *testing.T) {
+	s := newService(t)
+	err := s.Save(&models.BatchTemplate{Owner: "alice", Name: "x", Target: "orders", Request: orders, SummaryDays: 7, DetailDays: 30})
+	if !errors.Is(err, services.ErrInvalidTemplate) {
+		t.Errorf("期望 ErrInvalidTemplate，实际 %v", err)
+	}
+	if err := s.Save(&models.BatchTemplate{Owner: "alice", Name: "y", Target: "orders", Request: orders, DetailDays: 30}); err != nil {
+		t.Errorf("只设置明细的保留天数: %v", err)
+	}
+}
+
+// 结果的保留时长：任务结束时记录过期时间（模板设置了保留策略时按模板），过期后从内存中移除并删除明细，任务记录保留
+func TestResultTTL(t *testing.T) {
+	dir := t.TempDir()
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(dir, "ttl.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &models.BatchTemplate{Owner: "alice", Name: "weekly", Target: "orders", Request: orders, SummaryDays: 30, DetailDays: 7}
+	if err := (&services.TemplateService{DB: db}).Save(template); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs := services.NewJobManager(&services.DBProgressStore{DB: db})
+	jobs.ResultTTL = time.Hour
+	// 只检查按过期时间移除，不按结束后的保留时长移除内存中的任务
+	jobs.FinishedTTL = 0
+	artifacts := &services.ArtifactService{DB: db, Dir: filepath.Join(dir, "artifacts"), Cold: &services.LocalArchiveStorage{Dir: filepath.Join(dir, "archive")}}
+	janitor := &services.RetentionJanitor{DB: db, Artifacts: artifacts, Jobs: jobs}
+
+	adhoc, _ := jobs.Start(context.Background(), "order", "alice", 1)
+	templated, _ := jobs.Start(context.Background(), "order", "alice", 1)
+	templated.SetTemplate(template.ID, services.DetailRetention(*template))
+	for _, job := range []*services.Job{adhoc, templated} {
+		jobs.Finish(job, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+		if _, err := artifacts.SaveJobResult(job.Info(), &services.BatchResult{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info := adhoc.Info()
+	if info.ExpiresAt == nil || !info.ExpiresAt.Equal(info.EndTime.Add(time.Hour)) {
+		t.Fatalf("过期时间 %v，结束时间 %v", info.ExpiresAt, info.EndTime)
+	}
+	if expires := templated.Info().ExpiresAt; expires == nil || expires.Sub(*templated.Info().EndTime) != 7*24*time.Hour {
+		t.Fatalf("模板批次的过期时间 %v", expires)
+	}
+	var saved models.BatchJobResult
+	db.Where("job_id = ?", adhoc.ID()).First(&saved)
+	if saved.ExpiresAt == nil {
+		t.Error("任务记录中没有过期时间")
+	}
+
+	report, err := janitor.Sweep(time.Now().Add(2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Expired != 1 || report.Evicted != 1 {
+		t.Errorf("清理结果 %+v", report)
+	}
+	if _, ok := jobs.Get(adhoc.ID()); ok {
+		t.Error("过期的任务应已从内存中移除")
+	}
+	if _, ok := jobs.Get(templated.ID()); !ok {
+		t.Error("模板批次尚未过期")
+	}
+	if _, _, err := artifacts.LoadJobResult(adhoc.ID(), "alice"); !errors.Is(err, services.ErrJobOutputNotFound) {
+		t.Errorf("过期的结果应已删除: %v", err)
+	}
+	var summaries int64
+	db.Model(&models.BatchJobResult{}).Where("job_id = ?", adhoc.ID()).Count(&summaries)
+	if summaries != 1 {
+		t.Error("任务记录应保留")
+	}
+}
+
+// 默认配置（未设置 RESULT_TTL）下结束的任务同样从内存中移除：结束超过 FinishedTTL 的任务在保留清理时移除，
+// 超出 MaxFinished 时任务结束即移除最早结束的任务；执行中的任务不受影响
+func TestFinishedJobsEvictedByDefault(t *testing.T) {
+	jobs := services.NewJobManager(nil)
+	if jobs.ResultTTL != 0 || jobs.FinishedTTL != services.DefaultFinishedTTL || jobs.MaxFinished != services.DefaultMaxFinished {
+		t.Fatalf("默认配置 ResultTTL=%v FinishedTTL=%v MaxFinished=%d", jobs.ResultTTL, jobs.FinishedTTL, jobs.MaxFinished)
+	}
+	finished, _ := jobs.Start(context.Background(), "order", "alice", 1)
+	running, _ := jobs.Start(context.Background(), "order", "alice", 1)
+	jobs.Finish(finished, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+
+	if n := jobs.EvictExpired(time.Now().Add(services.DefaultFinishedTTL / 2)); n != 0 {
+		t.Fatalf("未超过保留时长的任务不应移除，移除了 %d 个", n)
+	}
+	if n := jobs.EvictExpired(time.Now().Add(services.DefaultFinishedTTL + time.Minute)); n != 1 {
+		t.Fatalf("结束超过保留时长的任务应从内存中移除，移除了 %d 个", n)
+	}
+	if _, ok := jobs.Get(finished.ID()); ok {
+		t.Fatal("结束超过保留时长的任务仍在内存中")
+	}
+	if _, ok := jobs.Get(running.ID()); !ok {
+		t.Fatal("执行中的任务不应移除")
+	}
+
+	jobs.MaxFinished = 2
+	var ids []string
+	for i := 0; i < 3; i++ {
+		job, _ := jobs.Start(context.Background(), "order", "alice", 1)
+		jobs.Finish(job, &services.BatchResult{TotalTasks: 1, SuccessTasks: 1})
+		ids = append(ids, job.ID())
+	}
+	if _, ok := jobs.Get(ids[0]); ok {
+		t.Error("超出上限时最早结束的任务应移除")
+	}
+	for _, id := range ids[1:] {
+		if _, ok := jobs.Get(id); !ok {
+			t.Errorf("任务 %s 不应移除", id)
+		}
+	}
+}
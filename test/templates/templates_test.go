@@ -0,0 +1,86 @@
+package templates
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+func newService(t *testing.T) *services.TemplateService {
+	t.Helper()
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "templates.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &services.TemplateService{DB: db}
+}
+
+const orders = `{"orders": [{"customer_id": "CUST_0001", "product_name": "p", "quantity": 1, "price": 10}], "fail_fast": true}`
+
+// 模板按用户隔离，同一用户的名称不能重复，其他用户看不到也改不了
+func TestTemplateCRUD(t *testing.T) {
+	s := newService(t)
+	template := &models.BatchTemplate{Owner: "alice", Name: " nightly ", Target: "orders", Request: orders}
+	if err := s.Save(template); err != nil {
+		t.Fatal(err)
+	}
+	if template.ID == 0 || template.Name != "nightly" {
+		t.Fatalf("创建的模板 %+v", template)
+	}
+	if err := s.Save(&models.BatchTemplate{Owner: "alice", Name: "nightly", Target: "orders", Request: orders}); !errors.Is(err, services.ErrTemplateExists) {
+		t.Errorf("重名时期望 ErrTemplateExists，实际 %v", err)
+	}
+	if err := s.Save(&models.BatchTemplate{Owner: "bob", Name: "nightly", Target: "orders", Request: orders}); err != nil {
+		t.Errorf("不同用户可以使用相同的名称: %v", err)
+	}
+
+	if _, err := s.Get(template.ID, "bob"); !errors.Is(err, services.ErrTemplateNotFound) {
+		t.Errorf("其他用户的模板应返回 ErrTemplateNotFound，实际 %v", err)
+	}
+	if err := s.Save(&models.BatchTemplate{ID: template.ID, Owner: "bob", Name: "x", Target: "orders", Request: orders}); !errors.Is(err, services.ErrTemplateNotFound) {
+		t.Errorf("不能更新其他用户的模板，实际 %v", err)
+	}
+
+	update := &models.BatchTemplate{ID: template.ID, Owner: "alice", Name: "hourly", Target: "pipeline", Request: `{"items": ["a"], "stages": []}`}
+	if err := s.Save(update); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(template.ID, "alice")
+	if err != nil || got.Name != "hourly" || got.Target != "pipeline" || got.Request != update.Request {
+		t.Fatalf("更新后的模板 %+v %v", got, err)
+	}
+	if list, _ := s.List("alice"); len(list) != 1 {
+		t.Errorf("alice 应有 1 个模板，实际 %d", len(list))
+	}
+
+	if err := s.Delete(template.ID, "bob"); !errors.Is(err, services.ErrTemplateNotFound) {
+		t.Errorf("不能删除其他用户的模板，实际 %v", err)
+	}
+	if err := s.Delete(template.ID, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(template.ID, "alice"); !errors.Is(err, services.ErrTemplateNotFound) {
+		t.Errorf("删除后应返回 ErrTemplateNotFound，实际 %v", err)
+	}
+}
+
+// 请求体须为 JSON 对象，且包含 target 对应的非空任务列表
+func TestTemplateRequestValidation(t *testing.T) {
+	for _, tc := range []struct{ target, request string }{
+		{"orders", `[]`},
+		{"orders", `{"apis": [{}]}`},
+		{"orders", `{"orders": []}`},
+		{"pipeline", `{"orders": [{}]}`},
+		{"reports", orders},
+	} {
+		if err := services.CheckTemplateRequest(tc.target, []byte(tc.request)); !errors.Is(err, services.ErrInvalidTemplate) {
+			t.Errorf("%s %s: 期望 ErrInvalidTemplate，实际 %v", tc.target, tc.request, err)
+		}
+	}
+	if err := services.CheckTemplateRequest("orders", []byte(orders)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,90 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// call 在 host 上完成一次调用，返回引起的并发调整
+func call(t *testing.T, th *services.HostThrottle, host string, err error) *services.ThrottleDecision {
+	t.Helper()
+	done, acquireErr := th.Acquire(context.Background(), host)
+	if acquireErr != nil {
+		t.Fatalf("Acquire 失败: %v", acquireErr)
+	}
+	return done(err)
+}
+
+// 主机持续返回 503 时并发减半，恢复后逐步加回上限，其他主机不受影响
+func TestHostThrottleDegradesAndRecovers(t *testing.T) {
+	th := &services.HostThrottle{MaxConcurrency: 4, MinSamples: 4}
+	unavailable := &services.HTTPStatusError{StatusCode: 503}
+
+	var decisions []*services.ThrottleDecision
+	for i := 0; i < 8; i++ {
+		if d := call(t, th, "a.example", unavailable); d != nil {
+			decisions = append(decisions, d)
+		}
+		call(t, th, "b.example", nil)
+	}
+	if len(decisions) != 2 || decisions[0].Limit != 2 || decisions[1].Limit != 1 {
+		t.Fatalf("期望并发 4→2→1，实际 %+v", decisions)
+	}
+	if decisions[0].Reason != "degraded" || decisions[0].FailureRate != 1 {
+		t.Errorf("调整内容不正确: %+v", decisions[0])
+	}
+	if limits := th.Limits(); len(limits) != 1 || limits["a.example"] != 1 {
+		t.Errorf("期望只有 a.example 被限制为1，实际 %v", limits)
+	}
+
+	for i := 0; i < 12; i++ {
+		call(t, th, "a.example", nil)
+	}
+	if limits := th.Limits(); len(limits) != 0 {
+		t.Errorf("恢复后仍有主机被限制: %v", limits)
+	}
+}
+
+// 非暂时性错误不计入失败率
+func TestHostThrottleIgnoresPermanentErrors(t *testing.T) {
+	th := &services.HostThrottle{MaxConcurrency: 4, MinSamples: 4}
+	for i := 0; i < 8; i++ {
+		if d := call(t, th, "a.example", errors.New("参数错误")); d != nil {
+			t.Fatalf("非暂时性错误引起了并发调整: %+v", d)
+		}
+	}
+}
+
+// 达到并发上限后 Acquire 等待，ctx 结束时返回其错误
+func TestHostThrottleBlocksAtLimit(t *testing.T) {
+	th := &services.HostThrottle{MaxConcurrency: 1}
+	done, err := th.Acquire(context.Background(), "a.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := th.Acquire(ctx, "a.example"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望等待到超时，实际 %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		next, err := th.Acquire(context.Background(), "a.example")
+		if err == nil {
+			next(nil)
+		}
+		close(acquired)
+	}()
+	done(nil)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("释放后等待的调用没有继续")
+	}
+}
@@ -0,0 +1,62 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/services"
+)
+
+// 请求覆盖的并发数和批次超时只作用于该批次，未覆盖的批次仍按服务的配置执行
+func TestRunLimitsOverrideService(t *testing.T) {
+	s := &services.OrderProcessService{MaxConcurrency: 5, Timeout: 100 * time.Millisecond}
+	var orders []services.OrderTask
+	for id := 1; id <= 3; id++ {
+		orders = append(orders, services.OrderTask{ID: id, CustomerID: "c", ProductName: "p", Quantity: 1, Price: 1})
+	}
+
+	jobs := services.NewJobManager(nil)
+	job, ctx := jobs.Start(context.Background(), "order", "", len(orders))
+	job.SetLimits(services.RunLimits{MaxConcurrency: 1, Timeout: 5 * time.Second})
+	start := time.Now()
+	result := s.BatchProcessOrders(ctx, orders)
+	// 每个订单 110-130ms，按服务的批次超时会全部超时；并发数为1时依次执行
+	if result.SuccessTasks != len(orders) {
+		t.Fatalf("覆盖批次超时后应全部成功，实际成功 %d 个", result.SuccessTasks)
+	}
+	if elapsed := time.Since(start); elapsed < 330*time.Millisecond {
+		t.Errorf("并发数为1时应依次执行，实际耗时 %v", elapsed)
+	}
+
+	other, ctx := jobs.Start(context.Background(), "order", "", len(orders))
+	result = s.BatchProcessOrders(ctx, orders)
+	if result.SuccessTasks != 0 {
+		t.Errorf("未覆盖的批次应按服务的批次超时结束，实际成功 %d 个", result.SuccessTasks)
+	}
+	jobs.Finish(job, result)
+	jobs.Finish(other, result)
+}
+
+// 超出服务端的上限时返回 ErrLimitExceeded，上限为0时不允许覆盖
+func TestRunLimitsCheck(t *testing.T) {
+	ceiling := services.RunLimits{MaxConcurrency: 50, Timeout: time.Minute}
+	cases := []struct {
+		limits services.RunLimits
+		ok     bool
+	}{
+		{services.RunLimits{}, true},
+		{services.RunLimits{MaxConcurrency: 50, Timeout: time.Minute}, true},
+		{services.RunLimits{MaxConcurrency: 51}, false},
+		{services.RunLimits{Timeout: 2 * time.Minute}, false},
+	}
+	for _, c := range cases {
+		if err := c.limits.Check(ceiling); (err == nil) != c.ok || (err != nil && !errors.Is(err, services.ErrLimitExceeded)) {
+			t.Errorf("%+v: %v", c.limits, err)
+		}
+	}
+	if err := (services.RunLimits{MaxConcurrency: 1}).Check(services.RunLimits{}); err == nil {
+		t.Error("上限为0时不应允许覆盖")
+	}
+}
@@ -0,0 +1,121 @@
+package timeout
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"concurrency-web-app/backend/batch"
+	"concurrency-web-app/backend/services"
+)
+
+// 单个订单超时只让该订单失败，批次中的其他订单照常完成并出现在结果中
+func TestPerTaskTimeoutFailsSingleTask(t *testing.T) {
+	s := &services.OrderProcessService{
+		MaxConcurrency: 2,
+		Timeout:        5 * time.Second,
+		PerTaskTimeout: 200 * time.Millisecond,
+	}
+	// 订单模拟耗时为 100+ID*10 毫秒：ID 1 为 110ms，ID 30 为 400ms
+	orders := []services.OrderTask{
+		{ID: 1, CustomerID: "c1", ProductName: "p", Quantity: 1, Price: 1},
+		{ID: 30, CustomerID: "c2", ProductName: "p", Quantity: 1, Price: 1},
+	}
+
+	result := s.BatchProcessOrders(context.Background(), orders)
+	if len(result.Results) != 2 {
+		t.Fatalf("期望两个订单都有结果，实际 %d 个", len(result.Results))
+	}
+	if result.SuccessTasks != 1 || result.FailedTasks != 1 {
+		t.Fatalf("期望1个成功1个失败，实际成功 %d 失败 %d", result.SuccessTasks, result.FailedTasks)
+	}
+	// 结果中的 ID 为任务序号，序号 1 为慢订单
+	for _, r := range result.Results {
+		if r.ID == 1 && !strings.Contains(r.Error, "单个任务超时") {
+			t.Errorf("慢订单的错误应注明单个任务超时，实际: %s", r.Error)
+		}
+		if r.ID == 1 && r.Duration > 300 {
+			t.Errorf("慢订单应在预算到期时结束，实际耗时 %dms", r.Duration)
+		}
+	}
+}
+
+// 批次超时时结果仍包含全部任务：已完成的照常返回，执行中的记为超时，未开始的记为未开始
+func TestBatchTimeoutReportsEveryTask(t *testing.T) {
+	for _, mode := range []batch.Mode{batch.ModePerTask, batch.ModeWorkerPool} {
+		s := &services.OrderProcessService{
+			Mode:           mode,
+			MaxConcurrency: 1,
+			Timeout:        300 * time.Millisecond,
+		}
+		// 每个订单约 110-150ms，并发数为1时 300ms 内只能完成前两个
+		var orders []services.OrderTask
+		for id := 1; id <= 5; id++ {
+			orders = append(orders, services.OrderTask{ID: id, CustomerID: "c", ProductName: "p", Quantity: 1, Price: 1})
+		}
+
+		result := s.BatchProcessOrders(context.Background(), orders)
+		if len(result.Results) != len(orders) {
+			t.Fatalf("模式 %v: 期望 %d 条结果，实际 %d 条", mode, len(orders), len(result.Results))
+		}
+		// 工作池按提交顺序派发，可以确定每个任务的状态；每任务一协程时派发顺序不确定，只检查计数
+		if mode == batch.ModeWorkerPool {
+			want := []string{
+				services.TaskStatusSuccess, services.TaskStatusSuccess, services.TaskStatusTimeout,
+				services.TaskStatusNotStarted, services.TaskStatusNotStarted,
+			}
+			for i, r := range result.Results {
+				if r.ID != i || r.Status != want[i] {
+					t.Errorf("任务 %d 状态为 %s，期望 %s", r.ID, r.Status, want[i])
+				}
+			}
+		}
+		if result.SuccessTasks != 2 || result.TimeoutTasks != 1 || result.NotStartedTasks != 2 || result.FailedTasks != 3 {
+			t.Errorf("模式 %v: 计数不正确 success=%d timeout=%d not_started=%d failed=%d",
+				mode, result.SuccessTasks, result.TimeoutTasks, result.NotStartedTasks, result.FailedTasks)
+		}
+
+		// 逐个输出结果的批次不保留明细，汇总计数同样覆盖没有结果的任务
+		each := s.EachOrder(context.Background(), orders, func(services.TaskResult) {})
+		if each.SuccessTasks != 2 || each.TimeoutTasks != 1 || each.NotStartedTasks != 2 {
+			t.Errorf("模式 %v: 流式汇总计数不正确 success=%d timeout=%d not_started=%d",
+				mode, each.SuccessTasks, each.TimeoutTasks, each.NotStartedTasks)
+		}
+	}
+}
+
+// 服务关闭等待超时后取消执行中的批次：已完成的结果保留，其余任务记为取消
+func TestShutdownCancelsRunningBatch(t *testing.T) {
+	s := &services.OrderProcessService{Mode: batch.ModeWorkerPool, MaxConcurrency: 1, Timeout: 5 * time.Second}
+	var orders []services.OrderTask
+	for id := 1; id <= 5; id++ {
+		orders = append(orders, services.OrderTask{ID: id, CustomerID: "c", ProductName: "p", Quantity: 1, Price: 1})
+	}
+
+	jobs := services.NewJobManager(nil)
+	job, ctx := jobs.Start(context.Background(), "order", "", len(orders))
+	jobs.Drain()
+	select {
+	case <-jobs.Draining():
+	default:
+		t.Fatal("Drain 之后应处于关闭状态")
+	}
+	time.AfterFunc(300*time.Millisecond, func() {
+		if n := jobs.CancelRunning(services.CancelModeShutdown); n != 1 {
+			t.Errorf("期望取消1个批次，实际 %d 个", n)
+		}
+	})
+
+	result := s.BatchProcessOrders(ctx, orders)
+	jobs.Finish(job, result)
+	if result.SuccessTasks != 2 || result.CancelledTasks != 3 || len(result.Results) != len(orders) {
+		t.Fatalf("计数不正确 success=%d cancelled=%d results=%d", result.SuccessTasks, result.CancelledTasks, len(result.Results))
+	}
+	if info := job.Info(); info.Status != services.JobStatusCancelled || info.CancelMode != services.CancelModeShutdown {
+		t.Errorf("任务状态为 %s/%s，期望 cancelled/shutdown", info.Status, info.CancelMode)
+	}
+	if jobs.CancelRunning(services.CancelModeShutdown) != 0 {
+		t.Error("已结束的批次不应再次取消")
+	}
+}
@@ -0,0 +1,66 @@
+package warmup
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"concurrency-web-app/backend/models"
+	"concurrency-web-app/backend/services"
+)
+
+// 预热后数据库连接和到下游主机的连接留在空闲池中，无法连接的主机只使该项失败
+func TestWarmup(t *testing.T) {
+	db, _, err := models.InitDB(models.DBConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "warmup.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var heads, conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		}
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	apis := &services.APICallService{MaxConcurrency: 2}
+	warmup := &services.Warmup{DB: db, DBConns: 3, APIs: apis, Hosts: []string{server.URL, down.URL}, Workers: 100}
+	report := warmup.Run(context.Background())
+	if report.OK || len(report.Steps) != 5 {
+		t.Fatalf("预热结果 %+v", report)
+	}
+	for _, step := range report.Steps {
+		if ok := step.Name != "host:"+down.URL; step.OK != ok {
+			t.Errorf("%s: %+v", step.Name, step)
+		}
+	}
+
+	sqlDB, _ := db.DB()
+	if idle := sqlDB.Stats().Idle; idle < 3 {
+		t.Errorf("空闲的数据库连接 %d，期望至少 3 个", idle)
+	}
+	if heads.Load() != 1 {
+		t.Errorf("HEAD 请求 %d 次", heads.Load())
+	}
+
+	// 之后的调用复用预热建立的连接
+	result := apis.BatchCallAPIs(context.Background(), []services.APICallTask{{URL: server.URL, Method: "GET"}})
+	if result.SuccessTasks != 1 {
+		t.Fatalf("调用结果 %+v", result)
+	}
+	if conns.Load() != 1 {
+		t.Errorf("期望复用预热的连接，实际建立了 %d 个连接", conns.Load())
+	}
+}